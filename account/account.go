@@ -106,4 +106,6 @@ type AccountMetadata struct {
 	Key       []byte //PrivateKey in encrypted
 	EncAlg    string //Encrypt alg of private key
 	Hash      string //Hash alg
+	HDSeed    string //Id of the hd seed, empty if not derived
+	HDPath    string //Derivation path of hd wallet
 }
@@ -38,6 +38,10 @@ type Client interface {
 	NewAccount(label string, typeCode keypair.KeyType, curveCode byte, sigScheme s.SignatureScheme, passwd []byte) (*Account, error)
 	//ImportAccount import a already exist account to wallet
 	ImportAccount(accMeta *AccountMetadata) error
+	//ImportMnemonic save the encrypted mnemonic to wallet, return the id of the hd seed
+	ImportMnemonic(mnemonic string, passwd []byte) (string, error)
+	//DeriveAccount derive the next account of the hd seed and add it to wallet
+	DeriveAccount(seedId, label string, typeCode keypair.KeyType, curveCode byte, sigScheme s.SignatureScheme, passwd []byte) (*Account, error)
 	//GetAccountByAddress return account object by address
 	GetAccountByAddress(address string, passwd []byte) (*Account, error)
 	//GetAccountByLabel return account object by label
@@ -214,6 +218,8 @@ func (this *ClientImpl) ImportAccount(accMeta *AccountMetadata) error {
 	accData.Hash = accMeta.Hash
 	accData.Salt = accMeta.Salt
 	accData.Param = map[string]string{"curve": accMeta.Curve}
+	accData.HDSeed = accMeta.HDSeed
+	accData.HDPath = accMeta.HDPath
 
 	oldAccMeta := this.GetAccountMetadataByLabel(accData.Label)
 	if oldAccMeta != nil {
@@ -223,6 +229,108 @@ func (this *ClientImpl) ImportAccount(accMeta *AccountMetadata) error {
 	return this.addAccountData(accData)
 }
 
+func (this *ClientImpl) ImportMnemonic(mnemonic string, passwd []byte) (string, error) {
+	if len(passwd) == 0 {
+		return "", fmt.Errorf("password cannot empty")
+	}
+	seed, err := MnemonicToSeed(mnemonic)
+	if err != nil {
+		return "", err
+	}
+	id := HDSeedId(seed)
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	if this.walletData.GetHDSeed(id) != nil {
+		return id, nil
+	}
+	seedData, err := encryptMnemonic(strings.Join(strings.Fields(mnemonic), " "), id, passwd, this.walletData.Scrypt)
+	if err != nil {
+		return "", fmt.Errorf("encrypt mnemonic error: %s", err)
+	}
+	bkSeeds := this.walletData.HDSeeds
+	this.walletData.HDSeeds = append(append([]*HDSeedData{}, bkSeeds...), seedData)
+	err = this.save()
+	if err != nil {
+		this.walletData.HDSeeds = bkSeeds
+		return "", fmt.Errorf("save error: %s", err)
+	}
+	return id, nil
+}
+
+func (this *ClientImpl) DeriveAccount(seedId, label string, typeCode keypair.KeyType, curveCode byte, sigScheme s.SignatureScheme, passwd []byte) (*Account, error) {
+	this.lock.RLock()
+	seedData := this.walletData.GetHDSeed(seedId)
+	this.lock.RUnlock()
+	if seedData == nil {
+		return nil, fmt.Errorf("cannot find hd seed: %s", seedId)
+	}
+	mnemonic, err := decryptMnemonic(seedData, passwd)
+	if err != nil {
+		return nil, err
+	}
+	seed, err := MnemonicToSeed(mnemonic)
+	if err != nil {
+		return nil, err
+	}
+	for index := this.nextHDIndex(seedId, typeCode); index < HD_HARDENED; index++ {
+		path := HDPath(typeCode, index)
+		prvkey, pubkey, err := DeriveKeyPair(seed, path, typeCode, curveCode)
+		if err != nil {
+			return nil, err
+		}
+		address := types.AddressFromPubKey(pubkey)
+		addressBase58 := address.ToBase58()
+		if this.GetAccountMetadataByAddress(addressBase58) != nil {
+			//already imported in other way
+			ccntminue
+		}
+		prvSecret, err := keypair.EncryptWithCustomScrypt(prvkey, addressBase58, passwd, this.walletData.Scrypt)
+		if err != nil {
+			return nil, fmt.Errorf("encryptPrivateKey error: %s", err)
+		}
+		accData := &AccountData{}
+		accData.Label = label
+		accData.SetKeyPair(prvSecret)
+		accData.SigSch = sigScheme.Name()
+		accData.PubKey = hex.EncodeToString(keypair.SerializePublicKey(pubkey))
+		accData.HDSeed = seedId
+		accData.HDPath = path
+
+		err = this.addAccountData(accData)
+		if err != nil {
+			return nil, err
+		}
+		return &Account{
+			PrivateKey: prvkey,
+			PublicKey:  pubkey,
+			Address:    address,
+			SigScheme:  sigScheme,
+		}, nil
+	}
+	return nil, fmt.Errorf("no more account can be derived from hd seed: %s", seedId)
+}
+
+//nextHDIndex return the index after the last derived account of the key type
+func (this *ClientImpl) nextHDIndex(seedId string, typeCode keypair.KeyType) uint32 {
+	this.lock.RLock()
+	defer this.lock.RUnlock()
+	next := uint32(0)
+	for _, accData := range this.walletData.Accounts {
+		if accData.HDSeed != seedId {
+			ccntminue
+		}
+		indexes, err := ParseHDPath(accData.HDPath)
+		if err != nil || len(indexes) == 0 {
+			ccntminue
+		}
+		index := indexes[len(indexes)-1] &^ HD_HARDENED
+		if accData.HDPath == HDPath(typeCode, index) && index >= next {
+			next = index + 1
+		}
+	}
+	return next
+}
+
 func (this *ClientImpl) GetAccountByAddress(address string, passwd []byte) (*Account, error) {
 	this.lock.RLock()
 	defer this.lock.RUnlock()
@@ -341,6 +449,8 @@ func (this *ClientImpl) getAccountMetadata(accData *AccountData) *AccountMetadat
 	accMeta.Hash = accData.Hash
 	accMeta.Curve = accData.Param["curve"]
 	accMeta.Salt = accData.Salt
+	accMeta.HDSeed = accData.HDSeed
+	accMeta.HDPath = accData.HDPath
 	return accMeta
 }
 
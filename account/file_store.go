@@ -38,6 +38,8 @@ type AccountData struct {
 	SigSch    string `json:"signatureScheme"`
 	IsDefault bool   `json:"isDefault"`
 	Lock      bool   `json:"lock"`
+	HDSeed    string `json:"hdSeed,omitempty"`
+	HDPath    string `json:"hdPath,omitempty"`
 }
 
 func (this *AccountData) SetKeyPair(keyinfo *keypair.ProtectedKey) {
@@ -66,12 +68,21 @@ func (this *AccountData) SetLabel(label string) {
 	this.Label = label
 }
 
+/** HDSeedData - the encrypted mnemonic of hd wallet **/
+type HDSeedData struct {
+	Id     string               `json:"id"`
+	Key    []byte               `json:"key"`
+	Salt   []byte               `json:"salt"`
+	Scrypt *keypair.ScryptParam `json:"scrypt"`
+}
+
 type WalletData struct {
 	Name       string               `json:"name"`
 	Version    string               `json:"version"`
 	Scrypt     *keypair.ScryptParam `json:"scrypt"`
 	Identities []Identity           `json:"identities,omitempty"`
 	Accounts   []*AccountData       `json:"accounts,omitempty"`
+	HDSeeds    []*HDSeedData        `json:"hdSeeds,omitempty"`
 	Extra      string               `json:"extra,omitempty"`
 }
 
@@ -99,6 +110,7 @@ func (this *WalletData) Clone() *WalletData {
 		w.Accounts[i] = &ac
 	}
 	w.Identities = this.Identities
+	w.HDSeeds = this.HDSeeds
 	w.Extra = this.Extra
 	return &w
 }
@@ -203,6 +215,15 @@ func (this *WalletData) reencrypt(passwords [][]byte, param *keypair.ScryptParam
 	return nil
 }
 
+func (this *WalletData) GetHDSeed(id string) *HDSeedData {
+	for _, seed := range this.HDSeeds {
+		if seed.Id == id {
+			return seed
+		}
+	}
+	return nil
+}
+
 func (this *WalletData) AddIdentity(id *Identity) {
 	this.Identities = append(this.Identities, *id)
 }
@@ -0,0 +1,276 @@
+/*
+ * Copyright (C) 2018 The cntm Authors
+ * This file is part of The cntm library.
+ *
+ * The cntm is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The cntm is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The cntm.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package account
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/conntectome/cntm-crypto/keypair"
+	"github.com/tyler-smith/go-bip39"
+	"golang.org/x/crypto/ed25519"
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	HD_COIN_TYPE      = 1024 //registered BIP-44 coin type
+	HD_HARDENED       = 0x80000000
+	HD_ENTROPY_BITS   = 256 //24 words mnemonic
+	HD_SEED_ID_LEN    = 8
+	HD_PATH_ROOT      = "m"
+	HD_PATH_SEPARATOR = "/"
+)
+
+//hdNode is a SLIP-10 extended private key
+type hdNode struct {
+	key       []byte
+	chainCode []byte
+}
+
+//NewMnemonic generate a new BIP-39 mnemonic
+func NewMnemonic() (string, error) {
+	entropy, err := bip39.NewEntropy(HD_ENTROPY_BITS)
+	if err != nil {
+		return "", err
+	}
+	return bip39.NewMnemonic(entropy)
+}
+
+//MnemonicToSeed check the mnemonic and return the BIP-39 seed of it
+func MnemonicToSeed(mnemonic string) ([]byte, error) {
+	mnemonic = strings.Join(strings.Fields(mnemonic), " ")
+	if !bip39.IsMnemonicValid(mnemonic) {
+		return nil, fmt.Errorf("invalid mnemonic")
+	}
+	return bip39.NewSeed(mnemonic, ""), nil
+}
+
+//HDSeedId return the id of seed, which does not reveal the seed
+func HDSeedId(seed []byte) string {
+	h := sha256.Sum256(seed)
+	h = sha256.Sum256(h[:])
+	return hex.EncodeToString(h[:HD_SEED_ID_LEN])
+}
+
+//HDPath return the BIP-44 path of the index-th account. Ed25519 only supports
+//hardened derivation, so all the levels of its path are hardened
+func HDPath(keyType keypair.KeyType, index uint32) string {
+	if keyType == keypair.PK_EDDSA {
+		return fmt.Sprintf("m/44'/%d'/0'/0'/%d'", HD_COIN_TYPE, index)
+	}
+	return fmt.Sprintf("m/44'/%d'/0'/0/%d", HD_COIN_TYPE, index)
+}
+
+//ParseHDPath parse path like m/44'/1024'/0'/0/0 to child indexes
+func ParseHDPath(path string) ([]uint32, error) {
+	parts := strings.Split(strings.TrimSpace(path), HD_PATH_SEPARATOR)
+	if len(parts) == 0 || parts[0] != HD_PATH_ROOT {
+		return nil, fmt.Errorf("invalid hd path: %s", path)
+	}
+	indexes := make([]uint32, 0, len(parts)-1)
+	for _, part := range parts[1:] {
+		hardened := strings.HasSuffix(part, "'") || strings.HasSuffix(part, "H")
+		if hardened {
+			part = part[:len(part)-1]
+		}
+		index, err := strconv.ParseUint(part, 10, 31)
+		if err != nil {
+			return nil, fmt.Errorf("invalid hd path: %s", path)
+		}
+		if hardened {
+			index += HD_HARDENED
+		}
+		indexes = append(indexes, uint32(index))
+	}
+	return indexes, nil
+}
+
+//DeriveKeyPair derive the key pair at path from seed by SLIP-10. SM2 is not
+//covered by SLIP-10, it follows the same scheme with the sm2p256v1 curve
+func DeriveKeyPair(seed []byte, path string, keyType keypair.KeyType, curveCode byte) (keypair.PrivateKey, keypair.PublicKey, error) {
+	indexes, err := ParseHDPath(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	var curve elliptic.Curve
+	var masterKey string
+	switch {
+	case keyType == keypair.PK_ECDSA && curveCode == keypair.P256:
+		curve, masterKey = elliptic.P256(), "Nist256p1 seed"
+	case keyType == keypair.PK_SM2 && curveCode == keypair.SM2P256V1:
+		curve, err = keypair.GetCurve(curveCode)
+		if err != nil {
+			return nil, nil, err
+		}
+		masterKey = "sm2p256v1 seed"
+	case keyType == keypair.PK_EDDSA && curveCode == keypair.ED25519:
+		masterKey = "ed25519 seed"
+	default:
+		return nil, nil, fmt.Errorf("hd derivation does not support key type %d with curve %d", keyType, curveCode)
+	}
+
+	node := newMasterNode(seed, masterKey, curve)
+	for _, index := range indexes {
+		if curve == nil && index < HD_HARDENED {
+			return nil, nil, fmt.Errorf("ed25519 only supports hardened derivation: %s", path)
+		}
+		node = node.child(index, curve)
+	}
+
+	buf := bytes.NewBuffer(nil)
+	buf.WriteByte(byte(keyType))
+	buf.WriteByte(curveCode)
+	if curve == nil {
+		buf.Write(ed25519.NewKeyFromSeed(node.key))
+	} else {
+		buf.Write(node.key)
+		buf.Write(compressPoint(curve, node.key))
+	}
+	prvkey, err := keypair.DeserializePrivateKey(buf.Bytes())
+	if err != nil {
+		return nil, nil, fmt.Errorf("deserialize derived key error: %s", err)
+	}
+	return prvkey, prvkey.Public(), nil
+}
+
+//newMasterNode return the master node, curve is nil for ed25519
+func newMasterNode(seed []byte, masterKey string, curve elliptic.Curve) *hdNode {
+	mac := hmac.New(sha512.New, []byte(masterKey))
+	mac.Write(seed)
+	I := mac.Sum(nil)
+	for curve != nil && !validKey(curve, I[:32]) {
+		mac.Reset()
+		mac.Write(I)
+		I = mac.Sum(nil)
+	}
+	return &hdNode{key: I[:32], chainCode: I[32:]}
+}
+
+func (this *hdNode) child(index uint32, curve elliptic.Curve) *hdNode {
+	var seq [4]byte
+	binary.BigEndian.PutUint32(seq[:], index)
+	data := make([]byte, 0, 37)
+	if index >= HD_HARDENED {
+		data = append(data, 0)
+		data = append(data, this.key...)
+	} else {
+		data = append(data, compressPoint(curve, this.key)...)
+	}
+	data = append(data, seq[:]...)
+
+	for {
+		mac := hmac.New(sha512.New, this.chainCode)
+		mac.Write(data)
+		I := mac.Sum(nil)
+		if curve == nil {
+			return &hdNode{key: I[:32], chainCode: I[32:]}
+		}
+		n := curve.Params().N
+		il := new(big.Int).SetBytes(I[:32])
+		if il.Cmp(n) < 0 {
+			k := il.Add(il, new(big.Int).SetBytes(this.key))
+			k.Mod(k, n)
+			if k.Sign() != 0 {
+				return &hdNode{key: paddedBytes(k), chainCode: I[32:]}
+			}
+		}
+		data = append([]byte{1}, I[32:]...)
+		data = append(data, seq[:]...)
+	}
+}
+
+func validKey(curve elliptic.Curve, key []byte) bool {
+	k := new(big.Int).SetBytes(key)
+	return k.Sign() != 0 && k.Cmp(curve.Params().N) < 0
+}
+
+func compressPoint(curve elliptic.Curve, key []byte) []byte {
+	x, y := curve.ScalarBaseMult(key)
+	buf := make([]byte, 0, 33)
+	buf = append(buf, byte(2+y.Bit(0)))
+	return append(buf, paddedBytes(x)...)
+}
+
+func paddedBytes(k *big.Int) []byte {
+	buf := make([]byte, 32)
+	b := k.Bytes()
+	copy(buf[32-len(b):], b)
+	return buf
+}
+
+//encryptMnemonic encrypt mnemonic by AES-GCM with the key derived from passwd by scrypt
+func encryptMnemonic(mnemonic, id string, passwd []byte, param *keypair.ScryptParam) (*HDSeedData, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	aead, nonce, err := mnemonicCipher(passwd, salt, param)
+	if err != nil {
+		return nil, err
+	}
+	return &HDSeedData{
+		Id:     id,
+		Key:    aead.Seal(nil, nonce, []byte(mnemonic), []byte(id)),
+		Salt:   salt,
+		Scrypt: param,
+	}, nil
+}
+
+func decryptMnemonic(seedData *HDSeedData, passwd []byte) (string, error) {
+	aead, nonce, err := mnemonicCipher(passwd, seedData.Salt, seedData.Scrypt)
+	if err != nil {
+		return "", err
+	}
+	mnemonic, err := aead.Open(nil, nonce, seedData.Key, []byte(seedData.Id))
+	if err != nil {
+		return "", fmt.Errorf("decrypt mnemonic failed, wrong password")
+	}
+	return string(mnemonic), nil
+}
+
+func mnemonicCipher(passwd, salt []byte, param *keypair.ScryptParam) (cipher.AEAD, []byte, error) {
+	if param == nil || param.DKLen < 44 {
+		return nil, nil, fmt.Errorf("invalid scrypt param")
+	}
+	dkey, err := scrypt.Key(passwd, salt, param.N, param.R, param.P, param.DKLen)
+	if err != nil {
+		return nil, nil, err
+	}
+	block, err := aes.NewCipher(dkey[len(dkey)-32:])
+	if err != nil {
+		return nil, nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, nil, err
+	}
+	return aead, dkey[:12], nil
+}
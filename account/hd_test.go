@@ -0,0 +1,137 @@
+/*
+ * Copyright (C) 2018 The cntm Authors
+ * This file is part of The cntm library.
+ *
+ * The cntm is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The cntm is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The cntm.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package account
+
+import (
+	"crypto/elliptic"
+	"encoding/hex"
+	"os"
+	"testing"
+
+	"github.com/conntectome/cntm-crypto/keypair"
+	s "github.com/conntectome/cntm-crypto/signature"
+	"github.com/stretchr/testify/assert"
+)
+
+const testMnemonic = "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"
+
+//test vector 1 of SLIP-10
+func TestSlip10Vectors(t *testing.T) {
+	seed, _ := hex.DecodeString("000102030405060708090a0b0c0d0e0f")
+
+	node := newMasterNode(seed, "ed25519 seed", nil)
+	assert.Equal(t, "2b4be7f19ee27bbf30c667b642d5f4aa69fd169872f8fc3059c08ebae2eb19e7", hex.EncodeToString(node.key))
+	assert.Equal(t, "90046a93de5380a72b5e45010748567d5ea02bbf6522f979e05c0d8d8ca9fffb", hex.EncodeToString(node.chainCode))
+	node = node.child(HD_HARDENED, nil)
+	assert.Equal(t, "68e0fe46dfb67e368c75379acec591dad19df3cde26e63b93a8e704f1dade7a3", hex.EncodeToString(node.key))
+
+	curve := elliptic.P256()
+	node = newMasterNode(seed, "Nist256p1 seed", curve)
+	assert.Equal(t, "612091aaa12e22dd2abef664f8a01a82cae99ad7441b7ef8110424915c268bc2", hex.EncodeToString(node.key))
+	assert.Equal(t, "beeb672fe4621673f722f38529c07392fecaa61015c80c34f29ce8b41b3cb6ea", hex.EncodeToString(node.chainCode))
+	node = node.child(HD_HARDENED, curve)
+	assert.Equal(t, "6939694369114c67917a182c59ddb8cafc3004e63ca5d3b84403ba8613debc0c", hex.EncodeToString(node.key))
+	assert.Equal(t, "0384610f5ecffe8fda089363a41f56a5c7ffc1d81b59a612d0d649b2d22355590c", hex.EncodeToString(compressPoint(curve, node.key)))
+	node = node.child(1, curve)
+	assert.Equal(t, "284e9d38d07d21e4e281b645089a94f4cf5a5a81369acf151a1c3a57f18b2129", hex.EncodeToString(node.key))
+	assert.Equal(t, "03526c63f8d0b4bbbf9c80df553fe66742df4676b241dabefdef67733e070f6844", hex.EncodeToString(compressPoint(curve, node.key)))
+}
+
+func TestParseHDPath(t *testing.T) {
+	indexes, err := ParseHDPath(HDPath(keypair.PK_ECDSA, 3))
+	assert.Nil(t, err)
+	assert.Equal(t, []uint32{44 + HD_HARDENED, HD_COIN_TYPE + HD_HARDENED, HD_HARDENED, 0, 3}, indexes)
+	indexes, err = ParseHDPath(HDPath(keypair.PK_EDDSA, 3))
+	assert.Nil(t, err)
+	assert.Equal(t, uint32(3+HD_HARDENED), indexes[4])
+
+	for _, path := range []string{"", "44'/0", "m/-1", "m/2147483648", "m/a'"} {
+		_, err = ParseHDPath(path)
+		assert.NotNil(t, err, path)
+	}
+}
+
+func TestDeriveKeyPair(t *testing.T) {
+	seed, err := MnemonicToSeed(testMnemonic)
+	assert.Nil(t, err)
+	_, err = MnemonicToSeed("abandon abandon abandon")
+	assert.NotNil(t, err)
+
+	keyTypes := []struct {
+		keyType keypair.KeyType
+		curve   byte
+	}{
+		{keypair.PK_ECDSA, keypair.P256},
+		{keypair.PK_SM2, keypair.SM2P256V1},
+		{keypair.PK_EDDSA, keypair.ED25519},
+	}
+	for _, kt := range keyTypes {
+		prv1, pub1, err := DeriveKeyPair(seed, HDPath(kt.keyType, 0), kt.keyType, kt.curve)
+		assert.Nil(t, err)
+		assert.Equal(t, keypair.SerializePublicKey(prv1.Public()), keypair.SerializePublicKey(pub1))
+		_, pub2, err := DeriveKeyPair(seed, HDPath(kt.keyType, 0), kt.keyType, kt.curve)
+		assert.Nil(t, err)
+		assert.Equal(t, keypair.SerializePublicKey(pub1), keypair.SerializePublicKey(pub2))
+		_, pub3, err := DeriveKeyPair(seed, HDPath(kt.keyType, 1), kt.keyType, kt.curve)
+		assert.Nil(t, err)
+		assert.NotEqual(t, keypair.SerializePublicKey(pub1), keypair.SerializePublicKey(pub3))
+	}
+
+	_, _, err = DeriveKeyPair(seed, HDPath(keypair.PK_ECDSA, 0), keypair.PK_ECDSA, keypair.P384)
+	assert.NotNil(t, err)
+	_, _, err = DeriveKeyPair(seed, HDPath(keypair.PK_ECDSA, 0), keypair.PK_EDDSA, keypair.ED25519)
+	assert.NotNil(t, err)
+}
+
+func TestClientDeriveAccount(t *testing.T) {
+	seedId, err := testWallet.ImportMnemonic(testMnemonic, testPasswd)
+	assert.Nil(t, err)
+	seedId2, err := testWallet.ImportMnemonic(testMnemonic, testPasswd)
+	assert.Nil(t, err)
+	assert.Equal(t, seedId, seedId2)
+
+	acc1, err := testWallet.DeriveAccount(seedId, "hd1", keypair.PK_ECDSA, keypair.P256, s.SHA256withECDSA, testPasswd)
+	assert.Nil(t, err)
+	acc2, err := testWallet.DeriveAccount(seedId, "hd2", keypair.PK_ECDSA, keypair.P256, s.SHA256withECDSA, testPasswd)
+	assert.Nil(t, err)
+	assert.NotEqual(t, acc1.Address, acc2.Address)
+	accMeta := testWallet.GetAccountMetadataByAddress(acc2.Address.ToBase58())
+	assert.Equal(t, seedId, accMeta.HDSeed)
+	assert.Equal(t, HDPath(keypair.PK_ECDSA, 1), accMeta.HDPath)
+
+	_, err = testWallet.DeriveAccount(seedId, "hd3", keypair.PK_ECDSA, keypair.P256, s.SHA256withECDSA, []byte("wrong"))
+	assert.NotNil(t, err)
+
+	path := "./wallet_recover_test.dat"
+	defer os.Remove(path)
+	wallet, err := Open(path)
+	assert.Nil(t, err)
+	seedId2, err = wallet.ImportMnemonic(testMnemonic, testPasswd)
+	assert.Nil(t, err)
+	assert.Equal(t, seedId, seedId2)
+	acc, err := wallet.DeriveAccount(seedId, "", keypair.PK_ECDSA, keypair.P256, s.SHA256withECDSA, testPasswd)
+	assert.Nil(t, err)
+	assert.Equal(t, acc1.Address, acc.Address)
+
+	wallet, err = Open(path)
+	assert.Nil(t, err)
+	acc, err = wallet.DeriveAccount(seedId, "", keypair.PK_ECDSA, keypair.P256, s.SHA256withECDSA, testPasswd)
+	assert.Nil(t, err)
+	assert.Equal(t, acc2.Address, acc.Address)
+}
@@ -0,0 +1,184 @@
+/*
+ * Copyright (C) 2018 The cntmology Authors
+ * This file is part of The cntmology library.
+ *
+ * The cntmology is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The cntmology is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * alcntm with The cntmology.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package signer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+
+	"github.com/cntmio/cntmology/common"
+)
+
+// MAX_PROTECTED_HEIGHTS is the number of latest heights remembered, signing below them is refused
+const MAX_PROTECTED_HEIGHTS = 1024
+
+type proposal struct {
+	Block      common.Uint256 `json:"block"`
+	EmptyBlock common.Uint256 `json:"empty_block"`
+}
+
+// signedRound is what was signed at a height, all in the view of the height
+type signedRound struct {
+	View           uint32          `json:"view"`
+	Proposal       *proposal       `json:"proposal,omitempty"`
+	Endorsed       *common.Uint256 `json:"endorsed,omitempty"`
+	EndorsedEmpty  *common.Uint256 `json:"endorsed_empty,omitempty"`
+	Committed      *common.Uint256 `json:"committed,omitempty"`
+	CommittedEmpty *common.Uint256 `json:"committed_empty,omitempty"`
+}
+
+// SlashingProtection remembers the proposals, endorsements and commitments signed at each height, so that
+// different blocks are never signed at the same height, even across restarts of the daemon. A height is
+// in a single view, signing in another view at a signed height is refused
+type SlashingProtection struct {
+	lock   sync.Mutex
+	file   string // empty if not persisted
+	rounds map[uint32]*signedRound
+}
+
+// NewSlashingProtection loads the signed rounds from file, which is created if not exist
+func NewSlashingProtection(file string) (*SlashingProtection, error) {
+	self := &SlashingProtection{file: file, rounds: make(map[uint32]*signedRound)}
+	if file == "" {
+		return self, nil
+	}
+	data, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return self, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &self.rounds); err != nil {
+		return nil, fmt.Errorf("invalid slashing protection file %s: %s", file, err)
+	}
+	return self, nil
+}
+
+// CheckProposal records the proposal at height in view, return error if a different one was signed at height
+func (self *SlashingProtection) CheckProposal(view, height uint32, block, emptyBlock common.Uint256) error {
+	p := &proposal{Block: block, EmptyBlock: emptyBlock}
+	return self.check(view, height, func(round *signedRound) error {
+		if round.Proposal != nil && *round.Proposal != *p {
+			return fmt.Errorf("refuse to propose block %s at height %d, block %s has been proposed",
+				block.ToHexString(), height, round.Proposal.Block.ToHexString())
+		}
+		round.Proposal = p
+		return nil
+	})
+}
+
+// CheckEndorsement records the endorsement at height in view, return error if a different block was endorsed
+// at height. The empty block is endorsed apart from the block
+func (self *SlashingProtection) CheckEndorsement(view, height uint32, block common.Uint256, forEmpty bool) error {
+	return self.check(view, height, func(round *signedRound) error {
+		signed := &round.Endorsed
+		if forEmpty {
+			signed = &round.EndorsedEmpty
+		}
+		if *signed != nil && **signed != block {
+			return fmt.Errorf("refuse to endorse block %s at height %d, block %s has been endorsed",
+				block.ToHexString(), height, (*signed).ToHexString())
+		}
+		*signed = &block
+		return nil
+	})
+}
+
+// CheckCommit records the commitment at height in view, return error if a different block was committed
+// at height. The empty block is committed apart from the block
+func (self *SlashingProtection) CheckCommit(view, height uint32, block common.Uint256, forEmpty bool) error {
+	return self.check(view, height, func(round *signedRound) error {
+		signed := &round.Committed
+		if forEmpty {
+			signed = &round.CommittedEmpty
+		}
+		if *signed != nil && **signed != block {
+			return fmt.Errorf("refuse to commit block %s at height %d, block %s has been committed",
+				block.ToHexString(), height, (*signed).ToHexString())
+		}
+		*signed = &block
+		return nil
+	})
+}
+
+// check applies record to the round signed at height, the round is persisted if recorded
+func (self *SlashingProtection) check(view, height uint32, record func(round *signedRound) error) error {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	prev, ok := self.rounds[height]
+	if !ok {
+		if len(self.rounds) >= MAX_PROTECTED_HEIGHTS && height < self.lowest() {
+			return fmt.Errorf("refuse to sign block at height %d lower than protected heights", height)
+		}
+		prev = &signedRound{View: view}
+	}
+	if prev.View != view {
+		return fmt.Errorf("refuse to sign block at height %d in view %d, view %d has been signed",
+			height, view, prev.View)
+	}
+	round := *prev
+	if err := record(&round); err != nil {
+		return err
+	}
+
+	self.rounds[height] = &round
+	for len(self.rounds) > MAX_PROTECTED_HEIGHTS {
+		delete(self.rounds, self.lowest())
+	}
+	if err := self.persist(); err != nil {
+		if ok {
+			self.rounds[height] = prev
+		} else {
+			delete(self.rounds, height)
+		}
+		return fmt.Errorf("persist slashing protection: %s", err)
+	}
+	return nil
+}
+
+func (self *SlashingProtection) lowest() uint32 {
+	first := true
+	var lowest uint32
+	for height := range self.rounds {
+		if first || height < lowest {
+			lowest = height
+			first = false
+		}
+	}
+	return lowest
+}
+
+func (self *SlashingProtection) persist() error {
+	if self.file == "" {
+		return nil
+	}
+	data, err := json.Marshal(self.rounds)
+	if err != nil {
+		return err
+	}
+	tmp := self.file + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, self.file)
+}
@@ -0,0 +1,218 @@
+/*
+ * Copyright (C) 2018 The cntmology Authors
+ * This file is part of The cntmology library.
+ *
+ * The cntmology is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The cntmology is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * alcntm with The cntmology.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package signer
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+const (
+	NONCE_LEN       = 32
+	MAC_LEN         = sha256.Size
+	MAX_FRAME_LEN   = 2 * 1024 * 1024  // the maximum length of request and response, enough for the max tx
+	REQUEST_TIMEOUT = 10 * time.Second // deadline of one request, including the auth handshake
+)
+
+// request methods. Block hashes, digests and tx hashes are only signed by the methods of their own,
+// which get the data hashed by the signer, so that each of them is checked before signing
+const (
+	METHOD_PUBKEY               = "pubkey"
+	METHOD_SIGN                 = "sign"
+	METHOD_SIGN_TX              = "sign_tx"
+	METHOD_SIGN_PROPOSAL        = "sign_proposal"
+	METHOD_SIGN_ENDORSEMENT     = "sign_endorsement"
+	METHOD_SIGN_COMMIT          = "sign_commit"
+	METHOD_SIGN_CROSS_CHAIN_MSG = "sign_cross_chain_msg"
+	METHOD_SIGN_STATE_ROOT      = "sign_state_root"
+	METHOD_VRF                  = "vrf"
+)
+
+type request struct {
+	Method     string `json:"method"`
+	Data       []byte `json:"data,omitempty"`
+	View       uint32 `json:"view,omitempty"`
+	Height     uint32 `json:"height,omitempty"`
+	Block      []byte `json:"block,omitempty"`       // serialized header
+	EmptyBlock []byte `json:"empty_block,omitempty"` // serialized header
+	ForEmpty   bool   `json:"for_empty,omitempty"`
+}
+
+type response struct {
+	Error       string `json:"error,omitempty"`
+	PubKey      []byte `json:"pubkey,omitempty"`
+	Scheme      byte   `json:"scheme,omitempty"`
+	Sig         []byte `json:"sig,omitempty"`
+	EmptySig    []byte `json:"empty_sig,omitempty"`
+	EvidenceSig []byte `json:"evidence_sig,omitempty"` // sig over the proposal or endorsement digest
+	Value       []byte `json:"value,omitempty"`
+	Proof       []byte `json:"proof,omitempty"`
+}
+
+// ParseAddress splits the signer address into network and address,
+// e.g. unix:///var/run/signer.sock or tcp://127.0.0.1:20339
+func ParseAddress(uri string) (network, addr string, err error) {
+	parts := strings.SplitN(uri, "://", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return "", "", fmt.Errorf("invalid signer address %s, expect unix://<path> or tcp://<host:port>", uri)
+	}
+	switch parts[0] {
+	case "unix", "tcp":
+		return parts[0], parts[1], nil
+	}
+	return "", "", fmt.Errorf("unsupported signer network %s", parts[0])
+}
+
+// authConn is a connection authenticated by the shared secret. Every frame carries the mac of
+// its sequence number and payload keyed by the session key of its direction, so frames can not be
+// forged, replayed or reflected back to the sender
+type authConn struct {
+	conn    net.Conn
+	sendKey []byte
+	recvKey []byte
+	sendSeq uint64
+	recvSeq uint64
+}
+
+// newAuthConn derives the session keys of both directions from the nonces of the handshake
+func newAuthConn(conn net.Conn, secret, serverNonce, clientNonce []byte, isServer bool) *authConn {
+	clientKey := computeMac(secret, []byte("client session"), serverNonce, clientNonce)
+	serverKey := computeMac(secret, []byte("server session"), serverNonce, clientNonce)
+	if isServer {
+		return &authConn{conn: conn, sendKey: serverKey, recvKey: clientKey}
+	}
+	return &authConn{conn: conn, sendKey: clientKey, recvKey: serverKey}
+}
+
+func computeMac(key []byte, parts ...[]byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	for _, part := range parts {
+		mac.Write(part)
+	}
+	return mac.Sum(nil)
+}
+
+func randNonce() ([]byte, error) {
+	nonce := make([]byte, NONCE_LEN)
+	_, err := io.ReadFull(rand.Reader, nonce)
+	return nonce, err
+}
+
+// clientAuth proves the knowledge of secret to server and checks the server proves it as well
+func clientAuth(conn net.Conn, secret []byte) (*authConn, error) {
+	serverNonce := make([]byte, NONCE_LEN)
+	if _, err := io.ReadFull(conn, serverNonce); err != nil {
+		return nil, fmt.Errorf("read server nonce: %s", err)
+	}
+	clientNonce, err := randNonce()
+	if err != nil {
+		return nil, err
+	}
+	proof := computeMac(secret, []byte("client"), serverNonce, clientNonce)
+	if _, err := conn.Write(append(clientNonce, proof...)); err != nil {
+		return nil, err
+	}
+	serverProof := make([]byte, MAC_LEN)
+	if _, err := io.ReadFull(conn, serverProof); err != nil {
+		return nil, fmt.Errorf("signer refused authentication: %s", err)
+	}
+	if !hmac.Equal(serverProof, computeMac(secret, []byte("server"), clientNonce, serverNonce)) {
+		return nil, errors.New("signer failed to prove the shared secret")
+	}
+	return newAuthConn(conn, secret, serverNonce, clientNonce, false), nil
+}
+
+// serverAuth checks the client knows secret and proves it to client
+func serverAuth(conn net.Conn, secret []byte) (*authConn, error) {
+	serverNonce, err := randNonce()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := conn.Write(serverNonce); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, NONCE_LEN+MAC_LEN)
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		return nil, fmt.Errorf("read client proof: %s", err)
+	}
+	clientNonce, proof := buf[:NONCE_LEN], buf[NONCE_LEN:]
+	if !hmac.Equal(proof, computeMac(secret, []byte("client"), serverNonce, clientNonce)) {
+		return nil, errors.New("client failed to prove the shared secret")
+	}
+	if _, err := conn.Write(computeMac(secret, []byte("server"), clientNonce, serverNonce)); err != nil {
+		return nil, err
+	}
+	return newAuthConn(conn, secret, serverNonce, clientNonce, true), nil
+}
+
+func (self *authConn) writeMsg(msg interface{}) error {
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	if len(payload)+MAC_LEN > MAX_FRAME_LEN {
+		return fmt.Errorf("message length %d exceed max frame length", len(payload))
+	}
+	var seq [8]byte
+	binary.BigEndian.PutUint64(seq[:], self.sendSeq)
+	self.sendSeq++
+
+	frame := make([]byte, 4, 4+len(payload)+MAC_LEN)
+	binary.BigEndian.PutUint32(frame, uint32(len(payload)+MAC_LEN))
+	frame = append(frame, payload...)
+	frame = append(frame, computeMac(self.sendKey, seq[:], payload)...)
+	_, err = self.conn.Write(frame)
+	return err
+}
+
+func (self *authConn) readMsg(msg interface{}) error {
+	var head [4]byte
+	if _, err := io.ReadFull(self.conn, head[:]); err != nil {
+		return err
+	}
+	length := binary.BigEndian.Uint32(head[:])
+	if length < MAC_LEN || length > MAX_FRAME_LEN {
+		return fmt.Errorf("invalid frame length %d", length)
+	}
+	frame := make([]byte, length)
+	if _, err := io.ReadFull(self.conn, frame); err != nil {
+		return err
+	}
+	payload, mac := frame[:length-MAC_LEN], frame[length-MAC_LEN:]
+	var seq [8]byte
+	binary.BigEndian.PutUint64(seq[:], self.recvSeq)
+	self.recvSeq++
+	if !hmac.Equal(mac, computeMac(self.recvKey, seq[:], payload)) {
+		return errors.New("invalid frame mac")
+	}
+	return json.Unmarshal(payload, msg)
+}
+
+func (self *authConn) Close() error {
+	return self.conn.Close()
+}
@@ -0,0 +1,211 @@
+/*
+ * Copyright (C) 2018 The cntmology Authors
+ * This file is part of The cntmology library.
+ *
+ * The cntmology is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The cntmology is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * alcntm with The cntmology.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package signer
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/cntmio/cntmology-crypto/keypair"
+	s "github.com/cntmio/cntmology-crypto/signature"
+	"github.com/cntmio/cntmology/common"
+	"github.com/cntmio/cntmology/core/types"
+)
+
+// RemoteSigner asks an external signing daemon to sign, the private key never enters this process
+type RemoteSigner struct {
+	network string
+	addr    string
+	secret  []byte
+
+	lock   sync.Mutex
+	conn   *authConn // nil before connected or after an io error
+	pubKey keypair.PublicKey
+	scheme s.SignatureScheme
+}
+
+// NewRemoteSigner connects to the daemon at uri and fetches the public key of its signing key
+func NewRemoteSigner(uri string, secret []byte) (*RemoteSigner, error) {
+	network, addr, err := ParseAddress(uri)
+	if err != nil {
+		return nil, err
+	}
+	self := &RemoteSigner{network: network, addr: addr, secret: secret}
+	resp, err := self.call(&request{Method: METHOD_PUBKEY})
+	if err != nil {
+		return nil, err
+	}
+	self.pubKey, err = keypair.DeserializePublicKey(resp.PubKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key from signer: %s", err)
+	}
+	self.scheme = s.SignatureScheme(resp.Scheme)
+	return self, nil
+}
+
+func (self *RemoteSigner) PubKey() keypair.PublicKey {
+	return self.pubKey
+}
+
+func (self *RemoteSigner) Scheme() s.SignatureScheme {
+	return self.scheme
+}
+
+func (self *RemoteSigner) Sign(data []byte) ([]byte, error) {
+	resp, err := self.call(&request{Method: METHOD_SIGN, Data: data})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Sig, nil
+}
+
+func (self *RemoteSigner) SignTransaction(tx *types.MutableTransaction) ([]byte, error) {
+	immutable, err := tx.IntoImmutable()
+	if err != nil {
+		return nil, err
+	}
+	resp, err := self.call(&request{Method: METHOD_SIGN_TX, Data: immutable.Raw})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Sig, nil
+}
+
+func (self *RemoteSigner) SignProposal(view uint32, block, emptyBlock *types.Header) ([]byte, []byte, []byte, error) {
+	resp, err := self.call(&request{
+		Method:     METHOD_SIGN_PROPOSAL,
+		View:       view,
+		Block:      block.ToArray(),
+		EmptyBlock: emptyBlock.ToArray(),
+	})
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return resp.Sig, resp.EmptySig, resp.EvidenceSig, nil
+}
+
+func (self *RemoteSigner) SignEndorsement(view uint32, block *types.Header, forEmpty bool) ([]byte, []byte, error) {
+	resp, err := self.call(&request{
+		Method:   METHOD_SIGN_ENDORSEMENT,
+		View:     view,
+		Block:    block.ToArray(),
+		ForEmpty: forEmpty,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return resp.Sig, resp.EvidenceSig, nil
+}
+
+func (self *RemoteSigner) SignCommit(view uint32, block *types.Header, forEmpty bool) ([]byte, error) {
+	resp, err := self.call(&request{
+		Method:   METHOD_SIGN_COMMIT,
+		View:     view,
+		Block:    block.ToArray(),
+		ForEmpty: forEmpty,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Sig, nil
+}
+
+func (self *RemoteSigner) SignCrossChainMsg(msg *types.CrossChainMsg) ([]byte, error) {
+	sink := common.NewZeroCopySink(nil)
+	msg.Serialization(sink)
+	resp, err := self.call(&request{Method: METHOD_SIGN_CROSS_CHAIN_MSG, Data: sink.Bytes()})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Sig, nil
+}
+
+func (self *RemoteSigner) SignStateRoot(height uint32, stateRoot common.Uint256) ([]byte, error) {
+	resp, err := self.call(&request{Method: METHOD_SIGN_STATE_ROOT, Height: height, Data: stateRoot[:]})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Sig, nil
+}
+
+func (self *RemoteSigner) Vrf(data []byte) ([]byte, []byte, error) {
+	resp, err := self.call(&request{Method: METHOD_VRF, Data: data})
+	if err != nil {
+		return nil, nil, err
+	}
+	return resp.Value, resp.Proof, nil
+}
+
+// Close closes the connection to daemon, it is reconnected on the next request
+func (self *RemoteSigner) Close() {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	if self.conn != nil {
+		self.conn.Close()
+		self.conn = nil
+	}
+}
+
+func (self *RemoteSigner) connect() (*authConn, error) {
+	conn, err := net.DialTimeout(self.network, self.addr, REQUEST_TIMEOUT)
+	if err != nil {
+		return nil, fmt.Errorf("connect to signer %s: %s", self.addr, err)
+	}
+	_ = conn.SetDeadline(time.Now().Add(REQUEST_TIMEOUT))
+	ac, err := clientAuth(conn, self.secret)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return ac, nil
+}
+
+// call sends the request and waits for response, the connection is reestablished once on io error
+func (self *RemoteSigner) call(req *request) (*response, error) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	var err error
+	for retry := 0; retry < 2; retry++ {
+		if self.conn == nil {
+			self.conn, err = self.connect()
+			if err != nil {
+				return nil, err
+			}
+		}
+		resp := &response{}
+		_ = self.conn.conn.SetDeadline(time.Now().Add(REQUEST_TIMEOUT))
+		if err = self.conn.writeMsg(req); err == nil {
+			err = self.conn.readMsg(resp)
+		}
+		if err != nil {
+			self.conn.Close()
+			self.conn = nil
+			ccntminue
+		}
+		if resp.Error != "" {
+			return nil, errors.New(resp.Error)
+		}
+		return resp, nil
+	}
+	return nil, fmt.Errorf("signer %s: %s", self.addr, err)
+}
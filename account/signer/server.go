@@ -0,0 +1,213 @@
+/*
+ * Copyright (C) 2018 The cntmology Authors
+ * This file is part of The cntmology library.
+ *
+ * The cntmology is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The cntmology is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * alcntm with The cntmology.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package signer
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/cntmio/cntmology-crypto/keypair"
+	"github.com/cntmio/cntmology/common"
+	"github.com/cntmio/cntmology/common/log"
+	"github.com/cntmio/cntmology/core/types"
+)
+
+// Server is the signing daemon serving the remote signers which know the shared secret
+type Server struct {
+	signer  Signer
+	secret  []byte
+	protect *SlashingProtection
+
+	lock     sync.Mutex
+	listener net.Listener
+}
+
+func NewServer(signer Signer, secret []byte, protect *SlashingProtection) *Server {
+	return &Server{signer: signer, secret: secret, protect: protect}
+}
+
+// Listen listens on uri, e.g. unix:///var/run/signer.sock or tcp://127.0.0.1:20339
+func Listen(uri string) (net.Listener, error) {
+	network, addr, err := ParseAddress(uri)
+	if err != nil {
+		return nil, err
+	}
+	return net.Listen(network, addr)
+}
+
+// Serve accepts connections on listener until it is closed
+func (self *Server) Serve(listener net.Listener) error {
+	self.lock.Lock()
+	self.listener = listener
+	self.lock.Unlock()
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+		go self.handleConn(conn)
+	}
+}
+
+func (self *Server) Close() error {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	if self.listener == nil {
+		return nil
+	}
+	return self.listener.Close()
+}
+
+func (self *Server) handleConn(conn net.Conn) {
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(REQUEST_TIMEOUT))
+	ac, err := serverAuth(conn, self.secret)
+	if err != nil {
+		log.Warnf("[signer] authenticate %s failed: %s", conn.RemoteAddr(), err)
+		return
+	}
+	// the connection is kept open between requests
+	_ = conn.SetDeadline(time.Time{})
+	for {
+		req := &request{}
+		if err := ac.readMsg(req); err != nil {
+			log.Debugf("[signer] connection from %s closed: %s", conn.RemoteAddr(), err)
+			return
+		}
+		resp := self.handle(req)
+		_ = conn.SetWriteDeadline(time.Now().Add(REQUEST_TIMEOUT))
+		if err := ac.writeMsg(resp); err != nil {
+			return
+		}
+	}
+}
+
+func (self *Server) handle(req *request) *response {
+	resp := &response{}
+	var err error
+	switch req.Method {
+	case METHOD_PUBKEY:
+		resp.PubKey = keypair.SerializePublicKey(self.signer.PubKey())
+		resp.Scheme = byte(self.signer.Scheme())
+	case METHOD_SIGN:
+		// a block hash or a digest would escape the checks of its method
+		if len(req.Data) == common.UINT256_SIZE {
+			err = fmt.Errorf("refuse to sign %d bytes data", len(req.Data))
+		} else {
+			resp.Sig, err = self.signer.Sign(req.Data)
+		}
+	case METHOD_SIGN_TX:
+		resp.Sig, err = self.signTransaction(req)
+	case METHOD_SIGN_PROPOSAL:
+		resp.Sig, resp.EmptySig, resp.EvidenceSig, err = self.signProposal(req)
+	case METHOD_SIGN_ENDORSEMENT:
+		resp.Sig, resp.EvidenceSig, err = self.signEndorsement(req)
+	case METHOD_SIGN_COMMIT:
+		resp.Sig, err = self.signCommit(req)
+	case METHOD_SIGN_CROSS_CHAIN_MSG:
+		resp.Sig, err = self.signCrossChainMsg(req)
+	case METHOD_SIGN_STATE_ROOT:
+		resp.Sig, err = self.signStateRoot(req)
+	case METHOD_VRF:
+		resp.Value, resp.Proof, err = self.signer.Vrf(req.Data)
+	default:
+		err = fmt.Errorf("unknown method %s", req.Method)
+	}
+	if err != nil {
+		log.Warnf("[signer] %s request failed: %s", req.Method, err)
+		return &response{Error: err.Error()}
+	}
+	return resp
+}
+
+func (self *Server) signTransaction(req *request) ([]byte, error) {
+	tx, err := types.TransactionFromRawBytes(req.Data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid tx: %s", err)
+	}
+	mutable, err := tx.IntoMutable()
+	if err != nil {
+		return nil, fmt.Errorf("invalid tx: %s", err)
+	}
+	return self.signer.SignTransaction(mutable)
+}
+
+func (self *Server) signProposal(req *request) ([]byte, []byte, []byte, error) {
+	block, err := types.HeaderFromRawBytes(req.Block)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("invalid block: %s", err)
+	}
+	emptyBlock, err := types.HeaderFromRawBytes(req.EmptyBlock)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("invalid empty block: %s", err)
+	}
+	if block.Height != emptyBlock.Height || block.PrevBlockHash != emptyBlock.PrevBlockHash {
+		return nil, nil, nil, fmt.Errorf("empty block of height %d, block of height %d", emptyBlock.Height, block.Height)
+	}
+	if self.protect != nil {
+		if err := self.protect.CheckProposal(req.View, block.Height, block.Hash(), emptyBlock.Hash()); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+	return self.signer.SignProposal(req.View, block, emptyBlock)
+}
+
+func (self *Server) signEndorsement(req *request) ([]byte, []byte, error) {
+	block, err := types.HeaderFromRawBytes(req.Block)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid block: %s", err)
+	}
+	if self.protect != nil {
+		if err := self.protect.CheckEndorsement(req.View, block.Height, block.Hash(), req.ForEmpty); err != nil {
+			return nil, nil, err
+		}
+	}
+	return self.signer.SignEndorsement(req.View, block, req.ForEmpty)
+}
+
+func (self *Server) signCommit(req *request) ([]byte, error) {
+	block, err := types.HeaderFromRawBytes(req.Block)
+	if err != nil {
+		return nil, fmt.Errorf("invalid block: %s", err)
+	}
+	if self.protect != nil {
+		if err := self.protect.CheckCommit(req.View, block.Height, block.Hash(), req.ForEmpty); err != nil {
+			return nil, err
+		}
+	}
+	return self.signer.SignCommit(req.View, block, req.ForEmpty)
+}
+
+func (self *Server) signCrossChainMsg(req *request) ([]byte, error) {
+	msg := new(types.CrossChainMsg)
+	if err := msg.Deserialization(common.NewZeroCopySource(req.Data)); err != nil {
+		return nil, fmt.Errorf("invalid cross chain msg: %s", err)
+	}
+	return self.signer.SignCrossChainMsg(msg)
+}
+
+func (self *Server) signStateRoot(req *request) ([]byte, error) {
+	stateRoot, err := common.Uint256ParseFromBytes(req.Data)
+	if err != nil {
+		return nil, err
+	}
+	return self.signer.SignStateRoot(req.Height, stateRoot)
+}
@@ -0,0 +1,179 @@
+/*
+ * Copyright (C) 2018 The cntmology Authors
+ * This file is part of The cntmology library.
+ *
+ * The cntmology is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The cntmology is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * alcntm with The cntmology.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+// Package signer provides the abstraction of signing keys, which may be held in process
+// or by an external signing daemon reached over a unix socket or tcp
+package signer
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/cntmio/cntmology-crypto/keypair"
+	s "github.com/cntmio/cntmology-crypto/signature"
+	"github.com/cntmio/cntmology-crypto/vrf"
+	"github.com/cntmio/cntmology/account"
+	"github.com/cntmio/cntmology/common"
+	vconfig "github.com/cntmio/cntmology/consensus/vbft/config"
+	"github.com/cntmio/cntmology/core/signature"
+	"github.com/cntmio/cntmology/core/types"
+)
+
+// MIN_SECRET_LEN is the minimal length of the secret shared by signer and its clients
+const MIN_SECRET_LEN = 16
+
+// Signer signs data with a key which is not necessarily held by this process
+type Signer interface {
+	PubKey() keypair.PublicKey
+	Scheme() s.SignatureScheme
+
+	// Sign returns the serialized signature of data. A remote signer refuses to sign 32 bytes data,
+	// which may be a block hash or a digest, the methods below are used for them instead
+	Sign(data []byte) ([]byte, error)
+
+	// SignTransaction signs the hash of tx
+	SignTransaction(tx *types.MutableTransaction) ([]byte, error)
+
+	// SignProposal signs the block and the empty block proposed in view, and the proposal digest binding them
+	// to the round. A signer with slashing protection refuses to sign different blocks at the same height
+	SignProposal(view uint32, block, emptyBlock *types.Header) (blockSig, emptySig, proposalSig []byte, err error)
+
+	// SignEndorsement signs the block endorsed in view, and the endorsement digest binding it to the round
+	// unless the block is the empty one. A signer with slashing protection refuses to endorse different blocks
+	// at the same height
+	SignEndorsement(view uint32, block *types.Header, forEmpty bool) (blockSig, endorsementSig []byte, err error)
+
+	// SignCommit signs the block committed in view. A signer with slashing protection refuses to commit
+	// different blocks at the same height
+	SignCommit(view uint32, block *types.Header, forEmpty bool) ([]byte, error)
+
+	// SignCrossChainMsg signs the hash of the cross chain msg
+	SignCrossChainMsg(msg *types.CrossChainMsg) ([]byte, error)
+
+	// SignStateRoot signs the digest of the state root submitted at height
+	SignStateRoot(height uint32, stateRoot common.Uint256) ([]byte, error)
+
+	// Vrf computes the vrf value and proof of data
+	Vrf(data []byte) (value, proof []byte, err error)
+}
+
+// Address returns the address of signer's public key
+func Address(signer Signer) common.Address {
+	return types.AddressFromPubKey(signer.PubKey())
+}
+
+// ToAccount returns an account holding only the public part of signer,
+// used by the modules which identify themselves by account
+func ToAccount(signer Signer) *account.Account {
+	return &account.Account{
+		PublicKey: signer.PubKey(),
+		Address:   Address(signer),
+		SigScheme: signer.Scheme(),
+	}
+}
+
+type localSigner struct {
+	acct *account.Account
+}
+
+// NewLocalSigner returns a signer using the decrypted account in process
+func NewLocalSigner(acct *account.Account) Signer {
+	return &localSigner{acct: acct}
+}
+
+func (self *localSigner) PubKey() keypair.PublicKey {
+	return self.acct.PublicKey
+}
+
+func (self *localSigner) Scheme() s.SignatureScheme {
+	return self.acct.SigScheme
+}
+
+func (self *localSigner) Sign(data []byte) ([]byte, error) {
+	return signature.Sign(self.acct, data)
+}
+
+func (self *localSigner) SignTransaction(tx *types.MutableTransaction) ([]byte, error) {
+	hash := tx.Hash()
+	return self.Sign(hash[:])
+}
+
+func (self *localSigner) SignProposal(view uint32, block, emptyBlock *types.Header) ([]byte, []byte, []byte, error) {
+	blockHash, emptyHash := block.Hash(), emptyBlock.Hash()
+	blockSig, err := self.Sign(blockHash[:])
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	emptySig, err := self.Sign(emptyHash[:])
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	digest := vconfig.ProposalDigest(view, block.Height, blockHash, emptyHash)
+	proposalSig, err := self.Sign(digest[:])
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return blockSig, emptySig, proposalSig, nil
+}
+
+func (self *localSigner) SignEndorsement(view uint32, block *types.Header, forEmpty bool) ([]byte, []byte, error) {
+	hash := block.Hash()
+	blockSig, err := self.Sign(hash[:])
+	if err != nil || forEmpty {
+		return blockSig, nil, err
+	}
+	digest := vconfig.EndorsementDigest(view, block.Height, hash)
+	endorsementSig, err := self.Sign(digest[:])
+	if err != nil {
+		return nil, nil, err
+	}
+	return blockSig, endorsementSig, nil
+}
+
+func (self *localSigner) SignCommit(view uint32, block *types.Header, forEmpty bool) ([]byte, error) {
+	hash := block.Hash()
+	return self.Sign(hash[:])
+}
+
+func (self *localSigner) SignCrossChainMsg(msg *types.CrossChainMsg) ([]byte, error) {
+	hash := msg.Hash()
+	return self.Sign(hash[:])
+}
+
+func (self *localSigner) SignStateRoot(height uint32, stateRoot common.Uint256) ([]byte, error) {
+	digest := vconfig.SubmitDigest(height, stateRoot)
+	return self.Sign(digest[:])
+}
+
+func (self *localSigner) Vrf(data []byte) ([]byte, []byte, error) {
+	return vrf.Vrf(self.acct.PrivateKey, data)
+}
+
+// LoadSecret reads the shared secret from file, surrounding white spaces are ignored
+func LoadSecret(path string) ([]byte, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	secret := bytes.TrimSpace(data)
+	if len(secret) < MIN_SECRET_LEN {
+		return nil, fmt.Errorf("secret in %s is shorter than %d bytes", path, MIN_SECRET_LEN)
+	}
+	return secret, nil
+}
@@ -0,0 +1,264 @@
+/*
+ * Copyright (C) 2018 The cntmology Authors
+ * This file is part of The cntmology library.
+ *
+ * The cntmology is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The cntmology is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * alcntm with The cntmology.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package signer
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cntmio/cntmology-crypto/vrf"
+	"github.com/cntmio/cntmology/account"
+	"github.com/cntmio/cntmology/common"
+	vconfig "github.com/cntmio/cntmology/consensus/vbft/config"
+	"github.com/cntmio/cntmology/core/payload"
+	"github.com/cntmio/cntmology/core/signature"
+	"github.com/cntmio/cntmology/core/types"
+	"github.com/stretchr/testify/assert"
+)
+
+var testSecret = []byte("0123456789abcdef0123456789abcdef")
+
+func startServer(t *testing.T, protect *SlashingProtection) (*Server, *account.Account, string) {
+	acct := account.NewAccount("")
+	listener, err := Listen("tcp://127.0.0.1:0")
+	assert.Nil(t, err)
+	server := NewServer(NewLocalSigner(acct), testSecret, protect)
+	go server.Serve(listener)
+	return server, acct, "tcp://" + listener.Addr().String()
+}
+
+func TestRemoteSigner(t *testing.T) {
+	server, acct, uri := startServer(t, nil)
+	defer server.Close()
+
+	signer, err := NewRemoteSigner(uri, testSecret)
+	assert.Nil(t, err)
+	defer signer.Close()
+	assert.Equal(t, acct.Address, Address(signer))
+
+	data := []byte("data to sign")
+	sig, err := signer.Sign(data)
+	assert.Nil(t, err)
+	assert.Nil(t, signature.Verify(acct.PublicKey, data, sig))
+
+	value, proof, err := signer.Vrf(data)
+	assert.Nil(t, err)
+	ok, err := vrf.Verify(acct.PublicKey, data, value, proof)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+}
+
+func TestRemoteSignerWrongSecret(t *testing.T) {
+	server, _, uri := startServer(t, nil)
+	defer server.Close()
+
+	_, err := NewRemoteSigner(uri, []byte("fedcba9876543210fedcba9876543210"))
+	assert.NotNil(t, err)
+}
+
+func TestRemoteSignerReconnect(t *testing.T) {
+	server, acct, uri := startServer(t, nil)
+	defer server.Close()
+
+	signer, err := NewRemoteSigner(uri, testSecret)
+	assert.Nil(t, err)
+	signer.conn.Close()
+	sig, err := signer.Sign([]byte{1})
+	assert.Nil(t, err)
+	assert.Nil(t, signature.Verify(acct.PublicKey, []byte{1}, sig))
+}
+
+func TestRemoteSignerRefuseHash(t *testing.T) {
+	server, _, uri := startServer(t, nil)
+	defer server.Close()
+
+	signer, err := NewRemoteSigner(uri, testSecret)
+	assert.Nil(t, err)
+	defer signer.Close()
+	hash := common.Uint256{1}
+	_, err = signer.Sign(hash[:])
+	assert.NotNil(t, err)
+}
+
+func TestRemoteSignerTransaction(t *testing.T) {
+	server, acct, uri := startServer(t, nil)
+	defer server.Close()
+
+	signer, err := NewRemoteSigner(uri, testSecret)
+	assert.Nil(t, err)
+	defer signer.Close()
+	tx := &types.MutableTransaction{
+		TxType:   types.InvokeCntm,
+		Nonce:    1,
+		GasPrice: 500,
+		GasLimit: 20000,
+		Payer:    acct.Address,
+		Payload:  &payload.InvokeCode{Code: []byte{1, 2, 3}},
+		Sigs:     []types.Sig{},
+	}
+	sig, err := signer.SignTransaction(tx)
+	assert.Nil(t, err)
+	hash := tx.Hash()
+	assert.Nil(t, signature.Verify(acct.PublicKey, hash[:], sig))
+
+	msg := &types.CrossChainMsg{Version: types.CURR_CROSS_STATES_VERSION, Height: 10, StatesRoot: common.Uint256{1}}
+	sig, err = signer.SignCrossChainMsg(msg)
+	assert.Nil(t, err)
+	hash = msg.Hash()
+	assert.Nil(t, signature.Verify(acct.PublicKey, hash[:], sig))
+
+	sig, err = signer.SignStateRoot(10, common.Uint256{1})
+	assert.Nil(t, err)
+	digest := vconfig.SubmitDigest(10, common.Uint256{1})
+	assert.Nil(t, signature.Verify(acct.PublicKey, digest[:], sig))
+}
+
+func testHeader(height uint32, nonce uint64) *types.Header {
+	return &types.Header{Height: height, ConsensusData: nonce}
+}
+
+func TestSlashingProtection(t *testing.T) {
+	protect, err := NewSlashingProtection("")
+	assert.Nil(t, err)
+	server, acct, uri := startServer(t, protect)
+	defer server.Close()
+
+	signer, err := NewRemoteSigner(uri, testSecret)
+	assert.Nil(t, err)
+	defer signer.Close()
+	block, empty := testHeader(10, 1), testHeader(10, 2)
+	blockSig, emptySig, proposalSig, err := signer.SignProposal(2, block, empty)
+	assert.Nil(t, err)
+	blockHash, emptyHash := block.Hash(), empty.Hash()
+	assert.Nil(t, signature.Verify(acct.PublicKey, blockHash[:], blockSig))
+	assert.Nil(t, signature.Verify(acct.PublicKey, emptyHash[:], emptySig))
+	digest := vconfig.ProposalDigest(2, 10, blockHash, emptyHash)
+	assert.Nil(t, signature.Verify(acct.PublicKey, digest[:], proposalSig))
+
+	// signing the same proposal again is allowed
+	_, _, _, err = signer.SignProposal(2, block, empty)
+	assert.Nil(t, err)
+	_, _, _, err = signer.SignProposal(2, testHeader(10, 3), empty)
+	assert.NotNil(t, err)
+	_, _, _, err = signer.SignProposal(2, testHeader(11, 3), testHeader(11, 2))
+	assert.Nil(t, err)
+	// the empty block must be at the height of block
+	_, _, _, err = signer.SignProposal(2, testHeader(12, 3), testHeader(11, 2))
+	assert.NotNil(t, err)
+	// a signed height can not be signed in another view
+	_, _, _, err = signer.SignProposal(3, block, empty)
+	assert.NotNil(t, err)
+}
+
+func TestSlashingProtectionEndorseCommit(t *testing.T) {
+	protect, err := NewSlashingProtection("")
+	assert.Nil(t, err)
+	server, acct, uri := startServer(t, protect)
+	defer server.Close()
+
+	signer, err := NewRemoteSigner(uri, testSecret)
+	assert.Nil(t, err)
+	defer signer.Close()
+	block, empty := testHeader(10, 1), testHeader(10, 2)
+	blockSig, endorsementSig, err := signer.SignEndorsement(2, block, false)
+	assert.Nil(t, err)
+	hash := block.Hash()
+	assert.Nil(t, signature.Verify(acct.PublicKey, hash[:], blockSig))
+	digest := vconfig.EndorsementDigest(2, 10, hash)
+	assert.Nil(t, signature.Verify(acct.PublicKey, digest[:], endorsementSig))
+
+	// the empty block is endorsed apart from the block
+	_, _, err = signer.SignEndorsement(2, empty, true)
+	assert.Nil(t, err)
+	_, _, err = signer.SignEndorsement(2, testHeader(10, 3), false)
+	assert.NotNil(t, err)
+	_, _, err = signer.SignEndorsement(3, block, false)
+	assert.NotNil(t, err)
+
+	sig, err := signer.SignCommit(2, block, false)
+	assert.Nil(t, err)
+	assert.Nil(t, signature.Verify(acct.PublicKey, hash[:], sig))
+	_, err = signer.SignCommit(2, block, false)
+	assert.Nil(t, err)
+	_, err = signer.SignCommit(2, testHeader(10, 3), false)
+	assert.NotNil(t, err)
+}
+
+func TestSlashingProtectionPersist(t *testing.T) {
+	dir, err := ioutil.TempDir("", "signer")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "protection.json")
+
+	protect, err := NewSlashingProtection(file)
+	assert.Nil(t, err)
+	assert.Nil(t, protect.CheckProposal(1, 5, common.Uint256{1}, common.Uint256{2}))
+	assert.Nil(t, protect.CheckEndorsement(1, 5, common.Uint256{1}, false))
+
+	protect, err = NewSlashingProtection(file)
+	assert.Nil(t, err)
+	assert.NotNil(t, protect.CheckProposal(1, 5, common.Uint256{3}, common.Uint256{2}))
+	assert.NotNil(t, protect.CheckEndorsement(1, 5, common.Uint256{3}, false))
+	assert.NotNil(t, protect.CheckCommit(2, 5, common.Uint256{1}, false))
+	assert.Nil(t, protect.CheckProposal(1, 5, common.Uint256{1}, common.Uint256{2}))
+}
+
+func TestSlashingProtectionPrune(t *testing.T) {
+	protect, _ := NewSlashingProtection("")
+	for i := uint32(1); i <= MAX_PROTECTED_HEIGHTS+1; i++ {
+		assert.Nil(t, protect.CheckProposal(1, i, common.Uint256{1}, common.Uint256{2}))
+	}
+	assert.Equal(t, MAX_PROTECTED_HEIGHTS, len(protect.rounds))
+	assert.NotNil(t, protect.CheckProposal(1, 1, common.Uint256{1}, common.Uint256{2}))
+}
+
+func TestAuthConnReflect(t *testing.T) {
+	serverNonce, clientNonce := []byte("server nonce"), []byte("client nonce")
+	c1, c2 := net.Pipe()
+	defer c1.Close()
+	defer c2.Close()
+	sender := newAuthConn(c1, testSecret, serverNonce, clientNonce, true)
+	go sender.writeMsg(&request{Method: METHOD_PUBKEY})
+	// a frame of the server is not accepted as a frame of the client
+	reflected := newAuthConn(c2, testSecret, serverNonce, clientNonce, true)
+	assert.NotNil(t, reflected.readMsg(&request{}))
+
+	go sender.writeMsg(&request{Method: METHOD_PUBKEY})
+	receiver := newAuthConn(c2, testSecret, serverNonce, clientNonce, false)
+	// the first frame was consumed above
+	receiver.recvSeq = 1
+	req := &request{}
+	assert.Nil(t, receiver.readMsg(req))
+	assert.Equal(t, METHOD_PUBKEY, req.Method)
+}
+
+func TestParseAddress(t *testing.T) {
+	network, addr, err := ParseAddress("unix:///var/run/signer.sock")
+	assert.Nil(t, err)
+	assert.Equal(t, "unix", network)
+	assert.Equal(t, "/var/run/signer.sock", addr)
+
+	_, _, err = ParseAddress("http://127.0.0.1:20339")
+	assert.NotNil(t, err)
+	_, _, err = ParseAddress("127.0.0.1:20339")
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,104 @@
+/*
+ * Copyright (C) 2018 The cntmology Authors
+ * This file is part of The cntmology library.
+ *
+ * The cntmology is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The cntmology is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * alcntm with The cntmology.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"os"
+	"os/signal"
+	"runtime"
+	"syscall"
+
+	"github.com/cntmio/cntmology/account/signer"
+	"github.com/cntmio/cntmology/cmd"
+	cmdcom "github.com/cntmio/cntmology/cmd/common"
+	"github.com/cntmio/cntmology/cmd/utils"
+	"github.com/cntmio/cntmology/common/config"
+	"github.com/cntmio/cntmology/common/log"
+	"github.com/urfave/cli"
+)
+
+func setupSigner() *cli.App {
+	app := cli.NewApp()
+	app.Usage = "Ontology remote signer"
+	app.Action = startSigner
+	app.Version = config.Version
+	app.Copyright = "Copyright in 2018 The Ontology Authors"
+	app.Flags = []cli.Flag{
+		utils.LogLevelFlag,
+		utils.WalletFileFlag,
+		utils.AccountAddressFlag,
+		utils.AccountPassFlag,
+		utils.SignerListenFlag,
+		utils.SignerSecretFileFlag,
+		utils.SignerProtectionFileFlag,
+	}
+	app.Before = func(ccntmext *cli.Ccntmext) error {
+		runtime.GOMAXPROCS(runtime.NumCPU())
+		return nil
+	}
+	return app
+}
+
+func startSigner(ctx *cli.Ccntmext) {
+	logLevel := ctx.GlobalInt(utils.GetFlagName(utils.LogLevelFlag))
+	log.InitLog(logLevel, log.PATH, log.Stdout)
+
+	secret, err := signer.LoadSecret(ctx.String(utils.GetFlagName(utils.SignerSecretFileFlag)))
+	if err != nil {
+		log.Errorf("LoadSecret error:%s", err)
+		return
+	}
+	protect, err := signer.NewSlashingProtection(ctx.String(utils.GetFlagName(utils.SignerProtectionFileFlag)))
+	if err != nil {
+		log.Errorf("NewSlashingProtection error:%s", err)
+		return
+	}
+	acc, err := cmdcom.GetAccount(ctx)
+	if err != nil {
+		log.Errorf("GetAccount error:%s", err)
+		return
+	}
+
+	uri := ctx.String(utils.GetFlagName(utils.SignerListenFlag))
+	listener, err := signer.Listen(uri)
+	if err != nil {
+		log.Errorf("Listen %s error:%s", uri, err)
+		return
+	}
+	server := signer.NewServer(signer.NewLocalSigner(acc), secret, protect)
+	go func() {
+		if err := server.Serve(listener); err != nil {
+			log.Infof("Signer server stopped:%s", err)
+		}
+	}()
+	log.Infof("Signer for address:%s listening on:%s", acc.Address.ToBase58(), uri)
+
+	sc := make(chan os.Signal, 1)
+	signal.Notify(sc, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	sig := <-sc
+	log.Infof("Signer received exit signal:%v.", sig.String())
+	server.Close()
+}
+
+func main() {
+	if err := setupSigner().Run(os.Args); err != nil {
+		cmd.PrintErrorMsg(err.Error())
+		os.Exit(1)
+	}
+}
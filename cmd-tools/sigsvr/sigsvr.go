@@ -18,20 +18,29 @@
 package main
 
 import (
+	"encoding/hex"
 	"os"
 	"os/signal"
 	"runtime"
+	"strings"
 	"syscall"
+	"time"
 
+	"github.com/cntmio/cntmology/account/signer"
 	"github.com/cntmio/cntmology/cmd"
 	"github.com/cntmio/cntmology/cmd/abi"
 	cmdsvr "github.com/cntmio/cntmology/cmd/sigsvr"
+	"github.com/cntmio/cntmology/cmd/sigsvr/audit"
 	clisvrcom "github.com/cntmio/cntmology/cmd/sigsvr/common"
+	"github.com/cntmio/cntmology/cmd/sigsvr/policy"
+	"github.com/cntmio/cntmology/cmd/sigsvr/session"
 	"github.com/cntmio/cntmology/cmd/sigsvr/store"
 	"github.com/cntmio/cntmology/cmd/utils"
 	"github.com/cntmio/cntmology/common/config"
 	"github.com/cntmio/cntmology/common/log"
+	"github.com/cntmio/cntmology/core/types"
 	"github.com/urfave/cli"
+	"golang.org/x/crypto/ed25519"
 )
 
 func setupSigSvr() *cli.App {
@@ -47,6 +56,20 @@ func setupSigSvr() *cli.App {
 		utils.CliAddressFlag,
 		utils.CliRpcPortFlag,
 		utils.CliABIPathFlag,
+		utils.SignerFlag,
+		utils.SignerSecretFileFlag,
+		utils.CliApiKeyFileFlag,
+		utils.CliTLSCertFlag,
+		utils.CliTLSKeyFlag,
+		utils.CliTLSClientCAFlag,
+		utils.CliPolicyFileFlag,
+		utils.CliAuditLogFlag,
+		utils.CliAuditKeyFileFlag,
+		utils.CliSessionDirFlag,
+		utils.CliSessionExpireFlag,
+		utils.CliSessionRpcFlag,
+		utils.CliSessionApiKeyFlag,
+		utils.CliSessionPeersFlag,
 	}
 	app.Commands = []cli.Command{
 		cmdsvr.ImportWalletCommand,
@@ -82,6 +105,33 @@ func startSigSvr(ctx *cli.Ccntmext) {
 	}
 	log.Infof("Load wallet data success. Account number:%d", accountNum)
 
+	if ctx.IsSet(utils.GetFlagName(utils.SignerFlag)) {
+		secret, err := signer.LoadSecret(ctx.String(utils.GetFlagName(utils.SignerSecretFileFlag)))
+		if err != nil {
+			log.Errorf("LoadSecret error:%s", err)
+			return
+		}
+		remote, err := signer.NewRemoteSigner(ctx.String(utils.GetFlagName(utils.SignerFlag)), secret)
+		if err != nil {
+			log.Errorf("NewRemoteSigner error:%s", err)
+			return
+		}
+		defer remote.Close()
+		clisvrcom.DefRemoteSigner = remote
+		log.Infof("Remote signer for address:%s", signer.Address(remote).ToBase58())
+	}
+
+	if !initSession(ctx) {
+		return
+	}
+	auditLog, ok := initSecurity(ctx)
+	if !ok {
+		return
+	}
+	if auditLog != nil {
+		defer auditLog.Close()
+	}
+
 	rpcAddress := ctx.String(utils.GetFlagName(utils.CliAddressFlag))
 	rpcPort := ctx.Uint(utils.GetFlagName(utils.CliRpcPortFlag))
 	if rpcPort == 0 {
@@ -94,7 +144,11 @@ func startSigSvr(ctx *cli.Ccntmext) {
 	abi.DefAbiMgr.Init(abiPath)
 
 	log.Infof("Sig server init success")
-	log.Infof("Sig server listing on: %s:%d", rpcAddress, rpcPort)
+	scheme := "http"
+	if ctx.IsSet(utils.GetFlagName(utils.CliTLSCertFlag)) {
+		scheme = "https"
+	}
+	log.Infof("Sig server listing on: %s://%s:%d", scheme, rpcAddress, rpcPort)
 
 	exit := make(chan bool, 0)
 	sc := make(chan os.Signal, 1)
@@ -109,6 +163,81 @@ func startSigSvr(ctx *cli.Ccntmext) {
 	<-exit
 }
 
+//initSecurity sets up authentication, signing policy and audit log of sig server
+func initSecurity(ctx *cli.Ccntmext) (*audit.Log, bool) {
+	if ctx.IsSet(utils.GetFlagName(utils.CliApiKeyFileFlag)) {
+		apiKeys, err := cmdsvr.LoadApiKeys(ctx.String(utils.GetFlagName(utils.CliApiKeyFileFlag)))
+		if err != nil {
+			log.Errorf("LoadApiKeys error:%s", err)
+			return nil, false
+		}
+		cmdsvr.DefCliRpcSvr.SetApiKeys(apiKeys)
+		log.Infof("Load api keys success. Client number:%d", len(apiKeys))
+	}
+	if ctx.IsSet(utils.GetFlagName(utils.CliTLSCertFlag)) {
+		tlsConfig, err := cmdsvr.NewTLSConfig(ctx.String(utils.GetFlagName(utils.CliTLSCertFlag)),
+			ctx.String(utils.GetFlagName(utils.CliTLSKeyFlag)),
+			ctx.String(utils.GetFlagName(utils.CliTLSClientCAFlag)))
+		if err != nil {
+			log.Errorf("NewTLSConfig error:%s", err)
+			return nil, false
+		}
+		cmdsvr.DefCliRpcSvr.SetTLSConfig(tlsConfig)
+	} else if ctx.IsSet(utils.GetFlagName(utils.CliTLSClientCAFlag)) {
+		log.Errorf("Please using --%s flag to specific tls certificate", utils.GetFlagName(utils.CliTLSCertFlag))
+		return nil, false
+	}
+	if ctx.IsSet(utils.GetFlagName(utils.CliPolicyFileFlag)) {
+		p, err := policy.LoadPolicy(ctx.String(utils.GetFlagName(utils.CliPolicyFileFlag)))
+		if err != nil {
+			log.Errorf("LoadPolicy error:%s", err)
+			return nil, false
+		}
+		clisvrcom.DefPolicy = p
+	}
+	if ctx.IsSet(utils.GetFlagName(utils.CliAuditLogFlag)) {
+		key, err := audit.LoadKey(ctx.String(utils.GetFlagName(utils.CliAuditKeyFileFlag)))
+		if err != nil {
+			log.Errorf("Load audit key error:%s", err)
+			return nil, false
+		}
+		auditLog, err := audit.Open(ctx.String(utils.GetFlagName(utils.CliAuditLogFlag)), key)
+		if err != nil {
+			log.Errorf("Open audit log error:%s", err)
+			return nil, false
+		}
+		cmdsvr.DefCliRpcSvr.SetAuditLog(auditLog)
+		log.Infof("Audit log public key:%s", hex.EncodeToString(key.Public().(ed25519.PublicKey)))
+		return auditLog, true
+	}
+	return nil, true
+}
+
+//initSession sets up the multi-signature sessions coordinated by sig server
+func initSession(ctx *cli.Ccntmext) bool {
+	var submit session.SubmitFunc
+	if rpcAddr := ctx.String(utils.GetFlagName(utils.CliSessionRpcFlag)); rpcAddr != "" {
+		submit = func(tx *types.Transaction) (string, error) {
+			return utils.SendRawTransactionTo(rpcAddr, tx)
+		}
+	}
+	sessionMgr, err := session.NewManager(ctx.String(utils.GetFlagName(utils.CliSessionDirFlag)), submit)
+	if err != nil {
+		log.Errorf("Load sessions error:%s", err)
+		return false
+	}
+	clisvrcom.DefSessionMgr = sessionMgr
+	clisvrcom.DefSessionExpire = time.Duration(ctx.Uint(utils.GetFlagName(utils.CliSessionExpireFlag))) * time.Second
+	clisvrcom.DefSessionApiKey = ctx.String(utils.GetFlagName(utils.CliSessionApiKeyFlag))
+	clisvrcom.DefSessionPeers = nil
+	for _, peer := range strings.Split(ctx.String(utils.GetFlagName(utils.CliSessionPeersFlag)), ",") {
+		if peer = strings.TrimSpace(peer); peer != "" {
+			clisvrcom.DefSessionPeers = append(clisvrcom.DefSessionPeers, peer)
+		}
+	}
+	return true
+}
+
 func main() {
 	if err := setupSigSvr().Run(os.Args); err != nil {
 		cmd.PrintErrorMsg(err.Error())
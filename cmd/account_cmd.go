@@ -31,6 +31,7 @@ import (
 	"github.com/cntmio/cntmology/cmd/utils"
 	"github.com/cntmio/cntmology/common/password"
 	"github.com/cntmio/cntmology/core/types"
+	"github.com/howeyc/gopass"
 	"github.com/urfave/cli"
 )
 
@@ -56,9 +57,12 @@ You can use ./Ontology account --help command to view help information of wallet
 					utils.AccountDefaultFlag,
 					utils.AccountLabelFlag,
 					utils.IdentityFlag,
+					utils.AccountMnemonicFlag,
 					utils.WalletFileFlag,
 				},
 				Description: ` Add a new account to wallet.
+   With --mnemonic, a new mnemonic is created and the accounts are derived from it by BIP-44 path m/44'/1024'/0'/0/<index>,
+   only ecdsa P-256, sm2 and ed25519 support hd derivation.
    Ontology support three type of key: ecdsa, sm2 and ed25519, and support 224、256、384、521 bits length of key in ecdsa, but only support 256 bits length of key in sm2 and ed25519.
    Ontology support multiple signature scheme.
    For ECDSA support SHA224withECDSA、SHA256withECDSA、SHA384withECDSA、SHA512withEdDSA、SHA3-224withECDSA、SHA3-256withECDSA、SHA3-384withECDSA、SHA3-512withECDSA、RIPEMD160withECDSA;
@@ -89,6 +93,39 @@ You can use ./Ontology account --help command to view help information of wallet
    3 ed25519|   25519 256    | SHA512withEdDSA
    -------------------------------------------------`,
 			},
+			{
+				Action:    accountDerive,
+				Name:      "derive",
+				Usage:     "Derive new accounts from the hd seed of wallet",
+				ArgsUsage: "[sub-command options]",
+				Flags: []cli.Flag{
+					utils.AccountHDSeedFlag,
+					utils.AccountQuantityFlag,
+					utils.AccountTypeFlag,
+					utils.AccountKeylenFlag,
+					utils.AccountSigSchemeFlag,
+					utils.AccountDefaultFlag,
+					utils.AccountLabelFlag,
+					utils.WalletFileFlag,
+				},
+				Description: `Derive the next accounts from the hd seed created by 'add --mnemonic' or 'recover'. The password of the seed is used to encrypt the new accounts.`,
+			},
+			{
+				Action:    accountRecover,
+				Name:      "recover",
+				Usage:     "Recover accounts from mnemonic",
+				ArgsUsage: "[sub-command options]",
+				Flags: []cli.Flag{
+					utils.AccountQuantityFlag,
+					utils.AccountTypeFlag,
+					utils.AccountKeylenFlag,
+					utils.AccountSigSchemeFlag,
+					utils.AccountDefaultFlag,
+					utils.AccountLabelFlag,
+					utils.WalletFileFlag,
+				},
+				Description: `Import the mnemonic to wallet, and derive the first <quantity> accounts from it.`,
+			},
 			{
 				Action:    accountList,
 				Name:      "list",
@@ -150,7 +187,7 @@ You can use ./Ontology account --help command to view help information of wallet
 	}
 )
 
-func accountCreate(ctx *cli.Ccntmext) error {
+func checkKeyOptions(ctx *cli.Ccntmext) (keypair.KeyType, byte, signature.SignatureScheme) {
 	reader := bufio.NewReader(os.Stdin)
 	optionType := ""
 	optionCurve := ""
@@ -167,13 +204,15 @@ func accountCreate(ctx *cli.Ccntmext) error {
 		PrintInfoMsg("	curve: %s", curveMap[optionCurve].name)
 		PrintInfoMsg("	signature scheme: %s", schemeMap[optionScheme].name)
 	}
+	return keyTypeMap[optionType].code, curveMap[optionCurve].code, schemeMap[optionScheme].code
+}
+
+func accountCreate(ctx *cli.Ccntmext) error {
+	keyType, curve, scheme := checkKeyOptions(ctx)
 	optionFile := checkFileName(ctx)
 	optionNumber := checkNumber(ctx)
 	optionLabel := checkLabel(ctx)
 	pass, _ := password.GetConfirmedPassword()
-	keyType := keyTypeMap[optionType].code
-	curve := curveMap[optionCurve].code
-	scheme := schemeMap[optionScheme].code
 	wallet, err := account.Open(optionFile)
 	if err != nil {
 		return fmt.Errorf("error opening wallet: %s", err)
@@ -195,26 +234,133 @@ func accountCreate(ctx *cli.Ccntmext) error {
 		PrintInfoMsg("Bind public key: %s", id.Ccntmrol[0].Public)
 		return nil
 	}
-	for i := 0; i < optionNumber; i++ {
-		label := optionLabel
-		if label != "" && optionNumber > 1 {
-			label = fmt.Sprintf("%s%d", label, i+1)
+	if ctx.Bool(utils.GetFlagName(utils.AccountMnemonicFlag)) {
+		mnemonic, err := account.NewMnemonic()
+		if err != nil {
+			return fmt.Errorf("error creating mnemonic: %s", err)
+		}
+		seedId, err := wallet.ImportMnemonic(mnemonic, pass)
+		if err != nil {
+			return fmt.Errorf("error saving mnemonic: %s", err)
+		}
+		err = deriveAccounts(wallet, seedId, optionLabel, optionNumber, keyType, curve, scheme, pass)
+		if err != nil {
+			return err
 		}
+		PrintInfoMsg("Mnemonic of hd seed %s:", seedId)
+		PrintInfoMsg("%s", mnemonic)
+		PrintWarnMsg("Write down the mnemonic and keep it safe. It is the only backup of all the accounts derived from it.")
+		PrintInfoMsg("Create account successfully.")
+		return nil
+	}
+	for i := 0; i < optionNumber; i++ {
+		label := numberedLabel(optionLabel, i, optionNumber)
 		acc, err := wallet.NewAccount(label, keyType, curve, scheme, pass)
 		if err != nil {
 			return fmt.Errorf("error creating new account: %s", err)
 		}
-		PrintInfoMsg("Index:%d", wallet.GetAccountNum())
-		PrintInfoMsg("Label:%s", label)
-		PrintInfoMsg("Address:%s", acc.Address.ToBase58())
-		PrintInfoMsg("Public key:%s", hex.EncodeToString(keypair.SerializePublicKey(acc.PublicKey)))
-		PrintInfoMsg("Signature scheme:%s", acc.SigScheme.Name())
+		printNewAccount(wallet, label, acc)
 	}
 
 	PrintInfoMsg("Create account successfully.")
 	return nil
 }
 
+func numberedLabel(label string, i, number int) string {
+	if label != "" && number > 1 {
+		return fmt.Sprintf("%s%d", label, i+1)
+	}
+	return label
+}
+
+func printNewAccount(wallet account.Client, label string, acc *account.Account) {
+	PrintInfoMsg("Index:%d", wallet.GetAccountNum())
+	PrintInfoMsg("Label:%s", label)
+	PrintInfoMsg("Address:%s", acc.Address.ToBase58())
+	PrintInfoMsg("Public key:%s", hex.EncodeToString(keypair.SerializePublicKey(acc.PublicKey)))
+	PrintInfoMsg("Signature scheme:%s", acc.SigScheme.Name())
+	accMeta := wallet.GetAccountMetadataByAddress(acc.Address.ToBase58())
+	if accMeta != nil && accMeta.HDPath != "" {
+		PrintInfoMsg("HD path:%s", accMeta.HDPath)
+	}
+}
+
+func deriveAccounts(wallet account.Client, seedId, optionLabel string, optionNumber int, keyType keypair.KeyType, curve byte, scheme signature.SignatureScheme, pass []byte) error {
+	for i := 0; i < optionNumber; i++ {
+		label := numberedLabel(optionLabel, i, optionNumber)
+		acc, err := wallet.DeriveAccount(seedId, label, keyType, curve, scheme, pass)
+		if err != nil {
+			return fmt.Errorf("error deriving account: %s", err)
+		}
+		printNewAccount(wallet, label, acc)
+	}
+	return nil
+}
+
+func accountDerive(ctx *cli.Ccntmext) error {
+	wallet, err := common.OpenWallet(ctx)
+	if err != nil {
+		return err
+	}
+	seedId := ctx.String(utils.GetFlagName(utils.AccountHDSeedFlag))
+	if seedId == "" {
+		seeds := wallet.GetWalletData().HDSeeds
+		if len(seeds) != 1 {
+			return fmt.Errorf("wallet has %d hd seeds, please specify one by --%s", len(seeds), utils.GetFlagName(utils.AccountHDSeedFlag))
+		}
+		seedId = seeds[0].Id
+	}
+	keyType, curve, scheme := checkKeyOptions(ctx)
+	optionNumber := checkNumber(ctx)
+	optionLabel := checkLabel(ctx)
+	pass, err := common.GetPasswd(ctx)
+	if err != nil {
+		return err
+	}
+	defer common.ClearPasswd(pass)
+	err = deriveAccounts(wallet, seedId, optionLabel, optionNumber, keyType, curve, scheme, pass)
+	if err != nil {
+		return err
+	}
+	PrintInfoMsg("Derive account successfully.")
+	return nil
+}
+
+func accountRecover(ctx *cli.Ccntmext) error {
+	fmt.Printf("Mnemonic:")
+	mnemonic, err := gopass.GetPasswd()
+	if err != nil {
+		return fmt.Errorf("input mnemonic error: %s", err)
+	}
+	defer common.ClearPasswd(mnemonic)
+	if _, err := account.MnemonicToSeed(string(mnemonic)); err != nil {
+		return err
+	}
+	keyType, curve, scheme := checkKeyOptions(ctx)
+	optionFile := checkFileName(ctx)
+	optionNumber := checkNumber(ctx)
+	optionLabel := checkLabel(ctx)
+	pass, err := password.GetConfirmedPassword()
+	if err != nil {
+		return fmt.Errorf("input password error: %s", err)
+	}
+	defer common.ClearPasswd(pass)
+	wallet, err := account.Open(optionFile)
+	if err != nil {
+		return fmt.Errorf("error opening wallet: %s", err)
+	}
+	seedId, err := wallet.ImportMnemonic(string(mnemonic), pass)
+	if err != nil {
+		return fmt.Errorf("error saving mnemonic: %s", err)
+	}
+	err = deriveAccounts(wallet, seedId, optionLabel, optionNumber, keyType, curve, scheme, pass)
+	if err != nil {
+		return err
+	}
+	PrintInfoMsg("Recover account of hd seed %s successfully.", seedId)
+	return nil
+}
+
 func accountList(ctx *cli.Ccntmext) error {
 	optionFile := checkFileName(ctx)
 	wallet, err := account.Open(optionFile)
@@ -265,6 +411,10 @@ func accountList(ctx *cli.Ccntmext) error {
 		PrintInfoMsg("	Curve: %v", accMeta.Curve)
 		PrintInfoMsg("	Key length: %v bits", len(accMeta.Key)*8)
 		PrintInfoMsg("	Public key: %v", accMeta.PubKey)
+		if accMeta.HDPath != "" {
+			PrintInfoMsg("	HD seed: %v", accMeta.HDSeed)
+			PrintInfoMsg("	HD path: %v", accMeta.HDPath)
+		}
 		PrintInfoMsg("	Signature scheme: %v\n", accMeta.SigSch)
 	}
 	return nil
@@ -20,6 +20,7 @@ package cmd
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/cntmio/cntmology/cmd/utils"
 	"github.com/cntmio/cntmology/common"
@@ -91,6 +92,7 @@ func setGenesis(ctx *cli.Ccntmext, cfg *config.OntologyConfig) error {
 		if cfg.Genesis.SOLO.GenBlockTime <= 1 {
 			cfg.Genesis.SOLO.GenBlockTime = config.DEFAULT_GEN_BLOCK_TIME
 		}
+		cfg.Genesis.SOLO.DevMode = ctx.Bool(utils.GetFlagName(utils.TestModeDevFlag))
 		return nil
 	}
 
@@ -137,8 +139,16 @@ func setGenesis(ctx *cli.Ccntmext, cfg *config.OntologyConfig) error {
 func setCommonConfig(ctx *cli.Ccntmext, cfg *config.CommonConfig) {
 	cfg.LogLevel = ctx.Uint(utils.GetFlagName(utils.LogLevelFlag))
 	cfg.EnableEventLog = !ctx.Bool(utils.GetFlagName(utils.DisableEventLogFlag))
+	cfg.EnableArchive = ctx.Bool(utils.GetFlagName(utils.EnableArchiveFlag))
+	cfg.PruneBlocks = uint32(ctx.Uint(utils.GetFlagName(utils.PruneBlocksFlag)))
+	cfg.SnapshotInterval = uint32(ctx.Uint(utils.GetFlagName(utils.SnapshotIntervalFlag)))
+	cfg.StateTrieHeight = uint32(ctx.Uint(utils.GetFlagName(utils.StateTrieHeightFlag)))
 	cfg.MinGasLimit = ctx.Uint64(utils.GetFlagName(utils.GasLimitFlag))
 	cfg.GasPrice = ctx.Uint64(utils.GetFlagName(utils.GasPriceFlag))
+	cfg.TxPoolCapacity = uint32(ctx.Uint(utils.GetFlagName(utils.TxPoolCapacityFlag)))
+	cfg.TxPoolPayerLimit = uint32(ctx.Uint(utils.GetFlagName(utils.TxPoolPayerLimitFlag)))
+	cfg.TxPoolPriceBump = ctx.Uint64(utils.GetFlagName(utils.TxPoolPriceBumpFlag))
+	cfg.TxPoolLifetime = uint32(ctx.Uint(utils.GetFlagName(utils.TxPoolLifetimeFlag)))
 	cfg.DataDir = ctx.String(utils.GetFlagName(utils.DataDirFlag))
 	//add new flag for ethgaslimit
 	cfg.ETHTxGasLimit = ctx.Uint64(utils.GetFlagName(utils.ETHTxGasLimitFlag))
@@ -161,6 +171,16 @@ func setP2PNodeConfig(ctx *cli.Ccntmext, cfg *config.P2PNodeConfig) {
 	cfg.MaxConnInBound = ctx.Uint(utils.GetFlagName(utils.MaxConnInBoundFlag))
 	cfg.MaxConnOutBound = ctx.Uint(utils.GetFlagName(utils.MaxConnOutBoundFlag))
 	cfg.MaxConnInBoundForSingleIP = ctx.Uint(utils.GetFlagName(utils.MaxConnInBoundForSingleIPFlag))
+	cfg.FastSync = ctx.Bool(utils.GetFlagName(utils.FastSyncFlag))
+	cfg.RequireEncryption = ctx.Bool(utils.GetFlagName(utils.RequireEncryptionFlag))
+	cfg.PinnedPeers = nil
+	for _, pinned := range strings.Split(ctx.String(utils.GetFlagName(utils.PinnedPeersFlag)), ",") {
+		if pinned = strings.TrimSpace(pinned); pinned != "" {
+			cfg.PinnedPeers = append(cfg.PinnedPeers, pinned)
+		}
+	}
+	cfg.MaxBandwidth = ctx.Uint(utils.GetFlagName(utils.MaxBandwidthFlag))
+	cfg.MaxPeerBandwidth = ctx.Uint(utils.GetFlagName(utils.MaxPeerBandwidthFlag))
 
 	rsvfile := ctx.String(utils.GetFlagName(utils.ReservedPeersFileFlag))
 	if cfg.ReservedPeersOnly {
@@ -187,6 +207,7 @@ func setRpcConfig(ctx *cli.Ccntmext, cfg *config.RpcConfig) {
 	cfg.EnableHttpJsonRpc = !ctx.Bool(utils.GetFlagName(utils.RPCDisabledFlag))
 	cfg.HttpJsonPort = ctx.Uint(utils.GetFlagName(utils.RPCPortFlag))
 	cfg.HttpLocalPort = ctx.Uint(utils.GetFlagName(utils.RPCLocalProtFlag))
+	cfg.EnableEthRpc = ctx.Bool(utils.GetFlagName(utils.ETHRPCEnableFlag))
 	cfg.EthJsonPort = ctx.Uint(utils.GetFlagName(utils.ETHRPCPortFlag))
 }
 
@@ -200,6 +221,12 @@ func setGraphQLConfig(ctx *cli.Ccntmext, cfg *config.GraphQLConfig) {
 	cfg.EnableGraphQL = ctx.Bool(utils.GetFlagName(utils.GraphQLEnableFlag))
 	cfg.GraphQLPort = ctx.Uint(utils.GetFlagName(utils.GraphQLPortFlag))
 	cfg.MaxConnections = ctx.Uint(utils.GetFlagName(utils.GraphQLMaxConnsFlag))
+	cfg.AllowedOrigins = nil
+	for _, origin := range strings.Split(ctx.String(utils.GetFlagName(utils.GraphQLOriginsFlag)), ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			cfg.AllowedOrigins = append(cfg.AllowedOrigins, origin)
+		}
+	}
 }
 
 func setWebSocketConfig(ctx *cli.Ccntmext, cfg *config.WebSocketConfig) {
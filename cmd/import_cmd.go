@@ -48,6 +48,7 @@ var ImportCommand = cli.Command{
 		utils.ConfigFlag,
 		utils.NetworkIdFlag,
 		utils.DisableEventLogFlag,
+		utils.EnableArchiveFlag,
 	},
 	Description: "Note that import cmd doesn't support testmode",
 }
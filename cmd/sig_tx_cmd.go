@@ -23,6 +23,7 @@ import (
 	"strings"
 
 	"github.com/cntmio/cntmology-crypto/keypair"
+	"github.com/cntmio/cntmology/account/signer"
 	cmdcom "github.com/cntmio/cntmology/cmd/common"
 	"github.com/cntmio/cntmology/cmd/utils"
 	"github.com/cntmio/cntmology/common"
@@ -54,6 +55,8 @@ var MultiSigTxCommand = cli.Command{
 		utils.AccountMultiMFlag,
 		utils.AccountMultiPubKeyFlag,
 		utils.AccountAddressFlag,
+		utils.SignerFlag,
+		utils.SignerSecretFileFlag,
 		utils.SendTxFlag,
 		utils.PrepareExecTransactionFlag,
 	},
@@ -252,14 +255,30 @@ func sigToTx(ctx *cli.Ccntmext) error {
 		return fmt.Errorf("IntoMutable error:%s", err)
 	}
 
-	acc, err := cmdcom.GetAccount(ctx)
-	if err != nil {
-		return fmt.Errorf("GetAccount error:%s", err)
-	}
+	if ctx.IsSet(utils.GetFlagName(utils.SignerFlag)) {
+		secret, err := signer.LoadSecret(ctx.String(utils.GetFlagName(utils.SignerSecretFileFlag)))
+		if err != nil {
+			return fmt.Errorf("LoadSecret error:%s", err)
+		}
+		remote, err := signer.NewRemoteSigner(ctx.String(utils.GetFlagName(utils.SignerFlag)), secret)
+		if err != nil {
+			return fmt.Errorf("NewRemoteSigner error:%s", err)
+		}
+		defer remote.Close()
+		err = utils.SignTransactionBySigner(remote, mutTx)
+		if err != nil {
+			return fmt.Errorf("SignTransaction error:%s", err)
+		}
+	} else {
+		acc, err := cmdcom.GetAccount(ctx)
+		if err != nil {
+			return fmt.Errorf("GetAccount error:%s", err)
+		}
 
-	err = utils.SignTransaction(acc, mutTx)
-	if err != nil {
-		return fmt.Errorf("SignTransaction error:%s", err)
+		err = utils.SignTransaction(acc, mutTx)
+		if err != nil {
+			return fmt.Errorf("SignTransaction error:%s", err)
+		}
 	}
 
 	tx, err = mutTx.IntoImmutable()
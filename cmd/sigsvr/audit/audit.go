@@ -0,0 +1,183 @@
+/*
+ * Copyright (C) 2018 The cntmology Authors
+ * This file is part of The cntmology library.
+ *
+ * The cntmology is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The cntmology is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * alcntm with The cntmology.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+//Package audit keeps the append only log of sig server requests. Every entry is
+//chained to the previous one by hash and signed, so that the log cannot be
+//modified or truncated in the middle without being detected
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/cntmio/cntmology/cmd/sigsvr/policy"
+	"golang.org/x/crypto/ed25519"
+)
+
+const MAX_ENTRY_SIZE = 1024 * 1024
+
+type Entry struct {
+	Seq       uint64         `json:"seq"`
+	Time      int64          `json:"time"`
+	Remote    string         `json:"remote"`
+	Client    string         `json:"client,omitempty"`
+	Qid       string         `json:"qid,omitempty"`
+	Method    string         `json:"method"`
+	Account   string         `json:"account,omitempty"`
+	DryRun    bool           `json:"dry_run,omitempty"`
+	Params    string         `json:"params,omitempty"` //sha256 of request params
+	Tx        *policy.TxInfo `json:"tx,omitempty"`
+	ErrorCode int            `json:"error_code"`
+	ErrorInfo string         `json:"error_info,omitempty"`
+	Prev      string         `json:"prev"` //sha256 of the previous line
+	Sig       string         `json:"sig"`
+}
+
+type Log struct {
+	lock sync.Mutex
+	file *os.File
+	key  ed25519.PrivateKey
+	seq  uint64
+	prev string
+}
+
+//LoadKey loads the ed25519 key to sign audit log, a new key is generated if file not exist
+func LoadKey(file string) (ed25519.PrivateKey, error) {
+	data, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		if err = ioutil.WriteFile(file, []byte(hex.EncodeToString(key.Seed())), 0600); err != nil {
+			return nil, err
+		}
+		return key, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	seed, err := hex.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil || len(seed) != ed25519.SeedSize {
+		return nil, fmt.Errorf("invalid audit key file %s", file)
+	}
+	return ed25519.NewKeyFromSeed(seed), nil
+}
+
+//Open verifies the existing log and opens it for appending
+func Open(file string, key ed25519.PrivateKey) (*Log, error) {
+	seq, prev, err := verify(file, key.Public().(ed25519.PublicKey))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	f, err := os.OpenFile(file, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	return &Log{file: f, key: key, seq: seq, prev: prev}, nil
+}
+
+//Append signs the entry and appends it to the log. The entry is on disk when it returns
+func (this *Log) Append(entry *Entry) error {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	entry.Seq = this.seq + 1
+	entry.Prev = this.prev
+	entry.Sig = ""
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	entry.Sig = hex.EncodeToString(ed25519.Sign(this.key, data))
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if _, err = this.file.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	if err = this.file.Sync(); err != nil {
+		return err
+	}
+	this.seq = entry.Seq
+	this.prev = lineHash(line)
+	return nil
+}
+
+func (this *Log) Close() error {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	return this.file.Close()
+}
+
+//Verify checks the signatures and chain of log, returns the number of entries
+func Verify(file string, pub ed25519.PublicKey) (uint64, error) {
+	seq, _, err := verify(file, pub)
+	return seq, err
+}
+
+func verify(file string, pub ed25519.PublicKey) (uint64, string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return 0, "", err
+	}
+	defer f.Close()
+	seq := uint64(0)
+	prev := ""
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 4096), MAX_ENTRY_SIZE)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		entry := &Entry{}
+		if err = json.Unmarshal(line, entry); err != nil {
+			return seq, prev, fmt.Errorf("invalid audit entry %d: %s", seq+1, err)
+		}
+		if entry.Seq != seq+1 || entry.Prev != prev {
+			return seq, prev, fmt.Errorf("audit log broken at entry %d", seq+1)
+		}
+		sig, err := hex.DecodeString(entry.Sig)
+		if err != nil {
+			return seq, prev, fmt.Errorf("invalid signature of audit entry %d", entry.Seq)
+		}
+		entry.Sig = ""
+		data, err := json.Marshal(entry)
+		if err != nil {
+			return seq, prev, err
+		}
+		if !ed25519.Verify(pub, data, sig) {
+			return seq, prev, fmt.Errorf("invalid signature of audit entry %d", entry.Seq)
+		}
+		seq = entry.Seq
+		prev = lineHash(line)
+	}
+	return seq, prev, scanner.Err()
+}
+
+func lineHash(line []byte) string {
+	h := sha256.Sum256(bytes.TrimSpace(line))
+	return hex.EncodeToString(h[:])
+}
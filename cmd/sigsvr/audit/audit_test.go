@@ -0,0 +1,89 @@
+/*
+ * Copyright (C) 2018 The cntmology Authors
+ * This file is part of The cntmology library.
+ *
+ * The cntmology is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The cntmology is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * alcntm with The cntmology.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package audit
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cntmio/cntmology/cmd/sigsvr/policy"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/ed25519"
+)
+
+func TestAuditLog(t *testing.T) {
+	dir, err := ioutil.TempDir("", "audit")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	key, err := LoadKey(filepath.Join(dir, "audit.key"))
+	assert.Nil(t, err)
+	loaded, err := LoadKey(filepath.Join(dir, "audit.key"))
+	assert.Nil(t, err)
+	assert.Equal(t, key, loaded)
+	pub := key.Public().(ed25519.PublicKey)
+
+	file := filepath.Join(dir, "audit.log")
+	log, err := Open(file, key)
+	assert.Nil(t, err)
+	tx := &policy.TxInfo{
+		Method:    "transfer",
+		Params:    []interface{}{"00", "10"},
+		Transfers: []*policy.Transfer{{Value: big.NewInt(10)}},
+	}
+	assert.Nil(t, log.Append(&Entry{Method: "sigtransfertx", Client: "wallet", Tx: tx}))
+	assert.Nil(t, log.Append(&Entry{Method: "sigdata", ErrorCode: 1011}))
+	assert.Nil(t, log.Close())
+
+	//reopen continues the chain
+	log, err = Open(file, key)
+	assert.Nil(t, err)
+	assert.Nil(t, log.Append(&Entry{Method: "sigrawtx"}))
+	assert.Nil(t, log.Close())
+	count, err := Verify(file, pub)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(3), count)
+
+	other, err := LoadKey(filepath.Join(dir, "other.key"))
+	assert.Nil(t, err)
+	_, err = Verify(file, other.Public().(ed25519.PublicKey))
+	assert.NotNil(t, err)
+	_, err = Open(file, other)
+	assert.NotNil(t, err)
+
+	data, err := ioutil.ReadFile(file)
+	assert.Nil(t, err)
+	lines := bytes.SplitAfter(data, []byte("\n"))
+
+	//tampered entry
+	tampered := bytes.Replace(data, []byte(`"error_code":1011`), []byte(`"error_code":0`), 1)
+	assert.Nil(t, ioutil.WriteFile(file, tampered, 0600))
+	_, err = Verify(file, pub)
+	assert.NotNil(t, err)
+
+	//removed entry
+	assert.Nil(t, ioutil.WriteFile(file, append(lines[0], lines[2]...), 0600))
+	count, err = Verify(file, pub)
+	assert.NotNil(t, err)
+	assert.Equal(t, uint64(1), count)
+}
@@ -0,0 +1,105 @@
+/*
+ * Copyright (C) 2018 The cntmology Authors
+ * This file is part of The cntmology library.
+ *
+ * The cntmology is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The cntmology is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * alcntm with The cntmology.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package sigsvr
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/cntmio/cntmology/cmd/sigsvr/common"
+)
+
+//LoadApiKeys loads the api keys from json file of client name => hex sha256 of api key.
+//Only the hash is stored so that the file does not leak the keys
+func LoadApiKeys(file string) (map[string][]byte, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	hexKeys := make(map[string]string)
+	if err = json.Unmarshal(data, &hexKeys); err != nil {
+		return nil, fmt.Errorf("invalid api key file %s: %s", file, err)
+	}
+	keys := make(map[string][]byte, len(hexKeys))
+	for name, hexKey := range hexKeys {
+		hash, err := hex.DecodeString(hexKey)
+		if err != nil || len(hash) != sha256.Size {
+			return nil, fmt.Errorf("invalid api key hash of client %s", name)
+		}
+		keys[name] = hash
+	}
+	return keys, nil
+}
+
+//NewTLSConfig returns the tls config which requires client certificate signed by clientCAFile
+func NewTLSConfig(certFile, keyFile, clientCAFile string) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("load tls key pair error: %s", err)
+	}
+	config := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if clientCAFile != "" {
+		data, err := ioutil.ReadFile(clientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("no certificate in %s", clientCAFile)
+		}
+		config.ClientCAs = pool
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return config, nil
+}
+
+//authenticate returns the name of client, by api key or common name of client certificate
+func (this *CliRpcServer) authenticate(r *http.Request) (string, error) {
+	client := ""
+	if r.TLS != nil && len(r.TLS.PeerCertificates) > 0 {
+		client = r.TLS.PeerCertificates[0].Subject.CommonName
+	}
+	if len(this.apiKeys) == 0 {
+		return client, nil
+	}
+	key := r.Header.Get(common.API_KEY_HEADER)
+	if key == "" {
+		return "", fmt.Errorf("missing api key")
+	}
+	hash := sha256.Sum256([]byte(key))
+	for name, expected := range this.apiKeys {
+		if subtle.ConstantTimeCompare(hash[:], expected) == 1 {
+			if client != "" && client != name {
+				return "", fmt.Errorf("api key of %s does not match client certificate of %s", name, client)
+			}
+			return name, nil
+		}
+	}
+	return "", fmt.Errorf("invalid api key")
+}
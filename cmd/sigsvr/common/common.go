@@ -19,21 +19,74 @@
 package common
 
 import (
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/cntmio/cntmology/account"
+	"github.com/cntmio/cntmology/account/signer"
+	"github.com/cntmio/cntmology/cmd/sigsvr/policy"
+	"github.com/cntmio/cntmology/cmd/sigsvr/session"
 	"github.com/cntmio/cntmology/cmd/sigsvr/store"
+	"github.com/cntmio/cntmology/common/log"
+	"github.com/cntmio/cntmology/core/types"
 )
 
+//API_KEY_HEADER is the http header of api key to authenticate client
+const API_KEY_HEADER = "X-Api-Key"
+
 var DefWalletStore *store.WalletStore
 
+//DefRemoteSigner signs for its own address without the wallet, nil if not configured
+var DefRemoteSigner signer.Signer
+
+//DefPolicy restricts what can be signed, nil to sign anything of the unlocked accounts
+var DefPolicy *policy.Policy
+
+//DefSessionMgr keeps the multi-signature sessions coordinated by this sig server
+var DefSessionMgr *session.Manager
+
+//DefSessionExpire is the expire time of session if not specified by the creator
+var DefSessionExpire = 24 * time.Hour
+
+//DefSessionApiKey is sent to the sig server which coordinates the remote session
+var DefSessionApiKey string
+
+//DefSessionPeers are the urls of the sig servers allowed to coordinate remote sessions,
+//remote sessions are refused if empty
+var DefSessionPeers []string
+
+//IsSessionPeer returns whether the sig server of url is allowed to coordinate remote sessions
+func IsSessionPeer(url string) bool {
+	url = strings.TrimRight(url, "/")
+	for _, peer := range DefSessionPeers {
+		if url == strings.TrimRight(peer, "/") {
+			return true
+		}
+	}
+	return false
+}
+
 type CliRpcRequest struct {
 	Qid     string          `json:"qid"`
 	Params  json.RawMessage `json:"params"`
 	Account string          `json:"account"`
 	Pwd     string          `json:"pwd"`
 	Method  string          `json:"method"`
+	DryRun  bool            `json:"dry_run,omitempty"`
+
+	Client  string         `json:"-"` //name of the authenticated client
+	Signing string         `json:"-"` //address of the signing account
+	TxInfo  *policy.TxInfo `json:"-"` //decoded data to sign, for audit
+}
+
+//DryRunResult is the result of dry run request, which is decoded but not signed
+type DryRunResult struct {
+	Tx      *policy.TxInfo `json:"tx"`
+	Allowed bool           `json:"allowed"`
+	Reason  string         `json:"reason,omitempty"`
 }
 
 func (this *CliRpcRequest) GetAccount() (*account.Account, error) {
@@ -57,6 +110,53 @@ func (this *CliRpcRequest) GetAccount() (*account.Account, error) {
 	return acc, nil
 }
 
+//GetSigner return the remote signer if the request is for its address, else the wallet account
+func (this *CliRpcRequest) GetSigner() (signer.Signer, error) {
+	if DefRemoteSigner != nil {
+		if this.Account == "" || this.Account == signer.Address(DefRemoteSigner).ToBase58() {
+			return DefRemoteSigner, nil
+		}
+	}
+	acc, err := this.GetAccount()
+	if err != nil {
+		return nil, err
+	}
+	return signer.NewLocalSigner(acc), nil
+}
+
+//CheckSign decodes the tx or raw data to sign and checks it with the policy. It returns
+//false if nothing should be signed, for dry run or denied, with resp filled
+func (this *CliRpcRequest) CheckSign(s signer.Signer, tx *types.MutableTransaction, rawData []byte, resp *CliRpcResponse) bool {
+	this.Signing = signer.Address(s).ToBase58()
+	var err error
+	if tx != nil {
+		this.TxInfo, err = policy.DecodeTx(tx)
+	} else {
+		this.TxInfo = &policy.TxInfo{RawData: hex.EncodeToString(rawData)}
+	}
+	if err == nil && DefPolicy != nil {
+		err = DefPolicy.Check(this.Client, this.Method, this.Signing, this.TxInfo, this.DryRun)
+	} else if err != nil && DefPolicy == nil {
+		log.Debugf("Cli Qid:%s %s DecodeTx error:%s", this.Qid, this.Method, err)
+		err = nil
+	}
+	if this.DryRun {
+		result := &DryRunResult{Tx: this.TxInfo, Allowed: err == nil}
+		if err != nil {
+			result.Reason = err.Error()
+		}
+		resp.Result = result
+		return false
+	}
+	if err != nil {
+		log.Infof("Cli Qid:%s %s denied by policy:%s", this.Qid, this.Method, err)
+		resp.ErrorCode = CLIERR_POLICY_DENIED
+		resp.ErrorInfo = err.Error()
+		return false
+	}
+	return true
+}
+
 type CliRpcResponse struct {
 	Qid       string      `json:"qid"`
 	Method    string      `json:"method"`
@@ -31,4 +31,10 @@ func init() {
 	DefCliRpcSvr.RegHandler("signeovminvoketx", handlers.SigNeoVMInvokeTx)
 	DefCliRpcSvr.RegHandler("signeovminvokeabitx", handlers.SigNeoVMInvokeAbiTx)
 	DefCliRpcSvr.RegHandler("signativeinvoketx", handlers.SigNativeInvokeTx)
+	DefCliRpcSvr.RegHandler("createsigsession", handlers.CreateSigSession)
+	DefCliRpcSvr.RegHandler("getsigsession", handlers.GetSigSession)
+	DefCliRpcSvr.RegHandler("listsigsession", handlers.ListSigSession)
+	DefCliRpcSvr.RegHandler("sigsession", handlers.SigSession)
+	DefCliRpcSvr.RegHandler("rejectsigsession", handlers.RejectSigSession)
+	DefCliRpcSvr.RegHandler("submitsigsession", handlers.SubmitSigSession)
 }
@@ -22,7 +22,6 @@ import (
 	"encoding/json"
 
 	clisvrcom "github.com/cntmio/cntmology/cmd/sigsvr/common"
-	cliutil "github.com/cntmio/cntmology/cmd/utils"
 	"github.com/cntmio/cntmology/common/log"
 )
 
@@ -47,13 +46,16 @@ func SigData(req *clisvrcom.CliRpcRequest, resp *clisvrcom.CliRpcResponse) {
 		resp.ErrorCode = clisvrcom.CLIERR_INVALID_PARAMS
 		return
 	}
-	signer, err := req.GetAccount()
+	signer, err := req.GetSigner()
 	if err != nil {
-		log.Infof("Cli Qid:%s SigData GetAccount:%s", req.Qid, err)
+		log.Infof("Cli Qid:%s SigData GetSigner:%s", req.Qid, err)
 		resp.ErrorCode = clisvrcom.CLIERR_ACCOUNT_UNLOCK
 		return
 	}
-	sigData, err := cliutil.Sign(rawData, signer)
+	if !req.CheckSign(signer, nil, rawData, resp) {
+		return
+	}
+	sigData, err := signer.Sign(rawData)
 	if err != nil {
 		log.Infof("Cli Qid:%s SigData Sign error:%s", req.Qid, err)
 		resp.ErrorCode = clisvrcom.CLIERR_INTERNAL_ERR
@@ -90,13 +90,16 @@ func SigMutilRawTransaction(req *clisvrcom.CliRpcRequest, resp *clisvrcom.CliRpc
 		pubKeys = append(pubKeys, pk)
 	}
 
-	signer, err := req.GetAccount()
+	signer, err := req.GetSigner()
 	if err != nil {
-		log.Infof("Cli Qid:%s SigMutilRawTransaction GetAccount:%s", req.Qid, err)
+		log.Infof("Cli Qid:%s SigMutilRawTransaction GetSigner:%s", req.Qid, err)
 		resp.ErrorCode = clisvrcom.CLIERR_ACCOUNT_UNLOCK
 		return
 	}
-	err = cliutil.MultiSigTransaction(mutTx, uint16(rawReq.M), pubKeys, signer)
+	if !req.CheckSign(signer, mutTx, nil, resp) {
+		return
+	}
+	err = cliutil.MultiSigTransactionBySigner(mutTx, uint16(rawReq.M), pubKeys, signer)
 	if err != nil {
 		log.Infof("Cli Qid:%s SigMutilRawTransaction MultiSigTransaction error:%s", req.Qid, err)
 		resp.ErrorCode = clisvrcom.CLIERR_INTERNAL_ERR
@@ -83,13 +83,16 @@ func SigNativeInvokeTx(req *clisvrcom.CliRpcRequest, resp *clisvrcom.CliRpcRespo
 		tx.Payer = payerAddress
 	}
 
-	signer, err := req.GetAccount()
+	signer, err := req.GetSigner()
 	if err != nil {
-		log.Infof("Cli Qid:%s SigNativeInvokeTx GetAccount:%s", req.Qid, err)
+		log.Infof("Cli Qid:%s SigNativeInvokeTx GetSigner:%s", req.Qid, err)
 		resp.ErrorCode = clisvrcom.CLIERR_ACCOUNT_UNLOCK
 		return
 	}
-	err = cliutil.SignTransaction(signer, tx)
+	if !req.CheckSign(signer, tx, nil, resp) {
+		return
+	}
+	err = cliutil.SignTransactionBySigner(signer, tx)
 	if err != nil {
 		log.Infof("Cli Qid:%s SigNativeInvokeTx SignTransaction error:%s", req.Qid, err)
 		resp.ErrorCode = clisvrcom.CLIERR_INTERNAL_ERR
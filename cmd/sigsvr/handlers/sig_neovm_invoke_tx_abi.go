@@ -88,13 +88,16 @@ func SigNeoVMInvokeAbiTx(req *clisvrcom.CliRpcRequest, resp *clisvrcom.CliRpcRes
 		}
 		mutable.Payer = payerAddress
 	}
-	signer, err := req.GetAccount()
+	signer, err := req.GetSigner()
 	if err != nil {
-		log.Infof("Cli Qid:%s SigNeoVMInvokeAbiTx GetAccount:%s", req.Qid, err)
+		log.Infof("Cli Qid:%s SigNeoVMInvokeAbiTx GetSigner:%s", req.Qid, err)
 		resp.ErrorCode = clisvrcom.CLIERR_ACCOUNT_UNLOCK
 		return
 	}
-	err = cliutil.SignTransaction(signer, mutable)
+	if !req.CheckSign(signer, mutable, nil, resp) {
+		return
+	}
+	err = cliutil.SignTransactionBySigner(signer, mutable)
 	if err != nil {
 		log.Infof("Cli Qid:%s SigNeoVMInvokeAbiTx SignTransaction error:%s", req.Qid, err)
 		resp.ErrorCode = clisvrcom.CLIERR_INTERNAL_ERR
@@ -23,8 +23,8 @@ import (
 	"encoding/json"
 
 	"github.com/cntmio/cntmology-crypto/keypair"
+	accsigner "github.com/cntmio/cntmology/account/signer"
 	clisvrcom "github.com/cntmio/cntmology/cmd/sigsvr/common"
-	cliutil "github.com/cntmio/cntmology/cmd/utils"
 	"github.com/cntmio/cntmology/common"
 	"github.com/cntmio/cntmology/common/log"
 	"github.com/cntmio/cntmology/core/types"
@@ -63,19 +63,21 @@ func SigRawTransaction(req *clisvrcom.CliRpcRequest, resp *clisvrcom.CliRpcRespo
 		resp.ErrorCode = clisvrcom.CLIERR_INVALID_TX
 		return
 	}
-	signer, err := req.GetAccount()
+	signer, err := req.GetSigner()
 	if err != nil {
-		log.Infof("Cli Qid:%s SigRawTransaction GetAccount:%s", req.Qid, err)
+		log.Infof("Cli Qid:%s SigRawTransaction GetSigner:%s", req.Qid, err)
 		resp.ErrorCode = clisvrcom.CLIERR_ACCOUNT_UNLOCK
 		return
 	}
 	var emptyAddress = common.Address{}
 	if mutable.Payer == emptyAddress {
-		mutable.Payer = signer.Address
+		mutable.Payer = accsigner.Address(signer)
 	}
 
-	txHash := mutable.Hash()
-	sigData, err := cliutil.Sign(txHash.ToArray(), signer)
+	if !req.CheckSign(signer, mutable, nil, resp) {
+		return
+	}
+	sigData, err := signer.SignTransaction(mutable)
 	if err != nil {
 		log.Infof("Cli Qid:%s SigRawTransaction Sign error:%s", req.Qid, err)
 		resp.ErrorCode = clisvrcom.CLIERR_INTERNAL_ERR
@@ -85,7 +87,7 @@ func SigRawTransaction(req *clisvrcom.CliRpcRequest, resp *clisvrcom.CliRpcRespo
 		mutable.Sigs = make([]types.Sig, 0)
 	}
 	mutable.Sigs = append(mutable.Sigs, types.Sig{
-		PubKeys: []keypair.PublicKey{signer.PublicKey},
+		PubKeys: []keypair.PublicKey{signer.PubKey()},
 		M:       1,
 		SigData: [][]byte{sigData},
 	})
@@ -0,0 +1,504 @@
+/*
+ * Copyright (C) 2018 The cntmology Authors
+ * This file is part of The cntmology library.
+ *
+ * The cntmology is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The cntmology is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * alcntm with The cntmology.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package handlers
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/cntmio/cntmology-crypto/keypair"
+	accsigner "github.com/cntmio/cntmology/account/signer"
+	clisvrcom "github.com/cntmio/cntmology/cmd/sigsvr/common"
+	"github.com/cntmio/cntmology/cmd/sigsvr/policy"
+	"github.com/cntmio/cntmology/cmd/sigsvr/session"
+	"github.com/cntmio/cntmology/common"
+	"github.com/cntmio/cntmology/common/log"
+	"github.com/cntmio/cntmology/core/types"
+)
+
+//sessionClient requests the sig server which coordinates the remote session. Redirects are not followed,
+//so the api key is never sent to a sig server not allowed
+var sessionClient = &http.Client{
+	Timeout: 30 * time.Second,
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+type CreateSigSessionReq struct {
+	RawTx   string   `json:"raw_tx"`
+	M       int      `json:"m"`
+	PubKeys []string `json:"pub_keys"`
+	Expire  uint32   `json:"expire"` //seconds, default by sig server
+	Sign    bool     `json:"sign"`   //add the signature of account
+}
+
+type SigSessionRsp struct {
+	Session *session.Session `json:"session"`
+	Tx      *policy.TxInfo   `json:"tx,omitempty"` //decoded tx of session
+}
+
+type GetSigSessionReq struct {
+	Url string `json:"url"` //sig server which coordinates the session, empty for this sig server
+	Id  string `json:"id"`
+}
+
+type ListSigSessionReq struct {
+	Status string `json:"status"`
+}
+
+type ListSigSessionRsp struct {
+	Sessions []*session.Session `json:"sessions"`
+}
+
+type SigSessionReq struct {
+	Url    string `json:"url"`
+	Id     string `json:"id"`
+	PubKey string `json:"pub_key"` //set with sig by the sig server of other holder
+	Sig    string `json:"sig"`
+}
+
+type RejectSigSessionReq struct {
+	Url    string `json:"url"`
+	Id     string `json:"id"`
+	Reason string `json:"reason"`
+	PubKey string `json:"pub_key"` //set with sig by the sig server of other holder
+	Sig    string `json:"sig"`
+}
+
+type SubmitSigSessionReq struct {
+	Id string `json:"id"`
+}
+
+//CreateSigSession starts a session to collect the signatures of the holders of multisig address
+func CreateSigSession(req *clisvrcom.CliRpcRequest, resp *clisvrcom.CliRpcResponse) {
+	rawReq := &CreateSigSessionReq{}
+	err := json.Unmarshal(req.Params, rawReq)
+	if err != nil {
+		resp.ErrorCode = clisvrcom.CLIERR_INVALID_PARAMS
+		return
+	}
+	mutTx, err := parseMutableTx(rawReq.RawTx)
+	if err != nil {
+		log.Infof("Cli Qid:%s CreateSigSession parse tx error:%s", req.Qid, err)
+		resp.ErrorCode = clisvrcom.CLIERR_INVALID_TX
+		return
+	}
+	pubKeys := make([]keypair.PublicKey, 0, len(rawReq.PubKeys))
+	for _, pkStr := range rawReq.PubKeys {
+		pk, err := parsePubKey(pkStr)
+		if err != nil {
+			log.Infof("Cli Qid:%s CreateSigSession parse pub key error:%s", req.Qid, err)
+			resp.ErrorCode = clisvrcom.CLIERR_INVALID_PARAMS
+			return
+		}
+		pubKeys = append(pubKeys, pk)
+	}
+	addr, err := types.AddressFromMultiPubKeys(pubKeys, rawReq.M)
+	if err != nil {
+		resp.ErrorCode = clisvrcom.CLIERR_INVALID_PARAMS
+		resp.ErrorInfo = err.Error()
+		return
+	}
+	if mutTx.Payer == common.ADDRESS_EMPTY {
+		mutTx.Payer = addr
+	}
+
+	var signer accsigner.Signer
+	if rawReq.Sign {
+		signer, err = req.GetSigner()
+		if err != nil {
+			log.Infof("Cli Qid:%s CreateSigSession GetSigner:%s", req.Qid, err)
+			resp.ErrorCode = clisvrcom.CLIERR_ACCOUNT_UNLOCK
+			return
+		}
+		holder := false
+		for _, pk := range pubKeys {
+			if keypair.ComparePublicKey(pk, signer.PubKey()) {
+				holder = true
+				break
+			}
+		}
+		if !holder {
+			resp.ErrorCode = clisvrcom.CLIERR_INVALID_PARAMS
+			resp.ErrorInfo = "account is not a holder of multisig address"
+			return
+		}
+		if !req.CheckSign(signer, mutTx, nil, resp) {
+			return
+		}
+	}
+
+	expire := clisvrcom.DefSessionExpire
+	if rawReq.Expire > 0 {
+		expire = time.Duration(rawReq.Expire) * time.Second
+	}
+	s, err := clisvrcom.DefSessionMgr.Create(req.Client, mutTx, rawReq.M, pubKeys, expire)
+	if err != nil {
+		log.Infof("Cli Qid:%s CreateSigSession error:%s", req.Qid, err)
+		resp.ErrorCode = clisvrcom.CLIERR_SESSION
+		resp.ErrorInfo = err.Error()
+		return
+	}
+	log.Infof("Cli Qid:%s CreateSigSession session:%s tx:%s", req.Qid, s.Id, s.TxHash)
+	if signer != nil && s.Status == session.STATUS_PENDING {
+		tx, err := s.Tx()
+		if err != nil {
+			resp.ErrorCode = clisvrcom.CLIERR_INTERNAL_ERR
+			return
+		}
+		sig, err := signer.SignTransaction(tx)
+		if err != nil {
+			log.Infof("Cli Qid:%s CreateSigSession Sign error:%s", req.Qid, err)
+			resp.ErrorCode = clisvrcom.CLIERR_INTERNAL_ERR
+			return
+		}
+		s, err = clisvrcom.DefSessionMgr.AddSig(s.Id, signer.PubKey(), sig)
+		if err != nil {
+			log.Infof("Cli Qid:%s CreateSigSession AddSig error:%s", req.Qid, err)
+			resp.ErrorCode = clisvrcom.CLIERR_SESSION
+			resp.ErrorInfo = err.Error()
+			return
+		}
+	}
+	resp.Result = &SigSessionRsp{Session: s}
+}
+
+//GetSigSession returns the session with the decoded tx, the session of other sig server is fetched by url
+func GetSigSession(req *clisvrcom.CliRpcRequest, resp *clisvrcom.CliRpcResponse) {
+	rawReq := &GetSigSessionReq{}
+	err := json.Unmarshal(req.Params, rawReq)
+	if err != nil {
+		resp.ErrorCode = clisvrcom.CLIERR_INVALID_PARAMS
+		return
+	}
+	s, ok := getSession(req.Qid, rawReq.Url, rawReq.Id, resp)
+	if !ok {
+		return
+	}
+	tx, err := s.Tx()
+	if err != nil {
+		log.Infof("Cli Qid:%s GetSigSession session:%s tx error:%s", req.Qid, s.Id, err)
+		resp.ErrorCode = clisvrcom.CLIERR_INVALID_TX
+		return
+	}
+	info, err := policy.DecodeTx(tx)
+	if err != nil {
+		log.Debugf("Cli Qid:%s GetSigSession DecodeTx error:%s", req.Qid, err)
+	}
+	resp.Result = &SigSessionRsp{Session: s, Tx: info}
+}
+
+//ListSigSession returns the sessions of this sig server
+func ListSigSession(req *clisvrcom.CliRpcRequest, resp *clisvrcom.CliRpcResponse) {
+	rawReq := &ListSigSessionReq{}
+	if len(req.Params) > 0 {
+		err := json.Unmarshal(req.Params, rawReq)
+		if err != nil {
+			resp.ErrorCode = clisvrcom.CLIERR_INVALID_PARAMS
+			return
+		}
+	}
+	resp.Result = &ListSigSessionRsp{
+		Sessions: clisvrcom.DefSessionMgr.List(rawReq.Status),
+	}
+}
+
+//SigSession signs the tx of session by account and adds the signature to the session, which is sent to
+//the sig server of url if not empty. The signature of other holder with pub_key and sig is added to local session
+func SigSession(req *clisvrcom.CliRpcRequest, resp *clisvrcom.CliRpcResponse) {
+	rawReq := &SigSessionReq{}
+	err := json.Unmarshal(req.Params, rawReq)
+	if err != nil {
+		resp.ErrorCode = clisvrcom.CLIERR_INVALID_PARAMS
+		return
+	}
+	if rawReq.Sig != "" {
+		pk, sig, err := parsePubKeySig(rawReq.PubKey, rawReq.Sig)
+		if err != nil {
+			resp.ErrorCode = clisvrcom.CLIERR_INVALID_PARAMS
+			resp.ErrorInfo = err.Error()
+			return
+		}
+		s, err := clisvrcom.DefSessionMgr.AddSig(rawReq.Id, pk, sig)
+		if err != nil {
+			log.Infof("Cli Qid:%s SigSession AddSig error:%s", req.Qid, err)
+			resp.ErrorCode = clisvrcom.CLIERR_SESSION
+			resp.ErrorInfo = err.Error()
+			return
+		}
+		resp.Result = &SigSessionRsp{Session: s}
+		return
+	}
+
+	s, ok := getSession(req.Qid, rawReq.Url, rawReq.Id, resp)
+	if !ok {
+		return
+	}
+	mutTx, err := s.Tx()
+	if err != nil {
+		log.Infof("Cli Qid:%s SigSession session:%s tx error:%s", req.Qid, s.Id, err)
+		resp.ErrorCode = clisvrcom.CLIERR_INVALID_TX
+		return
+	}
+	signer, err := req.GetSigner()
+	if err != nil {
+		log.Infof("Cli Qid:%s SigSession GetSigner:%s", req.Qid, err)
+		resp.ErrorCode = clisvrcom.CLIERR_ACCOUNT_UNLOCK
+		return
+	}
+	if !s.HasPubKey(signer.PubKey()) {
+		resp.ErrorCode = clisvrcom.CLIERR_INVALID_PARAMS
+		resp.ErrorInfo = "account is not a holder of session"
+		return
+	}
+	if !req.CheckSign(signer, mutTx, nil, resp) {
+		return
+	}
+	sig, err := signer.SignTransaction(mutTx)
+	if err != nil {
+		log.Infof("Cli Qid:%s SigSession Sign error:%s", req.Qid, err)
+		resp.ErrorCode = clisvrcom.CLIERR_INTERNAL_ERR
+		return
+	}
+	if rawReq.Url == "" {
+		s, err = clisvrcom.DefSessionMgr.AddSig(rawReq.Id, signer.PubKey(), sig)
+		if err != nil {
+			log.Infof("Cli Qid:%s SigSession AddSig error:%s", req.Qid, err)
+			resp.ErrorCode = clisvrcom.CLIERR_SESSION
+			resp.ErrorInfo = err.Error()
+			return
+		}
+		resp.Result = &SigSessionRsp{Session: s}
+		return
+	}
+	rsp := &SigSessionRsp{}
+	params := &SigSessionReq{
+		Id:     rawReq.Id,
+		PubKey: hex.EncodeToString(keypair.SerializePublicKey(signer.PubKey())),
+		Sig:    hex.EncodeToString(sig),
+	}
+	if !callSigSvr(req.Qid, rawReq.Url, "sigsession", params, rsp, resp) {
+		return
+	}
+	resp.Result = rsp
+}
+
+//RejectSigSession rejects the session by account, which is sent to the sig server of url if not empty.
+//The rejection of other holder with pub_key and sig is recorded to local session
+func RejectSigSession(req *clisvrcom.CliRpcRequest, resp *clisvrcom.CliRpcResponse) {
+	rawReq := &RejectSigSessionReq{}
+	err := json.Unmarshal(req.Params, rawReq)
+	if err != nil {
+		resp.ErrorCode = clisvrcom.CLIERR_INVALID_PARAMS
+		return
+	}
+	var pk keypair.PublicKey
+	var sig []byte
+	if rawReq.Sig != "" {
+		pk, sig, err = parsePubKeySig(rawReq.PubKey, rawReq.Sig)
+		if err != nil {
+			resp.ErrorCode = clisvrcom.CLIERR_INVALID_PARAMS
+			resp.ErrorInfo = err.Error()
+			return
+		}
+	} else {
+		signer, err := req.GetSigner()
+		if err != nil {
+			log.Infof("Cli Qid:%s RejectSigSession GetSigner:%s", req.Qid, err)
+			resp.ErrorCode = clisvrcom.CLIERR_ACCOUNT_UNLOCK
+			return
+		}
+		pk = signer.PubKey()
+		sig, err = signer.Sign(session.RejectData(rawReq.Id))
+		if err != nil {
+			log.Infof("Cli Qid:%s RejectSigSession Sign error:%s", req.Qid, err)
+			resp.ErrorCode = clisvrcom.CLIERR_INTERNAL_ERR
+			return
+		}
+		if rawReq.Url != "" {
+			rsp := &SigSessionRsp{}
+			params := &RejectSigSessionReq{
+				Id:     rawReq.Id,
+				Reason: rawReq.Reason,
+				PubKey: hex.EncodeToString(keypair.SerializePublicKey(pk)),
+				Sig:    hex.EncodeToString(sig),
+			}
+			if !callSigSvr(req.Qid, rawReq.Url, "rejectsigsession", params, rsp, resp) {
+				return
+			}
+			resp.Result = rsp
+			return
+		}
+	}
+	s, err := clisvrcom.DefSessionMgr.Reject(rawReq.Id, pk, sig, rawReq.Reason)
+	if err != nil {
+		log.Infof("Cli Qid:%s RejectSigSession error:%s", req.Qid, err)
+		resp.ErrorCode = clisvrcom.CLIERR_SESSION
+		resp.ErrorInfo = err.Error()
+		return
+	}
+	resp.Result = &SigSessionRsp{Session: s}
+}
+
+//SubmitSigSession submits the tx of complete or failed session to node again
+func SubmitSigSession(req *clisvrcom.CliRpcRequest, resp *clisvrcom.CliRpcResponse) {
+	rawReq := &SubmitSigSessionReq{}
+	err := json.Unmarshal(req.Params, rawReq)
+	if err != nil {
+		resp.ErrorCode = clisvrcom.CLIERR_INVALID_PARAMS
+		return
+	}
+	s, err := clisvrcom.DefSessionMgr.Submit(rawReq.Id)
+	if err != nil {
+		log.Infof("Cli Qid:%s SubmitSigSession error:%s", req.Qid, err)
+		resp.ErrorCode = clisvrcom.CLIERR_SESSION
+		resp.ErrorInfo = err.Error()
+		return
+	}
+	resp.Result = &SigSessionRsp{Session: s}
+}
+
+//getSession returns the session of this sig server, or of the sig server of url
+func getSession(qid, url, id string, resp *clisvrcom.CliRpcResponse) (*session.Session, bool) {
+	if url == "" {
+		s, err := clisvrcom.DefSessionMgr.Get(id)
+		if err != nil {
+			resp.ErrorCode = clisvrcom.CLIERR_SESSION
+			resp.ErrorInfo = err.Error()
+			return nil, false
+		}
+		return s, true
+	}
+	rsp := &SigSessionRsp{}
+	if !callSigSvr(qid, url, "getsigsession", &GetSigSessionReq{Id: id}, rsp, resp) {
+		return nil, false
+	}
+	if rsp.Session == nil || rsp.Session.Id != id {
+		resp.ErrorCode = clisvrcom.CLIERR_SESSION
+		resp.ErrorInfo = fmt.Sprintf("invalid session from %s", url)
+		return nil, false
+	}
+	return rsp.Session, true
+}
+
+//callSigSvr requests the method of sig server at url, and decodes the result to result.
+//Only the sig servers configured as session peers are requested
+func callSigSvr(qid, url, method string, params interface{}, result interface{}, resp *clisvrcom.CliRpcResponse) bool {
+	if !clisvrcom.IsSessionPeer(url) {
+		log.Infof("Cli Qid:%s %s refused, %s is not a session peer", qid, method, url)
+		resp.ErrorCode = clisvrcom.CLIERR_INVALID_PARAMS
+		resp.ErrorInfo = fmt.Sprintf("sig server %s is not a session peer", url)
+		return false
+	}
+	data, err := json.Marshal(params)
+	if err != nil {
+		resp.ErrorCode = clisvrcom.CLIERR_INTERNAL_ERR
+		return false
+	}
+	data, err = json.Marshal(&clisvrcom.CliRpcRequest{
+		Qid:    qid,
+		Method: method,
+		Params: data,
+	})
+	if err != nil {
+		resp.ErrorCode = clisvrcom.CLIERR_INTERNAL_ERR
+		return false
+	}
+	httpReq, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		resp.ErrorCode = clisvrcom.CLIERR_INVALID_PARAMS
+		resp.ErrorInfo = fmt.Sprintf("invalid url %s", url)
+		return false
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if clisvrcom.DefSessionApiKey != "" {
+		httpReq.Header.Set(clisvrcom.API_KEY_HEADER, clisvrcom.DefSessionApiKey)
+	}
+	httpResp, err := sessionClient.Do(httpReq)
+	if err != nil {
+		log.Infof("Cli Qid:%s %s request %s error:%s", qid, method, url, err)
+		resp.ErrorCode = clisvrcom.CLIERR_SESSION
+		resp.ErrorInfo = fmt.Sprintf("request %s error", url)
+		return false
+	}
+	defer httpResp.Body.Close()
+	body, err := ioutil.ReadAll(httpResp.Body)
+	if err != nil {
+		resp.ErrorCode = clisvrcom.CLIERR_SESSION
+		resp.ErrorInfo = fmt.Sprintf("read response of %s error", url)
+		return false
+	}
+	rsp := &struct {
+		ErrorCode int             `json:"error_code"`
+		ErrorInfo string          `json:"error_info"`
+		Result    json.RawMessage `json:"result"`
+	}{}
+	if err = json.Unmarshal(body, rsp); err == nil && rsp.ErrorCode == clisvrcom.CLIERR_OK {
+		err = json.Unmarshal(rsp.Result, result)
+	}
+	if err != nil {
+		resp.ErrorCode = clisvrcom.CLIERR_SESSION
+		resp.ErrorInfo = fmt.Sprintf("invalid response of %s", url)
+		return false
+	}
+	if rsp.ErrorCode != clisvrcom.CLIERR_OK {
+		resp.ErrorCode = rsp.ErrorCode
+		resp.ErrorInfo = fmt.Sprintf("%s: %s", url, rsp.ErrorInfo)
+		return false
+	}
+	return true
+}
+
+func parseMutableTx(rawTx string) (*types.MutableTransaction, error) {
+	rawTxData, err := hex.DecodeString(rawTx)
+	if err != nil {
+		return nil, err
+	}
+	tx, err := types.TransactionFromRawBytes(rawTxData)
+	if err != nil {
+		return nil, err
+	}
+	return tx.IntoMutable()
+}
+
+func parsePubKey(pkStr string) (keypair.PublicKey, error) {
+	pkData, err := hex.DecodeString(pkStr)
+	if err != nil {
+		return nil, err
+	}
+	return keypair.DeserializePublicKey(pkData)
+}
+
+func parsePubKeySig(pkStr, sigStr string) (keypair.PublicKey, []byte, error) {
+	pk, err := parsePubKey(pkStr)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid pub key")
+	}
+	sig, err := hex.DecodeString(sigStr)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid sig")
+	}
+	return pk, sig, nil
+}
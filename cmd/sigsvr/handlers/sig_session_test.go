@@ -0,0 +1,164 @@
+/*
+ * Copyright (C) 2018 The cntmology Authors
+ * This file is part of The cntmology library.
+ *
+ * The cntmology is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The cntmology is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * alcntm with The cntmology.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package handlers
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cntmio/cntmology-crypto/keypair"
+	"github.com/cntmio/cntmology-crypto/signature"
+	clisvrcom "github.com/cntmio/cntmology/cmd/sigsvr/common"
+	"github.com/cntmio/cntmology/cmd/sigsvr/session"
+	"github.com/cntmio/cntmology/cmd/utils"
+	"github.com/cntmio/cntmology/common"
+	"github.com/cntmio/cntmology/core/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSigSession(t *testing.T) {
+	var err error
+	clisvrcom.DefSessionMgr, err = session.NewManager("", nil)
+	assert.Nil(t, err)
+
+	acc1, err := clisvrcom.DefWalletStore.NewAccountData(keypair.PK_ECDSA, keypair.P256, signature.SHA256withECDSA, pwd)
+	assert.Nil(t, err)
+	clisvrcom.DefWalletStore.AddAccountData(acc1)
+	acc2, err := clisvrcom.DefWalletStore.NewAccountData(keypair.PK_ECDSA, keypair.P256, signature.SHA256withECDSA, pwd)
+	assert.Nil(t, err)
+	clisvrcom.DefWalletStore.AddAccountData(acc2)
+	acc3, err := clisvrcom.DefWalletStore.NewAccountData(keypair.PK_ECDSA, keypair.P256, signature.SHA256withECDSA, pwd)
+	assert.Nil(t, err)
+	clisvrcom.DefWalletStore.AddAccountData(acc3)
+
+	pubKeys := make([]keypair.PublicKey, 0, 3)
+	for _, pkStr := range []string{acc1.PubKey, acc2.PubKey, acc3.PubKey} {
+		pk, err := parsePubKey(pkStr)
+		assert.Nil(t, err)
+		pubKeys = append(pubKeys, pk)
+	}
+	fromAddr, err := types.AddressFromMultiPubKeys(pubKeys, 2)
+	assert.Nil(t, err)
+	tx, err := utils.TransferTx(0, 0, "cntm", fromAddr.ToBase58(), acc1.Address, 10)
+	assert.Nil(t, err)
+	immut, err := tx.IntoImmutable()
+	assert.Nil(t, err)
+	sink := common.ZeroCopySink{}
+	immut.Serialization(&sink)
+
+	data, err := json.Marshal(&CreateSigSessionReq{
+		RawTx:   hex.EncodeToString(sink.Bytes()),
+		M:       2,
+		PubKeys: []string{acc1.PubKey, acc2.PubKey, acc3.PubKey},
+		Sign:    true,
+	})
+	assert.Nil(t, err)
+	req := &clisvrcom.CliRpcRequest{
+		Qid:     "t",
+		Method:  "createsigsession",
+		Params:  data,
+		Account: acc1.Address,
+		Pwd:     string(pwd),
+	}
+	resp := &clisvrcom.CliRpcResponse{}
+	CreateSigSession(req, resp)
+	if resp.ErrorCode != clisvrcom.CLIERR_OK {
+		t.Errorf("CreateSigSession failed,ErrorCode:%d ErrorString:%s", resp.ErrorCode, resp.ErrorInfo)
+		return
+	}
+	s := resp.Result.(*SigSessionRsp).Session
+	assert.Equal(t, session.STATUS_PENDING, s.Status)
+	assert.Equal(t, 1, len(s.Sigs))
+
+	//the coordinator sig server
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		req := &clisvrcom.CliRpcRequest{}
+		resp := &clisvrcom.CliRpcResponse{}
+		if err := json.Unmarshal(body, req); err != nil {
+			resp.ErrorCode = clisvrcom.CLIERR_INVALID_REQUEST
+		}
+		switch req.Method {
+		case "getsigsession":
+			GetSigSession(req, resp)
+		case "sigsession":
+			SigSession(req, resp)
+		case "rejectsigsession":
+			RejectSigSession(req, resp)
+		default:
+			resp.ErrorCode = clisvrcom.CLIERR_UNSUPPORT_METHOD
+		}
+		data, _ := json.Marshal(resp)
+		w.Write(data)
+	}))
+	defer svr.Close()
+
+	//the sig server not configured as session peer is never requested
+	data, err = json.Marshal(&GetSigSessionReq{Url: svr.URL, Id: s.Id})
+	assert.Nil(t, err)
+	req = &clisvrcom.CliRpcRequest{Qid: "t", Method: "getsigsession", Params: data}
+	resp = &clisvrcom.CliRpcResponse{}
+	GetSigSession(req, resp)
+	assert.Equal(t, clisvrcom.CLIERR_INVALID_PARAMS, resp.ErrorCode)
+
+	clisvrcom.DefSessionPeers = []string{svr.URL + "/"}
+	defer func() { clisvrcom.DefSessionPeers = nil }()
+	resp = &clisvrcom.CliRpcResponse{}
+	GetSigSession(req, resp)
+	if resp.ErrorCode != clisvrcom.CLIERR_OK {
+		t.Errorf("GetSigSession failed,ErrorCode:%d ErrorString:%s", resp.ErrorCode, resp.ErrorInfo)
+		return
+	}
+	rsp := resp.Result.(*SigSessionRsp)
+	assert.Equal(t, s.TxHash, rsp.Session.TxHash)
+	assert.Equal(t, "transfer", rsp.Tx.Method)
+
+	data, err = json.Marshal(&RejectSigSessionReq{Url: svr.URL, Id: s.Id, Reason: "wrong amount"})
+	assert.Nil(t, err)
+	req = &clisvrcom.CliRpcRequest{Qid: "t", Method: "rejectsigsession", Params: data, Account: acc3.Address, Pwd: string(pwd)}
+	resp = &clisvrcom.CliRpcResponse{}
+	RejectSigSession(req, resp)
+	if resp.ErrorCode != clisvrcom.CLIERR_OK {
+		t.Errorf("RejectSigSession failed,ErrorCode:%d ErrorString:%s", resp.ErrorCode, resp.ErrorInfo)
+		return
+	}
+	assert.Equal(t, 1, len(resp.Result.(*SigSessionRsp).Session.Rejections))
+
+	data, err = json.Marshal(&SigSessionReq{Url: svr.URL, Id: s.Id})
+	assert.Nil(t, err)
+	req = &clisvrcom.CliRpcRequest{Qid: "t", Method: "sigsession", Params: data, Account: acc2.Address, Pwd: string(pwd)}
+	resp = &clisvrcom.CliRpcResponse{}
+	SigSession(req, resp)
+	if resp.ErrorCode != clisvrcom.CLIERR_OK {
+		t.Errorf("SigSession failed,ErrorCode:%d ErrorString:%s", resp.ErrorCode, resp.ErrorInfo)
+		return
+	}
+	s = resp.Result.(*SigSessionRsp).Session
+	assert.Equal(t, session.STATUS_COMPLETE, s.Status)
+	assert.NotEqual(t, "", s.SignedTx)
+
+	req.Account = acc3.Address
+	resp = &clisvrcom.CliRpcResponse{}
+	SigSession(req, resp)
+	assert.Equal(t, clisvrcom.CLIERR_SESSION, resp.ErrorCode)
+}
@@ -77,9 +77,9 @@ func SigTransferTransaction(req *clisvrcom.CliRpcRequest, resp *clisvrcom.CliRpc
 		mutable.Payer = payerAddress
 	}
 
-	signer, err := req.GetAccount()
+	signer, err := req.GetSigner()
 	if err != nil {
-		log.Infof("Cli Qid:%s SigTransferTransaction GetAccount:%s", req.Qid, err)
+		log.Infof("Cli Qid:%s SigTransferTransaction GetSigner:%s", req.Qid, err)
 		resp.ErrorCode = clisvrcom.CLIERR_ACCOUNT_UNLOCK
 		return
 	}
@@ -87,7 +87,10 @@ func SigTransferTransaction(req *clisvrcom.CliRpcRequest, resp *clisvrcom.CliRpc
 		resp.ErrorCode = clisvrcom.CLIERR_ACCOUNT_UNLOCK
 		return
 	}
-	err = cliutil.SignTransaction(signer, mutable)
+	if !req.CheckSign(signer, mutable, nil, resp) {
+		return
+	}
+	err = cliutil.SignTransactionBySigner(signer, mutable)
 	if err != nil {
 		log.Infof("Cli Qid:%s SigTransferTransaction SignTransaction error:%s", req.Qid, err)
 		resp.ErrorCode = clisvrcom.CLIERR_INTERNAL_ERR
@@ -135,9 +138,9 @@ func SigTransferTransactionV2(req *clisvrcom.CliRpcRequest, resp *clisvrcom.CliR
 		mutable.Payer = payerAddress
 	}
 
-	signer, err := req.GetAccount()
+	signer, err := req.GetSigner()
 	if err != nil {
-		log.Infof("Cli Qid:%s SigTransferTransaction GetAccount:%s", req.Qid, err)
+		log.Infof("Cli Qid:%s SigTransferTransaction GetSigner:%s", req.Qid, err)
 		resp.ErrorCode = clisvrcom.CLIERR_ACCOUNT_UNLOCK
 		return
 	}
@@ -145,7 +148,10 @@ func SigTransferTransactionV2(req *clisvrcom.CliRpcRequest, resp *clisvrcom.CliR
 		resp.ErrorCode = clisvrcom.CLIERR_ACCOUNT_UNLOCK
 		return
 	}
-	err = cliutil.SignTransaction(signer, mutable)
+	if !req.CheckSign(signer, mutable, nil, resp) {
+		return
+	}
+	err = cliutil.SignTransactionBySigner(signer, mutable)
 	if err != nil {
 		log.Infof("Cli Qid:%s SigTransferTransaction SignTransaction error:%s", req.Qid, err)
 		resp.ErrorCode = clisvrcom.CLIERR_INTERNAL_ERR
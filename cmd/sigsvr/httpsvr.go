@@ -19,11 +19,16 @@
 package sigsvr
 
 import (
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"time"
 
+	"github.com/cntmio/cntmology/cmd/sigsvr/audit"
 	"github.com/cntmio/cntmology/cmd/sigsvr/common"
 	"github.com/cntmio/cntmology/common/log"
 )
@@ -36,6 +41,9 @@ type CliRpcServer struct {
 	handlers   map[string]func(req *common.CliRpcRequest, resp *common.CliRpcResponse)
 	httpSvr    *http.Server
 	httpSvtMux *http.ServeMux
+	apiKeys    map[string][]byte //client name => sha256 of api key
+	tlsConfig  *tls.Config
+	auditLog   *audit.Log
 }
 
 func NewCliRpcServer() *CliRpcServer {
@@ -53,7 +61,13 @@ func (this *CliRpcServer) Start(address string, port uint) {
 		Handler: this.httpSvtMux,
 	}
 	this.httpSvtMux.HandleFunc("/cli", this.Handler)
-	err := this.httpSvr.ListenAndServe()
+	var err error
+	if this.tlsConfig != nil {
+		this.httpSvr.TLSConfig = this.tlsConfig
+		err = this.httpSvr.ListenAndServeTLS("", "")
+	} else {
+		err = this.httpSvr.ListenAndServe()
+	}
 	if err != nil {
 		if err == http.ErrServerClosed {
 			return
@@ -62,6 +76,21 @@ func (this *CliRpcServer) Start(address string, port uint) {
 	}
 }
 
+//SetApiKeys requires the clients to authenticate by api key
+func (this *CliRpcServer) SetApiKeys(apiKeys map[string][]byte) {
+	this.apiKeys = apiKeys
+}
+
+//SetTLSConfig serves over tls, the clients are authenticated by certificate if config requires
+func (this *CliRpcServer) SetTLSConfig(config *tls.Config) {
+	this.tlsConfig = config
+}
+
+//SetAuditLog records every request to the audit log. No result is returned if it cannot be recorded
+func (this *CliRpcServer) SetAuditLog(auditLog *audit.Log) {
+	this.auditLog = auditLog
+}
+
 func (this *CliRpcServer) RegHandler(method string, handler func(req *common.CliRpcRequest, resp *common.CliRpcResponse)) {
 	this.handlers[method] = handler
 }
@@ -76,15 +105,22 @@ func (this *CliRpcServer) GetHandler(method string) func(req *common.CliRpcReque
 
 func (this *CliRpcServer) Handler(w http.ResponseWriter, r *http.Request) {
 	resp := &common.CliRpcResponse{}
+	req := &common.CliRpcRequest{}
 	defer func() {
+		if resp.ErrorInfo == "" {
+			resp.ErrorInfo = common.GetCLIErrorDesc(resp.ErrorCode)
+		}
+		if err := this.audit(r, req, resp); err != nil {
+			log.Errorf("CliRpcServer audit Qid:%s error:%s", req.Qid, err)
+			resp.Result = nil
+			resp.ErrorCode = common.CLIERR_INTERNAL_ERR
+			resp.ErrorInfo = "audit log error"
+		}
 		w.Header().Add("Access-Ccntmrol-Allow-Headers", "Ccntment-Type")
 		w.Header().Set("ccntment-type", "application/json;charset=utf-8")
 		w.Header().Set("Access-Ccntmrol-Allow-Origin", "*")
 		w.WriteHeader(http.StatusOK)
 
-		if resp.ErrorInfo == "" {
-			resp.ErrorInfo = common.GetCLIErrorDesc(resp.ErrorCode)
-		}
 		data, err := json.Marshal(resp)
 		if err != nil {
 			log.Error("CliRpcServer json.Marshal JsonRpcResponse:%+v error:%s", resp, err)
@@ -111,13 +147,18 @@ func (this *CliRpcServer) Handler(w http.ResponseWriter, r *http.Request) {
 	}
 	defer r.Body.Close()
 
-	req := &common.CliRpcRequest{}
 	err = json.Unmarshal(data, req)
 	if err != nil {
 		log.Errorf("CliRpcServer json.Unmarshal JsonRpcRequest error:%s", err)
 		resp.ErrorCode = common.CLIERR_INVALID_PARAMS
 		return
 	}
+	req.Client, err = this.authenticate(r)
+	if err != nil {
+		log.Infof("CliRpcServer Qid:%s from:%s authenticate error:%s", req.Qid, r.RemoteAddr, err)
+		resp.ErrorCode = common.CLIERR_UNAUTHORIZED
+		return
+	}
 
 	pwd := req.Pwd
 	req.Pwd = "*"
@@ -133,10 +174,43 @@ func (this *CliRpcServer) Handler(w http.ResponseWriter, r *http.Request) {
 		resp.ErrorCode = common.CLIERR_UNSUPPORT_METHOD
 		return
 	}
+	if common.DefPolicy != nil {
+		if err = common.DefPolicy.CheckMethod(req.Method); err != nil {
+			resp.ErrorCode = common.CLIERR_POLICY_DENIED
+			resp.ErrorInfo = err.Error()
+			return
+		}
+	}
 
 	handler(req, resp)
 }
 
+func (this *CliRpcServer) audit(r *http.Request, req *common.CliRpcRequest, resp *common.CliRpcResponse) error {
+	if this.auditLog == nil {
+		return nil
+	}
+	entry := &audit.Entry{
+		Time:      time.Now().Unix(),
+		Remote:    r.RemoteAddr,
+		Client:    req.Client,
+		Qid:       req.Qid,
+		Method:    req.Method,
+		Account:   req.Signing,
+		DryRun:    req.DryRun,
+		Tx:        req.TxInfo,
+		ErrorCode: resp.ErrorCode,
+		ErrorInfo: resp.ErrorInfo,
+	}
+	if entry.Account == "" {
+		entry.Account = req.Account
+	}
+	if len(req.Params) > 0 {
+		hash := sha256.Sum256(req.Params)
+		entry.Params = hex.EncodeToString(hash[:])
+	}
+	return this.auditLog.Append(entry)
+}
+
 func (this *CliRpcServer) Close() {
 	err := this.httpSvr.Close()
 	if err != nil {
@@ -0,0 +1,256 @@
+/*
+ * Copyright (C) 2018 The cntmology Authors
+ * This file is part of The cntmology library.
+ *
+ * The cntmology is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The cntmology is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * alcntm with The cntmology.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+//Package policy restricts what the sig server may sign
+package policy
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/cntmio/cntmology/common"
+)
+
+const (
+	ANY_METHOD = "*"
+	DAY_FORMAT = "2006-01-02"
+)
+
+//Policy is loaded from json file. A sig server with policy only signs what is allowed explicitly
+type Policy struct {
+	Methods  []string         `json:"methods"`  //allowed rpc methods, empty for all
+	Accounts []*AccountPolicy `json:"accounts"` //accounts allowed to sign
+
+	stateFile string
+	lock      sync.Mutex
+	state     *limitState
+}
+
+type AccountPolicy struct {
+	Address     string             `json:"address"`      //base58 address of the account
+	Clients     []string           `json:"clients"`      //authenticated clients allowed to use the account, empty for all
+	RawData     bool               `json:"raw_data"`     //allow sigdata, whose data cannot be decoded
+	Ccntmracts  []*CcntmractPolicy `json:"ccntmracts"`   //ccntmracts and methods allowed to invoke
+	DailyLimits map[string]uint64  `json:"daily_limits"` //hex ccntmract address of native token => max value per day
+}
+
+type CcntmractPolicy struct {
+	Address string   `json:"address"` //hex ccntmract address
+	Methods []string `json:"methods"` //allowed methods, "*" for all
+}
+
+//limitState records the value spent of the day, persisted so that restart does not reset it
+type limitState struct {
+	Day   string                         `json:"day"`
+	Spent map[string]map[string]*big.Int `json:"spent"` //account => ccntmract => value
+}
+
+//LoadPolicy loads policy from file, the spent value of the day is kept in file.state
+func LoadPolicy(file string) (*Policy, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	policy := &Policy{}
+	if err = json.Unmarshal(data, policy); err != nil {
+		return nil, fmt.Errorf("invalid policy file %s: %s", file, err)
+	}
+	for _, acc := range policy.Accounts {
+		if _, err = common.AddressFromBase58(acc.Address); err != nil {
+			return nil, fmt.Errorf("invalid account address %s: %s", acc.Address, err)
+		}
+		for _, ct := range acc.Ccntmracts {
+			if ct.Address, err = normalizeAddress(ct.Address); err != nil {
+				return nil, fmt.Errorf("invalid ccntmract address %s: %s", ct.Address, err)
+			}
+		}
+		limits := make(map[string]uint64, len(acc.DailyLimits))
+		for addr, limit := range acc.DailyLimits {
+			hexAddr, err := normalizeAddress(addr)
+			if err != nil {
+				return nil, fmt.Errorf("invalid ccntmract address %s of daily limit: %s", addr, err)
+			}
+			limits[hexAddr] = limit
+		}
+		acc.DailyLimits = limits
+	}
+	policy.stateFile = file + ".state"
+	policy.state = &limitState{Spent: make(map[string]map[string]*big.Int)}
+	data, err = ioutil.ReadFile(policy.stateFile)
+	if err == nil {
+		if err = json.Unmarshal(data, policy.state); err != nil {
+			return nil, fmt.Errorf("invalid policy state file %s: %s", policy.stateFile, err)
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+	return policy, nil
+}
+
+//CheckMethod checks whether the rpc method is allowed
+func (this *Policy) CheckMethod(method string) error {
+	if len(this.Methods) == 0 || contains(this.Methods, method) {
+		return nil
+	}
+	return fmt.Errorf("method %s is not allowed", method)
+}
+
+//Check checks whether the client may sign info by account. The value transferred is
+//counted to the daily limit unless dryRun
+func (this *Policy) Check(client, method, account string, info *TxInfo, dryRun bool) error {
+	if err := this.CheckMethod(method); err != nil {
+		return err
+	}
+	accPolicy := this.getAccountPolicy(account)
+	if accPolicy == nil {
+		return fmt.Errorf("account %s is not allowed", account)
+	}
+	if len(accPolicy.Clients) > 0 && !contains(accPolicy.Clients, client) {
+		return fmt.Errorf("client %s is not allowed to use account %s", client, account)
+	}
+	if info.RawData != "" {
+		if !accPolicy.RawData {
+			return fmt.Errorf("account %s is not allowed to sign raw data", account)
+		}
+		return nil
+	}
+	if !accPolicy.allowInvoke(info.Ccntmract, info.Method) {
+		return fmt.Errorf("account %s is not allowed to invoke %s of ccntmract %s", account, info.Method, info.Ccntmract)
+	}
+	return this.checkLimits(accPolicy, account, info, dryRun)
+}
+
+func (this *Policy) getAccountPolicy(account string) *AccountPolicy {
+	for _, acc := range this.Accounts {
+		if acc.Address == account {
+			return acc
+		}
+	}
+	return nil
+}
+
+func (this *AccountPolicy) allowInvoke(ccntmract, method string) bool {
+	if ccntmract == "" {
+		return false
+	}
+	for _, ct := range this.Ccntmracts {
+		if ct.Address == ccntmract && (contains(ct.Methods, ANY_METHOD) || contains(ct.Methods, method)) {
+			return true
+		}
+	}
+	return false
+}
+
+func (this *Policy) checkLimits(accPolicy *AccountPolicy, account string, info *TxInfo, dryRun bool) error {
+	amounts := make(map[string]*big.Int)
+	for _, transfer := range info.Transfers {
+		if transfer.From != account && info.Method != METHOD_TRANSFER_FROM && info.Method != METHOD_TRANSFER_FROM_V2 {
+			ccntminue
+		}
+		if _, ok := amounts[transfer.Ccntmract]; !ok {
+			amounts[transfer.Ccntmract] = new(big.Int)
+		}
+		amounts[transfer.Ccntmract].Add(amounts[transfer.Ccntmract], transfer.Value)
+	}
+	if len(amounts) == 0 {
+		return nil
+	}
+
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	today := time.Now().UTC().Format(DAY_FORMAT)
+	if this.state.Day != today {
+		this.state = &limitState{Day: today, Spent: make(map[string]map[string]*big.Int)}
+	}
+	spent := this.state.Spent[account]
+	for ccntmract, amount := range amounts {
+		limit, ok := accPolicy.DailyLimits[ccntmract]
+		if !ok {
+			ccntminue
+		}
+		total := new(big.Int).Add(amount, spentOf(spent, ccntmract))
+		if total.Cmp(new(big.Int).SetUint64(limit)) > 0 {
+			return fmt.Errorf("daily limit %d of ccntmract %s exceeded by account %s, spent %s", limit, ccntmract, account, spentOf(spent, ccntmract))
+		}
+	}
+	if dryRun {
+		return nil
+	}
+
+	if spent == nil {
+		spent = make(map[string]*big.Int)
+		this.state.Spent[account] = spent
+	}
+	old := make(map[string]*big.Int)
+	for ccntmract, amount := range amounts {
+		old[ccntmract] = spent[ccntmract]
+		spent[ccntmract] = new(big.Int).Add(amount, spentOf(spent, ccntmract))
+	}
+	if err := this.saveState(); err != nil {
+		for ccntmract, value := range old {
+			if value == nil {
+				delete(spent, ccntmract)
+			} else {
+				spent[ccntmract] = value
+			}
+		}
+		return fmt.Errorf("save policy state error: %s", err)
+	}
+	return nil
+}
+
+func (this *Policy) saveState() error {
+	data, err := json.Marshal(this.state)
+	if err != nil {
+		return err
+	}
+	tmp := this.stateFile + "~"
+	if err = ioutil.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, this.stateFile)
+}
+
+func normalizeAddress(hexAddr string) (string, error) {
+	addr, err := common.AddressFromHexString(hexAddr)
+	if err != nil {
+		return hexAddr, err
+	}
+	return addr.ToHexString(), nil
+}
+
+func spentOf(spent map[string]*big.Int, ccntmract string) *big.Int {
+	if value, ok := spent[ccntmract]; ok && value != nil {
+		return value
+	}
+	return new(big.Int)
+}
+
+func contains(list []string, item string) bool {
+	for _, v := range list {
+		if v == item {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,105 @@
+/*
+ * Copyright (C) 2018 The cntmology Authors
+ * This file is part of The cntmology library.
+ *
+ * The cntmology is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The cntmology is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * alcntm with The cntmology.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package policy
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cntmio/cntmology/account"
+	cliutil "github.com/cntmio/cntmology/cmd/utils"
+	"github.com/cntmio/cntmology/smartccntmract/service/native/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestPolicy(t *testing.T, dir, data string) *Policy {
+	file := filepath.Join(dir, "policy.json")
+	assert.Nil(t, ioutil.WriteFile(file, []byte(data), 0600))
+	policy, err := LoadPolicy(file)
+	assert.Nil(t, err)
+	return policy
+}
+
+func TestDecodeTransferTx(t *testing.T) {
+	from := account.NewAccount("")
+	to := account.NewAccount("")
+	tx, err := cliutil.TransferTx(500, 20000, "cntm", from.Address.ToBase58(), to.Address.ToBase58(), 10)
+	assert.Nil(t, err)
+
+	info, err := DecodeTx(tx)
+	assert.Nil(t, err)
+	assert.Equal(t, VM_NATIVE, info.VmType)
+	assert.Equal(t, utils.OntCcntmractAddress.ToHexString(), info.Ccntmract)
+	assert.Equal(t, METHOD_TRANSFER, info.Method)
+	assert.Equal(t, uint64(500), info.GasPrice)
+	assert.Equal(t, 1, len(info.Transfers))
+	assert.Equal(t, from.Address.ToBase58(), info.Transfers[0].From)
+	assert.Equal(t, to.Address.ToBase58(), info.Transfers[0].To)
+	assert.Equal(t, int64(10), info.Transfers[0].Value.Int64())
+}
+
+func TestPolicyCheck(t *testing.T) {
+	dir, err := ioutil.TempDir("", "policy")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	from := account.NewAccount("")
+	to := account.NewAccount("")
+	cntm := utils.OntCcntmractAddress.ToHexString()
+	policy := newTestPolicy(t, dir, `{
+		"methods": ["sigtransfertx", "sigdata"],
+		"accounts": [{
+			"address": "`+from.Address.ToBase58()+`",
+			"clients": ["wallet"],
+			"ccntmracts": [{"address": "`+cntm+`", "methods": ["transfer"]}],
+			"daily_limits": {"`+cntm+`": 15}
+		}]
+	}`)
+	assert.NotNil(t, policy.CheckMethod("sigrawtx"))
+
+	tx, err := cliutil.TransferTx(500, 20000, "cntm", from.Address.ToBase58(), to.Address.ToBase58(), 10)
+	assert.Nil(t, err)
+	info, err := DecodeTx(tx)
+	assert.Nil(t, err)
+
+	assert.NotNil(t, policy.Check("other", "sigtransfertx", from.Address.ToBase58(), info, false))
+	assert.NotNil(t, policy.Check("wallet", "sigtransfertx", to.Address.ToBase58(), info, false))
+	assert.NotNil(t, policy.Check("wallet", "sigdata", from.Address.ToBase58(), &TxInfo{RawData: "00"}, false))
+
+	//dry run does not count to the daily limit
+	assert.Nil(t, policy.Check("wallet", "sigtransfertx", from.Address.ToBase58(), info, true))
+	assert.Nil(t, policy.Check("wallet", "sigtransfertx", from.Address.ToBase58(), info, true))
+	assert.Nil(t, policy.Check("wallet", "sigtransfertx", from.Address.ToBase58(), info, false))
+	assert.NotNil(t, policy.Check("wallet", "sigtransfertx", from.Address.ToBase58(), info, true))
+	assert.NotNil(t, policy.Check("wallet", "sigtransfertx", from.Address.ToBase58(), info, false))
+
+	//spent value survives restart
+	policy, err = LoadPolicy(filepath.Join(dir, "policy.json"))
+	assert.Nil(t, err)
+	assert.NotNil(t, policy.Check("wallet", "sigtransfertx", from.Address.ToBase58(), info, false))
+
+	approve, err := cliutil.ApproveTx(500, 20000, "cntm", from.Address.ToBase58(), to.Address.ToBase58(), 6)
+	assert.Nil(t, err)
+	info, err = DecodeTx(approve)
+	assert.Nil(t, err)
+	assert.Equal(t, METHOD_APPROVE, info.Method)
+	assert.NotNil(t, policy.Check("wallet", "sigtransfertx", from.Address.ToBase58(), info, false))
+}
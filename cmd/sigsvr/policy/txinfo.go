@@ -0,0 +1,411 @@
+/*
+ * Copyright (C) 2018 The cntmology Authors
+ * This file is part of The cntmology library.
+ *
+ * The cntmology is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The cntmology is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * alcntm with The cntmology.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package policy
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+
+	"github.com/cntmio/cntmology/common"
+	"github.com/cntmio/cntmology/core/payload"
+	"github.com/cntmio/cntmology/core/types"
+	cutils "github.com/cntmio/cntmology/core/utils"
+	"github.com/cntmio/cntmology/smartccntmract/service/native/utils"
+	"github.com/cntmio/cntmology/vm/neovm"
+	neotypes "github.com/cntmio/cntmology/vm/neovm/types"
+)
+
+const (
+	VM_NATIVE = "native"
+	VM_NEOVM  = "neovm"
+	VM_WASMVM = "wasmvm"
+)
+
+//native token methods which move value out of the signer
+const (
+	METHOD_TRANSFER         = "transfer"
+	METHOD_TRANSFER_V2      = "transferV2"
+	METHOD_TRANSFER_FROM    = "transferFrom"
+	METHOD_TRANSFER_FROM_V2 = "transferFromV2"
+	METHOD_APPROVE          = "approve"
+	METHOD_APPROVE_V2       = "approveV2"
+)
+
+//V2_SCALE is the precision raised by the V2 methods of native token
+var V2_SCALE = big.NewInt(1000000000)
+
+//TxInfo is the decoded transaction for policy check and review
+type TxInfo struct {
+	Payer     string        `json:"payer,omitempty"`
+	GasPrice  uint64        `json:"gas_price"`
+	GasLimit  uint64        `json:"gas_limit"`
+	VmType    string        `json:"vm_type,omitempty"`
+	Ccntmract string        `json:"ccntmract,omitempty"`
+	Method    string        `json:"method,omitempty"`
+	Params    []interface{} `json:"params,omitempty"`
+	Transfers []*Transfer   `json:"transfers,omitempty"`
+	RawData   string        `json:"raw_data,omitempty"`
+}
+
+//Transfer is value moved by native token methods, Value is in the unit of V1 methods
+type Transfer struct {
+	Ccntmract string   `json:"ccntmract"`
+	From      string   `json:"from"`
+	To        string   `json:"to"`
+	Value     *big.Int `json:"value"`
+}
+
+//vmArray is the array or struct of neovm, which is a reference type
+type vmArray struct {
+	items []interface{}
+}
+
+//DecodeTx decodes the invoked ccntmract, method and params of tx. The returned
+//TxInfo is never nil, the header of tx is set even if the payload cannot be decoded
+func DecodeTx(tx *types.MutableTransaction) (*TxInfo, error) {
+	info := &TxInfo{
+		GasPrice: tx.GasPrice,
+		GasLimit: tx.GasLimit,
+	}
+	if tx.Payer != common.ADDRESS_EMPTY {
+		info.Payer = tx.Payer.ToBase58()
+	}
+	invoke, ok := tx.Payload.(*payload.InvokeCode)
+	if !ok {
+		return info, fmt.Errorf("unsupported tx type %d", tx.TxType)
+	}
+	var err error
+	switch tx.TxType {
+	case types.InvokeNeo:
+		err = info.decodeNeoVM(invoke.Code)
+	case types.InvokeWasm:
+		err = info.decodeWasmVM(invoke.Code)
+	default:
+		err = fmt.Errorf("unsupported tx type %d", tx.TxType)
+	}
+	return info, err
+}
+
+func (this *TxInfo) decodeWasmVM(code []byte) error {
+	source := common.NewZeroCopySource(code)
+	addr, eof := source.NextAddress()
+	if eof {
+		return fmt.Errorf("invalid wasm invoke code")
+	}
+	args, _, irregular, eof := source.NextVarBytes()
+	if irregular || eof {
+		return fmt.Errorf("invalid wasm invoke code")
+	}
+	method, _, irregular, eof := common.NewZeroCopySource(args).NextString()
+	if irregular || eof {
+		return fmt.Errorf("invalid wasm invoke method")
+	}
+	this.VmType = VM_WASMVM
+	this.Ccntmract = addr.ToHexString()
+	this.Method = method
+	this.Params = []interface{}{hex.EncodeToString(args)}
+	return nil
+}
+
+//decodeNeoVM runs the param building instructions emitted by the sdk, and
+//stops at the invocation of ccntmract
+func (this *TxInfo) decodeNeoVM(code []byte) error {
+	var stack, alt []interface{}
+	pop := func(s *[]interface{}) (interface{}, error) {
+		if len(*s) == 0 {
+			return nil, fmt.Errorf("stack underflow")
+		}
+		item := (*s)[len(*s)-1]
+		*s = (*s)[:len(*s)-1]
+		return item, nil
+	}
+	popInt := func() (int, error) {
+		item, err := pop(&stack)
+		if err != nil {
+			return 0, err
+		}
+		n, err := toInt(item)
+		if err != nil || !n.IsInt64() || n.Int64() < 0 || n.Int64() > int64(len(code)) {
+			return 0, fmt.Errorf("invalid count")
+		}
+		return int(n.Int64()), nil
+	}
+	for pc := 0; pc < len(code); {
+		op := neovm.OpCode(code[pc])
+		pc++
+		switch {
+		case op == neovm.PUSH0:
+			stack = append(stack, []byte{})
+		case op >= neovm.PUSHBYTES1 && op <= neovm.PUSHDATA4:
+			data, next, err := readPushData(code, pc, op)
+			if err != nil {
+				return err
+			}
+			pc = next
+			stack = append(stack, data)
+		case op == neovm.PUSHM1:
+			stack = append(stack, big.NewInt(-1))
+		case op >= neovm.PUSH1 && op <= neovm.PUSH16:
+			stack = append(stack, big.NewInt(int64(op-neovm.PUSH1+1)))
+		case op == neovm.NEWSTRUCT:
+			n, err := popInt()
+			if err != nil {
+				return err
+			}
+			stack = append(stack, &vmArray{items: make([]interface{}, n)})
+		case op == neovm.PACK:
+			n, err := popInt()
+			if err != nil {
+				return err
+			}
+			arr := &vmArray{items: make([]interface{}, n)}
+			for i := 0; i < n; i++ {
+				if arr.items[i], err = pop(&stack); err != nil {
+					return err
+				}
+			}
+			stack = append(stack, arr)
+		case op == neovm.TOALTSTACK:
+			item, err := pop(&stack)
+			if err != nil {
+				return err
+			}
+			alt = append(alt, item)
+		case op == neovm.FROMALTSTACK:
+			item, err := pop(&alt)
+			if err != nil {
+				return err
+			}
+			stack = append(stack, item)
+		case op == neovm.DUPFROMALTSTACK:
+			if len(alt) == 0 {
+				return fmt.Errorf("stack underflow")
+			}
+			stack = append(stack, alt[len(alt)-1])
+		case op == neovm.SWAP:
+			if len(stack) < 2 {
+				return fmt.Errorf("stack underflow")
+			}
+			stack[len(stack)-1], stack[len(stack)-2] = stack[len(stack)-2], stack[len(stack)-1]
+		case op == neovm.APPEND:
+			item, err := pop(&stack)
+			if err != nil {
+				return err
+			}
+			target, err := pop(&stack)
+			if err != nil {
+				return err
+			}
+			arr, ok := target.(*vmArray)
+			if !ok {
+				return fmt.Errorf("append to non array")
+			}
+			arr.items = append(arr.items, item)
+		case op == neovm.SYSCALL:
+			if pc >= len(code) {
+				return fmt.Errorf("invalid syscall")
+			}
+			name, next, err := readPushData(code, pc+1, neovm.OpCode(code[pc]))
+			if err != nil || string(name) != cutils.NATIVE_INVOKE_NAME {
+				return fmt.Errorf("unsupported syscall")
+			}
+			if next != len(code) {
+				return fmt.Errorf("unexpected code after native invoke")
+			}
+			if _, err = pop(&stack); err != nil { //version
+				return err
+			}
+			return this.setInvoke(VM_NATIVE, &stack, pop)
+		case op == neovm.APPCALL:
+			if pc+common.ADDR_LEN != len(code) {
+				return fmt.Errorf("unexpected code after appcall")
+			}
+			stack = append(stack, code[pc:])
+			return this.setInvoke(VM_NEOVM, &stack, pop)
+		default:
+			return fmt.Errorf("unsupported opcode 0x%x", byte(op))
+		}
+	}
+	return fmt.Errorf("no ccntmract invoked")
+}
+
+//setInvoke pops ccntmract address, method and params from the stack
+func (this *TxInfo) setInvoke(vmType string, stack *[]interface{}, pop func(s *[]interface{}) (interface{}, error)) error {
+	addrItem, err := pop(stack)
+	if err != nil {
+		return err
+	}
+	methodItem, err := pop(stack)
+	if err != nil {
+		return err
+	}
+	addrData, ok := addrItem.([]byte)
+	if !ok {
+		return fmt.Errorf("invalid ccntmract address")
+	}
+	addr, err := common.AddressParseFromBytes(addrData)
+	if err != nil {
+		return fmt.Errorf("invalid ccntmract address: %s", err)
+	}
+	method, ok := methodItem.([]byte)
+	if !ok {
+		return fmt.Errorf("invalid method")
+	}
+	this.VmType = vmType
+	this.Ccntmract = addr.ToHexString()
+	this.Method = string(method)
+	var params interface{} = &vmArray{}
+	if len(*stack) > 0 {
+		params, _ = pop(stack)
+	}
+	if arr, ok := params.(*vmArray); ok {
+		this.Params = arr.toJson()
+	} else {
+		this.Params = []interface{}{toJson(params)}
+	}
+	if vmType == VM_NATIVE && (addr == utils.OntCcntmractAddress || addr == utils.OngCcntmractAddress) {
+		return this.decodeTransfers(addr, params)
+	}
+	return nil
+}
+
+//decodeTransfers collects the value moved by native token methods
+func (this *TxInfo) decodeTransfers(ccntmract common.Address, params interface{}) error {
+	arr, ok := params.(*vmArray)
+	if !ok || len(arr.items) == 0 {
+		return nil
+	}
+	var states []interface{}
+	switch this.Method {
+	case METHOD_TRANSFER, METHOD_TRANSFER_V2:
+		sts, ok := arr.items[0].(*vmArray)
+		if !ok {
+			return fmt.Errorf("invalid transfer params")
+		}
+		states = sts.items
+	case METHOD_TRANSFER_FROM, METHOD_TRANSFER_FROM_V2, METHOD_APPROVE, METHOD_APPROVE_V2:
+		states = arr.items[:1]
+	default:
+		return nil
+	}
+	v2 := this.Method == METHOD_TRANSFER_V2 || this.Method == METHOD_TRANSFER_FROM_V2 || this.Method == METHOD_APPROVE_V2
+	for _, item := range states {
+		st, ok := item.(*vmArray)
+		if !ok || len(st.items) < 3 {
+			return fmt.Errorf("invalid %s params", this.Method)
+		}
+		//transferFrom state is (sender, from, to, value), others are (from, to, value)
+		fields := st.items[len(st.items)-3:]
+		from, err := toAddress(fields[0])
+		if err != nil {
+			return err
+		}
+		to, err := toAddress(fields[1])
+		if err != nil {
+			return err
+		}
+		value, err := toInt(fields[2])
+		if err != nil {
+			return err
+		}
+		if value.Sign() < 0 {
+			return fmt.Errorf("negative value")
+		}
+		if v2 {
+			//round up so that the dust can not escape the limit
+			value = new(big.Int).Add(value, new(big.Int).Sub(V2_SCALE, big.NewInt(1)))
+			value.Div(value, V2_SCALE)
+		}
+		this.Transfers = append(this.Transfers, &Transfer{
+			Ccntmract: ccntmract.ToHexString(),
+			From:      from.ToBase58(),
+			To:        to.ToBase58(),
+			Value:     value,
+		})
+	}
+	return nil
+}
+
+func readPushData(code []byte, pc int, op neovm.OpCode) ([]byte, int, error) {
+	var size int
+	switch {
+	case op >= neovm.PUSHBYTES1 && op <= neovm.PUSHBYTES75:
+		size = int(op)
+	case op == neovm.PUSHDATA1 && pc+1 <= len(code):
+		size = int(code[pc])
+		pc += 1
+	case op == neovm.PUSHDATA2 && pc+2 <= len(code):
+		size = int(binary.LittleEndian.Uint16(code[pc:]))
+		pc += 2
+	case op == neovm.PUSHDATA4 && pc+4 <= len(code):
+		size = int(binary.LittleEndian.Uint32(code[pc:]))
+		pc += 4
+	default:
+		return nil, 0, fmt.Errorf("invalid push data")
+	}
+	if size < 0 || size > len(code)-pc {
+		return nil, 0, fmt.Errorf("push data out of range")
+	}
+	return code[pc : pc+size], pc + size, nil
+}
+
+func toInt(item interface{}) (*big.Int, error) {
+	switch v := item.(type) {
+	case *big.Int:
+		return v, nil
+	case []byte:
+		return neotypes.BigIntFromBytes(v), nil
+	case *vmArray:
+		//value of V2 methods is a struct of balance
+		if len(v.items) == 1 {
+			return toInt(v.items[0])
+		}
+	}
+	return nil, fmt.Errorf("invalid integer")
+}
+
+func toAddress(item interface{}) (common.Address, error) {
+	data, ok := item.([]byte)
+	if !ok {
+		return common.ADDRESS_EMPTY, fmt.Errorf("invalid address")
+	}
+	return common.AddressParseFromBytes(data)
+}
+
+func (this *vmArray) toJson() []interface{} {
+	items := make([]interface{}, 0, len(this.items))
+	for _, item := range this.items {
+		items = append(items, toJson(item))
+	}
+	return items
+}
+
+func toJson(item interface{}) interface{} {
+	switch v := item.(type) {
+	case []byte:
+		return hex.EncodeToString(v)
+	case *big.Int:
+		return v.String()
+	case *vmArray:
+		return v.toJson()
+	}
+	return nil
+}
@@ -0,0 +1,538 @@
+/*
+ * Copyright (C) 2018 The cntmology Authors
+ * This file is part of The cntmology library.
+ *
+ * The cntmology is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The cntmology is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * alcntm with The cntmology.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+//Package session coordinates the multi-signature of transaction among the holders of multisig address
+package session
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cntmio/cntmology-crypto/keypair"
+	"github.com/cntmio/cntmology/common"
+	"github.com/cntmio/cntmology/common/constants"
+	"github.com/cntmio/cntmology/common/log"
+	"github.com/cntmio/cntmology/core/signature"
+	"github.com/cntmio/cntmology/core/types"
+)
+
+const (
+	STATUS_PENDING    = "pending"    //collecting signatures
+	STATUS_COMPLETE   = "complete"   //enough signatures, not submitted
+	STATUS_SUBMITTING = "submitting" //submitting to node
+	STATUS_SUBMITTED  = "submitted"  //accepted by node
+	STATUS_FAILED     = "failed"     //rejected by node, can be submitted again
+	STATUS_REJECTED   = "rejected"   //too many holders rejected to collect enough signatures
+	STATUS_EXPIRED    = "expired"
+)
+
+//REJECT_PREFIX is prefixed to session id as the data signed to reject a session
+const REJECT_PREFIX = "reject:"
+
+//SubmitFunc sends the signed tx to node, and returns the tx hash
+type SubmitFunc func(tx *types.Transaction) (string, error)
+
+//Session collects the signatures of the holders of a M-of-N multisig address for a tx
+type Session struct {
+	Id          string            `json:"id"`
+	Creator     string            `json:"creator,omitempty"` //client which created the session
+	RawTx       string            `json:"raw_tx"`            //tx to sign
+	TxHash      string            `json:"tx_hash"`
+	M           int               `json:"m"`
+	PubKeys     []string          `json:"pub_keys"`
+	Address     string            `json:"address"` //multisig address
+	CreateTime  int64             `json:"create_time"`
+	ExpireTime  int64             `json:"expire_time"`
+	Status      string            `json:"status"`
+	Sigs        map[string]string `json:"sigs"`       //pub key => signature
+	Rejections  map[string]string `json:"rejections"` //pub key => reason
+	SignedTx    string            `json:"signed_tx,omitempty"`
+	SubmitError string            `json:"submit_error,omitempty"`
+
+	tx      *types.MutableTransaction
+	pubKeys []keypair.PublicKey
+}
+
+//RejectData returns the data signed by the holder to reject the session
+func RejectData(id string) []byte {
+	return []byte(REJECT_PREFIX + id)
+}
+
+//Tx returns the tx to sign of session, which is checked against the tx hash
+func (this *Session) Tx() (*types.MutableTransaction, error) {
+	raw, err := hex.DecodeString(this.RawTx)
+	if err != nil {
+		return nil, err
+	}
+	tx, err := types.TransactionFromRawBytes(raw)
+	if err != nil {
+		return nil, err
+	}
+	mutTx, err := tx.IntoMutable()
+	if err != nil {
+		return nil, err
+	}
+	if mutTx.Hash().ToHexString() != this.TxHash {
+		return nil, fmt.Errorf("tx hash mismatch")
+	}
+	return mutTx, nil
+}
+
+//HasPubKey returns whether pubKey is one of the holders
+func (this *Session) HasPubKey(pubKey keypair.PublicKey) bool {
+	pk := hex.EncodeToString(keypair.SerializePublicKey(pubKey))
+	for _, key := range this.PubKeys {
+		if key == pk {
+			return true
+		}
+	}
+	return false
+}
+
+func (this *Session) clone() *Session {
+	s := *this
+	s.PubKeys = append([]string{}, this.PubKeys...)
+	s.Sigs = make(map[string]string, len(this.Sigs))
+	for pk, sig := range this.Sigs {
+		s.Sigs[pk] = sig
+	}
+	s.Rejections = make(map[string]string, len(this.Rejections))
+	for pk, reason := range this.Rejections {
+		s.Rejections[pk] = reason
+	}
+	return &s
+}
+
+//init restores the tx and public keys of session
+func (this *Session) init() error {
+	tx, err := this.Tx()
+	if err != nil {
+		return err
+	}
+	this.tx = tx
+	this.pubKeys = make([]keypair.PublicKey, 0, len(this.PubKeys))
+	for _, pkStr := range this.PubKeys {
+		data, err := hex.DecodeString(pkStr)
+		if err != nil {
+			return err
+		}
+		pk, err := keypair.DeserializePublicKey(data)
+		if err != nil {
+			return err
+		}
+		this.pubKeys = append(this.pubKeys, pk)
+	}
+	if this.Sigs == nil {
+		this.Sigs = make(map[string]string)
+	}
+	if this.Rejections == nil {
+		this.Rejections = make(map[string]string)
+	}
+	return nil
+}
+
+//refresh expires the pending session, returns true if status changed
+func (this *Session) refresh(now int64) bool {
+	if this.Status == STATUS_PENDING && now > this.ExpireTime {
+		this.Status = STATUS_EXPIRED
+		return true
+	}
+	return false
+}
+
+//addSig verifies and records the signature of pubKey, returns false if it has been recorded
+func (this *Session) addSig(pubKey keypair.PublicKey, sig []byte) (bool, error) {
+	if !this.HasPubKey(pubKey) {
+		return false, fmt.Errorf("public key is not a holder of %s", this.Address)
+	}
+	pk := hex.EncodeToString(keypair.SerializePublicKey(pubKey))
+	if _, ok := this.Rejections[pk]; ok {
+		return false, fmt.Errorf("holder has rejected the session")
+	}
+	hash := this.tx.Hash()
+	if err := signature.Verify(pubKey, hash.ToArray(), sig); err != nil {
+		return false, fmt.Errorf("invalid signature: %s", err)
+	}
+	if _, ok := this.Sigs[pk]; ok {
+		return false, nil
+	}
+	this.Sigs[pk] = hex.EncodeToString(sig)
+	if len(this.Sigs) >= this.M {
+		signedTx, err := this.signedTx()
+		if err != nil {
+			return false, err
+		}
+		this.SignedTx = hex.EncodeToString(common.SerializeToBytes(signedTx))
+		this.Status = STATUS_COMPLETE
+	}
+	return true, nil
+}
+
+//signedTx returns the tx with the signatures of the first M holders in the order of public keys.
+//The other sigs of tx are kept
+func (this *Session) signedTx() (*types.Transaction, error) {
+	sigData := make([][]byte, 0, this.M)
+	for _, pk := range this.PubKeys {
+		sig, ok := this.Sigs[pk]
+		if !ok {
+			ccntminue
+		}
+		data, err := hex.DecodeString(sig)
+		if err != nil {
+			return nil, err
+		}
+		sigData = append(sigData, data)
+		if len(sigData) == this.M {
+			break
+		}
+	}
+	tx, err := this.Tx()
+	if err != nil {
+		return nil, err
+	}
+	sigs := make([]types.Sig, 0, len(tx.Sigs)+1)
+	for _, sig := range tx.Sigs {
+		addr, err := types.AddressFromMultiPubKeys(sig.PubKeys, int(sig.M))
+		if err == nil && addr.ToBase58() == this.Address {
+			ccntminue
+		}
+		sigs = append(sigs, sig)
+	}
+	tx.Sigs = append(sigs, types.Sig{
+		PubKeys: this.pubKeys,
+		M:       uint16(this.M),
+		SigData: sigData,
+	})
+	return tx.IntoImmutable()
+}
+
+//Manager keeps the sessions, which are saved to dir if not empty
+type Manager struct {
+	lock     sync.Mutex
+	dir      string
+	submit   SubmitFunc
+	sessions map[string]*Session
+}
+
+//NewManager loads the sessions saved in dir. The complete sessions are submitted by submit, nil to not submit
+func NewManager(dir string, submit SubmitFunc) (*Manager, error) {
+	mgr := &Manager{
+		dir:      dir,
+		submit:   submit,
+		sessions: make(map[string]*Session),
+	}
+	if dir == "" {
+		return mgr, nil
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), ".json") {
+			ccntminue
+		}
+		data, err := ioutil.ReadFile(filepath.Join(dir, file.Name()))
+		if err != nil {
+			return nil, err
+		}
+		s := &Session{}
+		if err = json.Unmarshal(data, s); err != nil {
+			return nil, fmt.Errorf("invalid session file %s: %s", file.Name(), err)
+		}
+		if err = s.init(); err != nil {
+			return nil, fmt.Errorf("invalid session file %s: %s", file.Name(), err)
+		}
+		if s.Status == STATUS_SUBMITTING {
+			//unknown whether it was accepted, submitting again is harmless
+			s.Status = STATUS_FAILED
+			s.SubmitError = "interrupted"
+		}
+		mgr.sessions[s.Id] = s
+	}
+	return mgr, nil
+}
+
+//Create starts a session to collect the signatures of the holders of pubKeys for tx. The payer of tx is
+//set to the multisig address if empty. The signatures of the holders already in tx are counted
+func (this *Manager) Create(creator string, mutTx *types.MutableTransaction, m int, pubKeys []keypair.PublicKey, expire time.Duration) (*Session, error) {
+	n := len(pubKeys)
+	if m <= 0 || n < m || n <= 1 || n > constants.MULTI_SIG_MAX_PUBKEY_SIZE {
+		return nil, fmt.Errorf("invalid m:%d of %d public keys", m, n)
+	}
+	pkStrs := make([]string, 0, n)
+	for _, pk := range pubKeys {
+		pkStr := hex.EncodeToString(keypair.SerializePublicKey(pk))
+		for _, other := range pkStrs {
+			if other == pkStr {
+				return nil, fmt.Errorf("duplicate public key %s", pkStr)
+			}
+		}
+		pkStrs = append(pkStrs, pkStr)
+	}
+	addr, err := types.AddressFromMultiPubKeys(pubKeys, m)
+	if err != nil {
+		return nil, err
+	}
+	if mutTx.Payer == common.ADDRESS_EMPTY {
+		mutTx.Payer = addr
+	}
+	tx, err := mutTx.IntoImmutable()
+	if err != nil {
+		return nil, err
+	}
+	id := make([]byte, 16)
+	if _, err = rand.Read(id); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	s := &Session{
+		Id:         hex.EncodeToString(id),
+		Creator:    creator,
+		RawTx:      hex.EncodeToString(common.SerializeToBytes(tx)),
+		TxHash:     tx.Hash().ToHexString(),
+		M:          m,
+		PubKeys:    pkStrs,
+		Address:    addr.ToBase58(),
+		CreateTime: now.Unix(),
+		ExpireTime: now.Add(expire).Unix(),
+		Status:     STATUS_PENDING,
+	}
+	if err = s.init(); err != nil {
+		return nil, err
+	}
+	for _, sig := range s.tx.Sigs {
+		sigAddr, err := types.AddressFromMultiPubKeys(sig.PubKeys, int(sig.M))
+		if err != nil || sigAddr != addr {
+			ccntminue
+		}
+		for _, data := range sig.SigData {
+			for _, pk := range sig.PubKeys {
+				if _, err = s.addSig(pk, data); err == nil {
+					break
+				}
+			}
+		}
+	}
+
+	this.lock.Lock()
+	if err = this.save(s); err != nil {
+		this.lock.Unlock()
+		return nil, err
+	}
+	this.sessions[s.Id] = s
+	result := s.clone()
+	this.lock.Unlock()
+
+	if result.Status == STATUS_COMPLETE {
+		return this.Submit(result.Id)
+	}
+	return result, nil
+}
+
+//Get returns the session of id
+func (this *Manager) Get(id string) (*Session, error) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	s, err := this.get(id)
+	if err != nil {
+		return nil, err
+	}
+	return s.clone(), nil
+}
+
+//List returns the sessions of status, all if status is empty, ordered by create time
+func (this *Manager) List(status string) []*Session {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	sessions := make([]*Session, 0)
+	for id := range this.sessions {
+		s, err := this.get(id)
+		if err != nil {
+			ccntminue
+		}
+		if status == "" || s.Status == status {
+			sessions = append(sessions, s.clone())
+		}
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		if sessions[i].CreateTime != sessions[j].CreateTime {
+			return sessions[i].CreateTime < sessions[j].CreateTime
+		}
+		return sessions[i].Id < sessions[j].Id
+	})
+	return sessions
+}
+
+//AddSig adds the signature of the holder of pubKey. The session is submitted once M signatures are collected
+func (this *Manager) AddSig(id string, pubKey keypair.PublicKey, sig []byte) (*Session, error) {
+	this.lock.Lock()
+	s, err := this.get(id)
+	if err != nil {
+		this.lock.Unlock()
+		return nil, err
+	}
+	if s.Status != STATUS_PENDING {
+		this.lock.Unlock()
+		return nil, fmt.Errorf("session %s is %s", id, s.Status)
+	}
+	old := s.clone()
+	added, err := s.addSig(pubKey, sig)
+	if err == nil && added {
+		if err = this.save(s); err != nil {
+			restore(s, old)
+		}
+	}
+	if err != nil {
+		this.lock.Unlock()
+		return nil, err
+	}
+	result := s.clone()
+	this.lock.Unlock()
+
+	if result.Status == STATUS_COMPLETE {
+		return this.Submit(id)
+	}
+	return result, nil
+}
+
+//Reject records the rejection of the holder of pubKey, sig is the signature of RejectData. The session
+//is rejected once the holders not rejected are fewer than M
+func (this *Manager) Reject(id string, pubKey keypair.PublicKey, sig []byte, reason string) (*Session, error) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	s, err := this.get(id)
+	if err != nil {
+		return nil, err
+	}
+	if s.Status != STATUS_PENDING {
+		return nil, fmt.Errorf("session %s is %s", id, s.Status)
+	}
+	if !s.HasPubKey(pubKey) {
+		return nil, fmt.Errorf("public key is not a holder of %s", s.Address)
+	}
+	if err = signature.Verify(pubKey, RejectData(id), sig); err != nil {
+		return nil, fmt.Errorf("invalid signature: %s", err)
+	}
+	pk := hex.EncodeToString(keypair.SerializePublicKey(pubKey))
+	if _, ok := s.Sigs[pk]; ok {
+		return nil, fmt.Errorf("holder has signed the session")
+	}
+	old := s.clone()
+	s.Rejections[pk] = reason
+	if len(s.PubKeys)-len(s.Rejections) < s.M {
+		s.Status = STATUS_REJECTED
+	}
+	if err = this.save(s); err != nil {
+		restore(s, old)
+		return nil, err
+	}
+	return s.clone(), nil
+}
+
+//Submit sends the tx of complete or failed session to node. The session stays complete if there is no node to submit
+func (this *Manager) Submit(id string) (*Session, error) {
+	this.lock.Lock()
+	s, err := this.get(id)
+	if err != nil {
+		this.lock.Unlock()
+		return nil, err
+	}
+	if s.Status != STATUS_COMPLETE && s.Status != STATUS_FAILED {
+		this.lock.Unlock()
+		return nil, fmt.Errorf("session %s is %s", id, s.Status)
+	}
+	if this.submit == nil {
+		result := s.clone()
+		this.lock.Unlock()
+		return result, nil
+	}
+	signedTx, err := s.signedTx()
+	if err != nil {
+		this.lock.Unlock()
+		return nil, err
+	}
+	s.Status = STATUS_SUBMITTING
+	this.lock.Unlock()
+
+	_, err = this.submit(signedTx)
+
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	if err != nil {
+		log.Infof("Submit session:%s tx:%s error:%s", id, s.TxHash, err)
+		s.Status = STATUS_FAILED
+		s.SubmitError = err.Error()
+	} else {
+		log.Infof("Submit session:%s tx:%s success", id, s.TxHash)
+		s.Status = STATUS_SUBMITTED
+		s.SubmitError = ""
+	}
+	if err = this.save(s); err != nil {
+		log.Errorf("Save session:%s error:%s", id, err)
+	}
+	return s.clone(), nil
+}
+
+func (this *Manager) get(id string) (*Session, error) {
+	s, ok := this.sessions[id]
+	if !ok {
+		return nil, fmt.Errorf("session %s not found", id)
+	}
+	if s.refresh(time.Now().Unix()) {
+		if err := this.save(s); err != nil {
+			log.Errorf("Save session:%s error:%s", id, err)
+		}
+	}
+	return s, nil
+}
+
+func (this *Manager) save(s *Session) error {
+	if this.dir == "" {
+		return nil
+	}
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	file := filepath.Join(this.dir, s.Id+".json")
+	tmp := file + "~"
+	if err = ioutil.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, file)
+}
+
+func restore(s, old *Session) {
+	s.Status = old.Status
+	s.Sigs = old.Sigs
+	s.Rejections = old.Rejections
+	s.SignedTx = old.SignedTx
+}
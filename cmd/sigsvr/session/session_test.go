@@ -0,0 +1,178 @@
+/*
+ * Copyright (C) 2018 The cntmology Authors
+ * This file is part of The cntmology library.
+ *
+ * The cntmology is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The cntmology is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * alcntm with The cntmology.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package session
+
+import (
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/cntmio/cntmology-crypto/keypair"
+	"github.com/cntmio/cntmology/account"
+	cliutil "github.com/cntmio/cntmology/cmd/utils"
+	"github.com/cntmio/cntmology/core/signature"
+	"github.com/cntmio/cntmology/core/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestTx(t *testing.T, pubKeys []keypair.PublicKey, m int) *types.MutableTransaction {
+	addr, err := types.AddressFromMultiPubKeys(pubKeys, m)
+	assert.Nil(t, err)
+	tx, err := cliutil.TransferTx(0, 20000, "cntm", addr.ToBase58(), account.NewAccount("").Address.ToBase58(), 10)
+	assert.Nil(t, err)
+	return tx
+}
+
+func signSession(t *testing.T, s *Session, acc *account.Account) []byte {
+	tx, err := s.Tx()
+	assert.Nil(t, err)
+	hash := tx.Hash()
+	sig, err := signature.Sign(acc, hash.ToArray())
+	assert.Nil(t, err)
+	return sig
+}
+
+func TestSession(t *testing.T) {
+	dir, err := ioutil.TempDir("", "session")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	submitted := make([]*types.Transaction, 0)
+	submit := func(tx *types.Transaction) (string, error) {
+		submitted = append(submitted, tx)
+		return tx.Hash().ToHexString(), nil
+	}
+	mgr, err := NewManager(dir, submit)
+	assert.Nil(t, err)
+
+	accs := []*account.Account{account.NewAccount(""), account.NewAccount(""), account.NewAccount("")}
+	pubKeys := []keypair.PublicKey{accs[0].PublicKey, accs[1].PublicKey, accs[2].PublicKey}
+	_, err = mgr.Create("", newTestTx(t, pubKeys, 2), 2, pubKeys[:1], time.Hour)
+	assert.NotNil(t, err)
+	_, err = mgr.Create("", newTestTx(t, pubKeys, 2), 2, []keypair.PublicKey{pubKeys[0], pubKeys[0]}, time.Hour)
+	assert.NotNil(t, err)
+
+	s, err := mgr.Create("wallet", newTestTx(t, pubKeys, 2), 2, pubKeys, time.Hour)
+	assert.Nil(t, err)
+	assert.Equal(t, STATUS_PENDING, s.Status)
+	tx, err := s.Tx()
+	assert.Nil(t, err)
+	assert.Equal(t, s.Address, tx.Payer.ToBase58())
+
+	//signature of other data
+	sig, err := signature.Sign(accs[0], []byte("data"))
+	assert.Nil(t, err)
+	_, err = mgr.AddSig(s.Id, accs[0].PublicKey, sig)
+	assert.NotNil(t, err)
+	_, err = mgr.AddSig(s.Id, account.NewAccount("").PublicKey, signSession(t, s, accs[0]))
+	assert.NotNil(t, err)
+
+	s, err = mgr.AddSig(s.Id, accs[0].PublicKey, signSession(t, s, accs[0]))
+	assert.Nil(t, err)
+	assert.Equal(t, STATUS_PENDING, s.Status)
+	assert.Equal(t, 1, len(s.Sigs))
+	s, err = mgr.AddSig(s.Id, accs[0].PublicKey, signSession(t, s, accs[0]))
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(s.Sigs))
+
+	rejectSig, err := signature.Sign(accs[1], RejectData(s.Id))
+	assert.Nil(t, err)
+	_, err = mgr.Reject(s.Id, accs[1].PublicKey, rejectSig[1:], "no")
+	assert.NotNil(t, err)
+	s, err = mgr.Reject(s.Id, accs[1].PublicKey, rejectSig, "no")
+	assert.Nil(t, err)
+	assert.Equal(t, STATUS_PENDING, s.Status)
+	_, err = mgr.AddSig(s.Id, accs[1].PublicKey, signSession(t, s, accs[1]))
+	assert.NotNil(t, err)
+
+	s, err = mgr.AddSig(s.Id, accs[2].PublicKey, signSession(t, s, accs[2]))
+	assert.Nil(t, err)
+	assert.Equal(t, STATUS_SUBMITTED, s.Status)
+	assert.Equal(t, 1, len(submitted))
+	signedTx := submitted[0]
+	assert.Equal(t, s.TxHash, signedTx.Hash().ToHexString())
+	assert.Equal(t, 1, len(signedTx.Sigs))
+	hash := signedTx.Hash()
+	assert.Nil(t, signature.VerifyMultiSignature(hash.ToArray(), pubKeys, 2, signedTx.Sigs[0].SigData))
+	raw, err := hex.DecodeString(s.SignedTx)
+	assert.Nil(t, err)
+	_, err = types.TransactionFromRawBytes(raw)
+	assert.Nil(t, err)
+	_, err = mgr.AddSig(s.Id, accs[1].PublicKey, signSession(t, s, accs[1]))
+	assert.NotNil(t, err)
+
+	//rejected by too many holders
+	rejected, err := mgr.Create("", newTestTx(t, pubKeys, 2), 2, pubKeys, time.Hour)
+	assert.Nil(t, err)
+	for _, acc := range accs[:2] {
+		sig, err := signature.Sign(acc, RejectData(rejected.Id))
+		assert.Nil(t, err)
+		rejected, err = mgr.Reject(rejected.Id, acc.PublicKey, sig, "")
+		assert.Nil(t, err)
+	}
+	assert.Equal(t, STATUS_REJECTED, rejected.Status)
+
+	expired, err := mgr.Create("", newTestTx(t, pubKeys, 2), 2, pubKeys, -time.Second)
+	assert.Nil(t, err)
+	_, err = mgr.AddSig(expired.Id, accs[0].PublicKey, signSession(t, expired, accs[0]))
+	assert.NotNil(t, err)
+	assert.Equal(t, 1, len(mgr.List(STATUS_EXPIRED)))
+
+	//sessions survive restart
+	mgr, err = NewManager(dir, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(mgr.List("")))
+	s, err = mgr.Get(s.Id)
+	assert.Nil(t, err)
+	assert.Equal(t, STATUS_SUBMITTED, s.Status)
+	assert.Equal(t, 2, len(s.Sigs))
+	assert.Equal(t, "no", s.Rejections[hex.EncodeToString(keypair.SerializePublicKey(accs[1].PublicKey))])
+}
+
+func TestSessionSubmitFailed(t *testing.T) {
+	fail := true
+	submit := func(tx *types.Transaction) (string, error) {
+		if fail {
+			return "", errors.New("node error")
+		}
+		return tx.Hash().ToHexString(), nil
+	}
+	mgr, err := NewManager("", submit)
+	assert.Nil(t, err)
+	accs := []*account.Account{account.NewAccount(""), account.NewAccount("")}
+	pubKeys := []keypair.PublicKey{accs[0].PublicKey, accs[1].PublicKey}
+
+	//signatures already in tx are counted
+	tx := newTestTx(t, pubKeys, 1)
+	assert.Nil(t, cliutil.MultiSigTransaction(tx, 1, pubKeys, accs[1]))
+	s, err := mgr.Create("", tx, 1, pubKeys, time.Hour)
+	assert.Nil(t, err)
+	assert.Equal(t, STATUS_FAILED, s.Status)
+	assert.Equal(t, "node error", s.SubmitError)
+
+	fail = false
+	s, err = mgr.Submit(s.Id)
+	assert.Nil(t, err)
+	assert.Equal(t, STATUS_SUBMITTED, s.Status)
+	_, err = mgr.Submit(s.Id)
+	assert.NotNil(t, err)
+}
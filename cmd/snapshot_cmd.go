@@ -0,0 +1,168 @@
+/*
+ * Copyright (C) 2018 The cntmology Authors
+ * This file is part of The cntmology library.
+ *
+ * The cntmology is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The cntmology is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * alcntm with The cntmology.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+
+	"github.com/cntmio/cntmology/cmd/utils"
+	"github.com/cntmio/cntmology/common/config"
+	"github.com/cntmio/cntmology/common/log"
+	"github.com/cntmio/cntmology/core/genesis"
+	"github.com/cntmio/cntmology/core/ledger"
+	"github.com/urfave/cli"
+)
+
+var SnapshotCommand = cli.Command{
+	Name:      "snapshot",
+	Usage:     "Export or import the state snapshot of DB",
+	ArgsUsage: "[arguments...]",
+	Description: `The state snapshot holds the state after the current block, it lets a new node start from the block
+without executing the blocks before it. The node must be stopped while the snapshot is exported or imported.`,
+	Subcommands: []cli.Command{
+		{
+			Action:    exportSnapshot,
+			Name:      "export",
+			Usage:     "Export the state snapshot of the current block to a file",
+			ArgsUsage: "[sub-command options]",
+			Flags: []cli.Flag{
+				utils.SnapshotFileFlag,
+				utils.DataDirFlag,
+				utils.ConfigFlag,
+				utils.NetworkIdFlag,
+			},
+		},
+		{
+			Action:    importSnapshot,
+			Name:      "import",
+			Usage:     "Import the state snapshot from a file to an empty DB",
+			ArgsUsage: "[sub-command options]",
+			Flags: []cli.Flag{
+				utils.SnapshotFileFlag,
+				utils.DataDirFlag,
+				utils.ConfigFlag,
+				utils.NetworkIdFlag,
+				utils.DisableEventLogFlag,
+				utils.EnableArchiveFlag,
+				utils.PruneBlocksFlag,
+			},
+			Description: `The blocks before the snapshot block are not imported. If the import is interrupted,
+remove the DB and import again.`,
+		},
+	},
+}
+
+func openSnapshotLedger(ctx *cli.Ccntmext) (*ledger.Ledger, error) {
+	cfg, err := SetOntologyConfig(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("SetOntologyConfig error:%s", err)
+	}
+	dbDir := utils.GetStoreDirPath(config.DefConfig.Common.DataDir, config.DefConfig.P2PNode.NetworkName)
+	stateHashHeight := config.GetStateHashCheckHeight(cfg.P2PNode.NetworkId)
+	ld, err := ledger.NewLedger(dbDir, stateHashHeight)
+	if err != nil {
+		return nil, fmt.Errorf("NewLedger error:%s", err)
+	}
+	bookKeepers, err := config.DefConfig.GetBookkeepers()
+	if err != nil {
+		ld.Close()
+		return nil, fmt.Errorf("GetBookkeepers error:%s", err)
+	}
+	genesisBlock, err := genesis.BuildGenesisBlock(bookKeepers, config.DefConfig.Genesis)
+	if err != nil {
+		ld.Close()
+		return nil, fmt.Errorf("BuildGenesisBlock error %s", err)
+	}
+	if err = ld.Init(bookKeepers, genesisBlock); err != nil {
+		ld.Close()
+		return nil, fmt.Errorf("Init ledger error:%s", err)
+	}
+	return ld, nil
+}
+
+func exportSnapshot(ctx *cli.Ccntmext) error {
+	log.InitLog(log.InfoLog)
+
+	snapshotFile := ctx.String(utils.GetFlagName(utils.SnapshotFileFlag))
+	if snapshotFile == "" {
+		PrintErrorMsg("Missing %s argument.", utils.SnapshotFileFlag.Name)
+		cli.ShowSubcommandHelp(ctx)
+		return nil
+	}
+	ld, err := openSnapshotLedger(ctx)
+	if err != nil {
+		return err
+	}
+	defer ld.Close()
+
+	ofile, err := os.OpenFile(snapshotFile, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return fmt.Errorf("OpenFile error:%s", err)
+	}
+	fWriter := bufio.NewWriter(ofile)
+	PrintInfoMsg("Start export state snapshot of block %d.", ld.GetCurrentBlockHeight())
+	info, digest, err := ld.ExportStateSnapshot(fWriter)
+	if err == nil {
+		err = fWriter.Flush()
+	}
+	if e := ofile.Close(); err == nil {
+		err = e
+	}
+	if err != nil {
+		os.Remove(snapshotFile)
+		return fmt.Errorf("export state snapshot error:%s", err)
+	}
+	PrintInfoMsg("Export state snapshot completed, block height:%d, state root:%s, digest:%s.", info.Height,
+		info.StateRoot.ToHexString(), digest.ToHexString())
+	return nil
+}
+
+func importSnapshot(ctx *cli.Ccntmext) error {
+	log.InitLog(log.InfoLog)
+
+	snapshotFile := ctx.String(utils.GetFlagName(utils.SnapshotFileFlag))
+	if snapshotFile == "" {
+		PrintErrorMsg("Missing %s argument.", utils.SnapshotFileFlag.Name)
+		cli.ShowSubcommandHelp(ctx)
+		return nil
+	}
+	ld, err := openSnapshotLedger(ctx)
+	if err != nil {
+		return err
+	}
+	defer ld.Close()
+
+	currBlockHeight := ld.GetCurrentBlockHeight()
+	if currBlockHeight != 0 {
+		PrintWarnMsg("CurrentBlockHeight:%d, the state snapshot can only be imported to an empty DB.", currBlockHeight)
+		return nil
+	}
+	//the headers are not synced offline, the state is checked against the state trie root of the snapshot only
+	PrintWarnMsg("The state snapshot is not checked against the consensus, only import a snapshot you trust.")
+	PrintInfoMsg("Start import state snapshot.")
+	info, err := ld.ImportStateSnapshot(snapshotFile, true)
+	if err != nil {
+		return fmt.Errorf("import state snapshot error:%s", err)
+	}
+	PrintInfoMsg("Import state snapshot completed, current block height:%d, state root:%s.", info.Height,
+		info.StateRoot.ToHexString())
+	return nil
+}
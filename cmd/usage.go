@@ -103,6 +103,10 @@ var AppHelpFlagGroups = []flagGroup{
 			utils.LogDirFlag,
 			utils.DisableLogFileFlag,
 			utils.DisableEventLogFlag,
+			utils.EnableArchiveFlag,
+			utils.PruneBlocksFlag,
+			utils.SnapshotIntervalFlag,
+			utils.StateTrieHeightFlag,
 			utils.DataDirFlag,
 			utils.ETHTxGasLimitFlag,
 			utils.WasmVerifyMethodFlag,
@@ -143,6 +147,10 @@ var AppHelpFlagGroups = []flagGroup{
 		Flags: []cli.Flag{
 			utils.GasPriceFlag,
 			utils.GasLimitFlag,
+			utils.TxPoolCapacityFlag,
+			utils.TxPoolPayerLimitFlag,
+			utils.TxPoolPriceBumpFlag,
+			utils.TxPoolLifetimeFlag,
 			utils.TxpoolPreExecDisableFlag,
 			utils.DisableSyncVerifyTxFlag,
 			utils.DisableBroadcastNetTxFlag,
@@ -159,6 +167,11 @@ var AppHelpFlagGroups = []flagGroup{
 			utils.MaxConnInBoundFlag,
 			utils.MaxConnOutBoundFlag,
 			utils.MaxConnInBoundForSingleIPFlag,
+			utils.FastSyncFlag,
+			utils.RequireEncryptionFlag,
+			utils.PinnedPeersFlag,
+			utils.MaxBandwidthFlag,
+			utils.MaxPeerBandwidthFlag,
 		},
 	},
 	{
@@ -168,6 +181,7 @@ var AppHelpFlagGroups = []flagGroup{
 			utils.RPCPortFlag,
 			utils.RPCLocalEnableFlag,
 			utils.RPCLocalProtFlag,
+			utils.ETHRPCEnableFlag,
 			utils.ETHRPCPortFlag,
 		},
 	},
@@ -185,6 +199,7 @@ var AppHelpFlagGroups = []flagGroup{
 			utils.GraphQLEnableFlag,
 			utils.GraphQLPortFlag,
 			utils.GraphQLMaxConnsFlag,
+			utils.GraphQLOriginsFlag,
 		},
 	},
 	{
@@ -199,6 +214,7 @@ var AppHelpFlagGroups = []flagGroup{
 		Flags: []cli.Flag{
 			utils.EnableTestModeFlag,
 			utils.TestModeGenBlockTimeFlag,
+			utils.TestModeDevFlag,
 		},
 	},
 	{
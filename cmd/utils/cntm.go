@@ -35,6 +35,7 @@ import (
 	"github.com/cntmio/cntmology-crypto/keypair"
 	sig "github.com/cntmio/cntmology-crypto/signature"
 	"github.com/cntmio/cntmology/account"
+	accsigner "github.com/cntmio/cntmology/account/signer"
 	"github.com/cntmio/cntmology/common"
 	"github.com/cntmio/cntmology/common/constants"
 	"github.com/cntmio/cntmology/common/serialization"
@@ -333,18 +334,23 @@ func NewInvokeTransaction(gasPrice, gasLimit uint64, invokeCode []byte) *types.M
 }
 
 func SignTransaction(signer *account.Account, tx *types.MutableTransaction) error {
+	return SignTransactionBySigner(accsigner.NewLocalSigner(signer), tx)
+}
+
+//SignTransactionBySigner sign the transaction with signer, which may hold the key out of process
+func SignTransactionBySigner(signer accsigner.Signer, tx *types.MutableTransaction) error {
 	if tx.Payer == common.ADDRESS_EMPTY {
-		tx.Payer = signer.Address
+		tx.Payer = accsigner.Address(signer)
 	}
-	txHash := tx.Hash()
-	sigData, err := Sign(txHash.ToArray(), signer)
+	sigData, err := signer.SignTransaction(tx)
 	if err != nil {
 		return fmt.Errorf("sign error:%s", err)
 	}
+	txHash := tx.Hash()
 	hasSig := false
 	for i, sig := range tx.Sigs {
-		if len(sig.PubKeys) == 1 && pubKeysEqual(sig.PubKeys, []keypair.PublicKey{signer.PublicKey}) {
-			if hasAlreadySig(txHash.ToArray(), signer.PublicKey, sig.SigData) {
+		if len(sig.PubKeys) == 1 && pubKeysEqual(sig.PubKeys, []keypair.PublicKey{signer.PubKey()}) {
+			if hasAlreadySig(txHash.ToArray(), signer.PubKey(), sig.SigData) {
 				//has already signed
 				return nil
 			}
@@ -355,7 +361,7 @@ func SignTransaction(signer *account.Account, tx *types.MutableTransaction) erro
 	}
 	if !hasSig {
 		tx.Sigs = append(tx.Sigs, types.Sig{
-			PubKeys: []keypair.PublicKey{signer.PublicKey},
+			PubKeys: []keypair.PublicKey{signer.PubKey()},
 			M:       1,
 			SigData: [][]byte{sigData},
 		})
@@ -364,13 +370,18 @@ func SignTransaction(signer *account.Account, tx *types.MutableTransaction) erro
 }
 
 func MultiSigTransaction(mutTx *types.MutableTransaction, m uint16, pubKeys []keypair.PublicKey, signer *account.Account) error {
+	return MultiSigTransactionBySigner(mutTx, m, pubKeys, accsigner.NewLocalSigner(signer))
+}
+
+//MultiSigTransactionBySigner add the signature of signer to the multi-signature of transaction
+func MultiSigTransactionBySigner(mutTx *types.MutableTransaction, m uint16, pubKeys []keypair.PublicKey, signer accsigner.Signer) error {
 	pkSize := len(pubKeys)
 	if m == 0 || int(m) > pkSize || pkSize > constants.MULTI_SIG_MAX_PUBKEY_SIZE {
 		return fmt.Errorf("invalid params")
 	}
 	validPubKey := false
 	for _, pk := range pubKeys {
-		if keypair.ComparePublicKey(pk, signer.PublicKey) {
+		if keypair.ComparePublicKey(pk, signer.PubKey()) {
 			validPubKey = true
 			break
 		}
@@ -390,11 +401,11 @@ func MultiSigTransaction(mutTx *types.MutableTransaction, m uint16, pubKeys []ke
 		mutTx.Sigs = make([]types.Sig, 0)
 	}
 
-	txHash := mutTx.Hash()
-	sigData, err := Sign(txHash.ToArray(), signer)
+	sigData, err := signer.SignTransaction(mutTx)
 	if err != nil {
 		return fmt.Errorf("sign error:%s", err)
 	}
+	txHash := mutTx.Hash()
 
 	hasMutilSig := false
 	for i, sigs := range mutTx.Sigs {
@@ -402,7 +413,7 @@ func MultiSigTransaction(mutTx *types.MutableTransaction, m uint16, pubKeys []ke
 			ccntminue
 		}
 		hasMutilSig = true
-		if hasAlreadySig(txHash.ToArray(), signer.PublicKey, sigs.SigData) {
+		if hasAlreadySig(txHash.ToArray(), signer.PubKey(), sigs.SigData) {
 			break
 		}
 		sigs.SigData = append(sigs.SigData, sigData)
@@ -479,6 +490,20 @@ func SendRawTransactionData(txData string) (string, error) {
 	if cntmErr != nil {
 		return "", cntmErr.Error
 	}
+	return parseTxHash(data)
+}
+
+//SendRawTransactionTo send a transaction to the json rpc of node at rpcAddr, and return hash of the transaction
+func SendRawTransactionTo(rpcAddr string, tx *types.Transaction) (string, error) {
+	txData := hex.EncodeToString(common.SerializeToBytes(tx))
+	data, cntmErr := sendRpcRequestTo(rpcAddr, "sendrawtransaction", []interface{}{txData})
+	if cntmErr != nil {
+		return "", cntmErr.Error
+	}
+	return parseTxHash(data)
+}
+
+func parseTxHash(data []byte) (string, error) {
 	hexHash := ""
 	err := json.Unmarshal(data, &hexHash)
 	if err != nil {
@@ -29,6 +29,7 @@ import (
 
 const (
 	DEFAULT_EXPORT_FILE   = "./OntBlocks.dat"
+	DEFAULT_SNAPSHOT_FILE = "./CntmSnapshot.dat"
 	DEFAULT_ABI_PATH      = "./abi"
 	DEFAULT_EXPORT_HEIGHT = 0
 	DEFAULT_WALLET_PATH   = "./wallet_data"
@@ -58,6 +59,23 @@ var (
 		Name:  "disable-event-log",
 		Usage: "Discard event log output by smart ccntmract execution",
 	}
+	EnableArchiveFlag = cli.BoolFlag{
+		Name:  "archive",
+		Usage: "Keep the state of every block from now on to query storage and balances at a given height",
+	}
+	PruneBlocksFlag = cli.UintFlag{
+		Name:  "prune-blocks",
+		Usage: "Only keep the state history of the latest `<number>` blocks (at least 128) in the archive mode. 0 keeps the whole history",
+	}
+	SnapshotIntervalFlag = cli.UintFlag{
+		Name:  "snapshot-interval",
+		Usage: "Export a state snapshot every `<number>` blocks to serve the fast sync of the peers. 0 disables the snapshots",
+	}
+	StateTrieHeightFlag = cli.UintFlag{
+		Name:  "state-trie-height",
+		Usage: "Commit to the state trie from block `<height>` on a custom network. All nodes of the network must use the same height. Disabled by default",
+		Value: config.DEFAULT_STATE_TRIE_HEIGHT,
+	}
 	WasmVerifyMethodFlag = cli.BoolFlag{
 		Name:  "enable-wasmjit-verifier",
 		Usage: "Enable wasmjit verifier to verify wasm ccntmract",
@@ -77,6 +95,11 @@ var (
 		Usage: "Stop import block `<height>` of the import.",
 		Value: DEFAULT_EXPORT_HEIGHT,
 	}
+	SnapshotFileFlag = cli.StringFlag{
+		Name:  "snapshot-file",
+		Usage: "Path of state snapshot `<file>`",
+		Value: DEFAULT_SNAPSHOT_FILE,
+	}
 	DataDirFlag = cli.StringFlag{
 		Name:  "data-dir",
 		Usage: "Block data storage `<path>`",
@@ -107,6 +130,26 @@ var (
 		Usage: "Min gas price `<value>` of transaction to be accepted by tx pool.",
 		Value: config.DEFAULT_GAS_PRICE,
 	}
+	TxPoolCapacityFlag = cli.UintFlag{
+		Name:  "tx-pool-capacity",
+		Usage: "Max transaction `<number>` in tx pool. The transaction of the lowest gas price is evicted for a higher one when the pool is full",
+		Value: config.DEFAULT_TX_POOL_CAPACITY,
+	}
+	TxPoolPayerLimitFlag = cli.UintFlag{
+		Name:  "tx-pool-payer-limit",
+		Usage: "Max transaction `<number>` of a payer in tx pool. 0 for no limit",
+		Value: config.DEFAULT_TX_POOL_PAYER_LIMIT,
+	}
+	TxPoolPriceBumpFlag = cli.Uint64Flag{
+		Name:  "tx-pool-price-bump",
+		Usage: "Min gas price bump `<percent>` to replace a transaction of the same payer and nonce in tx pool",
+		Value: config.DEFAULT_TX_POOL_PRICE_BUMP,
+	}
+	TxPoolLifetimeFlag = cli.UintFlag{
+		Name:  "tx-pool-lifetime",
+		Usage: "Drop the transactions staying in tx pool for more than `<number>` blocks. 0 to never expire",
+		Value: config.DEFAULT_TX_POOL_LIFETIME,
+	}
 	//Test Mode setting
 	EnableTestModeFlag = cli.BoolFlag{
 		Name:  "testmode",
@@ -117,6 +160,10 @@ var (
 		Usage: "Block-out `<time>`(s) in test mode.",
 		Value: config.DEFAULT_GEN_BLOCK_TIME,
 	}
+	TestModeDevFlag = cli.BoolFlag{
+		Name:  "testmode-dev",
+		Usage: "Dev chain in test mode. Mine a block on every transaction instead of every block-out time, and enable the dev rpc methods to mine, shift block time, snapshot and revert the chain",
+	}
 
 	//P2P setting
 	ReservedPeersOnlyFlag = cli.BoolFlag{
@@ -158,6 +205,26 @@ var (
 		Usage: "Max connection `<number>` in bound for single ip",
 		Value: config.DEFAULT_MAX_CONN_IN_BOUND_FOR_SINGLE_IP,
 	}
+	FastSyncFlag = cli.BoolFlag{
+		Name:  "fast-sync",
+		Usage: "Sync from the latest state snapshot of the peers instead of executing every block. Only works on an empty ledger",
+	}
+	RequireEncryptionFlag = cli.BoolFlag{
+		Name:  "require-encryption",
+		Usage: "Only accept p2p connections with encrypted transport. Peers not supporting encryption are rejected. It does not authenticate the peers, pin their peer ids with --pinned-peers",
+	}
+	PinnedPeersFlag = cli.StringFlag{
+		Name:  "pinned-peers",
+		Usage: "Peer ids pinned to ip addresses, the format is `<ip>=<peer id>,<ip>=<peer id>`. The peers at the addresses must authenticate with the peer ids over encrypted transport",
+	}
+	MaxBandwidthFlag = cli.UintFlag{
+		Name:  "max-bandwidth",
+		Usage: "Max upload bandwidth `<KB/s>` to all peers. 0 means unlimited",
+	}
+	MaxPeerBandwidthFlag = cli.UintFlag{
+		Name:  "max-peer-bandwidth",
+		Usage: "Max upload bandwidth `<KB/s>` to each peer. 0 means unlimited",
+	}
 	// RPC settings
 	RPCDisabledFlag = cli.BoolFlag{
 		Name:  "disable-rpc",
@@ -168,6 +235,10 @@ var (
 		Usage: "Json rpc server listening port `<number>`",
 		Value: config.DEFAULT_RPC_PORT,
 	}
+	ETHRPCEnableFlag = cli.BoolFlag{
+		Name:  "ethrpc",
+		Usage: "Enable the eth json rpc server",
+	}
 	ETHRPCPortFlag = cli.UintFlag{
 		Name:  "ethrpcport",
 		Usage: "Eth json rpc server listening port `<number>`",
@@ -225,6 +296,10 @@ var (
 		Usage: "GraphQL server maximum connections `<number>`",
 		Value: config.DEFAULT_HTTP_MAX_CONN,
 	}
+	GraphQLOriginsFlag = cli.StringFlag{
+		Name:  "graphql-origins",
+		Usage: "Comma separated list of origins allowed to open graphql subscriptions `<origins>`, * for any origin",
+	}
 
 	//Account setting
 	AccountPassFlag = cli.StringFlag{
@@ -273,6 +348,14 @@ var (
 		Name:  "key,k",
 		Usage: "Use `<private key>` (hex encoding) of the account",
 	}
+	AccountMnemonicFlag = cli.BoolFlag{
+		Name:  "mnemonic",
+		Usage: "Create a new mnemonic and derive the accounts from it, so that one backup recovers all of them",
+	}
+	AccountHDSeedFlag = cli.StringFlag{
+		Name:  "seed",
+		Usage: "`<id>` of the hd seed to derive account from. If not specified, using the only seed of wallet",
+	}
 	AccountVerboseFlag = cli.BoolFlag{
 		Name:  "verbose,v",
 		Usage: "Display accounts with details",
@@ -446,6 +529,24 @@ var (
 		Name:  "prepare,p",
 		Usage: "Prepare execute transaction, without commit to ledger",
 	}
+	SignerFlag = cli.StringFlag{
+		Name:  "signer",
+		Usage: "Sign by the remote signer at `<uri>` (unix:///path or tcp://host:port) instead of the wallet",
+	}
+	SignerSecretFileFlag = cli.StringFlag{
+		Name:  "signer-secret-file",
+		Usage: "Shared secret `<file>` to authenticate with the remote signer",
+	}
+	SignerListenFlag = cli.StringFlag{
+		Name:  "listen",
+		Usage: "Signer daemon listen `<uri>` (unix:///path or tcp://host:port)",
+		Value: "unix://signer.sock",
+	}
+	SignerProtectionFileFlag = cli.StringFlag{
+		Name:  "protection-file",
+		Usage: "Slashing protection `<file>` recording the signed proposals of the signer daemon",
+		Value: "signer_protection.json",
+	}
 	WithdrawcntmReceiveAccountFlag = cli.StringFlag{
 		Name:  "receive",
 		Usage: "cntm receive `<address>`，Default the same with owner account",
@@ -480,6 +581,56 @@ var (
 		Usage: "Wallet data `<path>`",
 		Value: DEFAULT_WALLET_PATH,
 	}
+	CliApiKeyFileFlag = cli.StringFlag{
+		Name:  "apikeyfile",
+		Usage: "Api key `<file>` of client name => hex sha256 of api key. Clients must send api key if set",
+	}
+	CliTLSCertFlag = cli.StringFlag{
+		Name:  "tlscert",
+		Usage: "TLS certificate `<file>` of sig server",
+	}
+	CliTLSKeyFlag = cli.StringFlag{
+		Name:  "tlskey",
+		Usage: "TLS private key `<file>` of sig server",
+	}
+	CliTLSClientCAFlag = cli.StringFlag{
+		Name:  "tlsclientca",
+		Usage: "CA certificate `<file>` to verify client certificate. Clients must present certificate if set",
+	}
+	CliPolicyFileFlag = cli.StringFlag{
+		Name:  "policy",
+		Usage: "Signing policy `<file>`",
+	}
+	CliAuditLogFlag = cli.StringFlag{
+		Name:  "auditlog",
+		Usage: "Audit log `<file>` of sig server",
+	}
+	CliAuditKeyFileFlag = cli.StringFlag{
+		Name:  "auditkey",
+		Usage: "Key `<file>` to sign audit log. Will be generated if not exist",
+		Value: "audit.key",
+	}
+	CliSessionDirFlag = cli.StringFlag{
+		Name:  "sessiondir",
+		Usage: "Multi-signature session data `<path>`. Sessions are kept in memory if not set",
+	}
+	CliSessionExpireFlag = cli.UintFlag{
+		Name:  "sessionexpire",
+		Usage: "Default expire time of multi-signature session in `<seconds>`",
+		Value: 86400,
+	}
+	CliSessionRpcFlag = cli.StringFlag{
+		Name:  "sessionrpc",
+		Usage: "Json rpc `<address>` of node to submit the tx of complete session, e.g. http://127.0.0.1:20336. Not submit if not set",
+	}
+	CliSessionApiKeyFlag = cli.StringFlag{
+		Name:  "sessionapikey",
+		Usage: "Api `<key>` to access the sig server which coordinates the remote session",
+	}
+	CliSessionPeersFlag = cli.StringFlag{
+		Name:  "sessionpeers",
+		Usage: "Comma separated `<urls>` of the sig servers allowed to coordinate remote sessions. Remote sessions are refused if not set",
+	}
 
 	//Export setting
 	ExportFileFlag = cli.StringFlag{
@@ -25,6 +25,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"math"
 
 	"github.com/conntectome/cntm-crypto/keypair"
 	"github.com/conntectome/cntm/common"
@@ -62,8 +63,10 @@ const (
 	DEFAULT_CONSENSUS_PORT                  = uint(20339)
 	DEFAULT_RPC_PORT                        = uint(20336)
 	DEFAULT_RPC_LOCAL_PORT                  = uint(20337)
+	DEFAULT_ETH_RPC_PORT                    = uint(20340)
 	DEFAULT_REST_PORT                       = uint(20334)
 	DEFAULT_WS_PORT                         = uint(20335)
+	DEFAULT_GRAPHQL_PORT                    = uint(20333)
 	DEFAULT_REST_MAX_CONN                   = uint(1024)
 	DEFAULT_MAX_CONN_IN_BOUND               = uint(1024)
 	DEFAULT_MAX_CONN_OUT_BOUND              = uint(1024)
@@ -77,6 +80,11 @@ const (
 	DEFUALT_CLI_RPC_ADDRESS                 = "127.0.0.1"
 	DEFAULT_MIN_GAS_LIMIT                   = 20000
 	DEFAULT_GAS_PRICE                       = 500
+	DEFAULT_TX_POOL_CAPACITY                = 100140
+	DEFAULT_TX_POOL_PAYER_LIMIT             = 1024
+	DEFAULT_TX_POOL_PRICE_BUMP              = 10 //Percent
+	DEFAULT_TX_POOL_LIFETIME                = 600
+	DEFAULT_STATE_TRIE_HEIGHT               = math.MaxUint32 //The state trie is disabled
 	DEFAULT_WASM_GAS_FACTOR                 = uint64(10)
 	DEFAULT_WASM_MAX_STEPCOUNT              = uint64(8000000)
 
@@ -130,6 +138,74 @@ func GetStateHashCheckHeight(id uint32) uint32 {
 	return STATE_HASH_CHECK_HEIGHT[id]
 }
 
+var STATE_TRIE_HEIGHT = map[uint32]uint32{
+	NETWORK_ID_MAIN_NET:    constants.STATE_TRIE_HEIGHT_MAINNET, //Network main
+	NETWORK_ID_POLARIS_NET: constants.STATE_TRIE_HEIGHT_POLARIS, //Network polaris
+	NETWORK_ID_SOLO_NET:    0,                                   //Network solo
+}
+
+//GetStateTrieHeight returns the height from which the state trie is committed to by the state merkle tree.
+//The networks other than main, polaris and solo use the configured StateTrieHeight, all nodes of such a
+//network must set the same height
+func GetStateTrieHeight(id uint32) uint32 {
+	height, ok := STATE_TRIE_HEIGHT[id]
+	if ok {
+		return height
+	}
+	if DefConfig.Common == nil {
+		return DEFAULT_STATE_TRIE_HEIGHT
+	}
+	return DefConfig.Common.StateTrieHeight
+}
+
+var NATIVE_GATEWAY_HEIGHT = map[uint32]uint32{
+	NETWORK_ID_MAIN_NET:    constants.NATIVE_GATEWAY_HEIGHT_MAINNET, //Network main
+	NETWORK_ID_POLARIS_NET: constants.NATIVE_GATEWAY_HEIGHT_POLARIS, //Network polaris
+	NETWORK_ID_SOLO_NET:    0,                                       //Network solo
+}
+
+//GetNativeGatewayHeight returns the height from which evm can call the native ccntmracts through
+//the native gateway ccntmracts, the gateway is disabled on the other networks
+func GetNativeGatewayHeight(id uint32) uint32 {
+	height, ok := NATIVE_GATEWAY_HEIGHT[id]
+	if ok {
+		return height
+	}
+	return math.MaxUint32
+}
+
+var STORAGE_ITERATOR_HEIGHT = map[uint32]uint32{
+	NETWORK_ID_MAIN_NET:    constants.STORAGE_ITERATOR_HEIGHT_MAINNET, //Network main
+	NETWORK_ID_POLARIS_NET: constants.STORAGE_ITERATOR_HEIGHT_POLARIS, //Network polaris
+	NETWORK_ID_SOLO_NET:    0,                                         //Network solo
+}
+
+//GetStorageIteratorHeight returns the height from which the neovm and wasm ccntmracts can iterate
+//the storage by key prefix, the iterators are disabled on the other networks
+func GetStorageIteratorHeight(id uint32) uint32 {
+	height, ok := STORAGE_ITERATOR_HEIGHT[id]
+	if ok {
+		return height
+	}
+	return math.MaxUint32
+}
+
+var WASM_CRYPTO_HEIGHT = map[uint32]uint32{
+	NETWORK_ID_MAIN_NET:    constants.WASM_CRYPTO_HEIGHT_MAINNET, //Network main
+	NETWORK_ID_POLARIS_NET: constants.WASM_CRYPTO_HEIGHT_POLARIS, //Network polaris
+	NETWORK_ID_SOLO_NET:    0,                                    //Network solo
+}
+
+//GetWasmCryptoHeight returns the height from which the wasm ccntmracts can import the hash, ecrecover,
+//signature verification and bn256 pairing host functions, they are disabled on the other networks
+func GetWasmCryptoHeight(id uint32) uint32 {
+	height, ok := WASM_CRYPTO_HEIGHT[id]
+	if ok {
+		return height
+	}
+	return math.MaxUint32
+}
+
 var OPCODE_HASKEY_ENABLE_HEIGHT = map[uint32]uint32{
 	NETWORK_ID_MAIN_NET:    constants.OPCODE_HEIGHT_UPDATE_FIRST_MAINNET, //Network main
 	NETWORK_ID_POLARIS_NET: constants.OPCODE_HEIGHT_UPDATE_FIRST_POLARIS, //Network polaris
@@ -498,15 +574,24 @@ type DBFTConfig struct {
 type SOLOConfig struct {
 	GenBlockTime uint
 	Bookkeepers  []string
+	DevMode      bool `json:"-"` //Mine on transaction and serve the dev rpc methods, set by the testmode-dev flag
 }
 
 type CommonConfig struct {
 	LogLevel         uint
 	NodeType         string
 	EnableEventLog   bool
+	EnableArchive    bool
+	PruneBlocks      uint32
+	SnapshotInterval uint32
 	SystemFee        map[string]int64
 	GasLimit         uint64
 	GasPrice         uint64
+	TxPoolCapacity   uint32 //Max number of transactions in the tx pool
+	TxPoolPayerLimit uint32 //Max number of transactions of a payer in the tx pool, 0 for no limit
+	TxPoolPriceBump  uint64 //Min gas price bump in percent to replace a transaction of the same payer and nonce
+	TxPoolLifetime   uint32 //Blocks after which a transaction is dropped from the tx pool, 0 to never expire
+	StateTrieHeight  uint32 //Height from which the state trie is committed to on a custom network, MaxUint32 to disable
 	DataDir          string
 	WasmVerifyMethod VerifyMethod
 }
@@ -537,12 +622,19 @@ type P2PNodeConfig struct {
 	MaxConnInBound            uint
 	MaxConnOutBound           uint
 	MaxConnInBoundForSingleIP uint
+	FastSync                  bool
+	RequireEncryption         bool
+	PinnedPeers               []string // <ip>=<peer id> of the peers which must authenticate with the peer id
+	MaxBandwidth              uint     // KB/s sent to all peers, 0 means unlimited
+	MaxPeerBandwidth          uint     // KB/s sent to each peer, 0 means unlimited
 }
 
 type RpcConfig struct {
 	EnableHttpJsonRpc bool
 	HttpJsonPort      uint
 	HttpLocalPort     uint
+	EnableEthRpc      bool
+	EthJsonPort       uint
 }
 
 type RestfulConfig struct {
@@ -553,6 +645,13 @@ type RestfulConfig struct {
 	HttpKeyPath        string
 }
 
+type GraphQLConfig struct {
+	EnableGraphQL  bool
+	GraphQLPort    uint
+	MaxConnections uint
+	AllowedOrigins []string // origins allowed to open subscriptions besides the server host
+}
+
 type WebSocketConfig struct {
 	EnableHttpWs bool
 	HttpWsPort   uint
@@ -579,6 +678,11 @@ func NewCntmConfig() *CntmConfig {
 			EnableEventLog:   DEFAULT_ENABLE_EVENT_LOG,
 			SystemFee:        make(map[string]int64),
 			MinGasLimit:      DEFAULT_MIN_GAS_LIMIT,
+			TxPoolCapacity:   DEFAULT_TX_POOL_CAPACITY,
+			TxPoolPayerLimit: DEFAULT_TX_POOL_PAYER_LIMIT,
+			TxPoolPriceBump:  DEFAULT_TX_POOL_PRICE_BUMP,
+			TxPoolLifetime:   DEFAULT_TX_POOL_LIFETIME,
+			StateTrieHeight:  DEFAULT_STATE_TRIE_HEIGHT,
 			DataDir:          DEFAULT_DATA_DIR,
 			WasmVerifyMethod: InterpVerifyMethod,
 			ETHTxGasLimit:    DEFAULT_ETH_TX_MAX_GAS_LIMIT,
@@ -608,11 +712,15 @@ func NewCntmConfig() *CntmConfig {
 			EnableHttpJsonRpc: true,
 			HttpJsonPort:      DEFAULT_RPC_PORT,
 			HttpLocalPort:     DEFAULT_RPC_LOCAL_PORT,
+			EthJsonPort:       DEFAULT_ETH_RPC_PORT,
 		},
 		Restful: &RestfulConfig{
 			EnableHttpRestful: true,
 			HttpRestPort:      DEFAULT_REST_PORT,
 		},
+		GraphQL: &GraphQLConfig{
+			GraphQLPort: DEFAULT_GRAPHQL_PORT,
+		},
 		Ws: &WebSocketConfig{
 			EnableHttpWs: true,
 			HttpWsPort:   DEFAULT_WS_PORT,
@@ -16,36 +16,25 @@
  * along with The cntm.  If not, see <http://www.gnu.org/licenses/>.
  */
 
-package events
+package config
 
 import (
-	"fmt"
+	"math"
 	"testing"
-)
-
-func TestNewEvent(t *testing.T) {
-	event := NewEvent()
-
-	var subscriber1 EventFunc = func(v interface{}) {
-		fmt.Println("subscriber1 event func.")
-	}
-
-	var subscriber2 EventFunc = func(v interface{}) {
-		fmt.Println("subscriber2 event func.")
-	}
 
-	fmt.Println("Subscribe...")
-	sub1 := event.Subscribe(EventReplyTx, subscriber1)
-	event.Subscribe(EventSaveBlock, subscriber2)
-
-	fmt.Println("Notify...")
-	event.Notify(EventReplyTx, nil)
-
-	fmt.Println("Notify All...")
-	event.NotifyAll()
-
-	event.UnSubscribe(EventReplyTx, sub1)
-	fmt.Println("Notify All after unsubscribe sub1...")
-	event.NotifyAll()
+	"github.com/stretchr/testify/assert"
+)
 
+func TestGetStateTrieHeight(t *testing.T) {
+	assert.Equal(t, uint32(0), GetStateTrieHeight(NETWORK_ID_SOLO_NET))
+
+	//custom networks keep the state trie disabled unless a height is configured
+	assert.Equal(t, uint32(math.MaxUint32), GetStateTrieHeight(1000))
+	old := DefConfig.Common.StateTrieHeight
+	defer func() {
+		DefConfig.Common.StateTrieHeight = old
+	}()
+	DefConfig.Common.StateTrieHeight = 100
+	assert.Equal(t, uint32(100), GetStateTrieHeight(1000))
+	assert.Equal(t, uint32(0), GetStateTrieHeight(NETWORK_ID_SOLO_NET))
 }
@@ -19,6 +19,7 @@
 package constants
 
 import (
+	"math"
 	"time"
 
 	"github.com/laizy/bigint"
@@ -93,6 +94,22 @@ const (
 const STATE_HASH_HEIGHT_MAINNET = 3000000
 const STATE_HASH_HEIGHT_POLARIS = 850000
 
+// ledger state trie height, not scheduled yet
+const STATE_TRIE_HEIGHT_MAINNET = math.MaxUint32
+const STATE_TRIE_HEIGHT_POLARIS = math.MaxUint32
+
+// evm native gateway ccntmracts height, not scheduled yet
+const NATIVE_GATEWAY_HEIGHT_MAINNET = math.MaxUint32
+const NATIVE_GATEWAY_HEIGHT_POLARIS = math.MaxUint32
+
+// neovm and wasm storage iterators height, not scheduled yet
+const STORAGE_ITERATOR_HEIGHT_MAINNET = math.MaxUint32
+const STORAGE_ITERATOR_HEIGHT_POLARIS = math.MaxUint32
+
+// wasm crypto host functions height, not scheduled yet
+const WASM_CRYPTO_HEIGHT_MAINNET = math.MaxUint32
+const WASM_CRYPTO_HEIGHT_POLARIS = math.MaxUint32
+
 // cntmvm opcode update check height
 const OPCODE_HEIGHT_UPDATE_FIRST_MAINNET = 6300000
 const OPCODE_HEIGHT_UPDATE_FIRST_POLARIS = 2100000
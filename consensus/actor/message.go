@@ -28,3 +28,82 @@ type TimeOut struct{}
 type BlockCompleted struct {
 	Block *types.Block
 }
+
+//dev chain requests to the solo consensus, replied with DevRsp
+type DevMine struct {
+	Blocks    uint32
+	Timestamp uint32 //Timestamp of the first block, 0 for the current time
+}
+type DevIncreaseTime struct {
+	Seconds uint32
+}
+type DevSetNextBlockTimestamp struct {
+	Timestamp uint32
+}
+type DevSnapshot struct{}
+type DevRevert struct {
+	SnapshotId uint32
+}
+type DevRsp struct {
+	Value uint64 //Height of the last mined block, time offset in seconds or snapshot id
+	Error error
+}
+
+//GetConsensusStatus requests the live status of the consensus, replied with ConsensusStatus
+type GetConsensusStatus struct{}
+
+type ConsensusStatus struct {
+	State           string
+	Index           uint32 //Index of the node in the chain config
+	Height          uint32 //Height of the block in consensus
+	CommittedHeight uint32
+	View            uint32 //View of the chain config
+	Peers           []*ConsensusPeerStatus
+	Round           *ConsensusRoundStatus
+	Timers          []*ConsensusTimerStatus
+	Latencies       []*BlockLatency //Latencies of the latest sealed blocks, in ascending height
+}
+
+type ConsensusPeerStatus struct {
+	Index           uint32
+	PubKey          string
+	Connected       bool
+	ChainConfigView uint32 //Chain config view reported by the peer
+	CommittedHeight uint32 //Committed height reported by the peer
+	LastHeartbeat   int64  //Unix time in milliseconds of the last heartbeat from the peer, 0 if none
+}
+
+type ConsensusRoundMsg struct {
+	Sender    uint32
+	Proposer  uint32
+	BlockHash string
+	ForEmpty  bool
+}
+
+//ConsensusRoundStatus lists the participants of the round and the messages received from them
+type ConsensusRoundStatus struct {
+	Height       uint32
+	StartTime    int64 //Unix time in milliseconds, 0 if the round is not started
+	Proposers    []uint32
+	Endorsers    []uint32
+	Committers   []uint32
+	Proposals    []*ConsensusRoundMsg
+	Endorsements []*ConsensusRoundMsg
+	Commits      []*ConsensusRoundMsg
+}
+
+type ConsensusTimerStatus struct {
+	Event    string
+	Height   uint32
+	Deadline int64 //Unix time in milliseconds
+}
+
+//BlockLatency is the time in milliseconds spent in each consensus phase of a block, 0 if the phase is skipped
+//such as when the block is fast forwarded
+type BlockLatency struct {
+	Height   uint32
+	Proposal int64 //From the round start to the first proposal
+	Endorse  int64 //From the first proposal to the endorsement quorum
+	Commit   int64 //From the endorsement quorum to the commit quorum
+	Total    int64 //From the round start to the block sealed
+}
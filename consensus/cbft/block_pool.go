@@ -795,6 +795,12 @@ func (pool *BlockPool) getExecMerkleRoot(blkNum uint32) (common.Uint256, error)
 	return pool.chainStore.getExecMerkleRoot(blkNum)
 }
 
+func (pool *BlockPool) getExecStateTrieRoot(blkNum uint32) (*common.Uint256, error) {
+	pool.lock.RLock()
+	defer pool.lock.RUnlock()
+	return pool.chainStore.getExecStateTrieRoot(blkNum)
+}
+
 func (pool *BlockPool) getCrossStatesRoot(blkNum uint32) (common.Uint256, error) {
 	pool.lock.RLock()
 	defer pool.lock.RUnlock()
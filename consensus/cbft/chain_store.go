@@ -91,6 +91,22 @@ func (self *ChainStore) getExecMerkleRoot(blkNum uint32) (common.Uint256, error)
 	}
 }
 
+//getExecStateTrieRoot returns the state trie root after the block, nil before the state trie is enabled
+func (self *ChainStore) getExecStateTrieRoot(blkNum uint32) (*common.Uint256, error) {
+	if blkNum < self.db.GetStateTrieHeight() {
+		return nil, nil
+	}
+	if blk, present := self.pendingBlocks[blkNum]; blk != nil && present && blk.execResult.StateTrieRoot != common.UINT256_EMPTY {
+		root := blk.execResult.StateTrieRoot
+		return &root, nil
+	}
+	root, err := self.db.GetStateTrieRoot(blkNum)
+	if err != nil {
+		return nil, fmt.Errorf("GetStateTrieRoot blockNum:%d, error :%s", blkNum, err)
+	}
+	return &root, nil
+}
+
 func (self *ChainStore) getCrossStatesRoot(blkNum uint32) (common.Uint256, error) {
 	if blk, present := self.pendingBlocks[blkNum]; blk != nil && present {
 		return blk.execResult.CrossStatesRoot, nil
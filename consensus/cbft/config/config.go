@@ -60,6 +60,8 @@ type CbftBlockInfo struct {
 	VrfProof           []byte       `json:"vrf_proof"`
 	LastConfigBlockNum uint32       `json:"last_config_block_num"`
 	NewChainConfig     *ChainConfig `json:"new_chain_config"`
+	// state trie root after the previous block, set since the state trie is enabled
+	PrevStateTrieRoot *common.Uint256 `json:"prev_state_trie_root,omitempty"`
 }
 
 const (
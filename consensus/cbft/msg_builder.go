@@ -267,12 +267,18 @@ func (self *Server) constructProposalMsg(blkNum uint32, sysTxs, userTxs []*types
 	if chainconfig != nil {
 		lastConfigBlkNum = blkNum
 	}
+	//the header commits to the state trie after the previous block, so that a state snapshot can be verified
+	stateTrieRoot, err := self.blockPool.getExecStateTrieRoot(blkNum - 1)
+	if err != nil {
+		return nil, fmt.Errorf("failed to getExecStateTrieRoot: %s,blkNum:%d", err, blkNum-1)
+	}
 	CbftBlkInfo := &vconfig.CbftBlockInfo{
 		Proposer:           self.Index,
 		VrfValue:           vrfValue,
 		VrfProof:           vrfProof,
 		LastConfigBlockNum: lastConfigBlkNum,
 		NewChainConfig:     chainconfig,
+		PrevStateTrieRoot:  stateTrieRoot,
 	}
 	consensusPayload, err := json.Marshal(CbftBlkInfo)
 	if err != nil {
@@ -1130,6 +1130,17 @@ func (self *Server) processProposalMsg(msg *blockProposalMsg) {
 		log.Errorf("BlockPrposalMessage check MerkleRoot blocknum:%d,msg MerkleRoot:%s,self MerkleRoot:%s", msg.GetBlockNum(), msgMerkleRoot.ToHexString(), merkleRoot.ToHexString())
 		return
 	}
+	stateTrieRoot, err := self.blockPool.getExecStateTrieRoot(msgBlkNum - 1)
+	if err != nil {
+		log.Errorf("failed to getExecStateTrieRoot: %s,blkNum:%d", err, msgBlkNum-1)
+		return
+	}
+	if msgRoot := msg.Block.Info.PrevStateTrieRoot; (msgRoot == nil) != (stateTrieRoot == nil) ||
+		(msgRoot != nil && *msgRoot != *stateTrieRoot) {
+		self.msgPool.DropMsg(msg)
+		log.Errorf("BlockPrposalMessage check StateTrieRoot blocknum:%d, state trie root mismatch", msgBlkNum)
+		return
+	}
 	cfg := vconfig.ChainConfig{}
 	if blk.getNewChainConfig() != nil {
 		cfg = *blk.getNewChainConfig()
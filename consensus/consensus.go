@@ -23,8 +23,10 @@ import (
 	"github.com/conntectome/cntm/account"
 	"github.com/conntectome/cntm/common/log"
 	"github.com/conntectome/cntm/consensus/dbft"
+	"github.com/conntectome/cntm/consensus/sbft"
 	"github.com/conntectome/cntm/consensus/solo"
 	"github.com/conntectome/cntm/consensus/Cbft"
+	p2p "github.com/conntectome/cntm/p2pserver/net/protocol"
 )
 
 type ConsensusService interface {
@@ -37,9 +39,13 @@ const (
 	CONSENSUS_DBFT = "dbft"
 	CONSENSUS_SOLO = "solo"
 	CONSENSUS_Cbft = "Cbft"
+	CONSENSUS_SBFT = "sbft"
 )
 
-func NewConsensusService(consensusType string, account *account.Account, txpool *actor.PID, ledger *actor.PID, p2p *actor.PID) (ConsensusService, error) {
+// NewConsensusService creates the consensus service of the type, the Cbft service sends its messages through
+// the p2p actor while the other services send them on the p2p network directly
+func NewConsensusService(consensusType string, account *account.Account, txpool *actor.PID, ledger *actor.PID,
+	p2pPid *actor.PID, net p2p.P2P) (ConsensusService, error) {
 	if consensusType == "" {
 		consensusType = CONSENSUS_DBFT
 	}
@@ -47,11 +53,13 @@ func NewConsensusService(consensusType string, account *account.Account, txpool
 	var err error
 	switch consensusType {
 	case CONSENSUS_DBFT:
-		consensus, err = dbft.NewDbftService(account, txpool, p2p)
+		consensus, err = dbft.NewDbftService(account, txpool, net)
 	case CONSENSUS_SOLO:
 		consensus, err = solo.NewSoloService(account, txpool)
 	case CONSENSUS_Cbft:
-		consensus, err = Cbft.NewCbftServer(account, txpool, p2p)
+		consensus, err = Cbft.NewCbftServer(account, txpool, p2pPid)
+	case CONSENSUS_SBFT:
+		consensus, err = sbft.NewSbftService(account, txpool, net)
 	}
 	log.Infof("ConsensusType:%s", consensusType)
 	return consensus, err
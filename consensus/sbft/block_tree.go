@@ -0,0 +1,96 @@
+/*
+ * Copyright (C) 2018 The cntmology Authors
+ * This file is part of The cntmology library.
+ *
+ * The cntmology is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The cntmology is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * alcntm with The cntmology.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package sbft
+
+import (
+	"github.com/cntmio/cntmology/common"
+	"github.com/cntmio/cntmology/core/types"
+)
+
+type blockNode struct {
+	block   *types.Block
+	view    uint64
+	qc      *QuorumCert // certifies the block, nil until a quorum voted for it
+	justify *QuorumCert // certifies the parent, carried by the proposal
+}
+
+func (node *blockNode) height() uint32 {
+	return node.block.Header.Height
+}
+
+func (node *blockNode) hash() common.Uint256 {
+	return node.block.Hash()
+}
+
+// blockTree keeps the proposed blocks not committed yet, rooted at the last committed block
+type blockTree struct {
+	root  *blockNode
+	nodes map[common.Uint256]*blockNode
+}
+
+func newBlockTree(root *blockNode) *blockTree {
+	tree := &blockTree{
+		root:  root,
+		nodes: make(map[common.Uint256]*blockNode),
+	}
+	tree.nodes[root.hash()] = root
+	return tree
+}
+
+func (tree *blockTree) getNode(hash common.Uint256) *blockNode {
+	return tree.nodes[hash]
+}
+
+func (tree *blockTree) addNode(node *blockNode) {
+	tree.nodes[node.hash()] = node
+}
+
+func (tree *blockTree) parent(node *blockNode) *blockNode {
+	if node == nil || node == tree.root {
+		return nil
+	}
+	return tree.nodes[node.block.Header.PrevBlockHash]
+}
+
+// path returns the uncommitted blocks from the child of the root to the node, nil if the node does not extend the root
+func (tree *blockTree) path(node *blockNode) []*blockNode {
+	var nodes []*blockNode
+	for n := node; n != tree.root; n = tree.parent(n) {
+		if n == nil {
+			return nil
+		}
+		nodes = append([]*blockNode{n}, nodes...)
+	}
+	return nodes
+}
+
+// extends checks the node is the root or descends from it
+func (tree *blockTree) extends(node *blockNode) bool {
+	return node == tree.root || tree.path(node) != nil
+}
+
+// setRoot commits the node, blocks not descending from it are dropped
+func (tree *blockTree) setRoot(root *blockNode) {
+	tree.root = root
+	for hash, node := range tree.nodes {
+		if node != root && (node.height() <= root.height() || tree.path(node) == nil) {
+			delete(tree.nodes, hash)
+		}
+	}
+}
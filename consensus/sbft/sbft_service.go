@@ -18,13 +18,929 @@
 
 package sbft
 
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/cntmio/cntmology-crypto/keypair"
+	"github.com/cntmio/cntmology-eventbus/actor"
+	"github.com/cntmio/cntmology/account"
+	"github.com/cntmio/cntmology/common"
+	"github.com/cntmio/cntmology/common/log"
+	actorTypes "github.com/cntmio/cntmology/consensus/actor"
+	"github.com/cntmio/cntmology/core/ledger"
+	"github.com/cntmio/cntmology/core/signature"
+	"github.com/cntmio/cntmology/core/types"
+	msgpack "github.com/cntmio/cntmology/p2pserver/message/msg_pack"
+	p2pmsg "github.com/cntmio/cntmology/p2pserver/message/types"
+	p2p "github.com/cntmio/cntmology/p2pserver/net/protocol"
+	txpool "github.com/cntmio/cntmology/txnpool/common"
+)
+
+const (
+	CAP_MESSAGE_CHANNEL = 4096
+	MAX_PENDING_BLOCKS  = 64
+	MAX_VOTE_VIEWS      = 16 // views ahead of the current view whose votes are collected
+	LEADER_TERM         = 2  // views led by a leader in a row
+	BLOCK_INTERVAL      = time.Second
+	VIEW_TIMEOUT        = 4 * time.Second
+	MAX_VIEW_TIMEOUT    = time.Minute
+)
+
+// pendingBlock is a block received before its parent, it is processed once the parent is fetched
+type pendingBlock struct {
+	from     uint32
+	view     uint64
+	block    *types.Block
+	justify  *QuorumCert
+	proposal bool
+}
+
+type voteSet struct {
+	height uint32
+	sigs   map[uint32][]byte
+}
+
+// SbftService is a chained BFT engine: the leader of each view proposes a block extending the highest
+// certified block, validators vote for it to the leader of the next view, which certifies it with a quorum
+// of votes in its own proposal. A block is committed once it heads three certified blocks of consecutive views.
 type SbftService struct {
+	account   *account.Account
+	ledger    Ledger
+	poolActor TxPool
+	p2p       p2p.P2P
+	pid       *actor.PID
+
+	blockInterval  time.Duration
+	viewTimeout    time.Duration
+	maxViewTimeout time.Duration
+
+	// the states below are only accessed by the loop goroutine
+	validators []keypair.PublicKey
+	index      int // index of the account in validators, -1 if not a validator
+	quorum     int
+
+	tree      *blockTree
+	view      uint64
+	lastVoted uint64
+	preferred uint64
+	highQC    *QuorumCert
+	proposed  uint64
+	scheduled uint64
+	timeouts  uint
+
+	votes    map[uint64]map[common.Uint256]*voteSet // votes by view and block hash
+	newViews map[uint64]map[uint32]*newViewMsg
+	pending  map[common.Uint256][]*pendingBlock
+
+	viewTimer    *time.Timer
+	proposeTimer *time.Timer
+
+	msgC    chan *p2pmsg.ConsensusPayload
+	quitC   chan struct{}
+	quitWg  sync.WaitGroup
+	started bool
+	lock    sync.Mutex
+}
+
+func NewSbftService(account *account.Account, txpool *actor.PID, p2p p2p.P2P) (*SbftService, error) {
+	service := newSbftService(account, ledger.DefLedger, &actorTypes.TxPoolActor{Pool: txpool}, p2p)
+
+	props := actor.FromProducer(func() actor.Actor {
+		return service
+	})
+	pid, err := actor.SpawnNamed(props, "consensus_sbft")
+	if err != nil {
+		return nil, err
+	}
+	service.pid = pid
+	return service, nil
+}
+
+func newSbftService(account *account.Account, ledger Ledger, txpool TxPool, p2p p2p.P2P) *SbftService {
+	return &SbftService{
+		account:        account,
+		ledger:         ledger,
+		poolActor:      txpool,
+		p2p:            p2p,
+		blockInterval:  BLOCK_INTERVAL,
+		viewTimeout:    VIEW_TIMEOUT,
+		maxViewTimeout: MAX_VIEW_TIMEOUT,
+		index:          -1,
+	}
+}
+
+func (self *SbftService) Receive(ccntmext actor.Ccntmext) {
+	switch msg := ccntmext.Message().(type) {
+	case *actor.Restarting:
+		log.Info("sbft actor restarting")
+	case *actor.Stopping:
+		log.Info("sbft actor stopping")
+	case *actor.Stopped:
+		log.Info("sbft actor stopped")
+	case *actor.Started:
+		log.Info("sbft actor started")
+	case *actor.Restart:
+		log.Info("sbft actor restart")
+	case *actorTypes.StartConsensus:
+		if err := self.start(); err != nil {
+			log.Errorf("sbft actor start consensus: %s", err)
+		}
+	case *actorTypes.StopConsensus:
+		self.stop()
+	case *p2pmsg.ConsensusPayload:
+		self.onConsensusPayload(msg)
+	default:
+		log.Info("sbft actor: Unknown msg ", msg, "type", reflect.TypeOf(msg))
+	}
+}
+
+func (self *SbftService) GetPID() *actor.PID {
+	return self.pid
+}
+
+func (self *SbftService) Start() error {
+	return self.start()
+}
+
+func (self *SbftService) Halt() error {
+	self.pid.Tell(&actorTypes.StopConsensus{})
+	return nil
+}
+
+func (self *SbftService) start() error {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	if self.started {
+		return nil
+	}
+	self.msgC = make(chan *p2pmsg.ConsensusPayload, CAP_MESSAGE_CHANNEL)
+	self.quitC = make(chan struct{})
+	self.viewTimer = time.NewTimer(self.viewTimeout)
+	self.proposeTimer = time.NewTimer(self.blockInterval)
+	stopTimer(self.proposeTimer)
+	if err := self.resetFromLedger(); err != nil {
+		return fmt.Errorf("sbft service start failed: %s", err)
+	}
+	self.started = true
+
+	self.quitWg.Add(1)
+	go self.loop()
+	return nil
+}
+
+func (self *SbftService) stop() {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	if !self.started {
+		return
+	}
+	self.started = false
+	close(self.quitC)
+	self.quitWg.Wait()
+	stopTimer(self.viewTimer)
+	stopTimer(self.proposeTimer)
+}
+
+func (self *SbftService) onConsensusPayload(payload *p2pmsg.ConsensusPayload) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	if !self.started {
+		return
+	}
+	select {
+	case self.msgC <- payload:
+	default:
+		log.Warnf("sbft msg channel full, drop msg from %s", payload.PeerId.ToHexString())
+	}
+}
+
+func (self *SbftService) loop() {
+	defer self.quitWg.Done()
+
+	self.tryPropose(false)
+	for {
+		select {
+		case payload := <-self.msgC:
+			self.processPayload(payload)
+		case <-self.viewTimer.C:
+			self.onViewTimeout()
+		case <-self.proposeTimer.C:
+			self.tryPropose(true)
+		case <-self.quitC:
+			return
+		}
+	}
+}
+
+func stopTimer(timer *time.Timer) {
+	if !timer.Stop() {
+		select {
+		case <-timer.C:
+		default:
+		}
+	}
+}
+
+func resetTimer(timer *time.Timer, d time.Duration) {
+	stopTimer(timer)
+	timer.Reset(d)
+}
+
+// resetFromLedger restarts the consensus from the current block of the ledger
+func (self *SbftService) resetFromLedger() error {
+	height := self.ledger.GetCurrentBlockHeight()
+	block, err := self.ledger.GetBlockByHeight(height)
+	if err != nil {
+		return fmt.Errorf("get block %d: %s", height, err)
+	}
+	state, err := self.ledger.GetBookkeeperState()
+	if err != nil {
+		return fmt.Errorf("get bookkeeper state: %s", err)
+	}
+	if len(state.CurrBookkeeper) == 0 {
+		return fmt.Errorf("empty bookkeepers")
+	}
+	self.validators = state.CurrBookkeeper
+	self.quorum = len(self.validators) - (len(self.validators)-1)/3
+	self.index = self.getIndex(self.account.PublicKey)
+
+	info := getBlockInfo(block)
+	root := &blockNode{
+		block: block,
+		view:  info.View,
+	}
+	// the committed block is trusted, its qc carries no signature
+	root.qc = &QuorumCert{
+		View:      info.View,
+		Height:    height,
+		BlockHash: block.Hash(),
+	}
+	self.tree = newBlockTree(root)
+	self.highQC = root.qc
+	self.votes = make(map[uint64]map[common.Uint256]*voteSet)
+	self.newViews = make(map[uint64]map[uint32]*newViewMsg)
+	self.pending = make(map[common.Uint256][]*pendingBlock)
+	if self.lastVoted < info.View {
+		self.lastVoted = info.View
+	}
+	if self.preferred < info.View {
+		self.preferred = info.View
+	}
+	if self.view <= info.View {
+		self.view = info.View + 1
+	}
+	resetTimer(self.viewTimer, self.currentViewTimeout())
+	log.Infof("sbft reset from block %d, view %d, validator %d of %d", height, self.view, self.index,
+		len(self.validators))
+	return nil
+}
+
+func (self *SbftService) getIndex(pubkey keypair.PublicKey) int {
+	key := keypair.SerializePublicKey(pubkey)
+	for i, validator := range self.validators {
+		if bytes.Equal(keypair.SerializePublicKey(validator), key) {
+			return i
+		}
+	}
+	return -1
+}
+
+// leader rotates every LEADER_TERM views, two live leaders in a row make three certified blocks of consecutive
+// views, so the chain commits with up to f validators down
+func (self *SbftService) leader(view uint64) uint32 {
+	return uint32(view / LEADER_TERM % uint64(len(self.validators)))
+}
+
+func (self *SbftService) isLeader(view uint64) bool {
+	return self.index >= 0 && self.leader(view) == uint32(self.index)
 }
 
-func (ss *SbftService) Halt() error {
+func (self *SbftService) currentViewTimeout() time.Duration {
+	timeout := self.viewTimeout
+	for i := uint(0); i < self.timeouts && timeout < self.maxViewTimeout; i++ {
+		timeout *= 2
+	}
+	if timeout > self.maxViewTimeout {
+		timeout = self.maxViewTimeout
+	}
+	return timeout
+}
+
+func (self *SbftService) enterView(view uint64) {
+	if view <= self.view {
+		return
+	}
+	self.view = view
+	for v := range self.newViews {
+		if v < view {
+			delete(self.newViews, v)
+		}
+	}
+	// the votes of the previous view certify the proposal the node leads the view with
+	for v := range self.votes {
+		if v+1 < view {
+			delete(self.votes, v)
+		}
+	}
+	stopTimer(self.proposeTimer)
+	resetTimer(self.viewTimer, self.currentViewTimeout())
+	log.Debugf("sbft enter view %d", view)
+	self.tryPropose(false)
+}
+
+func (self *SbftService) onViewTimeout() {
+	// the ledger may be synced by the p2p block sync when the node falls behind
+	if self.ledger.GetCurrentBlockHeight() > self.tree.root.height() {
+		if err := self.resetFromLedger(); err != nil {
+			log.Errorf("sbft reset from ledger: %s", err)
+		}
+	}
+	self.timeouts++
+	view := self.view + 1
+	log.Infof("sbft view %d timeout, move to view %d", self.view, view)
+	if self.index >= 0 {
+		msg := &newViewMsg{
+			View:   view,
+			HighQC: self.highQC,
+		}
+		self.broadcast(NewViewMessage, msg)
+		self.processNewView(uint32(self.index), msg)
+	}
+	self.enterView(view)
+}
+
+func (self *SbftService) processPayload(payload *p2pmsg.ConsensusPayload) {
+	index := self.getIndex(payload.Owner)
+	if index < 0 {
+		log.Debugf("sbft drop msg from non validator %s", payload.PeerId.ToHexString())
+		return
+	}
+	from := uint32(index)
+	msgType, msg, err := DeserializeSbftMsg(payload.Data)
+	if err != nil {
+		log.Warnf("sbft deserialize msg from %d: %s", from, err)
+		return
+	}
+	switch msgType {
+	case ProposalMessage:
+		m := msg.(*proposalMsg)
+		block, err := types.BlockFromRawBytes(m.Block)
+		if err != nil {
+			log.Warnf("sbft proposal from %d: %s", from, err)
+			return
+		}
+		if err := self.processBlock(from, payload, &pendingBlock{from, m.View, block, m.Justify, true}); err != nil {
+			log.Warnf("sbft proposal of view %d from %d: %s", m.View, from, err)
+		}
+	case VoteMessage:
+		if err := self.processVote(from, msg.(*voteMsg)); err != nil {
+			log.Warnf("sbft vote from %d: %s", from, err)
+		}
+	case NewViewMessage:
+		m := msg.(*newViewMsg)
+		if m.HighQC != nil && self.tree.getNode(m.HighQC.BlockHash) == nil && m.HighQC.Height > self.tree.root.height() {
+			self.fetchBlock(payload, m.HighQC.BlockHash)
+		}
+		self.processNewView(from, m)
+	case BlockFetchMessage:
+		self.processBlockFetch(payload, msg.(*blockFetchMsg))
+	case BlockFetchRespMessage:
+		m := msg.(*blockFetchRespMsg)
+		block, err := types.BlockFromRawBytes(m.Block)
+		if err != nil {
+			log.Warnf("sbft block fetch resp from %d: %s", from, err)
+			return
+		}
+		// only the blocks certified by verified qcs are fetched, the hash authenticates the block
+		if _, present := self.pending[block.Hash()]; !present {
+			return
+		}
+		if err := self.processBlock(from, payload, &pendingBlock{from, m.View, block, m.Justify, false}); err != nil {
+			log.Warnf("sbft fetched block of view %d from %d: %s", m.View, from, err)
+		}
+	}
+}
+
+func (self *SbftService) verifyQC(qc *QuorumCert) error {
+	if qc == nil {
+		return fmt.Errorf("nil qc")
+	}
+	root := self.tree.root
+	if qc.Height > root.height() {
+		return qc.verify(self.validators, self.quorum)
+	}
+	// qcs of committed blocks are checked against the ledger
+	hash := root.hash()
+	if qc.Height < root.height() {
+		block, err := self.ledger.GetBlockByHeight(qc.Height)
+		if err != nil {
+			return fmt.Errorf("get block %d: %s", qc.Height, err)
+		}
+		hash = block.Hash()
+	}
+	if hash != qc.BlockHash {
+		return fmt.Errorf("qc of block %d conflicts with the ledger", qc.Height)
+	}
+	return nil
+}
+
+// processBlock adds a proposed or fetched block to the tree, and votes for the proposal of the current view
+func (self *SbftService) processBlock(from uint32, payload *p2pmsg.ConsensusPayload, b *pendingBlock) error {
+	block := b.block
+	hash := block.Hash()
+	if self.tree.getNode(hash) != nil {
+		return nil
+	}
+	if b.proposal && from != self.leader(b.view) {
+		return fmt.Errorf("proposer %d is not the leader", from)
+	}
+	info := getBlockInfo(block)
+	if info.View != b.view || info.Proposer != self.leader(b.view) {
+		return fmt.Errorf("invalid block info, view %d proposer %d", info.View, info.Proposer)
+	}
+	justify := b.justify
+	if justify == nil {
+		return fmt.Errorf("nil justify")
+	}
+	if block.Header.Height != justify.Height+1 || block.Header.PrevBlockHash != justify.BlockHash {
+		return fmt.Errorf("block %d does not extend the justify of block %d", block.Header.Height, justify.Height)
+	}
+	if b.view <= justify.View {
+		return fmt.Errorf("view %d not higher than justify view %d", b.view, justify.View)
+	}
+	if err := self.verifyQC(justify); err != nil {
+		return fmt.Errorf("verify justify: %s", err)
+	}
+
+	parent := self.tree.getNode(justify.BlockHash)
+	if parent == nil {
+		if justify.Height <= self.tree.root.height() {
+			return fmt.Errorf("block %d forks the committed chain", block.Header.Height)
+		}
+		if len(self.pending) >= MAX_PENDING_BLOCKS {
+			return fmt.Errorf("too many pending blocks")
+		}
+		self.pending[justify.BlockHash] = append(self.pending[justify.BlockHash], b)
+		self.fetchBlock(payload, justify.BlockHash)
+		return nil
+	}
+	if parent.view != justify.View {
+		return fmt.Errorf("justify view %d mismatch parent view %d", justify.View, parent.view)
+	}
+	if !self.tree.extends(parent) {
+		return fmt.Errorf("parent block %d does not extend the committed block", parent.height())
+	}
+	if parent.qc == nil {
+		parent.qc = justify
+	}
+	self.processQC(justify)
+
+	if err := self.validateBlock(parent, block); err != nil {
+		return err
+	}
+	node := &blockNode{
+		block:   block,
+		view:    b.view,
+		justify: justify,
+	}
+	self.tree.addNode(node)
+	self.tryFormQC(hash)
+
+	children := self.pending[hash]
+	delete(self.pending, hash)
+	for _, child := range children {
+		if err := self.processBlock(child.from, payload, child); err != nil {
+			log.Warnf("sbft pending block of view %d from %d: %s", child.view, child.from, err)
+		}
+	}
+
+	if b.proposal && b.view == self.view {
+		self.vote(node)
+	}
 	return nil
 }
 
-func (ds *SbftService) Start() error {
+func (self *SbftService) validateBlock(parent *blockNode, block *types.Block) error {
+	header := block.Header
+	if header.Timestamp <= parent.block.Header.Timestamp {
+		return fmt.Errorf("block timestamp %d not later than parent %d", header.Timestamp,
+			parent.block.Header.Timestamp)
+	}
+	txHashes := make([]common.Uint256, 0, len(block.Transactions))
+	for _, tx := range block.Transactions {
+		txHashes = append(txHashes, tx.Hash())
+	}
+	txRoot := common.ComputeMerkleRoot(txHashes)
+	if header.TransactionsRoot != txRoot {
+		return fmt.Errorf("transactions root mismatch")
+	}
+
+	ancestors := self.tree.path(parent)
+	txRoots := make([]common.Uint256, 0, len(ancestors)+1)
+	included := make(map[common.Uint256]bool)
+	for _, node := range ancestors {
+		txRoots = append(txRoots, node.block.Header.TransactionsRoot)
+		for _, tx := range node.block.Transactions {
+			included[tx.Hash()] = true
+		}
+	}
+	txRoots = append(txRoots, txRoot)
+	blockRoot := self.ledger.GetBlockRootWithNewTxRoots(self.tree.root.height()+1, txRoots)
+	if header.BlockRoot != blockRoot {
+		return fmt.Errorf("block root mismatch")
+	}
+
+	for _, hash := range txHashes {
+		if included[hash] {
+			return fmt.Errorf("duplicated transaction %s", hash.ToHexString())
+		}
+		included[hash] = true
+	}
+	if len(block.Transactions) > 0 {
+		err := self.poolActor.VerifyBlock(block.Transactions, self.tree.root.height())
+		if err != nil && err != actor.ErrTimeout {
+			return fmt.Errorf("verify transactions: %s", err)
+		} else if err == actor.ErrTimeout {
+			log.Warnf("sbft verify transactions of block %d timeout", header.Height)
+		}
+	}
 	return nil
 }
+
+func (self *SbftService) vote(node *blockNode) {
+	if self.index < 0 || node.view <= self.lastVoted || node.justify.View < self.preferred {
+		return
+	}
+	hash := node.hash()
+	sig, err := signature.Sign(self.account, hash[:])
+	if err != nil {
+		log.Errorf("sbft sign vote: %s", err)
+		return
+	}
+	self.lastVoted = node.view
+	msg := &voteMsg{
+		View:      node.view,
+		Height:    node.height(),
+		BlockHash: hash,
+		Sig:       sig,
+	}
+	self.broadcast(VoteMessage, msg)
+	if err := self.processVote(uint32(self.index), msg); err != nil {
+		log.Errorf("sbft process own vote: %s", err)
+	}
+}
+
+// processVote collects the votes for the blocks of which the node leads the next view. Only the votes of
+// the views from the previous one to MAX_VOTE_VIEWS ahead are kept, and a validator votes once in a view.
+func (self *SbftService) processVote(from uint32, msg *voteMsg) error {
+	if !self.isLeader(msg.View+1) || msg.Height <= self.tree.root.height() {
+		return nil
+	}
+	if msg.View+1 < self.view || msg.View > self.view+MAX_VOTE_VIEWS {
+		return nil
+	}
+	views := self.votes[msg.View]
+	for hash, votes := range views {
+		if _, present := votes.sigs[from]; present {
+			if hash != msg.BlockHash {
+				return fmt.Errorf("double vote in view %d", msg.View)
+			}
+			return nil
+		}
+	}
+	if err := signature.Verify(self.validators[from], msg.BlockHash[:], msg.Sig); err != nil {
+		return fmt.Errorf("verify vote sig: %s", err)
+	}
+	if views == nil {
+		views = make(map[common.Uint256]*voteSet)
+		self.votes[msg.View] = views
+	}
+	votes := views[msg.BlockHash]
+	if votes == nil {
+		votes = &voteSet{
+			height: msg.Height,
+			sigs:   make(map[uint32][]byte),
+		}
+		views[msg.BlockHash] = votes
+	}
+	votes.sigs[from] = msg.Sig
+	self.tryFormQC(msg.BlockHash)
+	return nil
+}
+
+// tryFormQC certifies the block once it is received and voted by a quorum
+func (self *SbftService) tryFormQC(hash common.Uint256) {
+	node := self.tree.getNode(hash)
+	if node == nil || node.qc != nil {
+		return
+	}
+	votes := self.votes[node.view][hash]
+	if votes == nil || len(votes.sigs) < self.quorum {
+		return
+	}
+	qc := &QuorumCert{
+		View:      node.view,
+		Height:    node.height(),
+		BlockHash: hash,
+	}
+	for signer := range votes.sigs {
+		qc.Signers = append(qc.Signers, signer)
+	}
+	sort.Slice(qc.Signers, func(i, j int) bool {
+		return qc.Signers[i] < qc.Signers[j]
+	})
+	for _, signer := range qc.Signers {
+		qc.Sigs = append(qc.Sigs, votes.sigs[signer])
+	}
+	delete(self.votes[node.view], hash)
+	node.qc = qc
+	self.processQC(qc)
+}
+
+// processQC updates the high qc and the preferred view, commits the head of a 3-chain of consecutive views
+// and moves to the view after the qc
+func (self *SbftService) processQC(qc *QuorumCert) {
+	node := self.tree.getNode(qc.BlockHash)
+	if node == nil || node.view != qc.View {
+		return
+	}
+	if node.qc == nil {
+		node.qc = qc
+	}
+	if qc.View > self.highQC.View {
+		self.highQC = qc
+	}
+	if node.justify != nil && node.justify.View > self.preferred {
+		self.preferred = node.justify.View
+	}
+
+	parent := self.tree.parent(node)
+	if parent != nil && parent != self.tree.root {
+		grand := self.tree.parent(parent)
+		if grand != nil && grand != self.tree.root && node.view == parent.view+1 && parent.view == grand.view+1 {
+			self.commit(grand)
+		}
+	}
+	self.enterView(qc.View + 1)
+}
+
+func (self *SbftService) commit(node *blockNode) {
+	for _, n := range self.tree.path(node) {
+		block := n.block
+		height := n.height()
+		if height <= self.ledger.GetCurrentBlockHeight() {
+			// the block is synced by the p2p block sync
+			saved, err := self.ledger.GetBlockByHeight(height)
+			if err != nil || saved.Hash() != n.hash() {
+				log.Errorf("sbft committed block %d conflicts with the ledger", height)
+				self.reset()
+				return
+			}
+			self.tree.setRoot(n)
+			continue
+		}
+		bookkeepers := make([]keypair.PublicKey, 0, len(n.qc.Signers))
+		for _, signer := range n.qc.Signers {
+			bookkeepers = append(bookkeepers, self.validators[signer])
+		}
+		block.Header.Bookkeepers = bookkeepers
+		block.Header.SigData = n.qc.Sigs
+
+		result, err := self.ledger.ExecuteBlock(block)
+		if err != nil {
+			log.Errorf("sbft execute block %d: %s", height, err)
+			self.reset()
+			return
+		}
+		if err := self.ledger.SubmitBlock(block, nil, result); err != nil {
+			log.Errorf("sbft submit block %d: %s", height, err)
+			self.reset()
+			return
+		}
+		log.Infof("sbft committed block %d, view %d, %d txs", height, n.view, len(block.Transactions))
+		self.tree.setRoot(n)
+	}
+	self.timeouts = 0
+	for view, votes := range self.votes {
+		for hash, set := range votes {
+			if set.height <= node.height() {
+				delete(votes, hash)
+			}
+		}
+		if len(votes) == 0 {
+			delete(self.votes, view)
+		}
+	}
+	for hash, blocks := range self.pending {
+		if len(blocks) == 0 || blocks[0].justify.Height <= node.height() {
+			delete(self.pending, hash)
+		}
+	}
+}
+
+func (self *SbftService) reset() {
+	if err := self.resetFromLedger(); err != nil {
+		log.Errorf("sbft reset from ledger: %s", err)
+	}
+}
+
+// processNewView collects the new views of the view the node leads, and follows the view once f+1
+// validators moved to it
+func (self *SbftService) processNewView(from uint32, msg *newViewMsg) {
+	if msg.HighQC != nil {
+		if err := self.verifyQC(msg.HighQC); err != nil {
+			log.Warnf("sbft new view from %d: verify high qc: %s", from, err)
+			return
+		}
+		self.processQC(msg.HighQC)
+	}
+	if msg.View < self.view {
+		return
+	}
+	views := self.newViews[msg.View]
+	if views == nil {
+		views = make(map[uint32]*newViewMsg)
+		self.newViews[msg.View] = views
+	}
+	views[from] = msg
+	if msg.View > self.view && len(views) > len(self.validators)-self.quorum {
+		self.enterView(msg.View)
+		return
+	}
+	self.tryPropose(false)
+}
+
+func (self *SbftService) processBlockFetch(payload *p2pmsg.ConsensusPayload, msg *blockFetchMsg) {
+	node := self.tree.getNode(msg.BlockHash)
+	if node == nil || node.justify == nil {
+		return
+	}
+	self.sendTo(payload, BlockFetchRespMessage, &blockFetchRespMsg{
+		View:    node.view,
+		Block:   node.block.ToArray(),
+		Justify: node.justify,
+	})
+}
+
+func (self *SbftService) fetchBlock(payload *p2pmsg.ConsensusPayload, hash common.Uint256) {
+	if _, present := self.pending[hash]; !present {
+		if len(self.pending) >= MAX_PENDING_BLOCKS {
+			return
+		}
+		self.pending[hash] = nil
+	}
+	self.sendTo(payload, BlockFetchMessage, &blockFetchMsg{BlockHash: hash})
+}
+
+// tryPropose proposes a block once the leader gets the qc of the previous view or a quorum of new views.
+// Empty blocks are delayed by the block interval unless they are needed to commit the pending transactions.
+func (self *SbftService) tryPropose(force bool) {
+	if !self.isLeader(self.view) || self.proposed >= self.view {
+		return
+	}
+	parent := self.tree.getNode(self.highQC.BlockHash)
+	if parent == nil {
+		return
+	}
+	if self.highQC.View+1 != self.view && len(self.newViews[self.view]) < self.quorum {
+		return
+	}
+
+	ancestors := self.tree.path(parent)
+	txs := self.poolActor.GetTxnPool(true, self.tree.root.height())
+	if !force && len(txs) == 0 {
+		pendingTxs := false
+		for _, node := range ancestors {
+			if len(node.block.Transactions) > 0 {
+				pendingTxs = true
+				break
+			}
+		}
+		if !pendingTxs {
+			if self.scheduled != self.view {
+				self.scheduled = self.view
+				resetTimer(self.proposeTimer, self.blockInterval)
+			}
+			return
+		}
+	}
+
+	block, err := self.makeBlock(parent, ancestors, txs)
+	if err != nil {
+		log.Errorf("sbft make block of view %d: %s", self.view, err)
+		return
+	}
+	self.proposed = self.view
+	msg := &proposalMsg{
+		View:    self.view,
+		Block:   block.ToArray(),
+		Justify: self.highQC,
+	}
+	log.Infof("sbft propose block %d of view %d, %d txs", block.Header.Height, self.view, len(block.Transactions))
+	self.broadcast(ProposalMessage, msg)
+	err = self.processBlock(uint32(self.index), nil, &pendingBlock{uint32(self.index), self.view, block, self.highQC, true})
+	if err != nil {
+		log.Errorf("sbft process own proposal: %s", err)
+	}
+}
+
+func (self *SbftService) makeBlock(parent *blockNode, ancestors []*blockNode, txs []*txpool.VerifiedTx) (*types.Block, error) {
+	nextBookkeeper, err := types.AddressFromBookkeepers(self.validators)
+	if err != nil {
+		return nil, fmt.Errorf("GetBookkeeperAddress error:%s", err)
+	}
+	payload, err := json.Marshal(&SbftBlockInfo{
+		View:     self.view,
+		Proposer: uint32(self.index),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	included := make(map[common.Uint256]bool)
+	txRoots := make([]common.Uint256, 0, len(ancestors)+1)
+	for _, node := range ancestors {
+		txRoots = append(txRoots, node.block.Header.TransactionsRoot)
+		for _, tx := range node.block.Transactions {
+			included[tx.Hash()] = true
+		}
+	}
+	transactions := make([]*types.Transaction, 0, len(txs))
+	txHashes := make([]common.Uint256, 0, len(txs))
+	for _, entry := range txs {
+		hash := entry.Tx.Hash()
+		if included[hash] {
+			continue
+		}
+		included[hash] = true
+		transactions = append(transactions, entry.Tx)
+		txHashes = append(txHashes, hash)
+	}
+	txRoot := common.ComputeMerkleRoot(txHashes)
+	txRoots = append(txRoots, txRoot)
+
+	timestamp := uint32(time.Now().Unix())
+	if timestamp <= parent.block.Header.Timestamp {
+		timestamp = parent.block.Header.Timestamp + 1
+	}
+	header := &types.Header{
+		PrevBlockHash:    parent.hash(),
+		TransactionsRoot: txRoot,
+		BlockRoot:        self.ledger.GetBlockRootWithNewTxRoots(self.tree.root.height()+1, txRoots),
+		Timestamp:        timestamp,
+		Height:           parent.height() + 1,
+		ConsensusData:    common.GetNonce(),
+		ConsensusPayload: payload,
+		NextBookkeeper:   nextBookkeeper,
+	}
+	return &types.Block{
+		Header:       header,
+		Transactions: transactions,
+	}, nil
+}
+
+func (self *SbftService) newPayload(msgType MsgType, msg interface{}) (*p2pmsg.ConsensusPayload, error) {
+	data, err := SerializeSbftMsg(msgType, msg)
+	if err != nil {
+		return nil, err
+	}
+	payload := &p2pmsg.ConsensusPayload{
+		Data:  data,
+		Owner: self.account.PublicKey,
+	}
+	sink := common.NewZeroCopySink(nil)
+	payload.SerializationUnsigned(sink)
+	payload.Signature, err = signature.Sign(self.account, sink.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	return payload, nil
+}
+
+func (self *SbftService) broadcast(msgType MsgType, msg interface{}) {
+	payload, err := self.newPayload(msgType, msg)
+	if err != nil {
+		log.Errorf("sbft broadcast msg type %d: %s", msgType, err)
+		return
+	}
+	go self.p2p.Broadcast(msgpack.NewConsensus(payload))
+}
+
+// sendTo replies to the peer sent the payload
+func (self *SbftService) sendTo(to *p2pmsg.ConsensusPayload, msgType MsgType, msg interface{}) {
+	if to == nil {
+		return
+	}
+	payload, err := self.newPayload(msgType, msg)
+	if err != nil {
+		log.Errorf("sbft send msg type %d: %s", msgType, err)
+		return
+	}
+	go self.p2p.SendTo(to.PeerId, msgpack.NewConsensus(payload))
+}
@@ -0,0 +1,325 @@
+/*
+ * Copyright (C) 2018 The cntmology Authors
+ * This file is part of The cntmology library.
+ *
+ * The cntmology is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The cntmology is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * alcntm with The cntmology.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package sbft
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cntmio/cntmology-crypto/keypair"
+	"github.com/cntmio/cntmology-eventbus/actor"
+	"github.com/cntmio/cntmology/account"
+	"github.com/cntmio/cntmology/common"
+	"github.com/cntmio/cntmology/core/signature"
+	"github.com/cntmio/cntmology/core/states"
+	"github.com/cntmio/cntmology/core/store"
+	"github.com/cntmio/cntmology/core/types"
+	p2pcom "github.com/cntmio/cntmology/p2pserver/common"
+	msgTypes "github.com/cntmio/cntmology/p2pserver/message/types"
+	"github.com/cntmio/cntmology/p2pserver/mock"
+	"github.com/cntmio/cntmology/p2pserver/net/netserver"
+	p2p "github.com/cntmio/cntmology/p2pserver/net/protocol"
+	"github.com/cntmio/cntmology/p2pserver/peer"
+	txpool "github.com/cntmio/cntmology/txnpool/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func init() {
+	p2pcom.Difficulty = 1
+}
+
+type mockLedger struct {
+	lock        sync.RWMutex
+	blocks      []*types.Block
+	txRoots     []common.Uint256
+	bookkeepers []keypair.PublicKey
+	submitted   chan struct{} // closed and renewed on each submitted block
+}
+
+func newMockLedger(genesis *types.Block, bookkeepers []keypair.PublicKey) *mockLedger {
+	return &mockLedger{
+		blocks:      []*types.Block{genesis},
+		txRoots:     []common.Uint256{genesis.Header.TransactionsRoot},
+		bookkeepers: bookkeepers,
+		submitted:   make(chan struct{}),
+	}
+}
+
+// waitHeight blocks until the ledger reaches the height or the deadline passes
+func (self *mockLedger) waitHeight(height uint32, deadline <-chan time.Time) bool {
+	for {
+		self.lock.RLock()
+		current, submitted := uint32(len(self.blocks)-1), self.submitted
+		self.lock.RUnlock()
+		if current >= height {
+			return true
+		}
+		select {
+		case <-submitted:
+		case <-deadline:
+			return false
+		}
+	}
+}
+
+func (self *mockLedger) GetCurrentBlockHeight() uint32 {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+	return uint32(len(self.blocks) - 1)
+}
+
+func (self *mockLedger) GetBlockByHeight(height uint32) (*types.Block, error) {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+	if int(height) >= len(self.blocks) {
+		return nil, fmt.Errorf("block %d not found", height)
+	}
+	return self.blocks[height], nil
+}
+
+func (self *mockLedger) GetBookkeeperState() (*states.BookkeeperState, error) {
+	return &states.BookkeeperState{
+		CurrBookkeeper: self.bookkeepers,
+		NextBookkeeper: self.bookkeepers,
+	}, nil
+}
+
+func (self *mockLedger) GetBlockRootWithNewTxRoots(startHeight uint32, txRoots []common.Uint256) common.Uint256 {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+	roots := append([]common.Uint256{}, self.txRoots[:startHeight]...)
+	return common.ComputeMerkleRoot(append(roots, txRoots...))
+}
+
+func (self *mockLedger) ExecuteBlock(b *types.Block) (store.ExecuteResult, error) {
+	return store.ExecuteResult{}, nil
+}
+
+func (self *mockLedger) SubmitBlock(b *types.Block, crossChainMsg *types.CrossChainMsg, exec store.ExecuteResult) error {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	prev := self.blocks[len(self.blocks)-1]
+	if b.Header.Height != prev.Header.Height+1 || b.Header.PrevBlockHash != prev.Hash() {
+		return fmt.Errorf("block %d does not extend block %d", b.Header.Height, prev.Header.Height)
+	}
+	self.blocks = append(self.blocks, b)
+	self.txRoots = append(self.txRoots, b.Header.TransactionsRoot)
+	close(self.submitted)
+	self.submitted = make(chan struct{})
+	return nil
+}
+
+type mockTxPool struct{}
+
+func (self *mockTxPool) GetTxnPool(byCount bool, height uint32) []*txpool.VerifiedTx {
+	return nil
+}
+
+func (self *mockTxPool) VerifyBlock(txs []*types.Transaction, height uint32) error {
+	return nil
+}
+
+// consensusProtocol hands the consensus messages to the service as the p2p msg handler does
+type consensusProtocol struct {
+	service   *SbftService
+	connected chan struct{}
+}
+
+func (self *consensusProtocol) HandleSystemMessage(net p2p.P2P, msg p2p.SystemMessage) {
+	if _, ok := msg.(p2p.PeerConnected); ok {
+		select {
+		case self.connected <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (self *consensusProtocol) HandlePeerMessage(ctx *p2p.Ccntmext, msg msgTypes.Message) {
+	if m, ok := msg.(*msgTypes.Consensus); ok {
+		if err := m.Cons.Verify(); err != nil {
+			return
+		}
+		m.Cons.PeerId = ctx.Sender().GetID()
+		self.service.GetPID().Tell(&m.Cons)
+	}
+}
+
+type testNode struct {
+	server  *netserver.NetServer
+	ledger  *mockLedger
+	service *SbftService
+}
+
+func newTestNodes(t *testing.T, n int) []*testNode {
+	accounts := make([]*account.Account, 0, n)
+	bookkeepers := make([]keypair.PublicKey, 0, n)
+	for i := 0; i < n; i++ {
+		acc := account.NewAccount("")
+		accounts = append(accounts, acc)
+		bookkeepers = append(bookkeepers, acc.PublicKey)
+	}
+	genesis := &types.Block{
+		Header: &types.Header{
+			Timestamp:        uint32(time.Now().Unix()) - 100,
+			TransactionsRoot: common.ComputeMerkleRoot(nil),
+		},
+	}
+	genesis.Hash()
+
+	net := mock.NewNetwork()
+	nodes := make([]*testNode, 0, n)
+	protos := make([]*consensusProtocol, 0, n)
+	for i := 0; i < n; i++ {
+		keyId := p2pcom.RandPeerKeyId()
+		info := peer.NewPeerInfo(keyId.Id, 0, 0, true, 0, 0, 0, "1.10", "")
+		proto := &consensusProtocol{connected: make(chan struct{}, n)}
+		server := mock.NewNode(keyId, "", info, proto, net, nil, p2p.AllAddrFilter(), p2pcom.NewGlobalLoggerWrapper())
+		ledger := newMockLedger(genesis, bookkeepers)
+		service := newSbftService(accounts[i], ledger, &mockTxPool{}, server)
+		service.blockInterval = 50 * time.Millisecond
+		service.viewTimeout = 500 * time.Millisecond
+		service.maxViewTimeout = 2 * time.Second
+		service.pid = actor.Spawn(actor.FromProducer(func() actor.Actor {
+			return service
+		}))
+		proto.service = service
+		protos = append(protos, proto)
+		nodes = append(nodes, &testNode{server: server, ledger: ledger, service: service})
+	}
+	for i := 0; i < n; i++ {
+		for j := i + 1; j < n; j++ {
+			net.AllowConnect(nodes[i].server.GetID(), nodes[j].server.GetID())
+		}
+	}
+	for _, node := range nodes {
+		assert.Nil(t, node.server.Start())
+	}
+	for i := 0; i < n; i++ {
+		for j := i + 1; j < n; j++ {
+			nodes[i].server.Connect(nodes[j].server.GetHostInfo().Addr)
+		}
+	}
+	deadline := time.After(5 * time.Second)
+	for _, proto := range protos {
+		for i := 0; i < n-1; i++ {
+			select {
+			case <-proto.connected:
+			case <-deadline:
+				t.Fatal("nodes not connected")
+			}
+		}
+	}
+	return nodes
+}
+
+func waitHeight(nodes []*testNode, height uint32, timeout time.Duration) bool {
+	deadline := time.After(timeout)
+	for _, node := range nodes {
+		if !node.ledger.waitHeight(height, deadline) {
+			return false
+		}
+	}
+	return true
+}
+
+func checkSameChain(t *testing.T, nodes []*testNode, quorum int) {
+	height := nodes[0].ledger.GetCurrentBlockHeight()
+	for _, node := range nodes[1:] {
+		if h := node.ledger.GetCurrentBlockHeight(); h < height {
+			height = h
+		}
+	}
+	for h := uint32(1); h <= height; h++ {
+		block, err := nodes[0].ledger.GetBlockByHeight(h)
+		assert.Nil(t, err)
+		assert.True(t, len(block.Header.SigData) >= quorum)
+		for _, node := range nodes[1:] {
+			other, err := node.ledger.GetBlockByHeight(h)
+			assert.Nil(t, err)
+			assert.Equal(t, block.Hash(), other.Hash())
+		}
+	}
+}
+
+func TestSbftConsensus(t *testing.T) {
+	nodes := newTestNodes(t, 4)
+	for _, node := range nodes {
+		assert.Nil(t, node.service.Start())
+	}
+	defer func() {
+		for _, node := range nodes {
+			node.service.stop()
+			node.server.Stop()
+		}
+	}()
+
+	assert.True(t, waitHeight(nodes, 6, 20*time.Second))
+	checkSameChain(t, nodes, 3)
+
+	//the chain keeps growing with one of the four validators down
+	nodes[3].service.stop()
+	alive := nodes[:3]
+	height := alive[0].ledger.GetCurrentBlockHeight()
+	assert.True(t, waitHeight(alive, height+4, 30*time.Second))
+	checkSameChain(t, alive, 3)
+}
+
+func TestProcessVote(t *testing.T) {
+	accounts := make([]*account.Account, 0, 4)
+	bookkeepers := make([]keypair.PublicKey, 0, 4)
+	for i := 0; i < 4; i++ {
+		acc := account.NewAccount("")
+		accounts = append(accounts, acc)
+		bookkeepers = append(bookkeepers, acc.PublicKey)
+	}
+	genesis := &types.Block{
+		Header: &types.Header{TransactionsRoot: common.ComputeMerkleRoot(nil)},
+	}
+	service := newSbftService(accounts[0], newMockLedger(genesis, bookkeepers), &mockTxPool{}, nil)
+	service.viewTimer = time.NewTimer(time.Hour)
+	service.proposeTimer = time.NewTimer(time.Hour)
+	defer stopTimer(service.viewTimer)
+	defer stopTimer(service.proposeTimer)
+	assert.Nil(t, service.resetFromLedger())
+
+	newVote := func(from int, view uint64, hash common.Uint256) *voteMsg {
+		sig, err := signature.Sign(accounts[from], hash[:])
+		assert.Nil(t, err)
+		return &voteMsg{View: view, Height: 1, BlockHash: hash, Sig: sig}
+	}
+	// validator 0 leads the views 8 and 9
+	hash := common.Uint256{1}
+	assert.Nil(t, service.processVote(1, newVote(1, 7, hash)))
+	assert.Nil(t, service.processVote(1, newVote(1, 7, hash)))
+	assert.Equal(t, 1, len(service.votes[7][hash].sigs))
+	assert.NotNil(t, service.processVote(1, newVote(1, 7, common.Uint256{2})))
+	assert.Equal(t, 1, len(service.votes[7]))
+
+	// the votes of views too far ahead are dropped
+	assert.Nil(t, service.processVote(1, newVote(1, 40, hash)))
+	assert.Nil(t, service.votes[40])
+
+	// the votes of the views before the previous one are pruned and no longer collected
+	service.enterView(9)
+	assert.Equal(t, 0, len(service.votes))
+	assert.Nil(t, service.processVote(2, newVote(2, 7, hash)))
+	assert.Equal(t, 0, len(service.votes))
+}
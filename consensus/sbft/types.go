@@ -0,0 +1,179 @@
+/*
+ * Copyright (C) 2018 The cntmology Authors
+ * This file is part of The cntmology library.
+ *
+ * The cntmology is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The cntmology is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * alcntm with The cntmology.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package sbft
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/cntmio/cntmology-crypto/keypair"
+	"github.com/cntmio/cntmology/common"
+	"github.com/cntmio/cntmology/core/signature"
+	"github.com/cntmio/cntmology/core/states"
+	"github.com/cntmio/cntmology/core/store"
+	"github.com/cntmio/cntmology/core/types"
+	txpool "github.com/cntmio/cntmology/txnpool/common"
+)
+
+// Ledger is the part of the ledger the engine works on, ledger.DefLedger satisfies it
+type Ledger interface {
+	GetCurrentBlockHeight() uint32
+	GetBlockByHeight(height uint32) (*types.Block, error)
+	GetBookkeeperState() (*states.BookkeeperState, error)
+	GetBlockRootWithNewTxRoots(startHeight uint32, txRoots []common.Uint256) common.Uint256
+	ExecuteBlock(b *types.Block) (store.ExecuteResult, error)
+	SubmitBlock(b *types.Block, crossChainMsg *types.CrossChainMsg, exec store.ExecuteResult) error
+}
+
+// TxPool is the part of the txnpool actor the engine works on, actor.TxPoolActor satisfies it
+type TxPool interface {
+	GetTxnPool(byCount bool, height uint32) []*txpool.VerifiedTx
+	VerifyBlock(txs []*types.Transaction, height uint32) error
+}
+
+// SbftBlockInfo is the consensus payload of the block header
+type SbftBlockInfo struct {
+	View     uint64 `json:"view"`
+	Proposer uint32 `json:"proposer"`
+}
+
+// getBlockInfo returns the sbft info of the block, blocks not made by sbft such as the genesis block are of view 0
+func getBlockInfo(block *types.Block) *SbftBlockInfo {
+	info := &SbftBlockInfo{}
+	if err := json.Unmarshal(block.Header.ConsensusPayload, info); err != nil {
+		return &SbftBlockInfo{}
+	}
+	return info
+}
+
+// QuorumCert certifies a block with the votes of a quorum of validators, votes are signatures of the block hash
+type QuorumCert struct {
+	View      uint64         `json:"view"`
+	Height    uint32         `json:"height"`
+	BlockHash common.Uint256 `json:"block_hash"`
+	Signers   []uint32       `json:"signers"`
+	Sigs      [][]byte       `json:"sigs"`
+}
+
+func (qc *QuorumCert) verify(validators []keypair.PublicKey, quorum int) error {
+	if len(qc.Signers) != len(qc.Sigs) {
+		return fmt.Errorf("%d signers with %d sigs", len(qc.Signers), len(qc.Sigs))
+	}
+	signed := make(map[uint32]bool)
+	for i, signer := range qc.Signers {
+		if int(signer) >= len(validators) {
+			return fmt.Errorf("invalid signer %d", signer)
+		}
+		if signed[signer] {
+			return fmt.Errorf("duplicated signer %d", signer)
+		}
+		if err := signature.Verify(validators[signer], qc.BlockHash[:], qc.Sigs[i]); err != nil {
+			return fmt.Errorf("verify sig of signer %d: %s", signer, err)
+		}
+		signed[signer] = true
+	}
+	if len(signed) < quorum {
+		return fmt.Errorf("%d signers less than quorum %d", len(signed), quorum)
+	}
+	return nil
+}
+
+type MsgType uint8
+
+const (
+	ProposalMessage MsgType = iota
+	VoteMessage
+	NewViewMessage
+	BlockFetchMessage
+	BlockFetchRespMessage
+)
+
+type SbftMsgPayload struct {
+	Type    MsgType `json:"type"`
+	Payload []byte  `json:"payload"`
+}
+
+// proposalMsg proposes a block of the view extending the block certified by the justify qc
+type proposalMsg struct {
+	View    uint64      `json:"view"`
+	Block   []byte      `json:"block"`
+	Justify *QuorumCert `json:"justify"`
+}
+
+// voteMsg votes for a block, it is sent to the leader of the next view
+type voteMsg struct {
+	View      uint64         `json:"view"`
+	Height    uint32         `json:"height"`
+	BlockHash common.Uint256 `json:"block_hash"`
+	Sig       []byte         `json:"sig"`
+}
+
+// newViewMsg is sent on view timeout, it carries the highest qc of the sender to the leader of the view
+type newViewMsg struct {
+	View   uint64      `json:"view"`
+	HighQC *QuorumCert `json:"high_qc"`
+}
+
+type blockFetchMsg struct {
+	BlockHash common.Uint256 `json:"block_hash"`
+}
+
+// blockFetchRespMsg returns a block with the qc certifying its parent
+type blockFetchRespMsg struct {
+	View    uint64      `json:"view"`
+	Block   []byte      `json:"block"`
+	Justify *QuorumCert `json:"justify"`
+}
+
+func SerializeSbftMsg(msgType MsgType, msg interface{}) ([]byte, error) {
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(&SbftMsgPayload{
+		Type:    msgType,
+		Payload: payload,
+	})
+}
+
+func DeserializeSbftMsg(data []byte) (MsgType, interface{}, error) {
+	m := &SbftMsgPayload{}
+	if err := json.Unmarshal(data, m); err != nil {
+		return 0, nil, fmt.Errorf("unmarshal sbft msg payload: %s", err)
+	}
+	var msg interface{}
+	switch m.Type {
+	case ProposalMessage:
+		msg = &proposalMsg{}
+	case VoteMessage:
+		msg = &voteMsg{}
+	case NewViewMessage:
+		msg = &newViewMsg{}
+	case BlockFetchMessage:
+		msg = &blockFetchMsg{}
+	case BlockFetchRespMessage:
+		msg = &blockFetchRespMsg{}
+	default:
+		return 0, nil, fmt.Errorf("unknown msg type: %d", m.Type)
+	}
+	if err := json.Unmarshal(m.Payload, msg); err != nil {
+		return 0, nil, fmt.Errorf("failed to unmarshal msg (type: %d): %s", m.Type, err)
+	}
+	return m.Type, msg, nil
+}
@@ -0,0 +1,164 @@
+/*
+ * Copyright (C) 2018 The cntm Authors
+ * This file is part of The cntm library.
+ *
+ * The cntm is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The cntm is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The cntm.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package solo
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/conntectome/cntm-eventbus/actor"
+	actorTypes "github.com/conntectome/cntm/consensus/actor"
+	"github.com/conntectome/cntm/core/ledger"
+)
+
+//DEV_MAX_MINE_BLOCKS is the max number of blocks mined by one dev mine request
+const DEV_MAX_MINE_BLOCKS = 1000
+
+//devSnapshot is the dev chain saved by a snapshot request
+type devSnapshot struct {
+	height     uint32
+	timeOffset int64
+}
+
+func respond(context actor.Context, rsp *actorTypes.DevRsp) {
+	if context.Sender() != nil {
+		context.Sender().Request(rsp, context.Self())
+	}
+}
+
+//blockTimestamp returns the timestamp of the block after height. In dev mode it is shifted by the time offset or
+//set by the next block timestamp, and is kept after the timestamp of the previous block.
+func (self *SoloService) blockTimestamp(height uint32) (uint32, error) {
+	now := time.Now().Unix()
+	if !self.devMode {
+		return uint32(now), nil
+	}
+	timestamp := now + self.timeOffset
+	if self.nextTimestamp != 0 {
+		timestamp = int64(self.nextTimestamp)
+	}
+	prev, err := ledger.DefLedger.GetHeaderByHeight(height)
+	if err != nil {
+		return 0, fmt.Errorf("GetHeaderByHeight height:%d error:%s", height, err)
+	}
+	if timestamp <= int64(prev.Timestamp) {
+		timestamp = int64(prev.Timestamp) + 1
+	}
+	return uint32(timestamp), nil
+}
+
+//mineTxs mines a block of the transactions in the tx pool on the add of a transaction. The transactions added
+//together are mined by the first block, no empty block is mined for the others.
+func (self *SoloService) mineTxs() error {
+	if !self.devMode {
+		return nil
+	}
+	block, err := self.makeBlock()
+	if err != nil {
+		return fmt.Errorf("makeBlock error %s", err)
+	}
+	if len(block.Transactions) == 0 {
+		return nil
+	}
+	return self.submitBlock(block)
+}
+
+func (self *SoloService) devMine(msg *actorTypes.DevMine) *actorTypes.DevRsp {
+	if !self.devMode {
+		return &actorTypes.DevRsp{Error: fmt.Errorf("solo consensus is not in dev mode")}
+	}
+	blocks := msg.Blocks
+	if blocks == 0 {
+		blocks = 1
+	}
+	if blocks > DEV_MAX_MINE_BLOCKS {
+		return &actorTypes.DevRsp{Error: fmt.Errorf("can not mine more than %d blocks at once", DEV_MAX_MINE_BLOCKS)}
+	}
+	if msg.Timestamp != 0 {
+		if rsp := self.devSetNextBlockTimestamp(&actorTypes.DevSetNextBlockTimestamp{Timestamp: msg.Timestamp}); rsp.Error != nil {
+			return rsp
+		}
+	}
+	for i := uint32(0); i < blocks; i++ {
+		if err := self.genBlock(); err != nil {
+			return &actorTypes.DevRsp{Error: err}
+		}
+	}
+	return &actorTypes.DevRsp{Value: uint64(ledger.DefLedger.GetCurrentBlockHeight())}
+}
+
+func (self *SoloService) devIncreaseTime(msg *actorTypes.DevIncreaseTime) *actorTypes.DevRsp {
+	if !self.devMode {
+		return &actorTypes.DevRsp{Error: fmt.Errorf("solo consensus is not in dev mode")}
+	}
+	self.timeOffset += int64(msg.Seconds)
+	return &actorTypes.DevRsp{Value: uint64(self.timeOffset)}
+}
+
+func (self *SoloService) devSetNextBlockTimestamp(msg *actorTypes.DevSetNextBlockTimestamp) *actorTypes.DevRsp {
+	if !self.devMode {
+		return &actorTypes.DevRsp{Error: fmt.Errorf("solo consensus is not in dev mode")}
+	}
+	height := ledger.DefLedger.GetCurrentBlockHeight()
+	prev, err := ledger.DefLedger.GetHeaderByHeight(height)
+	if err != nil {
+		return &actorTypes.DevRsp{Error: fmt.Errorf("GetHeaderByHeight height:%d error:%s", height, err)}
+	}
+	if msg.Timestamp <= prev.Timestamp {
+		return &actorTypes.DevRsp{Error: fmt.Errorf("timestamp %d is not after the timestamp %d of block %d",
+			msg.Timestamp, prev.Timestamp, height)}
+	}
+	self.nextTimestamp = msg.Timestamp
+	return &actorTypes.DevRsp{Value: uint64(msg.Timestamp)}
+}
+
+func (self *SoloService) devSnapshot() *actorTypes.DevRsp {
+	if !self.devMode {
+		return &actorTypes.DevRsp{Error: fmt.Errorf("solo consensus is not in dev mode")}
+	}
+	self.lastSnapshotId++
+	self.snapshots[self.lastSnapshotId] = &devSnapshot{
+		height:     ledger.DefLedger.GetCurrentBlockHeight(),
+		timeOffset: self.timeOffset,
+	}
+	return &actorTypes.DevRsp{Value: uint64(self.lastSnapshotId)}
+}
+
+//devRevert reverts the dev chain to the snapshot, the snapshot and the ones taken after it are dropped
+func (self *SoloService) devRevert(msg *actorTypes.DevRevert) *actorTypes.DevRsp {
+	if !self.devMode {
+		return &actorTypes.DevRsp{Error: fmt.Errorf("solo consensus is not in dev mode")}
+	}
+	snapshot, ok := self.snapshots[msg.SnapshotId]
+	if !ok {
+		return &actorTypes.DevRsp{Error: fmt.Errorf("snapshot %d not found", msg.SnapshotId)}
+	}
+	if err := ledger.DefLedger.RevertToHeight(snapshot.height); err != nil {
+		return &actorTypes.DevRsp{Error: fmt.Errorf("RevertToHeight height:%d error:%s", snapshot.height, err)}
+	}
+	for id := range self.snapshots {
+		if id >= msg.SnapshotId {
+			delete(self.snapshots, id)
+		}
+	}
+	self.timeOffset = snapshot.timeOffset
+	self.nextTimestamp = 0
+	self.incrValidator.Clean()
+	return &actorTypes.DevRsp{Value: uint64(snapshot.height)}
+}
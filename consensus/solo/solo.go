@@ -51,6 +51,11 @@ type SoloService struct {
 	genBlockInterval time.Duration
 	pid              *actor.PID
 	sub              *events.ActorSubscriber
+	devMode          bool                    //Mine on transaction and serve the dev requests instead of the block timer
+	timeOffset       int64                   //Seconds added to the time of the blocks in dev mode
+	nextTimestamp    uint32                  //Timestamp of the next block in dev mode, 0 if not set
+	snapshots        map[uint32]*devSnapshot //Snapshot id => snapshot of the dev chain
+	lastSnapshotId   uint32
 }
 
 func NewSoloService(bkAccount *account.Account, txpool *actor.PID) (*SoloService, error) {
@@ -59,6 +64,8 @@ func NewSoloService(bkAccount *account.Account, txpool *actor.PID) (*SoloService
 		poolActor:        &actorTypes.TxPoolActor{Pool: txpool},
 		incrValidator:    increment.NewIncrementValidator(20),
 		genBlockInterval: time.Duration(config.DefConfig.Genesis.SOLO.GenBlockTime) * time.Second,
+		devMode:          config.DefConfig.Genesis.SOLO.DevMode,
+		snapshots:        make(map[uint32]*devSnapshot),
 	}
 
 	props := actor.FromProducer(func() actor.Actor {
@@ -92,8 +99,13 @@ func (self *SoloService) Receive(context actor.Context) {
 
 		self.sub.Subscribe(message.TOPIC_SAVE_BLOCK_COMPLETE)
 
-		timer := time.NewTicker(self.genBlockInterval)
 		self.existCh = make(chan interface{})
+		if self.devMode {
+			log.Info("solo consensus starts in dev mode, blocks are mined on transaction")
+			self.sub.Subscribe(message.TOPIC_TXN_POOL_ADD)
+			return
+		}
+		timer := time.NewTicker(self.genBlockInterval)
 		go func() {
 			defer timer.Stop()
 			existCh := self.existCh
@@ -112,6 +124,9 @@ func (self *SoloService) Receive(context actor.Context) {
 			self.existCh = nil
 			self.incrValidator.Clean()
 			self.sub.Unsubscribe(message.TOPIC_SAVE_BLOCK_COMPLETE)
+			if self.devMode {
+				self.sub.Unsubscribe(message.TOPIC_TXN_POOL_ADD)
+			}
 		}
 	case *message.SaveBlockCompleteMsg:
 		log.Infof("solo actor receives block complete event. block height=%d txnum=%d", msg.Block.Header.Height, len(msg.Block.Transactions))
@@ -122,6 +137,21 @@ func (self *SoloService) Receive(context actor.Context) {
 		if err != nil {
 			log.Errorf("Solo genBlock error %s", err)
 		}
+	case *message.TxnPoolAddMsg:
+		err := self.mineTxs()
+		if err != nil {
+			log.Errorf("Solo mineTxs error %s", err)
+		}
+	case *actorTypes.DevMine:
+		respond(context, self.devMine(msg))
+	case *actorTypes.DevIncreaseTime:
+		respond(context, self.devIncreaseTime(msg))
+	case *actorTypes.DevSetNextBlockTimestamp:
+		respond(context, self.devSetNextBlockTimestamp(msg))
+	case *actorTypes.DevSnapshot:
+		respond(context, self.devSnapshot())
+	case *actorTypes.DevRevert:
+		respond(context, self.devRevert(msg))
 	default:
 		log.Info("solo actor: Unknown msg ", msg, "type", reflect.TypeOf(msg))
 	}
@@ -146,7 +176,10 @@ func (self *SoloService) genBlock() error {
 	if err != nil {
 		return fmt.Errorf("makeBlock error %s", err)
 	}
+	return self.submitBlock(block)
+}
 
+func (self *SoloService) submitBlock(block *types.Block) error {
 	result, err := ledger.DefLedger.ExecuteBlock(block)
 	if err != nil {
 		return fmt.Errorf("genBlock DefLedgerPid.RequestFuture Height:%d error:%s", block.Header.Height, err)
@@ -171,6 +204,11 @@ func (self *SoloService) genBlock() error {
 	if err != nil {
 		return fmt.Errorf("genBlock DefLedgerPid.RequestFuture Height:%d error:%s", block.Header.Height, err)
 	}
+	if self.nextTimestamp != 0 {
+		//the blocks after the one of the set timestamp go on from it
+		self.timeOffset = int64(self.nextTimestamp) - time.Now().Unix()
+		self.nextTimestamp = 0
+	}
 	return nil
 }
 
@@ -214,12 +252,16 @@ func (self *SoloService) makeBlock() (*types.Block, error) {
 	txRoot := common.ComputeMerkleRoot(txHash)
 
 	blockRoot := ledger.DefLedger.GetBlockRootWithNewTxRoots(height+1, []common.Uint256{txRoot})
+	timestamp, err := self.blockTimestamp(height)
+	if err != nil {
+		return nil, err
+	}
 	header := &types.Header{
 		Version:          ContextVersion,
 		PrevBlockHash:    prevHash,
 		TransactionsRoot: txRoot,
 		BlockRoot:        blockRoot,
-		Timestamp:        uint32(time.Now().Unix()),
+		Timestamp:        timestamp,
 		Height:           height + 1,
 		ConsensusData:    common.GetNonce(),
 		NextBookkeeper:   nextBookkeeper,
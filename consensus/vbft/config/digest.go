@@ -0,0 +1,65 @@
+/*
+ * Copyright (C) 2018 The cntmology Authors
+ * This file is part of The cntmology library.
+ *
+ * The cntmology is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The cntmology is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * alcntm with The cntmology.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package vconfig
+
+import (
+	"crypto/sha256"
+
+	"github.com/cntmio/cntmology/common"
+)
+
+// prefixes of the digests signed by consensus peers besides the block hashes, they keep the digests
+// apart from each other and from the block signatures
+const (
+	PROPOSAL_DIGEST_PREFIX    = "vbft-propose"
+	ENDORSEMENT_DIGEST_PREFIX = "vbft-endorse"
+	SUBMIT_DIGEST_PREFIX      = "vbft-submit"
+)
+
+// ProposalDigest returns the digest a proposer signs for its proposal. It binds the block and the empty block
+// of the proposal to the height and the view, so two proposals of the same round prove the proposer equivocated.
+func ProposalDigest(view, height uint32, blockHash, emptyBlockHash common.Uint256) common.Uint256 {
+	sink := common.NewZeroCopySink(nil)
+	sink.WriteBytes([]byte(PROPOSAL_DIGEST_PREFIX))
+	sink.WriteUint32(view)
+	sink.WriteUint32(height)
+	sink.WriteHash(blockHash)
+	sink.WriteHash(emptyBlockHash)
+	return sha256.Sum256(sink.Bytes())
+}
+
+// EndorsementDigest returns the digest an endorser signs besides the block hash. It binds the endorsed block to
+// the height and the view, so two endorsements of different blocks in a round prove the endorser equivocated.
+func EndorsementDigest(view, height uint32, blockHash common.Uint256) common.Uint256 {
+	sink := common.NewZeroCopySink(nil)
+	sink.WriteBytes([]byte(ENDORSEMENT_DIGEST_PREFIX))
+	sink.WriteUint32(view)
+	sink.WriteUint32(height)
+	sink.WriteHash(blockHash)
+	return sha256.Sum256(sink.Bytes())
+}
+
+// SubmitDigest returns the digest a peer signs for the state root of the block at height it submits
+func SubmitDigest(height uint32, stateRoot common.Uint256) common.Uint256 {
+	sink := common.NewZeroCopySink(nil)
+	sink.WriteBytes([]byte(SUBMIT_DIGEST_PREFIX))
+	sink.WriteUint32(height)
+	sink.WriteHash(stateRoot)
+	return sha256.Sum256(sink.Bytes())
+}
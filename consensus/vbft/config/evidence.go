@@ -0,0 +1,259 @@
+/*
+ * Copyright (C) 2018 The cntmology Authors
+ * This file is part of The cntmology library.
+ *
+ * The cntmology is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The cntmology is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * alcntm with The cntmology.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package vconfig
+
+import (
+	"encoding/hex"
+	"fmt"
+
+	"github.com/cntmio/cntmology-crypto/keypair"
+	"github.com/cntmio/cntmology/common"
+	"github.com/cntmio/cntmology/core/signature"
+)
+
+const (
+	//key prefix of the evidences processed by the governance ccntmract
+	EVIDENCE_KEY_PREFIX = "evidence"
+
+	//evidence type
+	EVIDENCE_DOUBLE_PROPOSAL uint8 = 1
+	EVIDENCE_DOUBLE_ENDORSE  uint8 = 2
+)
+
+//Proposal is a block proposal signed over its digest
+type Proposal struct {
+	View           uint32
+	Height         uint32
+	BlockHash      common.Uint256
+	EmptyBlockHash common.Uint256
+	Sig            []byte
+}
+
+func (this *Proposal) Verify(pubkey keypair.PublicKey) error {
+	digest := ProposalDigest(this.View, this.Height, this.BlockHash, this.EmptyBlockHash)
+	return signature.Verify(pubkey, digest[:], this.Sig)
+}
+
+func (this *Proposal) Serialization(sink *common.ZeroCopySink) {
+	sink.WriteUint32(this.View)
+	sink.WriteUint32(this.Height)
+	sink.WriteHash(this.BlockHash)
+	sink.WriteHash(this.EmptyBlockHash)
+	sink.WriteVarBytes(this.Sig)
+}
+
+func (this *Proposal) Deserialization(source *common.ZeroCopySource) error {
+	var eof, irregular bool
+	this.View, eof = source.NextUint32()
+	this.Height, eof = source.NextUint32()
+	this.BlockHash, eof = source.NextHash()
+	this.EmptyBlockHash, eof = source.NextHash()
+	if eof {
+		return fmt.Errorf("source.NextHash, deserialize emptyBlockHash error")
+	}
+	this.Sig, _, irregular, eof = source.NextVarBytes()
+	if irregular || eof {
+		return fmt.Errorf("source.NextVarBytes, deserialize sig irregular:%v, eof: %v", irregular, eof)
+	}
+	return nil
+}
+
+//Endorsement is a block endorsement signed over its digest
+type Endorsement struct {
+	View      uint32
+	Height    uint32
+	BlockHash common.Uint256
+	Sig       []byte
+}
+
+func (this *Endorsement) Verify(pubkey keypair.PublicKey) error {
+	digest := EndorsementDigest(this.View, this.Height, this.BlockHash)
+	return signature.Verify(pubkey, digest[:], this.Sig)
+}
+
+func (this *Endorsement) Serialization(sink *common.ZeroCopySink) {
+	sink.WriteUint32(this.View)
+	sink.WriteUint32(this.Height)
+	sink.WriteHash(this.BlockHash)
+	sink.WriteVarBytes(this.Sig)
+}
+
+func (this *Endorsement) Deserialization(source *common.ZeroCopySource) error {
+	var eof, irregular bool
+	this.View, eof = source.NextUint32()
+	this.Height, eof = source.NextUint32()
+	this.BlockHash, eof = source.NextHash()
+	if eof {
+		return fmt.Errorf("source.NextHash, deserialize blockHash error")
+	}
+	this.Sig, _, irregular, eof = source.NextVarBytes()
+	if irregular || eof {
+		return fmt.Errorf("source.NextVarBytes, deserialize sig irregular:%v, eof: %v", irregular, eof)
+	}
+	return nil
+}
+
+//Evidence proves a consensus peer signed conflicting proposals or endorsements in the round of a height and a view.
+//Both are proved by two digests of the round signed by the peer, so evidence is bound to the round and a peer
+//restarted in a later view is not slashed for the proposals of an earlier one.
+type Evidence struct {
+	Type         uint8
+	PeerPubkey   string
+	Height       uint32
+	View         uint32
+	Proposals    []*Proposal
+	Endorsements []*Endorsement
+}
+
+//Verify checks the evidence against the public key of the accused peer
+func (this *Evidence) Verify() error {
+	pubkeyBytes, err := hex.DecodeString(this.PeerPubkey)
+	if err != nil {
+		return fmt.Errorf("hex.DecodeString, peerPubkey format error: %v", err)
+	}
+	pubkey, err := keypair.DeserializePublicKey(pubkeyBytes)
+	if err != nil {
+		return fmt.Errorf("keypair.DeserializePublicKey, peerPubkey format error: %v", err)
+	}
+	switch this.Type {
+	case EVIDENCE_DOUBLE_PROPOSAL:
+		return this.verifyDoubleProposal(pubkey)
+	case EVIDENCE_DOUBLE_ENDORSE:
+		return this.verifyDoubleEndorse(pubkey)
+	}
+	return fmt.Errorf("unknown evidence type %d", this.Type)
+}
+
+func (this *Evidence) verifyDoubleProposal(pubkey keypair.PublicKey) error {
+	if len(this.Proposals) != 2 || len(this.Endorsements) != 0 {
+		return fmt.Errorf("double proposal needs 2 proposals, got %d", len(this.Proposals))
+	}
+	first, second := this.Proposals[0], this.Proposals[1]
+	for _, proposal := range this.Proposals {
+		if proposal.Height != this.Height || proposal.View != this.View {
+			return fmt.Errorf("proposal of height %d view %d, evidence of height %d view %d",
+				proposal.Height, proposal.View, this.Height, this.View)
+		}
+	}
+	if first.BlockHash == second.BlockHash && first.EmptyBlockHash == second.EmptyBlockHash {
+		return fmt.Errorf("proposals of the same blocks")
+	}
+	for _, proposal := range this.Proposals {
+		if err := proposal.Verify(pubkey); err != nil {
+			return fmt.Errorf("verify proposal signature error: %v", err)
+		}
+	}
+	return nil
+}
+
+func (this *Evidence) verifyDoubleEndorse(pubkey keypair.PublicKey) error {
+	if len(this.Endorsements) != 2 || len(this.Proposals) != 0 {
+		return fmt.Errorf("double endorse needs 2 endorsements, got %d", len(this.Endorsements))
+	}
+	first, second := this.Endorsements[0], this.Endorsements[1]
+	for _, endorsement := range this.Endorsements {
+		if endorsement.Height != this.Height || endorsement.View != this.View {
+			return fmt.Errorf("endorsement of height %d view %d, evidence of height %d view %d",
+				endorsement.Height, endorsement.View, this.Height, this.View)
+		}
+	}
+	if first.BlockHash == second.BlockHash {
+		return fmt.Errorf("endorsements of the same block")
+	}
+	for _, endorsement := range this.Endorsements {
+		if err := endorsement.Verify(pubkey); err != nil {
+			return fmt.Errorf("verify endorsement signature error: %v", err)
+		}
+	}
+	return nil
+}
+
+func (this *Evidence) Serialization(sink *common.ZeroCopySink) {
+	sink.WriteUint8(this.Type)
+	sink.WriteString(this.PeerPubkey)
+	sink.WriteUint32(this.Height)
+	sink.WriteUint32(this.View)
+	sink.WriteVarUint(uint64(len(this.Proposals)))
+	for _, proposal := range this.Proposals {
+		proposal.Serialization(sink)
+	}
+	sink.WriteVarUint(uint64(len(this.Endorsements)))
+	for _, endorsement := range this.Endorsements {
+		endorsement.Serialization(sink)
+	}
+}
+
+func (this *Evidence) Deserialization(source *common.ZeroCopySource) error {
+	var eof, irregular bool
+	this.Type, eof = source.NextUint8()
+	if eof {
+		return fmt.Errorf("source.NextUint8, deserialize type error")
+	}
+	this.PeerPubkey, _, irregular, eof = source.NextString()
+	if irregular || eof {
+		return fmt.Errorf("source.NextString, deserialize peerPubkey irregular:%v, eof: %v", irregular, eof)
+	}
+	this.Height, eof = source.NextUint32()
+	this.View, eof = source.NextUint32()
+	if eof {
+		return fmt.Errorf("source.NextUint32, deserialize view error")
+	}
+	n, _, irregular, eof := source.NextVarUint()
+	if irregular || eof || n > 2 {
+		return fmt.Errorf("source.NextVarUint, deserialize proposals length error")
+	}
+	proposals := make([]*Proposal, 0, n)
+	for i := uint64(0); i < n; i++ {
+		proposal := new(Proposal)
+		if err := proposal.Deserialization(source); err != nil {
+			return fmt.Errorf("deserialize proposal error: %v", err)
+		}
+		proposals = append(proposals, proposal)
+	}
+	this.Proposals = proposals
+	n, _, irregular, eof = source.NextVarUint()
+	if irregular || eof || n > 2 {
+		return fmt.Errorf("source.NextVarUint, deserialize endorsements length error")
+	}
+	endorsements := make([]*Endorsement, 0, n)
+	for i := uint64(0); i < n; i++ {
+		endorsement := new(Endorsement)
+		if err := endorsement.Deserialization(source); err != nil {
+			return fmt.Errorf("deserialize endorsement error: %v", err)
+		}
+		endorsements = append(endorsements, endorsement)
+	}
+	this.Endorsements = endorsements
+	return nil
+}
+
+//EvidenceKey returns the storage key of the governance ccntmract marking the evidence against a peer
+//in the round of height and view as processed, the key is not prefixed by the ccntmract address
+func EvidenceKey(peerPubkey string, height, view uint32) ([]byte, error) {
+	peerPubkeyPrefix, err := hex.DecodeString(peerPubkey)
+	if err != nil {
+		return nil, fmt.Errorf("hex.DecodeString, peerPubkey format error: %v", err)
+	}
+	sink := common.NewZeroCopySink(nil)
+	sink.WriteBytes([]byte(EVIDENCE_KEY_PREFIX))
+	sink.WriteBytes(peerPubkeyPrefix)
+	sink.WriteUint32(height)
+	sink.WriteUint32(view)
+	return sink.Bytes(), nil
+}
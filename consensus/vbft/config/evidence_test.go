@@ -0,0 +1,114 @@
+/*
+ * Copyright (C) 2018 The cntmology Authors
+ * This file is part of The cntmology library.
+ *
+ * The cntmology is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The cntmology is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * alcntm with The cntmology.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package vconfig
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/cntmio/cntmology-crypto/keypair"
+	"github.com/cntmio/cntmology/account"
+	"github.com/cntmio/cntmology/common"
+	"github.com/cntmio/cntmology/core/signature"
+	"github.com/stretchr/testify/assert"
+)
+
+func buildTestEvidence(t *testing.T, acc *account.Account, view, height uint32) *Evidence {
+	evidence := &Evidence{
+		Type:       EVIDENCE_DOUBLE_ENDORSE,
+		PeerPubkey: hex.EncodeToString(keypair.SerializePublicKey(acc.PublicKey)),
+		Height:     height,
+		View:       view,
+	}
+	for _, blockHash := range []common.Uint256{{1}, {2}} {
+		digest := EndorsementDigest(view, height, blockHash)
+		sig, err := signature.Sign(acc, digest[:])
+		assert.Nil(t, err)
+		evidence.Endorsements = append(evidence.Endorsements, &Endorsement{
+			View:      view,
+			Height:    height,
+			BlockHash: blockHash,
+			Sig:       sig,
+		})
+	}
+	return evidence
+}
+
+func TestEvidenceVerify(t *testing.T) {
+	acc := account.NewAccount("")
+	evidence := buildTestEvidence(t, acc, 2, 10)
+	assert.Nil(t, evidence.Verify())
+
+	sink := common.NewZeroCopySink(nil)
+	evidence.Serialization(sink)
+	decoded := new(Evidence)
+	assert.Nil(t, decoded.Deserialization(common.NewZeroCopySource(sink.Bytes())))
+	assert.Equal(t, evidence, decoded)
+
+	// endorsements of the same block
+	same := buildTestEvidence(t, acc, 2, 10)
+	same.Endorsements[1] = same.Endorsements[0]
+	assert.NotNil(t, same.Verify())
+
+	// endorsements of another round
+	other := buildTestEvidence(t, acc, 2, 10)
+	other.View = 3
+	assert.NotNil(t, other.Verify())
+
+	// endorsements of another peer
+	forged := buildTestEvidence(t, acc, 2, 10)
+	forged.PeerPubkey = hex.EncodeToString(keypair.SerializePublicKey(account.NewAccount("").PublicKey))
+	assert.NotNil(t, forged.Verify())
+
+	// proposals of different blocks in a round
+	proposal := &Evidence{
+		Type:       EVIDENCE_DOUBLE_PROPOSAL,
+		PeerPubkey: evidence.PeerPubkey,
+		Height:     10,
+		View:       2,
+	}
+	for _, blockHash := range []common.Uint256{{1}, {2}} {
+		digest := ProposalDigest(2, 10, blockHash, common.Uint256{3})
+		sig, err := signature.Sign(acc, digest[:])
+		assert.Nil(t, err)
+		proposal.Proposals = append(proposal.Proposals, &Proposal{
+			View:           2,
+			Height:         10,
+			BlockHash:      blockHash,
+			EmptyBlockHash: common.Uint256{3},
+			Sig:            sig,
+		})
+	}
+	assert.Nil(t, proposal.Verify())
+	proposal.Type = EVIDENCE_DOUBLE_ENDORSE
+	assert.NotNil(t, proposal.Verify())
+}
+
+func TestEvidenceKey(t *testing.T) {
+	acc := account.NewAccount("")
+	peerPubkey := hex.EncodeToString(keypair.SerializePublicKey(acc.PublicKey))
+	key, err := EvidenceKey(peerPubkey, 10, 2)
+	assert.Nil(t, err)
+	expected := append([]byte(EVIDENCE_KEY_PREFIX), keypair.SerializePublicKey(acc.PublicKey)...)
+	expected = append(expected, 10, 0, 0, 0, 2, 0, 0, 0)
+	assert.Equal(t, expected, key)
+
+	_, err = EvidenceKey("peer", 10, 2)
+	assert.NotNil(t, err)
+}
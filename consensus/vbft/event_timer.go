@@ -45,6 +45,16 @@ const (
 	EventMax
 )
 
+var timerEventNames = []string{"ProposeBlockTimeout", "ProposalBackoff", "RandomBackoff", "Propose2ndBlockTimeout",
+	"EndorseBlockTimeout", "EndorseEmptyBlockTimeout", "CommitBlockTimeout", "PeerHeartbeat", "TxPool", "TxBlockTimeout"}
+
+func (evtType TimerEventType) String() string {
+	if evtType >= 0 && int(evtType) < len(timerEventNames) {
+		return timerEventNames[evtType]
+	}
+	return fmt.Sprintf("TimerEvent(%d)", int(evtType))
+}
+
 var (
 	makeProposalTimeout    = int64(300 * time.Millisecond)
 	make2ndProposalTimeout = int64(300 * time.Millisecond)
@@ -75,7 +85,8 @@ type EventTimer struct {
 	//timerQueue TimerQueue
 
 	// bft timers
-	eventTimers map[TimerEventType]perBlockTimer
+	eventTimers    map[TimerEventType]perBlockTimer
+	eventDeadlines map[TimerEventType]map[uint32]time.Time
 
 	// peer heartbeat tickers
 	peerTickers map[uint32]*time.Timer
@@ -85,15 +96,17 @@ type EventTimer struct {
 
 func NewEventTimer(server *Server) *EventTimer {
 	timer := &EventTimer{
-		server:       server,
-		C:            make(chan *TimerEvent, 64),
-		eventTimers:  make(map[TimerEventType]perBlockTimer),
-		peerTickers:  make(map[uint32]*time.Timer),
-		normalTimers: make(map[uint32]*time.Timer),
+		server:         server,
+		C:              make(chan *TimerEvent, 64),
+		eventTimers:    make(map[TimerEventType]perBlockTimer),
+		eventDeadlines: make(map[TimerEventType]map[uint32]time.Time),
+		peerTickers:    make(map[uint32]*time.Timer),
+		normalTimers:   make(map[uint32]*time.Timer),
 	}
 
 	for i := 0; i < int(EventMax); i++ {
 		timer.eventTimers[TimerEventType(i)] = make(map[uint32]*time.Timer)
+		timer.eventDeadlines[TimerEventType(i)] = make(map[uint32]time.Time)
 	}
 
 	return timer
@@ -113,6 +126,7 @@ func (self *EventTimer) stop() {
 	for i := 0; i < int(EventMax); i++ {
 		stopAllTimers(self.eventTimers[TimerEventType(i)])
 		self.eventTimers[TimerEventType(i)] = make(map[uint32]*time.Timer)
+		self.eventDeadlines[TimerEventType(i)] = make(map[uint32]time.Time)
 	}
 
 	// clear normal timers
@@ -201,6 +215,7 @@ func (self *EventTimer) startEventTimer(evtType TimerEventType, blockNum uint32)
 		log.Errorf("invalid timeout for event %d, blkNum %d", evtType, blockNum)
 		return fmt.Errorf("invalid timeout for event %d, blkNum %d", evtType, blockNum)
 	}
+	self.eventDeadlines[evtType][blockNum] = time.Now().Add(timeout)
 	timers[blockNum] = time.AfterFunc(timeout, func() {
 		self.C <- &TimerEvent{
 			evtType:  evtType,
@@ -220,6 +235,31 @@ func (self *EventTimer) cancelEventTimer(evtType TimerEventType, blockNum uint32
 		t.Stop()
 		delete(timers, blockNum)
 	}
+	delete(self.eventDeadlines[evtType], blockNum)
+}
+
+type timerDeadline struct {
+	evtType  TimerEventType
+	blockNum uint32
+	deadline time.Time
+}
+
+// getDeadlines returns the deadlines of the bft timers not cancelled, expired ones included
+func (self *EventTimer) getDeadlines() []*timerDeadline {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	deadlines := make([]*timerDeadline, 0)
+	for i := 0; i < int(EventMax); i++ {
+		for blockNum, deadline := range self.eventDeadlines[TimerEventType(i)] {
+			deadlines = append(deadlines, &timerDeadline{
+				evtType:  TimerEventType(i),
+				blockNum: blockNum,
+				deadline: deadline,
+			})
+		}
+	}
+	return deadlines
 }
 
 func (self *EventTimer) StartProposalTimer(blockNum uint32) error {
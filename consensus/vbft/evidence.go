@@ -0,0 +1,375 @@
+/*
+ * Copyright (C) 2018 The cntmology Authors
+ * This file is part of The cntmology library.
+ *
+ * The cntmology is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The cntmology is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * alcntm with The cntmology.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package vbft
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/cntmio/cntmology/common"
+	"github.com/cntmio/cntmology/common/config"
+	"github.com/cntmio/cntmology/common/log"
+	vconfig "github.com/cntmio/cntmology/consensus/vbft/config"
+	"github.com/cntmio/cntmology/core/ledger"
+	"github.com/cntmio/cntmology/core/payload"
+	"github.com/cntmio/cntmology/core/program"
+	"github.com/cntmio/cntmology/core/types"
+	"github.com/cntmio/cntmology/core/utils"
+	gov "github.com/cntmio/cntmology/smartccntmract/service/native/governance"
+	nutils "github.com/cntmio/cntmology/smartccntmract/service/native/utils"
+)
+
+// LAST_PROPOSAL_FILE keeps the last proposal signed by this peer, under the data dir
+const LAST_PROPOSAL_FILE = "vbft_last_proposal"
+
+const (
+	EVIDENCE_HISTORY_LEN   = 64   // blocks of signed proposals and endorsements kept for equivocation checking
+	EVIDENCE_EXPIRE_BLOCKS = 1000 // pending evidence not processed in these blocks is dropped
+	MAX_EVIDENCE_TXS       = 8    // max evidence txs in a proposal
+)
+
+// peerRound is the round of a peer at a height in a view, a peer signs at most one proposal or endorsement in it
+type peerRound struct {
+	peerIdx uint32
+	blkNum  uint32
+	view    uint32
+}
+
+type evidenceKey struct {
+	peerPubkey string
+	blkNum     uint32
+	view       uint32
+}
+
+type pendingEvidence struct {
+	peerIdx  uint32
+	evidence *vconfig.Evidence
+	hash     common.Uint256
+}
+
+// EvidencePool keeps the proposals of proposers and the endorsements of endorsers of recent rounds to detect
+// equivocation, and the evidences pending to be processed by the governance ccntmract
+type EvidencePool struct {
+	lock         sync.Mutex
+	proposals    map[peerRound]*vconfig.Proposal
+	endorsements map[peerRound]*vconfig.Endorsement
+	evidences    map[evidenceKey]*pendingEvidence
+}
+
+func newEvidencePool() *EvidencePool {
+	return &EvidencePool{
+		proposals:    make(map[peerRound]*vconfig.Proposal),
+		endorsements: make(map[peerRound]*vconfig.Endorsement),
+		evidences:    make(map[evidenceKey]*pendingEvidence),
+	}
+}
+
+// addProposal records the signed proposal of the proposer, returns the evidence if the proposer equivocated
+func (pool *EvidencePool) addProposal(peerPubkey string, msg *blockProposalMsg) *vconfig.Evidence {
+	proposal := msg.Block.Proposal
+	if proposal == nil {
+		return nil
+	}
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+
+	key := peerRound{peerIdx: msg.Block.getProposer(), blkNum: proposal.Height, view: proposal.View}
+	prev := pool.proposals[key]
+	if prev == nil {
+		pool.proposals[key] = proposal
+		return nil
+	}
+	if prev.BlockHash == proposal.BlockHash && prev.EmptyBlockHash == proposal.EmptyBlockHash {
+		return nil
+	}
+	return &vconfig.Evidence{
+		Type:       vconfig.EVIDENCE_DOUBLE_PROPOSAL,
+		PeerPubkey: peerPubkey,
+		Height:     key.blkNum,
+		View:       key.view,
+		Proposals:  []*vconfig.Proposal{prev, proposal},
+	}
+}
+
+// addEndorsement records the endorsement of a block, returns the evidence if the endorser equivocated
+func (pool *EvidencePool) addEndorsement(peerPubkey string, msg *blockEndorseMsg) *vconfig.Evidence {
+	if msg.EndorseForEmpty {
+		return nil
+	}
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+
+	key := peerRound{peerIdx: msg.Endorser, blkNum: msg.GetBlockNum(), view: msg.View}
+	endorsement := msg.getEndorsement()
+	prev := pool.endorsements[key]
+	if prev == nil {
+		pool.endorsements[key] = endorsement
+		return nil
+	}
+	if prev.BlockHash == endorsement.BlockHash {
+		return nil
+	}
+	return &vconfig.Evidence{
+		Type:         vconfig.EVIDENCE_DOUBLE_ENDORSE,
+		PeerPubkey:   peerPubkey,
+		Height:       key.blkNum,
+		View:         key.view,
+		Endorsements: []*vconfig.Endorsement{prev, endorsement},
+	}
+}
+
+// addEvidence adds the verified evidence to the pending evidences, returns false if known
+func (pool *EvidencePool) addEvidence(peerIdx uint32, evidence *vconfig.Evidence) bool {
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+
+	key := evidenceKey{peerPubkey: evidence.PeerPubkey, blkNum: evidence.Height, view: evidence.View}
+	if _, present := pool.evidences[key]; present {
+		return false
+	}
+	sink := common.NewZeroCopySink(nil)
+	evidence.Serialization(sink)
+	pool.evidences[key] = &pendingEvidence{
+		peerIdx:  peerIdx,
+		evidence: evidence,
+		hash:     sha256.Sum256(sink.Bytes()),
+	}
+	return true
+}
+
+func (pool *EvidencePool) removeEvidence(evidence *vconfig.Evidence) {
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+
+	delete(pool.evidences, evidenceKey{peerPubkey: evidence.PeerPubkey, blkNum: evidence.Height, view: evidence.View})
+}
+
+// getEvidences returns the pending evidences ordered by height, peer and view
+func (pool *EvidencePool) getEvidences() []*vconfig.Evidence {
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+
+	evidences := make([]*vconfig.Evidence, 0, len(pool.evidences))
+	for _, e := range pool.evidences {
+		evidences = append(evidences, e.evidence)
+	}
+	sort.Slice(evidences, func(i, j int) bool {
+		if evidences[i].Height != evidences[j].Height {
+			return evidences[i].Height < evidences[j].Height
+		}
+		if evidences[i].PeerPubkey != evidences[j].PeerPubkey {
+			return evidences[i].PeerPubkey < evidences[j].PeerPubkey
+		}
+		return evidences[i].View < evidences[j].View
+	})
+	return evidences
+}
+
+// faultyReports returns the reports of the pending evidences of the type
+func (pool *EvidencePool) faultyReports(evidenceType uint8) []*FaultyReport {
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+
+	var reports []*FaultyReport
+	for _, e := range pool.evidences {
+		if e.evidence.Type == evidenceType {
+			reports = append(reports, &FaultyReport{
+				FaultyID:      e.peerIdx,
+				FaultyMsgHash: e.hash,
+			})
+		}
+	}
+	return reports
+}
+
+func (pool *EvidencePool) onBlockSealed(blockNum uint32) {
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+
+	if blockNum > EVIDENCE_HISTORY_LEN {
+		for key := range pool.proposals {
+			if key.blkNum < blockNum-EVIDENCE_HISTORY_LEN {
+				delete(pool.proposals, key)
+			}
+		}
+		for key := range pool.endorsements {
+			if key.blkNum < blockNum-EVIDENCE_HISTORY_LEN {
+				delete(pool.endorsements, key)
+			}
+		}
+	}
+	if blockNum > EVIDENCE_EXPIRE_BLOCKS {
+		for key := range pool.evidences {
+			if key.blkNum < blockNum-EVIDENCE_EXPIRE_BLOCKS {
+				delete(pool.evidences, key)
+			}
+		}
+	}
+}
+
+// checkEquivocation checks the proposal or endorsement against the ones signed by the same peer in the round
+func (self *Server) checkEquivocation(msg ConsensusMsg) {
+	var peerIdx uint32
+	var evidence *vconfig.Evidence
+	switch pMsg := msg.(type) {
+	case *blockProposalMsg:
+		peerIdx = pMsg.Block.getProposer()
+		if pk := self.peerPool.GetPeerPubKey(peerIdx); pk != nil {
+			evidence = self.evidencePool.addProposal(vconfig.PubkeyID(pk), pMsg)
+		}
+	case *blockEndorseMsg:
+		peerIdx = pMsg.Endorser
+		if pk := self.peerPool.GetPeerPubKey(peerIdx); pk != nil {
+			evidence = self.evidencePool.addEndorsement(vconfig.PubkeyID(pk), pMsg)
+		}
+	}
+	if evidence != nil {
+		log.Warnf("server %d detected equivocation of peer %d in block %d view %d, evidence type %d",
+			self.Index, peerIdx, evidence.Height, evidence.View, evidence.Type)
+		self.reportEvidence(peerIdx, evidence)
+	}
+}
+
+// reportEvidence adds the evidence to the pending evidences and gossips it if it is new
+func (self *Server) reportEvidence(peerIdx uint32, evidence *vconfig.Evidence) {
+	if self.evidencePool.addEvidence(peerIdx, evidence) {
+		self.broadcast(self.constructEvidenceMsg(evidence))
+	}
+}
+
+func (self *Server) processEvidenceMsg(msg *evidenceMsg) {
+	evidence, err := msg.getEvidence()
+	if err != nil {
+		log.Errorf("server %d failed to get evidence: %s", self.Index, err)
+		return
+	}
+	peerIdx, present := self.peerPool.GetPeerIndex(evidence.PeerPubkey)
+	if !present {
+		log.Debugf("server %d received evidence of unknown peer: %s", self.Index, evidence.PeerPubkey)
+		return
+	}
+	if err := evidence.Verify(); err != nil {
+		log.Errorf("server %d failed to verify evidence of peer %d: %s", self.Index, peerIdx, err)
+		return
+	}
+	self.reportEvidence(peerIdx, evidence)
+}
+
+// createEvidenceTransactions invokes governance native ccntmract reportEvidence with the pending evidences,
+// evidences processed before the block are dropped
+func (self *Server) createEvidenceTransactions(blkNum uint32) []*types.Transaction {
+	txs := make([]*types.Transaction, 0)
+	for _, evidence := range self.evidencePool.getEvidences() {
+		if len(txs) >= MAX_EVIDENCE_TXS {
+			break
+		}
+		key, err := vconfig.EvidenceKey(evidence.PeerPubkey, evidence.Height, evidence.View)
+		if err != nil {
+			self.evidencePool.removeEvidence(evidence)
+			continue
+		}
+		value, _ := GetStorageValue(self.blockPool.getExecWriteSet(blkNum-1), ledger.DefLedger,
+			nutils.GovernanceCcntmractAddress, key)
+		if len(value) != 0 {
+			self.evidencePool.removeEvidence(evidence)
+			continue
+		}
+		sink := common.NewZeroCopySink(nil)
+		evidence.Serialization(sink)
+		mutable := utils.BuildNativeTransaction(nutils.GovernanceCcntmractAddress, gov.REPORT_EVIDENCE, sink.Bytes())
+		mutable.Nonce = blkNum
+		tx, err := mutable.IntoImmutable()
+		if err != nil {
+			log.Errorf("server %d failed to construct evidence transaction: %s", self.Index, err)
+			continue
+		}
+		txs = append(txs, tx)
+	}
+	return txs
+}
+
+func lastProposalFile() string {
+	return filepath.Join(config.DefConfig.Common.DataDir, config.DefConfig.P2PNode.NetworkName, LAST_PROPOSAL_FILE)
+}
+
+// saveLastProposal persists the proposal before it is broadcasted, so it is not signed again after a restart
+func (self *Server) saveLastProposal(msg *blockProposalMsg) error {
+	file := lastProposalFile()
+	if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+		return err
+	}
+	tmp := file + ".tmp"
+	if err := ioutil.WriteFile(tmp, msg.Block.Serialize(), 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, file)
+}
+
+// loadLastProposal returns the persisted proposal if it is the one of this peer in the round of blkNum
+func (self *Server) loadLastProposal(blkNum uint32) *blockProposalMsg {
+	data, err := ioutil.ReadFile(lastProposalFile())
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Errorf("server %d failed to read last proposal: %s", self.Index, err)
+		}
+		return nil
+	}
+	msg := &blockProposalMsg{}
+	if err := msg.UnmarshalJSON(data); err != nil {
+		log.Errorf("server %d failed to decode last proposal: %s", self.Index, err)
+		return nil
+	}
+	proposal := msg.Block.Proposal
+	if msg.GetBlockNum() != blkNum || msg.Block.getProposer() != self.Index || proposal == nil ||
+		proposal.View != self.GetChainConfig().View {
+		return nil
+	}
+	return msg
+}
+
+// evidenceTxSuffix is the code of the reportEvidence system tx following the pushed evidence,
+// the empty args of the template is pushed as one byte
+var evidenceTxSuffix = utils.BuildNativeTransaction(nutils.GovernanceCcntmractAddress, gov.REPORT_EVIDENCE,
+	nil).Payload.(*payload.InvokeCode).Code[1:]
+
+// getTxEvidence returns the evidence of the unsigned reportEvidence system tx built by createEvidenceTransactions
+func getTxEvidence(tx *types.Transaction) (*vconfig.Evidence, bool) {
+	if tx.TxType != types.InvokeCntm || len(tx.Sigs) != 0 {
+		return nil, false
+	}
+	invoke, ok := tx.Payload.(*payload.InvokeCode)
+	if !ok || !bytes.HasSuffix(invoke.Code, evidenceTxSuffix) {
+		return nil, false
+	}
+	params, err := program.GetParamInfo(invoke.Code[:len(invoke.Code)-len(evidenceTxSuffix)])
+	if err != nil || len(params) != 1 {
+		return nil, false
+	}
+	evidence := &vconfig.Evidence{}
+	source := common.NewZeroCopySource(params[0])
+	if err := evidence.Deserialization(source); err != nil || source.Len() != 0 {
+		return nil, false
+	}
+	return evidence, true
+}
@@ -0,0 +1,217 @@
+/*
+ * Copyright (C) 2018 The cntmology Authors
+ * This file is part of The cntmology library.
+ *
+ * The cntmology is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The cntmology is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * alcntm with The cntmology.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package vbft
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/cntmio/cntmology/account"
+	"github.com/cntmio/cntmology/common"
+	vconfig "github.com/cntmio/cntmology/consensus/vbft/config"
+	"github.com/cntmio/cntmology/core/signature"
+	"github.com/cntmio/cntmology/core/types"
+	"github.com/cntmio/cntmology/core/utils"
+	gov "github.com/cntmio/cntmology/smartccntmract/service/native/governance"
+	nutils "github.com/cntmio/cntmology/smartccntmract/service/native/utils"
+)
+
+func buildTestProposalMsg(t *testing.T, acc *account.Account, view uint32, blkHash common.Uint256) *blockProposalMsg {
+	consensusPayload, err := json.Marshal(&vconfig.VbftBlockInfo{Proposer: 1})
+	if err != nil {
+		t.Fatalf("failed to build consensus payload: %s", err)
+	}
+	block := &types.Block{Header: &types.Header{Height: 20, ConsensusPayload: consensusPayload}}
+	emptyHash := common.Uint256{9}
+	digest := vconfig.ProposalDigest(view, 20, blkHash, emptyHash)
+	sig, err := signature.Sign(acc, digest[:])
+	if err != nil {
+		t.Fatalf("failed to sign proposal: %s", err)
+	}
+	return &blockProposalMsg{Block: &Block{
+		Block: block,
+		Info:  &vconfig.VbftBlockInfo{Proposer: 1},
+		Proposal: &vconfig.Proposal{
+			View:           view,
+			Height:         20,
+			BlockHash:      blkHash,
+			EmptyBlockHash: emptyHash,
+			Sig:            sig,
+		},
+	}}
+}
+
+func buildTestEndorseMsg(t *testing.T, acc *account.Account, blkHash common.Uint256) *blockEndorseMsg {
+	digest := vconfig.EndorsementDigest(3, 20, blkHash)
+	sig, err := signature.Sign(acc, digest[:])
+	if err != nil {
+		t.Fatalf("failed to sign endorsement: %s", err)
+	}
+	return &blockEndorseMsg{
+		Endorser:          5,
+		BlockNum:          20,
+		EndorsedBlockHash: blkHash,
+		View:              3,
+		EvidenceSig:       sig,
+	}
+}
+
+func TestEvidencePoolProposal(t *testing.T) {
+	acc := account.NewAccount("SHA256withECDSA")
+	pubkey := vconfig.PubkeyID(acc.PublicKey)
+	pool := newEvidencePool()
+
+	proposal := buildTestProposalMsg(t, acc, 3, common.Uint256{1})
+	if evidence := pool.addProposal(pubkey, proposal); evidence != nil {
+		t.Fatalf("evidence of a single proposal")
+	}
+	if evidence := pool.addProposal(pubkey, buildTestProposalMsg(t, acc, 3, common.Uint256{1})); evidence != nil {
+		t.Fatalf("evidence of a repeated proposal")
+	}
+	// a proposal of another view is in another round
+	if evidence := pool.addProposal(pubkey, buildTestProposalMsg(t, acc, 4, common.Uint256{2})); evidence != nil {
+		t.Fatalf("evidence of proposals in different views")
+	}
+	unsigned := &blockProposalMsg{Block: &Block{Block: proposal.Block.Block, Info: proposal.Block.Info}}
+	if evidence := pool.addProposal(pubkey, unsigned); evidence != nil {
+		t.Fatalf("evidence of a proposal without proposal sig")
+	}
+
+	evidence := pool.addProposal(pubkey, buildTestProposalMsg(t, acc, 3, common.Uint256{2}))
+	if evidence == nil {
+		t.Fatalf("double proposal not detected")
+	}
+	if evidence.View != 3 {
+		t.Fatalf("evidence of view %d, expect 3", evidence.View)
+	}
+	if err := evidence.Verify(); err != nil {
+		t.Fatalf("failed to verify evidence: %s", err)
+	}
+	other := *evidence
+	other.PeerPubkey = vconfig.PubkeyID(account.NewAccount("SHA256withECDSA").PublicKey)
+	if err := other.Verify(); err == nil {
+		t.Fatalf("evidence verified against another proposer")
+	}
+	other = *evidence
+	other.View = 4
+	if err := other.Verify(); err == nil {
+		t.Fatalf("evidence verified in another view")
+	}
+
+	sink := common.NewZeroCopySink(nil)
+	evidence.Serialization(sink)
+	msg := &evidenceMsg{BlockNum: 20, Evidence: sink.Bytes()}
+	decoded, err := msg.getEvidence()
+	if err != nil {
+		t.Fatalf("failed to decode evidence: %s", err)
+	}
+	if err := decoded.Verify(); err != nil {
+		t.Fatalf("failed to verify decoded evidence: %s", err)
+	}
+
+	if !pool.addEvidence(1, evidence) || pool.addEvidence(1, decoded) {
+		t.Fatalf("evidence not deduplicated")
+	}
+	if len(pool.faultyReports(vconfig.EVIDENCE_DOUBLE_PROPOSAL)) != 1 {
+		t.Fatalf("no faulty report of the evidence")
+	}
+	pool.onBlockSealed(20 + EVIDENCE_EXPIRE_BLOCKS + 1)
+	if len(pool.getEvidences()) != 0 {
+		t.Fatalf("expired evidence not dropped")
+	}
+}
+
+func TestEvidencePoolEndorsement(t *testing.T) {
+	acc := account.NewAccount("SHA256withECDSA")
+	pubkey := vconfig.PubkeyID(acc.PublicKey)
+	pool := newEvidencePool()
+
+	endorse := buildTestEndorseMsg(t, acc, common.Uint256{1})
+	if err := endorse.getEndorsement().Verify(acc.PublicKey); err != nil {
+		t.Fatalf("failed to verify endorsement: %s", err)
+	}
+	if evidence := pool.addEndorsement(pubkey, endorse); evidence != nil {
+		t.Fatalf("evidence of a single endorsement")
+	}
+	if evidence := pool.addEndorsement(pubkey, buildTestEndorseMsg(t, acc, common.Uint256{1})); evidence != nil {
+		t.Fatalf("evidence of a repeated endorsement")
+	}
+	empty := buildTestEndorseMsg(t, acc, common.Uint256{2})
+	empty.EndorseForEmpty = true
+	if evidence := pool.addEndorsement(pubkey, empty); evidence != nil {
+		t.Fatalf("evidence of an empty endorsement")
+	}
+	otherView := buildTestEndorseMsg(t, acc, common.Uint256{2})
+	otherView.View = 4
+	if evidence := pool.addEndorsement(pubkey, otherView); evidence != nil {
+		t.Fatalf("evidence of endorsements in different views")
+	}
+
+	evidence := pool.addEndorsement(pubkey, buildTestEndorseMsg(t, acc, common.Uint256{2}))
+	if evidence == nil {
+		t.Fatalf("double endorsement not detected")
+	}
+	if err := evidence.Verify(); err != nil {
+		t.Fatalf("failed to verify evidence: %s", err)
+	}
+	evidence.Endorsements[1].BlockHash = common.Uint256{3}
+	if err := evidence.Verify(); err == nil {
+		t.Fatalf("evidence with a forged endorsement verified")
+	}
+}
+
+func TestGetTxEvidence(t *testing.T) {
+	acc := account.NewAccount("SHA256withECDSA")
+	pool := newEvidencePool()
+	pubkey := vconfig.PubkeyID(acc.PublicKey)
+	pool.addEndorsement(pubkey, buildTestEndorseMsg(t, acc, common.Uint256{1}))
+	evidence := pool.addEndorsement(pubkey, buildTestEndorseMsg(t, acc, common.Uint256{2}))
+	if evidence == nil {
+		t.Fatalf("double endorsement not detected")
+	}
+
+	sink := common.NewZeroCopySink(nil)
+	evidence.Serialization(sink)
+	tx, err := utils.BuildNativeTransaction(nutils.GovernanceCcntmractAddress, gov.REPORT_EVIDENCE, sink.Bytes()).IntoImmutable()
+	if err != nil {
+		t.Fatalf("failed to build evidence tx: %s", err)
+	}
+	decoded, ok := getTxEvidence(tx)
+	if !ok {
+		t.Fatalf("evidence tx not recognized")
+	}
+	if err := decoded.Verify(); err != nil {
+		t.Fatalf("failed to verify evidence of tx: %s", err)
+	}
+
+	tx, err = utils.BuildNativeTransaction(nutils.GovernanceCcntmractAddress, gov.REPORT_EVIDENCE, []byte{1, 2, 3}).IntoImmutable()
+	if err != nil {
+		t.Fatalf("failed to build tx: %s", err)
+	}
+	if _, ok := getTxEvidence(tx); ok {
+		t.Fatalf("invalid evidence tx recognized")
+	}
+	tx, err = utils.BuildNativeTransaction(nutils.GovernanceCcntmractAddress, "commitDpos", sink.Bytes()).IntoImmutable()
+	if err != nil {
+		t.Fatalf("failed to build tx: %s", err)
+	}
+	if _, ok := getTxEvidence(tx); ok {
+		t.Fatalf("commitDpos tx recognized as evidence tx")
+	}
+}
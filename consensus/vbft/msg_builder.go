@@ -28,7 +28,6 @@ import (
 	"github.com/cntmio/cntmology/common/log"
 	vconfig "github.com/cntmio/cntmology/consensus/vbft/config"
 	"github.com/cntmio/cntmology/core/ledger"
-	"github.com/cntmio/cntmology/core/signature"
 	"github.com/cntmio/cntmology/core/types"
 )
 
@@ -115,6 +114,12 @@ func DeserializeVbftMsg(msgPayload []byte) (ConsensusMsg, error) {
 			return nil, fmt.Errorf("failed to unmarshal msg (type: %d): %s", m.Type, err)
 		}
 		return t, nil
+	case EvidenceMessage:
+		t := &evidenceMsg{}
+		if err := json.Unmarshal(m.Payload, t); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal msg (type: %d): %s", m.Type, err)
+		}
+		return t, nil
 	}
 
 	return nil, fmt.Errorf("unknown msg type: %d", m.Type)
@@ -211,15 +216,31 @@ func (self *Server) constructBlock(blkNum uint32, prevBlkHash common.Uint256, tx
 		Header:       blkHeader,
 		Transactions: txs,
 	}
-	blkHash := blk.Hash()
-	sig, err := signature.Sign(self.account, blkHash[:])
+
+	return blk, nil
+}
+
+//signProposalBlocks sign the block and empty block of proposal in one request,
+//so that the signer can refuse to sign different blocks at the same height.
+//The returned proposal binds the blocks to the height and view, proving equivocation of the proposer
+func (self *Server) signProposalBlocks(blkNum uint32, blk, emptyBlk *types.Block) (*vconfig.Proposal, error) {
+	view := self.GetChainConfig().View
+	blkHash, emptyHash := blk.Hash(), emptyBlk.Hash()
+	blkSig, emptySig, proposalSig, err := self.signer.SignProposal(view, blk.Header, emptyBlk.Header)
 	if err != nil {
 		return nil, fmt.Errorf("sign block failed, block hash:%s, error: %s", blkHash.ToHexString(), err)
 	}
-	blkHeader.Bookkeepers = []keypair.PublicKey{self.account.PublicKey}
-	blkHeader.SigData = [][]byte{sig}
-
-	return blk, nil
+	blk.Header.Bookkeepers = []keypair.PublicKey{self.account.PublicKey}
+	blk.Header.SigData = [][]byte{blkSig}
+	emptyBlk.Header.Bookkeepers = []keypair.PublicKey{self.account.PublicKey}
+	emptyBlk.Header.SigData = [][]byte{emptySig}
+	return &vconfig.Proposal{
+		View:           view,
+		Height:         blkNum,
+		BlockHash:      blkHash,
+		EmptyBlockHash: emptyHash,
+		Sig:            proposalSig,
+	}, nil
 }
 
 func (self *Server) constructCrossChainMsg(blkNum uint32) (*types.CrossChainMsg, error) {
@@ -236,10 +257,9 @@ func (self *Server) constructCrossChainMsg(blkNum uint32) (*types.CrossChainMsg,
 		Height:     blkNum,
 		StatesRoot: root,
 	}
-	hash := msg.Hash()
-	sig, err := signature.Sign(self.account, hash[:])
+	sig, err := self.signer.SignCrossChainMsg(msg)
 	if err != nil {
-		return nil, fmt.Errorf("sign cross chain msg root failed,msg hash:%s,err:%s", hash.ToHexString(), err)
+		return nil, fmt.Errorf("sign cross chain msg root failed,msg hash:%s,err:%s", msg.Hash().ToHexString(), err)
 	}
 	msg.SigData = append(msg.SigData, sig)
 	return msg, nil
@@ -256,7 +276,7 @@ func (self *Server) constructProposalMsg(blkNum uint32, sysTxs, userTxs []*types
 		blocktimestamp = prevBlk.Block.Header.Timestamp + 1
 	}
 
-	vrfValue, vrfProof, err := computeVrf(self.account.PrivateKey, blkNum, prevBlk.getVrfValue())
+	vrfValue, vrfProof, err := computeVrfBySigner(self.signer, blkNum, prevBlk.getVrfValue())
 	if err != nil {
 		return nil, fmt.Errorf("failed to get vrf and proof: %s", err)
 	}
@@ -288,6 +308,10 @@ func (self *Server) constructProposalMsg(blkNum uint32, sysTxs, userTxs []*types
 	if err != nil {
 		return nil, fmt.Errorf("failed to constuct blk: %s", err)
 	}
+	proposal, err := self.signProposalBlocks(blkNum, blk, emptyBlk)
+	if err != nil {
+		return nil, err
+	}
 	merkleRoot, err := self.blockPool.getExecMerkleRoot(blkNum - 1)
 	if err != nil {
 		return nil, fmt.Errorf("failed to GetExecMerkleRoot: %s,blkNum:%d", err, blkNum-1)
@@ -303,6 +327,7 @@ func (self *Server) constructProposalMsg(blkNum uint32, sysTxs, userTxs []*types
 			Info:               vbftBlkInfo,
 			PrevExecMerkleRoot: merkleRoot,
 			CrossChainMsg:      crossChainMsg,
+			Proposal:           proposal,
 		},
 	}
 	return msg, nil
@@ -310,14 +335,11 @@ func (self *Server) constructProposalMsg(blkNum uint32, sysTxs, userTxs []*types
 
 func (self *Server) constructEndorseMsg(proposal *blockProposalMsg, forEmpty bool) (*blockEndorseMsg, error) {
 
-	// TODO, support faultyMsg reporting
-
-	var proposerSig, endorserSig []byte
-	var blkHash common.Uint256
-	var err error
+	var proposerSig []byte
+	var blk *types.Block
 	if !forEmpty {
 		proposerSig = proposal.BlockProposerSig
-		blkHash = proposal.Block.Block.Hash()
+		blk = proposal.Block.Block
 
 	} else {
 		if proposal.Block.EmptyBlock == nil {
@@ -326,9 +348,11 @@ func (self *Server) constructEndorseMsg(proposal *blockProposalMsg, forEmpty boo
 		}
 
 		proposerSig = proposal.EmptyBlockProposerSig
-		blkHash = proposal.Block.EmptyBlock.Hash()
+		blk = proposal.Block.EmptyBlock
 	}
-	endorserSig, err = signature.Sign(self.account, blkHash[:])
+	blkHash := blk.Hash()
+	view := self.GetChainConfig().View
+	endorserSig, evidenceSig, err := self.signer.SignEndorsement(view, blk.Header, forEmpty)
 	if err != nil {
 		return nil, fmt.Errorf("endorser failed to sign block. hash:%x, err: %s", blkHash, err)
 	}
@@ -339,12 +363,15 @@ func (self *Server) constructEndorseMsg(proposal *blockProposalMsg, forEmpty boo
 		BlockNum:          proposal.Block.getBlockNum(),
 		EndorsedBlockHash: blkHash,
 		EndorseForEmpty:   forEmpty,
+		FaultyProposals:   self.evidencePool.faultyReports(vconfig.EVIDENCE_DOUBLE_PROPOSAL),
 		ProposerSig:       proposerSig,
 		EndorserSig:       endorserSig,
+		View:              view,
+		EvidenceSig:       evidenceSig,
 	}
 	if proposal.Block.CrossChainMsg != nil {
 		hash := proposal.Block.CrossChainMsg.Hash()
-		sig, err := signature.Sign(self.account, hash[:])
+		sig, err := self.signer.SignCrossChainMsg(proposal.Block.CrossChainMsg)
 		if err != nil {
 			return nil, fmt.Errorf("sign cross chain msg root failed,msg hash:%s,err:%s", hash.ToHexString(), err)
 		}
@@ -356,15 +383,12 @@ func (self *Server) constructEndorseMsg(proposal *blockProposalMsg, forEmpty boo
 
 func (self *Server) constructCommitMsg(proposal *blockProposalMsg, endorses []*blockEndorseMsg, forEmpty bool) (*blockCommitMsg, error) {
 
-	// TODO, support faultyMsg reporting
-
-	var proposerSig, committerSig []byte
-	var blkHash common.Uint256
-	var err error
+	var proposerSig []byte
+	var blk *types.Block
 
 	if !forEmpty {
 		proposerSig = proposal.BlockProposerSig
-		blkHash = proposal.Block.Block.Hash()
+		blk = proposal.Block.Block
 	} else {
 		if proposal.Block.EmptyBlock == nil {
 			return nil, fmt.Errorf("blk %d proposal from %d has no empty proposal",
@@ -372,9 +396,10 @@ func (self *Server) constructCommitMsg(proposal *blockProposalMsg, endorses []*b
 		}
 
 		proposerSig = proposal.EmptyBlockProposerSig
-		blkHash = proposal.Block.EmptyBlock.Hash()
+		blk = proposal.Block.EmptyBlock
 	}
-	committerSig, err = signature.Sign(self.account, blkHash[:])
+	blkHash := blk.Hash()
+	committerSig, err := self.signer.SignCommit(self.GetChainConfig().View, blk.Header, forEmpty)
 	if err != nil {
 		return nil, fmt.Errorf("endorser failed to sign block. hash:%x, caused by: %s", blkHash, err)
 	}
@@ -403,6 +428,7 @@ func (self *Server) constructCommitMsg(proposal *blockProposalMsg, endorses []*b
 		BlockNum:                  proposal.Block.getBlockNum(),
 		CommitBlockHash:           blkHash,
 		CommitForEmpty:            forEmpty,
+		FaultyVerifies:            self.evidencePool.faultyReports(vconfig.EVIDENCE_DOUBLE_ENDORSE),
 		ProposerSig:               proposerSig,
 		EndorsersSig:              endorsersSig,
 		CommitterSig:              committerSig,
@@ -412,7 +438,7 @@ func (self *Server) constructCommitMsg(proposal *blockProposalMsg, endorses []*b
 	}
 
 	if proposal.Block.CrossChainMsg != nil && commitCrossChain {
-		sig, err := signature.Sign(self.account, hash[:])
+		sig, err := self.signer.SignCrossChainMsg(proposal.Block.CrossChainMsg)
 		if err != nil {
 			return nil, fmt.Errorf("sign cross chain msg root failed,msg hash:%s,err:%s", hash.ToHexString(), err)
 		}
@@ -454,8 +480,17 @@ func (self *Server) constructProposalFetchMsg(blkNum uint32, proposer uint32) *p
 	}
 }
 
+func (self *Server) constructEvidenceMsg(evidence *vconfig.Evidence) *evidenceMsg {
+	sink := common.NewZeroCopySink(nil)
+	evidence.Serialization(sink)
+	return &evidenceMsg{
+		BlockNum: evidence.Height,
+		Evidence: sink.Bytes(),
+	}
+}
+
 func (self *Server) constructBlockSubmitMsg(blkNum uint32, stateRoot common.Uint256) (*blockSubmitMsg, error) {
-	submitSig, err := signature.Sign(self.account, stateRoot[:])
+	submitSig, err := self.signer.SignStateRoot(blkNum, stateRoot)
 	if err != nil {
 		return nil, fmt.Errorf("submit failed to sign stateroot hash:%x, err: %s", stateRoot, err)
 	}
@@ -47,6 +47,7 @@ const (
 	BlockFetchMessage
 	BlockFetchRespMessage
 	BlockSubmitMessage
+	EvidenceMessage
 )
 
 type ConsensusMsg interface {
@@ -111,6 +112,20 @@ func (msg *blockProposalMsg) Verify(pub keypair.PublicKey) error {
 		}
 	}
 
+	// verify proposal
+	if proposal := msg.Block.Proposal; proposal != nil {
+		var emptyHash common.Uint256
+		if msg.Block.EmptyBlock != nil {
+			emptyHash = msg.Block.EmptyBlock.Hash()
+		}
+		if proposal.Height != msg.GetBlockNum() || proposal.BlockHash != hash || proposal.EmptyBlockHash != emptyHash {
+			return errors.New("proposal of other blocks")
+		}
+		if err := proposal.Verify(pub); err != nil {
+			return fmt.Errorf("failed to verify proposal sig: %s", err)
+		}
+	}
+
 	return nil
 }
 
@@ -158,6 +173,8 @@ type blockEndorseMsg struct {
 	EndorserSig              []byte          `json:"endorser_sig"`
 	CrossChainMsgHash        common.Uint256  `json:"cross_chain_msg_hash"`
 	CrossChainMsgEndorserSig []byte          `json:"cross_chain_msg_endorser_sig"`
+	View                     uint32          `json:"view"`
+	EvidenceSig              []byte          `json:"evidence_sig"` // sig over the endorsement digest
 }
 
 func (msg *blockEndorseMsg) Type() MsgType {
@@ -173,6 +190,11 @@ func (msg *blockEndorseMsg) Verify(pub keypair.PublicKey) error {
 	if !signature.Verify(pub, hash[:], sig) {
 		return fmt.Errorf("failed to verify block sig")
 	}
+	if !msg.EndorseForEmpty {
+		if err := msg.getEndorsement().Verify(pub); err != nil {
+			return fmt.Errorf("failed to verify evidence sig: %s", err)
+		}
+	}
 	if msg.CrossChainMsgEndorserSig != nil {
 		//verify cross states endorse sig
 		cSig, err := signature.Deserialize(msg.CrossChainMsgEndorserSig)
@@ -190,6 +212,15 @@ func (msg *blockEndorseMsg) GetBlockNum() uint32 {
 	return msg.BlockNum
 }
 
+func (msg *blockEndorseMsg) getEndorsement() *vconfig.Endorsement {
+	return &vconfig.Endorsement{
+		View:      msg.View,
+		Height:    msg.BlockNum,
+		BlockHash: msg.EndorsedBlockHash,
+		Sig:       msg.EvidenceSig,
+	}
+}
+
 func (msg *blockEndorseMsg) Serialize() ([]byte, error) {
 	return json.Marshal(msg)
 }
@@ -439,7 +470,7 @@ func (msg *blockSubmitMsg) Type() MsgType {
 }
 
 func (msg *blockSubmitMsg) Verify(pub keypair.PublicKey) error {
-	hash := msg.BlockStateRoot
+	hash := vconfig.SubmitDigest(msg.BlockNum, msg.BlockStateRoot)
 	sig, err := signature.Deserialize(msg.SubmitMsgSig)
 	if err != nil {
 		return fmt.Errorf("deserialize submitmsg sig: %s", err)
@@ -457,3 +488,37 @@ func (msg *blockSubmitMsg) GetBlockNum() uint32 {
 func (msg *blockSubmitMsg) Serialize() ([]byte, error) {
 	return json.Marshal(msg)
 }
+
+type evidenceMsg struct {
+	BlockNum uint32 `json:"block_num"`
+	Evidence []byte `json:"evidence"`
+}
+
+func (msg *evidenceMsg) Type() MsgType {
+	return EvidenceMessage
+}
+
+// evidence is self verifying, it is checked against the accused peer when processed
+func (msg *evidenceMsg) Verify(pub keypair.PublicKey) error {
+	_, err := msg.getEvidence()
+	return err
+}
+
+func (msg *evidenceMsg) GetBlockNum() uint32 {
+	return msg.BlockNum
+}
+
+func (msg *evidenceMsg) Serialize() ([]byte, error) {
+	return json.Marshal(msg)
+}
+
+func (msg *evidenceMsg) getEvidence() (*vconfig.Evidence, error) {
+	evidence := &vconfig.Evidence{}
+	if err := evidence.Deserialization(common.NewZeroCopySource(msg.Evidence)); err != nil {
+		return nil, fmt.Errorf("deserialize evidence: %s", err)
+	}
+	if evidence.Height != msg.BlockNum {
+		return nil, fmt.Errorf("evidence of height %d in msg of block %d", evidence.Height, msg.BlockNum)
+	}
+	return evidence, nil
+}
@@ -26,7 +26,6 @@ import (
 	"github.com/cntmio/cntmology/common"
 	"github.com/cntmio/cntmology/common/log"
 	vconfig "github.com/cntmio/cntmology/consensus/vbft/config"
-	"github.com/cntmio/cntmology/core/signature"
 	msgpack "github.com/cntmio/cntmology/p2pserver/message/msg_pack"
 	p2pmsg "github.com/cntmio/cntmology/p2pserver/message/types"
 )
@@ -457,7 +456,7 @@ func (self *Server) sendToPeer(peerIdx uint32, data []byte) error {
 
 	sink := common.NewZeroCopySink(nil)
 	msg.SerializationUnsigned(sink)
-	msg.Signature, _ = signature.Sign(self.account, sink.Bytes())
+	msg.Signature, _ = self.signer.Sign(sink.Bytes())
 
 	cons := msgpack.NewConsensus(msg)
 	p2pid, present := self.peerPool.getP2pId(peerIdx)
@@ -484,7 +483,7 @@ func (self *Server) broadcastToAll(data []byte) {
 
 	sink := common.NewZeroCopySink(nil)
 	payload.SerializationUnsigned(sink)
-	payload.Signature, _ = signature.Sign(self.account, sink.Bytes())
+	payload.Signature, _ = self.signer.Sign(sink.Bytes())
 
 	msg := msgpack.NewConsensus(payload)
 	go self.p2p.Broadcast(msg)
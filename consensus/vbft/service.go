@@ -30,6 +30,7 @@ import (
 	"github.com/cntmio/cntmology-crypto/vrf"
 	"github.com/cntmio/cntmology-eventbus/actor"
 	"github.com/cntmio/cntmology/account"
+	"github.com/cntmio/cntmology/account/signer"
 	"github.com/cntmio/cntmology/common"
 	"github.com/cntmio/cntmology/common/log"
 	actorTypes "github.com/cntmio/cntmology/consensus/actor"
@@ -87,7 +88,8 @@ type p2pMsgPayload struct {
 
 type Server struct {
 	Index         uint32
-	account       *account.Account
+	account       *account.Account // public part of the signing key
+	signer        signer.Signer
 	poolActor     *actorTypes.TxPoolActor
 	p2p           p2p.P2P
 	ledger        *ledger.Ledger
@@ -110,13 +112,15 @@ type Server struct {
 	config                   *vconfig.ChainConfig
 	currentParticipantConfig *BlockParticipantConfig
 
-	chainStore *ChainStore // block store
-	msgPool    *MsgPool    // consensus msg pool
-	blockPool  *BlockPool  // received block proposals
-	peerPool   *PeerPool   // consensus peers
-	syncer     *Syncer
-	stateMgr   *StateMgr
-	timer      *EventTimer
+	chainStore     *ChainStore   // block store
+	msgPool        *MsgPool      // consensus msg pool
+	evidencePool   *EvidencePool // equivocation evidence pool
+	blockPool      *BlockPool    // received block proposals
+	peerPool       *PeerPool     // consensus peers
+	syncer         *Syncer
+	stateMgr       *StateMgr
+	timer          *EventTimer
+	latencyTracker *LatencyTracker // consensus phase latencies
 
 	msgRecvC   *sync.Map // map[uint32]chan *p2pMsgPayload
 	msgC       chan ConsensusMsg
@@ -129,9 +133,15 @@ type Server struct {
 }
 
 func NewVbftServer(account *account.Account, txpool *actor.PID, p2p p2p.P2P) (*Server, error) {
+	return NewVbftServerWithSigner(signer.NewLocalSigner(account), txpool, p2p)
+}
+
+//NewVbftServerWithSigner create vbft server signing with signer, e.g. a remote signer with slashing protection
+func NewVbftServerWithSigner(s signer.Signer, txpool *actor.PID, p2p p2p.P2P) (*Server, error) {
 	server := &Server{
 		msgHistoryDuration: 64,
-		account:            account,
+		account:            signer.ToAccount(s),
+		signer:             s,
 		poolActor:          &actorTypes.TxPoolActor{Pool: txpool},
 		p2p:                p2p,
 		ledger:             ledger.DefLedger,
@@ -182,6 +192,10 @@ func (self *Server) Receive(ccntmext actor.Ccntmext) {
 		self.handleBlockPersistCompleted(msg.Block)
 	case *p2pmsg.ConsensusPayload:
 		self.NewConsensusPayload(msg)
+	case *actorTypes.GetConsensusStatus:
+		if ccntmext.Sender() != nil {
+			ccntmext.Sender().Request(self.getConsensusStatus(), ccntmext.Self())
+		}
 
 	default:
 		log.Info("vbft actor: Unknown msg ", msg, "type", reflect.TypeOf(msg))
@@ -438,6 +452,8 @@ func (self *Server) initialize() error {
 		return fmt.Errorf("init blockpool: %s", err)
 	}
 	self.msgPool = newMsgPool(self, self.msgHistoryDuration)
+	self.evidencePool = newEvidencePool()
+	self.latencyTracker = newLatencyTracker()
 	self.peerPool = NewPeerPool(0, self) // FIXME: maxSize
 	self.timer = NewEventTimer(self)
 	self.syncer = newSyncer(self)
@@ -510,7 +526,7 @@ func (self *Server) initialize() error {
 
 func (self *Server) start() error {
 	// check if server pubkey support VRF
-	if !vrf.ValidatePrivateKey(self.account.PrivateKey) || !vrf.ValidatePublicKey(self.account.PublicKey) {
+	if !vrf.ValidatePublicKey(self.account.PublicKey) {
 		return fmt.Errorf("server %d consensus start failed: invalid account key for VRF", self.Index)
 	}
 
@@ -657,6 +673,7 @@ func (self *Server) updateParticipantConfig() error {
 
 func (self *Server) startNewRound() error {
 	blkNum := self.GetCurrentBlockNo()
+	self.latencyTracker.onRoundStart(blkNum)
 
 	if err := self.updateParticipantConfig(); err != nil {
 		log.Errorf("startNewRound error:%s", err)
@@ -775,6 +792,7 @@ func (self *Server) onConsensusMsg(peerIdx uint32, msg ConsensusMsg, msgHash com
 			log.Error("invalid msg with proposal msg type")
 			return
 		}
+		self.checkEquivocation(pMsg)
 
 		msgBlkNum := pMsg.GetBlockNum()
 		if msgBlkNum > self.GetCurrentBlockNo() {
@@ -830,8 +848,7 @@ func (self *Server) onConsensusMsg(peerIdx uint32, msg ConsensusMsg, msgHash com
 			log.Error("invalid msg with endorse msg type")
 			return
 		}
-
-		// TODO: verify msg
+		self.checkEquivocation(pMsg)
 
 		msgBlkNum := pMsg.GetBlockNum()
 		if msgBlkNum > self.GetCurrentBlockNo() {
@@ -951,6 +968,14 @@ func (self *Server) onConsensusMsg(peerIdx uint32, msg ConsensusMsg, msgHash com
 			}
 		}
 
+	case EvidenceMessage:
+		pMsg, ok := msg.(*evidenceMsg)
+		if !ok {
+			log.Errorf("invalid msg with evidence msg type")
+			return
+		}
+		self.processEvidenceMsg(pMsg)
+
 	case ProposalFetchMessage:
 		pMsg, ok := msg.(*proposalFetchMsg)
 		if !ok {
@@ -1094,6 +1119,7 @@ func (self *Server) verifyCrossChainMsg(msg *blockProposalMsg) bool {
 
 func (self *Server) processProposalMsg(msg *blockProposalMsg) {
 	msgBlkNum := msg.GetBlockNum()
+	self.latencyTracker.onProposal(msgBlkNum)
 	blk, prevBlkHash := self.blockPool.getSealedBlock(msg.GetBlockNum() - 1)
 	if blk == nil {
 		log.Errorf("BlockProposal failed to GetPreBlock:%d", msg.GetBlockNum()-1)
@@ -1148,6 +1174,14 @@ func (self *Server) processProposalMsg(msg *blockProposalMsg) {
 		return
 	}
 
+	// the proposal binding the blocks to another view does not prove equivocation in this one
+	if msg.Block.Proposal != nil && msg.Block.Proposal.View != self.GetChainConfig().View {
+		log.Errorf("BlockPrposalMessage check view blocknum:%d,msg view:%d,self view:%d", msgBlkNum,
+			msg.Block.Proposal.View, self.GetChainConfig().View)
+		self.msgPool.DropMsg(msg)
+		return
+	}
+
 	// verify VRF
 	proposerPk := self.peerPool.GetPeerPubKey(msg.Block.getProposer())
 	if proposerPk == nil {
@@ -1166,8 +1200,8 @@ func (self *Server) processProposalMsg(msg *blockProposalMsg) {
 		log.Errorf("verify cross chain message error:%+v\n", msg.Block.CrossChainMsg)
 		return
 	}
-	txs := msg.Block.Block.Transactions
-	if len(txs) > 0 && self.nonSystxs(txs, msgBlkNum) {
+	txs := self.userTxs(msg.Block.Block.Transactions, msgBlkNum)
+	if len(txs) > 0 {
 		height := msgBlkNum - 1
 		start, end := self.incrValidator.BlockRange()
 		if msg.GetBlockNum() <= self.GetCompletedBlockNum() {
@@ -1229,9 +1263,7 @@ func (self *Server) processMsgEvent() error {
 			if msgBlkNum == self.GetCurrentBlockNo() {
 				// add proposal to block-pool
 				if err := self.blockPool.newBlockProposal(pMsg); err != nil {
-					// if err == errDupProposal {
-					// 	// TODO: faulty proposer detected
-					// }
+					// faulty proposer is reported by checkEquivocation when the proposal received
 					log.Errorf("failed to add block proposal (%d): %s", msgBlkNum, err)
 					return nil
 				}
@@ -2103,6 +2135,8 @@ func (self *Server) sealBlock(block *Block, empty bool, sigdata bool) error {
 	self.timer.onBlockSealed(sealedBlkNum)
 	self.msgPool.onBlockSealed(sealedBlkNum)
 	self.blockPool.onBlockSealed(sealedBlkNum)
+	self.evidencePool.onBlockSealed(sealedBlkNum)
+	self.latencyTracker.onBlockSealed(sealedBlkNum)
 
 	_, h := self.blockPool.getSealedBlock(sealedBlkNum)
 	prevBlkHash := block.getPrevBlockHash()
@@ -2209,12 +2243,41 @@ func (self *Server) nonSystxs(sysTxs []*types.Transaction, blkNum uint32) bool {
 	return true
 }
 
+// userTxs returns the txs of the proposal to be verified by txpool. The commitDpos tx and the reportEvidence
+// txs are system txs without signature, which the proposer places before the user txs
+func (self *Server) userTxs(txs []*types.Transaction, blkNum uint32) []*types.Transaction {
+	if len(txs) == 0 || !self.nonSystxs(txs, blkNum) {
+		return nil
+	}
+	for i, tx := range txs {
+		if i == MAX_EVIDENCE_TXS {
+			return txs[i:]
+		}
+		if _, ok := getTxEvidence(tx); !ok {
+			return txs[i:]
+		}
+	}
+	return nil
+}
+
 func (self *Server) makeProposal(blkNum uint32, forEmpty bool) error {
 	if blkNum < self.GetCurrentBlockNo() {
 		return fmt.Errorf("server %d ignore deprecatd blk proposal %d, current %d",
 			self.Index, blkNum, self.GetCurrentBlockNo())
 	}
 
+	// one proposal for a round, a second one would be taken as equivocation,
+	// so the proposal signed before a restart is proposed again
+	if proposal := self.loadLastProposal(blkNum); proposal != nil {
+		log.Infof("server %d rebroadcast proposal for block %d", self.Index, blkNum)
+		if h, err := HashMsg(proposal); err == nil && !self.msgPool.HasMsg(proposal, h) {
+			self.msgPool.AddMsg(proposal, h)
+			self.processProposalMsg(proposal)
+		}
+		self.broadcast(proposal)
+		return nil
+	}
+
 	validHeight := self.validHeight(blkNum)
 	sysTxs := make([]*types.Transaction, 0)
 	userTxs := make([]*types.Transaction, 0)
@@ -2237,6 +2300,9 @@ func (self *Server) makeProposal(blkNum uint32, forEmpty bool) error {
 		}
 		forEmpty = true
 		cfg = chainconfig
+	} else {
+		// evidence may commit dpos, not along with a chain config update
+		sysTxs = append(sysTxs, self.createEvidenceTransactions(blkNum)...)
 	}
 	if self.nonConsensusNode() {
 		return fmt.Errorf("%d quit consensus node", self.Index)
@@ -2258,6 +2324,9 @@ func (self *Server) makeProposal(blkNum uint32, forEmpty bool) error {
 	}
 
 	log.Infof("server %d make proposal for block %d", self.Index, blkNum)
+	if err := self.saveLastProposal(proposal); err != nil {
+		return fmt.Errorf("failed to save proposal: %s", err)
+	}
 
 	// add proposal to self
 	h, _ := HashMsg(proposal)
@@ -2268,6 +2337,7 @@ func (self *Server) makeProposal(blkNum uint32, forEmpty bool) error {
 }
 
 func (self *Server) makeCommitment(proposal *blockProposalMsg, blkNum uint32, forEmpty bool) error {
+	self.latencyTracker.onEndorsed(blkNum)
 	if err := self.commitBlock(proposal, forEmpty); err != nil {
 		return fmt.Errorf("failed to commit block proposal (%d): %s", blkNum, err)
 	}
@@ -2276,6 +2346,7 @@ func (self *Server) makeCommitment(proposal *blockProposalMsg, blkNum uint32, fo
 
 func (self *Server) makeSealed(proposal *blockProposalMsg, forEmpty bool) error {
 	blkNum := proposal.GetBlockNum()
+	self.latencyTracker.onCommitted(blkNum)
 
 	if err := self.verifyPrevBlockHash(blkNum, proposal); err != nil {
 		// TODO: in-consistency with prev-blockhash, resync-required
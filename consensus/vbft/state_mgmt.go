@@ -21,6 +21,7 @@ package vbft
 import (
 	"fmt"
 	"math"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -46,6 +47,16 @@ const (
 	SyncingCheck     // potentially lost syncing
 )
 
+var serverStateNames = []string{"Init", "LocalConfigured", "Configured", "Syncing", "WaitNetworkReady", "SyncReady",
+	"Synced", "SyncingCheck"}
+
+func (state ServerState) String() string {
+	if int(state) < len(serverStateNames) {
+		return serverStateNames[state]
+	}
+	return fmt.Sprintf("ServerState(%d)", uint32(state))
+}
+
 func isReady(state ServerState) bool {
 	return state >= SyncReady
 }
@@ -88,6 +99,7 @@ type StateMgr struct {
 	syncReadyTimeout time.Duration
 	currentState     ServerState
 	StateEventC      chan *StateEvent
+	peersLock        sync.RWMutex // taken by the writers of peers and the readers out of the run loop
 	peers            map[uint32]*PeerState
 
 	liveTicker             *time.Timer
@@ -146,7 +158,7 @@ func (self *StateMgr) run() {
 				}
 			case UpdatePeerConfig:
 				peerIdx := evt.peerState.peerIdx
-				self.peers[peerIdx] = evt.peerState
+				self.setPeerState(evt.peerState)
 
 				if self.getState() >= LocalConfigured {
 					v := self.getSyncedChainConfigView()
@@ -188,6 +200,24 @@ func (self *StateMgr) run() {
 	}
 }
 
+func (self *StateMgr) setPeerState(peerState *PeerState) {
+	self.peersLock.Lock()
+	defer self.peersLock.Unlock()
+	self.peers[peerState.peerIdx] = peerState
+}
+
+// getPeerStates returns a copy of the peer states, safe to call out of the run loop
+func (self *StateMgr) getPeerStates() map[uint32]PeerState {
+	self.peersLock.RLock()
+	defer self.peersLock.RUnlock()
+
+	states := make(map[uint32]PeerState, len(self.peers))
+	for idx, state := range self.peers {
+		states[idx] = *state
+	}
+	return states
+}
+
 func (self *StateMgr) onPeerUpdate(peerState *PeerState) {
 	peerIdx := peerState.peerIdx
 	newPeer := false
@@ -199,7 +229,7 @@ func (self *StateMgr) onPeerUpdate(peerState *PeerState) {
 		self.server.Index, self.server.GetCurrentBlockNo(), self.getState(), peerState)
 
 	// update peer state
-	self.peers[peerIdx] = peerState
+	self.setPeerState(peerState)
 
 	if !newPeer {
 		if isActive(self.getState()) && peerState.committedBlockNum > self.server.GetCurrentBlockNo()+MAX_SYNCING_CHECK_BLK_NUM {
@@ -270,7 +300,9 @@ func (self *StateMgr) onPeerDisconnected(peerIdx uint32) {
 	if _, present := self.peers[peerIdx]; !present {
 		return
 	}
+	self.peersLock.Lock()
 	delete(self.peers, peerIdx)
+	self.peersLock.Unlock()
 
 	// start another connection if necessary
 	currentState := self.getState()
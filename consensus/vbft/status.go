@@ -0,0 +1,251 @@
+/*
+ * Copyright (C) 2018 The cntmology Authors
+ * This file is part of The cntmology library.
+ *
+ * The cntmology is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The cntmology is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * alcntm with The cntmology.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package vbft
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/cntmio/cntmology/common"
+	actorTypes "github.com/cntmio/cntmology/consensus/actor"
+)
+
+const LATENCY_HISTORY_LEN = 64
+
+type roundTiming struct {
+	start     time.Time
+	proposal  time.Time
+	endorsed  time.Time
+	committed time.Time
+}
+
+// LatencyTracker records when each round reaches its consensus phases, and keeps the phase latencies of the
+// latest sealed blocks
+type LatencyTracker struct {
+	lock      sync.Mutex
+	rounds    map[uint32]*roundTiming
+	latencies []*actorTypes.BlockLatency
+}
+
+func newLatencyTracker() *LatencyTracker {
+	return &LatencyTracker{
+		rounds: make(map[uint32]*roundTiming),
+	}
+}
+
+func (self *LatencyTracker) getRound(blockNum uint32) *roundTiming {
+	round := self.rounds[blockNum]
+	if round == nil {
+		round = &roundTiming{}
+		self.rounds[blockNum] = round
+	}
+	return round
+}
+
+// mark sets the time of the phase once, the first event of the phase wins
+func (self *LatencyTracker) mark(blockNum uint32, phase func(*roundTiming) *time.Time) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	if t := phase(self.getRound(blockNum)); t.IsZero() {
+		*t = time.Now()
+	}
+}
+
+func (self *LatencyTracker) onRoundStart(blockNum uint32) {
+	self.mark(blockNum, func(round *roundTiming) *time.Time { return &round.start })
+}
+
+func (self *LatencyTracker) onProposal(blockNum uint32) {
+	self.mark(blockNum, func(round *roundTiming) *time.Time { return &round.proposal })
+}
+
+func (self *LatencyTracker) onEndorsed(blockNum uint32) {
+	self.mark(blockNum, func(round *roundTiming) *time.Time { return &round.endorsed })
+}
+
+func (self *LatencyTracker) onCommitted(blockNum uint32) {
+	self.mark(blockNum, func(round *roundTiming) *time.Time { return &round.committed })
+}
+
+func elapsedMs(from, to time.Time) int64 {
+	if from.IsZero() || to.IsZero() || to.Before(from) {
+		return 0
+	}
+	return int64(to.Sub(from) / time.Millisecond)
+}
+
+func (self *LatencyTracker) onBlockSealed(blockNum uint32) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	round := self.getRound(blockNum)
+	now := time.Now()
+	self.latencies = append(self.latencies, &actorTypes.BlockLatency{
+		Height:   blockNum,
+		Proposal: elapsedMs(round.start, round.proposal),
+		Endorse:  elapsedMs(round.proposal, round.endorsed),
+		Commit:   elapsedMs(round.endorsed, round.committed),
+		Total:    elapsedMs(round.start, now),
+	})
+	if len(self.latencies) > LATENCY_HISTORY_LEN {
+		self.latencies = self.latencies[len(self.latencies)-LATENCY_HISTORY_LEN:]
+	}
+	for num := range self.rounds {
+		if num <= blockNum {
+			delete(self.rounds, num)
+		}
+	}
+}
+
+func (self *LatencyTracker) getRoundStart(blockNum uint32) time.Time {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	if round, present := self.rounds[blockNum]; present {
+		return round.start
+	}
+	return time.Time{}
+}
+
+func (self *LatencyTracker) getLatencies() []*actorTypes.BlockLatency {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	latencies := make([]*actorTypes.BlockLatency, 0, len(self.latencies))
+	for _, latency := range self.latencies {
+		l := *latency
+		latencies = append(latencies, &l)
+	}
+	return latencies
+}
+
+func unixMs(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano() / int64(time.Millisecond)
+}
+
+func (self *Server) getRoundStatus(blkNum uint32) *actorTypes.ConsensusRoundStatus {
+	status := &actorTypes.ConsensusRoundStatus{
+		Height:       blkNum,
+		StartTime:    unixMs(self.latencyTracker.getRoundStart(blkNum)),
+		Proposals:    make([]*actorTypes.ConsensusRoundMsg, 0),
+		Endorsements: make([]*actorTypes.ConsensusRoundMsg, 0),
+		Commits:      make([]*actorTypes.ConsensusRoundMsg, 0),
+	}
+	self.metaLock.RLock()
+	if cfg := self.currentParticipantConfig; cfg != nil && cfg.BlockNum == blkNum {
+		status.Proposers = append(status.Proposers, cfg.Proposers...)
+		status.Endorsers = append(status.Endorsers, cfg.Endorsers...)
+		status.Committers = append(status.Committers, cfg.Committers...)
+	}
+	self.metaLock.RUnlock()
+
+	for _, msg := range self.msgPool.GetProposalMsgs(blkNum) {
+		if p, ok := msg.(*blockProposalMsg); ok && p != nil {
+			var hash common.Uint256
+			if p.Block.Block != nil {
+				hash = p.Block.Block.Hash()
+			}
+			status.Proposals = append(status.Proposals, &actorTypes.ConsensusRoundMsg{
+				Sender:    p.Block.getProposer(),
+				Proposer:  p.Block.getProposer(),
+				BlockHash: hash.ToHexString(),
+			})
+		}
+	}
+	for _, msg := range self.msgPool.GetEndorsementsMsgs(blkNum) {
+		if e, ok := msg.(*blockEndorseMsg); ok && e != nil {
+			status.Endorsements = append(status.Endorsements, &actorTypes.ConsensusRoundMsg{
+				Sender:    e.Endorser,
+				Proposer:  e.EndorsedProposer,
+				BlockHash: e.EndorsedBlockHash.ToHexString(),
+				ForEmpty:  e.EndorseForEmpty,
+			})
+		}
+	}
+	for _, msg := range self.msgPool.GetCommitMsgs(blkNum) {
+		if c, ok := msg.(*blockCommitMsg); ok && c != nil {
+			status.Commits = append(status.Commits, &actorTypes.ConsensusRoundMsg{
+				Sender:    c.Committer,
+				Proposer:  c.BlockProposer,
+				BlockHash: c.CommitBlockHash.ToHexString(),
+				ForEmpty:  c.CommitForEmpty,
+			})
+		}
+	}
+	return status
+}
+
+func (self *Server) getPeerStatus() []*actorTypes.ConsensusPeerStatus {
+	states := self.stateMgr.getPeerStates()
+	peers := make([]*actorTypes.ConsensusPeerStatus, 0)
+	for _, cfg := range self.GetChainConfig().Peers {
+		if cfg.Index == self.Index {
+			ccntminue
+		}
+		status := &actorTypes.ConsensusPeerStatus{
+			Index:  cfg.Index,
+			PubKey: cfg.ID,
+		}
+		if peer := self.peerPool.getPeer(cfg.Index); peer != nil {
+			status.Connected = peer.connected
+			if peer.LatestInfo != nil {
+				status.LastHeartbeat = unixMs(peer.LastUpdateTime)
+			}
+		}
+		if state, present := states[cfg.Index]; present {
+			status.ChainConfigView = state.chainConfigView
+			status.CommittedHeight = state.committedBlockNum
+		}
+		peers = append(peers, status)
+	}
+	return peers
+}
+
+// getConsensusStatus collects the live status of the consensus for the operators, to tell which peer holds
+// the round back
+func (self *Server) getConsensusStatus() *actorTypes.ConsensusStatus {
+	blkNum := self.GetCurrentBlockNo()
+	status := &actorTypes.ConsensusStatus{
+		State:           self.getState().String(),
+		Index:           self.Index,
+		Height:          blkNum,
+		CommittedHeight: self.GetCommittedBlockNo(),
+		View:            self.GetChainConfig().View,
+		Peers:           self.getPeerStatus(),
+		Round:           self.getRoundStatus(blkNum),
+		Timers:          make([]*actorTypes.ConsensusTimerStatus, 0),
+		Latencies:       self.latencyTracker.getLatencies(),
+	}
+	for _, d := range self.timer.getDeadlines() {
+		status.Timers = append(status.Timers, &actorTypes.ConsensusTimerStatus{
+			Event:    d.evtType.String(),
+			Height:   d.blockNum,
+			Deadline: unixMs(d.deadline),
+		})
+	}
+	sort.Slice(status.Timers, func(i, j int) bool {
+		return status.Timers[i].Deadline < status.Timers[j].Deadline
+	})
+	return status
+}
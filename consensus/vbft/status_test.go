@@ -0,0 +1,74 @@
+/*
+ * Copyright (C) 2018 The cntmology Authors
+ * This file is part of The cntmology library.
+ *
+ * The cntmology is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The cntmology is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * alcntm with The cntmology.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package vbft
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLatencyTracker(t *testing.T) {
+	tracker := newLatencyTracker()
+	for blkNum := uint32(1); blkNum <= LATENCY_HISTORY_LEN+2; blkNum++ {
+		tracker.onRoundStart(blkNum)
+		time.Sleep(time.Millisecond)
+		tracker.onProposal(blkNum)
+		tracker.onEndorsed(blkNum)
+		tracker.onEndorsed(blkNum)
+		tracker.onCommitted(blkNum)
+		if tracker.getRoundStart(blkNum).IsZero() {
+			t.Fatalf("round %d not started", blkNum)
+		}
+		tracker.onBlockSealed(blkNum)
+	}
+	// fast forwarded blocks skip the phases
+	tracker.onBlockSealed(LATENCY_HISTORY_LEN + 3)
+
+	latencies := tracker.getLatencies()
+	if len(latencies) != LATENCY_HISTORY_LEN {
+		t.Fatalf("%d latencies kept", len(latencies))
+	}
+	if latencies[0].Height != 4 {
+		t.Fatalf("oldest latency of block %d", latencies[0].Height)
+	}
+	for _, latency := range latencies[:len(latencies)-1] {
+		if latency.Proposal < 1 || latency.Total < latency.Proposal+latency.Endorse+latency.Commit {
+			t.Fatalf("invalid latency of block %d: %+v", latency.Height, latency)
+		}
+	}
+	last := latencies[len(latencies)-1]
+	if last.Proposal != 0 || last.Total != 0 {
+		t.Fatalf("invalid latency of fast forwarded block: %+v", last)
+	}
+}
+
+func TestTimerDeadlines(t *testing.T) {
+	eventtimer := constructEventTimer()
+	if err := eventtimer.StartCommitTimer(5); err != nil {
+		t.Fatalf("start commit timer: %s", err)
+	}
+	deadlines := eventtimer.getDeadlines()
+	if len(deadlines) != 1 || deadlines[0].evtType.String() != "CommitBlockTimeout" || deadlines[0].blockNum != 5 {
+		t.Fatalf("invalid deadlines: %v", deadlines)
+	}
+	eventtimer.onBlockSealed(5)
+	if len(eventtimer.getDeadlines()) != 0 {
+		t.Fatalf("deadlines left after block sealed")
+	}
+}
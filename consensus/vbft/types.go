@@ -34,6 +34,7 @@ type Block struct {
 	Info               *vconfig.VbftBlockInfo
 	PrevExecMerkleRoot common.Uint256
 	CrossChainMsg      *types.CrossChainMsg
+	Proposal           *vconfig.Proposal // nil if proposed by a peer not signing the proposal digest
 }
 
 func (blk *Block) getProposer() uint32 {
@@ -84,6 +85,10 @@ func (blk *Block) Serialize() []byte {
 	if blk.CrossChainMsg != nil {
 		blk.CrossChainMsg.Serialization(payload)
 	}
+	payload.WriteBool(blk.Proposal != nil)
+	if blk.Proposal != nil {
+		blk.Proposal.Serialization(payload)
+	}
 	return payload.Bytes()
 }
 
@@ -146,11 +151,25 @@ func (blk *Block) Deserialize(data []byte) error {
 			return err
 		}
 	}
+
+	var proposal *vconfig.Proposal
+	// ignore eof for backward compatibility
+	hasProposal, irr, _ := source.NextBool()
+	if irr {
+		return fmt.Errorf("read proposal-bool: %s", common.ErrIrregularData)
+	}
+	if hasProposal {
+		proposal = new(vconfig.Proposal)
+		if err := proposal.Deserialization(source); err != nil {
+			return fmt.Errorf("deserialize proposal: %s", err)
+		}
+	}
 	blk.Block = block
 	blk.EmptyBlock = emptyBlock
 	blk.Info = info
 	blk.PrevExecMerkleRoot = merkleRoot
 	blk.CrossChainMsg = crossChainMsg
+	blk.Proposal = proposal
 	return nil
 }
 
@@ -202,6 +202,28 @@ func TestSerialize(t *testing.T) {
 	t.Log("Block Serialize succ")
 }
 
+func TestSerializeProposal(t *testing.T) {
+	blk, err := constructBlock()
+	if err != nil {
+		t.Fatalf("constructBlock failed: %v", err)
+	}
+	decoded := &Block{}
+	if err := decoded.Deserialize(blk.Serialize()); err != nil {
+		t.Fatalf("deserialize block without proposal: %v", err)
+	}
+	if decoded.Proposal != nil {
+		t.Fatalf("proposal of block without proposal")
+	}
+
+	blk.Proposal = &vconfig.Proposal{View: 2, Height: 1, BlockHash: blk.Block.Hash(), Sig: []byte{1, 2, 3}}
+	if err := decoded.Deserialize(blk.Serialize()); err != nil {
+		t.Fatalf("deserialize block with proposal: %v", err)
+	}
+	if !reflect.DeepEqual(decoded.Proposal, blk.Proposal) {
+		t.Fatalf("proposal %+v, expect %+v", decoded.Proposal, blk.Proposal)
+	}
+}
+
 func TestInitVbftBlock(t *testing.T) {
 	blk, err := constructBlock()
 	if err != nil {
@@ -28,6 +28,7 @@ import (
 	"github.com/cntmio/cntmology-crypto/keypair"
 	"github.com/cntmio/cntmology-crypto/vrf"
 	"github.com/cntmio/cntmology/account"
+	"github.com/cntmio/cntmology/account/signer"
 	"github.com/cntmio/cntmology/common"
 	"github.com/cntmio/cntmology/common/config"
 	vconfig "github.com/cntmio/cntmology/consensus/vbft/config"
@@ -95,18 +96,36 @@ type vrfData struct {
 	PrevVrf  []byte `json:"prev_vrf"`
 }
 
-func computeVrf(sk keypair.PrivateKey, blkNum uint32, prevVrf []byte) ([]byte, []byte, error) {
+func vrfInput(blkNum uint32, prevVrf []byte) ([]byte, error) {
 	data, err := json.Marshal(&vrfData{
 		BlockNum: blkNum,
 		PrevVrf:  prevVrf,
 	})
 	if err != nil {
-		return nil, nil, fmt.Errorf("computeVrf failed to marshal vrfData: %s", err)
+		return nil, fmt.Errorf("computeVrf failed to marshal vrfData: %s", err)
+	}
+	return data, nil
+}
+
+func computeVrf(sk keypair.PrivateKey, blkNum uint32, prevVrf []byte) ([]byte, []byte, error) {
+	data, err := vrfInput(blkNum, prevVrf)
+	if err != nil {
+		return nil, nil, err
 	}
 
 	return vrf.Vrf(sk, data)
 }
 
+//computeVrfBySigner compute the vrf with the key held by signer, which may be remote
+func computeVrfBySigner(s signer.Signer, blkNum uint32, prevVrf []byte) ([]byte, []byte, error) {
+	data, err := vrfInput(blkNum, prevVrf)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return s.Vrf(data)
+}
+
 func verifyVrf(pk keypair.PublicKey, blkNum uint32, prevVrf, newVrf, proof []byte) error {
 	data, err := json.Marshal(&vrfData{
 		BlockNum: blkNum,
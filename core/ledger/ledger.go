@@ -20,17 +20,25 @@ package ledger
 
 import (
 	"fmt"
+	"io"
+	"time"
 
+	common2 "github.com/ethereum/go-ethereum/common"
+	types2 "github.com/ethereum/go-ethereum/core/types"
 	"github.com/conntectome/cntm-crypto/keypair"
 	"github.com/conntectome/cntm/common"
 	"github.com/conntectome/cntm/common/log"
 	"github.com/conntectome/cntm/core/payload"
 	"github.com/conntectome/cntm/core/states"
 	"github.com/conntectome/cntm/core/store"
+	scom "github.com/conntectome/cntm/core/store/common"
 	"github.com/conntectome/cntm/core/store/ledgerstore"
 	"github.com/conntectome/cntm/core/types"
 	"github.com/conntectome/cntm/smartcontract/event"
+	types3 "github.com/conntectome/cntm/smartcontract/service/evm/types"
 	cstate "github.com/conntectome/cntm/smartcontract/states"
+	"github.com/conntectome/cntm/smartcontract/storage"
+	"github.com/conntectome/cntm/vm/evm"
 )
 
 var DefLedger *Ledger
@@ -188,6 +196,124 @@ func (self *Ledger) PreExecuteContractBatch(txes []*types.Transaction, atomic bo
 	return self.ldgStore.PreExecuteContractBatch(txes, atomic)
 }
 
+func (self *Ledger) TraceEip155Tx(msg types2.Message, height uint32, vmConfig evm.Config, timeout time.Duration) (*types3.ExecutionResult, error) {
+	return self.ldgStore.TraceEip155Tx(msg, height, vmConfig, timeout)
+}
+
+func (self *Ledger) TraceTransaction(txHash common.Uint256, vmConfig evm.Config, timeout time.Duration) (*types3.ExecutionResult, error) {
+	return self.ldgStore.TraceTransaction(txHash, vmConfig, timeout)
+}
+
+func (self *Ledger) GetBlockBloom(height uint32) (types2.Bloom, error) {
+	return self.ldgStore.GetBlockBloom(height)
+}
+
+func (self *Ledger) GetBloomBits(bit uint, section uint32) ([]byte, error) {
+	return self.ldgStore.GetBloomBits(bit, section)
+}
+
+func (self *Ledger) BloomStatus() (uint32, uint32) {
+	return self.ldgStore.BloomStatus()
+}
+
+//ExportStateSnapshot writes the snapshot of the state after the current block to w
+func (self *Ledger) ExportStateSnapshot(w io.Writer) (*store.SnapshotInfo, common.Uint256, error) {
+	return self.ldgStore.ExportStateSnapshot(w)
+}
+
+//ImportStateSnapshot replaces the ledger holding the genesis block only with the snapshot file, an untrusted
+//snapshot is checked against the state trie root committed to by the synced headers
+func (self *Ledger) ImportStateSnapshot(path string, trusted bool) (*store.SnapshotInfo, error) {
+	return self.ldgStore.ImportStateSnapshot(path, trusted)
+}
+
+//LatestStateSnapshot returns the latest snapshot exported every SnapshotInterval blocks
+func (self *Ledger) LatestStateSnapshot() (*store.SnapshotFile, error) {
+	return self.ldgStore.LatestStateSnapshot()
+}
+
+//RevertToHeight rolls the ledger back to the block at height, it is used by the dev chain
+func (self *Ledger) RevertToHeight(height uint32) error {
+	return self.ldgStore.RevertToHeight(height)
+}
+
+func (self *Ledger) ArchiveStartHeight() (uint32, error) {
+	return self.ldgStore.ArchiveStartHeight()
+}
+
+func (self *Ledger) GetStorageItemAt(codeHash common.Address, key []byte, height uint32) ([]byte, error) {
+	storageKey := &states.StorageKey{
+		ContractAddress: codeHash,
+		Key:             key,
+	}
+	storageItem, err := self.ldgStore.GetStorageItemAt(storageKey, height)
+	if err != nil {
+		return nil, err
+	}
+	if storageItem == nil {
+		return nil, nil
+	}
+	return storageItem.Value, nil
+}
+
+func (self *Ledger) GetContractStateAt(contractHash common.Address, height uint32) (*payload.DeployCode, error) {
+	return self.ldgStore.GetContractStateAt(contractHash, height)
+}
+
+func (self *Ledger) PreExecuteContractBatchAt(txes []*types.Transaction, height uint32) ([]*cstate.PreExecResult, error) {
+	return self.ldgStore.PreExecuteContractBatchAt(txes, height)
+}
+
+func (self *Ledger) GetEthAccountAt(address common2.Address, height uint32) (*storage.EthAccount, error) {
+	overlay, err := self.ldgStore.NewOverlayDBAt(height)
+	if err != nil {
+		return nil, err
+	}
+	account, err := storage.NewCacheDB(overlay).GetEthAccount(address)
+	if err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
+
+func (self *Ledger) GetEthStateAt(address common2.Address, key common2.Hash, height uint32) ([]byte, error) {
+	overlay, err := self.ldgStore.NewOverlayDBAt(height)
+	if err != nil {
+		return nil, err
+	}
+	return storage.NewCacheDB(overlay).Get(append(address.Bytes(), key.Bytes()...))
+}
+
+//GetStateTrieHeight returns the height from which the state trie is enabled
+func (self *Ledger) GetStateTrieHeight() uint32 {
+	return self.ldgStore.GetStateTrieHeight()
+}
+
+//GetStateTrieRoot returns the state trie root after the block at height
+func (self *Ledger) GetStateTrieRoot(height uint32) (common.Uint256, error) {
+	return self.ldgStore.GetStateTrieRoot(height)
+}
+
+//GetStorageProof returns the proof of the storage value of the key in smart contract after the block at height
+func (self *Ledger) GetStorageProof(codeHash common.Address, key []byte, height uint32) (*types.StorageProof, error) {
+	storeKey := make([]byte, 0, 1+common.ADDR_LEN+len(key))
+	storeKey = append(storeKey, byte(scom.ST_STORAGE))
+	storeKey = append(storeKey, codeHash[:]...)
+	storeKey = append(storeKey, key...)
+	return self.ldgStore.GetStorageProof(storeKey, height)
+}
+
+//GetEthAccountProof returns the proof of the EVM account after the block at height
+func (self *Ledger) GetEthAccountProof(address common2.Address, height uint32) (*types.StorageProof, error) {
+	key := append([]byte{byte(scom.ST_ETH_ACCOUNT)}, address.Bytes()...)
+	return self.ldgStore.GetStorageProof(key, height)
+}
+
+//GetEthStateProof returns the proof of the EVM storage slot after the block at height
+func (self *Ledger) GetEthStateProof(address common2.Address, key common2.Hash, height uint32) (*types.StorageProof, error) {
+	return self.GetStorageProof(common.Address(address), key.Bytes(), height)
+}
+
 func (self *Ledger) GetEventNotifyByTx(tx common.Uint256) (*event.ExecuteNotify, error) {
 	return self.ldgStore.GetEventNotifyByTx(tx)
 }
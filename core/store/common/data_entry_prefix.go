@@ -32,6 +32,7 @@ const (
 	ST_BOOKKEEPER DataEntryPrefix = 0x03 //BookKeeper state key prefix
 	ST_CCNTMRACT   DataEntryPrefix = 0x04 //Smart contract state key prefix
 	ST_STORAGE    DataEntryPrefix = 0x05 //Smart contract storage key prefix
+	ST_DESTROYED  DataEntryPrefix = 0x06 //Destroyed smart contract prefix
 	ST_VALIDATOR  DataEntryPrefix = 0x07 //no use
 	ST_VOTE       DataEntryPrefix = 0x08 //Vote state key prefix
 
@@ -45,5 +46,23 @@ const (
 	SYS_STATE_MERKLE_TREE    DataEntryPrefix = 0x20 // state merkle tree root key prefix
 	SYS_CROSS_CHAIN_MSG      DataEntryPrefix = 0x22 // state merkle tree root key prefix
 
-	EVENT_NOTIFY DataEntryPrefix = 0x14 //Event notify key prefix
+	EVENT_NOTIFY      DataEntryPrefix = 0x14 //Event notify key prefix
+	EVENT_LOGS_BLOOM  DataEntryPrefix = 0x15 //Block height => logs bloom of the block
+	EVENT_BLOOM_BITS  DataEntryPrefix = 0x16 //Bloom bit + section => compressed bloom bits of the section
+	SYS_BLOOM_INDEXED DataEntryPrefix = 0x17 //Next block height to add to the logs bloom index
+
+	ST_ARCHIVE         DataEntryPrefix = 0x18 //State key + block height => value of the key before the block
+	SYS_ARCHIVE_HEIGHT DataEntryPrefix = 0x19 //First and last block heights of the state archive
+	ST_ARCHIVE_KEYS    DataEntryPrefix = 0x1a //Block height => state keys archived for the block
+	SYS_PRUNE_HEIGHT   DataEntryPrefix = 0x1b //Height of the next block whose state history is pruned
+
+	ST_TRIE_NODE         DataEntryPrefix = 0x1c //Node hash => node of the state trie
+	DATA_STATE_TRIE_ROOT DataEntryPrefix = 0x1d //Block height => change hash + state trie root after the block
+	SYS_SNAPSHOT_HEIGHT  DataEntryPrefix = 0x1e //Height of the state snapshot the state store was imported from
+	ST_SNAPSHOT_LEAF     DataEntryPrefix = 0x1f //Key hash => value hash of a state trie leaf, while a snapshot is imported
+	ST_TRIE_STALE        DataEntryPrefix = 0x23 //Block height => hashes of the state trie nodes the block replaced
+	ST_TRIE_STALE_NODE   DataEntryPrefix = 0x24 //Node hash => height of the last block which replaced the node
+
+	ST_ETH_CODE    DataEntryPrefix = 0x30 //EVM contract code hash => code
+	ST_ETH_ACCOUNT DataEntryPrefix = 0x31 //EVM account address => nonce + code hash
 )
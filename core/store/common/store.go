@@ -27,6 +27,21 @@ import (
 
 var ErrNotFound = errors.New("not found")
 
+//InvalidBlockError is returned when a block fails verification, as opposed to a failure of the local store
+type InvalidBlockError struct {
+	Err error
+}
+
+func (self *InvalidBlockError) Error() string {
+	return self.Err.Error()
+}
+
+//IsInvalidBlock check whether err is caused by the block rather than the local store
+func IsInvalidBlock(err error) bool {
+	_, ok := err.(*InvalidBlockError)
+	return ok
+}
+
 //Store iterator for iterate store
 type StoreIterator interface {
 	Next() bool //Next item. If item available return true, otherwise return false
@@ -102,3 +102,8 @@ func (this *BlockCache) GetTransaction(txHash common.Uint256) (*types.Transactio
 func (this *BlockCache) ContainTransaction(txHash common.Uint256) bool {
 	return this.transactionCache.Contains(string(txHash.ToArray()))
 }
+
+//RemoveTransaction remove transaction from cache
+func (this *BlockCache) RemoveTransaction(txHash common.Uint256) {
+	this.transactionCache.Remove(string(txHash.ToArray()))
+}
@@ -23,6 +23,7 @@ import (
 	"encoding/binary"
 	"fmt"
 	"io"
+	"math"
 
 	"github.com/conntectome/cntm/common"
 	"github.com/conntectome/cntm/common/serialization"
@@ -31,6 +32,9 @@ import (
 	"github.com/conntectome/cntm/core/types"
 )
 
+//HEADER_ONLY_TX_COUNT is the transaction count of the headers saved without their transactions
+const HEADER_ONLY_TX_COUNT = math.MaxUint32
+
 //Block store save the data of block & transaction
 type BlockStore struct {
 	enableCache bool                       //Is enable lru cache
@@ -92,8 +96,7 @@ func (this *BlockStore) ContainBlock(blockHash common.Uint256) (bool, error) {
 			return true, nil
 		}
 	}
-	key := genHeaderKey(blockHash)
-	_, err := this.store.Get(key)
+	_, _, err := this.loadHeaderWithTx(blockHash)
 	if err != nil {
 		if err == scom.ErrNotFound {
 			return false, nil
@@ -155,6 +158,9 @@ func (this *BlockStore) loadHeaderWithTx(blockHash common.Uint256) (*types.Heade
 	if eof {
 		return nil, nil, io.ErrUnexpectedEOF
 	}
+	if txSize == HEADER_ONLY_TX_COUNT {
+		return nil, nil, scom.ErrNotFound
+	}
 	txHashes := make([]common.Uint256, 0, int(txSize))
 	for i := uint32(0); i < txSize; i++ {
 		txHash, eof := source.NextHash()
@@ -182,6 +188,18 @@ func (this *BlockStore) SaveHeader(block *types.Block, sysFee common.Fixed64) er
 	return nil
 }
 
+//SaveHeaderOnly persist a block header whose transactions are not saved, the block is not contained in the store
+//until it is saved with SaveBlock
+func (this *BlockStore) SaveHeaderOnly(header *types.Header) {
+	key := this.getHeaderKey(header.Hash())
+	sink := common.NewZeroCopySink(nil)
+	var sysFee common.Fixed64
+	sysFee.Serialization(sink)
+	header.Serialization(sink)
+	sink.WriteUint32(HEADER_ONLY_TX_COUNT)
+	this.store.BatchPut(key, sink.Bytes())
+}
+
 //GetHeader return the header specified by block hash
 func (this *BlockStore) GetHeader(blockHash common.Uint256) (*types.Header, error) {
 	if this.enableCache {
@@ -348,6 +366,16 @@ func (this *BlockStore) SaveBlockHash(height uint32, blockHash common.Uint256) {
 	this.store.BatchPut(key, blockHash.ToArray())
 }
 
+//DeleteBlockHash delete the block hash of height from store
+func (this *BlockStore) DeleteBlockHash(height uint32) {
+	this.store.BatchDelete(this.getBlockHashKey(height))
+}
+
+//DeleteHeaderIndexList delete the header index list starting at startIndex from store
+func (this *BlockStore) DeleteHeaderIndexList(startIndex uint32) {
+	this.store.BatchDelete(this.getHeaderIndexListKey(startIndex))
+}
+
 //SaveTransaction persist transaction to store
 func (this *BlockStore) SaveTransaction(tx *types.Transaction, height uint32) {
 	if this.enableCache {
@@ -365,6 +393,14 @@ func (this *BlockStore) putTransaction(tx *types.Transaction, height uint32) {
 	this.store.BatchPut(key, value.Bytes())
 }
 
+//DeleteTransaction delete transaction from store
+func (this *BlockStore) DeleteTransaction(txHash common.Uint256) {
+	if this.enableCache {
+		this.cache.RemoveTransaction(txHash)
+	}
+	this.store.BatchDelete(this.getTransactionKey(txHash))
+}
+
 //GetTransaction return transaction by transaction hash
 func (this *BlockStore) GetTransaction(txHash common.Uint256) (*types.Transaction, uint32, error) {
 	if this.enableCache {
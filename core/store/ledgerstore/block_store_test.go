@@ -290,6 +290,41 @@ func TestSaveHeader(t *testing.T) {
 	}
 }
 
+func TestSaveHeaderOnly(t *testing.T) {
+	header := &types.Header{
+		Version:   123,
+		Timestamp: uint32(time.Date(2017, time.February, 23, 0, 0, 0, 0, time.UTC).Unix()),
+		Height:    uint32(2),
+	}
+	blockHash := header.Hash()
+
+	testBlockStore.NewBatch()
+	testBlockStore.SaveHeaderOnly(header)
+	err := testBlockStore.CommitTo()
+	if err != nil {
+		t.Errorf("CommitTo error %s", err)
+		return
+	}
+
+	h, err := testBlockStore.GetHeader(blockHash)
+	assert.Nil(t, err)
+	assert.Equal(t, blockHash, h.Hash())
+	//the block is not contained until its transactions are saved
+	exist, err := testBlockStore.ContainBlock(blockHash)
+	assert.Nil(t, err)
+	assert.False(t, exist)
+	_, err = testBlockStore.GetBlock(blockHash)
+	assert.NotNil(t, err)
+
+	testBlockStore.NewBatch()
+	err = testBlockStore.SaveBlock(&types.Block{Header: header, Transactions: []*types.Transaction{}})
+	assert.Nil(t, err)
+	assert.Nil(t, testBlockStore.CommitTo())
+	exist, err = testBlockStore.ContainBlock(blockHash)
+	assert.Nil(t, err)
+	assert.True(t, exist)
+}
+
 func TestBlock(t *testing.T) {
 	acc1 := account.NewAccount("")
 	acc2 := account.NewAccount("")
@@ -0,0 +1,147 @@
+/*
+ * Copyright (C) 2018 The cntm Authors
+ * This file is part of The cntm library.
+ *
+ * The cntm is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The cntm is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The cntm.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package ledgerstore
+
+import (
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/core/bloombits"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/conntectome/cntm/common/log"
+	scom "github.com/conntectome/cntm/core/store/common"
+	"github.com/conntectome/cntm/smartcontract/event"
+)
+
+//BLOOM_BITS_BLOCKS is the number of blocks a single bloom bits section covers
+const BLOOM_BITS_BLOCKS = uint32(4096)
+
+//blockLogsBloom return the bloom of the evm logs in the event notifies of a block
+func blockLogsBloom(notifies []*event.ExecuteNotify) types.Bloom {
+	var logs []*types.Log
+	for _, notify := range notifies {
+		for _, n := range notify.Notify {
+			if !n.IsEvm {
+				continue
+			}
+			storageLog, err := event.NotifyEventInfoToEvmLog(n)
+			if err != nil {
+				log.Warnf("blockLogsBloom: tx %s has invalid evm log: %s", notify.TxHash.ToHexString(), err)
+				continue
+			}
+			logs = append(logs, &types.Log{Address: storageLog.Address, Topics: storageLog.Topics})
+		}
+	}
+	return types.BytesToBloom(types.LogsBloom(logs))
+}
+
+//saveBlockBloom add the logs bloom of the block at height to the event store batch. When the block completes a
+//section, the bloom bits of the section are saved too, the blooms of the previous blocks must be committed.
+func (this *LedgerStoreImp) saveBlockBloom(height uint32, bloom types.Bloom) error {
+	this.eventStore.SaveBlockBloom(height, bloom)
+	this.eventStore.SaveBloomIndexed(height + 1)
+	if (height+1)%BLOOM_BITS_BLOCKS != 0 {
+		return nil
+	}
+	section := height / BLOOM_BITS_BLOCKS
+	gen, err := bloombits.NewGenerator(uint(BLOOM_BITS_BLOCKS))
+	if err != nil {
+		return err
+	}
+	start := section * BLOOM_BITS_BLOCKS
+	for h := start; h < height; h++ {
+		b, err := this.eventStore.GetBlockBloom(h)
+		if err != nil {
+			return fmt.Errorf("GetBlockBloom height:%d error %s", h, err)
+		}
+		if err = gen.AddBloom(uint(h-start), b); err != nil {
+			return err
+		}
+	}
+	if err = gen.AddBloom(uint(height-start), bloom); err != nil {
+		return err
+	}
+	for bit := uint(0); bit < types.BloomBitLength; bit++ {
+		bits, err := gen.Bitset(bit)
+		if err != nil {
+			return err
+		}
+		this.eventStore.SaveBloomBits(bit, section, bits)
+	}
+	log.Debugf("bloom bits section %d saved", section)
+	return nil
+}
+
+//initBloomIndex backfill the logs blooms of the blocks saved before the bloom index existed. It runs before
+//recoverStore, so only the blocks already saved to the state store are backfilled, the others are indexed
+//when recoverStore saves them again.
+func (this *LedgerStoreImp) initBloomIndex() error {
+	next, err := this.eventStore.GetBloomIndexed()
+	if err != nil {
+		if err != scom.ErrNotFound {
+			return fmt.Errorf("GetBloomIndexed error %s", err)
+		}
+		next = 0
+	}
+	_, current, err := this.stateStore.GetCurrentBlock()
+	if err != nil {
+		return fmt.Errorf("stateStore.GetCurrentBlock error %s", err)
+	}
+	if next > current {
+		return nil
+	}
+	log.Infof("backfill logs bloom index from height %d to %d", next, current)
+	this.eventStore.NewBatch()
+	for height := next; height <= current; height++ {
+		notifies, err := this.eventStore.GetEventNotifyByBlock(height)
+		if err != nil && err != scom.ErrNotFound {
+			return fmt.Errorf("GetEventNotifyByBlock height:%d error %s", height, err)
+		}
+		if (height+1)%BLOOM_BITS_BLOCKS == 0 {
+			// the section bloom bits are generated from the committed blooms
+			if err = this.eventStore.CommitTo(); err != nil {
+				return fmt.Errorf("eventStore.CommitTo height:%d error %s", height, err)
+			}
+			this.eventStore.NewBatch()
+		}
+		if err = this.saveBlockBloom(height, blockLogsBloom(notifies)); err != nil {
+			return err
+		}
+	}
+	return this.eventStore.CommitTo()
+}
+
+//GetBlockBloom return the logs bloom of the block at height
+func (this *LedgerStoreImp) GetBlockBloom(height uint32) (types.Bloom, error) {
+	return this.eventStore.GetBlockBloom(height)
+}
+
+//GetBloomBits return the bits of one bloom bit index for the blocks of section,
+//bit i of the result is set if the bloom bit is set in block section*BLOOM_BITS_BLOCKS+i
+func (this *LedgerStoreImp) GetBloomBits(bit uint, section uint32) ([]byte, error) {
+	return this.eventStore.GetBloomBits(bit, section)
+}
+
+//BloomStatus return the number of blocks a bloom bits section covers, and the number of sections saved
+func (this *LedgerStoreImp) BloomStatus() (uint32, uint32) {
+	next, err := this.eventStore.GetBloomIndexed()
+	if err != nil {
+		return BLOOM_BITS_BLOCKS, 0
+	}
+	return BLOOM_BITS_BLOCKS, next / BLOOM_BITS_BLOCKS
+}
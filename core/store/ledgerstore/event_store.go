@@ -24,6 +24,8 @@ import (
 	"encoding/json"
 	"fmt"
 
+	"github.com/ethereum/go-ethereum/common/bitutil"
+	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/conntectome/cntm/common"
 	"github.com/conntectome/cntm/common/log"
 	"github.com/conntectome/cntm/common/serialization"
@@ -181,6 +183,58 @@ func (this *EventStore) GetCurrentBlock() (common.Uint256, uint32, error) {
 	return blockHash, height, nil
 }
 
+//SaveBlockBloom persist the logs bloom of the block at height
+func (this *EventStore) SaveBlockBloom(height uint32, bloom types.Bloom) {
+	this.store.BatchPut(genLogsBloomKey(height), bloom.Bytes())
+}
+
+//GetBlockBloom return the logs bloom of the block at height
+func (this *EventStore) GetBlockBloom(height uint32) (types.Bloom, error) {
+	data, err := this.store.Get(genLogsBloomKey(height))
+	if err != nil {
+		return types.Bloom{}, err
+	}
+	if len(data) != types.BloomByteLength {
+		return types.Bloom{}, fmt.Errorf("invalid logs bloom length %d at height %d", len(data), height)
+	}
+	return types.BytesToBloom(data), nil
+}
+
+//SaveBloomBits persist the bits of one bloom bit index for all the blocks of section, bits is compressed before saved
+func (this *EventStore) SaveBloomBits(bit uint, section uint32, bits []byte) {
+	this.store.BatchPut(genBloomBitsKey(bit, section), bitutil.CompressBytes(bits))
+}
+
+//GetBloomBits return the decompressed bits of one bloom bit index for all the blocks of section
+func (this *EventStore) GetBloomBits(bit uint, section uint32) ([]byte, error) {
+	data, err := this.store.Get(genBloomBitsKey(bit, section))
+	if err != nil {
+		return nil, err
+	}
+	return bitutil.DecompressBytes(data, int(BLOOM_BITS_BLOCKS/8))
+}
+
+//SaveBloomIndexed persist the next block height whose logs bloom is not saved yet
+func (this *EventStore) SaveBloomIndexed(height uint32) {
+	value := common.NewZeroCopySink(nil)
+	value.WriteUint32(height)
+	this.store.BatchPut([]byte{byte(scom.SYS_BLOOM_INDEXED)}, value.Bytes())
+}
+
+//GetBloomIndexed return the next block height whose logs bloom is not saved yet,
+//ErrNotFound means no block bloom was ever saved
+func (this *EventStore) GetBloomIndexed() (uint32, error) {
+	data, err := this.store.Get([]byte{byte(scom.SYS_BLOOM_INDEXED)})
+	if err != nil {
+		return 0, err
+	}
+	height, eof := common.NewZeroCopySource(data).NextUint32()
+	if eof {
+		return 0, fmt.Errorf("invalid bloom indexed height")
+	}
+	return height, nil
+}
+
 func (this *EventStore) getCurrentBlockKey() []byte {
 	return []byte{byte(scom.SYS_CURRENT_BLOCK)}
 }
@@ -199,3 +253,18 @@ func genEventNotifyByTxKey(txHash common.Uint256) []byte {
 	copy(key[1:], data)
 	return key
 }
+
+func genLogsBloomKey(height uint32) []byte {
+	key := make([]byte, 5, 5)
+	key[0] = byte(scom.EVENT_LOGS_BLOOM)
+	binary.LittleEndian.PutUint32(key[1:], height)
+	return key
+}
+
+func genBloomBitsKey(bit uint, section uint32) []byte {
+	key := make([]byte, 7, 7)
+	key[0] = byte(scom.EVENT_BLOOM_BITS)
+	binary.BigEndian.PutUint16(key[1:], uint16(bit))
+	binary.BigEndian.PutUint32(key[3:], section)
+	return key
+}
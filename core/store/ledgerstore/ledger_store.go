@@ -59,6 +59,7 @@ import (
 const (
 	SYSTEM_VERSION          = byte(1)      //Version of ledger store
 	HEADER_INDEX_BATCH_SIZE = uint32(2000) //Bath size of saving header index
+	HEADER_CACHE_SIZE       = 10000        //Number of cached headers above which the headers are saved to block store
 )
 
 var (
@@ -67,6 +68,8 @@ var (
 	DBDirBlock          = "block"
 	DBDirState          = "states"
 	MerkleTreeStorePath = "merkle_tree.db"
+
+	StateMerkleTreeStorePath = "state_merkle_tree.db"
 )
 
 type PrexecuteParam struct {
@@ -81,6 +84,7 @@ type LedgerStoreImp struct {
 	stateStore           *StateStore                      //StateStore for saving state data, like balance, smart contract execution result, and so on.
 	eventStore           *EventStore                      //EventStore for saving log those gen after smart contract executed.
 	crossChainStore      *CrossChainStore                 //crossChainStore for saving cross chain msg.
+	stateHistory         *stateHistory                    //stateHistory for rebuilding the state before recent blocks.
 	storedIndexCount     uint32                           //record the count of have saved block index
 	currBlockHeight      uint32                           //Current block height
 	currBlockHash        common.Uint256                   //Current block hash
@@ -92,6 +96,9 @@ type LedgerStoreImp struct {
 	CbftPeerInfoblock    map[string]uint32 //pubInfo save pubkey,peerindex
 	lock                 sync.RWMutex
 	stateHashCheckHeight uint32
+	stateTrieHeight      uint32 //Height from which the state transition hash commits to the state trie
+	dataDir              string //Directory of the stores and the exported snapshots
+	exportingSnapshot    int32  //Whether a snapshot is being exported
 }
 
 //NewLedgerStore return LedgerStoreImp instance
@@ -103,6 +110,13 @@ func NewLedgerStore(dataDir string, stateHashHeight uint32) (*LedgerStoreImp, er
 		CbftPeerInfoblock:    make(map[string]uint32),
 		savingBlockSemaphore: make(chan bool, 1),
 		stateHashCheckHeight: stateHashHeight,
+		stateTrieHeight:      config.GetStateTrieHeight(config.DefConfig.P2PNode.NetworkId),
+		stateHistory:         newStateHistory(stateHistorySize()),
+		dataDir:              dataDir,
+	}
+	//the state trie is proved through the state merkle tree, which starts at the state hash check height
+	if ledgerStore.stateTrieHeight < stateHashHeight {
+		ledgerStore.stateTrieHeight = stateHashHeight
 	}
 
 	blockStore, err := NewBlockStore(fmt.Sprintf("%s%s%s", dataDir, string(os.PathSeparator), DBDirBlock), true)
@@ -119,11 +133,24 @@ func NewLedgerStore(dataDir string, stateHashHeight uint32) (*LedgerStoreImp, er
 
 	dbPath := fmt.Sprintf("%s%s%s", dataDir, string(os.PathSeparator), DBDirState)
 	merklePath := fmt.Sprintf("%s%s%s", dataDir, string(os.PathSeparator), MerkleTreeStorePath)
-	stateStore, err := NewStateStore(dbPath, merklePath, stateHashHeight)
+	stateMerklePath := fmt.Sprintf("%s%s%s", dataDir, string(os.PathSeparator), StateMerkleTreeStorePath)
+	stateStore, err := NewStateStore(dbPath, merklePath, stateMerklePath, stateHashHeight)
 	if err != nil {
 		return nil, fmt.Errorf("NewStateStore error %s", err)
 	}
 	ledgerStore.stateStore = stateStore
+	if config.DefConfig.Common.EnableArchive {
+		err = stateStore.EnableArchive()
+		if err != nil {
+			return nil, fmt.Errorf("EnableArchive error %s", err)
+		}
+	}
+	if config.DefConfig.Common.PruneBlocks > 0 {
+		err = stateStore.EnablePruning(config.DefConfig.Common.PruneBlocks)
+		if err != nil {
+			return nil, fmt.Errorf("EnablePruning error %s", err)
+		}
+	}
 
 	eventState, err := NewEventStore(fmt.Sprintf("%s%s%s", dataDir, string(os.PathSeparator), DBDirEvent))
 	if err != nil {
@@ -256,6 +283,10 @@ func (this *LedgerStoreImp) init() error {
 	if err != nil {
 		return fmt.Errorf("loadHeaderIndexList error %s", err)
 	}
+	err = this.initBloomIndex()
+	if err != nil {
+		return fmt.Errorf("initBloomIndex error %s", err)
+	}
 	err = this.recoverStore()
 	if err != nil {
 		return fmt.Errorf("recoverStore error %s", err)
@@ -549,6 +580,42 @@ func (this *LedgerStoreImp) AddHeaders(headers []*types.Header) error {
 			return err
 		}
 	}
+	return this.flushHeaderCache()
+}
+
+//flushHeaderCache saves the cached headers to block store when there are too many of them, so the headers
+//synced far ahead of the blocks do not stay in memory
+func (this *LedgerStoreImp) flushHeaderCache() error {
+	this.lock.RLock()
+	if len(this.headerCache) < HEADER_CACHE_SIZE {
+		this.lock.RUnlock()
+		return nil
+	}
+	headers := make([]*types.Header, 0, len(this.headerCache))
+	for _, header := range this.headerCache {
+		headers = append(headers, header)
+	}
+	this.lock.RUnlock()
+
+	this.getSavingBlockLock()
+	defer this.releaseSavingBlockLock()
+	currBlockHeight := this.GetCurrentBlockHeight()
+	this.blockStore.NewBatch()
+	for _, header := range headers {
+		//the saved blocks must not be overwritten
+		if header.Height > currBlockHeight {
+			this.blockStore.SaveHeaderOnly(header)
+		}
+	}
+	err := this.blockStore.CommitTo()
+	if err != nil {
+		return fmt.Errorf("blockStore.CommitTo error %s", err)
+	}
+	this.lock.Lock()
+	for _, header := range headers {
+		delete(this.headerCache, header.Hash())
+	}
+	this.lock.Unlock()
 	return nil
 }
 
@@ -594,24 +661,25 @@ func (this *LedgerStoreImp) SubmitBlock(block *types.Block, ccMsg *types.CrossCh
 	var err error
 	this.CbftPeerInfoblock, err = this.verifyHeader(block.Header, this.CbftPeerInfoblock)
 	if err != nil {
-		return fmt.Errorf("verifyHeader error %s", err)
+		return &scom.InvalidBlockError{Err: fmt.Errorf("verifyHeader error %s", err)}
 	}
 	if ccMsg != nil {
 		if ccMsg.Height != currBlockHeight {
-			return fmt.Errorf("cross chain msg height %d not equal next block height %d", blockHeight, ccMsg.Height)
+			return &scom.InvalidBlockError{Err: fmt.Errorf("cross chain msg height %d not equal next block height %d", blockHeight, ccMsg.Height)}
 		}
 		if ccMsg.Version != types.CURR_CROSS_STATES_VERSION {
-			return fmt.Errorf("error cross chain msg version excepted:%d actual:%d", types.CURR_CROSS_STATES_VERSION, ccMsg.Version)
+			return &scom.InvalidBlockError{Err: fmt.Errorf("error cross chain msg version excepted:%d actual:%d", types.CURR_CROSS_STATES_VERSION, ccMsg.Version)}
 		}
 		root, err := this.stateStore.GetCrossStatesRoot(ccMsg.Height)
 		if err != nil {
 			return fmt.Errorf("get cross states root fail:%s", err)
 		}
-		if root != ccMsg.StatesRoot {
-			return fmt.Errorf("cross state root compare fail, expected:%x actual:%x", ccMsg.StatesRoot, root)
+		//the cross states of the snapshot block are not imported, its cross chain msg is checked by the signatures only
+		if root != ccMsg.StatesRoot && !this.stateStore.isSnapshotHeight(ccMsg.Height) {
+			return &scom.InvalidBlockError{Err: fmt.Errorf("cross state root compare fail, expected:%x actual:%x", ccMsg.StatesRoot, root)}
 		}
 		if err := this.verifyCrossChainMsg(ccMsg, block.Header.Bookkeepers); err != nil {
-			return fmt.Errorf("verifyCrossChainMsg error: %s", err)
+			return &scom.InvalidBlockError{Err: fmt.Errorf("verifyCrossChainMsg error: %s", err)}
 		}
 	}
 
@@ -638,24 +706,25 @@ func (this *LedgerStoreImp) AddBlock(block *types.Block, ccMsg *types.CrossChain
 	var err error
 	this.CbftPeerInfoblock, err = this.verifyHeader(block.Header, this.CbftPeerInfoblock)
 	if err != nil {
-		return fmt.Errorf("verifyHeader error %s", err)
+		return &scom.InvalidBlockError{Err: fmt.Errorf("verifyHeader error %s", err)}
 	}
 	if ccMsg != nil {
 		if ccMsg.Height != currBlockHeight {
-			return fmt.Errorf("cross chain msg height %d not equal next block height %d", blockHeight, ccMsg.Height)
+			return &scom.InvalidBlockError{Err: fmt.Errorf("cross chain msg height %d not equal next block height %d", blockHeight, ccMsg.Height)}
 		}
 		if ccMsg.Version != types.CURR_CROSS_STATES_VERSION {
-			return fmt.Errorf("error cross chain msg version excepted:%d actual:%d", types.CURR_CROSS_STATES_VERSION, ccMsg.Version)
+			return &scom.InvalidBlockError{Err: fmt.Errorf("error cross chain msg version excepted:%d actual:%d", types.CURR_CROSS_STATES_VERSION, ccMsg.Version)}
 		}
 		root, err := this.stateStore.GetCrossStatesRoot(ccMsg.Height)
 		if err != nil {
 			return fmt.Errorf("get cross states root fail:%s", err)
 		}
-		if root != ccMsg.StatesRoot {
-			return fmt.Errorf("cross state root compare fail, expected:%x actual:%x", ccMsg.StatesRoot, root)
+		//the cross states of the snapshot block are not imported, its cross chain msg is checked by the signatures only
+		if root != ccMsg.StatesRoot && !this.stateStore.isSnapshotHeight(ccMsg.Height) {
+			return &scom.InvalidBlockError{Err: fmt.Errorf("cross state root compare fail, expected:%x actual:%x", ccMsg.StatesRoot, root)}
 		}
 		if err := this.verifyCrossChainMsg(ccMsg, block.Header.Bookkeepers); err != nil {
-			return fmt.Errorf("verifyCrossChainMsg error: %s", err)
+			return &scom.InvalidBlockError{Err: fmt.Errorf("verifyCrossChainMsg error: %s", err)}
 		}
 	}
 	err = this.saveBlock(block, ccMsg, stateMerkleRoot)
@@ -712,7 +781,7 @@ func (this *LedgerStoreImp) executeBlock(block *types.Block) (result store.Execu
 	cache := storage.NewCacheDB(overlay)
 	for i, tx := range block.Transactions {
 		cache.Reset()
-		notify, crossStateHashes, e := this.handleTransaction(overlay, cache, gasTable, block, tx, uint32(i), evmWitness)
+		notify, crossStateHashes, e := this.handleTransaction(overlay, cache, gasTable, block, tx)
 		if e != nil {
 			err = e
 			return
@@ -732,17 +801,22 @@ func (this *LedgerStoreImp) executeBlock(block *types.Block) (result store.Execu
 	} else {
 		result.CrossStatesRoot = common.UINT256_EMPTY
 	}
+	if block.Header.Height == this.stateHashCheckHeight {
+		result.Hash, err = calculateTotalStateHash(overlay)
+		if err != nil {
+			return
+		}
+	}
+	if block.Header.Height >= this.stateTrieHeight {
+		err = this.executeStateTrie(block.Header.Height, overlay, &result)
+		if err != nil {
+			return
+		}
+	}
 	if block.Header.Height < this.stateHashCheckHeight {
 		result.MerkleRoot = common.UINT256_EMPTY
 	} else if block.Header.Height == this.stateHashCheckHeight {
-		res, e := calculateTotalStateHash(overlay)
-		if e != nil {
-			err = e
-			return
-		}
-
-		result.MerkleRoot = res
-		result.Hash = result.MerkleRoot
+		result.MerkleRoot = result.Hash
 	} else {
 		result.MerkleRoot = this.stateStore.GetStateMerkleRootWithNewHash(result.Hash)
 	}
@@ -794,8 +868,21 @@ func (this *LedgerStoreImp) saveBlockToStateStore(block *types.Block, result sto
 	for _, notify := range result.Notify {
 		SaveNotify(this.eventStore, notify.TxHash, notify)
 	}
+	err := this.saveBlockBloom(blockHeight, blockLogsBloom(result.Notify))
+	if err != nil {
+		return fmt.Errorf("saveBlockBloom error %s", err)
+	}
 
-	err := this.stateStore.AddStateMerkleTreeRoot(blockHeight, result.Hash)
+	//the pruning goes first, a trie node it deletes may be added again by the block
+	err = this.stateStore.pruneBlock(blockHeight)
+	if err != nil {
+		return fmt.Errorf("pruneBlock error %s", err)
+	}
+	if blockHeight >= this.stateTrieHeight {
+		this.stateStore.saveStateTrie(blockHeight, result.ChangeHash, result.StateTrieRoot, result.StateTrieNodes,
+			result.StaleTrieNodes)
+	}
+	err = this.stateStore.AddStateMerkleTreeRoot(blockHeight, result.Hash)
 	if err != nil {
 		return fmt.Errorf("AddBlockMerkleTreeRoot error %s", err)
 	}
@@ -817,6 +904,17 @@ func (this *LedgerStoreImp) saveBlockToStateStore(block *types.Block, result sto
 
 	log.Debugf("the state transition hash of block %d is:%s", blockHeight, result.Hash.ToHexString())
 
+	prev, err := this.stateStore.getPreviousValues(result.WriteSet)
+	if err != nil {
+		return fmt.Errorf("getPreviousValues error %s", err)
+	}
+	saved, err := this.stateStore.getPreviousValues(this.stateStore.blockKeys(blockHeight))
+	if err != nil {
+		return fmt.Errorf("getPreviousValues error %s", err)
+	}
+	this.stateHistory.record(blockHeight, prev, saved)
+	this.stateStore.archiveBlock(blockHeight, prev)
+
 	result.WriteSet.ForEach(func(key, val []byte) {
 		if len(val) == 0 {
 			this.stateStore.BatchDeleteRawKey(key)
@@ -905,6 +1003,11 @@ func (this *LedgerStoreImp) submitBlock(block *types.Block, crossChainMsg *types
 	}
 	this.setCurrentBlock(blockHeight, blockHash)
 
+	interval := config.DefConfig.Common.SnapshotInterval
+	if interval > 0 && blockHeight%interval == 0 && blockHeight > 0 {
+		this.exportSnapshotFile()
+	}
+
 	if events.DefActorPublisher != nil {
 		events.DefActorPublisher.Publish(
 			message.TOPIC_SAVE_BLOCK_COMPLETE,
@@ -950,6 +1053,16 @@ func (this *LedgerStoreImp) handleTransaction(overlay *overlaydb.OverlayDB, cach
 	notify := &event.ExecuteNotify{TxHash: txHash, State: event.CCNTMRACT_STATE_FAIL}
 	var crossStateHashes []common.Uint256
 	var err error
+	if tx.IsEipTx() {
+		err = this.stateStore.HandleEIP155Transaction(this, cache, tx, block, notify)
+		if overlay.Error() != nil {
+			return nil, nil, fmt.Errorf("HandleEIP155Transaction tx %s error %s", txHash.ToHexString(), overlay.Error())
+		}
+		if err != nil {
+			log.Debugf("HandleEIP155Transaction tx %s error %s", txHash.ToHexString(), err)
+		}
+		return notify, nil, nil
+	}
 	switch tx.TxType {
 	case types.Deploy:
 		err = this.stateStore.HandleDeployTransaction(this, overlay, gasTable, cache, tx, block, notify)
@@ -1160,6 +1273,12 @@ func (this *LedgerStoreImp) PreExecuteContractWithParam(tx *types.Transaction, p
 	if header, err := this.GetHeaderByHeight(height); err == nil {
 		blockTime = header.Timestamp + 1
 	}
+	return this.preExecuteContract(tx, preParam, height, blockTime, this.stateStore.NewOverlayDB())
+}
+
+//preExecuteContract executes the transaction on overlay, the state after the block at height
+func (this *LedgerStoreImp) preExecuteContract(tx *types.Transaction, preParam PrexecuteParam, height, blockTime uint32,
+	overlay *overlaydb.OverlayDB) (*sstate.PreExecResult, error) {
 	stf := &sstate.PreExecResult{State: event.CCNTMRACT_STATE_FAIL, Gas: cntmvm.MIN_TRANSACTION_GAS, Result: nil}
 
 	sconfig := &smartcontract.Config{
@@ -1169,7 +1288,6 @@ func (this *LedgerStoreImp) PreExecuteContractWithParam(tx *types.Transaction, p
 		BlockHash: this.GetBlockHash(height),
 	}
 
-	overlay := this.stateStore.NewOverlayDB()
 	cache := storage.NewCacheDB(overlay)
 	gasTable := make(map[string]uint64)
 	cntmvm.GAS_TABLE.Range(func(k, value interface{}) bool {
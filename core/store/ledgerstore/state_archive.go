@@ -0,0 +1,320 @@
+/*
+ * Copyright (C) 2018 The cntm Authors
+ * This file is part of The cntm library.
+ *
+ * The cntm is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The cntm is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The cntm.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package ledgerstore
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/conntectome/cntm/common"
+	"github.com/conntectome/cntm/common/log"
+	"github.com/conntectome/cntm/core/payload"
+	"github.com/conntectome/cntm/core/states"
+	scom "github.com/conntectome/cntm/core/store/common"
+	"github.com/conntectome/cntm/core/store/overlaydb"
+	"github.com/conntectome/cntm/core/types"
+	sstate "github.com/conntectome/cntm/smartcontract/states"
+)
+
+var (
+	ErrArchiveDisabled = errors.New("the archive mode is disabled")
+	errArchiveReadOnly = errors.New("the archived state is read only")
+)
+
+//stateArchive keeps, for every block saved in the archive mode, the values the block overwrote in the state store.
+//The value of a key after the block at height is the value saved by the first block above height that wrote
+//the key, or the current value if no block above height wrote it.
+type stateArchive struct {
+	start uint32 //Height of the first archived block
+}
+
+//EnableArchive starts archiving the blocks saved from now on. The archive is kept across restarts, it restarts
+//from the next block if blocks were saved while the archive mode was disabled.
+func (self *StateStore) EnableArchive() error {
+	_, height, err := self.GetCurrentBlock()
+	if err != nil && err != scom.ErrNotFound {
+		return err
+	}
+	start := uint32(0)
+	if err == nil {
+		start = height + 1
+		first, last, err := self.getArchiveHeights()
+		if err != nil && err != scom.ErrNotFound {
+			return err
+		}
+		if err == nil && last == height {
+			start = first
+		}
+	}
+	if start > 0 {
+		log.Infof("state archive starts at block %d", start)
+	}
+	self.archive = &stateArchive{start: start}
+	return nil
+}
+
+//ArchiveStartHeight returns the lowest height the state can be read at
+func (self *StateStore) ArchiveStartHeight() (uint32, error) {
+	if self.archive == nil {
+		return 0, ErrArchiveDisabled
+	}
+	if self.archive.start == 0 {
+		return 0, nil
+	}
+	return self.archive.start - 1, nil
+}
+
+//archiveBlock adds prev, the values of the keys before the block at height, to the state store batch
+func (self *StateStore) archiveBlock(height uint32, prev *overlaydb.MemDB) {
+	if self.archive == nil {
+		return
+	}
+	keys := common.NewZeroCopySink(nil)
+	prev.ForEach(func(key, val []byte) {
+		self.store.BatchPut(genArchiveKey(key, height), val)
+		keys.WriteVarBytes(key)
+	})
+	//the keys of the block are indexed so the pruning can delete them without iterating the archive
+	self.store.BatchPut(genArchiveKeysKey(height), keys.Bytes())
+	self.saveArchiveHeights(self.archive.start, height)
+}
+
+func (self *StateStore) saveArchiveHeights(first, last uint32) {
+	heights := make([]byte, 8)
+	binary.LittleEndian.PutUint32(heights, first)
+	binary.LittleEndian.PutUint32(heights[4:], last)
+	self.store.BatchPut([]byte{byte(scom.SYS_ARCHIVE_HEIGHT)}, heights)
+}
+
+func (self *StateStore) getArchiveHeights() (uint32, uint32, error) {
+	data, err := self.store.Get([]byte{byte(scom.SYS_ARCHIVE_HEIGHT)})
+	if err != nil {
+		return 0, 0, err
+	}
+	if len(data) != 8 {
+		return 0, 0, fmt.Errorf("invalid archive heights %x", data)
+	}
+	return binary.LittleEndian.Uint32(data), binary.LittleEndian.Uint32(data[4:]), nil
+}
+
+//checkArchived checks the state after the block at height can be read
+func (self *StateStore) checkArchived(height uint32) error {
+	start, err := self.ArchiveStartHeight()
+	if err != nil {
+		return err
+	}
+	_, current, err := self.GetCurrentBlock()
+	if err != nil {
+		return err
+	}
+	if height > current {
+		return fmt.Errorf("block %d is not saved yet", height)
+	}
+	if height < start {
+		return fmt.Errorf("the state at block %d is not archived, the archive starts at block %d", height, start)
+	}
+	return nil
+}
+
+//getArchivedValue returns the value of the raw key after the block at height was saved
+func (self *StateStore) getArchivedValue(key []byte, height uint32) ([]byte, error) {
+	// the current value is read first: a block saved after it holds the value in the archive
+	value, err := self.store.Get(key)
+	if err != nil && err != scom.ErrNotFound {
+		return nil, err
+	}
+
+	prefix := genArchiveKey(key, 0)
+	prefix = prefix[:len(prefix)-4]
+	iter := self.store.NewIterator(prefix)
+	defer iter.Release()
+	if height < math.MaxUint32 && seekIterator(iter, genArchiveKey(key, height+1)) {
+		value = iter.Value()
+		if len(value) == 0 {
+			err = scom.ErrNotFound
+		} else {
+			err = nil
+		}
+	}
+	if e := iter.Error(); e != nil {
+		return nil, e
+	}
+	if err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+//genArchiveKey returns the archive key of the raw state key at height, the length of the key is written
+//before it so the versions of a key are not mixed with the versions of the keys it is a prefix of
+func genArchiveKey(key []byte, height uint32) []byte {
+	sink := common.NewZeroCopySink(make([]byte, 0, len(key)+14))
+	sink.WriteByte(byte(scom.ST_ARCHIVE))
+	sink.WriteVarBytes(key)
+	var buf [4]byte
+	binary.BigEndian.PutUint32(buf[:], height)
+	sink.WriteBytes(buf[:])
+	return sink.Bytes()
+}
+
+func genArchiveKeysKey(height uint32) []byte {
+	key := make([]byte, 5)
+	key[0] = byte(scom.ST_ARCHIVE_KEYS)
+	binary.BigEndian.PutUint32(key[1:], height)
+	return key
+}
+
+//seekIterator moves the iterator to the first key not less than key
+func seekIterator(iter scom.StoreIterator, key []byte) bool {
+	if seeker, ok := iter.(interface{ Seek(key []byte) bool }); ok {
+		return seeker.Seek(key)
+	}
+	for has := iter.First(); has; has = iter.Next() {
+		if bytes.Compare(iter.Key(), key) >= 0 {
+			return true
+		}
+	}
+	return false
+}
+
+//archiveStore is a read only view of the state store after the block at height
+type archiveStore struct {
+	state  *StateStore
+	height uint32
+}
+
+func (self *archiveStore) Get(key []byte) ([]byte, error) {
+	return self.state.getArchivedValue(key, self.height)
+}
+
+func (self *archiveStore) Has(key []byte) (bool, error) {
+	_, err := self.Get(key)
+	if err == scom.ErrNotFound {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (self *archiveStore) Put(key []byte, value []byte) error { return errArchiveReadOnly }
+func (self *archiveStore) Delete(key []byte) error            { return errArchiveReadOnly }
+func (self *archiveStore) NewBatch()                          {}
+func (self *archiveStore) BatchPut(key []byte, value []byte)  {}
+func (self *archiveStore) BatchDelete(key []byte)             {}
+func (self *archiveStore) BatchCommit() error                 { return errArchiveReadOnly }
+func (self *archiveStore) Close() error                       { return nil }
+
+//NewIterator returns an empty iterator failing with an error, the archive can not be iterated
+func (self *archiveStore) NewIterator(prefix []byte) scom.StoreIterator {
+	return &archiveIterator{}
+}
+
+type archiveIterator struct{}
+
+func (self *archiveIterator) Next() bool    { return false }
+func (self *archiveIterator) First() bool   { return false }
+func (self *archiveIterator) Key() []byte   { return nil }
+func (self *archiveIterator) Value() []byte { return nil }
+func (self *archiveIterator) Release()      {}
+func (self *archiveIterator) Error() error {
+	return errors.New("the archived state can not be iterated")
+}
+
+//NewOverlayDBAt returns an overlay of the state after the block at height was saved
+func (this *LedgerStoreImp) NewOverlayDBAt(height uint32) (*overlaydb.OverlayDB, error) {
+	if err := this.stateStore.checkArchived(height); err != nil {
+		return nil, err
+	}
+	return overlaydb.NewOverlayDB(&archiveStore{state: this.stateStore, height: height}), nil
+}
+
+//ArchiveStartHeight return the lowest height the state can be read at in the archive mode
+func (this *LedgerStoreImp) ArchiveStartHeight() (uint32, error) {
+	return this.stateStore.ArchiveStartHeight()
+}
+
+//GetStorageItemAt return the storage value of the key in smart contract after the block at height was saved
+func (this *LedgerStoreImp) GetStorageItemAt(key *states.StorageKey, height uint32) (*states.StorageItem, error) {
+	if err := this.stateStore.checkArchived(height); err != nil {
+		return nil, err
+	}
+	storeKey, err := this.stateStore.getStorageKey(key)
+	if err != nil {
+		return nil, err
+	}
+	data, err := this.stateStore.getArchivedValue(storeKey, height)
+	if err != nil {
+		return nil, err
+	}
+	item := new(states.StorageItem)
+	if err = item.Deserialization(common.NewZeroCopySource(data)); err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+//GetContractStateAt return contract by contract address after the block at height was saved
+func (this *LedgerStoreImp) GetContractStateAt(contractHash common.Address, height uint32) (*payload.DeployCode, error) {
+	if err := this.stateStore.checkArchived(height); err != nil {
+		return nil, err
+	}
+	key, err := this.stateStore.getContractStateKey(contractHash)
+	if err != nil {
+		return nil, err
+	}
+	data, err := this.stateStore.getArchivedValue(key, height)
+	if err != nil {
+		return nil, err
+	}
+	contract := new(payload.DeployCode)
+	if err = contract.Deserialization(common.NewZeroCopySource(data)); err != nil {
+		return nil, err
+	}
+	return contract, nil
+}
+
+//PreExecuteContractBatchAt return the result of smart contract execution on the state after the block at height.
+//The contracts can not iterate the archived state.
+func (this *LedgerStoreImp) PreExecuteContractBatchAt(txes []*types.Transaction, height uint32) ([]*sstate.PreExecResult, error) {
+	param := PrexecuteParam{
+		JitMode:    false,
+		WasmFactor: 0,
+		MinGas:     true,
+	}
+	blockTime := uint32(time.Now().Unix())
+	if header, err := this.GetHeaderByHeight(height); err == nil {
+		blockTime = header.Timestamp + 1
+	}
+	results := make([]*sstate.PreExecResult, 0, len(txes))
+	for _, tx := range txes {
+		overlay, err := this.NewOverlayDBAt(height)
+		if err != nil {
+			return nil, err
+		}
+		res, err := this.preExecuteContract(tx, param, height, blockTime, overlay)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, res)
+	}
+	return results, nil
+}
@@ -0,0 +1,103 @@
+/*
+ * Copyright (C) 2018 The cntm Authors
+ * This file is part of The cntm library.
+ *
+ * The cntm is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The cntm is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The cntm.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package ledgerstore
+
+import (
+	"testing"
+
+	"github.com/conntectome/cntm/common"
+	scom "github.com/conntectome/cntm/core/store/common"
+	"github.com/conntectome/cntm/core/store/overlaydb"
+	"github.com/stretchr/testify/assert"
+)
+
+func saveArchivedBlock(t *testing.T, db *StateStore, height uint32, writes map[string][]byte) {
+	writeSet := overlaydb.NewMemDB(0, 0)
+	for key, val := range writes {
+		if val == nil {
+			writeSet.Delete([]byte(key))
+		} else {
+			writeSet.Put([]byte(key), val)
+		}
+	}
+	prev, err := db.getPreviousValues(writeSet)
+	assert.Nil(t, err)
+
+	db.NewBatch()
+	assert.Nil(t, db.pruneBlock(height))
+	db.archiveBlock(height, prev)
+	writeSet.ForEach(func(key, val []byte) {
+		if len(val) == 0 {
+			db.store.BatchDelete(key)
+		} else {
+			db.store.BatchPut(key, val)
+		}
+	})
+	assert.Nil(t, db.SaveCurrentBlock(height, common.Uint256{byte(height)}))
+	assert.Nil(t, db.CommitTo())
+}
+
+func TestStateArchive(t *testing.T) {
+	db := NewMemStateStore(0)
+	_, err := db.ArchiveStartHeight()
+	assert.Equal(t, ErrArchiveDisabled, err)
+	assert.Nil(t, db.EnableArchive())
+
+	saveArchivedBlock(t, db, 0, map[string][]byte{"a": []byte("a0"), "ab": []byte("ab0")})
+	saveArchivedBlock(t, db, 1, map[string][]byte{"a": []byte("a1")})
+	saveArchivedBlock(t, db, 2, map[string][]byte{"b": []byte("b2")})
+	saveArchivedBlock(t, db, 3, map[string][]byte{"a": nil, "ab": []byte("ab3")})
+
+	expected := []map[string]string{
+		{"a": "a0", "ab": "ab0"},
+		{"a": "a1", "ab": "ab0"},
+		{"a": "a1", "ab": "ab0", "b": "b2"},
+		{"ab": "ab3", "b": "b2"},
+	}
+	for height, values := range expected {
+		assert.Nil(t, db.checkArchived(uint32(height)))
+		for _, key := range []string{"a", "ab", "b"} {
+			value, err := db.getArchivedValue([]byte(key), uint32(height))
+			if expect, ok := values[key]; ok {
+				assert.Nil(t, err)
+				assert.Equal(t, expect, string(value))
+			} else {
+				assert.Equal(t, scom.ErrNotFound, err)
+			}
+		}
+	}
+	assert.NotNil(t, db.checkArchived(4))
+
+	start, err := db.ArchiveStartHeight()
+	assert.Nil(t, err)
+	assert.Equal(t, uint32(0), start)
+	first, last, err := db.getArchiveHeights()
+	assert.Nil(t, err)
+	assert.Equal(t, uint32(0), first)
+	assert.Equal(t, uint32(3), last)
+
+	//the archive restarts from the next block after a gap
+	db.archive = nil
+	saveArchivedBlock(t, db, 4, map[string][]byte{"a": []byte("a4")})
+	assert.Nil(t, db.EnableArchive())
+	start, err = db.ArchiveStartHeight()
+	assert.Nil(t, err)
+	assert.Equal(t, uint32(4), start)
+	assert.NotNil(t, db.checkArchived(3))
+}
@@ -0,0 +1,153 @@
+/*
+ * Copyright (C) 2018 The cntm Authors
+ * This file is part of The cntm library.
+ *
+ * The cntm is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The cntm is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The cntm.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package ledgerstore
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	scom "github.com/conntectome/cntm/core/store/common"
+	"github.com/conntectome/cntm/core/store/leveldbstore"
+	"github.com/conntectome/cntm/core/store/overlaydb"
+)
+
+var errSnapshotReadOnly = errors.New("the state snapshot is read only")
+
+//STATE_HISTORY_SIZE is the number of recent blocks whose previous state can be rebuilt
+const STATE_HISTORY_SIZE = uint32(128)
+
+//stateHistory keeps, for each of the recent blocks, the values the block overwrote in the state store,
+//so the state before a recent block can be rebuilt without an archive database.
+//It is only kept in memory, blocks saved before the node started can not be rebuilt.
+type stateHistory struct {
+	lock   sync.RWMutex
+	undo   map[uint32]*overlaydb.MemDB //block height => previous values of the keys written by the block
+	saved  map[uint32]*overlaydb.MemDB //block height => previous values of the keys saved with the block out of its write set
+	size   uint32                      //Number of blocks kept, 0 to keep all the blocks
+	oldest uint32
+}
+
+func newStateHistory(size uint32) *stateHistory {
+	return &stateHistory{
+		undo:  make(map[uint32]*overlaydb.MemDB),
+		saved: make(map[uint32]*overlaydb.MemDB),
+		size:  size,
+	}
+}
+
+//record saves undo, the values the keys written by the block at height had before the block, and saved, the
+//values the keys the ledger saved with the block had before it.
+func (this *stateHistory) record(height uint32, undo, saved *overlaydb.MemDB) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	this.undo[height] = undo
+	this.saved[height] = saved
+	if len(this.undo) == 1 {
+		this.oldest = height
+	}
+	for this.size != 0 && height-this.oldest >= this.size {
+		delete(this.undo, this.oldest)
+		delete(this.saved, this.oldest)
+		this.oldest++
+	}
+}
+
+//stateBefore returns an overlay of store holding the state before the block at height was saved,
+//current is the height of the last block saved in store.
+func (this *stateHistory) stateBefore(store scom.PersistStore, height, current uint32) (*overlaydb.OverlayDB, error) {
+	this.lock.RLock()
+	defer this.lock.RUnlock()
+	return this.rollback(store, height, current, false)
+}
+
+//revert returns an overlay of store holding the state and the keys saved by the ledger before the block at
+//height was saved, and drops the history of the block and the blocks after it.
+func (this *stateHistory) revert(store scom.PersistStore, height, current uint32) (*overlaydb.OverlayDB, error) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	overlay, err := this.rollback(store, height, current, true)
+	if err != nil {
+		return nil, err
+	}
+	for h := height; h <= current; h++ {
+		delete(this.undo, h)
+		delete(this.saved, h)
+	}
+	return overlay, nil
+}
+
+func (this *stateHistory) rollback(store scom.PersistStore, height, current uint32,
+	withSaved bool) (*overlaydb.OverlayDB, error) {
+	if height > current {
+		return nil, fmt.Errorf("block %d is not saved yet", height)
+	}
+	overlay := overlaydb.NewOverlayDB(store)
+	apply := func(key, val []byte) {
+		if len(val) == 0 {
+			overlay.Delete(key)
+		} else {
+			overlay.Put(key, val)
+		}
+	}
+	for h := current; ; h-- {
+		undo, ok := this.undo[h]
+		if !ok {
+			return nil, fmt.Errorf("state before block %d is not available", height)
+		}
+		undo.ForEach(apply)
+		if withSaved {
+			this.saved[h].ForEach(apply)
+		}
+		if h == height {
+			break
+		}
+	}
+	return overlay, nil
+}
+
+//snapshotStore is a read only view of a snapshot of the state store, the state history is applied on it to rebuild
+//a past state which does not change with the blocks saved later
+type snapshotStore struct {
+	snap *leveldbstore.LevelDBSnapshot
+}
+
+func (self *snapshotStore) Get(key []byte) ([]byte, error) {
+	return self.snap.Get(key)
+}
+
+func (self *snapshotStore) Has(key []byte) (bool, error) {
+	_, err := self.Get(key)
+	if err == scom.ErrNotFound {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (self *snapshotStore) Put(key []byte, value []byte) error { return errSnapshotReadOnly }
+func (self *snapshotStore) Delete(key []byte) error            { return errSnapshotReadOnly }
+func (self *snapshotStore) NewBatch()                          {}
+func (self *snapshotStore) BatchPut(key []byte, value []byte)  {}
+func (self *snapshotStore) BatchDelete(key []byte)             {}
+func (self *snapshotStore) BatchCommit() error                 { return errSnapshotReadOnly }
+func (self *snapshotStore) Close() error                       { return nil }
+
+func (self *snapshotStore) NewIterator(prefix []byte) scom.StoreIterator {
+	return self.snap.NewIterator(prefix)
+}
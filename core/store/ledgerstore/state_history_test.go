@@ -0,0 +1,100 @@
+/*
+ * Copyright (C) 2018 The cntm Authors
+ * This file is part of The cntm library.
+ *
+ * The cntm is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The cntm is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The cntm.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package ledgerstore
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/conntectome/cntm/core/store/leveldbstore"
+	"github.com/conntectome/cntm/core/store/overlaydb"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStateHistory(t *testing.T) {
+	db := NewMemStateStore(0)
+	history := newStateHistory(3)
+
+	saveBlock := func(height uint32) {
+		writeSet := overlaydb.NewMemDB(0, 0)
+		writeSet.Put([]byte("a"), []byte(fmt.Sprint(height)))
+		writeSet.Put([]byte(fmt.Sprint("b", height)), []byte{1})
+		prev, err := db.getPreviousValues(writeSet)
+		assert.Nil(t, err)
+		history.record(height, prev, overlaydb.NewMemDB(0, 0))
+		writeSet.ForEach(func(key, val []byte) {
+			assert.Nil(t, db.store.Put(key, val))
+		})
+	}
+	for h := uint32(0); h <= 5; h++ {
+		saveBlock(h)
+	}
+
+	//only the last 3 blocks are kept, and the unsaved blocks can not be rebuilt
+	_, err := history.stateBefore(db.store, 2, 5)
+	assert.NotNil(t, err)
+	_, err = history.stateBefore(db.store, 6, 5)
+	assert.NotNil(t, err)
+	for h := uint32(3); h <= 5; h++ {
+		overlay, err := history.stateBefore(db.store, h, 5)
+		assert.Nil(t, err)
+		value, err := overlay.Get([]byte("a"))
+		assert.Nil(t, err)
+		assert.Equal(t, fmt.Sprint(h-1), string(value))
+		//the keys created by the block and the blocks after it are absent
+		for k := uint32(0); k <= 5; k++ {
+			value, err = overlay.Get([]byte(fmt.Sprint("b", k)))
+			assert.Nil(t, err)
+			assert.Equal(t, k < h, value != nil)
+		}
+	}
+	//rebuilding the state does not change the store
+	value, err := db.store.Get([]byte("a"))
+	assert.Nil(t, err)
+	assert.Equal(t, "5", string(value))
+}
+
+func TestStateHistorySnapshot(t *testing.T) {
+	db := NewMemStateStore(0)
+	store := db.store.(*leveldbstore.LevelDBStore)
+	history := newStateHistory(0)
+
+	assert.Nil(t, store.Put([]byte("a"), []byte("0")))
+	prev := overlaydb.NewMemDB(0, 0)
+	prev.Put([]byte("a"), []byte("0"))
+	history.record(1, prev, overlaydb.NewMemDB(0, 0))
+	assert.Nil(t, store.Put([]byte("a"), []byte("1")))
+
+	snap, err := store.NewSnapshot()
+	assert.Nil(t, err)
+	defer snap.Release()
+	overlay, err := history.stateBefore(&snapshotStore{snap: snap}, 1, 1)
+	assert.Nil(t, err)
+
+	//the blocks saved after the snapshot do not change the rebuilt state
+	assert.Nil(t, store.Put([]byte("a"), []byte("2")))
+	assert.Nil(t, store.Put([]byte("b"), []byte("2")))
+	value, err := overlay.Get([]byte("a"))
+	assert.Nil(t, err)
+	assert.Equal(t, "0", string(value))
+	value, err = overlay.Get([]byte("b"))
+	assert.Nil(t, err)
+	assert.Nil(t, value)
+	assert.Equal(t, errSnapshotReadOnly, (&snapshotStore{snap: snap}).Put([]byte("a"), nil))
+}
@@ -0,0 +1,279 @@
+/*
+ * Copyright (C) 2018 The cntm Authors
+ * This file is part of The cntm library.
+ *
+ * The cntm is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The cntm is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The cntm.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package ledgerstore
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/conntectome/cntm/common"
+	"github.com/conntectome/cntm/common/log"
+	scom "github.com/conntectome/cntm/core/store/common"
+)
+
+const (
+	MIN_PRUNE_BLOCKS = STATE_HISTORY_SIZE //Least number of recent blocks whose state history is kept
+	PRUNE_BATCH_SIZE = 10000              //Number of deletions committed at once when catching up
+)
+
+//statePruner discards the state history older than the kept blocks: the values the blocks overwrote in the state
+//archive, and the state trie nodes the blocks replaced which no later block added again. The current state, the
+//state merkle roots, the cross states and the blocks are never pruned, so a pruned node still verifies and serves
+//the new blocks and the cross states proofs.
+type statePruner struct {
+	keep uint32 //Number of recent blocks whose state history is kept
+	next uint32 //Height of the next block to prune
+}
+
+//EnablePruning keeps the state history of the last keep blocks only, it needs the archive mode. The history of the
+//blocks saved before is pruned at once, the history of the blocks saved from now on is pruned when the block is keep
+//blocks old. The trie nodes replaced by the blocks saved out of the archive mode are not indexed and never pruned.
+func (self *StateStore) EnablePruning(keep uint32) error {
+	if self.archive == nil {
+		return fmt.Errorf("the pruning needs the archive mode: %s", ErrArchiveDisabled)
+	}
+	if keep < MIN_PRUNE_BLOCKS {
+		return fmt.Errorf("at least %d blocks must be kept", MIN_PRUNE_BLOCKS)
+	}
+	self.pruner = &statePruner{keep: keep}
+	_, current, err := self.GetCurrentBlock()
+	if err == scom.ErrNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	next, err := self.getPruneHeight()
+	if err != nil && err != scom.ErrNotFound {
+		return err
+	}
+	self.pruner.next = next
+	if current < keep {
+		return nil
+	}
+	target := current - keep
+	if err == scom.ErrNotFound {
+		//never pruned, the history may predate the archive keys index
+		log.Infof("prune the state history up to block %d", target)
+		err = self.sweepHistory(target)
+	} else if next <= target {
+		log.Infof("prune the state history from block %d to %d", next, target)
+		err = self.pruneHistory(next, target)
+	} else {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	self.NewBatch()
+	self.prunedTo(target)
+	if _, last, err := self.getArchiveHeights(); err == nil {
+		self.saveArchiveHeights(self.archive.start, last)
+	}
+	return self.CommitTo()
+}
+
+//pruneBlock adds the pruning of the history older than the kept blocks to the batch of the block at height
+func (self *StateStore) pruneBlock(height uint32) error {
+	if self.pruner == nil || height < self.pruner.keep {
+		return nil
+	}
+	target := height - self.pruner.keep
+	for h := self.pruner.next; h <= target; h++ {
+		if err := self.pruneHeight(h); err != nil {
+			return err
+		}
+	}
+	self.prunedTo(target)
+	return nil
+}
+
+//prunedTo records the blocks up to target are pruned, the archive starts after them
+func (self *StateStore) prunedTo(target uint32) {
+	self.pruner.next = target + 1
+	value := make([]byte, 4)
+	binary.LittleEndian.PutUint32(value, self.pruner.next)
+	self.store.BatchPut([]byte{byte(scom.SYS_PRUNE_HEIGHT)}, value)
+	if self.archive != nil && self.archive.start <= target {
+		self.archive.start = target + 1
+	}
+}
+
+func (self *StateStore) getPruneHeight() (uint32, error) {
+	data, err := self.store.Get([]byte{byte(scom.SYS_PRUNE_HEIGHT)})
+	if err != nil {
+		return 0, err
+	}
+	if len(data) != 4 {
+		return 0, fmt.Errorf("invalid prune height %x", data)
+	}
+	return binary.LittleEndian.Uint32(data), nil
+}
+
+//pruneHeight adds the deletion of the history of the block at height to the batch
+func (self *StateStore) pruneHeight(height uint32) error {
+	if err := self.pruneTrieNodes(height); err != nil {
+		return err
+	}
+	indexKey := genArchiveKeysKey(height)
+	data, err := self.store.Get(indexKey)
+	if err == scom.ErrNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	source := common.NewZeroCopySource(data)
+	for source.Len() > 0 {
+		key, _, irregular, eof := source.NextVarBytes()
+		if irregular || eof {
+			return fmt.Errorf("invalid archive keys of block %d", height)
+		}
+		self.store.BatchDelete(genArchiveKey(key, height))
+	}
+	self.store.BatchDelete(indexKey)
+	return nil
+}
+
+//pruneTrieNodes adds the deletion of the trie nodes the block at height replaced to the batch. A node is only in the
+//tries of the blocks before height, unless a later block added it again or replaced it too.
+func (self *StateStore) pruneTrieNodes(height uint32) error {
+	indexKey := genTrieStaleKey(height)
+	data, err := self.store.Get(indexKey)
+	if err == scom.ErrNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	source := common.NewZeroCopySource(data)
+	for source.Len() > 0 {
+		hash, eof := source.NextHash()
+		if eof {
+			return fmt.Errorf("invalid stale trie nodes of block %d", height)
+		}
+		staleKey := genTrieStaleNodeKey(hash)
+		value, err := self.store.Get(staleKey)
+		if err == scom.ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if len(value) == 4 && binary.LittleEndian.Uint32(value) == height {
+			self.store.BatchDelete(genTrieNodeKey(hash))
+			self.store.BatchDelete(staleKey)
+		}
+	}
+	self.store.BatchDelete(indexKey)
+	return nil
+}
+
+//pruneHistory deletes the history of the blocks from start to end
+func (self *StateStore) pruneHistory(start, end uint32) error {
+	self.NewBatch()
+	for h := start; h <= end; h++ {
+		if err := self.pruneHeight(h); err != nil {
+			return err
+		}
+		if (h-start+1)%PRUNE_BATCH_SIZE == 0 {
+			if err := self.CommitTo(); err != nil {
+				return err
+			}
+			self.NewBatch()
+		}
+	}
+	return self.CommitTo()
+}
+
+//sweepHistory deletes the history of the blocks up to target by iterating the whole history
+func (self *StateStore) sweepHistory(target uint32) error {
+	if err := self.sweepTrieNodes(target); err != nil {
+		return err
+	}
+	err := self.sweep([]byte{byte(scom.ST_ARCHIVE_KEYS)}, target, func(key []byte) uint32 {
+		return binary.BigEndian.Uint32(key[1:])
+	})
+	if err != nil {
+		return err
+	}
+	return self.sweep([]byte{byte(scom.ST_ARCHIVE)}, target, func(key []byte) uint32 {
+		return binary.BigEndian.Uint32(key[len(key)-4:])
+	})
+}
+
+func (self *StateStore) sweep(prefix []byte, target uint32, heightOf func(key []byte) uint32) error {
+	iter := self.store.NewIterator(prefix)
+	defer iter.Release()
+	self.NewBatch()
+	count := 0
+	for iter.Next() {
+		key := iter.Key()
+		if len(key) < 5 || heightOf(key) > target {
+			continue
+		}
+		self.store.BatchDelete(key)
+		count++
+		if count%PRUNE_BATCH_SIZE == 0 {
+			if err := self.CommitTo(); err != nil {
+				return err
+			}
+			self.NewBatch()
+		}
+	}
+	if err := iter.Error(); err != nil {
+		return err
+	}
+	return self.CommitTo()
+}
+
+//sweepTrieNodes deletes the trie nodes replaced by the blocks up to target, they are indexed by block
+func (self *StateStore) sweepTrieNodes(target uint32) error {
+	var heights []uint32
+	iter := self.store.NewIterator([]byte{byte(scom.ST_TRIE_STALE)})
+	for iter.Next() {
+		key := iter.Key()
+		if len(key) != 5 {
+			continue
+		}
+		height := binary.BigEndian.Uint32(key[1:])
+		if height > target {
+			break
+		}
+		heights = append(heights, height)
+	}
+	iter.Release()
+	if err := iter.Error(); err != nil {
+		return err
+	}
+	self.NewBatch()
+	for i, height := range heights {
+		if err := self.pruneTrieNodes(height); err != nil {
+			return err
+		}
+		if (i+1)%PRUNE_BATCH_SIZE == 0 {
+			if err := self.CommitTo(); err != nil {
+				return err
+			}
+			self.NewBatch()
+		}
+	}
+	return self.CommitTo()
+}
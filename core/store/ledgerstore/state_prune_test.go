@@ -0,0 +1,157 @@
+/*
+ * Copyright (C) 2018 The cntm Authors
+ * This file is part of The cntm library.
+ *
+ * The cntm is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The cntm is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The cntm.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package ledgerstore
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/conntectome/cntm/common"
+	scom "github.com/conntectome/cntm/core/store/common"
+	"github.com/conntectome/cntm/core/store/overlaydb"
+	"github.com/conntectome/cntm/merkle"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatePruning(t *testing.T) {
+	db := NewMemStateStore(0)
+	assert.Nil(t, db.EnableArchive())
+	assert.NotNil(t, db.EnablePruning(MIN_PRUNE_BLOCKS-1))
+
+	saveBlock := func(height uint32) {
+		db.NewBatch()
+		assert.Nil(t, db.SaveCrossStates(height, []common.Uint256{{byte(height)}}))
+		assert.Nil(t, db.CommitTo())
+		saveArchivedBlock(t, db, height, map[string][]byte{"a": []byte(fmt.Sprint(height))})
+	}
+	//the history saved before the pruning is enabled is swept at once
+	for h := uint32(0); h < 10; h++ {
+		saveBlock(h)
+	}
+	keep := MIN_PRUNE_BLOCKS
+	for h := uint32(10); h <= keep+20; h++ {
+		saveBlock(h)
+	}
+	assert.Nil(t, db.EnablePruning(keep))
+	_, err := db.store.Get(genArchiveKey([]byte("a"), 20))
+	assert.Equal(t, scom.ErrNotFound, err)
+	_, err = db.store.Get(genArchiveKey([]byte("a"), 21))
+	assert.Nil(t, err)
+
+	//the history of the new blocks is pruned when they are keep blocks old
+	current := keep + 30
+	for h := keep + 21; h <= current; h++ {
+		saveBlock(h)
+	}
+	for h := uint32(0); h <= current; h++ {
+		//the cross states are kept for the cross states proofs
+		_, err := db.GetCrossStates(h)
+		assert.Nil(t, err)
+		_, err = db.store.Get(genArchiveKey([]byte("a"), h))
+		assert.Equal(t, h > current-keep, err == nil)
+	}
+
+	start, err := db.ArchiveStartHeight()
+	assert.Nil(t, err)
+	assert.Equal(t, current-keep, start)
+	assert.NotNil(t, db.checkArchived(start-1))
+	value, err := db.getArchivedValue([]byte("a"), start)
+	assert.Nil(t, err)
+	assert.Equal(t, fmt.Sprint(start), string(value))
+
+	//the archive and the pruning restart where they stopped
+	db.archive = nil
+	db.pruner = nil
+	assert.Nil(t, db.EnableArchive())
+	assert.Nil(t, db.EnablePruning(keep))
+	restart, err := db.ArchiveStartHeight()
+	assert.Nil(t, err)
+	assert.Equal(t, start, restart)
+	assert.Equal(t, current-keep+1, db.pruner.next)
+}
+
+//reachableTrieNodes adds the nodes of the trie of hash to nodes
+func reachableTrieNodes(t *testing.T, trie *stateTrie, hash common.Uint256, nodes map[common.Uint256]bool) {
+	if hash == common.UINT256_EMPTY || nodes[hash] {
+		return
+	}
+	node, err := trie.getNode(hash)
+	if !assert.Nil(t, err) {
+		return
+	}
+	nodes[hash] = true
+	if node[0] == merkle.SPARSE_INNER_NODE {
+		var left, right common.Uint256
+		copy(left[:], node[1:1+common.UINT256_SIZE])
+		copy(right[:], node[1+common.UINT256_SIZE:])
+		reachableTrieNodes(t, trie, left, nodes)
+		reachableTrieNodes(t, trie, right, nodes)
+	}
+}
+
+func TestStateTriePruning(t *testing.T) {
+	db := NewMemStateStore(0)
+	//the pruning needs the archive mode
+	assert.NotNil(t, db.EnablePruning(MIN_PRUNE_BLOCKS))
+	assert.Nil(t, db.EnableArchive())
+	keep := MIN_PRUNE_BLOCKS
+	assert.Nil(t, db.EnablePruning(keep))
+
+	current := keep + 20
+	roots := make([]common.Uint256, current+1)
+	root := common.UINT256_EMPTY
+	for h := uint32(0); h <= current; h++ {
+		writeSet := overlaydb.NewMemDB(0, 0)
+		//the value of key 0 goes back and forth, so its nodes are replaced and added again
+		writeSet.Put(trieTestKey(0), []byte{byte(h % 2)})
+		writeSet.Put(trieTestKey(int(h%5)+1), []byte{byte(h)})
+		writeSet.Put(trieTestKey(int(h)+10), []byte{byte(h)})
+		if h >= 3 {
+			writeSet.Delete(trieTestKey(int(h) + 7))
+		}
+		trie := newStateTrie(db.store)
+		var err error
+		root, err = updateStateTrie(trie, root, writeSet)
+		assert.Nil(t, err)
+		roots[h] = root
+
+		db.NewBatch()
+		assert.Nil(t, db.pruneBlock(h))
+		db.saveStateTrie(h, common.UINT256_EMPTY, root, trie.nodes, trie.staleNodes())
+		assert.Nil(t, db.SaveCurrentBlock(h, common.Uint256{byte(h)}))
+		assert.Nil(t, db.CommitTo())
+	}
+
+	//the store holds the nodes of the tries of the kept blocks only
+	reachable := make(map[common.Uint256]bool)
+	trie := newStateTrie(db.store)
+	for h := current - keep; h <= current; h++ {
+		reachableTrieNodes(t, trie, roots[h], reachable)
+	}
+	stored := 0
+	iter := db.store.NewIterator([]byte{byte(scom.ST_TRIE_NODE)})
+	for iter.Next() {
+		stored++
+	}
+	iter.Release()
+	assert.Nil(t, iter.Error())
+	assert.Equal(t, len(reachable), stored)
+	_, err := trie.getNode(roots[current-keep-1])
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,147 @@
+/*
+ * Copyright (C) 2018 The cntm Authors
+ * This file is part of The cntm library.
+ *
+ * The cntm is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The cntm is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The cntm.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package ledgerstore
+
+import (
+	"fmt"
+
+	"github.com/conntectome/cntm/common"
+	"github.com/conntectome/cntm/common/config"
+	"github.com/conntectome/cntm/common/log"
+	"github.com/conntectome/cntm/core/store/overlaydb"
+	"github.com/conntectome/cntm/core/types"
+)
+
+//stateHistorySize returns the number of blocks whose previous state is kept. The dev chain keeps all of them,
+//so it can be reverted to any block saved since the node started.
+func stateHistorySize() uint32 {
+	if config.DefConfig.Genesis.SOLO != nil && config.DefConfig.Genesis.SOLO.DevMode {
+		return 0
+	}
+	return STATE_HISTORY_SIZE
+}
+
+//RevertToHeight rolls the ledger back to the block at height, the blocks after it are removed with their
+//transactions and events. The state is rebuilt from the state history, so the ledger can only be reverted to
+//the blocks it keeps the history of, and not in archive or pruning mode.
+func (this *LedgerStoreImp) RevertToHeight(height uint32) error {
+	this.getSavingBlockLock()
+	defer this.releaseSavingBlockLock()
+	if this.closing {
+		return fmt.Errorf("ledger is closing")
+	}
+	current := this.GetCurrentBlockHeight()
+	if height > current {
+		return fmt.Errorf("block %d is not saved yet", height)
+	}
+	if height == current {
+		return nil
+	}
+	state := this.stateStore
+	if state.archive != nil || state.pruner != nil {
+		return fmt.Errorf("the ledger can not be reverted in archive or pruning mode")
+	}
+	blockHash := this.GetBlockHash(height)
+	blocks := make([]*types.Block, 0, current-height)
+	for h := height + 1; h <= current; h++ {
+		block, err := this.GetBlockByHeight(h)
+		if err != nil {
+			return fmt.Errorf("GetBlockByHeight height:%d error %s", h, err)
+		}
+		blocks = append(blocks, block)
+	}
+	overlay, err := this.stateHistory.revert(state.store, height+1, current)
+	if err != nil {
+		return err
+	}
+	//the state store is reverted first, the removed blocks are saved again by recoverStore if the node stops
+	//before the block store is reverted
+	if err = state.revert(height, overlay); err != nil {
+		return fmt.Errorf("revert state store error %s", err)
+	}
+
+	this.blockStore.NewBatch()
+	this.eventStore.NewBatch()
+	for _, block := range blocks {
+		txs := make([]common.Uint256, 0, len(block.Transactions))
+		for _, tx := range block.Transactions {
+			txHash := tx.Hash()
+			this.blockStore.DeleteTransaction(txHash)
+			txs = append(txs, txHash)
+		}
+		this.blockStore.DeleteBlockHash(block.Header.Height)
+		this.eventStore.PruneBlock(block.Header.Height, txs)
+	}
+	this.lock.RLock()
+	stored := this.storedIndexCount
+	this.lock.RUnlock()
+	for stored > height+1 {
+		stored -= HEADER_INDEX_BATCH_SIZE
+		this.blockStore.DeleteHeaderIndexList(stored)
+	}
+	if err = this.blockStore.SaveCurrentBlock(height, blockHash); err != nil {
+		return fmt.Errorf("SaveCurrentBlock error %s", err)
+	}
+	this.eventStore.SaveCurrentBlock(height, blockHash)
+	this.eventStore.SaveBloomIndexed(height + 1)
+	if err = this.blockStore.CommitTo(); err != nil {
+		return fmt.Errorf("blockStore.CommitTo error %s", err)
+	}
+	if err = this.eventStore.CommitTo(); err != nil {
+		return fmt.Errorf("eventStore.CommitTo error %s", err)
+	}
+
+	this.lock.Lock()
+	for h := range this.headerIndex {
+		if h > height {
+			delete(this.headerIndex, h)
+		}
+	}
+	for hash, header := range this.headerCache {
+		if header.Height > height {
+			delete(this.headerCache, hash)
+		}
+	}
+	this.storedIndexCount = stored
+	this.lock.Unlock()
+	this.setCurrentBlock(height, blockHash)
+	log.Infof("ledger reverted from block %d to block %d", current, height)
+	return nil
+}
+
+//revert commits overlay, which rolls the store back to the block at height, and reloads the merkle trees
+func (self *StateStore) revert(height uint32, overlay *overlaydb.OverlayDB) error {
+	self.NewBatch()
+	overlay.GetWriteSet().ForEach(func(key, val []byte) {
+		if len(val) == 0 {
+			self.BatchDeleteRawKey(key)
+		} else {
+			self.BatchPutRawKeyVal(key, val)
+		}
+	})
+	if err := self.CommitTo(); err != nil {
+		return err
+	}
+	//the hashes after the reverted tree size are overwritten by the next blocks
+	if self.merkleHashStore != nil {
+		self.merkleHashStore.Close()
+		self.merkleHashStore = nil
+	}
+	return self.init(height)
+}
@@ -0,0 +1,97 @@
+/*
+ * Copyright (C) 2018 The cntm Authors
+ * This file is part of The cntm library.
+ *
+ * The cntm is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The cntm is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The cntm.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package ledgerstore
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/conntectome/cntm/common"
+	scom "github.com/conntectome/cntm/core/store/common"
+	"github.com/conntectome/cntm/core/store/overlaydb"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStateRevert(t *testing.T) {
+	db := NewMemStateStore(0)
+	history := newStateHistory(0)
+
+	saveBlock := func(height uint32) {
+		writeSet := overlaydb.NewMemDB(0, 0)
+		writeSet.Put([]byte("a"), []byte(fmt.Sprint(height)))
+		writeSet.Put([]byte(fmt.Sprint("b", height)), []byte{1})
+		if height > 0 {
+			writeSet.Delete([]byte(fmt.Sprint("b", height-1)))
+		}
+		prev, err := db.getPreviousValues(writeSet)
+		assert.Nil(t, err)
+		saved, err := db.getPreviousValues(db.blockKeys(height))
+		assert.Nil(t, err)
+		history.record(height, prev, saved)
+
+		db.NewBatch()
+		assert.Nil(t, db.AddStateMerkleTreeRoot(height, common.Uint256{byte(height)}))
+		assert.Nil(t, db.AddBlockMerkleTreeRoot(common.Uint256{byte(height), 1}))
+		assert.Nil(t, db.SaveCurrentBlock(height, common.Uint256{byte(height)}))
+		assert.Nil(t, db.SaveCrossStates(height, []common.Uint256{{byte(height)}}))
+		writeSet.ForEach(func(key, val []byte) {
+			if len(val) == 0 {
+				db.BatchDeleteRawKey(key)
+			} else {
+				db.BatchPutRawKeyVal(key, val)
+			}
+		})
+		assert.Nil(t, db.CommitTo())
+	}
+
+	for h := uint32(0); h <= 2; h++ {
+		saveBlock(h)
+	}
+	stateRoot, blockRoot := db.deltaMerkleTree.Root(), db.merkleTree.Root()
+	for h := uint32(3); h <= 5; h++ {
+		saveBlock(h)
+	}
+
+	overlay, err := history.revert(db.store, 3, 5)
+	assert.Nil(t, err)
+	assert.Nil(t, db.revert(2, overlay))
+
+	_, height, err := db.GetCurrentBlock()
+	assert.Nil(t, err)
+	assert.Equal(t, uint32(2), height)
+	assert.Equal(t, stateRoot, db.deltaMerkleTree.Root())
+	assert.Equal(t, blockRoot, db.merkleTree.Root())
+	value, err := db.store.Get([]byte("a"))
+	assert.Nil(t, err)
+	assert.Equal(t, "2", string(value))
+	_, err = db.store.Get([]byte("b2"))
+	assert.Nil(t, err)
+	_, err = db.store.Get([]byte("b5"))
+	assert.Equal(t, scom.ErrNotFound, err)
+	_, err = db.GetCrossStates(3)
+	assert.Equal(t, scom.ErrNotFound, err)
+
+	//the history of the reverted blocks is dropped, the chain goes on from the reverted block
+	_, err = history.stateBefore(db.store, 3, 5)
+	assert.NotNil(t, err)
+	saveBlock(3)
+	_, err = history.stateBefore(db.store, 3, 3)
+	assert.Nil(t, err)
+	assert.Equal(t, uint32(4), db.merkleTree.TreeSize())
+}
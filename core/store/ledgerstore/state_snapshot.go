@@ -0,0 +1,915 @@
+/*
+ * Copyright (C) 2018 The cntm Authors
+ * This file is part of The cntm library.
+ *
+ * The cntm is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The cntm is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The cntm.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package ledgerstore
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/bits"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/conntectome/cntm/common"
+	"github.com/conntectome/cntm/common/log"
+	"github.com/conntectome/cntm/common/serialization"
+	vconfig "github.com/conntectome/cntm/consensus/Cbft/config"
+	"github.com/conntectome/cntm/core/store"
+	scom "github.com/conntectome/cntm/core/store/common"
+	"github.com/conntectome/cntm/core/store/leveldbstore"
+	"github.com/conntectome/cntm/core/types"
+	"github.com/conntectome/cntm/merkle"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+)
+
+//A state snapshot file holds, in order: the magic and version, the snapshot info, the block at the snapshot
+//height, the block hashes from the genesis block to the snapshot block, the state key values in ascending key order
+//ended by an empty key, and the sha256 digest of all the content before it.
+const (
+	SNAPSHOT_MAGIC          = "CNTMSNAP"
+	SNAPSHOT_VERSION        = byte(2)
+	SNAPSHOT_DIR            = "snapshots" //Directory of the snapshots exported every SnapshotInterval blocks
+	SNAPSHOT_KEEP_COUNT     = 2           //Number of snapshots kept in the snapshot directory
+	SNAPSHOT_BATCH_SIZE     = 10000       //Number of key values committed at once when importing
+	SNAPSHOT_STAGING_SUFFIX = ".import"   //Suffix of the state store directory a snapshot is imported to
+	SNAPSHOT_BACKUP_SUFFIX  = ".genesis"  //Suffix of the state store directory moved away by the import
+)
+
+//snapshotSource is the ledger content a snapshot is written from, it does not change while blocks are saved
+type snapshotSource struct {
+	info   store.SnapshotInfo
+	block  *types.Block
+	hashes []common.Uint256
+	state  *leveldbstore.LevelDBSnapshot
+}
+
+//prepareSnapshot takes the snapshot of the ledger after the current block, the saving block lock must be held
+func (this *LedgerStoreImp) prepareSnapshot() (*snapshotSource, error) {
+	db, ok := this.stateStore.store.(*leveldbstore.LevelDBStore)
+	if !ok {
+		return nil, fmt.Errorf("the state store does not support snapshots")
+	}
+	height, blockHash := this.GetCurrentBlock()
+	if height == 0 || height < this.stateHashCheckHeight {
+		return nil, fmt.Errorf("the state at block %d has no state merkle root", height)
+	}
+	root, err := this.stateStore.GetStateMerkleRoot(height)
+	if err != nil {
+		return nil, fmt.Errorf("GetStateMerkleRoot error %s", err)
+	}
+	block, err := this.blockStore.GetBlock(blockHash)
+	if err != nil {
+		return nil, fmt.Errorf("GetBlock error %s", err)
+	}
+	hashes := make([]common.Uint256, 0, height+1)
+	for h := uint32(0); h <= height; h++ {
+		hash := this.GetBlockHash(h)
+		if hash == common.UINT256_EMPTY {
+			return nil, fmt.Errorf("block hash of height %d not found", h)
+		}
+		hashes = append(hashes, hash)
+	}
+	snap, err := db.NewSnapshot()
+	if err != nil {
+		return nil, fmt.Errorf("NewSnapshot error %s", err)
+	}
+	return &snapshotSource{
+		info:   store.SnapshotInfo{Height: height, BlockHash: blockHash, StateRoot: root},
+		block:  block,
+		hashes: hashes,
+		state:  snap,
+	}, nil
+}
+
+//isSnapshotKey reports whether the state key belongs to the snapshot after the block at height. Only the keys the
+//import verifies are written: the state trie leaves, the state trie root of the block, the state merkle roots and the
+//block merkle tree. The trie nodes and the state merkle tree are rebuilt by the import, the state history and the
+//cross states are left out.
+func isSnapshotKey(key []byte, height uint32) bool {
+	if isStateTrieKey(key) {
+		return true
+	}
+	if len(key) == 0 {
+		return false
+	}
+	switch scom.DataEntryPrefix(key[0]) {
+	case scom.SYS_BLOCK_MERKLE_TREE:
+		return len(key) == 1
+	case scom.DATA_STATE_MERKLE_ROOT:
+		return len(key) == 5 && binary.LittleEndian.Uint32(key[1:]) <= height
+	case scom.DATA_STATE_TRIE_ROOT:
+		return len(key) == 5 && binary.LittleEndian.Uint32(key[1:]) == height
+	}
+	return false
+}
+
+//writeTo writes the snapshot to w and returns its digest
+func (self *snapshotSource) writeTo(w io.Writer) (common.Uint256, error) {
+	hasher := sha256.New()
+	bw := bufio.NewWriter(io.MultiWriter(w, hasher))
+	sink := common.NewZeroCopySink(nil)
+	sink.WriteBytes([]byte(SNAPSHOT_MAGIC))
+	sink.WriteByte(SNAPSHOT_VERSION)
+	self.info.Serialization(sink)
+	sink.WriteVarBytes(common.SerializeToBytes(self.block))
+	sink.WriteUint32(uint32(len(self.hashes)))
+	for _, hash := range self.hashes {
+		sink.WriteHash(hash)
+	}
+	if _, err := bw.Write(sink.Bytes()); err != nil {
+		return common.UINT256_EMPTY, err
+	}
+
+	iter := self.state.NewIterator(nil)
+	for iter.Next() {
+		//a key without value is not in the state trie
+		if !isSnapshotKey(iter.Key(), self.info.Height) || len(iter.Value()) == 0 {
+			continue
+		}
+		sink.Reset()
+		sink.WriteVarBytes(iter.Key())
+		sink.WriteVarBytes(iter.Value())
+		if _, err := bw.Write(sink.Bytes()); err != nil {
+			iter.Release()
+			return common.UINT256_EMPTY, err
+		}
+	}
+	iter.Release()
+	if err := iter.Error(); err != nil {
+		return common.UINT256_EMPTY, err
+	}
+	sink.Reset()
+	sink.WriteVarBytes(nil)
+	if _, err := bw.Write(sink.Bytes()); err != nil {
+		return common.UINT256_EMPTY, err
+	}
+	if err := bw.Flush(); err != nil {
+		return common.UINT256_EMPTY, err
+	}
+	var digest common.Uint256
+	copy(digest[:], hasher.Sum(nil))
+	if _, err := w.Write(digest[:]); err != nil {
+		return common.UINT256_EMPTY, err
+	}
+	return digest, nil
+}
+
+func (self *snapshotSource) release() {
+	self.state.Release()
+}
+
+//readSnapshot reads the snapshot from r, calling onInfo with the snapshot info if it is not nil, then onEntry
+//for every key value of the state. The keys must be snapshot keys in ascending order, so none is read twice. The
+//content is only known to be valid once the digest is checked at the end.
+func readSnapshot(r io.Reader, onEntry func(key, value []byte) error, onInfo func(info *store.SnapshotInfo)) (
+	*store.SnapshotInfo, *types.Block, []common.Uint256, error) {
+	br := bufio.NewReader(r)
+	hasher := sha256.New()
+	reader := io.TeeReader(br, hasher)
+	info, err := readSnapshotInfo(reader)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if onInfo != nil {
+		onInfo(info)
+	}
+	raw, err := serialization.ReadVarBytes(reader)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("read block error %s", err)
+	}
+	block, err := types.BlockFromRawBytes(raw)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("invalid block %s", err)
+	}
+	count, err := serialization.ReadUint32(reader)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("read block hashes error %s", err)
+	}
+	if count != info.Height+1 {
+		return nil, nil, nil, fmt.Errorf("%d block hashes for height %d", count, info.Height)
+	}
+	hashes := make([]common.Uint256, count)
+	for i := range hashes {
+		if _, err = io.ReadFull(reader, hashes[i][:]); err != nil {
+			return nil, nil, nil, fmt.Errorf("read block hashes error %s", err)
+		}
+	}
+	var prevKey []byte
+	for {
+		key, err := serialization.ReadVarBytes(reader)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("read state key error %s", err)
+		}
+		if len(key) == 0 {
+			break
+		}
+		if !isSnapshotKey(key, info.Height) {
+			return nil, nil, nil, fmt.Errorf("unexpected state key %x", key)
+		}
+		if bytes.Compare(key, prevKey) <= 0 {
+			return nil, nil, nil, fmt.Errorf("state key %x out of order", key)
+		}
+		prevKey = key
+		value, err := serialization.ReadVarBytes(reader)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("read state value error %s", err)
+		}
+		if err = onEntry(key, value); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+	var digest common.Uint256
+	if _, err = io.ReadFull(br, digest[:]); err != nil {
+		return nil, nil, nil, fmt.Errorf("read digest error %s", err)
+	}
+	if !bytes.Equal(digest[:], hasher.Sum(nil)) {
+		return nil, nil, nil, fmt.Errorf("snapshot digest mismatch")
+	}
+	return info, block, hashes, nil
+}
+
+func readSnapshotInfo(r io.Reader) (*store.SnapshotInfo, error) {
+	buf := make([]byte, len(SNAPSHOT_MAGIC)+1+4+2*common.UINT256_SIZE)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return nil, fmt.Errorf("read snapshot info error %s", err)
+	}
+	if string(buf[:len(SNAPSHOT_MAGIC)]) != SNAPSHOT_MAGIC {
+		return nil, fmt.Errorf("not a state snapshot")
+	}
+	if buf[len(SNAPSHOT_MAGIC)] != SNAPSHOT_VERSION {
+		return nil, fmt.Errorf("unsupported snapshot version %d", buf[len(SNAPSHOT_MAGIC)])
+	}
+	info := new(store.SnapshotInfo)
+	if err := info.Deserialization(common.NewZeroCopySource(buf[len(SNAPSHOT_MAGIC)+1:])); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+//ExportStateSnapshot writes the snapshot of the state after the current block to w, and returns the snapshot
+//info and digest
+func (this *LedgerStoreImp) ExportStateSnapshot(w io.Writer) (*store.SnapshotInfo, common.Uint256, error) {
+	this.getSavingBlockLock()
+	src, err := this.prepareSnapshot()
+	this.releaseSavingBlockLock()
+	if err != nil {
+		return nil, common.UINT256_EMPTY, err
+	}
+	defer src.release()
+	digest, err := src.writeTo(w)
+	if err != nil {
+		return nil, common.UINT256_EMPTY, err
+	}
+	return &src.info, digest, nil
+}
+
+//exportSnapshotFile writes the snapshot of the state after the current block to the snapshot directory in
+//background, the saving block lock must be held
+func (this *LedgerStoreImp) exportSnapshotFile() {
+	if !atomic.CompareAndSwapInt32(&this.exportingSnapshot, 0, 1) {
+		log.Warnf("skip the state snapshot of block %d, the previous snapshot is not finished", this.GetCurrentBlockHeight())
+		return
+	}
+	src, err := this.prepareSnapshot()
+	if err != nil {
+		atomic.StoreInt32(&this.exportingSnapshot, 0)
+		log.Errorf("prepare state snapshot error %s", err)
+		return
+	}
+	go func() {
+		defer atomic.StoreInt32(&this.exportingSnapshot, 0)
+		defer src.release()
+		dir := filepath.Join(this.dataDir, SNAPSHOT_DIR)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			log.Errorf("create snapshot directory error %s", err)
+			return
+		}
+		path := filepath.Join(dir, fmt.Sprintf("snapshot_%d.dat", src.info.Height))
+		f, err := os.Create(path + ".tmp")
+		if err != nil {
+			log.Errorf("create snapshot file error %s", err)
+			return
+		}
+		_, err = src.writeTo(f)
+		if e := f.Close(); err == nil {
+			err = e
+		}
+		if err == nil {
+			err = os.Rename(path+".tmp", path)
+		}
+		if err != nil {
+			os.Remove(path + ".tmp")
+			log.Errorf("export state snapshot of block %d error %s", src.info.Height, err)
+			return
+		}
+		log.Infof("state snapshot of block %d exported to %s", src.info.Height, path)
+		heights := this.snapshotHeights()
+		for len(heights) > SNAPSHOT_KEEP_COUNT {
+			os.Remove(filepath.Join(dir, fmt.Sprintf("snapshot_%d.dat", heights[0])))
+			heights = heights[1:]
+		}
+	}()
+}
+
+//snapshotHeights returns the heights of the snapshots in the snapshot directory in ascending order
+func (this *LedgerStoreImp) snapshotHeights() []uint32 {
+	files, err := ioutil.ReadDir(filepath.Join(this.dataDir, SNAPSHOT_DIR))
+	if err != nil {
+		return nil
+	}
+	var heights []uint32
+	for _, file := range files {
+		name := file.Name()
+		if !strings.HasPrefix(name, "snapshot_") || !strings.HasSuffix(name, ".dat") {
+			continue
+		}
+		height, err := strconv.ParseUint(strings.TrimSuffix(strings.TrimPrefix(name, "snapshot_"), ".dat"), 10, 32)
+		if err != nil {
+			continue
+		}
+		heights = append(heights, uint32(height))
+	}
+	sort.Slice(heights, func(i, j int) bool { return heights[i] < heights[j] })
+	return heights
+}
+
+//LatestStateSnapshot returns the latest snapshot exported to the snapshot directory
+func (this *LedgerStoreImp) LatestStateSnapshot() (*store.SnapshotFile, error) {
+	heights := this.snapshotHeights()
+	if len(heights) == 0 {
+		return nil, scom.ErrNotFound
+	}
+	path := filepath.Join(this.dataDir, SNAPSHOT_DIR, fmt.Sprintf("snapshot_%d.dat", heights[len(heights)-1]))
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := readSnapshotInfo(f)
+	if err != nil {
+		return nil, err
+	}
+	stat, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	file := &store.SnapshotFile{SnapshotInfo: *info, Path: path, Size: uint64(stat.Size())}
+	if _, err = f.ReadAt(file.Digest[:], stat.Size()-common.UINT256_SIZE); err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+//committedStateTrieRoot returns the state trie root after the block at height committed to by the header of the next
+//block, the header is verified by the header sync
+func (this *LedgerStoreImp) committedStateTrieRoot(height uint32, blockHash common.Uint256) (common.Uint256, error) {
+	if this.GetCurrentHeaderHeight() <= height {
+		return common.UINT256_EMPTY, fmt.Errorf("the header of block %d is not synced", height+1)
+	}
+	header, err := this.GetHeaderByHeight(height + 1)
+	if err != nil {
+		return common.UINT256_EMPTY, fmt.Errorf("GetHeaderByHeight error %s", err)
+	}
+	if header.PrevBlockHash != blockHash {
+		return common.UINT256_EMPTY, fmt.Errorf("the header of block %d does not follow the snapshot block", height+1)
+	}
+	blkInfo, err := vconfig.CbftBlock(header)
+	if err != nil {
+		return common.UINT256_EMPTY, err
+	}
+	if blkInfo.PrevStateTrieRoot == nil {
+		return common.UINT256_EMPTY, fmt.Errorf("the header of block %d commits to no state trie root", height+1)
+	}
+	return *blkInfo.PrevStateTrieRoot, nil
+}
+
+//snapshotStaging is the state store a snapshot is imported to, the ledger switches to it once the snapshot is
+//verified so a failed import leaves the genesis state untouched
+type snapshotStaging struct {
+	dir   string //Directory of the store, empty for a memory store
+	store *leveldbstore.LevelDBStore
+}
+
+func (this *LedgerStoreImp) newSnapshotStaging() (*snapshotStaging, error) {
+	dir := this.stateStore.dbDir
+	if dir == "" {
+		db, err := leveldbstore.NewMemLevelDBStore()
+		if err != nil {
+			return nil, err
+		}
+		return &snapshotStaging{store: db}, nil
+	}
+	dir += SNAPSHOT_STAGING_SUFFIX
+	//left by an interrupted import
+	if err := os.RemoveAll(dir); err != nil {
+		return nil, err
+	}
+	db, err := leveldbstore.NewLevelDBStore(dir)
+	if err != nil {
+		return nil, err
+	}
+	return &snapshotStaging{dir: dir, store: db}, nil
+}
+
+func (self *snapshotStaging) discard() {
+	self.store.Close()
+	if self.dir != "" {
+		os.RemoveAll(self.dir)
+	}
+}
+
+func genSnapshotLeafKey(keyHash common.Uint256) []byte {
+	return append([]byte{byte(scom.ST_SNAPSHOT_LEAF)}, keyHash[:]...)
+}
+
+//snapshotTrieBuilder builds the state trie from the leaves indexed by key hash in the staging store. It holds the
+//next two leaves only, and saves the new nodes to the store every SNAPSHOT_BATCH_SIZE nodes.
+type snapshotTrieBuilder struct {
+	trie  *stateTrie
+	store *leveldbstore.LevelDBStore
+	iter  scom.StoreIterator
+	next  []trieKV
+}
+
+//peek reports whether the i-th next leaf shares the first depth bits of path
+func (self *snapshotTrieBuilder) peek(i, depth int, path common.Uint256) bool {
+	for len(self.next) <= i && self.iter.Next() {
+		var kv trieKV
+		copy(kv.KeyHash[:], self.iter.Key()[1:])
+		copy(kv.ValueHash[:], self.iter.Value())
+		self.next = append(self.next, kv)
+	}
+	if len(self.next) <= i {
+		return false
+	}
+	for d := 0; d < depth; d++ {
+		if merkle.SparseKeyBit(self.next[i].KeyHash, d) != merkle.SparseKeyBit(path, d) {
+			return false
+		}
+	}
+	return true
+}
+
+//build returns the subtree at depth holding the next leaves sharing the first depth bits of path, it makes the
+//same trie as stateTrie.build
+func (self *snapshotTrieBuilder) build(depth int, path common.Uint256) (common.Uint256, error) {
+	if !self.peek(0, depth, path) {
+		return common.UINT256_EMPTY, nil
+	}
+	if !self.peek(1, depth, path) {
+		kv := self.next[0]
+		self.next = self.next[1:]
+		return self.trie.putNode(merkle.SPARSE_LEAF_NODE, kv.KeyHash, kv.ValueHash), nil
+	}
+	if depth >= merkle.SPARSE_MAX_DEPTH {
+		return common.UINT256_EMPTY, fmt.Errorf("duplicated key hash %s in state trie", self.next[0].KeyHash.ToHexString())
+	}
+	//the leaves are sorted, the ones of the left subtree come first
+	var left, right common.Uint256
+	var err error
+	if merkle.SparseKeyBit(self.next[0].KeyHash, depth) == 0 {
+		if left, err = self.build(depth+1, self.next[0].KeyHash); err != nil {
+			return common.UINT256_EMPTY, err
+		}
+	}
+	if self.peek(0, depth, path) {
+		if right, err = self.build(depth+1, self.next[0].KeyHash); err != nil {
+			return common.UINT256_EMPTY, err
+		}
+	}
+	hash, err := self.trie.join(left, right)
+	if err != nil {
+		return common.UINT256_EMPTY, err
+	}
+	if len(self.trie.nodes) >= SNAPSHOT_BATCH_SIZE {
+		err = self.flush()
+	}
+	return hash, err
+}
+
+func (self *snapshotTrieBuilder) flush() error {
+	self.store.NewBatch()
+	for hash, node := range self.trie.nodes {
+		self.store.BatchPut(genTrieNodeKey(hash), node)
+	}
+	self.trie.nodes = make(map[common.Uint256][]byte)
+	return self.store.BatchCommit()
+}
+
+//buildSnapshotTrie builds the state trie from the leaves indexed in the staging store, saves its nodes to the store
+//and deletes the index
+func buildSnapshotTrie(db *leveldbstore.LevelDBStore) (common.Uint256, error) {
+	prefix := []byte{byte(scom.ST_SNAPSHOT_LEAF)}
+	builder := &snapshotTrieBuilder{trie: newStateTrie(db), store: db, iter: db.NewIterator(prefix)}
+	root, err := builder.build(0, common.UINT256_EMPTY)
+	if err == nil {
+		err = builder.iter.Error()
+	}
+	builder.iter.Release()
+	if err == nil {
+		err = builder.flush()
+	}
+	if err != nil {
+		return common.UINT256_EMPTY, err
+	}
+
+	iter := db.NewIterator(prefix)
+	defer iter.Release()
+	db.NewBatch()
+	for count := 1; iter.Next(); count++ {
+		db.BatchDelete(iter.Key())
+		if count%SNAPSHOT_BATCH_SIZE == 0 {
+			if err := db.BatchCommit(); err != nil {
+				return common.UINT256_EMPTY, err
+			}
+			db.NewBatch()
+		}
+	}
+	if err := iter.Error(); err != nil {
+		return common.UINT256_EMPTY, err
+	}
+	return root, db.BatchCommit()
+}
+
+//verifySnapshot reads the snapshot to the staging store and checks it is the state after a block of this chain.
+//The state is checked against the state trie root committed to by the consensus unless the snapshot is trusted.
+//No key is imported unverified: the state trie is rebuilt from the leaves, the state merkle tree from the state
+//merkle roots, and the block merkle tree must have the block root of the snapshot block.
+func (this *LedgerStoreImp) verifySnapshot(r io.Reader, db *leveldbstore.LevelDBStore, trusted bool) (
+	*store.SnapshotInfo, *types.Block, []common.Uint256, error) {
+	staged := &StateStore{store: db}
+	var rootCount uint32
+	count := 0
+	db.NewBatch()
+	info, block, hashes, err := readSnapshot(r, func(key, value []byte) error {
+		if isStateTrieKey(key) {
+			if len(value) == 0 {
+				return fmt.Errorf("state key %x has no value", key)
+			}
+			//the leaves are indexed in the order the state trie is built in
+			kv := newTrieKV(key, value)
+			db.BatchPut(genSnapshotLeafKey(kv.KeyHash), kv.ValueHash[:])
+		} else if key[0] == byte(scom.DATA_STATE_MERKLE_ROOT) {
+			rootCount++
+		}
+		db.BatchPut(key, value)
+		count++
+		if count%SNAPSHOT_BATCH_SIZE == 0 {
+			if err := db.BatchCommit(); err != nil {
+				return err
+			}
+			db.NewBatch()
+		}
+		return nil
+	}, nil)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if err = db.BatchCommit(); err != nil {
+		return nil, nil, nil, err
+	}
+
+	if info.Height == 0 || info.Height < this.stateTrieHeight || info.Height < this.stateHashCheckHeight {
+		return nil, nil, nil, fmt.Errorf("the state at block %d has no state trie", info.Height)
+	}
+	if block.Header.Height != info.Height || block.Hash() != info.BlockHash || hashes[info.Height] != info.BlockHash {
+		return nil, nil, nil, fmt.Errorf("the block does not match the snapshot info")
+	}
+	for h, hash := range hashes {
+		local := this.GetBlockHash(uint32(h))
+		if h == 0 && local != hash {
+			return nil, nil, nil, fmt.Errorf("the snapshot is not of this chain, genesis block %s", hash.ToHexString())
+		}
+		if (!trusted || local != common.UINT256_EMPTY) && local != hash {
+			return nil, nil, nil, fmt.Errorf("block hash mismatch at height %d, local %s, snapshot %s", h,
+				local.ToHexString(), hash.ToHexString())
+		}
+	}
+
+	//the state is the leaves of the state trie after the block
+	changeHash, trieRoot, err := staged.GetStateTrieRoot(info.Height)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("the snapshot has no state trie root")
+	}
+	if !trusted {
+		committed, err := this.committedStateTrieRoot(info.Height, info.BlockHash)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		if committed != trieRoot {
+			return nil, nil, nil, fmt.Errorf("state trie root mismatch, committed %s, snapshot %s",
+				committed.ToHexString(), trieRoot.ToHexString())
+		}
+	}
+	root, err := buildSnapshotTrie(db)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if root != trieRoot {
+		return nil, nil, nil, fmt.Errorf("the state does not match the state trie root %s", trieRoot.ToHexString())
+	}
+
+	//the state merkle tree is rebuilt from the state transition hashes of the blocks, every state merkle root must
+	//be the root of the tree after its block, and the last transition hash commits to the state trie root
+	if rootCount != info.Height-this.stateHashCheckHeight+1 {
+		return nil, nil, nil, fmt.Errorf("%d state merkle roots for block %d", rootCount, info.Height)
+	}
+	tree := merkle.NewTree(0, nil, nil)
+	var transitionHash common.Uint256
+	for h := this.stateHashCheckHeight; h <= info.Height; h++ {
+		value, err := db.Get(staged.genStateMerkleRootKey(h))
+		if err != nil || len(value) != 2*common.UINT256_SIZE {
+			return nil, nil, nil, fmt.Errorf("invalid state merkle root of block %d", h)
+		}
+		copy(transitionHash[:], value[:common.UINT256_SIZE])
+		tree.AppendHash(transitionHash)
+		if root := tree.Root(); !bytes.Equal(value[common.UINT256_SIZE:], root[:]) {
+			return nil, nil, nil, fmt.Errorf("state merkle root mismatch at block %d", h)
+		}
+	}
+	if tree.Root() != info.StateRoot {
+		return nil, nil, nil, fmt.Errorf("state merkle root mismatch, snapshot %s", info.StateRoot.ToHexString())
+	}
+	if transitionHash != types.StateTransitionHash(changeHash, trieRoot) {
+		return nil, nil, nil, fmt.Errorf("the state transition hash does not commit to the state trie root")
+	}
+
+	//the block merkle tree after the block has the block root of the block
+	treeSize, treeHashes, err := staged.GetBlockMerkleTree()
+	if err != nil || treeSize != info.Height+1 || len(treeHashes) != bits.OnesCount32(treeSize) {
+		return nil, nil, nil, fmt.Errorf("the snapshot has no valid block merkle tree")
+	}
+	blockTree := merkle.NewTree(treeSize, treeHashes, nil)
+	if blockTree.Root() != block.Header.BlockRoot {
+		return nil, nil, nil, fmt.Errorf("the block merkle tree does not match the block root %s",
+			block.Header.BlockRoot.ToHexString())
+	}
+	db.NewBatch()
+	staged.putMerkleTree(staged.genStateMerkleTreeKey(), tree)
+	staged.putMerkleTree(staged.genBlockMerkleTreeKey(), blockTree)
+	if err = db.BatchCommit(); err != nil {
+		return nil, nil, nil, err
+	}
+	return info, block, hashes, nil
+}
+
+//ImportStateSnapshot replaces the ledger with the snapshot file, the ledger must only hold the genesis block.
+//The snapshot is imported to a staging store and verified before the ledger is touched, an untrusted snapshot
+//needs the headers synced past the snapshot block. The blocks before the snapshot are not saved, so the node
+//neither serves them nor rebuilds their events; the headers synced before the import are kept.
+func (this *LedgerStoreImp) ImportStateSnapshot(path string, trusted bool) (*store.SnapshotInfo, error) {
+	this.getSavingBlockLock()
+	defer this.releaseSavingBlockLock()
+	if this.closing {
+		return nil, fmt.Errorf("ledger is closing")
+	}
+	if this.GetCurrentBlockHeight() != 0 {
+		return nil, fmt.Errorf("the snapshot can only be imported to a ledger holding the genesis block only")
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	staging, err := this.newSnapshotStaging()
+	if err != nil {
+		return nil, fmt.Errorf("create staging store error %s", err)
+	}
+	info, block, hashes, err := this.verifySnapshot(f, staging.store, trusted)
+	if err != nil {
+		staging.discard()
+		return nil, fmt.Errorf("verify snapshot error %s", err)
+	}
+	log.Infof("import state snapshot of block %d", info.Height)
+
+	if err = this.importSnapshotState(staging, info); err != nil {
+		return nil, fmt.Errorf("import state error %s", err)
+	}
+	if err = this.importSnapshotBlocks(block, hashes); err != nil {
+		return nil, fmt.Errorf("import blocks error %s", err)
+	}
+	if err = this.importSnapshotEvents(info); err != nil {
+		return nil, fmt.Errorf("import events error %s", err)
+	}
+
+	root, err := this.stateStore.GetStateMerkleRoot(info.Height)
+	if err != nil {
+		return nil, fmt.Errorf("GetStateMerkleRoot error %s", err)
+	}
+	if root != info.StateRoot {
+		return nil, fmt.Errorf("state merkle root mismatch after import, expected %s, got %s",
+			info.StateRoot.ToHexString(), root.ToHexString())
+	}
+	log.Infof("state snapshot of block %d imported, state merkle root %s", info.Height, root.ToHexString())
+	return info, nil
+}
+
+//importSnapshotState switches the state store to the verified staging store
+func (this *LedgerStoreImp) importSnapshotState(staging *snapshotStaging, info *store.SnapshotInfo) error {
+	state := this.stateStore
+	staged := &StateStore{store: staging.store}
+	staged.NewBatch()
+	//the bookkeepers are not part of the snapshot, they are the ones of the genesis block
+	key, _ := state.getBookkeeperKey()
+	if value, err := state.store.Get(key); err == nil {
+		staged.BatchPutRawKeyVal(key, value)
+	}
+	if state.pruner != nil {
+		staged.pruner = &statePruner{keep: state.pruner.keep}
+		staged.prunedTo(info.Height)
+	}
+	if state.archive != nil {
+		staged.saveArchiveHeights(info.Height+1, info.Height)
+	}
+	staged.saveSnapshotHeight(info.Height)
+	//the current block is saved last, the state is not used before it is complete
+	if err := staged.SaveCurrentBlock(info.Height, info.BlockHash); err != nil {
+		staging.discard()
+		return err
+	}
+	if err := staged.CommitTo(); err != nil {
+		staging.discard()
+		return err
+	}
+	if err := state.swapStore(staging); err != nil {
+		return err
+	}
+	if state.pruner != nil {
+		state.pruner.next = info.Height + 1
+	}
+	if state.archive != nil {
+		state.archive.start = info.Height + 1
+	}
+
+	//the block merkle tree hashes of the blocks before the snapshot are not known, merkle proofs are not served.
+	//The state merkle store is rebuilt from the state merkle roots.
+	if state.merkleHashStore != nil {
+		state.merkleHashStore.Close()
+		state.merkleHashStore = nil
+	}
+	if state.merklePath != "" {
+		os.Remove(state.merklePath)
+	}
+	if state.stateMerkleHashStore != nil {
+		state.stateMerkleHashStore.Close()
+		state.stateMerkleHashStore = nil
+	}
+	if state.stateMerklePath != "" {
+		os.Remove(state.stateMerklePath)
+	}
+	this.stateHistory = newStateHistory(this.stateHistory.size)
+	return state.init(info.Height)
+}
+
+//swapStore replaces the store with the staging store, the store is kept if the staging store can not be moved in
+func (self *StateStore) swapStore(staging *snapshotStaging) error {
+	if staging.dir == "" {
+		self.store.Close()
+		self.store = staging.store
+		return nil
+	}
+	if err := staging.store.Close(); err != nil {
+		os.RemoveAll(staging.dir)
+		return err
+	}
+	if err := self.store.Close(); err != nil {
+		os.RemoveAll(staging.dir)
+		return err
+	}
+	backup := self.dbDir + SNAPSHOT_BACKUP_SUFFIX
+	err := os.RemoveAll(backup)
+	if err == nil {
+		err = os.Rename(self.dbDir, backup)
+		if err == nil {
+			if err = os.Rename(staging.dir, self.dbDir); err != nil {
+				os.Rename(backup, self.dbDir)
+			}
+		}
+	}
+	db, e := leveldbstore.NewLevelDBStore(self.dbDir)
+	if e != nil {
+		return fmt.Errorf("reopen state store error %s", e)
+	}
+	self.store = db
+	if err != nil {
+		os.RemoveAll(staging.dir)
+		return err
+	}
+	os.RemoveAll(backup)
+	return nil
+}
+
+//saveSnapshotHeight records the state is imported from the snapshot after the block at height
+func (self *StateStore) saveSnapshotHeight(height uint32) {
+	value := make([]byte, 4)
+	binary.LittleEndian.PutUint32(value, height)
+	self.store.BatchPut([]byte{byte(scom.SYS_SNAPSHOT_HEIGHT)}, value)
+}
+
+//isSnapshotHeight reports whether the state is imported from the snapshot after the block at height, the cross
+//states of the block are not known
+func (self *StateStore) isSnapshotHeight(height uint32) bool {
+	data, err := self.store.Get([]byte{byte(scom.SYS_SNAPSHOT_HEIGHT)})
+	return err == nil && len(data) == 4 && binary.LittleEndian.Uint32(data) == height
+}
+
+func (this *LedgerStoreImp) importSnapshotBlocks(block *types.Block, hashes []common.Uint256) error {
+	height := block.Header.Height
+	blockStore := this.blockStore
+	blockStore.NewBatch()
+	count := 0
+	commit := func() error {
+		count++
+		if count%SNAPSHOT_BATCH_SIZE != 0 {
+			return nil
+		}
+		if err := blockStore.CommitTo(); err != nil {
+			return err
+		}
+		blockStore.NewBatch()
+		return nil
+	}
+	for h := uint32(1); h < height; h++ {
+		if header := this.getHeaderCache(hashes[h]); header != nil {
+			blockStore.SaveHeaderOnly(header)
+			if err := commit(); err != nil {
+				return err
+			}
+		}
+	}
+	for h, hash := range hashes {
+		blockStore.SaveBlockHash(uint32(h), hash)
+		if err := commit(); err != nil {
+			return err
+		}
+	}
+	stored := uint32(0)
+	for ; stored+HEADER_INDEX_BATCH_SIZE <= height; stored += HEADER_INDEX_BATCH_SIZE {
+		blockStore.SaveHeaderIndexList(stored, hashes[stored:stored+HEADER_INDEX_BATCH_SIZE])
+	}
+	if err := blockStore.SaveBlock(block); err != nil {
+		return err
+	}
+	if err := blockStore.SaveCurrentBlock(height, block.Hash()); err != nil {
+		return err
+	}
+	if err := blockStore.CommitTo(); err != nil {
+		return err
+	}
+
+	this.lock.Lock()
+	for h, hash := range hashes {
+		this.headerIndex[uint32(h)] = hash
+	}
+	for hash, header := range this.headerCache {
+		if header.Height <= height {
+			delete(this.headerCache, hash)
+		}
+	}
+	this.storedIndexCount = stored
+	this.lock.Unlock()
+	this.setCurrentBlock(height, block.Hash())
+	return nil
+}
+
+//importSnapshotEvents starts the event store at the snapshot block, the blocks of the logs bloom section before
+//it have empty blooms
+func (this *LedgerStoreImp) importSnapshotEvents(info *store.SnapshotInfo) error {
+	this.eventStore.NewBatch()
+	for h := info.Height / BLOOM_BITS_BLOCKS * BLOOM_BITS_BLOCKS; h < info.Height; h++ {
+		this.eventStore.SaveBlockBloom(h, ethtypes.Bloom{})
+	}
+	if err := this.eventStore.CommitTo(); err != nil {
+		return err
+	}
+	this.eventStore.NewBatch()
+	if err := this.saveBlockBloom(info.Height, ethtypes.Bloom{}); err != nil {
+		return err
+	}
+	this.eventStore.SaveCurrentBlock(info.Height, info.BlockHash)
+	return this.eventStore.CommitTo()
+}
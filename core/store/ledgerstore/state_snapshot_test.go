@@ -0,0 +1,171 @@
+/*
+ * Copyright (C) 2018 The cntm Authors
+ * This file is part of The cntm library.
+ *
+ * The cntm is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The cntm is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The cntm.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package ledgerstore
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"testing"
+
+	"github.com/conntectome/cntm/common"
+	"github.com/conntectome/cntm/core/store"
+	scom "github.com/conntectome/cntm/core/store/common"
+	"github.com/conntectome/cntm/core/store/leveldbstore"
+	"github.com/conntectome/cntm/core/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStateSnapshotFormat(t *testing.T) {
+	state := NewMemStateStore(0)
+	db := state.store.(*leveldbstore.LevelDBStore)
+	storageKey := []byte{byte(scom.ST_STORAGE), 1, 2, 3}
+	assert.Nil(t, db.Put(storageKey, []byte("value")))
+	assert.Nil(t, db.Put(genArchiveKey(storageKey, 1), []byte("old")))
+	assert.Nil(t, db.Put(state.genCrossStatesKey(1), make([]byte, common.UINT256_SIZE)))
+	assert.Nil(t, db.Put(state.genCrossStatesKey(2), make([]byte, common.UINT256_SIZE)))
+	assert.Nil(t, db.Put(state.genStateTrieRootKey(1), make([]byte, 2*common.UINT256_SIZE)))
+	assert.Nil(t, db.Put(state.genStateTrieRootKey(2), make([]byte, 2*common.UINT256_SIZE)))
+	assert.Nil(t, db.Put(state.genStateMerkleRootKey(2), make([]byte, 2*common.UINT256_SIZE)))
+	assert.Nil(t, db.Put(state.genStateMerkleTreeKey(), make([]byte, 4)))
+	assert.Nil(t, db.Put(genTrieNodeKey(common.Uint256{1}), make([]byte, TRIE_NODE_LEN)))
+	assert.Nil(t, db.Put(state.getCurrentBlockKey(), []byte("current")))
+
+	snap, err := db.NewSnapshot()
+	assert.Nil(t, err)
+	block := &types.Block{Header: &types.Header{Height: 2}}
+	src := &snapshotSource{
+		info:   store.SnapshotInfo{Height: 2, BlockHash: block.Hash(), StateRoot: common.Uint256{1}},
+		block:  block,
+		hashes: []common.Uint256{{0}, {1}, block.Hash()},
+		state:  snap,
+	}
+	buf := bytes.NewBuffer(nil)
+	digest, err := src.writeTo(buf)
+	src.release()
+	assert.Nil(t, err)
+	data := buf.Bytes()
+	assert.Equal(t, digest[:], data[len(data)-common.UINT256_SIZE:])
+
+	entries := make(map[string]string)
+	info, blk, hashes, err := readSnapshot(bytes.NewReader(data), func(key, value []byte) error {
+		entries[string(key)] = string(value)
+		return nil
+	}, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, src.info, *info)
+	assert.Equal(t, block.Hash(), blk.Hash())
+	assert.Equal(t, src.hashes, hashes)
+	//the state history, the cross states, the current block, the old state trie roots and the state which is
+	//rebuilt by the import are left out
+	assert.Equal(t, map[string]string{
+		string(storageKey):                     "value",
+		string(state.genStateTrieRootKey(2)):   string(make([]byte, 2*common.UINT256_SIZE)),
+		string(state.genStateMerkleRootKey(2)): string(make([]byte, 2*common.UINT256_SIZE)),
+	}, entries)
+
+	//the tampered content does not match the digest
+	data[len(data)-common.UINT256_SIZE-3] ^= 1
+	_, _, _, err = readSnapshot(bytes.NewReader(data), func(key, value []byte) error { return nil }, nil)
+	assert.NotNil(t, err)
+}
+
+//writeSnapshotEntries returns a snapshot of the block at height 2 holding the key values in the given order
+func writeSnapshotEntries(kvs ...[]byte) []byte {
+	block := &types.Block{Header: &types.Header{Height: 2}}
+	info := store.SnapshotInfo{Height: 2, BlockHash: block.Hash()}
+	sink := common.NewZeroCopySink(nil)
+	sink.WriteBytes([]byte(SNAPSHOT_MAGIC))
+	sink.WriteByte(SNAPSHOT_VERSION)
+	info.Serialization(sink)
+	sink.WriteVarBytes(common.SerializeToBytes(block))
+	sink.WriteUint32(3)
+	for i := 0; i < 3; i++ {
+		sink.WriteHash(common.Uint256{byte(i)})
+	}
+	for _, kv := range kvs {
+		sink.WriteVarBytes(kv)
+	}
+	sink.WriteVarBytes(nil)
+	digest := sha256.Sum256(sink.Bytes())
+	return append(sink.Bytes(), digest[:]...)
+}
+
+func TestStateSnapshotKeys(t *testing.T) {
+	state := NewMemStateStore(0)
+	first := []byte{byte(scom.ST_STORAGE), 1}
+	second := []byte{byte(scom.ST_STORAGE), 2}
+	onEntry := func(key, value []byte) error { return nil }
+
+	_, _, _, err := readSnapshot(bytes.NewReader(writeSnapshotEntries(first, []byte("1"), second, []byte("2"))),
+		onEntry, nil)
+	assert.Nil(t, err)
+	//the keys must be ascending, a key can not be read twice
+	_, _, _, err = readSnapshot(bytes.NewReader(writeSnapshotEntries(second, []byte("2"), first, []byte("1"))),
+		onEntry, nil)
+	assert.NotNil(t, err)
+	_, _, _, err = readSnapshot(bytes.NewReader(writeSnapshotEntries(first, []byte("1"), first, []byte("2"))),
+		onEntry, nil)
+	assert.NotNil(t, err)
+	//the keys the import does not verify are rejected
+	for _, key := range [][]byte{
+		genTrieNodeKey(common.Uint256{1}),
+		state.genCrossStatesKey(2),
+		state.genStateTrieRootKey(1),
+		state.genStateMerkleRootKey(3),
+		state.genStateMerkleTreeKey(),
+		state.getCurrentBlockKey(),
+		{byte(scom.ST_BOOKKEEPER)},
+	} {
+		_, _, _, err = readSnapshot(bytes.NewReader(writeSnapshotEntries(key, []byte("1"))), onEntry, nil)
+		assert.NotNil(t, err, "key %x", key)
+	}
+}
+
+func TestSnapshotTrieBuilder(t *testing.T) {
+	for _, count := range []int{0, 1, 2, 3, 100, 2*SNAPSHOT_BATCH_SIZE + 1} {
+		db, err := leveldbstore.NewMemLevelDBStore()
+		assert.Nil(t, err)
+		var kvs []trieKV
+		db.NewBatch()
+		for i := 0; i < count; i++ {
+			kv := newTrieKV([]byte{byte(scom.ST_STORAGE), byte(i), byte(i >> 8), byte(i >> 16)}, []byte{byte(i)})
+			kvs = append(kvs, kv)
+			db.BatchPut(genSnapshotLeafKey(kv.KeyHash), kv.ValueHash[:])
+		}
+		assert.Nil(t, db.BatchCommit())
+		sortTrieKVs(kvs)
+		trie := newStateTrie(nil)
+		expected, err := trie.build(0, kvs)
+		assert.Nil(t, err)
+
+		root, err := buildSnapshotTrie(db)
+		assert.Nil(t, err)
+		assert.Equal(t, expected, root)
+		//the nodes are saved and the leaf index is deleted
+		for hash, node := range trie.nodes {
+			value, err := db.Get(genTrieNodeKey(hash))
+			assert.Nil(t, err)
+			assert.Equal(t, node, value)
+		}
+		iter := db.NewIterator([]byte{byte(scom.ST_SNAPSHOT_LEAF)})
+		assert.False(t, iter.Next())
+		iter.Release()
+		db.Close()
+	}
+}
@@ -24,6 +24,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"os"
 
 	"github.com/conntectome/cntm/common"
 	"github.com/conntectome/cntm/common/log"
@@ -50,12 +51,20 @@ type StateStore struct {
 	merkleTree           *merkle.CompactMerkleTree //Merkle tree of block root
 	deltaMerkleTree      *merkle.CompactMerkleTree //Merkle tree of delta state root
 	merkleHashStore      merkle.HashStore
+	stateMerklePath      string           //State merkle tree store path
+	stateMerkleHashStore merkle.HashStore //Hashes of the state merkle tree, nil if the state proofs are not served
 	stateHashCheckHeight uint32
+	archive              *stateArchive //State archive, nil if the archive mode is disabled
+	pruner               *statePruner  //State pruner, nil if the pruning mode is disabled
 }
 
 //NewStateStore return state store instance
-func NewStateStore(dbDir, merklePath string, stateHashCheckHeight uint32) (*StateStore, error) {
+func NewStateStore(dbDir, merklePath, stateMerklePath string, stateHashCheckHeight uint32) (*StateStore, error) {
 	var err error
+	//a snapshot import stopped after moving the state store away
+	if _, err = os.Stat(dbDir); os.IsNotExist(err) {
+		os.Rename(dbDir+SNAPSHOT_BACKUP_SUFFIX, dbDir)
+	}
 	store, err := leveldbstore.NewLevelDBStore(dbDir)
 	if err != nil {
 		return nil, err
@@ -64,6 +73,7 @@ func NewStateStore(dbDir, merklePath string, stateHashCheckHeight uint32) (*Stat
 		dbDir:                dbDir,
 		store:                store,
 		merklePath:           merklePath,
+		stateMerklePath:      stateMerklePath,
 		stateHashCheckHeight: stateHashCheckHeight,
 	}
 	_, height, err := stateStore.GetCurrentBlock()
@@ -80,10 +90,12 @@ func NewStateStore(dbDir, merklePath string, stateHashCheckHeight uint32) (*Stat
 // for test
 func NewMemStateStore(stateHashHeight uint32) *StateStore {
 	store, _ := leveldbstore.NewMemLevelDBStore()
+	stateMerkleHashStore := merkle.NewMemHashStore()
 	stateStore := &StateStore{
 		store:                store,
 		merkleTree:           merkle.NewTree(0, nil, nil),
-		deltaMerkleTree:      merkle.NewTree(0, nil, nil),
+		deltaMerkleTree:      merkle.NewTree(0, nil, stateMerkleHashStore),
+		stateMerkleHashStore: stateMerkleHashStore,
 		stateHashCheckHeight: stateHashHeight,
 	}
 
@@ -103,6 +115,31 @@ func (self *StateStore) BatchDeleteRawKey(key []byte) {
 	self.store.BatchDelete(key)
 }
 
+//getPreviousValues returns the values the keys of writeSet have in store, the keys not in store are deleted in the result
+func (self *StateStore) getPreviousValues(writeSet *overlaydb.MemDB) (*overlaydb.MemDB, error) {
+	prev := overlaydb.NewMemDB(0, 0)
+	var err error
+	writeSet.ForEach(func(key, val []byte) {
+		if err != nil {
+			return
+		}
+		value, e := self.store.Get(key)
+		if e != nil {
+			if e != scom.ErrNotFound {
+				err = e
+				return
+			}
+			prev.Delete(key)
+			return
+		}
+		prev.Put(key, value)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return prev, nil
+}
+
 func (self *StateStore) init(currBlockHeight uint32) error {
 	treeSize, hashes, err := self.GetBlockMerkleTree()
 	if err != nil && err != scom.ErrNotFound {
@@ -117,19 +154,76 @@ func (self *StateStore) init(currBlockHeight uint32) error {
 	}
 	self.merkleTree = merkle.NewTree(treeSize, hashes, self.merkleHashStore)
 
+	treeSize, hashes = 0, nil
 	if currBlockHeight >= self.stateHashCheckHeight {
-		treeSize, hashes, err := self.GetStateMerkleTree()
+		treeSize, hashes, err = self.GetStateMerkleTree()
 		if err != nil && err != scom.ErrNotFound {
 			return err
 		}
 		if treeSize > 0 && treeSize != currBlockHeight-self.stateHashCheckHeight+1 {
 			return fmt.Errorf("merkle tree size is inconsistent with blockheight: %d", currBlockHeight+1)
 		}
-		self.deltaMerkleTree = merkle.NewTree(treeSize, hashes, nil)
 	}
+	if self.stateMerkleHashStore != nil {
+		self.stateMerkleHashStore.Close()
+		self.stateMerkleHashStore = nil
+	}
+	if self.stateMerklePath != "" {
+		self.stateMerkleHashStore, err = merkle.NewFileHashStore(self.stateMerklePath, treeSize)
+		if err != nil {
+			log.Warnf("state merkle store is inconsistent with StateStore, rebuild it: %s", err)
+			self.stateMerkleHashStore, err = self.rebuildStateMerkleHashStore(treeSize, hashes)
+			if err != nil {
+				log.Warnf("rebuild state merkle store error: %s, state proofs will be disabled", err)
+			}
+		}
+	}
+	if currBlockHeight >= self.stateHashCheckHeight {
+		self.deltaMerkleTree = merkle.NewTree(treeSize, hashes, self.stateMerkleHashStore)
+	}
+	return nil
+}
+
+//bulkHashStore skips the sync of every appended hash while a hash store is rebuilt
+type bulkHashStore struct {
+	merkle.HashStore
+}
+
+func (self bulkHashStore) Flush() error {
 	return nil
 }
 
+//rebuildStateMerkleHashStore rebuilds the state merkle store from the state transition hashes of the blocks
+func (self *StateStore) rebuildStateMerkleHashStore(treeSize uint32, hashes []common.Uint256) (merkle.HashStore, error) {
+	if err := os.Remove(self.stateMerklePath); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	hashStore, err := merkle.NewFileHashStore(self.stateMerklePath, 0)
+	if err != nil {
+		return nil, err
+	}
+	tree := merkle.NewTree(0, nil, bulkHashStore{hashStore})
+	for i := uint32(0); i < treeSize; i++ {
+		value, err := self.store.Get(self.genStateMerkleRootKey(self.stateHashCheckHeight + i))
+		if err != nil || len(value) < common.UINT256_SIZE {
+			hashStore.Close()
+			return nil, fmt.Errorf("state transition hash of block %d not found", self.stateHashCheckHeight+i)
+		}
+		var hash common.Uint256
+		copy(hash[:], value)
+		tree.AppendHash(hash)
+	}
+	if err = hashStore.Flush(); err != nil {
+		hashStore.Close()
+		return nil, err
+	}
+	if tree.Root() != merkle.NewTree(treeSize, hashes, nil).Root() {
+		hashStore.Close()
+		return nil, fmt.Errorf("rebuilt state merkle root mismatch")
+	}
+	return hashStore, nil
+}
+
 //GetStateMerkleTree return merkle tree size an tree node
 func (self *StateStore) GetStateMerkleTree() (uint32, []common.Uint256, error) {
 	key := self.genStateMerkleTreeKey()
@@ -187,22 +281,13 @@ func (self *StateStore) AddStateMerkleTreeRoot(blockHeight uint32, writeSetHash
 	if blockHeight < self.stateHashCheckHeight {
 		return nil
 	} else if blockHeight == self.stateHashCheckHeight {
-		self.deltaMerkleTree = merkle.NewTree(0, nil, nil)
+		self.deltaMerkleTree = merkle.NewTree(0, nil, self.stateMerkleHashStore)
 	}
-	key := self.genStateMerkleTreeKey()
-
 	self.deltaMerkleTree.AppendHash(writeSetHash)
-	treeSize := self.deltaMerkleTree.TreeSize()
-	hashes := self.deltaMerkleTree.Hashes()
-	value := common.NewZeroCopySink(make([]byte, 0, 4+len(hashes)*common.UINT256_SIZE))
-	value.WriteUint32(treeSize)
-	for _, hash := range hashes {
-		value.WriteHash(hash)
-	}
-	self.store.BatchPut(key, value.Bytes())
+	self.putMerkleTree(self.genStateMerkleTreeKey(), self.deltaMerkleTree)
 
-	key = self.genStateMerkleRootKey(blockHeight)
-	value.Reset()
+	key := self.genStateMerkleRootKey(blockHeight)
+	value := common.NewZeroCopySink(make([]byte, 0, 2*common.UINT256_SIZE))
 	value.WriteHash(writeSetHash)
 	value.WriteHash(self.deltaMerkleTree.Root())
 	self.store.BatchPut(key, value.Bytes())
@@ -212,18 +297,34 @@ func (self *StateStore) AddStateMerkleTreeRoot(blockHeight uint32, writeSetHash
 
 //AddBlockMerkleTreeRoot add a new tree root
 func (self *StateStore) AddBlockMerkleTreeRoot(txRoot common.Uint256) error {
-	key := self.genBlockMerkleTreeKey()
-
 	self.merkleTree.AppendHash(txRoot)
-	treeSize := self.merkleTree.TreeSize()
-	hashes := self.merkleTree.Hashes()
+	self.putMerkleTree(self.genBlockMerkleTreeKey(), self.merkleTree)
+	return nil
+}
+
+//putMerkleTree adds the size and the hashes of the merkle tree to the batch
+func (self *StateStore) putMerkleTree(key []byte, tree *merkle.CompactMerkleTree) {
+	hashes := tree.Hashes()
 	value := common.NewZeroCopySink(make([]byte, 0, 4+len(hashes)*common.UINT256_SIZE))
-	value.WriteUint32(treeSize)
+	value.WriteUint32(tree.TreeSize())
 	for _, hash := range hashes {
 		value.WriteHash(hash)
 	}
 	self.store.BatchPut(key, value.Bytes())
-	return nil
+}
+
+//GetStateMerkleProof returns the audit path of the state transition hash of the block at height in the state
+//merkle tree after the block, and the size of the tree
+func (self *StateStore) GetStateMerkleProof(height uint32) ([]common.Uint256, uint32, error) {
+	if height < self.stateHashCheckHeight {
+		return nil, 0, fmt.Errorf("block %d is before the state hash check height %d", height, self.stateHashCheckHeight)
+	}
+	treeSize := height - self.stateHashCheckHeight + 1
+	proof, err := self.deltaMerkleTree.InclusionProof(treeSize-1, treeSize)
+	if err != nil {
+		return nil, 0, err
+	}
+	return proof, treeSize, nil
 }
 
 //GetMerkleProof return merkle proof of block
@@ -443,6 +544,19 @@ func (self *StateStore) genStateMerkleRootKey(height uint32) []byte {
 	return key
 }
 
+//blockKeys returns the keys saved with the block at height out of its write set, the block merkle trees and the
+//per block hashes, which must be rolled back with the state when the block is reverted
+func (self *StateStore) blockKeys(height uint32) *overlaydb.MemDB {
+	keys := overlaydb.NewMemDB(0, 0)
+	keys.Delete(self.getCurrentBlockKey())
+	keys.Delete(self.genBlockMerkleTreeKey())
+	keys.Delete(self.genStateMerkleTreeKey())
+	keys.Delete(self.genStateMerkleRootKey(height))
+	keys.Delete(self.genCrossStatesKey(height))
+	keys.Delete(self.genStateTrieRootKey(height))
+	return keys
+}
+
 //ClearAll clear all data in state store
 func (self *StateStore) ClearAll() error {
 	self.store.NewBatch()
@@ -460,7 +574,12 @@ func (self *StateStore) ClearAll() error {
 
 //Close state store
 func (self *StateStore) Close() error {
-	self.merkleHashStore.Close()
+	if self.merkleHashStore != nil {
+		self.merkleHashStore.Close()
+	}
+	if self.stateMerkleHashStore != nil {
+		self.stateMerkleHashStore.Close()
+	}
 	return self.store.Close()
 }
 
@@ -0,0 +1,473 @@
+/*
+ * Copyright (C) 2018 The cntm Authors
+ * This file is part of The cntm library.
+ *
+ * The cntm is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The cntm is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The cntm.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package ledgerstore
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"sort"
+
+	"github.com/conntectome/cntm/common"
+	"github.com/conntectome/cntm/common/log"
+	"github.com/conntectome/cntm/core/store"
+	scom "github.com/conntectome/cntm/core/store/common"
+	"github.com/conntectome/cntm/core/store/overlaydb"
+	"github.com/conntectome/cntm/core/types"
+	"github.com/conntectome/cntm/merkle"
+)
+
+//The state trie is a sparse merkle tree of the contract and storage states, it lets a key be proved in or out
+//of the state after a block. Its nodes are content addressed and shared by the tries of the blocks, so the trie of
+//every block since the activation stays provable. In the pruning mode the nodes only the pruned blocks use are
+//deleted with the state history.
+const TRIE_NODE_LEN = 1 + 2*common.UINT256_SIZE
+
+//stateTriePrefixes are the state keys committed to by the state trie
+var stateTriePrefixes = []scom.DataEntryPrefix{scom.ST_CCNTMRACT, scom.ST_STORAGE, scom.ST_DESTROYED,
+	scom.ST_ETH_CODE, scom.ST_ETH_ACCOUNT}
+
+func isStateTrieKey(key []byte) bool {
+	if len(key) == 0 {
+		return false
+	}
+	for _, prefix := range stateTriePrefixes {
+		if key[0] == byte(prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+//trieKV is a key to update in the state trie, ValueHash is empty if the key is deleted
+type trieKV struct {
+	KeyHash   common.Uint256
+	ValueHash common.Uint256
+}
+
+type stateTrie struct {
+	store scom.PersistStore
+	nodes map[common.Uint256][]byte //Nodes added by the updates, not saved yet
+	stale []common.Uint256          //Nodes replaced by the updates
+}
+
+func newStateTrie(store scom.PersistStore) *stateTrie {
+	return &stateTrie{
+		store: store,
+		nodes: make(map[common.Uint256][]byte),
+	}
+}
+
+func genTrieNodeKey(hash common.Uint256) []byte {
+	return append([]byte{byte(scom.ST_TRIE_NODE)}, hash[:]...)
+}
+
+func (self *stateTrie) getNode(hash common.Uint256) ([]byte, error) {
+	if node, ok := self.nodes[hash]; ok {
+		return node, nil
+	}
+	node, err := self.store.Get(genTrieNodeKey(hash))
+	if err != nil {
+		return nil, fmt.Errorf("get trie node %s error %s", hash.ToHexString(), err)
+	}
+	if len(node) != TRIE_NODE_LEN {
+		return nil, fmt.Errorf("invalid trie node %s", hash.ToHexString())
+	}
+	return node, nil
+}
+
+func (self *stateTrie) putNode(kind byte, first, second common.Uint256) common.Uint256 {
+	node := make([]byte, 0, TRIE_NODE_LEN)
+	node = append(node, kind)
+	node = append(node, first[:]...)
+	node = append(node, second[:]...)
+	hash := common.Uint256(sha256.Sum256(node))
+	self.nodes[hash] = node
+	return hash
+}
+
+func (self *stateTrie) isLeaf(hash common.Uint256) (bool, error) {
+	node, err := self.getNode(hash)
+	if err != nil {
+		return false, err
+	}
+	return node[0] == merkle.SPARSE_LEAF_NODE, nil
+}
+
+//join returns the subtree of two children, a lone leaf moves up in place of the subtree
+func (self *stateTrie) join(left, right common.Uint256) (common.Uint256, error) {
+	if left == common.UINT256_EMPTY && right == common.UINT256_EMPTY {
+		return common.UINT256_EMPTY, nil
+	}
+	if left == common.UINT256_EMPTY || right == common.UINT256_EMPTY {
+		child := left
+		if child == common.UINT256_EMPTY {
+			child = right
+		}
+		leaf, err := self.isLeaf(child)
+		if err != nil {
+			return common.UINT256_EMPTY, err
+		}
+		if leaf {
+			return child, nil
+		}
+	}
+	return self.putNode(merkle.SPARSE_INNER_NODE, left, right), nil
+}
+
+//splitKVs returns the index of the first kv going to the right subtree at depth, kvs is sorted by key hash
+func splitKVs(kvs []trieKV, depth int) int {
+	return sort.Search(len(kvs), func(i int) bool {
+		return merkle.SparseKeyBit(kvs[i].KeyHash, depth) == 1
+	})
+}
+
+//build returns the subtree at depth holding the kvs which are not deleted
+func (self *stateTrie) build(depth int, kvs []trieKV) (common.Uint256, error) {
+	live := make([]trieKV, 0, len(kvs))
+	for _, kv := range kvs {
+		if kv.ValueHash != common.UINT256_EMPTY {
+			live = append(live, kv)
+		}
+	}
+	return self.buildLive(depth, live)
+}
+
+func (self *stateTrie) buildLive(depth int, kvs []trieKV) (common.Uint256, error) {
+	switch len(kvs) {
+	case 0:
+		return common.UINT256_EMPTY, nil
+	case 1:
+		return self.putNode(merkle.SPARSE_LEAF_NODE, kvs[0].KeyHash, kvs[0].ValueHash), nil
+	}
+	if depth >= merkle.SPARSE_MAX_DEPTH {
+		return common.UINT256_EMPTY, fmt.Errorf("duplicated key hash %s in state trie", kvs[0].KeyHash.ToHexString())
+	}
+	split := splitKVs(kvs, depth)
+	left, err := self.buildLive(depth+1, kvs[:split])
+	if err != nil {
+		return common.UINT256_EMPTY, err
+	}
+	right, err := self.buildLive(depth+1, kvs[split:])
+	if err != nil {
+		return common.UINT256_EMPTY, err
+	}
+	return self.join(left, right)
+}
+
+//update applies the kvs to the subtree at depth and returns the new subtree, kvs is sorted by key hash
+func (self *stateTrie) update(hash common.Uint256, depth int, kvs []trieKV) (common.Uint256, error) {
+	if len(kvs) == 0 {
+		return hash, nil
+	}
+	if hash == common.UINT256_EMPTY {
+		return self.build(depth, kvs)
+	}
+	root, err := self.updateNode(hash, depth, kvs)
+	if err == nil && root != hash {
+		self.stale = append(self.stale, hash)
+	}
+	return root, err
+}
+
+func (self *stateTrie) updateNode(hash common.Uint256, depth int, kvs []trieKV) (common.Uint256, error) {
+	node, err := self.getNode(hash)
+	if err != nil {
+		return common.UINT256_EMPTY, err
+	}
+	var first, second common.Uint256
+	copy(first[:], node[1:1+common.UINT256_SIZE])
+	copy(second[:], node[1+common.UINT256_SIZE:])
+	if node[0] == merkle.SPARSE_LEAF_NODE {
+		//the existing leaf is kept unless the kvs update it
+		i := sort.Search(len(kvs), func(i int) bool {
+			return bytes.Compare(kvs[i].KeyHash[:], first[:]) >= 0
+		})
+		if i < len(kvs) && kvs[i].KeyHash == first {
+			return self.build(depth, kvs)
+		}
+		merged := make([]trieKV, 0, len(kvs)+1)
+		merged = append(merged, kvs[:i]...)
+		merged = append(merged, trieKV{KeyHash: first, ValueHash: second})
+		merged = append(merged, kvs[i:]...)
+		return self.build(depth, merged)
+	}
+	if depth >= merkle.SPARSE_MAX_DEPTH {
+		return common.UINT256_EMPTY, fmt.Errorf("state trie deeper than %d", merkle.SPARSE_MAX_DEPTH)
+	}
+	split := splitKVs(kvs, depth)
+	left, err := self.update(first, depth+1, kvs[:split])
+	if err != nil {
+		return common.UINT256_EMPTY, err
+	}
+	right, err := self.update(second, depth+1, kvs[split:])
+	if err != nil {
+		return common.UINT256_EMPTY, err
+	}
+	return self.join(left, right)
+}
+
+//staleNodes returns the nodes replaced by the updates which are not in the trie any more, a leaf moved to another
+//depth is added again
+func (self *stateTrie) staleNodes() []common.Uint256 {
+	stale := make([]common.Uint256, 0, len(self.stale))
+	for _, hash := range self.stale {
+		if _, ok := self.nodes[hash]; !ok {
+			stale = append(stale, hash)
+		}
+	}
+	return stale
+}
+
+//prove returns the proof of the key hash in the trie of root
+func (self *stateTrie) prove(root, keyHash common.Uint256) (*merkle.SparseMerkleProof, error) {
+	proof := &merkle.SparseMerkleProof{}
+	hash := root
+	for depth := 0; hash != common.UINT256_EMPTY; depth++ {
+		node, err := self.getNode(hash)
+		if err != nil {
+			return nil, err
+		}
+		var first, second common.Uint256
+		copy(first[:], node[1:1+common.UINT256_SIZE])
+		copy(second[:], node[1+common.UINT256_SIZE:])
+		if node[0] == merkle.SPARSE_LEAF_NODE {
+			proof.Leaf = &merkle.SparseMerkleLeaf{KeyHash: first, ValueHash: second}
+			break
+		}
+		if depth >= merkle.SPARSE_MAX_DEPTH {
+			return nil, fmt.Errorf("state trie deeper than %d", merkle.SPARSE_MAX_DEPTH)
+		}
+		if merkle.SparseKeyBit(keyHash, depth) == 0 {
+			proof.Siblings = append(proof.Siblings, second)
+			hash = first
+		} else {
+			proof.Siblings = append(proof.Siblings, first)
+			hash = second
+		}
+	}
+	return proof, nil
+}
+
+func sortTrieKVs(kvs []trieKV) {
+	sort.Slice(kvs, func(i, j int) bool {
+		return bytes.Compare(kvs[i].KeyHash[:], kvs[j].KeyHash[:]) < 0
+	})
+}
+
+func newTrieKV(key, value []byte) trieKV {
+	kv := trieKV{KeyHash: sha256.Sum256(key)}
+	if len(value) != 0 {
+		kv.ValueHash = sha256.Sum256(value)
+	}
+	return kv
+}
+
+//buildStateTrie returns the state trie of all the state keys in overlay
+func buildStateTrie(trie *stateTrie, overlay *overlaydb.OverlayDB) (common.Uint256, error) {
+	var kvs []trieKV
+	for _, prefix := range stateTriePrefixes {
+		iter := overlay.NewIterator([]byte{byte(prefix)})
+		for has := iter.First(); has; has = iter.Next() {
+			kvs = append(kvs, newTrieKV(iter.Key(), iter.Value()))
+		}
+		iter.Release()
+		if err := iter.Error(); err != nil {
+			return common.UINT256_EMPTY, err
+		}
+	}
+	sortTrieKVs(kvs)
+	return trie.build(0, kvs)
+}
+
+//updateStateTrie returns the state trie of root after the state keys in writeSet are applied
+func updateStateTrie(trie *stateTrie, root common.Uint256, writeSet *overlaydb.MemDB) (common.Uint256, error) {
+	var kvs []trieKV
+	writeSet.ForEach(func(key, val []byte) {
+		if isStateTrieKey(key) {
+			kvs = append(kvs, newTrieKV(key, val))
+		}
+	})
+	sortTrieKVs(kvs)
+	return trie.update(root, 0, kvs)
+}
+
+func (self *StateStore) genStateTrieRootKey(height uint32) []byte {
+	key := make([]byte, 5, 5)
+	key[0] = byte(scom.DATA_STATE_TRIE_ROOT)
+	binary.LittleEndian.PutUint32(key[1:], height)
+	return key
+}
+
+//GetStateTrieRoot returns the change hash of the block at height and the state trie root after the block
+func (self *StateStore) GetStateTrieRoot(height uint32) (common.Uint256, common.Uint256, error) {
+	value, err := self.store.Get(self.genStateTrieRootKey(height))
+	if err != nil {
+		return common.UINT256_EMPTY, common.UINT256_EMPTY, err
+	}
+	source := common.NewZeroCopySource(value)
+	changeHash, eof := source.NextHash()
+	root, eof := source.NextHash()
+	if eof {
+		return common.UINT256_EMPTY, common.UINT256_EMPTY, fmt.Errorf("invalid state trie root of block %d", height)
+	}
+	return changeHash, root, nil
+}
+
+func genTrieStaleKey(height uint32) []byte {
+	key := make([]byte, 5)
+	key[0] = byte(scom.ST_TRIE_STALE)
+	binary.BigEndian.PutUint32(key[1:], height)
+	return key
+}
+
+func genTrieStaleNodeKey(hash common.Uint256) []byte {
+	return append([]byte{byte(scom.ST_TRIE_STALE_NODE)}, hash[:]...)
+}
+
+//saveStateTrie saves the new nodes and the root of the state trie after the block at height to the batch. In the
+//archive mode the nodes the block replaced are indexed, so the pruning can delete them with the history of the block.
+func (self *StateStore) saveStateTrie(height uint32, changeHash, root common.Uint256,
+	nodes map[common.Uint256][]byte, stale []common.Uint256) {
+	for hash, node := range nodes {
+		self.store.BatchPut(genTrieNodeKey(hash), node)
+		//a node replaced by an older block may be added again, the pruning of the older block must keep it
+		self.store.BatchDelete(genTrieStaleNodeKey(hash))
+	}
+	if self.archive != nil && len(stale) != 0 {
+		value := make([]byte, 4)
+		binary.LittleEndian.PutUint32(value, height)
+		sink := common.NewZeroCopySink(make([]byte, 0, len(stale)*common.UINT256_SIZE))
+		for _, hash := range stale {
+			self.store.BatchPut(genTrieStaleNodeKey(hash), value)
+			sink.WriteHash(hash)
+		}
+		self.store.BatchPut(genTrieStaleKey(height), sink.Bytes())
+	}
+	sink := common.NewZeroCopySink(make([]byte, 0, 2*common.UINT256_SIZE))
+	sink.WriteHash(changeHash)
+	sink.WriteHash(root)
+	self.store.BatchPut(self.genStateTrieRootKey(height), sink.Bytes())
+}
+
+//GetStateTrieHeight returns the height from which the state trie is enabled
+func (this *LedgerStoreImp) GetStateTrieHeight() uint32 {
+	return this.stateTrieHeight
+}
+
+//GetStateTrieRoot returns the state trie root after the block at height
+func (this *LedgerStoreImp) GetStateTrieRoot(height uint32) (common.Uint256, error) {
+	if height < this.stateTrieHeight {
+		return common.UINT256_EMPTY, scom.ErrNotFound
+	}
+	_, root, err := this.stateStore.GetStateTrieRoot(height)
+	return root, err
+}
+
+//proveStateTrie returns the proof of the key in the state trie of root
+func (self *StateStore) proveStateTrie(root common.Uint256, key []byte) (*merkle.SparseMerkleProof, error) {
+	return newStateTrie(self.store).prove(root, sha256.Sum256(key))
+}
+
+//executeStateTrie updates the state trie with the block, and makes the state transition hash of the block commit
+//to the change hash and the state trie root. The trie is built from the whole state at the activation height.
+func (this *LedgerStoreImp) executeStateTrie(height uint32, overlay *overlaydb.OverlayDB,
+	result *store.ExecuteResult) error {
+	trie := newStateTrie(this.stateStore.store)
+	var root common.Uint256
+	var err error
+	if height == this.stateTrieHeight {
+		root, err = buildStateTrie(trie, overlay)
+	} else {
+		_, prevRoot, e := this.stateStore.GetStateTrieRoot(height - 1)
+		switch e {
+		case nil:
+			root, err = updateStateTrie(trie, prevRoot, result.WriteSet)
+		case scom.ErrNotFound:
+			//the blocks since the activation were saved without the state trie by an older node
+			log.Warnf("state trie root of block %d not found, build the state trie from the state", height-1)
+			root, err = buildStateTrie(trie, overlay)
+		default:
+			return fmt.Errorf("GetStateTrieRoot error %s", e)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("update state trie error %s", err)
+	}
+	result.ChangeHash = result.Hash
+	result.StateTrieRoot = root
+	result.StateTrieNodes = trie.nodes
+	result.StaleTrieNodes = trie.staleNodes()
+	result.Hash = types.StateTransitionHash(result.ChangeHash, root)
+	return nil
+}
+
+//GetStorageProof returns the proof of the state key after the block at height, the key is proved not to exist
+//if it has no value. The proofs before the current block need the archive mode for the value of the key.
+func (this *LedgerStoreImp) GetStorageProof(key []byte, height uint32) (*types.StorageProof, error) {
+	if !isStateTrieKey(key) {
+		return nil, fmt.Errorf("the key is not committed to by the state trie")
+	}
+	if height < this.stateTrieHeight {
+		return nil, fmt.Errorf("the state trie is enabled from block %d", this.stateTrieHeight)
+	}
+	currHeight := this.GetCurrentBlockHeight()
+	if height > currHeight {
+		return nil, scom.ErrNotFound
+	}
+	changeHash, trieRoot, err := this.stateStore.GetStateTrieRoot(height)
+	if err != nil {
+		return nil, fmt.Errorf("GetStateTrieRoot error %s", err)
+	}
+	overlay := this.stateStore.NewOverlayDB()
+	if height != currHeight {
+		overlay, err = this.NewOverlayDBAt(height)
+		if err != nil {
+			return nil, err
+		}
+	}
+	value, err := overlay.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	trieProof, err := this.stateStore.proveStateTrie(trieRoot, key)
+	if err != nil {
+		return nil, err
+	}
+	//the state moves on if a block is saved while the proof is made
+	if err = trieProof.Verify(trieRoot, key, value); err != nil {
+		return nil, fmt.Errorf("the state of block %d changed, try again", height)
+	}
+	stateProof, treeSize, err := this.stateStore.GetStateMerkleProof(height)
+	if err != nil {
+		return nil, fmt.Errorf("GetStateMerkleProof error %s", err)
+	}
+	return &types.StorageProof{
+		Height:     height,
+		BlockHash:  this.GetBlockHash(height),
+		Key:        key,
+		Value:      value,
+		TrieProof:  *trieProof,
+		TrieRoot:   trieRoot,
+		ChangeHash: changeHash,
+		StateProof: stateProof,
+		TreeSize:   treeSize,
+	}, nil
+}
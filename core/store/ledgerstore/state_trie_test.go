@@ -0,0 +1,82 @@
+/*
+ * Copyright (C) 2018 The cntm Authors
+ * This file is part of The cntm library.
+ *
+ * The cntm is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The cntm is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The cntm.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package ledgerstore
+
+import (
+	"testing"
+
+	"github.com/conntectome/cntm/common"
+	scom "github.com/conntectome/cntm/core/store/common"
+	"github.com/conntectome/cntm/core/store/overlaydb"
+	"github.com/stretchr/testify/assert"
+)
+
+func trieTestKey(i int) []byte {
+	return []byte{byte(scom.ST_STORAGE), byte(i >> 8), byte(i)}
+}
+
+func TestStateTrieUpdate(t *testing.T) {
+	state := NewMemStateStore(0)
+	values := make(map[int][]byte)
+
+	//the trie updated block by block has the root of the trie built from the state
+	root := common.UINT256_EMPTY
+	for round := 0; round < 5; round++ {
+		writeSet := overlaydb.NewMemDB(0, 0)
+		for i := round * 7; i < 200; i += 3 + round {
+			if round > 0 && i%2 == 0 {
+				writeSet.Delete(trieTestKey(i))
+				delete(values, i)
+			} else {
+				value := []byte{byte(i), byte(round)}
+				writeSet.Put(trieTestKey(i), value)
+				values[i] = value
+			}
+		}
+		//keys out of the state trie are left out
+		writeSet.Put([]byte{byte(scom.ST_ARCHIVE), byte(round)}, []byte{1})
+
+		trie := newStateTrie(state.store)
+		var err error
+		root, err = updateStateTrie(trie, root, writeSet)
+		assert.Nil(t, err)
+		state.NewBatch()
+		state.saveStateTrie(uint32(round), common.UINT256_EMPTY, root, trie.nodes, trie.staleNodes())
+		assert.Nil(t, state.CommitTo())
+
+		var kvs []trieKV
+		for i, value := range values {
+			kvs = append(kvs, newTrieKV(trieTestKey(i), value))
+		}
+		sortTrieKVs(kvs)
+		built, err := newStateTrie(state.store).build(0, kvs)
+		assert.Nil(t, err)
+		assert.Equal(t, built, root)
+	}
+
+	_, saved, err := state.GetStateTrieRoot(4)
+	assert.Nil(t, err)
+	assert.Equal(t, root, saved)
+	for i := 0; i < 256; i++ {
+		proof, err := state.proveStateTrie(root, trieTestKey(i))
+		assert.Nil(t, err)
+		assert.Nil(t, proof.Verify(root, trieTestKey(i), values[i]))
+		assert.NotNil(t, proof.Verify(root, trieTestKey(i), []byte{byte(i), 9}))
+	}
+}
@@ -0,0 +1,237 @@
+/*
+ * Copyright (C) 2018 The cntm Authors
+ * This file is part of The cntm library.
+ *
+ * The cntm is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The cntm is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The cntm.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package ledgerstore
+
+import (
+	"fmt"
+	"math/big"
+	"time"
+
+	common2 "github.com/ethereum/go-ethereum/common"
+	types3 "github.com/ethereum/go-ethereum/core/types"
+	"github.com/conntectome/cntm/common"
+	"github.com/conntectome/cntm/common/config"
+	"github.com/conntectome/cntm/core/store/leveldbstore"
+	"github.com/conntectome/cntm/core/store/overlaydb"
+	"github.com/conntectome/cntm/core/types"
+	evm2 "github.com/conntectome/cntm/smartcontract/service/evm"
+	types5 "github.com/conntectome/cntm/smartcontract/service/evm/types"
+	"github.com/conntectome/cntm/smartcontract/service/native/cntm"
+	"github.com/conntectome/cntm/smartcontract/service/native/utils"
+	"github.com/conntectome/cntm/smartcontract/service/cntmvm"
+	"github.com/conntectome/cntm/smartcontract/storage"
+	"github.com/conntectome/cntm/vm/evm"
+	"github.com/conntectome/cntm/vm/evm/params"
+)
+
+//TRACE_DEADLINE_CHECK_STEPS is the number of evm steps between the deadline checks of a trace
+const TRACE_DEADLINE_CHECK_STEPS = 1000
+
+//TraceEip155Tx executes the message on the state after the block at height with the given vm config, the result
+//is not committed to store. The state of a past block is read from the archive, or rebuilt from the state history.
+//The execution is cancelled once timeout is passed.
+func (this *LedgerStoreImp) TraceEip155Tx(msg types3.Message, height uint32, vmConfig evm.Config,
+	timeout time.Duration) (*types5.ExecutionResult, error) {
+	deadline := newTraceDeadline(timeout)
+	current := this.GetCurrentBlockHeight()
+	if height > current {
+		return nil, fmt.Errorf("block %d is not saved yet", height)
+	}
+	// use previous block time to make it predictable for easy test
+	blockTime := uint32(time.Now().Unix())
+	if header, err := this.GetHeaderByHeight(height); err == nil {
+		blockTime = header.Timestamp + 1
+	}
+	overlay := this.stateStore.NewOverlayDB()
+	if height < current {
+		var release func()
+		var err error
+		overlay, release, err = this.stateAfter(height)
+		if err != nil {
+			return nil, err
+		}
+		defer release()
+	}
+	cache := storage.NewCacheDB(overlay)
+	statedb := storage.NewStateDB(cache, common2.Hash{}, common2.Hash(this.GetBlockHash(height)), cntm.OngBalanceHandle{})
+	chainConfig := params.GetChainConfig(config.DefConfig.P2PNode.EVMChainId)
+
+	invokeNative, err := newNativeInvoker(this, cache, height+1, blockTime, this.GetBlockHash(height+1), &types.Transaction{})
+	if err != nil {
+		return nil, err
+	}
+	blockContext := evm2.NewEVMBlockCcntmext(height+1, blockTime, this)
+	blockContext.InvokeNative = invokeNative
+	vmenv := evm.NewEVM(blockContext, evm2.NewEVMTxCcntmext(msg), statedb, chainConfig, deadline.config(vmConfig))
+	result, err := evm2.ApplyMessage(vmenv, msg, utils.GovernanceCcntmractAddress)
+	if err != nil {
+		return nil, err
+	}
+	return result, deadline.check()
+}
+
+//TraceTransaction replays the EIP155 transaction with the given vm config, on the state it was executed with.
+//The transactions of the archived blocks, or else of the last STATE_HISTORY_SIZE blocks saved since the node
+//started, can be replayed. The replay runs on a snapshot of the state, without blocking the blocks being saved, and is cancelled once
+//timeout is passed.
+func (this *LedgerStoreImp) TraceTransaction(txHash common.Uint256, vmConfig evm.Config,
+	timeout time.Duration) (*types5.ExecutionResult, error) {
+	deadline := newTraceDeadline(timeout)
+	tx, height, err := this.GetTransaction(txHash)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.GetEIP155Tx(); err != nil {
+		return nil, fmt.Errorf("transaction %s is not an evm transaction", txHash.ToHexString())
+	}
+	block, err := this.GetBlockByHeight(height)
+	if err != nil {
+		return nil, err
+	}
+
+	overlay, release, err := this.stateBefore(height)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	// the global params refreshed by executeBlock are not rolled back, the current gas table is used
+	gasTable := make(map[string]uint64)
+	cntmvm.GAS_TABLE.Range(func(k, value interface{}) bool {
+		gasTable[k.(string)] = value.(uint64)
+		return true
+	})
+	cache := storage.NewCacheDB(overlay)
+	for _, t := range block.Transactions {
+		if err := deadline.check(); err != nil {
+			return nil, err
+		}
+		cache.Reset()
+		if t.Hash() == txHash {
+			result, _, err := applyEip155Tx(this, cache, block, t, deadline.config(vmConfig))
+			if err != nil {
+				return nil, err
+			}
+			return result, deadline.check()
+		}
+		if _, _, err = this.handleTransaction(overlay, cache, gasTable, block, t); err != nil {
+			return nil, fmt.Errorf("replay tx %s error %s", t.Hash().ToHexString(), err)
+		}
+	}
+	return nil, fmt.Errorf("transaction %s not found in block %d", txHash.ToHexString(), height)
+}
+
+//stateBefore returns an overlay holding the state before the block at height was saved. The state is read from
+//the archive if the previous block is archived. Otherwise the state history is applied on a snapshot of the state
+//store under the saving block lock, the overlay does not change with the blocks saved later and is used without
+//the lock. release must be called once the overlay is not used.
+func (this *LedgerStoreImp) stateBefore(height uint32) (overlay *overlaydb.OverlayDB, release func(), err error) {
+	if height > 0 {
+		if overlay, err := this.NewOverlayDBAt(height - 1); err == nil {
+			return overlay, func() {}, nil
+		}
+	}
+	db, ok := this.stateStore.store.(*leveldbstore.LevelDBStore)
+	if !ok {
+		return nil, nil, fmt.Errorf("the state store does not support snapshots")
+	}
+	this.getSavingBlockLock()
+	defer this.releaseSavingBlockLock()
+	snap, err := db.NewSnapshot()
+	if err != nil {
+		return nil, nil, fmt.Errorf("NewSnapshot error %s", err)
+	}
+	overlay, err = this.stateHistory.stateBefore(&snapshotStore{snap: snap}, height, this.GetCurrentBlockHeight())
+	if err != nil {
+		snap.Release()
+		return nil, nil, err
+	}
+	return overlay, snap.Release, nil
+}
+
+//stateAfter returns an overlay holding the state after the block at height was saved, it is read from the archive
+//if the block is archived, or rebuilt from the state history.
+func (this *LedgerStoreImp) stateAfter(height uint32) (overlay *overlaydb.OverlayDB, release func(), err error) {
+	return this.stateBefore(height + 1)
+}
+
+//traceDeadline bounds the time spent on a trace, it traces the evm executions of the trace to cancel them once
+//the deadline is passed. The cancelled executions stop without error, check reports the timeout.
+type traceDeadline struct {
+	tracer   evm.Tracer
+	timeout  time.Duration
+	deadline time.Time
+	steps    uint64
+	expired  bool
+}
+
+func newTraceDeadline(timeout time.Duration) *traceDeadline {
+	return &traceDeadline{timeout: timeout, deadline: time.Now().Add(timeout)}
+}
+
+//config returns the vm config of an execution bounded by the deadline, the tracer of vmConfig is kept
+func (self *traceDeadline) config(vmConfig evm.Config) evm.Config {
+	self.tracer = nil
+	if vmConfig.Debug {
+		self.tracer = vmConfig.Tracer
+	}
+	vmConfig.Debug = true
+	vmConfig.Tracer = self
+	return vmConfig
+}
+
+func (self *traceDeadline) check() error {
+	if !self.expired && time.Now().After(self.deadline) {
+		self.expired = true
+	}
+	if self.expired {
+		return fmt.Errorf("trace timeout after %s", self.timeout)
+	}
+	return nil
+}
+
+func (self *traceDeadline) CaptureStart(from common2.Address, to common2.Address, create bool, input []byte,
+	gas uint64, value *big.Int) {
+	if self.tracer != nil {
+		self.tracer.CaptureStart(from, to, create, input, gas, value)
+	}
+}
+
+func (self *traceDeadline) CaptureState(env *evm.EVM, pc uint64, op evm.OpCode, gas, cost uint64, memory *evm.Memory,
+	stack *evm.Stack, rStack *evm.ReturnStack, rData []byte, ccntmract *evm.Ccntmract, depth int, err error) {
+	self.steps++
+	if self.steps%TRACE_DEADLINE_CHECK_STEPS == 0 && self.check() != nil {
+		env.Cancel()
+	}
+	if self.tracer != nil {
+		self.tracer.CaptureState(env, pc, op, gas, cost, memory, stack, rStack, rData, ccntmract, depth, err)
+	}
+}
+
+func (self *traceDeadline) CaptureFault(env *evm.EVM, pc uint64, op evm.OpCode, gas, cost uint64, memory *evm.Memory,
+	stack *evm.Stack, rStack *evm.ReturnStack, ccntmract *evm.Ccntmract, depth int, err error) {
+	if self.tracer != nil {
+		self.tracer.CaptureFault(env, pc, op, gas, cost, memory, stack, rStack, ccntmract, depth, err)
+	}
+}
+
+func (self *traceDeadline) CaptureEnd(output []byte, gasUsed uint64, t time.Duration, err error) {
+	if self.tracer != nil {
+		self.tracer.CaptureEnd(output, gasUsed, t, err)
+	}
+}
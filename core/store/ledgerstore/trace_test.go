@@ -0,0 +1,191 @@
+/*
+ * Copyright (C) 2018 The cntm Authors
+ * This file is part of The cntm library.
+ *
+ * The cntm is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The cntm is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The cntm.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package ledgerstore
+
+import (
+	"io/ioutil"
+	"math/big"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/conntectome/cntm/common"
+	"github.com/conntectome/cntm/common/config"
+	"github.com/conntectome/cntm/core/store/overlaydb"
+	"github.com/conntectome/cntm/core/types"
+	"github.com/conntectome/cntm/smartcontract/event"
+	evm2 "github.com/conntectome/cntm/smartcontract/service/evm"
+	"github.com/conntectome/cntm/smartcontract/service/native/cntm"
+	"github.com/conntectome/cntm/smartcontract/storage"
+	"github.com/conntectome/cntm/vm/evm"
+	"github.com/conntectome/cntm/vm/evm/params"
+	common2 "github.com/ethereum/go-ethereum/common"
+	types3 "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+//traceTestCode returns the storage slot 0 of the contract
+var traceTestCode = []byte{0x60, 0x00, 0x54, 0x60, 0x00, 0x52, 0x60, 0x20, 0x60, 0x00, 0xf3}
+
+//newTraceTestLedger returns a ledger whose block 0 sets the slot 0 of the contract to 1, and block 1 sets it to 2
+//and has a transaction calling the contract
+func newTraceTestLedger(t *testing.T, contract common2.Address) (*LedgerStoreImp, *types.Transaction, func()) {
+	dir, err := ioutil.TempDir("", "trace")
+	assert.Nil(t, err)
+	blockStore, err := NewBlockStore(dir, false)
+	assert.Nil(t, err)
+	ledger := &LedgerStoreImp{
+		blockStore:           blockStore,
+		stateStore:           NewMemStateStore(0),
+		stateHistory:         newStateHistory(0),
+		headerIndex:          make(map[uint32]common.Uint256),
+		headerCache:          make(map[common.Uint256]*types.Header),
+		savingBlockSemaphore: make(chan bool, 1),
+	}
+
+	saveState := func(height uint32, value byte) {
+		overlay := overlaydb.NewOverlayDB(ledger.stateStore.store)
+		statedb := storage.NewStateDB(storage.NewCacheDB(overlay), common2.Hash{}, common2.Hash{}, cntm.OngBalanceHandle{})
+		statedb.SetCode(contract, traceTestCode)
+		statedb.SetState(contract, common2.Hash{}, common2.Hash{31: value})
+		assert.Nil(t, statedb.Commit())
+		prev, err := ledger.stateStore.getPreviousValues(overlay.GetWriteSet())
+		assert.Nil(t, err)
+		ledger.stateHistory.record(height, prev, overlaydb.NewMemDB(0, 0))
+		overlay.GetWriteSet().ForEach(func(key, val []byte) {
+			assert.Nil(t, ledger.stateStore.store.Put(key, val))
+		})
+		ledger.currBlockHeight = height
+	}
+	saveState(0, 1)
+	saveState(1, 2)
+
+	key, err := crypto.GenerateKey()
+	assert.Nil(t, err)
+	chainId := big.NewInt(int64(config.DefConfig.P2PNode.EVMChainId))
+	ethTx, err := types3.SignTx(types3.NewTransaction(0, contract, big.NewInt(0), 100000, big.NewInt(0), nil),
+		types3.NewEIP155Signer(chainId), key)
+	assert.Nil(t, err)
+	tx, err := types.TransactionFromEIP155(ethTx)
+	assert.Nil(t, err)
+	block := &types.Block{Header: &types.Header{Height: 1, Timestamp: 1}, Transactions: []*types.Transaction{tx}}
+	blockStore.NewBatch()
+	assert.Nil(t, blockStore.SaveBlock(block))
+	assert.Nil(t, blockStore.CommitTo())
+	ledger.setHeaderIndex(1, block.Hash())
+
+	return ledger, tx, func() {
+		blockStore.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+//lockTracer checks a block can be saved while the trace runs
+type lockTracer struct {
+	*evm.StructLogger
+	ledger   *LedgerStoreImp
+	unlocked bool
+}
+
+func (self *lockTracer) CaptureState(env *evm.EVM, pc uint64, op evm.OpCode, gas, cost uint64, memory *evm.Memory,
+	stack *evm.Stack, rStack *evm.ReturnStack, rData []byte, contract *evm.Ccntmract, depth int, err error) {
+	if !self.ledger.tryGetSavingBlockLock() {
+		self.unlocked = true
+		self.ledger.releaseSavingBlockLock()
+	}
+	self.StructLogger.CaptureState(env, pc, op, gas, cost, memory, stack, rStack, rData, contract, depth, err)
+}
+
+func TestTraceTransaction(t *testing.T) {
+	contract := common2.HexToAddress("0x1111111111111111111111111111111111111111")
+	ledger, tx, clean := newTraceTestLedger(t, contract)
+	defer clean()
+
+	//the transaction is replayed on the state before its block, without the saving block lock
+	tracer := &lockTracer{StructLogger: evm.NewStructLogger(nil), ledger: ledger}
+	result, err := ledger.TraceTransaction(tx.Hash(), evm.Config{Debug: true, Tracer: tracer}, time.Second)
+	assert.Nil(t, err)
+	assert.False(t, result.Failed())
+	assert.Equal(t, common2.Hash{31: 1}.Bytes(), result.Return())
+	assert.True(t, tracer.unlocked)
+	assert.NotEmpty(t, tracer.StructLogs())
+
+	_, err = ledger.TraceTransaction(common.Uint256{1}, evm.Config{}, time.Second)
+	assert.NotNil(t, err)
+	_, err = ledger.TraceTransaction(tx.Hash(), evm.Config{}, time.Nanosecond)
+	assert.NotNil(t, err)
+	assert.True(t, strings.Contains(err.Error(), "timeout"))
+}
+
+func TestHandleEip155Transaction(t *testing.T) {
+	contract := common2.HexToAddress("0x1111111111111111111111111111111111111111")
+	ledger, tx, clean := newTraceTestLedger(t, contract)
+	defer clean()
+
+	//the block executes the transaction through the same evm as the trace
+	block, err := ledger.GetBlockByHeight(1)
+	assert.Nil(t, err)
+	overlay := ledger.stateStore.NewOverlayDB()
+	notify, _, err := ledger.handleTransaction(overlay, storage.NewCacheDB(overlay), nil, block, tx)
+	assert.Nil(t, err)
+	assert.Equal(t, event.CCNTMRACT_STATE_SUCCESS, notify.State)
+	assert.Equal(t, tx.Hash(), notify.TxHash)
+
+	ethTx, err := tx.GetEIP155Tx()
+	assert.Nil(t, err)
+	chainId := big.NewInt(int64(config.DefConfig.P2PNode.EVMChainId))
+	sender, err := types3.Sender(types3.NewEIP155Signer(chainId), ethTx)
+	assert.Nil(t, err)
+	statedb := storage.NewStateDB(storage.NewCacheDB(overlay), common2.Hash{}, common2.Hash{}, cntm.OngBalanceHandle{})
+	assert.Equal(t, uint64(1), statedb.GetNonce(sender))
+}
+
+func TestTraceEip155TxAtHeight(t *testing.T) {
+	contract := common2.HexToAddress("0x1111111111111111111111111111111111111111")
+	ledger, _, clean := newTraceTestLedger(t, contract)
+	defer clean()
+
+	msg := types3.NewMessage(common2.Address{}, &contract, 0, big.NewInt(0), 100000, big.NewInt(0), nil, false)
+	for height, value := range []byte{1, 2} {
+		result, err := ledger.TraceEip155Tx(msg, uint32(height), evm.Config{}, time.Second)
+		assert.Nil(t, err)
+		assert.Equal(t, common2.Hash{31: value}.Bytes(), result.Return())
+	}
+	_, err := ledger.TraceEip155Tx(msg, 2, evm.Config{}, time.Second)
+	assert.NotNil(t, err)
+}
+
+func TestTraceDeadline(t *testing.T) {
+	loop := common2.HexToAddress("0x2222222222222222222222222222222222222222")
+	cache := storage.NewCacheDB(overlaydb.NewOverlayDB(NewMemStateStore(0).store))
+	statedb := storage.NewStateDB(cache, common2.Hash{}, common2.Hash{}, cntm.OngBalanceHandle{})
+	//JUMPDEST PUSH1 0 JUMP
+	statedb.SetCode(loop, []byte{0x5b, 0x60, 0x00, 0x56})
+
+	deadline := newTraceDeadline(50 * time.Millisecond)
+	vmenv := evm.NewEVM(evm2.NewEVMBlockCcntmext(1, 1, nil), evm.TxCcntmext{}, statedb,
+		params.GetChainConfig(config.DefConfig.P2PNode.EVMChainId), deadline.config(evm.Config{}))
+	start := time.Now()
+	_, _, _ = vmenv.Call(evm.AccountRef(common2.Address{}), loop, nil, 1<<62, big.NewInt(0))
+	assert.True(t, time.Since(start) < 10*time.Second)
+	assert.True(t, vmenv.Cancelled())
+	assert.NotNil(t, deadline.check())
+}
@@ -24,6 +24,7 @@ import (
 	"math"
 	"strconv"
 
+	common2 "github.com/ethereum/go-ethereum/common"
 	"github.com/conntectome/cntm/common"
 	sysconfig "github.com/conntectome/cntm/common/config"
 	"github.com/conntectome/cntm/common/log"
@@ -35,13 +36,18 @@ import (
 	"github.com/conntectome/cntm/errors"
 	"github.com/conntectome/cntm/smartcontract"
 	"github.com/conntectome/cntm/smartcontract/event"
+	evm2 "github.com/conntectome/cntm/smartcontract/service/evm"
+	types5 "github.com/conntectome/cntm/smartcontract/service/evm/types"
 	"github.com/conntectome/cntm/smartcontract/service/native/global_params"
 	ninit "github.com/conntectome/cntm/smartcontract/service/native/init"
 	"github.com/conntectome/cntm/smartcontract/service/native/cntm"
+	"github.com/conntectome/cntm/smartcontract/service/native/system"
 	"github.com/conntectome/cntm/smartcontract/service/native/utils"
 	"github.com/conntectome/cntm/smartcontract/service/cntmvm"
 	"github.com/conntectome/cntm/smartcontract/service/wasmvm"
 	"github.com/conntectome/cntm/smartcontract/storage"
+	"github.com/conntectome/cntm/vm/evm"
+	"github.com/conntectome/cntm/vm/evm/params"
 )
 
 func tuneGasFeeByHeight(height uint32, gas uint64, gasRound uint64, curBalance uint64) uint64 {
@@ -75,10 +81,13 @@ func (self *StateStore) HandleDeployTransaction(store store.LedgerStore, overlay
 	)
 
 	if deploy.VmType() == payload.WASMVM_TYPE {
-		_, err = wasmvm.ReadWasmModule(deploy.GetRawCode(), sysconfig.DefConfig.Common.WasmVerifyMethod)
+		compiled, err := wasmvm.ReadWasmModule(deploy.GetRawCode(), sysconfig.DefConfig.Common.WasmVerifyMethod)
 		if err != nil {
 			return err
 		}
+		if err := wasmvm.CheckHostImports(compiled.RawModule, block.Header.Height); err != nil {
+			return err
+		}
 	}
 
 	if tx.GasPrice != 0 {
@@ -281,6 +290,59 @@ func (self *StateStore) HandleInvokeTransaction(store store.LedgerStore, overlay
 	return sc.CrossHashes, nil
 }
 
+//HandleEIP155Transaction executes the EIP155 transaction, the evm ccntmracts call the native ccntmracts through the
+//native gateway
+func (self *StateStore) HandleEIP155Transaction(store store.LedgerStore, cache *storage.CacheDB, tx *types.Transaction,
+	block *types.Block, notify *event.ExecuteNotify) error {
+	_, receipt, err := applyEip155Tx(store, cache, block, tx, evm.Config{})
+	if err != nil {
+		return err
+	}
+	*notify = *event.ExecuteNotifyFromEthReceipt(receipt)
+	cache.Commit()
+	return nil
+}
+
+//applyEip155Tx executes the EIP155 transaction of block on cache, and commits the state changes to the cache backend
+func applyEip155Tx(store store.LedgerStore, cache *storage.CacheDB, block *types.Block, tx *types.Transaction,
+	vmConfig evm.Config) (*types5.ExecutionResult, *types.Receipt, error) {
+	eip155Tx, err := tx.GetEIP155Tx()
+	if err != nil {
+		return nil, nil, err
+	}
+	invokeNative, err := newNativeInvoker(store, cache, block.Header.Height, block.Header.Timestamp, block.Hash(), tx)
+	if err != nil {
+		return nil, nil, err
+	}
+	statedb := storage.NewStateDB(cache, common2.Hash(tx.Hash()), common2.Hash(block.Hash()), cntm.OngBalanceHandle{})
+	chainConfig := params.GetChainConfig(sysconfig.DefConfig.P2PNode.EVMChainId)
+	usedGas := uint64(0)
+	return evm2.ApplyTransaction(chainConfig, store, statedb, block.Header.Height, block.Header.Timestamp,
+		eip155Tx, &usedGas, utils.GovernanceCcntmractAddress, vmConfig, true, invokeNative)
+}
+
+//newNativeInvoker returns the native gateway of the evm executed in the block at height, the native ccntmracts
+//called through the gateway are metered by the evm gas
+func newNativeInvoker(store store.LedgerStore, cache *storage.CacheDB, height, blockTime uint32,
+	blockHash common.Uint256, tx *types.Transaction) (evm.InvokeNativeFunc, error) {
+	sc := smartcontract.SmartCcntmract{
+		Config: &smartcontract.Config{
+			Time:      blockTime,
+			Height:    height,
+			BlockHash: blockHash,
+			Tx:        tx,
+		},
+		CacheDB: cache,
+		Store:   store,
+		Gas:     math.MaxUint64,
+	}
+	service, err := sc.NewNativeService()
+	if err != nil {
+		return nil, err
+	}
+	return system.NewNativeInvoker(service), nil
+}
+
 func SaveNotify(eventStore scommon.EventStore, txHash common.Uint256, notify *event.ExecuteNotify) error {
 	if !sysconfig.DefConfig.Common.EnableEventLog {
 		return nil
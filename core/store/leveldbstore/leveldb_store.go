@@ -175,3 +175,39 @@ func (self *LevelDBStore) NewIterator(prefix []byte) common.StoreIterator {
 
 	return iter
 }
+
+//LevelDBSnapshot is a read only view of the leveldb at the time the snapshot was taken
+type LevelDBSnapshot struct {
+	snap *leveldb.Snapshot
+}
+
+//NewSnapshot return a snapshot of the current state of the leveldb, it must be released after use
+func (self *LevelDBStore) NewSnapshot() (*LevelDBSnapshot, error) {
+	snap, err := self.db.GetSnapshot()
+	if err != nil {
+		return nil, err
+	}
+	return &LevelDBSnapshot{snap: snap}, nil
+}
+
+//Get the value of a key from the snapshot
+func (self *LevelDBSnapshot) Get(key []byte) ([]byte, error) {
+	dat, err := self.snap.Get(key, nil)
+	if err != nil {
+		if err == leveldb.ErrNotFound {
+			return nil, common.ErrNotFound
+		}
+		return nil, err
+	}
+	return dat, nil
+}
+
+//NewIterator return a iterator of the snapshot with the key prefix
+func (self *LevelDBSnapshot) NewIterator(prefix []byte) common.StoreIterator {
+	return self.snap.NewIterator(util.BytesPrefix(prefix), nil)
+}
+
+//Release the snapshot
+func (self *LevelDBSnapshot) Release() {
+	self.snap.Release()
+}
@@ -140,3 +140,44 @@ func TestIterator(t *testing.T) {
 	}
 
 }
+
+func TestSnapshot(t *testing.T) {
+	key := []byte("snap")
+	err := testLevelDB.Put(key, []byte("v1"))
+	if err != nil {
+		t.Errorf("Put error:%s", err)
+		return
+	}
+	snap, err := testLevelDB.NewSnapshot()
+	if err != nil {
+		t.Errorf("NewSnapshot error:%s", err)
+		return
+	}
+	defer snap.Release()
+
+	err = testLevelDB.Put(key, []byte("v2"))
+	if err != nil {
+		t.Errorf("Put error:%s", err)
+		return
+	}
+	err = testLevelDB.Put([]byte("snap1"), []byte("v2"))
+	if err != nil {
+		t.Errorf("Put error:%s", err)
+		return
+	}
+
+	v, err := snap.Get(key)
+	if err != nil || string(v) != "v1" {
+		t.Errorf("TestSnapshot Get value:%s error:%v", v, err)
+		return
+	}
+	count := 0
+	iter := snap.NewIterator(key)
+	for iter.Next() {
+		count++
+	}
+	iter.Release()
+	if count != 1 {
+		t.Errorf("TestSnapshot iterator count:%d != 1", count)
+	}
+}
@@ -0,0 +1,57 @@
+/*
+ * Copyright (C) 2018 The cntm Authors
+ * This file is part of The cntm library.
+ *
+ * The cntm is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The cntm is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The cntm.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package store
+
+import (
+	"io"
+
+	"github.com/conntectome/cntm/common"
+)
+
+//SnapshotInfo identifies a snapshot of the state after the block at Height
+type SnapshotInfo struct {
+	Height    uint32
+	BlockHash common.Uint256
+	StateRoot common.Uint256 //State merkle root after the block
+}
+
+func (this *SnapshotInfo) Serialization(sink *common.ZeroCopySink) {
+	sink.WriteUint32(this.Height)
+	sink.WriteHash(this.BlockHash)
+	sink.WriteHash(this.StateRoot)
+}
+
+func (this *SnapshotInfo) Deserialization(source *common.ZeroCopySource) error {
+	var eof bool
+	this.Height, eof = source.NextUint32()
+	this.BlockHash, eof = source.NextHash()
+	this.StateRoot, eof = source.NextHash()
+	if eof {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+
+//SnapshotFile is a snapshot saved to a file
+type SnapshotFile struct {
+	SnapshotInfo
+	Path   string
+	Size   uint64
+	Digest common.Uint256 //sha256 of the file content before the digest
+}
@@ -19,6 +19,10 @@
 package store
 
 import (
+	"io"
+	"time"
+
+	types2 "github.com/ethereum/go-ethereum/core/types"
 	"github.com/conntectome/cntm-crypto/keypair"
 	"github.com/conntectome/cntm/common"
 	"github.com/conntectome/cntm/core/payload"
@@ -26,7 +30,9 @@ import (
 	"github.com/conntectome/cntm/core/store/overlaydb"
 	"github.com/conntectome/cntm/core/types"
 	"github.com/conntectome/cntm/smartcontract/event"
+	types3 "github.com/conntectome/cntm/smartcontract/service/evm/types"
 	cstates "github.com/conntectome/cntm/smartcontract/states"
+	"github.com/conntectome/cntm/vm/evm"
 )
 
 type ExecuteResult struct {
@@ -36,6 +42,10 @@ type ExecuteResult struct {
 	CrossStates     []common.Uint256
 	CrossStatesRoot common.Uint256
 	Notify          []*event.ExecuteNotify
+	ChangeHash      common.Uint256            //Change hash of the write set, zero before the state trie is enabled
+	StateTrieRoot   common.Uint256            //State trie root after the block, zero before the state trie is enabled
+	StateTrieNodes  map[common.Uint256][]byte //New nodes of the state trie
+	StaleTrieNodes  []common.Uint256          //Nodes of the state trie replaced by the block
 }
 
 // LedgerStore provides func with store package.
@@ -69,9 +79,34 @@ type LedgerStore interface {
 	PreExecuteContractBatch(txes []*types.Transaction, atomic bool) ([]*cstates.PreExecResult, uint32, error)
 	GetEventNotifyByTx(tx common.Uint256) (*event.ExecuteNotify, error)
 	GetEventNotifyByBlock(height uint32) ([]*event.ExecuteNotify, error)
+	TraceEip155Tx(msg types2.Message, height uint32, vmConfig evm.Config, timeout time.Duration) (*types3.ExecutionResult, error)
+	TraceTransaction(txHash common.Uint256, vmConfig evm.Config, timeout time.Duration) (*types3.ExecutionResult, error)
+
+	//historical state, needs the archive mode
+	ArchiveStartHeight() (uint32, error)
+	NewOverlayDBAt(height uint32) (*overlaydb.OverlayDB, error)
+	GetStorageItemAt(key *states.StorageKey, height uint32) (*states.StorageItem, error)
+	GetContractStateAt(contractHash common.Address, height uint32) (*payload.DeployCode, error)
+	PreExecuteContractBatchAt(txes []*types.Transaction, height uint32) ([]*cstates.PreExecResult, error)
+
+	//state snapshots
+	ExportStateSnapshot(w io.Writer) (*SnapshotInfo, common.Uint256, error)
+	ImportStateSnapshot(path string, trusted bool) (*SnapshotInfo, error)
+	LatestStateSnapshot() (*SnapshotFile, error)
+	RevertToHeight(height uint32) error
+
+	//logs bloom index
+	GetBlockBloom(height uint32) (types2.Bloom, error)
+	GetBloomBits(bit uint, section uint32) ([]byte, error)
+	BloomStatus() (uint32, uint32)
 
 	//cross chain states root
 	GetCrossStatesRoot(height uint32) (common.Uint256, error)
 	GetCrossChainMsg(height uint32) (*types.CrossChainMsg, error)
 	GetCrossStatesProof(height uint32, key []byte) ([]byte, error)
+
+	//state trie proofs
+	GetStateTrieHeight() uint32
+	GetStateTrieRoot(height uint32) (common.Uint256, error)
+	GetStorageProof(key []byte, height uint32) (*types.StorageProof, error)
 }
@@ -0,0 +1,130 @@
+/*
+ * Copyright (C) 2018 The cntm Authors
+ * This file is part of The cntm library.
+ *
+ * The cntm is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The cntm is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The cntm.  If not, see <http://www.gnu.org/licenses/>.
+ */
+package types
+
+import (
+	"crypto/sha256"
+	"fmt"
+
+	"github.com/conntectome/cntm/common"
+	"github.com/conntectome/cntm/merkle"
+)
+
+//StateTransitionHash returns the state transition hash of a block once the state trie is enabled, it commits
+//to the change hash of the block and the state trie root after the block
+func StateTransitionHash(changeHash, trieRoot common.Uint256) common.Uint256 {
+	data := make([]byte, 0, 2*common.UINT256_SIZE)
+	data = append(data, changeHash[:]...)
+	data = append(data, trieRoot[:]...)
+	return sha256.Sum256(data)
+}
+
+//StorageProof proves the value of a state key after a block, or that the key does not exist. The key is
+//proved against the state trie root, the state transition hash of the block commits to the root, and the
+//hash is proved to be the last leaf of the state merkle tree after the block.
+//The state merkle root is agreed by the consensus but is not carried by the block header, so the verifier
+//must get it from a source it trusts.
+type StorageProof struct {
+	Height     uint32
+	BlockHash  common.Uint256
+	Key        []byte
+	Value      []byte //Empty if the key does not exist
+	TrieProof  merkle.SparseMerkleProof
+	TrieRoot   common.Uint256
+	ChangeHash common.Uint256
+	StateProof []common.Uint256 //Audit path of the state transition hash in the state merkle tree
+	TreeSize   uint32           //Size of the state merkle tree after the block
+}
+
+//Verify checks the proof against the header of the block and the state merkle root after the block
+func (this *StorageProof) Verify(header *Header, stateRoot common.Uint256) error {
+	if header.Height != this.Height || header.Hash() != this.BlockHash {
+		return fmt.Errorf("the proof is not of block %d", header.Height)
+	}
+	if err := this.TrieProof.Verify(this.TrieRoot, this.Key, this.Value); err != nil {
+		return fmt.Errorf("verify trie proof error %s", err)
+	}
+	if this.TreeSize == 0 {
+		return fmt.Errorf("empty state merkle tree")
+	}
+	leaf := StateTransitionHash(this.ChangeHash, this.TrieRoot)
+	err := merkle.NewMerkleVerifier().VerifyLeafHashInclusion(leaf, this.TreeSize-1, this.StateProof, stateRoot,
+		this.TreeSize)
+	if err != nil {
+		return fmt.Errorf("verify state proof error %s", err)
+	}
+	return nil
+}
+
+func (this *StorageProof) Serialization(sink *common.ZeroCopySink) {
+	sink.WriteUint32(this.Height)
+	sink.WriteHash(this.BlockHash)
+	sink.WriteVarBytes(this.Key)
+	sink.WriteVarBytes(this.Value)
+	this.TrieProof.Serialization(sink)
+	sink.WriteHash(this.TrieRoot)
+	sink.WriteHash(this.ChangeHash)
+	sink.WriteVarUint(uint64(len(this.StateProof)))
+	for _, hash := range this.StateProof {
+		sink.WriteHash(hash)
+	}
+	sink.WriteUint32(this.TreeSize)
+}
+
+func (this *StorageProof) Deserialization(source *common.ZeroCopySource) error {
+	var eof, irr bool
+	this.Height, eof = source.NextUint32()
+	this.BlockHash, eof = source.NextHash()
+	if eof {
+		return fmt.Errorf("StorageProof, deserialization read block error")
+	}
+	this.Key, _, irr, eof = source.NextVarBytes()
+	if irr || eof {
+		return fmt.Errorf("StorageProof, deserialization read key error")
+	}
+	this.Value, _, irr, eof = source.NextVarBytes()
+	if irr || eof {
+		return fmt.Errorf("StorageProof, deserialization read value error")
+	}
+	if err := this.TrieProof.Deserialization(source); err != nil {
+		return fmt.Errorf("StorageProof, deserialization read trie proof error %s", err)
+	}
+	this.TrieRoot, eof = source.NextHash()
+	this.ChangeHash, eof = source.NextHash()
+	if eof {
+		return fmt.Errorf("StorageProof, deserialization read trie root error")
+	}
+	n, _, irr, eof := source.NextVarUint()
+	if irr || eof || n > source.Len()/common.UINT256_SIZE {
+		return fmt.Errorf("StorageProof, deserialization read state proof length error")
+	}
+	stateProof := make([]common.Uint256, 0, n)
+	for i := uint64(0); i < n; i++ {
+		hash, eof := source.NextHash()
+		if eof {
+			return fmt.Errorf("StorageProof, deserialization read state proof error")
+		}
+		stateProof = append(stateProof, hash)
+	}
+	this.StateProof = stateProof
+	this.TreeSize, eof = source.NextUint32()
+	if eof {
+		return fmt.Errorf("StorageProof, deserialization read tree size error")
+	}
+	return nil
+}
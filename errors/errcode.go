@@ -52,6 +52,8 @@ const (
 	ErrNetVerifyFail        ErrCode = 45019
 	ErrGasPrice             ErrCode = 45020
 	ErrVerifySignature      ErrCode = 45021
+	ErrReplaceUnderpriced   ErrCode = 45022
+	ErrPayerLimit           ErrCode = 45023
 )
 
 func (err ErrCode) Error() string {
@@ -100,6 +102,10 @@ func (err ErrCode) Error() string {
 		return "invalid gas price"
 	case ErrVerifySignature:
 		return "transaction verify signature fail"
+	case ErrReplaceUnderpriced:
+		return "replacement transaction underpriced"
+	case ErrPayerLimit:
+		return "payer transaction limit reached in tx pool"
 
 	}
 
@@ -19,10 +19,23 @@
 package actor
 
 import (
+	"errors"
+	"fmt"
+	"time"
+
 	"github.com/cntmio/cntmology-eventbus/actor"
+	"github.com/cntmio/cntmology/common/config"
 	cactor "github.com/cntmio/cntmology/consensus/actor"
 )
 
+//DEV_REQ_TIMEOUT is the timeout in seconds of the dev chain requests, mining may take a while
+const DEV_REQ_TIMEOUT = 60
+
+//consensusStatusEngines are the consensus engines replying to GetConsensusStatus, the others drop the request
+var consensusStatusEngines = map[string]bool{
+	"vbft": true,
+}
+
 var consensusSrvPid *actor.PID
 
 func SetConsensusPid(actr *actor.PID) {
@@ -44,3 +57,78 @@ func ConsensusSrvHalt() error {
 	}
 	return nil
 }
+
+//devRequest sends a dev chain request to the solo consensus actor and returns the value of the response
+func devRequest(msg interface{}) (uint64, error) {
+	if config.DefConfig.Genesis.SOLO == nil || !config.DefConfig.Genesis.SOLO.DevMode {
+		return 0, errors.New("dev mode is not enabled, start the node with --testmode --testmode-dev")
+	}
+	if consensusSrvPid == nil {
+		return 0, errors.New("consensus is not started")
+	}
+	future := consensusSrvPid.RequestFuture(msg, DEV_REQ_TIMEOUT*time.Second)
+	result, err := future.Result()
+	if err != nil {
+		return 0, fmt.Errorf(ERR_ACTOR_COMM, err)
+	}
+	rsp, ok := result.(*cactor.DevRsp)
+	if !ok {
+		return 0, fmt.Errorf("unexpected dev response %T", result)
+	}
+	return rsp.Value, rsp.Error
+}
+
+//DevMine mines blocks on the dev chain, the first one at timestamp if it is not 0, and returns the height of the
+//last block
+func DevMine(blocks, timestamp uint32) (uint32, error) {
+	height, err := devRequest(&cactor.DevMine{Blocks: blocks, Timestamp: timestamp})
+	return uint32(height), err
+}
+
+//DevIncreaseTime shifts the time of the next blocks of the dev chain, and returns the total shift in seconds
+func DevIncreaseTime(seconds uint32) (int64, error) {
+	offset, err := devRequest(&cactor.DevIncreaseTime{Seconds: seconds})
+	return int64(offset), err
+}
+
+//DevSetNextBlockTimestamp sets the timestamp of the next block of the dev chain
+func DevSetNextBlockTimestamp(timestamp uint32) error {
+	_, err := devRequest(&cactor.DevSetNextBlockTimestamp{Timestamp: timestamp})
+	return err
+}
+
+//DevSnapshot saves the dev chain at the current block and returns the snapshot id
+func DevSnapshot() (uint32, error) {
+	id, err := devRequest(&cactor.DevSnapshot{})
+	return uint32(id), err
+}
+
+//DevRevert reverts the dev chain to the snapshot, and returns the height it is reverted to
+func DevRevert(id uint32) (uint32, error) {
+	height, err := devRequest(&cactor.DevRevert{SnapshotId: id})
+	return uint32(height), err
+}
+
+//GetConsensusStatus returns the live status of the consensus
+func GetConsensusStatus() (*cactor.ConsensusStatus, error) {
+	engine := config.DefConfig.Genesis.ConsensusType
+	if engine == "" {
+		engine = config.CONSENSUS_TYPE_DBFT
+	}
+	if !consensusStatusEngines[engine] {
+		return nil, fmt.Errorf("consensus status is not supported by %s", engine)
+	}
+	if consensusSrvPid == nil {
+		return nil, errors.New("consensus is not started")
+	}
+	future := consensusSrvPid.RequestFuture(&cactor.GetConsensusStatus{}, REQ_TIMEOUT*time.Second)
+	result, err := future.Result()
+	if err != nil {
+		return nil, fmt.Errorf(ERR_ACTOR_COMM, err)
+	}
+	status, ok := result.(*cactor.ConsensusStatus)
+	if !ok {
+		return nil, fmt.Errorf("unexpected consensus status response %T", result)
+	}
+	return status, nil
+}
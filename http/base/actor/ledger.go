@@ -19,6 +19,8 @@
 package actor
 
 import (
+	"time"
+
 	common2 "github.com/ethereum/go-ethereum/common"
 	types2 "github.com/ethereum/go-ethereum/core/types"
 	"github.com/cntmio/cntmology/common"
@@ -29,6 +31,7 @@ import (
 	types3 "github.com/cntmio/cntmology/smartccntmract/service/evm/types"
 	cstate "github.com/cntmio/cntmology/smartccntmract/states"
 	"github.com/cntmio/cntmology/smartccntmract/storage"
+	"github.com/cntmio/cntmology/vm/evm"
 )
 
 const (
@@ -77,6 +80,11 @@ func GetStorageItem(address common.Address, key []byte) ([]byte, error) {
 	return ledger.DefLedger.GetStorageItem(address, key)
 }
 
+//GetStorageItemAt return the storage item after the block at height, needs the archive mode
+func GetStorageItemAt(address common.Address, key []byte, height uint32) ([]byte, error) {
+	return ledger.DefLedger.GetStorageItemAt(address, key, height)
+}
+
 //GetCcntmractStateFromStore from ledger
 func GetCcntmractStateFromStore(hash common.Address) (*payload.DeployCode, error) {
 	hash = updateNativeSCAddr(hash)
@@ -98,6 +106,11 @@ func PreExecuteCcntmractBatch(tx []*types.Transaction, atomic bool) ([]*cstate.P
 	return ledger.DefLedger.PreExecuteCcntmractBatch(tx, atomic)
 }
 
+//PreExecuteCcntmractBatchAt pre-execute the txes on the state after the block at height, needs the archive mode
+func PreExecuteCcntmractBatchAt(tx []*types.Transaction, height uint32) ([]*cstate.PreExecResult, error) {
+	return ledger.DefLedger.PreExecuteContractBatchAt(tx, height)
+}
+
 //GetEventNotifyByTxHash from ledger
 func GetEventNotifyByTxHash(txHash common.Uint256) (*event.ExecuteNotify, error) {
 	return ledger.DefLedger.GetEventNotifyByTx(txHash)
@@ -108,6 +121,21 @@ func GetEventNotifyByHeight(height uint32) ([]*event.ExecuteNotify, error) {
 	return ledger.DefLedger.GetEventNotifyByBlock(height)
 }
 
+//GetBlockBloom return the logs bloom of the block at height
+func GetBlockBloom(height uint32) (types2.Bloom, error) {
+	return ledger.DefLedger.GetBlockBloom(height)
+}
+
+//GetBloomBits return the bits of one bloom bit index for the blocks of a section
+func GetBloomBits(bit uint, section uint32) ([]byte, error) {
+	return ledger.DefLedger.GetBloomBits(bit, section)
+}
+
+//BloomStatus return the section size of the bloom bits index, and the number of indexed sections
+func BloomStatus() (uint32, uint32) {
+	return ledger.DefLedger.BloomStatus()
+}
+
 //GetMerkleProof from ledger
 func GetMerkleProof(proofHeight uint32, rootHeight uint32) ([]common.Uint256, error) {
 	return ledger.DefLedger.GetMerkleProof(proofHeight, rootHeight)
@@ -137,7 +165,41 @@ func GetEthStorage(addr common2.Address, key common2.Hash) ([]byte, error) {
 	return ledger.DefLedger.GetEthState(addr, key)
 }
 
+func GetEthAccountAt(address common2.Address, height uint32) (*storage.EthAccount, error) {
+	return ledger.DefLedger.GetEthAccountAt(address, height)
+}
+
+func GetEthStorageAt(addr common2.Address, key common2.Hash, height uint32) ([]byte, error) {
+	return ledger.DefLedger.GetEthStateAt(addr, key, height)
+}
+
+//GetStorageProof return the proof of the storage item after the block at height
+func GetStorageProof(address common.Address, key []byte, height uint32) (*types.StorageProof, error) {
+	return ledger.DefLedger.GetStorageProof(address, key, height)
+}
+
+func GetEthAccountProof(address common2.Address, height uint32) (*types.StorageProof, error) {
+	return ledger.DefLedger.GetEthAccountProof(address, height)
+}
+
+func GetEthStorageProof(addr common2.Address, key common2.Hash, height uint32) (*types.StorageProof, error) {
+	return ledger.DefLedger.GetEthStateProof(addr, key, height)
+}
+
+//GetStateMerkleRoot return the state merkle root after the block at height
+func GetStateMerkleRoot(height uint32) (common.Uint256, error) {
+	return ledger.DefLedger.GetStateMerkleRoot(height)
+}
+
 func PreExecuteEip155Tx(msg types2.Message) (*types3.ExecutionResult, error) {
 	res, err := ledger.DefLedger.PreExecuteEip155Tx(msg)
 	return res, err
 }
+
+func TraceEip155Tx(msg types2.Message, height uint32, vmConfig evm.Config, timeout time.Duration) (*types3.ExecutionResult, error) {
+	return ledger.DefLedger.TraceEip155Tx(msg, height, vmConfig, timeout)
+}
+
+func TraceTransaction(txHash common.Uint256, vmConfig evm.Config, timeout time.Duration) (*types3.ExecutionResult, error) {
+	return ledger.DefLedger.TraceTransaction(txHash, vmConfig, timeout)
+}
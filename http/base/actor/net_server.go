@@ -93,3 +93,11 @@ func GetNodeType() uint64 {
 	}
 	return netServer.GetHostInfo().Services
 }
+
+//GetTrafficStats from netSever actor
+func GetTrafficStats() common.TrafficStats {
+	if netServer == nil {
+		return common.TrafficStats{}
+	}
+	return netServer.GetTrafficStats()
+}
@@ -76,3 +76,8 @@ func GetTxnCount() []uint32 {
 func GetTxnHashList() []common.Uint256 {
 	return txPoolService.GetTxList()
 }
+
+//GetTxsByPayerFromPool returns the transactions of the payer in the txpool
+func GetTxsByPayerFromPool(payer common.Address) []*tcomn.TXEntry {
+	return txPoolService.GetTxsByPayer(payer)
+}
@@ -46,6 +46,7 @@ import (
 	"github.com/cntmio/cntmology/smartccntmract/service/native/cntm"
 	"github.com/cntmio/cntmology/smartccntmract/service/native/utils"
 	cstate "github.com/cntmio/cntmology/smartccntmract/states"
+	tcomn "github.com/cntmio/cntmology/txnpool/common"
 	"github.com/cntmio/cntmology/vm/neovm"
 )
 
@@ -133,6 +134,19 @@ type CrossStatesProof struct {
 	AuditPath string
 }
 
+//StorageProof is the proof of a state key after a block, Proof is the serialized types.StorageProof which is
+//verified against the header of the block and StateRoot
+type StorageProof struct {
+	Height     uint32
+	BlockHash  string
+	Key        string
+	Value      string
+	TrieRoot   string
+	ChangeHash string
+	StateRoot  string
+	Proof      string
+}
+
 type CrossStatesLeafHashes struct {
 	Height uint32
 	Hashes []string
@@ -180,11 +194,12 @@ type NodeInfo struct {
 	NodePort    uint16         // The nodes's port
 	ID          common2.PeerId // The nodes's id
 	NodeTime    int64
-	NodeVersion uint32   // The network protocol the node used
-	NodeType    uint64   // The services the node supplied
-	Relay       bool     // The relay capability of the node (merge into capbility flag)
-	Height      uint32   // The node latest block height
-	TxnCnt      []uint32 // The transactions in pool
+	NodeVersion uint32               // The network protocol the node used
+	NodeType    uint64               // The services the node supplied
+	Relay       bool                 // The relay capability of the node (merge into capbility flag)
+	Height      uint32               // The node latest block height
+	TxnCnt      []uint32             // The transactions in pool
+	Traffic     common2.TrafficStats // The bytes and messages exchanged with peers
 	//RxTxnCnt uint64 // The transaction received by this node
 }
 
@@ -202,6 +217,17 @@ type TXNEntryInfo struct {
 	State []TXNAttrInfo // the result from each validator
 }
 
+//PayerTxInfo is a transaction of a payer in the txpool, ReplaceGasPrice is the min gas price of a
+//transaction with the same payer and nonce to replace it
+type PayerTxInfo struct {
+	TxHash          string
+	Nonce           uint32
+	GasPrice        uint64
+	GasLimit        uint64
+	Height          uint32 //Block height when the transaction entered the txpool
+	ReplaceGasPrice uint64
+}
+
 func GetLogEvent(obj *event.LogEventArgs) (map[string]bool, LogEventArgs) {
 	hash := obj.TxHash
 	addr := obj.CcntmractAddress.ToHexString()
@@ -349,6 +375,71 @@ func GetBalanceV2(address common.Address) (*BalanceOfRsp, error) {
 	}, nil
 }
 
+//GetBalanceAt return the balances after the block at height, needs the archive mode
+func GetBalanceAt(address common.Address, height uint32) (*BalanceOfRsp, error) {
+	balances, err := GetNativeTokenBalanceAt(0, []common.Address{utils.OntCcntmractAddress, utils.OngCcntmractAddress}, address, height)
+	if err != nil {
+		return nil, fmt.Errorf("get cntm balance error:%s", err)
+	}
+	return &BalanceOfRsp{
+		Ont:    fmt.Sprintf("%d", balances[0].MustToInteger64()),
+		Ong:    fmt.Sprintf("%d", balances[1].MustToInteger64()),
+		Height: fmt.Sprintf("%d", height),
+	}, nil
+}
+
+//GetBalanceV2At return the balances after the block at height, needs the archive mode
+func GetBalanceV2At(address common.Address, height uint32) (*BalanceOfRsp, error) {
+	balances, err := GetNativeTokenBalanceAt(0, []common.Address{utils.OntCcntmractAddress, utils.OngCcntmractAddress}, address, height)
+	if err != nil {
+		return nil, fmt.Errorf("get cntm balance error:%s", err)
+	}
+	return &BalanceOfRsp{
+		Ont:    balances[0].String(),
+		Ong:    balances[1].String(),
+		Height: fmt.Sprintf("%d", height),
+	}, nil
+}
+
+//GetStorageProof return the proof of the storage value of the key in smart contract after the block at height
+func GetStorageProof(address common.Address, key []byte, height uint32) (*StorageProof, error) {
+	proof, err := bactor.GetStorageProof(address, key, height)
+	if err != nil {
+		return nil, err
+	}
+	stateRoot, err := bactor.GetStateMerkleRoot(height)
+	if err != nil {
+		return nil, fmt.Errorf("get state merkle root error:%s", err)
+	}
+	return &StorageProof{
+		Height:     proof.Height,
+		BlockHash:  proof.BlockHash.ToHexString(),
+		Key:        common.ToHexString(proof.Key),
+		Value:      common.ToHexString(proof.Value),
+		TrieRoot:   proof.TrieRoot.ToHexString(),
+		ChangeHash: proof.ChangeHash.ToHexString(),
+		StateRoot:  stateRoot.ToHexString(),
+		Proof:      common.ToHexString(common.SerializeToBytes(proof)),
+	}, nil
+}
+
+//GetTxsByPayer returns the transactions of the payer in the txpool ordered by nonce
+func GetTxsByPayer(payer common.Address) []PayerTxInfo {
+	txEntries := bactor.GetTxsByPayerFromPool(payer)
+	txs := make([]PayerTxInfo, 0, len(txEntries))
+	for _, txEntry := range txEntries {
+		txs = append(txs, PayerTxInfo{
+			TxHash:          txEntry.Tx.Hash().ToHexString(),
+			Nonce:           txEntry.Tx.Nonce,
+			GasPrice:        txEntry.Tx.GasPrice,
+			GasLimit:        txEntry.Tx.GasLimit,
+			Height:          txEntry.Height,
+			ReplaceGasPrice: tcomn.ReplacementGasPrice(txEntry.Tx.GasPrice),
+		})
+	}
+	return txs
+}
+
 func GetOep4Balance(ccntmractAddress common.Address, addrs []common.Address) (*Oep4BalanceOfRsp, error) {
 	balances, height, err := GetOep4CcntmractBalance(ccntmractAddress, addrs, true)
 	if err != nil {
@@ -422,40 +513,68 @@ func GetAllowanceV2(asset string, from, to common.Address) (string, error) {
 
 func GetNativeTokenBalance(cVersion byte, ccntmractAddres []common.Address, accAddr common.Address, atomic bool) (
 	[]states.NativeTokenBalance, uint32, error) {
+	txes, err := newBalanceOfTxes(cVersion, ccntmractAddres, accAddr)
+	if err != nil {
+		return nil, 0, err
+	}
+	results, height, err := bactor.PreExecuteCcntmractBatch(txes, atomic)
+	if err != nil {
+		return nil, 0, fmt.Errorf("PrepareInvokeCcntmract error:%s", err)
+	}
+	balances, err := parseNativeTokenBalances(results)
+	if err != nil {
+		return nil, 0, err
+	}
+	return balances, height, nil
+}
+
+//GetNativeTokenBalanceAt return the native token balances after the block at height, needs the archive mode
+func GetNativeTokenBalanceAt(cVersion byte, ccntmractAddres []common.Address, accAddr common.Address, height uint32) (
+	[]states.NativeTokenBalance, error) {
+	txes, err := newBalanceOfTxes(cVersion, ccntmractAddres, accAddr)
+	if err != nil {
+		return nil, err
+	}
+	results, err := bactor.PreExecuteCcntmractBatchAt(txes, height)
+	if err != nil {
+		return nil, fmt.Errorf("PrepareInvokeCcntmract error:%s", err)
+	}
+	return parseNativeTokenBalances(results)
+}
+
+func newBalanceOfTxes(cVersion byte, ccntmractAddres []common.Address, accAddr common.Address) ([]*types.Transaction, error) {
 	txes := make([]*types.Transaction, 0, len(ccntmractAddres))
 	for _, ccntmractAddr := range ccntmractAddres {
 		mutable, err := NewNativeInvokeTransaction(0, 0, ccntmractAddr, cVersion, "balanceOfV2", []interface{}{accAddr[:]})
 		if err != nil {
-			return nil, 0, fmt.Errorf("NewNativeInvokeTransaction error:%s", err)
+			return nil, fmt.Errorf("NewNativeInvokeTransaction error:%s", err)
 		}
 
 		tx, err := mutable.IntoImmutable()
 		if err != nil {
-			return nil, 0, err
+			return nil, err
 		}
 
 		txes = append(txes, tx)
 	}
+	return txes, nil
+}
 
-	results, height, err := bactor.PreExecuteCcntmractBatch(txes, atomic)
-	if err != nil {
-		return nil, 0, fmt.Errorf("PrepareInvokeCcntmract error:%s", err)
-	}
-	balances := make([]states.NativeTokenBalance, 0, len(ccntmractAddres))
+func parseNativeTokenBalances(results []*cstate.PreExecResult) ([]states.NativeTokenBalance, error) {
+	balances := make([]states.NativeTokenBalance, 0, len(results))
 	for _, result := range results {
 		if result.State == 0 {
-			return nil, 0, fmt.Errorf("prepare invoke failed")
+			return nil, fmt.Errorf("prepare invoke failed")
 		}
 		data, err := hex.DecodeString(result.Result.(string))
 		if err != nil {
-			return nil, 0, fmt.Errorf("hex.DecodeString error:%s", err)
+			return nil, fmt.Errorf("hex.DecodeString error:%s", err)
 		}
 
 		balance := common.BigIntFromNeoBytes(data)
 		balances = append(balances, states.NativeTokenBalance{Balance: bigint.New(balance)})
 	}
-
-	return balances, height, nil
+	return balances, nil
 }
 
 func GetOep4CcntmractBalance(ccntmractAddr common.Address, accAddr []common.Address, atomic bool) ([]string, uint32, error) {
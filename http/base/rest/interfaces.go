@@ -72,6 +72,17 @@ func GetNodeSyncStatus(cmd map[string]interface{}) map[string]interface{} {
 	return resp
 }
 
+//get the live status of the consensus
+func GetConsensusStatus(cmd map[string]interface{}) map[string]interface{} {
+	resp := ResponsePack(berr.SUCCESS)
+	status, err := bactor.GetConsensusStatus()
+	if err != nil {
+		return ResponsePack(berr.INTERNAL_ERROR)
+	}
+	resp["Result"] = status
+	return resp
+}
+
 //get block height
 func GetBlockHeight(cmd map[string]interface{}) map[string]interface{} {
 	resp := ResponsePack(berr.SUCCESS)
@@ -398,7 +409,16 @@ func GetStorage(cmd map[string]interface{}) map[string]interface{} {
 	if err != nil {
 		return ResponsePack(berr.INVALID_PARAMS)
 	}
-	value, err := bactor.GetStorageItem(address, item)
+	height, archived, err := getStateHeight(cmd)
+	if err != nil {
+		return ResponsePack(berr.INVALID_PARAMS)
+	}
+	var value []byte
+	if archived {
+		value, err = bactor.GetStorageItemAt(address, item, height)
+	} else {
+		value, err = bactor.GetStorageItem(address, item)
+	}
 	if err != nil {
 		if err == scom.ErrNotFound {
 			return ResponsePack(berr.SUCCESS)
@@ -420,7 +440,16 @@ func GetBalance(cmd map[string]interface{}) map[string]interface{} {
 	if err != nil {
 		return ResponsePack(berr.INVALID_PARAMS)
 	}
-	balance, err := bcomn.GetBalance(address)
+	height, archived, err := getStateHeight(cmd)
+	if err != nil {
+		return ResponsePack(berr.INVALID_PARAMS)
+	}
+	var balance *bcomn.BalanceOfRsp
+	if archived {
+		balance, err = bcomn.GetBalanceAt(address, height)
+	} else {
+		balance, err = bcomn.GetBalance(address)
+	}
 	if err != nil {
 		return ResponsePack(berr.INVALID_PARAMS)
 	}
@@ -439,7 +468,16 @@ func GetBalanceV2(cmd map[string]interface{}) map[string]interface{} {
 	if err != nil {
 		return ResponsePack(berr.INVALID_PARAMS)
 	}
-	balance, err := bcomn.GetBalanceV2(address)
+	height, archived, err := getStateHeight(cmd)
+	if err != nil {
+		return ResponsePack(berr.INVALID_PARAMS)
+	}
+	var balance *bcomn.BalanceOfRsp
+	if archived {
+		balance, err = bcomn.GetBalanceV2At(address, height)
+	} else {
+		balance, err = bcomn.GetBalanceV2(address)
+	}
 	if err != nil {
 		return ResponsePack(berr.INVALID_PARAMS)
 	}
@@ -447,6 +485,20 @@ func GetBalanceV2(cmd map[string]interface{}) map[string]interface{} {
 	return resp
 }
 
+//getStateHeight return the optional Height param of the state queries, the state at a
+//height is read from the archive, the current state is read without the param
+func getStateHeight(cmd map[string]interface{}) (uint32, bool, error) {
+	param, ok := cmd["Height"].(string)
+	if !ok || len(param) == 0 {
+		return 0, false, nil
+	}
+	height, err := strconv.ParseUint(param, 10, 32)
+	if err != nil {
+		return 0, false, err
+	}
+	return uint32(height), true, nil
+}
+
 //get merkle proof by transaction hash
 func GetMerkleProof(cmd map[string]interface{}) map[string]interface{} {
 	resp := ResponsePack(berr.SUCCESS)
@@ -0,0 +1,191 @@
+/*
+ * Copyright (C) 2018 The cntmology Authors
+ * This file is part of The cntmology library.
+ *
+ * The cntmology is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The cntmology is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * alcntm with The cntmology.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+// Package client provides typed clients of the node's JSON-RPC, REST and WebSocket apis
+package client
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/cntmio/cntmology/common"
+	cactor "github.com/cntmio/cntmology/consensus/actor"
+	"github.com/cntmio/cntmology/core/payload"
+	"github.com/cntmio/cntmology/core/types"
+	bcomn "github.com/cntmio/cntmology/http/base/common"
+	berr "github.com/cntmio/cntmology/http/base/error"
+	"github.com/cntmio/cntmology/smartccntmract/event"
+)
+
+// ErrNotSupported is returned when the method is not served by the transport of the client
+var ErrNotSupported = errors.New("method not supported by this transport")
+
+// Error is an error code returned by the node, see http/base/error for the codes
+type Error struct {
+	Code int64
+	Desc string
+}
+
+func (self *Error) Error() string {
+	desc := self.Desc
+	if desc == "" {
+		desc = berr.ErrMap[self.Code]
+	}
+	return fmt.Sprintf("node error %d: %s", self.Code, desc)
+}
+
+// BlockTxHashes is the result of the block transactions apis
+type BlockTxHashes struct {
+	Hash         string
+	Height       uint32
+	Transactions []string
+}
+
+// GasPrice is the result of the gas price apis
+type GasPrice struct {
+	GasPrice uint64 `json:"gasprice"`
+	Height   uint32 `json:"height"`
+}
+
+// Client is the api of a node, implemented by RpcClient, RestClient and WsClient.
+// The methods the transport does not serve return ErrNotSupported.
+type Client interface {
+	GetVersion() (string, error)
+	GetNetworkId() (uint32, error)
+	GetConnectionCount() (uint32, error)
+	GetSyncStatus() (*bcomn.SyncStatus, error)
+	GetConsensusStatus() (*cactor.ConsensusStatus, error)
+
+	GetCurrentBlockHeight() (uint32, error)
+	GetCurrentBlockHash() (common.Uint256, error)
+	GetBlockHash(height uint32) (common.Uint256, error)
+	GetBlockByHeight(height uint32) (*types.Block, error)
+	GetBlockByHash(hash common.Uint256) (*types.Block, error)
+	GetBlockInfoByHeight(height uint32) (*bcomn.BlockInfo, error)
+	GetBlockTxHashesByHeight(height uint32) (*BlockTxHashes, error)
+	GetBlockHeightByTxHash(txHash common.Uint256) (uint32, error)
+	GetMerkleProof(txHash common.Uint256) (*bcomn.MerkleProof, error)
+
+	GetTransaction(txHash common.Uint256) (*types.Transaction, error)
+	SendRawTransaction(tx *types.Transaction) (common.Uint256, error)
+	PreExecuteTransaction(tx *types.Transaction) (*bcomn.PreExecuteResult, error)
+
+	GetContractState(contract common.Address) (*payload.DeployCode, error)
+	GetStorage(contract common.Address, key []byte) ([]byte, error)
+	GetSmartContractEvent(txHash common.Uint256) (*event.ExecuteNotify, error)
+	GetSmartContractEventsByHeight(height uint32) ([]*event.ExecuteNotify, error)
+
+	GetBalance(addr common.Address) (*bcomn.BalanceOfRsp, error)
+	GetBalanceV2(addr common.Address) (*bcomn.BalanceOfRsp, error)
+	GetOep4Balance(contract common.Address, addrs []common.Address) (*bcomn.Oep4BalanceOfRsp, error)
+	GetAllowance(asset string, from, to common.Address) (string, error)
+	GetAllowanceV2(asset string, from, to common.Address) (string, error)
+	GetUnboundOng(addr common.Address) (string, error)
+	GetGrantOng(addr common.Address) (string, error)
+	GetGasPrice() (*GasPrice, error)
+
+	GetMemPoolTxCount() ([]uint32, error)
+	GetMemPoolTxState(txHash common.Uint256) (*bcomn.TXNEntryInfo, error)
+	GetMemPoolTxHashList() ([]common.Uint256, error)
+	GetMemPoolTxsByPayer(payer common.Address) ([]bcomn.PayerTxInfo, error)
+	ReplaceRawTransaction(tx *types.Transaction) (common.Uint256, error)
+
+	GetCrossChainMsg(height uint32) (string, error)
+	GetCrossStatesProof(height uint32, key []byte) (*bcomn.CrossStatesProof, error)
+	GetCrossStatesLeafHashes(height uint32) (*bcomn.CrossStatesLeafHashes, error)
+}
+
+func hexToUint256(str string) (common.Uint256, error) {
+	hash, err := common.Uint256FromHexString(str)
+	if err != nil {
+		return common.UINT256_EMPTY, fmt.Errorf("invalid hash %s: %s", str, err)
+	}
+	return hash, nil
+}
+
+func hexToBlock(str string) (*types.Block, error) {
+	raw, err := common.HexToBytes(str)
+	if err != nil {
+		return nil, fmt.Errorf("invalid block hex: %s", err)
+	}
+	return types.BlockFromRawBytes(raw)
+}
+
+func hexToTransaction(str string) (*types.Transaction, error) {
+	raw, err := common.HexToBytes(str)
+	if err != nil {
+		return nil, fmt.Errorf("invalid transaction hex: %s", err)
+	}
+	return types.TransactionFromRawBytes(raw)
+}
+
+func hexToDeployCode(str string) (*payload.DeployCode, error) {
+	raw, err := common.HexToBytes(str)
+	if err != nil {
+		return nil, fmt.Errorf("invalid contract hex: %s", err)
+	}
+	contract := &payload.DeployCode{}
+	if err = contract.Deserialization(common.NewZeroCopySource(raw)); err != nil {
+		return nil, err
+	}
+	return contract, nil
+}
+
+// toExecuteNotify converts the notify formatted by bcomn.GetExecuteNotify back to the ledger type
+func toExecuteNotify(notify *bcomn.ExecuteNotify) (*event.ExecuteNotify, error) {
+	txHash, err := hexToUint256(notify.TxHash)
+	if err != nil {
+		return nil, err
+	}
+	res := &event.ExecuteNotify{
+		TxHash:      txHash,
+		State:       notify.State,
+		GasConsumed: notify.GasConsumed,
+		GasStepUsed: notify.GasStepUsed,
+		TxIndex:     notify.TxIndex,
+	}
+	if notify.CreatedCcntmract != "" {
+		if res.CreatedCcntmract, err = common.AddressFromHexString(notify.CreatedCcntmract); err != nil {
+			return nil, fmt.Errorf("invalid created contract %s: %s", notify.CreatedCcntmract, err)
+		}
+	}
+	for _, n := range notify.Notify {
+		addr, err := common.AddressFromHexString(n.CcntmractAddress)
+		if err != nil {
+			return nil, fmt.Errorf("invalid contract address %s: %s", n.CcntmractAddress, err)
+		}
+		res.Notify = append(res.Notify, &event.NotifyEventInfo{
+			CcntmractAddress: addr,
+			States:           n.States,
+			IsEvm:            n.IsEvm,
+		})
+	}
+	return res, nil
+}
+
+func toLogEvent(log *bcomn.LogEventArgs) (*event.LogEventArgs, error) {
+	txHash, err := hexToUint256(log.TxHash)
+	if err != nil {
+		return nil, err
+	}
+	addr, err := common.AddressFromHexString(log.CcntmractAddress)
+	if err != nil {
+		return nil, fmt.Errorf("invalid contract address %s: %s", log.CcntmractAddress, err)
+	}
+	return &event.LogEventArgs{TxHash: txHash, CcntmractAddress: addr, Message: log.Message}, nil
+}
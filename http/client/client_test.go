@@ -0,0 +1,225 @@
+/*
+ * Copyright (C) 2018 The cntmology Authors
+ * This file is part of The cntmology library.
+ *
+ * The cntmology is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The cntmology is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * alcntm with The cntmology.  If not, see <http://www.gnu.org/licenses/>.
+ */
+package client
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cntmio/cntmology/common"
+	cactor "github.com/cntmio/cntmology/consensus/actor"
+	bcomn "github.com/cntmio/cntmology/http/base/common"
+	berr "github.com/cntmio/cntmology/http/base/error"
+	"github.com/cntmio/cntmology/smartccntmract/event"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+var testTxHash = common.Uint256{1, 2, 3}
+var testContract = common.Address{4, 5, 6}
+
+func testNotify() *bcomn.ExecuteNotify {
+	return &bcomn.ExecuteNotify{
+		TxHash:      testTxHash.ToHexString(),
+		State:       1,
+		GasConsumed: 20000,
+		Notify: []bcomn.NotifyEventInfo{
+			{CcntmractAddress: testContract.ToHexString(), States: []interface{}{"transfer"}},
+		},
+	}
+}
+
+func TestRpcClient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := &rpcRequest{}
+		assert.Nil(t, json.NewDecoder(r.Body).Decode(req))
+		resp := map[string]interface{}{"error": berr.SUCCESS, "id": req.Id}
+		switch req.Method {
+		case "getblockcount":
+			resp["result"] = 11
+		case "getsmartcodeevent":
+			assert.Equal(t, []interface{}{testTxHash.ToHexString()}, req.Params)
+			resp["result"] = testNotify()
+		case "getstorage":
+			resp["result"] = nil
+		default:
+			resp["error"] = berr.INVALID_METHOD
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+
+	client := NewRpcClient(server.URL)
+	height, err := client.GetCurrentBlockHeight()
+	assert.Nil(t, err)
+	assert.Equal(t, uint32(10), height)
+
+	notify, err := client.GetSmartContractEvent(testTxHash)
+	assert.Nil(t, err)
+	assert.Equal(t, testTxHash, notify.TxHash)
+	assert.Equal(t, uint64(20000), notify.GasConsumed)
+	assert.Equal(t, testContract, notify.Notify[0].CcntmractAddress)
+
+	value, err := client.GetStorage(testContract, []byte("key"))
+	assert.Nil(t, err)
+	assert.Nil(t, value)
+
+	_, err = client.GetVersion()
+	assert.Equal(t, berr.INVALID_METHOD, err.(*Error).Code)
+}
+
+func TestRestClient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		resp := map[string]interface{}{"Error": berr.SUCCESS}
+		switch r.URL.Path {
+		case "/api/v1/block/hash/10":
+			resp["Result"] = testTxHash.ToHexString()
+		case "/api/v1/smartcode/event/transactions/10":
+			resp["Result"] = []*bcomn.ExecuteNotify{testNotify()}
+		case "/api/v1/storage/" + testContract.ToHexString() + "/6b6579":
+			resp["Error"], resp["Result"] = berr.INTERNAL_ERROR, "db closed"
+		default:
+			resp["Error"] = berr.INVALID_METHOD
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+
+	client := NewRestClient(server.URL)
+	hash, err := client.GetBlockHash(10)
+	assert.Nil(t, err)
+	assert.Equal(t, testTxHash, hash)
+
+	notifies, err := client.GetSmartContractEventsByHeight(10)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(notifies))
+	assert.Equal(t, testTxHash, notifies[0].TxHash)
+
+	_, err = client.GetStorage(testContract, []byte("key"))
+	assert.Equal(t, &Error{Code: berr.INTERNAL_ERROR, Desc: "db closed"}, err)
+
+	_, err = client.GetCrossChainMsg(10)
+	assert.Equal(t, ErrNotSupported, err)
+}
+
+func TestWsClientReconnect(t *testing.T) {
+	upgrader := websocket.Upgrader{}
+	conns := make(chan *websocket.Conn, 4)
+	subscribes := make(chan map[string]interface{}, 4)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		conns <- conn
+		for {
+			req := make(map[string]interface{})
+			if err := conn.ReadJSON(&req); err != nil {
+				return
+			}
+			rsp := map[string]interface{}{"Action": req["Action"], "Id": req["Id"], "Error": berr.SUCCESS}
+			if req["Action"] == "getsessioncount" {
+				rsp["Result"] = 1
+			}
+			conn.WriteJSON(rsp)
+			if req["Action"] == "subscribe" {
+				subscribes <- req
+				conn.WriteJSON(map[string]interface{}{"Action": event.EVENT_NOTIFY, "Result": testNotify()})
+			}
+		}
+	}))
+	defer server.Close()
+
+	events := make(chan *event.ExecuteNotify, 4)
+	client := NewWsClient("ws" + strings.TrimPrefix(server.URL, "http"))
+	assert.Nil(t, client.Connect())
+	defer client.Close()
+	sub := &WsSubscription{
+		ContractsFilter: []common.Address{testContract},
+		OnEvent: func(notify *event.ExecuteNotify) {
+			events <- notify
+		},
+	}
+	assert.Nil(t, client.Subscribe(sub))
+
+	waitSubscribe := func() {
+		select {
+		case req := <-subscribes:
+			assert.Equal(t, true, req["SubscribeEvent"])
+			assert.Equal(t, false, req["SubscribeRawBlock"])
+			assert.Equal(t, []interface{}{testContract.ToHexString()}, req["CcntmractsFilter"])
+		case <-time.After(5 * time.Second):
+			t.Fatal("subscribe request not received")
+		}
+		select {
+		case notify := <-events:
+			assert.Equal(t, testTxHash, notify.TxHash)
+		case <-time.After(5 * time.Second):
+			t.Fatal("event not pushed")
+		}
+	}
+	waitSubscribe()
+
+	// the node drops the connection, the client dials again and restores the subscription
+	(<-conns).Close()
+	waitSubscribe()
+	count, err := client.GetSessionCount()
+	assert.Nil(t, err)
+	assert.Equal(t, uint32(1), count)
+
+	client.Close()
+	_, err = client.GetSessionCount()
+	assert.Equal(t, ErrDisconnected, err)
+}
+
+func TestWsClientConsensusStatus(t *testing.T) {
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			req := make(map[string]interface{})
+			if err := conn.ReadJSON(&req); err != nil {
+				return
+			}
+			rsp := map[string]interface{}{"Action": req["Action"], "Id": req["Id"], "Error": berr.SUCCESS}
+			if req["Action"] == "getconsensusstatus" {
+				rsp["Result"] = &cactor.ConsensusStatus{State: "syncing", Index: 2, Height: 11, CommittedHeight: 10}
+			}
+			conn.WriteJSON(rsp)
+		}
+	}))
+	defer server.Close()
+
+	client := NewWsClient("ws" + strings.TrimPrefix(server.URL, "http"))
+	assert.Nil(t, client.Connect())
+	defer client.Close()
+	status, err := client.GetConsensusStatus()
+	assert.Nil(t, err)
+	assert.Equal(t, "syncing", status.State)
+	assert.Equal(t, uint32(2), status.Index)
+	assert.Equal(t, uint32(11), status.Height)
+	assert.Equal(t, uint32(10), status.CommittedHeight)
+}
@@ -0,0 +1,365 @@
+/*
+ * Copyright (C) 2018 The cntmology Authors
+ * This file is part of The cntmology library.
+ *
+ * The cntmology is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The cntmology is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * alcntm with The cntmology.  If not, see <http://www.gnu.org/licenses/>.
+ */
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/cntmio/cntmology/common"
+	cactor "github.com/cntmio/cntmology/consensus/actor"
+	"github.com/cntmio/cntmology/core/payload"
+	"github.com/cntmio/cntmology/core/types"
+	bcomn "github.com/cntmio/cntmology/http/base/common"
+	"github.com/cntmio/cntmology/smartccntmract/event"
+)
+
+// restResponse is the response of the rest and websocket apis, see rest.ResponsePack
+type restResponse struct {
+	Action  string
+	Id      interface{} `json:",omitempty"`
+	Error   int64
+	Desc    string
+	Result  json.RawMessage
+	Version string
+}
+
+// actionTransport sends the action with the request params of the rest handlers, and decodes the result.
+// It returns ErrNotSupported if the transport does not serve the action.
+type actionTransport interface {
+	request(action string, params map[string]interface{}, result interface{}) error
+}
+
+// restApi implements Client over the rest handlers, which serve both the rest and websocket apis
+type restApi struct {
+	transport actionTransport
+}
+
+func (self *restApi) GetVersion() (string, error) {
+	var version string
+	err := self.transport.request("getversion", nil, &version)
+	return version, err
+}
+
+func (self *restApi) GetNetworkId() (uint32, error) {
+	var id uint32
+	err := self.transport.request("getnetworkid", nil, &id)
+	return id, err
+}
+
+func (self *restApi) GetConnectionCount() (uint32, error) {
+	var count uint32
+	err := self.transport.request("getconnectioncount", nil, &count)
+	return count, err
+}
+
+func (self *restApi) GetSyncStatus() (*bcomn.SyncStatus, error) {
+	status := &bcomn.SyncStatus{}
+	if err := self.transport.request("getsyncstatus", nil, status); err != nil {
+		return nil, err
+	}
+	return status, nil
+}
+
+// GetConsensusStatus is served by the rpc and websocket apis
+func (self *restApi) GetConsensusStatus() (*cactor.ConsensusStatus, error) {
+	status := &cactor.ConsensusStatus{}
+	if err := self.transport.request("getconsensusstatus", nil, status); err != nil {
+		return nil, err
+	}
+	return status, nil
+}
+
+func (self *restApi) GetCurrentBlockHeight() (uint32, error) {
+	var height uint32
+	err := self.transport.request("getblockheight", nil, &height)
+	return height, err
+}
+
+func (self *restApi) GetCurrentBlockHash() (common.Uint256, error) {
+	height, err := self.GetCurrentBlockHeight()
+	if err != nil {
+		return common.UINT256_EMPTY, err
+	}
+	return self.GetBlockHash(height)
+}
+
+func (self *restApi) GetBlockHash(height uint32) (common.Uint256, error) {
+	var hash string
+	if err := self.transport.request("getblockhash", heightParams(height), &hash); err != nil {
+		return common.UINT256_EMPTY, err
+	}
+	return hexToUint256(hash)
+}
+
+func (self *restApi) GetBlockByHeight(height uint32) (*types.Block, error) {
+	var block string
+	params := heightParams(height)
+	params["Raw"] = "1"
+	if err := self.transport.request("getblockbyheight", params, &block); err != nil {
+		return nil, err
+	}
+	return hexToBlock(block)
+}
+
+func (self *restApi) GetBlockByHash(hash common.Uint256) (*types.Block, error) {
+	var block string
+	params := hashParams(hash)
+	params["Raw"] = "1"
+	if err := self.transport.request("getblockbyhash", params, &block); err != nil {
+		return nil, err
+	}
+	return hexToBlock(block)
+}
+
+func (self *restApi) GetBlockInfoByHeight(height uint32) (*bcomn.BlockInfo, error) {
+	info := &bcomn.BlockInfo{}
+	if err := self.transport.request("getblockbyheight", heightParams(height), info); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+func (self *restApi) GetBlockTxHashesByHeight(height uint32) (*BlockTxHashes, error) {
+	txs := &BlockTxHashes{}
+	if err := self.transport.request("getblocktxsbyheight", heightParams(height), txs); err != nil {
+		return nil, err
+	}
+	return txs, nil
+}
+
+func (self *restApi) GetBlockHeightByTxHash(txHash common.Uint256) (uint32, error) {
+	var height uint32
+	err := self.transport.request("getblockheightbytxhash", hashParams(txHash), &height)
+	return height, err
+}
+
+func (self *restApi) GetMerkleProof(txHash common.Uint256) (*bcomn.MerkleProof, error) {
+	proof := &bcomn.MerkleProof{}
+	if err := self.transport.request("getmerkleproof", hashParams(txHash), proof); err != nil {
+		return nil, err
+	}
+	return proof, nil
+}
+
+func (self *restApi) GetTransaction(txHash common.Uint256) (*types.Transaction, error) {
+	var tx string
+	params := hashParams(txHash)
+	params["Raw"] = "1"
+	if err := self.transport.request("gettransaction", params, &tx); err != nil {
+		return nil, err
+	}
+	return hexToTransaction(tx)
+}
+
+func (self *restApi) SendRawTransaction(tx *types.Transaction) (common.Uint256, error) {
+	var hash string
+	params := map[string]interface{}{"Data": common.ToHexString(tx.ToArray())}
+	if err := self.transport.request("sendrawtransaction", params, &hash); err != nil {
+		return common.UINT256_EMPTY, err
+	}
+	return hexToUint256(hash)
+}
+
+func (self *restApi) PreExecuteTransaction(tx *types.Transaction) (*bcomn.PreExecuteResult, error) {
+	res := &bcomn.PreExecuteResult{}
+	params := map[string]interface{}{"Data": common.ToHexString(tx.ToArray()), "PreExec": "1"}
+	if err := self.transport.request("sendrawtransaction", params, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (self *restApi) GetContractState(contract common.Address) (*payload.DeployCode, error) {
+	var code string
+	params := map[string]interface{}{"Hash": contract.ToHexString(), "Raw": "1"}
+	if err := self.transport.request("getccntmract", params, &code); err != nil {
+		return nil, err
+	}
+	return hexToDeployCode(code)
+}
+
+func (self *restApi) GetStorage(contract common.Address, key []byte) ([]byte, error) {
+	var value string
+	params := map[string]interface{}{"Hash": contract.ToHexString(), "Key": common.ToHexString(key)}
+	if err := self.transport.request("getstorage", params, &value); err != nil {
+		return nil, err
+	}
+	if value == "" {
+		return nil, nil
+	}
+	return common.HexToBytes(value)
+}
+
+func (self *restApi) GetSmartContractEvent(txHash common.Uint256) (*event.ExecuteNotify, error) {
+	var notify *bcomn.ExecuteNotify
+	if err := self.transport.request("getsmartcodeeventbyhash", hashParams(txHash), &notify); err != nil {
+		return nil, err
+	}
+	if notify == nil {
+		return nil, nil
+	}
+	return toExecuteNotify(notify)
+}
+
+func (self *restApi) GetSmartContractEventsByHeight(height uint32) ([]*event.ExecuteNotify, error) {
+	var notifies []*bcomn.ExecuteNotify
+	if err := self.transport.request("getsmartcodeeventbyheight", heightParams(height), &notifies); err != nil {
+		return nil, err
+	}
+	return toExecuteNotifies(notifies)
+}
+
+func (self *restApi) GetBalance(addr common.Address) (*bcomn.BalanceOfRsp, error) {
+	balance := &bcomn.BalanceOfRsp{}
+	if err := self.transport.request("getbalance", addrParams(addr), balance); err != nil {
+		return nil, err
+	}
+	return balance, nil
+}
+
+func (self *restApi) GetBalanceV2(addr common.Address) (*bcomn.BalanceOfRsp, error) {
+	balance := &bcomn.BalanceOfRsp{}
+	if err := self.transport.request("getbalancev2", addrParams(addr), balance); err != nil {
+		return nil, err
+	}
+	return balance, nil
+}
+
+func (self *restApi) GetOep4Balance(contract common.Address, addrs []common.Address) (*bcomn.Oep4BalanceOfRsp, error) {
+	return nil, ErrNotSupported
+}
+
+func (self *restApi) GetAllowance(asset string, from, to common.Address) (string, error) {
+	var allowance string
+	err := self.transport.request("getallowance", allowanceParams(asset, from, to), &allowance)
+	return allowance, err
+}
+
+func (self *restApi) GetAllowanceV2(asset string, from, to common.Address) (string, error) {
+	var allowance string
+	err := self.transport.request("getallowancev2", allowanceParams(asset, from, to), &allowance)
+	return allowance, err
+}
+
+func (self *restApi) GetUnboundOng(addr common.Address) (string, error) {
+	var amount string
+	err := self.transport.request("getunboundcntm", addrParams(addr), &amount)
+	return amount, err
+}
+
+func (self *restApi) GetGrantOng(addr common.Address) (string, error) {
+	var amount string
+	err := self.transport.request("getgrantcntm", addrParams(addr), &amount)
+	return amount, err
+}
+
+func (self *restApi) GetGasPrice() (*GasPrice, error) {
+	price := &GasPrice{}
+	if err := self.transport.request("getgasprice", nil, price); err != nil {
+		return nil, err
+	}
+	return price, nil
+}
+
+func (self *restApi) GetMemPoolTxCount() ([]uint32, error) {
+	var count []uint32
+	err := self.transport.request("getmempooltxcount", nil, &count)
+	return count, err
+}
+
+func (self *restApi) GetMemPoolTxState(txHash common.Uint256) (*bcomn.TXNEntryInfo, error) {
+	state := &bcomn.TXNEntryInfo{}
+	if err := self.transport.request("getmempooltxstate", hashParams(txHash), state); err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+func (self *restApi) GetMemPoolTxHashList() ([]common.Uint256, error) {
+	var hashes []common.Uint256
+	err := self.transport.request("getmempooltxhashlist", nil, &hashes)
+	return hashes, err
+}
+
+func (self *restApi) GetMemPoolTxsByPayer(payer common.Address) ([]bcomn.PayerTxInfo, error) {
+	var txs []bcomn.PayerTxInfo
+	err := self.transport.request("getmempooltxsbypayer", addrParams(payer), &txs)
+	return txs, err
+}
+
+func (self *restApi) ReplaceRawTransaction(tx *types.Transaction) (common.Uint256, error) {
+	var hash string
+	params := map[string]interface{}{"Data": common.ToHexString(tx.ToArray())}
+	if err := self.transport.request("replacerawtransaction", params, &hash); err != nil {
+		return common.UINT256_EMPTY, err
+	}
+	return hexToUint256(hash)
+}
+
+func (self *restApi) GetCrossChainMsg(height uint32) (string, error) {
+	return "", ErrNotSupported
+}
+
+func (self *restApi) GetCrossStatesProof(height uint32, key []byte) (*bcomn.CrossStatesProof, error) {
+	return nil, ErrNotSupported
+}
+
+func (self *restApi) GetCrossStatesLeafHashes(height uint32) (*bcomn.CrossStatesLeafHashes, error) {
+	return nil, ErrNotSupported
+}
+
+func heightParams(height uint32) map[string]interface{} {
+	return map[string]interface{}{"Height": height}
+}
+
+func hashParams(hash common.Uint256) map[string]interface{} {
+	return map[string]interface{}{"Hash": hash.ToHexString()}
+}
+
+func addrParams(addr common.Address) map[string]interface{} {
+	return map[string]interface{}{"Addr": addr.ToBase58()}
+}
+
+func allowanceParams(asset string, from, to common.Address) map[string]interface{} {
+	return map[string]interface{}{"Asset": asset, "From": from.ToBase58(), "To": to.ToBase58()}
+}
+
+func toExecuteNotifies(notifies []*bcomn.ExecuteNotify) ([]*event.ExecuteNotify, error) {
+	res := make([]*event.ExecuteNotify, 0, len(notifies))
+	for _, notify := range notifies {
+		n, err := toExecuteNotify(notify)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, n)
+	}
+	return res, nil
+}
+
+// decodeResult decodes the result of a successful response, an empty string or null result
+// leaves result unchanged, the handlers return it when the item is not found
+func decodeResult(raw json.RawMessage, result interface{}) error {
+	if result == nil || len(raw) == 0 || string(raw) == "null" || string(raw) == `""` {
+		return nil
+	}
+	if err := json.Unmarshal(raw, result); err != nil {
+		return fmt.Errorf("decode result %s error: %s", raw, err)
+	}
+	return nil
+}
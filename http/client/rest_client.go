@@ -0,0 +1,160 @@
+/*
+ * Copyright (C) 2018 The cntmology Authors
+ * This file is part of The cntmology library.
+ *
+ * The cntmology is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The cntmology is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * alcntm with The cntmology.  If not, see <http://www.gnu.org/licenses/>.
+ */
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	bcomn "github.com/cntmio/cntmology/http/base/common"
+)
+
+// restPaths maps the actions to the routes of http/restful/restful/server.go
+var restPaths = map[string]string{
+	"getconnectioncount":        "/api/v1/node/connectioncount",
+	"getsyncstatus":             "/api/v1/node/syncstatus",
+	"getblocktxsbyheight":       "/api/v1/block/transactions/height/:height",
+	"getblockbyheight":          "/api/v1/block/details/height/:height",
+	"getblockbyhash":            "/api/v1/block/details/hash/:hash",
+	"getblockheight":            "/api/v1/block/height",
+	"getblockhash":              "/api/v1/block/hash/:height",
+	"gettransaction":            "/api/v1/transaction/:hash",
+	"getstorage":                "/api/v1/storage/:hash/:key",
+	"getbalance":                "/api/v1/balance/:addr",
+	"getbalancev2":              "/api/v1/balancev2/:addr",
+	"getccntmract":              "/api/v1/ccntmract/:hash",
+	"getsmartcodeeventbyheight": "/api/v1/smartcode/event/transactions/:height",
+	"getsmartcodeeventbyhash":   "/api/v1/smartcode/event/txhash/:hash",
+	"getblockheightbytxhash":    "/api/v1/block/height/txhash/:hash",
+	"getmerkleproof":            "/api/v1/merkleproof/:hash",
+	"getgasprice":               "/api/v1/gasprice",
+	"getallowance":              "/api/v1/allowance/:asset/:from/:to",
+	"getallowancev2":            "/api/v1/allowancev2/:asset/:from/:to",
+	"getunboundcntm":            "/api/v1/unboundcntm/:addr",
+	"getgrantcntm":              "/api/v1/grantcntm/:addr",
+	"getmempooltxcount":         "/api/v1/mempool/txcount",
+	"getmempooltxstate":         "/api/v1/mempool/txstate/:hash",
+	"getmempooltxhashlist":      "/api/v1/mempool/txhashlist",
+	"getversion":                "/api/v1/version",
+	"getnetworkid":              "/api/v1/networkid",
+	"sendrawtransaction":        "/api/v1/transaction",
+}
+
+// the path variables of the routes, and the request params they are filled with
+var restPathParams = map[string]string{
+	":height": "Height",
+	":hash":   "Hash",
+	":key":    "Key",
+	":addr":   "Addr",
+	":asset":  "Asset",
+	":from":   "From",
+	":to":     "To",
+}
+
+// RestClient is the client of the restful api
+type RestClient struct {
+	restApi
+	addr       string
+	httpClient *http.Client
+}
+
+var _ Client = (*RestClient)(nil)
+
+// NewRestClient returns a client of the restful api served at addr, e.g. http://localhost:20334
+func NewRestClient(addr string) *RestClient {
+	client := &RestClient{
+		addr:       strings.TrimRight(addr, "/"),
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+	}
+	client.restApi.transport = client
+	return client
+}
+
+func (self *RestClient) request(action string, params map[string]interface{}, result interface{}) error {
+	path, ok := restPaths[action]
+	if !ok {
+		return ErrNotSupported
+	}
+	for variable, param := range restPathParams {
+		if val, ok := params[param]; ok {
+			path = strings.Replace(path, variable, url.PathEscape(fmt.Sprint(val)), 1)
+		}
+	}
+	query := url.Values{}
+	if raw, ok := params["Raw"]; ok {
+		query.Set("raw", fmt.Sprint(raw))
+	}
+	if preExec, ok := params["PreExec"]; ok {
+		query.Set("preExec", fmt.Sprint(preExec))
+	}
+	reqUrl := self.addr + path
+	if len(query) > 0 {
+		reqUrl += "?" + query.Encode()
+	}
+
+	var resp *http.Response
+	var err error
+	if action == "sendrawtransaction" {
+		var data []byte
+		data, err = json.Marshal(map[string]interface{}{
+			"Action":  action,
+			"Version": "1.0.0",
+			"Data":    params["Data"],
+		})
+		if err != nil {
+			return err
+		}
+		resp, err = self.httpClient.Post(reqUrl, "application/json", bytes.NewReader(data))
+	} else {
+		resp, err = self.httpClient.Get(reqUrl)
+	}
+	if err != nil {
+		return fmt.Errorf("%s request error: %s", action, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, bcomn.MAX_REQUEST_BODY_SIZE*64))
+	if err != nil {
+		return fmt.Errorf("read %s response error: %s", action, err)
+	}
+	rsp := &restResponse{}
+	if err = json.Unmarshal(body, rsp); err != nil {
+		return fmt.Errorf("decode %s response %s error: %s", action, body, err)
+	}
+	if rsp.Error != 0 {
+		return restError(rsp)
+	}
+	return decodeResult(rsp.Result, result)
+}
+
+// restError returns the error of a failed response, the result of a failed call may hold the reason
+func restError(rsp *restResponse) error {
+	err := &Error{Code: rsp.Error, Desc: rsp.Desc}
+	var reason string
+	if json.Unmarshal(rsp.Result, &reason) == nil && reason != "" {
+		err.Desc = reason
+	}
+	return err
+}
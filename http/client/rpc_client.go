@@ -0,0 +1,227 @@
+/*
+ * Copyright (C) 2018 The cntmology Authors
+ * This file is part of The cntmology library.
+ *
+ * The cntmology is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The cntmology is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * alcntm with The cntmology.  If not, see <http://www.gnu.org/licenses/>.
+ */
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/cntmio/cntmology/common"
+	bcomn "github.com/cntmio/cntmology/http/base/common"
+)
+
+// JSON_RPC_VERSION is the version of the json rpc requests
+const JSON_RPC_VERSION = "2.0"
+
+type rpcRequest struct {
+	Version string        `json:"jsonrpc"`
+	Id      uint64        `json:"id"`
+	Method  string        `json:"method"`
+	Params  []interface{} `json:"params"`
+}
+
+type rpcResponse struct {
+	Error  int64           `json:"error"`
+	Desc   string          `json:"desc"`
+	Result json.RawMessage `json:"result"`
+}
+
+// rpcMethod is the json rpc method serving an action of the rest handlers, with its positional params
+type rpcMethod struct {
+	name   string
+	params func(p map[string]interface{}) []interface{}
+}
+
+func noParams(p map[string]interface{}) []interface{} { return nil }
+
+func paramsOf(keys ...string) func(p map[string]interface{}) []interface{} {
+	return func(p map[string]interface{}) []interface{} {
+		params := make([]interface{}, 0, len(keys))
+		for _, key := range keys {
+			params = append(params, p[key])
+		}
+		return params
+	}
+}
+
+// verboseParamsOf returns the params of a method returning the raw hex item, or the json item
+// with the verbose flag when the rest Raw param is not set
+func verboseParamsOf(key string) func(p map[string]interface{}) []interface{} {
+	return func(p map[string]interface{}) []interface{} {
+		if p["Raw"] == "1" {
+			return []interface{}{p[key]}
+		}
+		return []interface{}{p[key], 1}
+	}
+}
+
+// rpcMethods maps the actions to the methods registered in http/jsonrpc/rpc_server.go
+var rpcMethods = map[string]rpcMethod{
+	"getversion":                {"getversion", noParams},
+	"getnetworkid":              {"getnetworkid", noParams},
+	"getconnectioncount":        {"getconnectioncount", noParams},
+	"getsyncstatus":             {"getsyncstatus", noParams},
+	"getconsensusstatus":        {"getconsensusstatus", noParams},
+	"getblockhash":              {"getblockhash", paramsOf("Height")},
+	"getblockbyheight":          {"getblock", verboseParamsOf("Height")},
+	"getblockbyhash":            {"getblock", verboseParamsOf("Hash")},
+	"getblocktxsbyheight":       {"getblocktxsbyheight", paramsOf("Height")},
+	"getblockheightbytxhash":    {"getblockheightbytxhash", paramsOf("Hash")},
+	"getmerkleproof":            {"getmerkleproof", paramsOf("Hash")},
+	"gettransaction":            {"getrawtransaction", verboseParamsOf("Hash")},
+	"getccntmract":              {"getccntmractstate", verboseParamsOf("Hash")},
+	"getstorage":                {"getstorage", paramsOf("Hash", "Key")},
+	"getsmartcodeeventbyhash":   {"getsmartcodeevent", paramsOf("Hash")},
+	"getsmartcodeeventbyheight": {"getsmartcodeevent", paramsOf("Height")},
+	"getbalance":                {"getbalance", paramsOf("Addr")},
+	"getbalancev2":              {"getbalancev2", paramsOf("Addr")},
+	"getallowance":              {"getallowance", paramsOf("Asset", "From", "To")},
+	"getallowancev2":            {"getallowancev2", paramsOf("Asset", "From", "To")},
+	"getunboundcntm":            {"getunboundcntm", paramsOf("Addr")},
+	"getgrantcntm":              {"getgrantcntm", paramsOf("Addr")},
+	"getgasprice":               {"getgasprice", noParams},
+	"getmempooltxcount":         {"getmempooltxcount", noParams},
+	"getmempooltxstate":         {"getmempooltxstate", paramsOf("Hash")},
+	"getmempooltxhashlist":      {"getmempooltxhashlist", noParams},
+	"getmempooltxsbypayer":      {"getmempooltxsbypayer", paramsOf("Addr")},
+	"replacerawtransaction":     {"replacerawtransaction", paramsOf("Data")},
+	"sendrawtransaction": {"sendrawtransaction", func(p map[string]interface{}) []interface{} {
+		if p["PreExec"] == "1" {
+			return []interface{}{p["Data"], 1}
+		}
+		return []interface{}{p["Data"]}
+	}},
+}
+
+// RpcClient is the client of the json rpc api
+type RpcClient struct {
+	restApi
+	addr       string
+	httpClient *http.Client
+	id         uint64
+}
+
+var _ Client = (*RpcClient)(nil)
+
+// NewRpcClient returns a client of the json rpc api served at addr, e.g. http://localhost:20336
+func NewRpcClient(addr string) *RpcClient {
+	client := &RpcClient{
+		addr:       addr,
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+	}
+	client.restApi.transport = client
+	return client
+}
+
+// Call sends the json rpc request of method, and decodes the result of the response into result
+func (self *RpcClient) Call(method string, result interface{}, params ...interface{}) error {
+	if params == nil {
+		params = []interface{}{}
+	}
+	data, err := json.Marshal(&rpcRequest{
+		Version: JSON_RPC_VERSION,
+		Id:      atomic.AddUint64(&self.id, 1),
+		Method:  method,
+		Params:  params,
+	})
+	if err != nil {
+		return fmt.Errorf("marshal %s request error: %s", method, err)
+	}
+	resp, err := self.httpClient.Post(self.addr, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("%s request error: %s", method, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, bcomn.MAX_REQUEST_BODY_SIZE*64))
+	if err != nil {
+		return fmt.Errorf("read %s response error: %s", method, err)
+	}
+	rsp := &rpcResponse{}
+	if err = json.Unmarshal(body, rsp); err != nil {
+		return fmt.Errorf("decode %s response %s error: %s", method, body, err)
+	}
+	if rsp.Error != 0 {
+		return &Error{Code: rsp.Error, Desc: rsp.Desc}
+	}
+	return decodeResult(rsp.Result, result)
+}
+
+func (self *RpcClient) request(action string, params map[string]interface{}, result interface{}) error {
+	method, ok := rpcMethods[action]
+	if !ok {
+		return ErrNotSupported
+	}
+	return self.Call(method.name, result, method.params(params)...)
+}
+
+func (self *RpcClient) GetCurrentBlockHeight() (uint32, error) {
+	var count uint32
+	if err := self.Call("getblockcount", &count); err != nil {
+		return 0, err
+	}
+	return count - 1, nil
+}
+
+func (self *RpcClient) GetCurrentBlockHash() (common.Uint256, error) {
+	var hash string
+	if err := self.Call("getbestblockhash", &hash); err != nil {
+		return common.UINT256_EMPTY, err
+	}
+	return hexToUint256(hash)
+}
+
+func (self *RpcClient) GetOep4Balance(contract common.Address, addrs []common.Address) (*bcomn.Oep4BalanceOfRsp, error) {
+	accounts := make([]interface{}, 0, len(addrs))
+	for _, addr := range addrs {
+		accounts = append(accounts, addr.ToBase58())
+	}
+	balance := &bcomn.Oep4BalanceOfRsp{}
+	if err := self.Call("getoep4balance", balance, contract.ToHexString(), accounts); err != nil {
+		return nil, err
+	}
+	return balance, nil
+}
+
+func (self *RpcClient) GetCrossChainMsg(height uint32) (string, error) {
+	var msg string
+	err := self.Call("getcrosschainmsg", &msg, height)
+	return msg, err
+}
+
+func (self *RpcClient) GetCrossStatesProof(height uint32, key []byte) (*bcomn.CrossStatesProof, error) {
+	proof := &bcomn.CrossStatesProof{}
+	if err := self.Call("getcrossstatesproof", proof, height, common.ToHexString(key)); err != nil {
+		return nil, err
+	}
+	return proof, nil
+}
+
+func (self *RpcClient) GetCrossStatesLeafHashes(height uint32) (*bcomn.CrossStatesLeafHashes, error) {
+	hashes := &bcomn.CrossStatesLeafHashes{}
+	if err := self.Call("getcrossstatesleafhashes", hashes, height); err != nil {
+		return nil, err
+	}
+	return hashes, nil
+}
@@ -0,0 +1,442 @@
+/*
+ * Copyright (C) 2018 The cntmology Authors
+ * This file is part of The cntmology library.
+ *
+ * The cntmology is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The cntmology is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * alcntm with The cntmology.  If not, see <http://www.gnu.org/licenses/>.
+ */
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/cntmio/cntmology/common"
+	"github.com/cntmio/cntmology/common/log"
+	"github.com/cntmio/cntmology/core/types"
+	bcomn "github.com/cntmio/cntmology/http/base/common"
+	"github.com/cntmio/cntmology/smartccntmract/event"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	WS_REQUEST_TIMEOUT     = 30 * time.Second
+	WS_HEARTBEAT_INTERVAL  = 60 * time.Second // the node closes the sessions inactive for 300s
+	WS_MIN_RECONNECT_DELAY = time.Second
+	WS_MAX_RECONNECT_DELAY = 30 * time.Second
+	WS_PUSH_QUEUE_SIZE     = 256
+)
+
+var (
+	ErrDisconnected = errors.New("websocket disconnected")
+	ErrClientClosed = errors.New("websocket client closed")
+)
+
+// wsActions are the actions registered in http/websocket/websocket/server.go
+var wsActions = map[string]bool{
+	"getblockheightbytxhash":    true,
+	"getsmartcodeeventbyhash":   true,
+	"getsmartcodeeventbyheight": true,
+	"getccntmract":              true,
+	"getbalance":                true,
+	"getbalancev2":              true,
+	"getconnectioncount":        true,
+	"getblockbyheight":          true,
+	"getblockhash":              true,
+	"getblockbyhash":            true,
+	"getblockheight":            true,
+	"gettransaction":            true,
+	"sendrawtransaction":        true,
+	"heartbeat":                 true,
+	"subscribe":                 true,
+	"getstorage":                true,
+	"getallowance":              true,
+	"getallowancev2":            true,
+	"getmerkleproof":            true,
+	"getblocktxsbyheight":       true,
+	"getgasprice":               true,
+	"getunboundcntm":            true,
+	"getgrantcntm":              true,
+	"getmempooltxcount":         true,
+	"getmempooltxstate":         true,
+	"getmempooltxhashlist":      true,
+	"getversion":                true,
+	"getnetworkid":              true,
+	"getsessioncount":           true,
+	"getconsensusstatus":        true,
+}
+
+// WsSubscription selects the items the node pushes to the client, each kind of item is
+// subscribed when its handler is set. The handlers are called in order from a single goroutine.
+type WsSubscription struct {
+	// restrict the events to the contracts, all the events are pushed if empty
+	ContractsFilter []common.Address
+
+	OnEvent         func(notify *event.ExecuteNotify)
+	OnLog           func(log *event.LogEventArgs)
+	OnBlock         func(block *types.Block)
+	OnJsonBlock     func(block *bcomn.BlockInfo)
+	OnBlockTxHashes func(txs *BlockTxHashes)
+	// called when a pushed item can not be decoded, or the subscription can not be restored after reconnect
+	OnError func(err error)
+}
+
+func (self *WsSubscription) params() map[string]interface{} {
+	filter := make([]string, 0, len(self.ContractsFilter))
+	for _, addr := range self.ContractsFilter {
+		filter = append(filter, addr.ToHexString())
+	}
+	return map[string]interface{}{
+		"CcntmractsFilter":      filter,
+		"SubscribeEvent":        self.OnEvent != nil || self.OnLog != nil,
+		"SubscribeJsonBlock":    self.OnJsonBlock != nil,
+		"SubscribeRawBlock":     self.OnBlock != nil,
+		"SubscribeBlockTxHashs": self.OnBlockTxHashes != nil,
+	}
+}
+
+// WsClient is the client of the websocket api. It reconnects when the connection is lost,
+// and subscribes again with the last subscription.
+type WsClient struct {
+	restApi
+	addr   string
+	dialer *websocket.Dialer
+
+	lock         sync.Mutex
+	conn         *websocket.Conn
+	nextId       uint64
+	pending      map[string]chan *restResponse
+	subscription *WsSubscription
+
+	writeLock sync.Mutex
+	pushes    chan *restResponse
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+var _ Client = (*WsClient)(nil)
+
+// NewWsClient returns a client of the websocket api served at addr, e.g. ws://localhost:20335
+func NewWsClient(addr string) *WsClient {
+	client := &WsClient{
+		addr:    addr,
+		dialer:  websocket.DefaultDialer,
+		pending: make(map[string]chan *restResponse),
+		pushes:  make(chan *restResponse, WS_PUSH_QUEUE_SIZE),
+		closed:  make(chan struct{}),
+	}
+	client.restApi.transport = client
+	return client
+}
+
+// Connect dials the node, the client keeps reconnecting until it is closed
+func (self *WsClient) Connect() error {
+	conn, _, err := self.dialer.Dial(self.addr, nil)
+	if err != nil {
+		return fmt.Errorf("dial %s error: %s", self.addr, err)
+	}
+	self.lock.Lock()
+	self.conn = conn
+	self.lock.Unlock()
+
+	go self.run(conn)
+	go self.dispatch()
+	go self.heartbeat()
+	return nil
+}
+
+// Close closes the connection and stops reconnecting
+func (self *WsClient) Close() {
+	self.closeOnce.Do(func() {
+		close(self.closed)
+		self.lock.Lock()
+		conn := self.conn
+		self.conn = nil
+		self.lock.Unlock()
+		if conn != nil {
+			conn.Close()
+		}
+	})
+}
+
+// Subscribe replaces the subscription of the client, the subscription is restored after a reconnect
+func (self *WsClient) Subscribe(sub *WsSubscription) error {
+	self.lock.Lock()
+	self.subscription = sub
+	self.lock.Unlock()
+	return self.request("subscribe", sub.params(), nil)
+}
+
+// Unsubscribe stops all the pushes of the node
+func (self *WsClient) Unsubscribe() error {
+	return self.Subscribe(&WsSubscription{})
+}
+
+// GetSessionCount returns the number of websocket sessions of the node
+func (self *WsClient) GetSessionCount() (uint32, error) {
+	var count uint32
+	err := self.request("getsessioncount", nil, &count)
+	return count, err
+}
+
+func (self *WsClient) request(action string, params map[string]interface{}, result interface{}) error {
+	if !wsActions[action] {
+		return ErrNotSupported
+	}
+	self.lock.Lock()
+	conn := self.conn
+	if conn == nil {
+		self.lock.Unlock()
+		return ErrDisconnected
+	}
+	self.nextId++
+	id := strconv.FormatUint(self.nextId, 10)
+	ch := make(chan *restResponse, 1)
+	self.pending[id] = ch
+	self.lock.Unlock()
+	defer func() {
+		self.lock.Lock()
+		delete(self.pending, id)
+		self.lock.Unlock()
+	}()
+
+	req := map[string]interface{}{"Action": action, "Id": id, "Version": "1.0.0"}
+	for k, v := range params {
+		req[k] = v
+	}
+	self.writeLock.Lock()
+	conn.SetWriteDeadline(time.Now().Add(WS_REQUEST_TIMEOUT))
+	err := conn.WriteJSON(req)
+	self.writeLock.Unlock()
+	if err != nil {
+		return fmt.Errorf("send %s request error: %s", action, err)
+	}
+
+	timer := time.NewTimer(WS_REQUEST_TIMEOUT)
+	defer timer.Stop()
+	select {
+	case rsp := <-ch:
+		if rsp == nil {
+			return ErrDisconnected
+		}
+		if rsp.Error != 0 {
+			return restError(rsp)
+		}
+		return decodeResult(rsp.Result, result)
+	case <-timer.C:
+		return fmt.Errorf("%s request timeout", action)
+	case <-self.closed:
+		return ErrClientClosed
+	}
+}
+
+// run reads the connection, and reconnects when the connection is lost
+func (self *WsClient) run(conn *websocket.Conn) {
+	for {
+		self.read(conn)
+
+		self.lock.Lock()
+		if self.conn == conn {
+			self.conn = nil
+		}
+		for id, ch := range self.pending {
+			close(ch)
+			delete(self.pending, id)
+		}
+		self.lock.Unlock()
+		conn.Close()
+
+		conn = self.reconnect()
+		if conn == nil {
+			return
+		}
+		go self.resubscribe()
+	}
+}
+
+func (self *WsClient) read(conn *websocket.Conn) {
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			select {
+			case <-self.closed:
+			default:
+				log.Warnf("websocket client read error: %s", err)
+			}
+			return
+		}
+		rsp := &restResponse{}
+		if err := json.Unmarshal(data, rsp); err != nil {
+			log.Warnf("websocket client decode message %s error: %s", data, err)
+			continue
+		}
+		if id, ok := rsp.Id.(string); ok {
+			self.lock.Lock()
+			ch, ok := self.pending[id]
+			self.lock.Unlock()
+			if ok {
+				ch <- rsp
+				continue
+			}
+		}
+		select {
+		case self.pushes <- rsp:
+		case <-self.closed:
+			return
+		}
+	}
+}
+
+// reconnect dials the node until it succeeds or the client is closed
+func (self *WsClient) reconnect() *websocket.Conn {
+	delay := WS_MIN_RECONNECT_DELAY
+	for {
+		select {
+		case <-time.After(delay):
+		case <-self.closed:
+			return nil
+		}
+		conn, _, err := self.dialer.Dial(self.addr, nil)
+		if err == nil {
+			self.lock.Lock()
+			defer self.lock.Unlock()
+			select {
+			case <-self.closed:
+				conn.Close()
+				return nil
+			default:
+			}
+			self.conn = conn
+			log.Infof("websocket client reconnected to %s", self.addr)
+			return conn
+		}
+		log.Debugf("websocket client reconnect to %s error: %s", self.addr, err)
+		if delay *= 2; delay > WS_MAX_RECONNECT_DELAY {
+			delay = WS_MAX_RECONNECT_DELAY
+		}
+	}
+}
+
+func (self *WsClient) resubscribe() {
+	self.lock.Lock()
+	sub := self.subscription
+	self.lock.Unlock()
+	if sub == nil {
+		return
+	}
+	if err := self.request("subscribe", sub.params(), nil); err != nil && sub.OnError != nil {
+		sub.OnError(fmt.Errorf("resubscribe error: %s", err))
+	}
+}
+
+func (self *WsClient) heartbeat() {
+	ticker := time.NewTicker(WS_HEARTBEAT_INTERVAL)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := self.request("heartbeat", nil, nil); err != nil {
+				log.Debugf("websocket client heartbeat error: %s", err)
+			}
+		case <-self.closed:
+			return
+		}
+	}
+}
+
+// dispatch calls the handlers of the subscription with the pushed items
+func (self *WsClient) dispatch() {
+	for {
+		select {
+		case rsp := <-self.pushes:
+			self.lock.Lock()
+			sub := self.subscription
+			self.lock.Unlock()
+			if sub == nil {
+				continue
+			}
+			if err := sub.handle(rsp); err != nil && sub.OnError != nil {
+				sub.OnError(err)
+			}
+		case <-self.closed:
+			return
+		}
+	}
+}
+
+func (self *WsSubscription) handle(rsp *restResponse) error {
+	switch rsp.Action {
+	case event.EVENT_NOTIFY:
+		if self.OnEvent == nil {
+			return nil
+		}
+		notify := &bcomn.ExecuteNotify{}
+		if err := decodeResult(rsp.Result, notify); err != nil {
+			return err
+		}
+		evt, err := toExecuteNotify(notify)
+		if err != nil {
+			return err
+		}
+		self.OnEvent(evt)
+	case event.EVENT_LOG:
+		if self.OnLog == nil {
+			return nil
+		}
+		logEvt := &bcomn.LogEventArgs{}
+		if err := decodeResult(rsp.Result, logEvt); err != nil {
+			return err
+		}
+		evt, err := toLogEvent(logEvt)
+		if err != nil {
+			return err
+		}
+		self.OnLog(evt)
+	case "sendrawblock":
+		if self.OnBlock == nil {
+			return nil
+		}
+		var raw string
+		if err := decodeResult(rsp.Result, &raw); err != nil {
+			return err
+		}
+		block, err := hexToBlock(raw)
+		if err != nil {
+			return err
+		}
+		self.OnBlock(block)
+	case "sendjsonblock":
+		if self.OnJsonBlock == nil {
+			return nil
+		}
+		block := &bcomn.BlockInfo{}
+		if err := decodeResult(rsp.Result, block); err != nil {
+			return err
+		}
+		self.OnJsonBlock(block)
+	case "sendblocktxhashs":
+		if self.OnBlockTxHashes == nil {
+			return nil
+		}
+		txs := &BlockTxHashes{}
+		if err := decodeResult(rsp.Result, txs); err != nil {
+			return err
+		}
+		self.OnBlockTxHashes(txs)
+	}
+	return nil
+}
@@ -0,0 +1,217 @@
+/*
+ * Copyright (C) 2018 The cntmology Authors
+ * This file is part of The cntmology library.
+ *
+ * The cntmology is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The cntmology is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * alcntm with The cntmology.  If not, see <http://www.gnu.org/licenses/>.
+ */
+package debug
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/cntmio/cntmology/common/log"
+	bactor "github.com/cntmio/cntmology/http/base/actor"
+	"github.com/cntmio/cntmology/http/ethrpc/eth"
+	types2 "github.com/cntmio/cntmology/http/ethrpc/types"
+	utils2 "github.com/cntmio/cntmology/http/ethrpc/utils"
+	types3 "github.com/cntmio/cntmology/smartccntmract/service/evm/types"
+	"github.com/cntmio/cntmology/vm/evm"
+)
+
+// CallTracerName selects the call tree tracer instead of the struct logger
+const CallTracerName = "callTracer"
+
+// defaultTraceTimeout is the amount of time a trace runs before it is cancelled
+const defaultTraceTimeout = 5 * time.Second
+
+// maxTraceTimeout bounds the timeout a caller can request, longer timeouts are clamped to it
+const maxTraceTimeout = time.Minute
+
+// TraceConfig holds extra parameters to trace functions.
+type TraceConfig struct {
+	*evm.LogConfig
+	Tracer  *string
+	Timeout *string
+}
+
+// ExecutionResult groups all structured logs emitted by the EVM
+// while replaying a transaction in debug mode as well as transaction
+// execution status, the amount of gas used and the return value
+type ExecutionResult struct {
+	Gas         uint64         `json:"gas"`
+	Failed      bool           `json:"failed"`
+	ReturnValue string         `json:"returnValue"`
+	StructLogs  []StructLogRes `json:"structLogs"`
+}
+
+// StructLogRes stores a structured log emitted by the EVM while replaying a
+// transaction in debug mode
+type StructLogRes struct {
+	Pc      uint64             `json:"pc"`
+	Op      string             `json:"op"`
+	Gas     uint64             `json:"gas"`
+	GasCost uint64             `json:"gasCost"`
+	Depth   int                `json:"depth"`
+	Error   string             `json:"error,omitempty"`
+	Stack   *[]string          `json:"stack,omitempty"`
+	Memory  *[]string          `json:"memory,omitempty"`
+	Storage *map[string]string `json:"storage,omitempty"`
+}
+
+// PublicDebugAPI is the debug_ prefixed set of APIs, it replays evm executions with a tracer.
+type PublicDebugAPI struct{}
+
+// NewPublicDebugAPI creates an instance of the debug API.
+func NewPublicDebugAPI() *PublicDebugAPI {
+	return &PublicDebugAPI{}
+}
+
+// TraceTransaction returns the structured logs created during the execution of the EVM transaction
+// and returns them as a JSON object. The transaction is replayed on the state it was executed with,
+// which is only available for recent blocks.
+func (api *PublicDebugAPI) TraceTransaction(hash common.Hash, config *TraceConfig) (interface{}, error) {
+	log.Debugf("debug_traceTransaction hash %s", hash.Hex())
+	tracer, err := newTracer(config)
+	if err != nil {
+		return nil, err
+	}
+	timeout, err := traceTimeout(config)
+	if err != nil {
+		return nil, err
+	}
+	res, err := bactor.TraceTransaction(utils2.EthToOntHash(hash), evm.Config{Debug: true, Tracer: tracer}, timeout)
+	if err != nil {
+		return nil, err
+	}
+	return traceResult(tracer, res), nil
+}
+
+// TraceCall lets you trace a given eth_call. The call is executed on the state after the block
+// of blockNumber, the state of the past blocks needs the archive mode or to be recent.
+func (api *PublicDebugAPI) TraceCall(args types2.CallArgs, blockNumber types2.BlockNumber, config *TraceConfig) (interface{}, error) {
+	log.Debugf("debug_traceCall args %v ,block number %v ", args, blockNumber)
+	tracer, err := newTracer(config)
+	if err != nil {
+		return nil, err
+	}
+	timeout, err := traceTimeout(config)
+	if err != nil {
+		return nil, err
+	}
+	height := traceHeight(blockNumber, bactor.GetCurrentBlockHeight())
+	msg := args.AsMessage(eth.RPCGasCap)
+	res, err := bactor.TraceEip155Tx(msg, height, evm.Config{Debug: true, Tracer: tracer}, timeout)
+	if err != nil {
+		return nil, err
+	}
+	return traceResult(tracer, res), nil
+}
+
+func newTracer(config *TraceConfig) (evm.Tracer, error) {
+	if config == nil {
+		return evm.NewStructLogger(nil), nil
+	}
+	if config.Tracer != nil {
+		if *config.Tracer != CallTracerName {
+			return nil, fmt.Errorf("unsupported tracer %s, only %s is available", *config.Tracer, CallTracerName)
+		}
+		return evm.NewCallTracer(), nil
+	}
+	return evm.NewStructLogger(config.LogConfig), nil
+}
+
+func traceTimeout(config *TraceConfig) (time.Duration, error) {
+	if config == nil || config.Timeout == nil {
+		return defaultTraceTimeout, nil
+	}
+	timeout, err := time.ParseDuration(*config.Timeout)
+	if err != nil {
+		return 0, fmt.Errorf("invalid trace timeout %s: %v", *config.Timeout, err)
+	}
+	if timeout <= 0 {
+		return 0, fmt.Errorf("invalid trace timeout %s", *config.Timeout)
+	}
+	if timeout > maxTraceTimeout {
+		timeout = maxTraceTimeout
+	}
+	return timeout, nil
+}
+
+// traceHeight returns the height of the block whose state blockNumber is traced on
+func traceHeight(blockNumber types2.BlockNumber, current uint32) uint32 {
+	if blockNumber.IsLatest() || blockNumber.IsPending() || blockNumber < 0 {
+		return current
+	}
+	return uint32(blockNumber)
+}
+
+func traceResult(tracer evm.Tracer, res *types3.ExecutionResult) interface{} {
+	switch tracer := tracer.(type) {
+	case *evm.StructLogger:
+		returnVal := fmt.Sprintf("%x", res.Return())
+		// If the result contains a revert reason, return it.
+		if len(res.Revert()) > 0 {
+			returnVal = fmt.Sprintf("%x", res.Revert())
+		}
+		return &ExecutionResult{
+			Gas:         res.UsedGas,
+			Failed:      res.Failed(),
+			ReturnValue: returnVal,
+			StructLogs:  FormatLogs(tracer.StructLogs()),
+		}
+	case *evm.CallTracer:
+		return tracer.Result()
+	default:
+		panic(fmt.Sprintf("unknown tracer type %T", tracer))
+	}
+}
+
+// FormatLogs formats EVM returned structured logs for json output
+func FormatLogs(logs []evm.StructLog) []StructLogRes {
+	formatted := make([]StructLogRes, len(logs))
+	for index, trace := range logs {
+		formatted[index] = StructLogRes{
+			Pc:      trace.Pc,
+			Op:      trace.Op.String(),
+			Gas:     trace.Gas,
+			GasCost: trace.GasCost,
+			Depth:   trace.Depth,
+			Error:   trace.ErrorString(),
+		}
+		if trace.Stack != nil {
+			stack := make([]string, len(trace.Stack))
+			for i, stackValue := range trace.Stack {
+				stack[i] = fmt.Sprintf("%x", common.LeftPadBytes(stackValue.Bytes(), 32))
+			}
+			formatted[index].Stack = &stack
+		}
+		if trace.Memory != nil {
+			memory := make([]string, 0, (len(trace.Memory)+31)/32)
+			for i := 0; i+32 <= len(trace.Memory); i += 32 {
+				memory = append(memory, fmt.Sprintf("%x", trace.Memory[i:i+32]))
+			}
+			formatted[index].Memory = &memory
+		}
+		if trace.Storage != nil {
+			storage := make(map[string]string)
+			for i, storageValue := range trace.Storage {
+				storage[fmt.Sprintf("%x", i)] = fmt.Sprintf("%x", storageValue)
+			}
+			formatted[index].Storage = &storage
+		}
+	}
+	return formatted
+}
@@ -0,0 +1,117 @@
+/*
+ * Copyright (C) 2018 The cntmology Authors
+ * This file is part of The cntmology library.
+ *
+ * The cntmology is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The cntmology is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * alcntm with The cntmology.  If not, see <http://www.gnu.org/licenses/>.
+ */
+package debug
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	types2 "github.com/cntmio/cntmology/http/ethrpc/types"
+	types3 "github.com/cntmio/cntmology/smartccntmract/service/evm/types"
+	"github.com/cntmio/cntmology/vm/evm"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTraceTimeout(t *testing.T) {
+	timeout, err := traceTimeout(nil)
+	assert.Nil(t, err)
+	assert.Equal(t, defaultTraceTimeout, timeout)
+
+	timeout, err = traceTimeout(&TraceConfig{})
+	assert.Nil(t, err)
+	assert.Equal(t, defaultTraceTimeout, timeout)
+
+	value := "1s"
+	timeout, err = traceTimeout(&TraceConfig{Timeout: &value})
+	assert.Nil(t, err)
+	assert.Equal(t, time.Second, timeout)
+
+	value = "1h"
+	timeout, err = traceTimeout(&TraceConfig{Timeout: &value})
+	assert.Nil(t, err)
+	assert.Equal(t, maxTraceTimeout, timeout)
+
+	for _, value := range []string{"soon", "0s", "-1s"} {
+		value := value
+		_, err = traceTimeout(&TraceConfig{Timeout: &value})
+		assert.NotNil(t, err, value)
+	}
+}
+
+func TestTraceHeight(t *testing.T) {
+	assert.Equal(t, uint32(10), traceHeight(types2.LatestBlockNumber, 10))
+	assert.Equal(t, uint32(10), traceHeight(types2.PendingBlockNumber, 10))
+	assert.Equal(t, uint32(5), traceHeight(types2.BlockNumber(5), 10))
+	// heights above the current block are rejected by the ledger
+	assert.Equal(t, uint32(11), traceHeight(types2.BlockNumber(11), 10))
+}
+
+func TestNewTracer(t *testing.T) {
+	tracer, err := newTracer(nil)
+	assert.Nil(t, err)
+	assert.IsType(t, &evm.StructLogger{}, tracer)
+
+	name := CallTracerName
+	tracer, err = newTracer(&TraceConfig{Tracer: &name})
+	assert.Nil(t, err)
+	assert.IsType(t, &evm.CallTracer{}, tracer)
+
+	name = "jsTracer"
+	_, err = newTracer(&TraceConfig{Tracer: &name})
+	assert.NotNil(t, err)
+}
+
+func TestTraceResult(t *testing.T) {
+	res := &types3.ExecutionResult{UsedGas: 21000, ReturnData: []byte{0x12, 0x34}}
+	result := traceResult(evm.NewStructLogger(nil), res)
+	execution, ok := result.(*ExecutionResult)
+	assert.True(t, ok)
+	assert.Equal(t, uint64(21000), execution.Gas)
+	assert.False(t, execution.Failed)
+	assert.Equal(t, "1234", execution.ReturnValue)
+	assert.Empty(t, execution.StructLogs)
+
+	res = &types3.ExecutionResult{UsedGas: 21000, Err: errors.New("execution reverted")}
+	execution = traceResult(evm.NewStructLogger(nil), res).(*ExecutionResult)
+	assert.True(t, execution.Failed)
+}
+
+func TestFormatLogs(t *testing.T) {
+	logs := []evm.StructLog{{
+		Pc:      1,
+		Op:      evm.ADD,
+		Gas:     100,
+		GasCost: 3,
+		Depth:   1,
+		Stack:   []*big.Int{big.NewInt(1)},
+		Memory:  make([]byte, 40),
+		Storage: map[common.Hash]common.Hash{{1}: {2}},
+		Err:     errors.New("failed"),
+	}}
+	formatted := FormatLogs(logs)
+	assert.Equal(t, 1, len(formatted))
+	assert.Equal(t, "ADD", formatted[0].Op)
+	assert.Equal(t, "failed", formatted[0].Error)
+	assert.Equal(t, []string{common.BigToHash(big.NewInt(1)).Hex()[2:]}, *formatted[0].Stack)
+	// only complete words of memory are reported
+	assert.Equal(t, 1, len(*formatted[0].Memory))
+	assert.Equal(t, 1, len(*formatted[0].Storage))
+}
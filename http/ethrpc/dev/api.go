@@ -0,0 +1,100 @@
+/*
+ * Copyright (C) 2018 The cntmology Authors
+ * This file is part of The cntmology library.
+ *
+ * The cntmology is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The cntmology is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * alcntm with The cntmology.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package dev
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/cntmio/cntmology/common/log"
+	bactor "github.com/cntmio/cntmology/http/base/actor"
+)
+
+// PublicDevAPI is the evm_ prefixed set of APIs to control the dev chain, in the Hardhat and Ganache
+// flavor. It is only served when the node runs the dev chain.
+type PublicDevAPI struct{}
+
+// NewDevAPI creates an instance of the dev chain API.
+func NewDevAPI() *PublicDevAPI {
+	return &PublicDevAPI{}
+}
+
+func toUint32(name string, value uint64) (uint32, error) {
+	if value > math.MaxUint32 {
+		return 0, fmt.Errorf("%s %d out of range", name, value)
+	}
+	return uint32(value), nil
+}
+
+// Mine mines a block, at the timestamp if it is given.
+func (api *PublicDevAPI) Mine(timestamp *uint64) (string, error) {
+	log.Debug("evm_mine")
+	var blockTime uint32
+	if timestamp != nil {
+		var err error
+		if blockTime, err = toUint32("timestamp", *timestamp); err != nil {
+			return "", err
+		}
+	}
+	if _, err := bactor.DevMine(1, blockTime); err != nil {
+		return "", err
+	}
+	return "0x0", nil
+}
+
+// IncreaseTime shifts the time of the next blocks, and returns the total shift in seconds.
+func (api *PublicDevAPI) IncreaseTime(seconds uint64) (int64, error) {
+	log.Debug("evm_increaseTime")
+	value, err := toUint32("seconds", seconds)
+	if err != nil {
+		return 0, err
+	}
+	return bactor.DevIncreaseTime(value)
+}
+
+// SetNextBlockTimestamp sets the timestamp of the next block.
+func (api *PublicDevAPI) SetNextBlockTimestamp(timestamp uint64) error {
+	log.Debug("evm_setNextBlockTimestamp")
+	value, err := toUint32("timestamp", timestamp)
+	if err != nil {
+		return err
+	}
+	return bactor.DevSetNextBlockTimestamp(value)
+}
+
+// Snapshot saves the chain at the current block, and returns the snapshot id.
+func (api *PublicDevAPI) Snapshot() (hexutil.Uint64, error) {
+	log.Debug("evm_snapshot")
+	id, err := bactor.DevSnapshot()
+	return hexutil.Uint64(id), err
+}
+
+// Revert reverts the chain to the snapshot, the snapshot and the ones taken after it are dropped.
+func (api *PublicDevAPI) Revert(id hexutil.Uint64) (bool, error) {
+	log.Debug("evm_revert")
+	value, err := toUint32("snapshot id", uint64(id))
+	if err != nil {
+		return false, err
+	}
+	if _, err = bactor.DevRevert(value); err != nil {
+		return false, err
+	}
+	return true, nil
+}
@@ -47,6 +47,7 @@ import (
 	"github.com/cntmio/cntmology/smartccntmract/service/evm"
 	types3 "github.com/cntmio/cntmology/smartccntmract/service/evm/types"
 	"github.com/cntmio/cntmology/smartccntmract/service/native/utils"
+	"github.com/cntmio/cntmology/smartccntmract/storage"
 	errors2 "github.com/cntmio/cntmology/vm/evm/errors"
 	"github.com/cntmio/cntmology/vm/evm/params"
 )
@@ -82,9 +83,18 @@ func (api *EthereumAPI) BlockNumber() (hexutil.Uint64, error) {
 	return hexutil.Uint64(height), nil
 }
 
-func (api *EthereumAPI) GetBalance(address common.Address, _ rpc.BlockNumberOrHash) (*hexutil.Big, error) {
+func (api *EthereumAPI) GetBalance(address common.Address, blockNrOrHash rpc.BlockNumberOrHash) (*hexutil.Big, error) {
 	log.Debugf("eth_getBalance address %v", address.Hex())
-	balance, err := getOngBalance(address)
+	height, archived, err := blockNrOrHashHeight(blockNrOrHash)
+	if err != nil {
+		return (*hexutil.Big)(big.NewInt(0)), err
+	}
+	var balance states.NativeTokenBalance
+	if archived {
+		balance, err = getOngBalanceAt(address, height)
+	} else {
+		balance, err = getOngBalance(address)
+	}
 	if err != nil {
 		return (*hexutil.Big)(big.NewInt(0)), err
 	}
@@ -99,6 +109,48 @@ func getOngBalance(address common.Address) (states.NativeTokenBalance, error) {
 	return balances[0], nil
 }
 
+func getOngBalanceAt(address common.Address, height uint32) (states.NativeTokenBalance, error) {
+	balances, err := hComm.GetNativeTokenBalanceAt(0, []oComm.Address{utils.OngCcntmractAddress}, oComm.Address(address), height)
+	if err != nil {
+		return states.NativeTokenBalance{}, fmt.Errorf("get cntm balance at block %d error:%s", height, err)
+	}
+	return balances[0], nil
+}
+
+// stateHeight returns the height of the state of blockNum, and whether it is the state of a past
+// block, which is read from the archive
+func stateHeight(blockNum types2.BlockNumber) (uint32, bool, error) {
+	current := bactor.GetCurrentBlockHeight()
+	height := resolveBlockNumber(&blockNum, current)
+	if height > current {
+		return 0, false, fmt.Errorf("block %d not found", height)
+	}
+	return height, height < current, nil
+}
+
+// blockNrOrHashHeight is stateHeight for the go-ethereum block number or hash param
+func blockNrOrHashHeight(blockNrOrHash rpc.BlockNumberOrHash) (uint32, bool, error) {
+	if hash, ok := blockNrOrHash.Hash(); ok {
+		block, err := bactor.GetBlockFromStore(oComm.Uint256(hash))
+		if err != nil || block == nil {
+			return 0, false, fmt.Errorf("block %s not found", hash.Hex())
+		}
+		return stateHeight(types2.BlockNumber(block.Header.Height))
+	}
+	number, ok := blockNrOrHash.Number()
+	if !ok {
+		return stateHeight(types2.LatestBlockNumber)
+	}
+	switch number {
+	case rpc.LatestBlockNumber, rpc.PendingBlockNumber:
+		return stateHeight(types2.LatestBlockNumber)
+	case rpc.EarliestBlockNumber:
+		return 0, bactor.GetCurrentBlockHeight() > 0, nil
+	default:
+		return stateHeight(types2.BlockNumber(number))
+	}
+}
+
 func (api *EthereumAPI) ProtocolVersion() hexutil.Uint {
 	log.Debug("eth_protocolVersion")
 	return hexutil.Uint(ProtocolVersion)
@@ -144,6 +196,13 @@ func (api *EthereumAPI) Accounts() ([]common.Address, error) {
 
 func (api *EthereumAPI) GetStorageAt(address common.Address, key string, blockNum types2.BlockNumber) (hexutil.Bytes, error) {
 	log.Debugf("eth_getStorageAt address %v, key %s, blockNum %v", address.Hex(), key, blockNum)
+	height, archived, err := stateHeight(blockNum)
+	if err != nil {
+		return nil, err
+	}
+	if archived {
+		return bactor.GetEthStorageAt(address, common.HexToHash(key), height)
+	}
 	return bactor.GetEthStorage(address, common.HexToHash(key))
 }
 
@@ -198,7 +257,16 @@ func (api *EthereumAPI) GetUncleCountByBlockNumber(number int64) hexutil.Uint {
 
 func (api *EthereumAPI) GetCode(address common.Address, blockNumber types2.BlockNumber) (hexutil.Bytes, error) {
 	log.Debugf("eth_getCode address %s, blockNumber %v", address.Hex(), blockNumber)
-	account, err := bactor.GetEthAccount(address)
+	height, archived, err := stateHeight(blockNumber)
+	if err != nil {
+		return nil, err
+	}
+	var account *storage.EthAccount
+	if archived {
+		account, err = bactor.GetEthAccountAt(address, height)
+	} else {
+		account, err = bactor.GetEthAccount(address)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -234,7 +302,11 @@ func generateLog(rawNotify *event.ExecuteNotify) ([]*types.Log, *common.Hash, *o
 	}
 	hash := bactor.GetBlockHashFromStore(height)
 	ethHash := utils2.OntToEthHash(hash)
-	for idx, n := range rawNotify.Notify {
+	for _, n := range rawNotify.Notify {
+		// native notifies (e.g. gas fee transfer) have no evm log representation
+		if !n.IsEvm {
+			continue
+		}
 		storageLog, err := event.NotifyEventInfoToEvmLog(n)
 		if err != nil {
 			return nil, nil, nil, 0, err
@@ -248,7 +320,7 @@ func generateLog(rawNotify *event.ExecuteNotify) ([]*types.Log, *common.Hash, *o
 				TxHash:      utils2.OntToEthHash(txHash),
 				TxIndex:     uint(rawNotify.TxIndex),
 				BlockHash:   ethHash,
-				Index:       uint(idx),
+				Index:       uint(len(res)),
 				Removed:     false,
 			})
 	}
@@ -606,6 +678,54 @@ func (api *EthereumAPI) GetUncleByBlockNumberAndIndex(_ hexutil.Uint, _ hexutil.
 	return nil
 }
 
+// GetProof returns the state trie proofs of the account and the storage slots. Each proof is a single serialized
+// StorageProof of the native ledger, it is verified against the block header and the state merkle root of the
+// block, StorageHash is the state trie root. The balance is not covered by the account proof.
 func (api *EthereumAPI) GetProof(address common.Address, storageKeys []string, block types2.BlockNumber) (*types2.AccountResult, error) {
-	return nil, fmt.Errorf("eth_getProof is not supported")
+	log.Debugf("eth_getProof address %v, keys %v, blockNum %v", address.Hex(), storageKeys, block)
+	height, archived, err := stateHeight(block)
+	if err != nil {
+		return nil, err
+	}
+	accountProof, err := bactor.GetEthAccountProof(address, height)
+	if err != nil {
+		return nil, err
+	}
+	account := &storage.EthAccount{}
+	if len(accountProof.Value) != 0 {
+		if err := account.Deserialization(oComm.NewZeroCopySource(accountProof.Value)); err != nil {
+			return nil, err
+		}
+	}
+	var balance states.NativeTokenBalance
+	if archived {
+		balance, err = getOngBalanceAt(address, height)
+	} else {
+		balance, err = getOngBalance(address)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	storageProofs := make([]types2.StorageResult, 0, len(storageKeys))
+	for _, key := range storageKeys {
+		proof, err := bactor.GetEthStorageProof(address, common.HexToHash(key), height)
+		if err != nil {
+			return nil, err
+		}
+		storageProofs = append(storageProofs, types2.StorageResult{
+			Key:   key,
+			Value: (*hexutil.Big)(new(big.Int).SetBytes(proof.Value)),
+			Proof: []string{hexutil.Encode(oComm.SerializeToBytes(proof))},
+		})
+	}
+	return &types2.AccountResult{
+		Address:      address,
+		AccountProof: []string{hexutil.Encode(oComm.SerializeToBytes(accountProof))},
+		Balance:      (*hexutil.Big)(balance.ToBigInt()),
+		CodeHash:     account.CodeHash,
+		Nonce:        hexutil.Uint64(account.Nonce),
+		StorageHash:  common.Hash(accountProof.TrieRoot),
+		StorageProof: storageProofs,
+	}, nil
 }
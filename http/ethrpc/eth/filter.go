@@ -0,0 +1,221 @@
+/*
+ * Copyright (C) 2018 The cntmology Authors
+ * This file is part of The cntmology library.
+ *
+ * The cntmology is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The cntmology is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * alcntm with The cntmology.  If not, see <http://www.gnu.org/licenses/>.
+ */
+package eth
+
+import (
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	oComm "github.com/cntmio/cntmology/common"
+	sCom "github.com/cntmio/cntmology/core/store/common"
+	bactor "github.com/cntmio/cntmology/http/base/actor"
+	"github.com/cntmio/cntmology/smartccntmract/event"
+)
+
+// MaxFilterBlockRange is the max number of blocks a single log query may scan
+const MaxFilterBlockRange = 10000
+
+// Filter can be used to retrieve and filter logs.
+type Filter struct {
+	addresses []common.Address
+	topics    [][]common.Hash
+	matchers  [][]bloomIndexes // bloom indexes of the criteria, empty when the filter matches everything
+
+	block      *common.Hash // block hash if filtering a single block
+	begin, end uint32       // range interval if filtering multiple blocks
+}
+
+// NewRangeFilter creates a new filter which inspects the blocks between begin and end
+// (inclusive) to figure out whether a particular block is interesting or not.
+func NewRangeFilter(begin, end uint32, addresses []common.Address, topics [][]common.Hash) *Filter {
+	return &Filter{
+		addresses: addresses,
+		topics:    topics,
+		matchers:  newMatchers(addresses, topics),
+		begin:     begin,
+		end:       end,
+	}
+}
+
+// NewBlockFilter creates a new filter which directly inspects the contents of
+// a block to figure out whether it is interesting or not.
+func NewBlockFilter(block common.Hash, addresses []common.Address, topics [][]common.Hash) *Filter {
+	return &Filter{
+		addresses: addresses,
+		topics:    topics,
+		matchers:  newMatchers(addresses, topics),
+		block:     &block,
+	}
+}
+
+// Logs searches the blockchain for matching log entries, returning all from the
+// first block that contains matches.
+func (f *Filter) Logs() ([]*types.Log, error) {
+	if f.block != nil {
+		block, err := bactor.GetBlockFromStore(oComm.Uint256(*f.block))
+		if err != nil {
+			if err == sCom.ErrNotFound {
+				return nil, fmt.Errorf("unknown block %s", f.block.Hex())
+			}
+			return nil, err
+		}
+		if block == nil {
+			return nil, fmt.Errorf("unknown block %s", f.block.Hex())
+		}
+		return f.blockLogs(block.Header.Height)
+	}
+	if f.begin > f.end {
+		return nil, fmt.Errorf("invalid block range: from %d to %d", f.begin, f.end)
+	}
+	if f.end-f.begin >= MaxFilterBlockRange {
+		return nil, fmt.Errorf("block range from %d to %d exceeds limit of %d blocks", f.begin, f.end, MaxFilterBlockRange)
+	}
+	size, sections := bactor.BloomStatus()
+	var logs []*types.Log
+	for height := f.begin; ; height++ {
+		var found []*types.Log
+		var err error
+		if section := height / size; len(f.matchers) > 0 && section < sections {
+			// the rest of the section is checked at once with the bloom bits
+			last := (section+1)*size - 1
+			if last > f.end {
+				last = f.end
+			}
+			found, err = f.indexedLogs(section, size, height, last)
+			height = last
+		} else {
+			found, err = f.blockLogs(height)
+		}
+		if err != nil {
+			return nil, err
+		}
+		logs = append(logs, found...)
+		if height == f.end {
+			// avoid overflow when end is math.MaxUint32
+			break
+		}
+	}
+	return logs, nil
+}
+
+// indexedLogs returns the logs matching the filter criteria within the blocks begin to end
+// (inclusive) of a section, only the blocks selected by the section bloom bits are inspected.
+func (f *Filter) indexedLogs(section, size, begin, end uint32) ([]*types.Log, error) {
+	matches, err := matchSection(f.matchers, size, func(bit uint) ([]byte, error) {
+		return bactor.GetBloomBits(bit, section)
+	})
+	if err != nil {
+		return nil, err
+	}
+	var logs []*types.Log
+	for height := begin; height <= end; height++ {
+		if !bitsetContains(matches, height-section*size) {
+			continue
+		}
+		found, err := f.scanBlock(height)
+		if err != nil {
+			return nil, err
+		}
+		logs = append(logs, found...)
+	}
+	return logs, nil
+}
+
+// blockLogs returns the logs matching the filter criteria within a single block.
+func (f *Filter) blockLogs(height uint32) ([]*types.Log, error) {
+	if len(f.matchers) > 0 {
+		// a block without a saved bloom is always inspected
+		bloom, err := bactor.GetBlockBloom(height)
+		if err == nil && !bloomFilter(bloom, f.addresses, f.topics) {
+			return nil, nil
+		}
+	}
+	return f.scanBlock(height)
+}
+
+// scanBlock reads the event notifies of a block and returns the logs matching the filter criteria.
+func (f *Filter) scanBlock(height uint32) ([]*types.Log, error) {
+	notifies, err := bactor.GetEventNotifyByHeight(height)
+	if err != nil {
+		// blocks without any transaction have no event notify saved
+		if err == sCom.ErrNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var logs []*types.Log
+	for _, notify := range notifies {
+		if !hasEvmLog(notify) {
+			continue
+		}
+		txLogs, _, _, _, err := generateLog(notify)
+		if err != nil {
+			return nil, err
+		}
+		logs = append(logs, filterLogs(txLogs, f.addresses, f.topics)...)
+	}
+	return logs, nil
+}
+
+func hasEvmLog(notify *event.ExecuteNotify) bool {
+	for _, n := range notify.Notify {
+		if n.IsEvm {
+			return true
+		}
+	}
+	return false
+}
+
+func includes(addresses []common.Address, a common.Address) bool {
+	for _, addr := range addresses {
+		if addr == a {
+			return true
+		}
+	}
+	return false
+}
+
+// filterLogs creates a slice of logs matching the given criteria.
+func filterLogs(logs []*types.Log, addresses []common.Address, topics [][]common.Hash) []*types.Log {
+	var ret []*types.Log
+Logs:
+	for _, log := range logs {
+		if len(addresses) > 0 && !includes(addresses, log.Address) {
+			continue
+		}
+		// If the to filtered topics is greater than the amount of topics in logs, skip.
+		if len(topics) > len(log.Topics) {
+			continue Logs
+		}
+		for i, sub := range topics {
+			match := len(sub) == 0 // empty rule set == wildcard
+			for _, topic := range sub {
+				if log.Topics[i] == topic {
+					match = true
+					break
+				}
+			}
+			if !match {
+				continue Logs
+			}
+		}
+		ret = append(ret, log)
+	}
+	return ret
+}
@@ -0,0 +1,250 @@
+/*
+ * Copyright (C) 2018 The cntmology Authors
+ * This file is part of The cntmology library.
+ *
+ * The cntmology is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The cntmology is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * alcntm with The cntmology.  If not, see <http://www.gnu.org/licenses/>.
+ */
+package eth
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/cntmio/cntmology/common/log"
+	bactor "github.com/cntmio/cntmology/http/base/actor"
+	types2 "github.com/cntmio/cntmology/http/ethrpc/types"
+)
+
+// FilterTimeout is the time after which a filter which is not polled is uninstalled
+const FilterTimeout = 5 * time.Minute
+
+type filterType byte
+
+const (
+	logsFilter filterType = iota
+	blocksFilter
+)
+
+type filter struct {
+	typ      filterType
+	crit     types2.FilterCriteria
+	next     uint32 // next block height GetFilterChanges will inspect
+	lastPoll time.Time
+}
+
+// PublicFilterAPI offers support to create and manage filters. This will allow external clients to retrieve various
+// information related to the chain such as new blocks and logs.
+type PublicFilterAPI struct {
+	filtersMu sync.Mutex
+	filters   map[rpc.ID]*filter
+}
+
+// NewPublicFilterAPI returns a new PublicFilterAPI instance.
+func NewPublicFilterAPI() *PublicFilterAPI {
+	api := &PublicFilterAPI{
+		filters: make(map[rpc.ID]*filter),
+	}
+	go api.timeoutLoop()
+	return api
+}
+
+// timeoutLoop runs every 5 minutes and deletes filters that have not been recently used.
+func (api *PublicFilterAPI) timeoutLoop() {
+	ticker := time.NewTicker(FilterTimeout)
+	defer ticker.Stop()
+	for {
+		<-ticker.C
+		api.filtersMu.Lock()
+		for id, f := range api.filters {
+			if time.Since(f.lastPoll) > FilterTimeout {
+				log.Debugf("eth filter %s expired", id)
+				delete(api.filters, id)
+			}
+		}
+		api.filtersMu.Unlock()
+	}
+}
+
+// NewBlockFilter creates a filter that fetches blocks that are imported into the chain.
+// It is part of the filter package since polling goes with eth_getFilterChanges.
+func (api *PublicFilterAPI) NewBlockFilter() rpc.ID {
+	log.Debug("eth_newBlockFilter")
+	id := rpc.NewID()
+	api.filtersMu.Lock()
+	api.filters[id] = &filter{
+		typ:      blocksFilter,
+		next:     bactor.GetCurrentBlockHeight() + 1,
+		lastPoll: time.Now(),
+	}
+	api.filtersMu.Unlock()
+	return id
+}
+
+// NewFilter creates a new filter and returns the filter id. It can be
+// used to retrieve logs when the state changes. This method cannot be
+// used to fetch logs that are already stored in the state.
+func (api *PublicFilterAPI) NewFilter(crit types2.FilterCriteria) (rpc.ID, error) {
+	log.Debugf("eth_newFilter crit %v", crit)
+	if crit.BlockHash != nil {
+		return "", errors.New("blockHash is not supported by eth_newFilter")
+	}
+	current := bactor.GetCurrentBlockHeight()
+	begin, end := resolveRange(crit, current)
+	if crit.ToBlock != nil && begin > end {
+		return "", fmt.Errorf("invalid block range: from %d to %d", begin, end)
+	}
+	next := current + 1
+	if begin > next {
+		next = begin
+	}
+	id := rpc.NewID()
+	api.filtersMu.Lock()
+	api.filters[id] = &filter{
+		typ:      logsFilter,
+		crit:     crit,
+		next:     next,
+		lastPoll: time.Now(),
+	}
+	api.filtersMu.Unlock()
+	return id, nil
+}
+
+// GetLogs returns logs matching the given argument that are stored within the state.
+func (api *PublicFilterAPI) GetLogs(crit types2.FilterCriteria) ([]*types.Log, error) {
+	log.Debugf("eth_getLogs crit %v", crit)
+	var f *Filter
+	if crit.BlockHash != nil {
+		f = NewBlockFilter(*crit.BlockHash, crit.Addresses, crit.Topics)
+	} else {
+		begin, end := resolveRange(crit, bactor.GetCurrentBlockHeight())
+		f = NewRangeFilter(begin, end, crit.Addresses, crit.Topics)
+	}
+	logs, err := f.Logs()
+	if err != nil {
+		return nil, err
+	}
+	return returnLogs(logs), nil
+}
+
+// UninstallFilter removes the filter with the given filter id.
+func (api *PublicFilterAPI) UninstallFilter(id rpc.ID) bool {
+	log.Debugf("eth_uninstallFilter id %s", id)
+	api.filtersMu.Lock()
+	_, found := api.filters[id]
+	if found {
+		delete(api.filters, id)
+	}
+	api.filtersMu.Unlock()
+	return found
+}
+
+// GetFilterLogs returns the logs for the filter with the given id.
+// If the filter could not be found an empty array of logs is returned.
+func (api *PublicFilterAPI) GetFilterLogs(id rpc.ID) ([]*types.Log, error) {
+	log.Debugf("eth_getFilterLogs id %s", id)
+	api.filtersMu.Lock()
+	f, found := api.filters[id]
+	if found {
+		f.lastPoll = time.Now()
+	}
+	api.filtersMu.Unlock()
+
+	if !found || f.typ != logsFilter {
+		return nil, fmt.Errorf("filter not found")
+	}
+	begin, end := resolveRange(f.crit, bactor.GetCurrentBlockHeight())
+	logs, err := NewRangeFilter(begin, end, f.crit.Addresses, f.crit.Topics).Logs()
+	if err != nil {
+		return nil, err
+	}
+	return returnLogs(logs), nil
+}
+
+// GetFilterChanges returns the logs for the filter with the given id since
+// last time it was called. This can be used for polling.
+//
+// For block filters the result is []common.Hash. For log filters the result is []*types.Log.
+func (api *PublicFilterAPI) GetFilterChanges(id rpc.ID) (interface{}, error) {
+	log.Debugf("eth_getFilterChanges id %s", id)
+	current := bactor.GetCurrentBlockHeight()
+
+	api.filtersMu.Lock()
+	f, found := api.filters[id]
+	if !found {
+		api.filtersMu.Unlock()
+		return nil, fmt.Errorf("filter not found")
+	}
+	f.lastPoll = time.Now()
+	if f.typ == blocksFilter {
+		hashes := make([]common.Hash, 0)
+		for ; f.next <= current; f.next++ {
+			hashes = append(hashes, common.Hash(bactor.GetBlockHashFromStore(f.next)))
+		}
+		api.filtersMu.Unlock()
+		return hashes, nil
+	}
+	begin, crit := f.next, f.crit
+	_, end := resolveRange(crit, current)
+	if end > current {
+		end = current
+	}
+	if begin > end {
+		api.filtersMu.Unlock()
+		return []*types.Log{}, nil
+	}
+	// the rest of the range will be returned by the next poll
+	if end-begin >= MaxFilterBlockRange {
+		end = begin + MaxFilterBlockRange - 1
+	}
+	api.filtersMu.Unlock()
+
+	logs, err := NewRangeFilter(begin, end, crit.Addresses, crit.Topics).Logs()
+	if err != nil {
+		return nil, err
+	}
+	// the range is polled again after a failure, and a concurrent poll of the
+	// same range advances the filter only once
+	api.filtersMu.Lock()
+	if f.next == begin {
+		f.next = end + 1
+	}
+	api.filtersMu.Unlock()
+	return returnLogs(logs), nil
+}
+
+// resolveRange returns the block range selected by the criteria, unset bounds default to the latest block.
+func resolveRange(crit types2.FilterCriteria, current uint32) (uint32, uint32) {
+	return resolveBlockNumber(crit.FromBlock, current), resolveBlockNumber(crit.ToBlock, current)
+}
+
+func resolveBlockNumber(num *types2.BlockNumber, current uint32) uint32 {
+	if num == nil || num.IsLatest() || num.IsPending() {
+		return current
+	}
+	return uint32(*num)
+}
+
+// returnLogs is a helper that will return an empty log array in case the given logs array is nil,
+// otherwise the given logs array is returned.
+func returnLogs(logs []*types.Log) []*types.Log {
+	if logs == nil {
+		return []*types.Log{}
+	}
+	return logs
+}
@@ -0,0 +1,113 @@
+/*
+ * Copyright (C) 2018 The cntmology Authors
+ * This file is part of The cntmology library.
+ *
+ * The cntmology is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The cntmology is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * alcntm with The cntmology.  If not, see <http://www.gnu.org/licenses/>.
+ */
+package eth
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/bloombits"
+	"github.com/ethereum/go-ethereum/core/types"
+	types2 "github.com/cntmio/cntmology/http/ethrpc/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFilterLogs(t *testing.T) {
+	addr1 := common.HexToAddress("0x01")
+	addr2 := common.HexToAddress("0x02")
+	topic1 := common.HexToHash("0x11")
+	topic2 := common.HexToHash("0x12")
+	topic3 := common.HexToHash("0x13")
+
+	logs := []*types.Log{
+		{Address: addr1, Topics: []common.Hash{topic1}},
+		{Address: addr1, Topics: []common.Hash{topic1, topic2}},
+		{Address: addr2, Topics: []common.Hash{topic2, topic3}},
+		{Address: addr2},
+	}
+
+	assert.Equal(t, logs, filterLogs(logs, nil, nil))
+	assert.Equal(t, logs[:2], filterLogs(logs, []common.Address{addr1}, nil))
+	assert.Equal(t, logs[:2], filterLogs(logs, nil, [][]common.Hash{{topic1}}))
+	assert.Equal(t, logs[1:2], filterLogs(logs, nil, [][]common.Hash{{topic1}, {topic2}}))
+	assert.Equal(t, logs[1:3], filterLogs(logs, nil, [][]common.Hash{nil, {topic2, topic3}}))
+	assert.Equal(t, logs[2:3], filterLogs(logs, []common.Address{addr2}, [][]common.Hash{{topic2}}))
+	assert.Nil(t, filterLogs(logs, []common.Address{addr2}, [][]common.Hash{{topic1}}))
+}
+
+func TestResolveRange(t *testing.T) {
+	var crit types2.FilterCriteria
+	err := json.Unmarshal([]byte(`{"fromBlock":"0x10","toBlock":"latest"}`), &crit)
+	assert.Nil(t, err)
+	begin, end := resolveRange(crit, 100)
+	assert.Equal(t, uint32(16), begin)
+	assert.Equal(t, uint32(100), end)
+
+	begin, end = resolveRange(types2.FilterCriteria{}, 100)
+	assert.Equal(t, uint32(100), begin)
+	assert.Equal(t, uint32(100), end)
+}
+
+func TestRangeFilterLimit(t *testing.T) {
+	_, err := NewRangeFilter(10, 9, nil, nil).Logs()
+	assert.NotNil(t, err)
+	_, err = NewRangeFilter(0, MaxFilterBlockRange, nil, nil).Logs()
+	assert.NotNil(t, err)
+}
+
+func TestMatchSection(t *testing.T) {
+	addr1 := common.HexToAddress("0x01")
+	addr2 := common.HexToAddress("0x02")
+	topic1 := common.HexToHash("0x11")
+	topic2 := common.HexToHash("0x12")
+
+	const size = 16
+	blockLogs := map[uint][]*types.Log{
+		1: {{Address: addr1, Topics: []common.Hash{topic1}}},
+		5: {{Address: addr2, Topics: []common.Hash{topic2}}},
+		9: {{Address: addr1, Topics: []common.Hash{topic2}}},
+	}
+	gen, err := bloombits.NewGenerator(size)
+	assert.Nil(t, err)
+	for i := uint(0); i < size; i++ {
+		bloom := types.BytesToBloom(types.LogsBloom(blockLogs[i]))
+		assert.Nil(t, gen.AddBloom(i, bloom))
+		assert.Equal(t, len(blockLogs[i]) > 0, bloomFilter(bloom, nil, [][]common.Hash{{topic1, topic2}}))
+	}
+	getBits := func(bit uint) ([]byte, error) {
+		return gen.Bitset(bit)
+	}
+	matched := func(addresses []common.Address, topics [][]common.Hash) []uint32 {
+		bits, err := matchSection(newMatchers(addresses, topics), size, getBits)
+		assert.Nil(t, err)
+		var blocks []uint32
+		for i := uint32(0); i < size; i++ {
+			if bitsetContains(bits, i) {
+				blocks = append(blocks, i)
+			}
+		}
+		return blocks
+	}
+
+	assert.Equal(t, []uint32{1, 9}, matched([]common.Address{addr1}, nil))
+	assert.Equal(t, []uint32{5, 9}, matched(nil, [][]common.Hash{{topic2}}))
+	assert.Equal(t, []uint32{9}, matched([]common.Address{addr1}, [][]common.Hash{{topic2}}))
+	assert.Equal(t, []uint32{1, 5, 9}, matched([]common.Address{addr1, addr2}, [][]common.Hash{nil}))
+	assert.Nil(t, matched([]common.Address{addr2}, [][]common.Hash{{topic1}}))
+}
@@ -0,0 +1,139 @@
+/*
+ * Copyright (C) 2018 The cntmology Authors
+ * This file is part of The cntmology library.
+ *
+ * The cntmology is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The cntmology is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * alcntm with The cntmology.  If not, see <http://www.gnu.org/licenses/>.
+ */
+package eth
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/bitutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// bloomIndexes represents the bit indexes inside the bloom filter that belong
+// to some key.
+type bloomIndexes [3]uint
+
+// calcBloomIndexes returns the bloom filter bit indexes belonging to the given key.
+func calcBloomIndexes(b []byte) bloomIndexes {
+	b = crypto.Keccak256(b)
+
+	var idxs bloomIndexes
+	for i := 0; i < len(idxs); i++ {
+		idxs[i] = (uint(b[2*i])<<8)&2047 + uint(b[2*i+1])
+	}
+	return idxs
+}
+
+// newMatchers converts the filter criteria into groups of bloom indexes. A block may match if for
+// every group, one of the keys of the group has all its bits set in the block bloom.
+func newMatchers(addresses []common.Address, topics [][]common.Hash) [][]bloomIndexes {
+	var matchers [][]bloomIndexes
+	if len(addresses) > 0 {
+		group := make([]bloomIndexes, 0, len(addresses))
+		for _, addr := range addresses {
+			group = append(group, calcBloomIndexes(addr.Bytes()))
+		}
+		matchers = append(matchers, group)
+	}
+	for _, sub := range topics {
+		// empty rule set == wildcard
+		if len(sub) == 0 {
+			continue
+		}
+		group := make([]bloomIndexes, 0, len(sub))
+		for _, topic := range sub {
+			group = append(group, calcBloomIndexes(topic.Bytes()))
+		}
+		matchers = append(matchers, group)
+	}
+	return matchers
+}
+
+// bloomFilter checks the block bloom against the filter criteria.
+func bloomFilter(bloom types.Bloom, addresses []common.Address, topics [][]common.Hash) bool {
+	if len(addresses) > 0 {
+		var included bool
+		for _, addr := range addresses {
+			if types.BloomLookup(bloom, addr) {
+				included = true
+				break
+			}
+		}
+		if !included {
+			return false
+		}
+	}
+
+	for _, sub := range topics {
+		included := len(sub) == 0 // empty rule set == wildcard
+		for _, topic := range sub {
+			if types.BloomLookup(bloom, topic) {
+				included = true
+				break
+			}
+		}
+		if !included {
+			return false
+		}
+	}
+	return true
+}
+
+// matchSection returns a bitset of the blocks of a section of size blocks whose bloom may match,
+// getBits returns the bitset of one bloom bit for the section. Bit i of the result is the most
+// significant bit first, as produced by the bloombits generator.
+func matchSection(matchers [][]bloomIndexes, size uint32, getBits func(bit uint) ([]byte, error)) ([]byte, error) {
+	cache := make(map[uint][]byte)
+	result := filledBitset(size, 0xff)
+	for _, group := range matchers {
+		groupBits := filledBitset(size, 0)
+		for _, idxs := range group {
+			keyBits := filledBitset(size, 0xff)
+			for _, idx := range idxs {
+				bits, ok := cache[idx]
+				if !ok {
+					var err error
+					bits, err = getBits(idx)
+					if err != nil {
+						return nil, err
+					}
+					cache[idx] = bits
+				}
+				bitutil.ANDBytes(keyBits, keyBits, bits)
+			}
+			bitutil.ORBytes(groupBits, groupBits, keyBits)
+		}
+		bitutil.ANDBytes(result, result, groupBits)
+	}
+	return result, nil
+}
+
+// bitsetContains reports whether bit i of the bitset is set.
+func bitsetContains(bits []byte, i uint32) bool {
+	return bits[i/8]&(1<<(7-i%8)) != 0
+}
+
+func filledBitset(size uint32, b byte) []byte {
+	bits := make([]byte, size/8)
+	if b != 0 {
+		for i := range bits {
+			bits[i] = b
+		}
+	}
+	return bits
+}
@@ -0,0 +1,67 @@
+/*
+ * Copyright (C) 2018 The cntmology Authors
+ * This file is part of The cntmology library.
+ *
+ * The cntmology is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The cntmology is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * alcntm with The cntmology.  If not, see <http://www.gnu.org/licenses/>.
+ */
+package ethrpc
+
+import (
+	"net/http"
+	"strconv"
+
+	ethLog "github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rpc"
+	cfg "github.com/cntmio/cntmology/common/config"
+	"github.com/cntmio/cntmology/common/log"
+	"github.com/cntmio/cntmology/http/ethrpc/debug"
+	"github.com/cntmio/cntmology/http/ethrpc/dev"
+	"github.com/cntmio/cntmology/http/ethrpc/eth"
+	"github.com/cntmio/cntmology/http/ethrpc/utils"
+	"github.com/cntmio/cntmology/http/ethrpc/web3"
+)
+
+func StartEthServer(txpool eth.TxPoolService) error {
+	log.Infof("start eth rpc server")
+	ethLog.Root().SetHandler(utils.OntLogHandler())
+	server := rpc.NewServer()
+	err := server.RegisterName("eth", eth.NewEthereumAPI(txpool))
+	if err != nil {
+		return err
+	}
+	err = server.RegisterName("eth", eth.NewPublicFilterAPI())
+	if err != nil {
+		return err
+	}
+	err = server.RegisterName("web3", web3.NewAPI())
+	if err != nil {
+		return err
+	}
+	err = server.RegisterName("debug", debug.NewPublicDebugAPI())
+	if err != nil {
+		return err
+	}
+	if cfg.DefConfig.Genesis.SOLO != nil && cfg.DefConfig.Genesis.SOLO.DevMode {
+		err = server.RegisterName("evm", dev.NewDevAPI())
+		if err != nil {
+			return err
+		}
+	}
+
+	err = http.ListenAndServe(":"+strconv.Itoa(int(cfg.DefConfig.Rpc.EthJsonPort)), server)
+	if err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+/*
+ * Copyright (C) 2018 The cntmology Authors
+ * This file is part of The cntmology library.
+ *
+ * The cntmology is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The cntmology is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * alcntm with The cntmology.  If not, see <http://www.gnu.org/licenses/>.
+ */
+package types
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFilterCriteriaUnmarshal(t *testing.T) {
+	topic0 := "0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef"
+	topic1 := "0x000000000000000000000000000000000000000000000000000000000000abcd"
+	addr := "0x8f4f9c7ef6d0e6bd6b0d0d4b3b5f5e1a3c2d1e0f"
+
+	var crit FilterCriteria
+	input := `{"fromBlock":"0x1f","address":"` + addr + `","topics":["` + topic0 + `",null,["` + topic0 + `","` + topic1 + `"]]}`
+	assert.Nil(t, json.Unmarshal([]byte(input), &crit))
+	assert.Equal(t, BlockNumber(31), *crit.FromBlock)
+	assert.Nil(t, crit.ToBlock)
+	assert.Equal(t, []common.Address{common.HexToAddress(addr)}, crit.Addresses)
+	assert.Equal(t, 3, len(crit.Topics))
+	assert.Equal(t, []common.Hash{common.HexToHash(topic0)}, crit.Topics[0])
+	assert.Nil(t, crit.Topics[1])
+	assert.Equal(t, []common.Hash{common.HexToHash(topic0), common.HexToHash(topic1)}, crit.Topics[2])
+
+	crit = FilterCriteria{}
+	input = `{"address":["` + addr + `","` + addr + `"],"topics":[[null,"` + topic1 + `"]]}`
+	assert.Nil(t, json.Unmarshal([]byte(input), &crit))
+	assert.Equal(t, 2, len(crit.Addresses))
+	assert.Nil(t, crit.Topics[0])
+
+	input = `{"blockHash":"` + topic0 + `","fromBlock":"latest"}`
+	assert.NotNil(t, json.Unmarshal([]byte(input), &FilterCriteria{}))
+	input = `{"address":"0x1234"}`
+	assert.NotNil(t, json.Unmarshal([]byte(input), &FilterCriteria{}))
+}
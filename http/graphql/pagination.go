@@ -0,0 +1,209 @@
+/*
+ * Copyright (C) 2018 The cntmology Authors
+ * This file is part of The cntmology library.
+ *
+ * The cntmology is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The cntmology is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * alcntm with The cntmology.  If not, see <http://www.gnu.org/licenses/>.
+ */
+package graphql
+
+import (
+	"encoding/base64"
+	"fmt"
+
+	"github.com/cntmio/cntmology/http/base/actor"
+)
+
+const (
+	DEFAULT_PAGE_SIZE = 20
+	MAX_PAGE_SIZE     = 100
+	MAX_SCAN_BLOCKS   = 1000 // max blocks read by a request before returning a partial page
+)
+
+// overridden in tests
+var (
+	getBlockByHeight      = actor.GetBlockByHeight
+	getCurrentBlockHeight = actor.GetCurrentBlockHeight
+)
+
+type pageInfo struct {
+	HasNextPage bool
+	EndCursor   *string
+}
+
+type blockEdge struct {
+	Cursor string
+	Node   *block
+}
+
+type blockConnection struct {
+	Edges    []*blockEdge
+	PageInfo *pageInfo
+}
+
+type transactionEdge struct {
+	Cursor string
+	Node   *transaction
+}
+
+type transactionConnection struct {
+	Edges    []*transactionEdge
+	PageInfo *pageInfo
+}
+
+type pageArgs struct {
+	From  Uint32
+	To    *Uint32
+	First *int32
+	After *string
+}
+
+// pageSize returns the number of edges requested, bounded by MAX_PAGE_SIZE
+func (self *pageArgs) pageSize() (int, error) {
+	if self.First == nil {
+		return DEFAULT_PAGE_SIZE, nil
+	}
+	if *self.First <= 0 || *self.First > MAX_PAGE_SIZE {
+		return 0, fmt.Errorf("first must be in range [1, %d]", MAX_PAGE_SIZE)
+	}
+	return int(*self.First), nil
+}
+
+// lastHeight returns the height of the last block of the range, bounded by the current height
+func (self *pageArgs) lastHeight(current uint32) uint32 {
+	if self.To != nil && uint32(*self.To) < current {
+		return uint32(*self.To)
+	}
+	return current
+}
+
+// after returns the position decoded from the After cursor, which must lie in the range of the request
+func (self *pageArgs) after(current uint32) (height uint32, index uint32, ok bool, err error) {
+	if self.After == nil {
+		return 0, 0, false, nil
+	}
+	height, index, err = decodeCursor(*self.After)
+	if err != nil {
+		return 0, 0, false, err
+	}
+	if height < uint32(self.From) || height > self.lastHeight(current) {
+		return 0, 0, false, fmt.Errorf("cursor %s out of range", *self.After)
+	}
+	return height, index, true, nil
+}
+
+// the cursor of a transaction is the height of its block and its index in the block,
+// the cursor of a block is its height, with a zero index.
+// a page ending after MAX_SCAN_BLOCKS uses the tx count of the last scanned block as index
+func encodeCursor(height uint32, index uint32) string {
+	return base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("%d:%d", height, index)))
+}
+
+func decodeCursor(cursor string) (height uint32, index uint32, err error) {
+	raw, err := base64.StdEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid cursor %s", cursor)
+	}
+	if _, err = fmt.Sscanf(string(raw), "%d:%d", &height, &index); err != nil {
+		return 0, 0, fmt.Errorf("invalid cursor %s", cursor)
+	}
+	if encodeCursor(height, index) != cursor {
+		return 0, 0, fmt.Errorf("invalid cursor %s", cursor)
+	}
+	return height, index, nil
+}
+
+func (self *resolver) Blocks(args pageArgs) (*blockConnection, error) {
+	size, err := args.pageSize()
+	if err != nil {
+		return nil, err
+	}
+	current := getCurrentBlockHeight()
+	start := uint32(args.From)
+	height, _, ok, err := args.after(current)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		start = height + 1
+	}
+
+	end := args.lastHeight(current)
+	conn := &blockConnection{PageInfo: &pageInfo{}}
+	for height := start; height <= end; height++ {
+		if len(conn.Edges) == size {
+			conn.PageInfo.HasNextPage = true
+			break
+		}
+		b, err := getBlockByHeight(height)
+		if err != nil {
+			return nil, err
+		}
+		cursor := encodeCursor(height, 0)
+		conn.Edges = append(conn.Edges, &blockEdge{Cursor: cursor, Node: NewBlock(b)})
+		conn.PageInfo.EndCursor = &cursor
+	}
+
+	return conn, nil
+}
+
+func (self *resolver) Transactions(args pageArgs) (*transactionConnection, error) {
+	size, err := args.pageSize()
+	if err != nil {
+		return nil, err
+	}
+	current := getCurrentBlockHeight()
+	start, first := uint32(args.From), uint32(0)
+	height, index, ok, err := args.after(current)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		start, first = height, index+1
+	}
+
+	end := args.lastHeight(current)
+	conn := &transactionConnection{PageInfo: &pageInfo{}}
+	scanned, count := 0, uint32(0)
+	for height := start; height <= end && !conn.PageInfo.HasNextPage; height++ {
+		if scanned == MAX_SCAN_BLOCKS {
+			// resume after the last scanned block, which may hold no edge of this page
+			cursor := encodeCursor(height-1, count)
+			conn.PageInfo.HasNextPage = true
+			conn.PageInfo.EndCursor = &cursor
+			break
+		}
+		b, err := getBlockByHeight(height)
+		if err != nil {
+			return nil, err
+		}
+		scanned += 1
+		count = uint32(len(b.Transactions))
+		if first > count+1 {
+			return nil, fmt.Errorf("cursor %s out of range", *args.After)
+		}
+		for index := first; index < count; index++ {
+			if len(conn.Edges) == size {
+				conn.PageInfo.HasNextPage = true
+				break
+			}
+			cursor := encodeCursor(height, index)
+			tx := NewTransaction(b.Transactions[index], height)
+			conn.Edges = append(conn.Edges, &transactionEdge{Cursor: cursor, Node: tx})
+			conn.PageInfo.EndCursor = &cursor
+		}
+		first = 0
+	}
+
+	return conn, nil
+}
@@ -0,0 +1,170 @@
+/*
+ * Copyright (C) 2018 The cntmology Authors
+ * This file is part of The cntmology library.
+ *
+ * The cntmology is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The cntmology is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * alcntm with The cntmology.  If not, see <http://www.gnu.org/licenses/>.
+ */
+package graphql
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/cntmio/cntmology/core/payload"
+	"github.com/cntmio/cntmology/core/types"
+	"github.com/cntmio/cntmology/http/base/actor"
+	"github.com/stretchr/testify/assert"
+)
+
+// setupChain serves blocks from txCounts, with txCounts[h] transactions in block h,
+// and returns the function restoring the ledger
+func setupChain(txCounts []int) func() {
+	getBlockByHeight = func(height uint32) (*types.Block, error) {
+		if int(height) >= len(txCounts) {
+			return nil, fmt.Errorf("unknown block %d", height)
+		}
+		b := &types.Block{Header: &types.Header{Height: height}}
+		for i := 0; i < txCounts[height]; i++ {
+			b.Transactions = append(b.Transactions, &types.Transaction{Payload: &payload.InvokeCode{}})
+		}
+		return b, nil
+	}
+	getCurrentBlockHeight = func() uint32 {
+		return uint32(len(txCounts) - 1)
+	}
+	return func() {
+		getBlockByHeight = actor.GetBlockByHeight
+		getCurrentBlockHeight = actor.GetCurrentBlockHeight
+	}
+}
+
+func pageOf(from uint32, first int32, after *string) pageArgs {
+	return pageArgs{From: Uint32(from), First: &first, After: after}
+}
+
+func TestDecodeCursor(t *testing.T) {
+	height, index, err := decodeCursor(encodeCursor(12, 3))
+	assert.Nil(t, err)
+	assert.Equal(t, uint32(12), height)
+	assert.Equal(t, uint32(3), index)
+
+	for _, raw := range []string{"12", "12:3x", "-1:0", "12:-3", "4294967296:0", " 12:3"} {
+		_, _, err = decodeCursor(base64.StdEncoding.EncodeToString([]byte(raw)))
+		assert.NotNil(t, err, raw)
+	}
+	_, _, err = decodeCursor("not base64!")
+	assert.NotNil(t, err)
+}
+
+func TestBlocksPage(t *testing.T) {
+	defer setupChain(make([]int, 10))()
+	r := &resolver{}
+
+	conn, err := r.Blocks(pageOf(2, 3, nil))
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(conn.Edges))
+	assert.Equal(t, Uint32(2), conn.Edges[0].Node.Header.Height)
+	assert.True(t, conn.PageInfo.HasNextPage)
+
+	conn, err = r.Blocks(pageOf(2, 10, conn.PageInfo.EndCursor))
+	assert.Nil(t, err)
+	assert.Equal(t, 5, len(conn.Edges))
+	assert.Equal(t, Uint32(5), conn.Edges[0].Node.Header.Height)
+	assert.False(t, conn.PageInfo.HasNextPage)
+
+	// cursors outside of the requested range
+	for _, cursor := range []string{encodeCursor(1, 0), encodeCursor(10, 0), encodeCursor(0xffffffff, 0)} {
+		_, err = r.Blocks(pageOf(2, 3, &cursor))
+		assert.NotNil(t, err)
+	}
+	_, err = r.Blocks(pageOf(0, MAX_PAGE_SIZE+1, nil))
+	assert.NotNil(t, err)
+}
+
+func TestTransactionsPage(t *testing.T) {
+	defer setupChain([]int{0, 2, 0, 3, 1})()
+	r := &resolver{}
+
+	var heights []Uint32
+	var after *string
+	for pages := 0; ; pages++ {
+		conn, err := r.Transactions(pageOf(0, 2, after))
+		assert.Nil(t, err)
+		for _, edge := range conn.Edges {
+			heights = append(heights, edge.Node.Height)
+		}
+		if !conn.PageInfo.HasNextPage {
+			assert.Equal(t, 2, pages)
+			break
+		}
+		after = conn.PageInfo.EndCursor
+	}
+	assert.Equal(t, []Uint32{1, 1, 3, 3, 3, 4}, heights)
+
+	// index past the transactions of the block
+	cursor := encodeCursor(1, 5)
+	_, err := r.Transactions(pageOf(0, 2, &cursor))
+	assert.NotNil(t, err)
+}
+
+func TestTransactionsScanLimit(t *testing.T) {
+	txCounts := make([]int, 2*MAX_SCAN_BLOCKS+10)
+	txCounts[len(txCounts)-1] = 1
+	defer setupChain(txCounts)()
+	r := &resolver{}
+
+	// empty blocks end the page after MAX_SCAN_BLOCKS, the cursor resumes after them
+	conn, err := r.Transactions(pageOf(0, 10, nil))
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(conn.Edges))
+	assert.True(t, conn.PageInfo.HasNextPage)
+	height, _, err := decodeCursor(*conn.PageInfo.EndCursor)
+	assert.Nil(t, err)
+	assert.Equal(t, uint32(MAX_SCAN_BLOCKS-1), height)
+
+	conn, err = r.Transactions(pageOf(0, 10, conn.PageInfo.EndCursor))
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(conn.Edges))
+	assert.True(t, conn.PageInfo.HasNextPage)
+
+	conn, err = r.Transactions(pageOf(0, 10, conn.PageInfo.EndCursor))
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(conn.Edges))
+	assert.Equal(t, Uint32(len(txCounts)-1), conn.Edges[0].Node.Height)
+	assert.False(t, conn.PageInfo.HasNextPage)
+}
+
+func TestCheckOrigin(t *testing.T) {
+	request := func(host, origin string) *http.Request {
+		r := &http.Request{Host: host, Header: make(http.Header)}
+		if origin != "" {
+			r.Header.Set("Origin", origin)
+		}
+		return r
+	}
+
+	check := checkOrigin(nil)
+	assert.True(t, check(request("node:20333", "")))
+	assert.True(t, check(request("node:20333", "http://node:20333")))
+	assert.False(t, check(request("node:20333", "http://evil.com")))
+
+	check = checkOrigin([]string{"https://explorer.io"})
+	assert.True(t, check(request("node:20333", "https://explorer.io")))
+	assert.False(t, check(request("node:20333", "http://explorer.io")))
+
+	check = checkOrigin([]string{"*"})
+	assert.True(t, check(request("node:20333", "http://evil.com")))
+}
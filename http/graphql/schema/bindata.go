@@ -96,7 +96,7 @@ func (fi bindataFileInfo) Sys() interface{} {
 	return nil
 }
 
-var _schemaGraphql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x94\x55\x4d\x6f\xdb\x30\x0c\xbd\xfb\x57\xb0\xc8\xa5\xbb\xf4\xd0\xb5\xc5\xe0\x5b\xb3\x16\x48\xd1\xaf\x6c\xc9\x36\x0c\x45\x31\x30\x32\x6b\x0b\xb1\x25\x4f\x94\xd3\x18\x45\xff\xfb\x20\xcb\x76\xe5\xc4\x1d\xd0\x53\x22\xea\xf1\xe9\x3d\x92\x92\x27\xb0\x42\xa6\xd3\x2f\xa0\x0d\x64\xb4\x05\xb6\x46\xaa\x14\x30\x49\x0c\x31\x47\x2c\x30\x47\x03\xe7\xed\x72\x12\x62\xf4\x13\x64\xc8\xd9\xf1\xe9\x59\x07\x9b\xb9\xff\xbb\x98\xb2\x5a\xe5\x52\xc0\x9a\xea\x0e\x36\xaf\x56\xd7\x54\x47\xdd\xf2\x87\x54\xf6\xf3\x71\x34\x81\x4a\x2a\x7b\x76\x02\xa4\x84\x4e\x28\x01\xe4\x96\x25\x04\x9e\x9d\x44\x11\xa9\xaa\x80\xe5\x76\x59\x97\x04\x2f\x11\x00\xc0\xd5\xdd\xcf\xfb\xeb\xcb\x3f\x77\x97\xf7\xe1\xf2\xd7\xf9\xe2\xb6\x59\x5f\x5c\xce\x6f\xee\x7f\xf7\xdb\xed\xb2\xd9\x7e\x8d\x22\xa9\x2c\x99\x27\x14\x04\x73\xac\x73\x8d\x49\x4b\xea\x54\x40\x0c\x8b\x46\xc3\x81\x43\x5a\x77\xe2\x95\xda\xe8\x35\x7d\x75\x9b\xb2\x28\x73\x2a\x48\x59\x1e\x49\xdd\xcf\xbc\xa0\x32\xd7\xf5\x47\x32\x5d\x64\x53\x38\xa3\xc3\x98\xc2\x62\x17\x45\x86\xa5\x56\xc3\x20\x56\x36\xd3\x66\x18\xa3\x02\x65\x3e\x0c\x25\xc4\x62\xa0\x76\x02\xd6\xa0\x62\x14\x56\x6a\xe5\x9a\x50\x09\x5b\x19\x72\xdd\xd4\xca\xea\x5c\xa7\xb5\x77\xb4\x0c\x60\x2f\x43\x1d\xbe\xab\xfe\x00\x37\x26\x71\x33\x1d\xad\x7c\xad\x04\x0d\x21\x76\xeb\x5d\xfa\xb6\xfa\x58\x8a\x3c\x37\x72\x17\x99\x22\xdf\xc8\x42\xda\x61\xb4\xc4\x9a\x4c\xdc\x0d\x6a\x1f\x73\x95\x8d\xbb\x12\xfb\x28\xcb\x94\x63\x78\x58\xc8\xf4\xe0\xf1\x20\xf2\xfa\x48\xa6\x59\x40\xd8\x35\x6c\x21\xd3\xd6\x16\xcb\xf4\x02\x2d\xba\x3c\x5f\xa6\xc7\xf6\x88\x66\x94\x1d\x9f\x1f\xea\x2e\x7e\x3b\x20\x9b\xc0\x34\xd7\x62\xfd\xbf\x4a\x7a\x80\x3f\x6c\x02\xcb\x8c\x20\x23\x4c\xc8\x38\xa4\xcd\x24\xc3\xca\x01\x8e\x5a\xb9\x6e\x27\x86\x59\xf3\xdb\x7a\xf0\x49\x41\xdf\x38\xc8\x03\xa9\x44\x5e\x25\x94\x78\x82\x10\x15\xc3\x43\xd0\x45\xa7\xbf\x11\xec\xb9\x41\x3a\x96\x50\x0b\x7a\x42\x2f\xba\x05\x85\xaa\xdb\xfe\xf7\xb2\x7d\xe6\xd1\xf8\x6c\x84\x6e\x91\xb3\xd1\xa4\x70\x76\x02\x7c\x69\x68\x23\x75\xd5\xf9\x73\x28\x8f\x77\x1b\xb3\xf1\x1c\x51\x19\x43\xca\x76\x29\x4d\xd3\x8f\x46\x07\x20\xac\xa8\x2c\x88\x2d\x16\x65\x58\xce\x94\x14\x19\xb4\x7d\x3d\x3b\xcc\x28\x43\x41\x66\x9d\xbb\xd6\x10\x81\xd1\xda\xc2\xb3\xb4\x19\xe4\x84\x1b\x62\x78\x32\xba\x68\xe8\xb8\x27\xb7\x7a\xaf\xe3\xcd\xdf\xef\x5a\xdb\x11\x57\x83\x96\x37\xfc\x23\x23\x63\xb7\xfc\x4e\xba\xd0\x8a\x49\x71\xc5\x90\xa0\xc5\xb1\xdc\x1e\xe1\x6f\x80\x7f\x85\x87\x0e\xab\xdc\xca\xee\x9b\xe1\x28\x84\x56\xba\x65\x55\x3a\x21\x86\xe7\x4c\x83\x40\xd5\x17\x0e\x14\x6d\x6d\x78\x88\x5b\x4f\xb5\x5e\xaf\x89\xca\xc1\x45\x1e\x4a\xdd\x67\xed\x19\xf7\x6a\xd6\xb3\x0d\xaf\x67\x40\xc8\x32\x55\xd8\xdd\xc7\x8f\xb1\x8f\x3d\x08\xdd\xb3\x31\xc5\x1c\x95\xe8\xbe\x4d\x5a\xd9\xb7\xa2\xf9\x40\x3a\x0c\xbc\xf7\xfc\x7c\xab\xc8\xd4\x2d\x4b\x4a\xb6\x79\x22\xa6\xf5\xac\x41\x1f\xee\x24\x7d\x8a\xfd\x13\xb2\x0b\x46\xce\x0e\x83\x2b\x34\x0a\xf3\xa0\x3d\xbe\xb7\xf7\x3a\x25\xbb\xdc\xee\xd0\x04\x8f\x46\x4f\xe6\x7d\x1f\xba\x41\x78\xeb\xa0\x3b\xd2\x6f\x34\xce\x58\x64\x54\x60\xeb\xea\xaf\x73\x18\x7b\xa3\xd1\x6b\xf4\x2f\x00\x00\xff\xff\x28\x4d\x93\xb3\x8d\x08\x00\x00")
+var _schemaGraphql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x02\xff\xb5\x57\x5b\x6f\xdb\x36\x14\x7e\xf7\xaf\x60\xe0\x17\x07\x30\x82\xad\x6b\x83\xc1\x6f\x4d\x62\x20\x59\x9b\x4b\xeb\x74\xc5\x50\x14\x03\x2d\x1d\xdb\x44\x24\x52\x23\x29\x47\x46\xb1\xff\xbe\xc3\xab\x48\x59\x19\xda\x01\xcb\x4b\x44\xf2\x9c\x8f\xe7\xfa\xf1\x78\x4a\xd6\x54\xc1\x9b\x5f\x89\x90\x64\x07\x1d\x51\x5a\x32\xbe\x25\xb4\x2c\x25\x28\x35\x51\x05\xad\xa8\x24\x6f\xfd\x72\x9a\xca\x88\x0d\xd9\x51\xb5\x7b\xf5\xe6\x3c\x88\x5d\x9b\xef\xa1\x4c\xd3\xae\x2b\x56\x90\x27\x38\x04\xb1\x87\x76\xfd\x0e\x57\x61\xf9\x89\x71\xfd\xcb\x2b\xd4\x6b\xf1\xe3\xfc\x35\x01\x5e\x88\x12\x4a\x42\x95\x47\x49\x05\xcf\x5f\x4f\x26\xc0\xdb\x9a\x3c\x76\x8f\x87\x06\xc8\xb7\x09\xc1\xbf\x9b\xbb\xdf\xef\xdf\x2d\xff\xbc\x5b\xde\xa7\xcb\xcf\x6f\x57\xb7\x76\x7d\xb5\x7c\x78\x7f\xff\x47\x3c\xf6\x4b\x7b\xfc\xf7\x64\x82\xa8\x20\x37\xb4\x00\xf2\x40\x0f\x95\xa0\xa5\x07\x35\x56\x90\x05\x59\x59\x1b\x4e\x8c\xa4\x36\x37\xde\xf0\xbd\x78\x82\x4b\x73\xc8\xea\xa6\x82\x1a\xb8\x56\x23\xaa\xc7\x9a\x57\xd0\x54\xe2\xf0\x23\x9a\x66\x67\x5f\x1b\x47\xf3\x3d\x4e\xeb\xa1\x14\x48\xc5\x04\xcf\x37\x69\xab\x77\x42\xe6\x7b\x50\x53\x56\xe5\x5b\x25\xa8\x22\xb3\x76\x4a\xb4\xa4\x5c\xd1\x42\x23\xa4\x49\x42\x5b\xe8\x56\x82\xc9\x66\xc1\x75\x2d\x2a\xb1\x3d\x38\x97\x1e\x13\xb9\x6f\xb9\x21\x2e\xad\xee\x06\x53\x27\x0b\x5b\x1e\xde\x7e\xc1\x0b\xc8\x45\x74\xe7\xdc\x74\x79\x75\x7b\x5b\xaa\x1e\x24\x1b\x4a\xe2\xee\x7b\x56\x33\x9d\xef\x36\xf4\x00\xe8\xaa\xaf\xd4\xb8\x67\x42\xbb\x08\x31\x76\xbb\x8a\x6d\xd5\x82\x7c\x59\xb1\xed\xc9\xd7\x93\x89\xb3\x0f\xd8\x76\x97\x00\x86\x8c\xa1\x8c\x77\x0b\x95\xae\xa8\xa6\x46\xcf\xc5\xe9\xab\xbf\xc2\xd6\xb2\xc1\x73\x55\x1d\xf6\x6f\x33\xb0\x29\xb9\xa8\x44\xf1\xf4\xaf\xa1\x74\x12\xee\xb6\x29\x79\xdc\x01\x5a\x45\x4b\x90\x46\x54\xef\x98\x22\x6b\x23\x70\xe6\xed\x35\x27\x18\x51\xfb\xdf\x3b\xe1\x94\x92\xcc\xa9\x44\x8f\x30\x5e\x54\x2d\xb6\x95\x03\x48\xa5\xd0\xf6\x24\x8d\xc6\x01\x6b\xb1\xc3\x26\xcc\xa0\xa4\xb6\x50\x07\xe8\x8c\xf6\x42\xa9\xd5\xbe\x00\xa2\xd9\x4e\xf3\x6c\xbc\x38\x52\x6f\xb1\x46\x46\x95\xd2\xe2\x49\xe4\x1b\x09\x7b\x26\xda\xe0\x9f\x91\x72\xf2\xe6\xe0\x7a\x5c\xa7\x68\xa5\xc4\xa6\x0b\x2a\x36\xeb\x67\xa3\x15\x90\x46\x94\xd5\xa0\x34\xad\x9b\x34\x9c\x5b\xe0\x20\xa9\x8e\xf1\x0c\x32\xa3\x08\x35\xc8\xa7\xca\xa4\x06\x80\x48\x21\x34\x79\x66\x7a\x47\x2a\xa0\x7b\x50\x64\x23\x45\x6d\xe1\x54\x04\xd7\xe2\x28\xe3\xf6\xf3\x23\xea\x8e\x78\x95\xa5\xdc\xe2\x8f\x94\x8c\xee\xd4\x0b\xea\x05\xaa\x01\x57\x18\xc9\x12\x2b\x7c\x4c\x37\x4a\xb8\x16\x70\x3c\x9c\x7b\xd8\x56\x9a\x85\x57\xc3\x16\xb7\xe0\xc2\xa3\x72\xa4\x34\x45\x9e\x77\x82\x14\x94\xc7\xc0\x11\x0e\x9d\x4e\x2f\x31\xeb\x0b\x21\x9e\x9e\x00\x9a\xac\x93\x73\x53\x8f\x51\x23\xe2\x51\xcc\x22\x5a\xde\x9f\x09\x20\xb6\x35\xa7\xb1\x21\x7f\x08\x7d\x8c\x11\x02\x6f\x5c\xe0\x7b\x85\x0c\x17\x38\x1d\x1b\xbd\x8f\xda\xf8\xce\x08\x03\x4d\xc9\x9d\xd0\x6c\x73\x40\xca\x66\x1a\x2b\x8d\xac\x0f\xd8\x7c\x68\x24\xe6\xbb\xd0\xa4\x6c\xdd\x4b\x9d\x71\x35\x74\x50\xb4\xe6\xcb\xd9\xe1\x00\x96\x7b\x53\xf3\xe1\x7d\x71\xea\x3e\xba\x03\xc2\x9c\x92\xdf\x56\xf7\x77\xf1\x05\xc6\x8a\xd6\xa0\x5c\x45\x60\xc2\x2c\x98\xf3\xdd\x1e\xe4\xaf\x08\x53\xcb\x3d\x3a\x85\x29\xc4\xc2\xe6\xde\x83\x65\xb0\x87\xe0\x25\x58\x23\x84\xf2\xd2\x01\x31\x07\x9c\x99\xef\x8c\x4e\xcd\xd5\xdd\xf5\xe0\xe9\x98\x92\x9f\xc9\x06\x87\x15\xd5\x16\x05\xda\x3d\x27\x3f\xd9\xe5\x06\x5f\x35\x4c\x63\x6f\xdd\xd1\x9b\x71\x89\x45\xdc\xd6\x50\xe6\x71\xc7\x83\x95\x86\xe6\x93\x1a\x1e\xe8\xee\x86\x97\xd0\xe5\x30\x85\x04\xd3\xf3\x97\x3e\x88\x83\xe8\xb9\x00\x61\x41\x24\x61\x4f\xab\xe2\x81\x6e\xe1\x86\x6f\x44\xa4\xcb\xcf\x3b\xc0\xc0\x4a\x52\x0b\x2c\x40\xa6\xa1\x46\x36\x10\x55\x25\x9e\x6d\xbc\x2b\xaa\x34\x81\x72\x0b\x91\x06\xef\xb0\x45\x0c\x48\x12\xe5\x8c\xdb\x94\x90\x21\x59\x51\x79\x6e\xd8\xa4\xa1\xd8\x95\x38\x4f\xd1\x0d\x8e\x3a\x66\x63\x03\xba\xd8\xb9\xac\x9a\x36\x6c\x68\xb8\x05\x78\x79\x69\x81\x42\x72\xfb\x9a\x36\xa5\xbf\x44\xc4\x50\x49\x99\x58\x88\x80\x99\x5e\xac\xe4\x49\xae\x88\x21\xe3\x90\xce\x09\xc6\x36\xd3\x96\x11\x36\xbe\xa8\x3e\x4a\x8b\x18\xaf\x1e\x2a\x79\xa7\xbe\xcb\x92\xf4\x5d\x1b\x03\x79\xd1\xaa\xc1\x45\xdf\x63\xdb\x87\x16\xe4\xc1\xc3\x6c\x41\x5b\xbf\x2e\x0e\xd7\xb6\xaf\x67\x83\xf6\x3e\xf5\x41\x1a\x0a\x63\xad\xcf\x92\xe7\x6e\x54\xcc\x09\x1d\xe1\xf5\x1d\x82\x82\x8f\xdd\x00\x26\xf1\x27\x82\x39\x8e\x9a\x19\xd2\xee\x0b\xd9\x5c\xe9\x0e\x32\x9e\x84\xbd\x9d\x55\x87\x0d\x3b\x27\xae\xe2\x68\x55\xd9\x62\xca\x5e\xa2\x7e\x56\x38\xf3\x48\xcb\x0e\xcf\xaa\x03\x11\xdc\xf2\xad\x6b\x6e\x4b\x09\xce\x1f\x7c\x46\xb0\x6a\xd7\x40\xb6\x0c\xef\xf3\x05\x69\x6f\x9e\xa5\x44\x30\x1f\xb0\x25\xda\xfc\x25\x36\x5b\x6f\x73\x69\xc7\x6d\xa4\xb1\x48\x99\x54\xdb\x27\x6a\x4e\x78\x8b\x06\x33\xdb\x29\xa6\xf3\x0c\xdf\x73\xdf\x00\x18\x99\xd0\xde\xc7\xa1\xe9\x27\xf8\x6c\x4c\xeb\x22\x63\xee\x69\xd5\xe2\xab\xa2\xb1\xa1\x4b\xd2\x72\x33\x1b\xe9\x81\x08\xfe\x10\xca\x99\xbc\x37\x07\x09\x84\x28\xd0\xd1\x90\x15\xe2\x60\x9d\xcd\x8a\x23\xbe\x99\x1b\x98\x58\xf1\xa7\xb1\x59\x13\xb3\x6c\xe4\xfd\x78\xe1\xc3\x6b\xbf\xb1\xfb\xfd\x12\xbf\x66\x76\x2c\x54\x18\xee\x53\x4b\x14\x25\x6c\x28\xd2\xb4\x72\x23\x48\x3f\x30\xa5\xa3\x92\x03\x9e\x19\xb0\x58\x80\x46\x39\x2c\xe6\x64\xc3\xa4\x42\x63\x6f\x38\xfa\x66\x29\x27\xd8\x17\x0a\xba\xef\xbb\x93\x97\xe6\x18\xcf\x63\xff\x9b\x17\xe9\x65\xff\xd5\x97\x51\x26\xe9\x19\xe1\xb6\xc5\x87\xa8\xe7\x96\x29\x59\x21\xbb\x62\xe2\xd3\x6a\x30\x03\x08\xfe\x4b\xdf\x70\x6f\x74\xba\xd5\x20\xdd\xcf\xf1\x09\xc5\x49\x85\xab\xa3\xd3\x7e\xf4\xc5\xe9\xa5\xfc\x48\x9f\x13\xbb\xb0\x71\xd2\x32\x71\x2c\x11\x7f\xde\xb4\x6b\x55\x48\xd6\x64\x46\x26\xb5\xa3\x70\x40\x2d\x83\x3d\x95\xe1\x46\x19\x66\xb5\x67\x9b\xc7\xc8\xf5\x63\x4c\x61\x94\x3c\x42\x12\x6b\xe3\x86\xf9\x59\x5f\xe8\x1e\x3a\x1d\x08\x62\xb3\x62\x3f\x24\x34\x10\xb6\x6d\x9f\x1f\x37\x04\xba\xe6\x18\xc0\xf8\xa6\x8a\x1d\xfe\xca\xf5\x0e\xfd\x65\x68\x79\xe1\xd8\xd9\x6e\xd4\x3e\x2b\x8b\x98\x1f\x17\xba\x24\x16\x8b\x2c\x32\x08\xf9\x0f\x14\xba\x12\xc6\x26\x11\x00\x00")
 
 func schemaGraphqlBytes() ([]byte, error) {
 	return bindataRead(
@@ -111,7 +111,7 @@ func schemaGraphql() (*asset, error) {
 		return nil, err
 	}
 
-	info := bindataFileInfo{name: "schema.graphql", size: 2189, mode: os.FileMode(438), modTime: time.Unix(1598341445, 0)}
+	info := bindataFileInfo{name: "schema.graphql", size: 4390, mode: os.FileMode(438), modTime: time.Unix(1792207970, 0)}
 	a := &asset{bytes: bytes, info: info}
 	return a, nil
 }
@@ -19,6 +19,8 @@
 package graphql
 
 import (
+	"encoding/json"
+	"fmt"
 	"net"
 	"net/http"
 	"strconv"
@@ -29,10 +31,14 @@ import (
 	"github.com/cntmio/cntmology/common/config"
 	"github.com/cntmio/cntmology/common/log"
 	"github.com/cntmio/cntmology/core/payload"
+	scom "github.com/cntmio/cntmology/core/store/common"
 	"github.com/cntmio/cntmology/core/types"
+	cntmErrors "github.com/cntmio/cntmology/errors"
+	"github.com/cntmio/cntmology/events/message"
 	"github.com/cntmio/cntmology/http/base/actor"
 	comm "github.com/cntmio/cntmology/http/base/common"
 	"github.com/cntmio/cntmology/http/graphql/schema"
+	"github.com/cntmio/cntmology/smartccntmract/event"
 	"github.com/cntmio/cntmology/smartccntmract/service/native/utils"
 	"golang.org/x/net/netutil"
 )
@@ -113,25 +119,28 @@ func NewTxPayload(pl types.Payload) *TxPayload {
 	case *payload.InvokeCode:
 		return &TxPayload{pl: &invokeCodePayload{Code: common.ToHexString(val.Code)}}
 	case *payload.DeployCode:
-		vmty := "Neo"
-		if val.VmType() == payload.WASMVM_TYPE {
-			vmty = "Wasm"
-		}
-		dp := &deployCodePayload{
-			Code:    common.ToHexString(val.GetRawCode()),
-			VmType:  vmty,
-			Name:    val.Name,
-			Version: val.Version,
-			Author:  val.Author,
-			Email:   val.Email,
-			Desc:    val.Description,
-		}
-		return &TxPayload{pl: dp}
+		return &TxPayload{pl: newDeployCodePayload(val)}
 	default:
 		panic("unreachable")
 	}
 }
 
+func newDeployCodePayload(val *payload.DeployCode) *deployCodePayload {
+	vmty := "Neo"
+	if val.VmType() == payload.WASMVM_TYPE {
+		vmty = "Wasm"
+	}
+	return &deployCodePayload{
+		Code:    common.ToHexString(val.GetRawCode()),
+		VmType:  vmty,
+		Name:    val.Name,
+		Version: val.Version,
+		Author:  val.Author,
+		Email:   val.Email,
+		Desc:    val.Description,
+	}
+}
+
 func NewTransaction(tx *types.Transaction, height uint32) *transaction {
 	ty := convTxType(tx)
 	var sigs []*Sig
@@ -306,6 +315,127 @@ func (self *resolver) GetBalance(args struct{ Addr Addr }) (*balance, error) {
 	}, nil
 }
 
+type notifyEvent struct {
+	ContractAddress Addr
+	States          string
+	IsEvm           bool
+}
+
+type executeNotify struct {
+	TxHash          H256
+	State           Uint32
+	GasConsumed     Uint64
+	GasStepUsed     Uint64
+	TxIndex         Uint32
+	CreatedContract Addr
+	Notify          []*notifyEvent
+}
+
+func NewExecuteNotify(notify *event.ExecuteNotify) *executeNotify {
+	evts := make([]*notifyEvent, 0, len(notify.Notify))
+	for _, n := range notify.Notify {
+		states, err := json.Marshal(n.States)
+		if err != nil {
+			states = []byte(fmt.Sprintf("%q", fmt.Sprint(n.States)))
+		}
+		evts = append(evts, &notifyEvent{
+			ContractAddress: Addr{n.CcntmractAddress},
+			States:          string(states),
+			IsEvm:           n.IsEvm,
+		})
+	}
+	return &executeNotify{
+		TxHash:          H256(notify.TxHash),
+		State:           Uint32(notify.State),
+		GasConsumed:     Uint64(notify.GasConsumed),
+		GasStepUsed:     Uint64(notify.GasStepUsed),
+		TxIndex:         Uint32(notify.TxIndex),
+		CreatedContract: Addr{notify.CreatedCcntmract},
+		Notify:          evts,
+	}
+}
+
+func (self *resolver) Events(args struct {
+	TxHash *H256
+	Height *Uint32
+}) ([]*executeNotify, error) {
+	var notifies []*event.ExecuteNotify
+	switch {
+	case args.TxHash != nil && args.Height == nil:
+		notify, err := actor.GetEventNotifyByTxHash(common.Uint256(*args.TxHash))
+		if err != nil {
+			return nil, err
+		}
+		if notify != nil {
+			notifies = append(notifies, notify)
+		}
+	case args.TxHash == nil && args.Height != nil:
+		var err error
+		notifies, err = actor.GetEventNotifyByHeight(uint32(*args.Height))
+		if err != nil && err != scom.ErrNotFound {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("exactly one of txHash and height must be given")
+	}
+
+	evts := make([]*executeNotify, 0, len(notifies))
+	for _, notify := range notifies {
+		evts = append(evts, NewExecuteNotify(notify))
+	}
+	return evts, nil
+}
+
+func (self *resolver) GetContract(args struct{ Addr Addr }) (*deployCodePayload, error) {
+	contract, err := actor.GetCcntmractStateFromStore(args.Addr.Address)
+	if err != nil {
+		return nil, err
+	}
+	if contract == nil {
+		return nil, nil
+	}
+
+	return newDeployCodePayload(contract), nil
+}
+
+func (self *resolver) GetStorage(args struct {
+	Contract Addr
+	Key      string
+}) (*string, error) {
+	key, err := common.HexToBytes(args.Key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid storage key: %s", err)
+	}
+	value, err := actor.GetStorageItem(args.Contract.Address, key)
+	if err != nil {
+		if err == scom.ErrNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	val := common.ToHexString(value)
+	return &val, nil
+}
+
+func (self *resolver) SendRawTransaction(args struct{ Tx string }) (H256, error) {
+	raw, err := common.HexToBytes(args.Tx)
+	if err != nil {
+		return H256{}, fmt.Errorf("invalid transaction: %s", err)
+	}
+	txn, err := types.TransactionFromRawBytes(raw)
+	if err != nil {
+		return H256{}, fmt.Errorf("invalid transaction: %s", err)
+	}
+	hash := txn.Hash()
+	log.Debugf("graphql sendRawTransaction recv %s", hash.ToHexString())
+	if errCode, desc := comm.SendTxToPool(txn); errCode != cntmErrors.ErrNoError {
+		return H256{}, fmt.Errorf("send transaction %s to pool failed: %s", hash.ToHexString(), desc)
+	}
+
+	return H256(hash), nil
+}
+
 func StartServer(cfg *config.GraphQLConfig) {
 	if !cfg.EnableGraphQL || cfg.GraphQLPort == 0 {
 		return
@@ -317,6 +447,9 @@ func StartServer(cfg *config.GraphQLConfig) {
 	}))
 
 	serverMut.Handle("/query", &relay.Handler{Schema: cntmSchema})
+	serverMut.Handle("/subscriptions", newSubscriptionHandler(cntmSchema, cfg.AllowedOrigins))
+
+	actor.SubscribeEvent(message.TOPIC_SAVE_BLOCK_COMPLETE, blockFeed.publish)
 
 	server := &http.Server{Handler: serverMut}
 	listener, err := net.Listen("tcp", ":"+strconv.Itoa(int(cfg.GraphQLPort)))
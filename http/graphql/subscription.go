@@ -0,0 +1,351 @@
+/*
+ * Copyright (C) 2018 The cntmology Authors
+ * This file is part of The cntmology library.
+ *
+ * The cntmology is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The cntmology is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * alcntm with The cntmology.  If not, see <http://www.gnu.org/licenses/>.
+ */
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/gorilla/websocket"
+	"github.com/graph-gophers/graphql-go"
+	"github.com/cntmio/cntmology/common/log"
+	scom "github.com/cntmio/cntmology/core/store/common"
+	"github.com/cntmio/cntmology/core/types"
+	"github.com/cntmio/cntmology/http/base/actor"
+)
+
+// SUBSCRIPTION_BUFFER is the number of blocks buffered for a slow subscriber, later blocks are dropped
+const SUBSCRIPTION_BUFFER = 16
+
+// MAX_SESSION_SUBSCRIPTIONS is the max number of running subscriptions of one websocket session
+const MAX_SESSION_SUBSCRIPTIONS = 32
+
+// blockFeed dispatches the saved blocks to the subscriptions, it is fed by the save block complete event
+var blockFeed = newBlockBroker()
+
+type blockBroker struct {
+	lock sync.RWMutex
+	subs map[chan *types.Block]struct{}
+}
+
+func newBlockBroker() *blockBroker {
+	return &blockBroker{subs: make(map[chan *types.Block]struct{})}
+}
+
+func (self *blockBroker) subscribe() chan *types.Block {
+	ch := make(chan *types.Block, SUBSCRIPTION_BUFFER)
+	self.lock.Lock()
+	self.subs[ch] = struct{}{}
+	self.lock.Unlock()
+	return ch
+}
+
+func (self *blockBroker) unsubscribe(ch chan *types.Block) {
+	self.lock.Lock()
+	delete(self.subs, ch)
+	self.lock.Unlock()
+}
+
+func (self *blockBroker) publish(v interface{}) {
+	b, ok := v.(types.Block)
+	if !ok {
+		return
+	}
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+	for ch := range self.subs {
+		select {
+		case ch <- &b:
+		default:
+			log.Warnf("graphql subscriber is too slow, block %d dropped", b.Header.Height)
+		}
+	}
+}
+
+// watchBlocks calls fn with each saved block until ctx is done or fn returns false, then calls done
+func watchBlocks(ctx context.Context, fn func(b *types.Block) bool, done func()) {
+	ch := blockFeed.subscribe()
+	go func() {
+		defer done()
+		defer blockFeed.unsubscribe(ch)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case b := <-ch:
+				if !fn(b) {
+					return
+				}
+			}
+		}
+	}()
+}
+
+func (self *resolver) NewBlock(ctx context.Context) <-chan *block {
+	out := make(chan *block)
+	watchBlocks(ctx, func(b *types.Block) bool {
+		select {
+		case out <- NewBlock(b):
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}, func() { close(out) })
+	return out
+}
+
+func (self *resolver) ContractEvent(ctx context.Context, args struct{ Contract *Addr }) <-chan *executeNotify {
+	out := make(chan *executeNotify)
+	watchBlocks(ctx, func(b *types.Block) bool {
+		notifies, err := actor.GetEventNotifyByHeight(b.Header.Height)
+		if err == scom.ErrNotFound {
+			return true
+		}
+		if err != nil {
+			log.Warnf("graphql contractEvent: get events of block %d error: %s", b.Header.Height, err)
+			return true
+		}
+		for _, notify := range notifies {
+			evt := NewExecuteNotify(notify)
+			if args.Contract != nil {
+				var matched []*notifyEvent
+				for _, n := range evt.Notify {
+					if n.ContractAddress.Address == args.Contract.Address {
+						matched = append(matched, n)
+					}
+				}
+				if len(matched) == 0 {
+					continue
+				}
+				evt.Notify = matched
+			}
+			select {
+			case out <- evt:
+			case <-ctx.Done():
+				return false
+			}
+		}
+		return true
+	}, func() { close(out) })
+	return out
+}
+
+// message types of the graphql-ws protocol
+const (
+	GQL_CONNECTION_INIT      = "connection_init"
+	GQL_CONNECTION_ACK       = "connection_ack"
+	GQL_CONNECTION_ERROR     = "connection_error"
+	GQL_CONNECTION_TERMINATE = "connection_terminate"
+	GQL_START                = "start"
+	GQL_STOP                 = "stop"
+	GQL_DATA                 = "data"
+	GQL_ERROR                = "error"
+	GQL_COMPLETE             = "complete"
+)
+
+type wsMessage struct {
+	ID      string          `json:"id,omitempty"`
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+type wsStartPayload struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+// subscriptionHandler serves the subscriptions of schema over websocket, with the graphql-ws protocol
+type subscriptionHandler struct {
+	schema   *graphql.Schema
+	upgrader websocket.Upgrader
+}
+
+func newSubscriptionHandler(schema *graphql.Schema, origins []string) *subscriptionHandler {
+	return &subscriptionHandler{
+		schema: schema,
+		upgrader: websocket.Upgrader{
+			CheckOrigin:  checkOrigin(origins),
+			Subprotocols: []string{"graphql-ws"},
+		},
+	}
+}
+
+// checkOrigin accepts requests without origin, from the host of the server, or from one of origins.
+// "*" in origins accepts any origin
+func checkOrigin(origins []string) func(r *http.Request) bool {
+	return func(r *http.Request) bool {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			return true
+		}
+		for _, allowed := range origins {
+			if allowed == "*" || strings.EqualFold(allowed, origin) {
+				return true
+			}
+		}
+		u, err := url.Parse(origin)
+		if err != nil {
+			return false
+		}
+		return strings.EqualFold(u.Host, r.Host)
+	}
+}
+
+func (self *subscriptionHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	conn, err := self.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Warnf("graphql subscription upgrade error: %s", err)
+		return
+	}
+	session := &wsSession{
+		schema: self.schema,
+		conn:   conn,
+		ops:    make(map[string]*wsOperation),
+	}
+	session.serve()
+}
+
+type wsSession struct {
+	schema *graphql.Schema
+	conn   *websocket.Conn
+
+	writeLock sync.Mutex
+	lock      sync.Mutex
+	ops       map[string]*wsOperation // operation id => running subscription
+}
+
+type wsOperation struct {
+	cancel context.CancelFunc
+}
+
+func (self *wsSession) serve() {
+	defer func() {
+		self.lock.Lock()
+		for _, op := range self.ops {
+			op.cancel()
+		}
+		self.ops = make(map[string]*wsOperation)
+		self.lock.Unlock()
+		self.conn.Close()
+	}()
+
+	for {
+		var msg wsMessage
+		if err := self.conn.ReadJSON(&msg); err != nil {
+			if !websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				log.Debugf("graphql subscription read error: %s", err)
+			}
+			return
+		}
+		switch msg.Type {
+		case GQL_CONNECTION_INIT:
+			self.write(&wsMessage{Type: GQL_CONNECTION_ACK})
+		case GQL_CONNECTION_TERMINATE:
+			return
+		case GQL_START:
+			self.start(msg.ID, msg.Payload)
+		case GQL_STOP:
+			self.stop(msg.ID)
+		default:
+			self.writeError(msg.ID, GQL_CONNECTION_ERROR, "unknown message type "+msg.Type)
+		}
+	}
+}
+
+func (self *wsSession) start(id string, raw json.RawMessage) {
+	var payload wsStartPayload
+	if err := json.Unmarshal(raw, &payload); err != nil {
+		self.writeError(id, GQL_ERROR, "invalid start payload: "+err.Error())
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	op := &wsOperation{cancel: cancel}
+	self.lock.Lock()
+	if _, ok := self.ops[id]; ok {
+		self.lock.Unlock()
+		cancel()
+		self.writeError(id, GQL_ERROR, "duplicated operation id "+id)
+		return
+	}
+	if len(self.ops) >= MAX_SESSION_SUBSCRIPTIONS {
+		self.lock.Unlock()
+		cancel()
+		self.writeError(id, GQL_ERROR, "too many subscriptions in the session")
+		return
+	}
+	self.ops[id] = op
+	self.lock.Unlock()
+
+	responses, err := self.schema.Subscribe(ctx, payload.Query, payload.OperationName, payload.Variables)
+	if err != nil {
+		self.finish(id, op)
+		self.writeError(id, GQL_ERROR, err.Error())
+		return
+	}
+	go func() {
+		for resp := range responses {
+			data, err := json.Marshal(resp)
+			if err != nil {
+				log.Warnf("graphql subscription marshal response error: %s", err)
+				continue
+			}
+			self.write(&wsMessage{ID: id, Type: GQL_DATA, Payload: data})
+		}
+		self.finish(id, op)
+		self.write(&wsMessage{ID: id, Type: GQL_COMPLETE})
+	}()
+}
+
+func (self *wsSession) stop(id string) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	if op, ok := self.ops[id]; ok {
+		op.cancel()
+		delete(self.ops, id)
+	}
+}
+
+// finish releases the subscription op once it completes or fails. the id may be already stopped and reused
+// by a new subscription, which is kept
+func (self *wsSession) finish(id string, op *wsOperation) {
+	op.cancel()
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	if self.ops[id] == op {
+		delete(self.ops, id)
+	}
+}
+
+func (self *wsSession) writeError(id string, ty string, desc string) {
+	payload, _ := json.Marshal(map[string]string{"message": desc})
+	self.write(&wsMessage{ID: id, Type: ty, Payload: payload})
+}
+
+func (self *wsSession) write(msg *wsMessage) {
+	self.writeLock.Lock()
+	defer self.writeLock.Unlock()
+	if err := self.conn.WriteJSON(msg); err != nil {
+		log.Debugf("graphql subscription write error: %s", err)
+	}
+}
@@ -0,0 +1,130 @@
+/*
+ * Copyright (C) 2018 The cntmology Authors
+ * This file is part of The cntmology library.
+ *
+ * The cntmology is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The cntmology is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * alcntm with The cntmology.  If not, see <http://www.gnu.org/licenses/>.
+ */
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"github.com/graph-gophers/graphql-go"
+	"github.com/stretchr/testify/assert"
+)
+
+const tickSchema = `
+schema {
+	query: Query
+	subscription: Subscription
+}
+type Query {
+	hello: String!
+}
+type Subscription {
+	tick(count: Int!): Int!
+}
+`
+
+type tickResolver struct{}
+
+func (self *tickResolver) Hello() string { return "hello" }
+
+// Tick sends count ticks and completes, or ticks until the subscription is stopped if count is negative
+func (self *tickResolver) Tick(ctx context.Context, args struct{ Count int32 }) <-chan int32 {
+	out := make(chan int32)
+	go func() {
+		defer close(out)
+		for i := int32(0); args.Count < 0 || i < args.Count; i++ {
+			select {
+			case out <- i:
+			case <-ctx.Done():
+				return
+			}
+			if args.Count < 0 {
+				<-ctx.Done()
+				return
+			}
+		}
+	}()
+	return out
+}
+
+func dialTickServer(t *testing.T) (*websocket.Conn, func()) {
+	schema := graphql.MustParseSchema(tickSchema, &tickResolver{})
+	server := httptest.NewServer(newSubscriptionHandler(schema, nil))
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	assert.Nil(t, err)
+	return conn, func() {
+		conn.Close()
+		server.Close()
+	}
+}
+
+func startTick(t *testing.T, conn *websocket.Conn, id string, count int) {
+	payload, _ := json.Marshal(&wsStartPayload{Query: fmt.Sprintf("subscription { tick(count: %d) }", count)})
+	assert.Nil(t, conn.WriteJSON(&wsMessage{ID: id, Type: GQL_START, Payload: payload}))
+}
+
+func readMessage(t *testing.T, conn *websocket.Conn) *wsMessage {
+	msg := &wsMessage{}
+	assert.Nil(t, conn.ReadJSON(msg))
+	return msg
+}
+
+func TestSubscriptionLimit(t *testing.T) {
+	conn, closer := dialTickServer(t)
+	defer closer()
+
+	// completed subscriptions are released from the session
+	for i := 0; i < MAX_SESSION_SUBSCRIPTIONS+1; i++ {
+		id := fmt.Sprintf("done-%d", i)
+		startTick(t, conn, id, 1)
+		msg := readMessage(t, conn)
+		assert.Equal(t, id, msg.ID)
+		assert.Equal(t, GQL_DATA, msg.Type)
+		msg = readMessage(t, conn)
+		assert.Equal(t, id, msg.ID)
+		assert.Equal(t, GQL_COMPLETE, msg.Type)
+	}
+
+	for i := 0; i < MAX_SESSION_SUBSCRIPTIONS; i++ {
+		id := fmt.Sprintf("run-%d", i)
+		startTick(t, conn, id, -1)
+		msg := readMessage(t, conn)
+		assert.Equal(t, id, msg.ID)
+		assert.Equal(t, GQL_DATA, msg.Type)
+	}
+	startTick(t, conn, "over", -1)
+	msg := readMessage(t, conn)
+	assert.Equal(t, "over", msg.ID)
+	assert.Equal(t, GQL_ERROR, msg.Type)
+	assert.Ccntmains(t, string(msg.Payload), "too many subscriptions")
+
+	// a stopped subscription frees its slot
+	assert.Nil(t, conn.WriteJSON(&wsMessage{ID: "run-0", Type: GQL_STOP}))
+	msg = readMessage(t, conn)
+	assert.Equal(t, "run-0", msg.ID)
+	assert.Equal(t, GQL_COMPLETE, msg.Type)
+	startTick(t, conn, "again", -1)
+	msg = readMessage(t, conn)
+	assert.Equal(t, "again", msg.ID)
+	assert.Equal(t, GQL_DATA, msg.Type)
+}
@@ -20,6 +20,8 @@ package jsonrpc
 
 import (
 	"encoding/hex"
+	"fmt"
+	"math"
 
 	"github.com/cntmio/cntmology/common"
 	"github.com/cntmio/cntmology/common/config"
@@ -409,7 +411,7 @@ func GetBlockHeightByTxHash(params []interface{}) map[string]interface{} {
 	return responsePack(berr.INVALID_PARAMS, "")
 }
 
-//get balance of address
+//get balance of address, at the optional height
 func GetBalance(params []interface{}) map[string]interface{} {
 	if len(params) < 1 {
 		return responsePack(berr.INVALID_PARAMS, "")
@@ -422,13 +424,296 @@ func GetBalance(params []interface{}) map[string]interface{} {
 	if err != nil {
 		return responsePack(berr.INVALID_PARAMS, "")
 	}
-	rsp, err := bcomn.GetBalance(address)
+	height, archived, ok := getStateHeight(params, 1)
+	if !ok {
+		return responsePack(berr.INVALID_PARAMS, "")
+	}
+	var rsp *bcomn.BalanceOfRsp
+	if archived {
+		rsp, err = bcomn.GetBalanceAt(address, height)
+	} else {
+		rsp, err = bcomn.GetBalance(address)
+	}
+	if err != nil {
+		return responsePack(berr.INVALID_PARAMS, "")
+	}
+	return responseSuccess(rsp)
+}
+
+//get balance of address with the decimals of the v2 tokens, at the optional height
+func GetBalanceV2(params []interface{}) map[string]interface{} {
+	if len(params) < 1 {
+		return responsePack(berr.INVALID_PARAMS, "")
+	}
+	addrBase58, ok := params[0].(string)
+	if !ok {
+		return responsePack(berr.INVALID_PARAMS, "")
+	}
+	address, err := common.AddressFromBase58(addrBase58)
+	if err != nil {
+		return responsePack(berr.INVALID_PARAMS, "")
+	}
+	height, archived, ok := getStateHeight(params, 1)
+	if !ok {
+		return responsePack(berr.INVALID_PARAMS, "")
+	}
+	var rsp *bcomn.BalanceOfRsp
+	if archived {
+		rsp, err = bcomn.GetBalanceV2At(address, height)
+	} else {
+		rsp, err = bcomn.GetBalanceV2(address)
+	}
 	if err != nil {
 		return responsePack(berr.INVALID_PARAMS, "")
 	}
 	return responseSuccess(rsp)
 }
 
+//get storage from ccntmract, at the optional height
+//   {"jsonrpc": "2.0", "method": "getstorage", "params": ["code hash", "key", height], "id": 0}
+func GetStorage(params []interface{}) map[string]interface{} {
+	if len(params) < 2 {
+		return responsePack(berr.INVALID_PARAMS, "")
+	}
+	str, ok := params[0].(string)
+	if !ok {
+		return responsePack(berr.INVALID_PARAMS, "")
+	}
+	address, err := bcomn.GetAddress(str)
+	if err != nil {
+		return responsePack(berr.INVALID_PARAMS, "")
+	}
+	str, ok = params[1].(string)
+	if !ok {
+		return responsePack(berr.INVALID_PARAMS, "")
+	}
+	key, err := hex.DecodeString(str)
+	if err != nil {
+		return responsePack(berr.INVALID_PARAMS, "")
+	}
+	height, archived, ok := getStateHeight(params, 2)
+	if !ok {
+		return responsePack(berr.INVALID_PARAMS, "")
+	}
+	var value []byte
+	if archived {
+		value, err = bactor.GetStorageItemAt(address, key, height)
+	} else {
+		value, err = bactor.GetStorageItem(address, key)
+	}
+	if err != nil {
+		if err == scom.ErrNotFound {
+			return responseSuccess(nil)
+		}
+		return responsePack(berr.INVALID_PARAMS, "")
+	}
+	return responseSuccess(common.ToHexString(value))
+}
+
+//get the proof of the storage value of the key in ccntmract, at the optional height
+//   {"jsonrpc": "2.0", "method": "getstorageproof", "params": ["code hash", "key", height], "id": 0}
+func GetStorageProof(params []interface{}) map[string]interface{} {
+	if len(params) < 2 {
+		return responsePack(berr.INVALID_PARAMS, "")
+	}
+	str, ok := params[0].(string)
+	if !ok {
+		return responsePack(berr.INVALID_PARAMS, "")
+	}
+	address, err := bcomn.GetAddress(str)
+	if err != nil {
+		return responsePack(berr.INVALID_PARAMS, "")
+	}
+	str, ok = params[1].(string)
+	if !ok {
+		return responsePack(berr.INVALID_PARAMS, "")
+	}
+	key, err := hex.DecodeString(str)
+	if err != nil {
+		return responsePack(berr.INVALID_PARAMS, "")
+	}
+	height, archived, ok := getStateHeight(params, 2)
+	if !ok {
+		return responsePack(berr.INVALID_PARAMS, "")
+	}
+	if !archived {
+		height = bactor.GetCurrentBlockHeight()
+	}
+	proof, err := bcomn.GetStorageProof(address, key, height)
+	if err != nil {
+		log.Errorf("GetStorageProof error:%s", err)
+		return responsePack(berr.INTERNAL_ERROR, err.Error())
+	}
+	return responseSuccess(proof)
+}
+
+//getStateHeight return the optional height param at index of the state queries, the state at
+//a height is read from the archive, the current state is read without the param
+func getStateHeight(params []interface{}, index int) (uint32, bool, bool) {
+	if len(params) <= index || params[index] == nil {
+		return 0, false, true
+	}
+	height, ok := params[index].(float64)
+	if !ok || height < 0 || height > math.MaxUint32 || height != float64(uint32(height)) {
+		return 0, false, false
+	}
+	return uint32(height), true, true
+}
+
+//get the transactions of the payer in the txpool ordered by nonce
+//   {"jsonrpc": "2.0", "method": "getmempooltxsbypayer", "params": ["payer base58 address"], "id": 0}
+func GetMemPoolTxsByPayer(params []interface{}) map[string]interface{} {
+	if len(params) < 1 {
+		return responsePack(berr.INVALID_PARAMS, "")
+	}
+	addrBase58, ok := params[0].(string)
+	if !ok {
+		return responsePack(berr.INVALID_PARAMS, "")
+	}
+	payer, err := common.AddressFromBase58(addrBase58)
+	if err != nil {
+		return responsePack(berr.INVALID_PARAMS, "")
+	}
+	return responseSuccess(bcomn.GetTxsByPayer(payer))
+}
+
+//replace the transaction of the same payer and nonce in the txpool, the gas price must be at least the
+//ReplaceGasPrice of the pooled one. A transaction is cancelled by replacing it with a no-op one
+//   {"jsonrpc": "2.0", "method": "replacerawtransaction", "params": ["raw transaction"], "id": 0}
+func ReplaceRawTransaction(params []interface{}) map[string]interface{} {
+	if len(params) < 1 {
+		return responsePack(berr.INVALID_PARAMS, "")
+	}
+	str, ok := params[0].(string)
+	if !ok {
+		return responsePack(berr.INVALID_PARAMS, "")
+	}
+	raw, err := common.HexToBytes(str)
+	if err != nil {
+		return responsePack(berr.INVALID_PARAMS, "")
+	}
+	txn, err := types.TransactionFromRawBytes(raw)
+	if err != nil {
+		return responsePack(berr.INVALID_TRANSACTION, "")
+	}
+	var replaced *bcomn.PayerTxInfo
+	for _, tx := range bcomn.GetTxsByPayer(txn.Payer) {
+		if tx.Nonce == txn.Nonce {
+			replaced = &tx
+			break
+		}
+	}
+	if replaced == nil {
+		return responsePack(berr.UNKNOWN_TRANSACTION, "no transaction of the payer and nonce in the txpool")
+	}
+	if txn.GasPrice < replaced.ReplaceGasPrice {
+		return responsePack(int64(cntmErrors.ErrReplaceUnderpriced),
+			fmt.Sprintf("gas price should be at least %d to replace %s", replaced.ReplaceGasPrice,
+				replaced.TxHash))
+	}
+	hash := txn.Hash()
+	if errCode, desc := bcomn.SendTxToPool(txn); errCode != cntmErrors.ErrNoError {
+		log.Warnf("ReplaceRawTransaction verified %s error: %s", hash.ToHexString(), desc)
+		return responsePack(int64(errCode), desc)
+	}
+	log.Debugf("ReplaceRawTransaction %s replaced %s", hash.ToHexString(), replaced.TxHash)
+	return responseSuccess(hash.ToHexString())
+}
+
+//getUint32Param return the optional uint32 param at index, and whether it is set
+func getUint32Param(params []interface{}, index int) (uint32, bool, bool) {
+	if len(params) <= index || params[index] == nil {
+		return 0, false, true
+	}
+	value, ok := params[index].(float64)
+	if !ok || value < 0 || value > math.MaxUint32 || value != float64(uint32(value)) {
+		return 0, false, false
+	}
+	return uint32(value), true, true
+}
+
+//get the live status of the consensus: the round in progress, the peers and the timers, and the phase latencies
+//of the latest blocks
+//   {"jsonrpc": "2.0", "method": "getconsensusstatus", "params": [], "id": 0}
+func GetConsensusStatus(params []interface{}) map[string]interface{} {
+	status, err := bactor.GetConsensusStatus()
+	if err != nil {
+		return responsePack(berr.INTERNAL_ERROR, err.Error())
+	}
+	return responseSuccess(status)
+}
+
+//mine blocks on the dev chain, 1 block by default, the first one at the optional timestamp. Returns the height
+//of the last block
+//   {"jsonrpc": "2.0", "method": "dev_mine", "params": [blocks, timestamp], "id": 0}
+func DevMine(params []interface{}) map[string]interface{} {
+	blocks, _, ok := getUint32Param(params, 0)
+	if !ok {
+		return responsePack(berr.INVALID_PARAMS, "")
+	}
+	timestamp, _, ok := getUint32Param(params, 1)
+	if !ok {
+		return responsePack(berr.INVALID_PARAMS, "")
+	}
+	height, err := bactor.DevMine(blocks, timestamp)
+	if err != nil {
+		return responsePack(berr.INTERNAL_ERROR, err.Error())
+	}
+	return responseSuccess(height)
+}
+
+//shift the time of the next blocks of the dev chain, returns the total shift in seconds
+//   {"jsonrpc": "2.0", "method": "dev_increaseTime", "params": [seconds], "id": 0}
+func DevIncreaseTime(params []interface{}) map[string]interface{} {
+	seconds, set, ok := getUint32Param(params, 0)
+	if !set || !ok {
+		return responsePack(berr.INVALID_PARAMS, "")
+	}
+	offset, err := bactor.DevIncreaseTime(seconds)
+	if err != nil {
+		return responsePack(berr.INTERNAL_ERROR, err.Error())
+	}
+	return responseSuccess(offset)
+}
+
+//set the timestamp of the next block of the dev chain, it must be after the timestamp of the current block
+//   {"jsonrpc": "2.0", "method": "dev_setNextBlockTimestamp", "params": [timestamp], "id": 0}
+func DevSetNextBlockTimestamp(params []interface{}) map[string]interface{} {
+	timestamp, set, ok := getUint32Param(params, 0)
+	if !set || !ok {
+		return responsePack(berr.INVALID_PARAMS, "")
+	}
+	if err := bactor.DevSetNextBlockTimestamp(timestamp); err != nil {
+		return responsePack(berr.INTERNAL_ERROR, err.Error())
+	}
+	return responseSuccess(timestamp)
+}
+
+//save the dev chain at the current block, returns the snapshot id
+//   {"jsonrpc": "2.0", "method": "dev_snapshot", "params": [], "id": 0}
+func DevSnapshot(params []interface{}) map[string]interface{} {
+	id, err := bactor.DevSnapshot()
+	if err != nil {
+		return responsePack(berr.INTERNAL_ERROR, err.Error())
+	}
+	return responseSuccess(id)
+}
+
+//revert the dev chain to the snapshot, the snapshot and the ones taken after it are dropped. Returns the height
+//the chain is reverted to
+//   {"jsonrpc": "2.0", "method": "dev_revert", "params": [snapshot id], "id": 0}
+func DevRevert(params []interface{}) map[string]interface{} {
+	id, set, ok := getUint32Param(params, 0)
+	if !set || !ok {
+		return responsePack(berr.INVALID_PARAMS, "")
+	}
+	height, err := bactor.DevRevert(id)
+	if err != nil {
+		return responsePack(berr.INTERNAL_ERROR, err.Error())
+	}
+	return responseSuccess(height)
+}
+
 func RegDataFile(params []interface{}) map[string]interface{} {
 	if len(params) < 1 {
 		return responsePacking(Err.INVALID_PARAMS, nil)
@@ -38,11 +38,13 @@ func StartRPCServer() error {
 	rpc.HandleFunc("getblockhash", GetBlockHash)
 	rpc.HandleFunc("getconnectioncount", GetConnectionCount)
 	rpc.HandleFunc("getsyncstatus", GetSyncStatus)
+	rpc.HandleFunc("getconsensusstatus", GetConsensusStatus)
 	//HandleFunc("getrawmempool", GetRawMemPool)
 
 	rpc.HandleFunc("getrawtransaction", GetRawTransaction)
 	rpc.HandleFunc("sendrawtransaction", SendRawTransaction)
 	rpc.HandleFunc("getstorage", GetStorage)
+	rpc.HandleFunc("getstorageproof", GetStorageProof)
 	rpc.HandleFunc("getversion", GetNodeVersion)
 	rpc.HandleFunc("getnetworkid", GetNetworkId)
 
@@ -50,6 +52,8 @@ func StartRPCServer() error {
 	rpc.HandleFunc("getmempooltxcount", GetMemPoolTxCount)
 	rpc.HandleFunc("getmempooltxstate", GetMemPoolTxState)
 	rpc.HandleFunc("getmempooltxhashlist", GetMemPoolTxHashList)
+	rpc.HandleFunc("getmempooltxsbypayer", GetMemPoolTxsByPayer)
+	rpc.HandleFunc("replacerawtransaction", ReplaceRawTransaction)
 	rpc.HandleFunc("getsmartcodeevent", GetSmartCodeEvent)
 	rpc.HandleFunc("getblockheightbytxhash", GetBlockHeightByTxHash)
 
@@ -68,6 +72,12 @@ func StartRPCServer() error {
 	rpc.HandleFunc("getcrossstatesproof", GetCrossStatesProof)
 	rpc.HandleFunc("getcrossstatesleafhashes", GetCrossStatesLeafHashes)
 
+	rpc.HandleFunc("dev_mine", DevMine)
+	rpc.HandleFunc("dev_increaseTime", DevIncreaseTime)
+	rpc.HandleFunc("dev_setNextBlockTimestamp", DevSetNextBlockTimestamp)
+	rpc.HandleFunc("dev_snapshot", DevSnapshot)
+	rpc.HandleFunc("dev_revert", DevRevert)
+
 	err := http.ListenAndServe(":"+strconv.Itoa(int(cfg.DefConfig.Rpc.HttpJsonPort)), nil)
 	if err != nil {
 		return fmt.Errorf("ListenAndServe error:%s", err)
@@ -42,6 +42,7 @@ func GetNodeState(params []interface{}) map[string]interface{} {
 	relay := bactor.GetRelayState()
 	height := bactor.GetCurrentBlockHeight()
 	txnCnt := bactor.GetTxnCount()
+	traffic := bactor.GetTrafficStats()
 	n := common.NodeInfo{
 		NodeTime:    t,
 		NodePort:    port,
@@ -51,6 +52,7 @@ func GetNodeState(params []interface{}) map[string]interface{} {
 		Relay:       relay,
 		Height:      height,
 		TxnCnt:      txnCnt,
+		Traffic:     traffic,
 	}
 	return rpc.ResponseSuccess(n)
 }
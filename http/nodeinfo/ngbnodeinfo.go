@@ -27,6 +27,8 @@ type NgbNodeInfo struct {
 	HttpInfoAddr string
 	HttpInfoPort uint16
 	NgbVersion   string
+	BytesSent    uint64
+	BytesRecv    uint64
 }
 
 type NgbNodeInfoSlice []NgbNodeInfo
@@ -59,11 +59,21 @@ var (
 		Name: "cntmology_p2p_reconnect_count",
 		Help: "cntmology p2p reconnect count",
 	})
+
+	bytesSentMetric = prom.NewGauge(prom.GaugeOpts{
+		Name: "cntmology_p2p_bytes_sent",
+		Help: "cntmology p2p bytes sent to all peers",
+	})
+
+	bytesRecvMetric = prom.NewGauge(prom.GaugeOpts{
+		Name: "cntmology_p2p_bytes_recv",
+		Help: "cntmology p2p bytes received from all peers",
+	})
 )
 
 var (
 	metrics = []prom.Collector{nodePortMetric, blockHeightMetric, inboundsCountMetric,
-		outboundsCountMetric, peerStatusMetric, reconnectCountMetric}
+		outboundsCountMetric, peerStatusMetric, reconnectCountMetric, bytesSentMetric, bytesRecvMetric}
 )
 
 func initMetric() error {
@@ -81,6 +91,10 @@ func metricUpdate(n p2p.P2P) {
 
 	blockHeightMetric.Set(float64(ledger.DefLedger.GetCurrentBlockHeight()))
 
+	traffic := n.GetTrafficStats()
+	bytesSentMetric.Set(float64(traffic.BytesSent))
+	bytesRecvMetric.Set(float64(traffic.BytesRecv))
+
 	ns, ok := n.(*netserver.NetServer)
 	if !ok {
 		return
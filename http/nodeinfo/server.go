@@ -44,6 +44,8 @@ type Info struct {
 	NodePort      uint16
 	NodeId        string
 	NodeType      string
+	BytesSent     uint64
+	BytesRecv     uint64
 }
 
 const (
@@ -94,6 +96,8 @@ func viewHandler(w http.ResponseWriter, r *http.Request) {
 		ngbVersion = ngbrNoders[i].GetSoftVersion()
 
 		ngbrInfo := newNgbNodeInfo(ngbId, ngbType, ngbAddr, ngbHttpInfoAddr, ngbInfoPort, ngbVersion)
+		traffic := ngbrNoders[i].GetTrafficStats()
+		ngbrInfo.BytesSent, ngbrInfo.BytesRecv = traffic.BytesSent, traffic.BytesRecv
 		ngbrNodersInfo = append(ngbrNodersInfo, *ngbrInfo)
 	}
 	sort.Sort(NgbNodeInfoSlice(ngbrNodersInfo))
@@ -104,6 +108,8 @@ func viewHandler(w http.ResponseWriter, r *http.Request) {
 		http.Redirect(w, r, "/info", http.StatusFound)
 		return
 	}
+	traffic := node.GetTrafficStats()
+	pageInfo.BytesSent, pageInfo.BytesRecv = traffic.BytesSent, traffic.BytesRecv
 
 	err = templates.ExecuteTemplate(w, "info", pageInfo)
 	if err != nil {
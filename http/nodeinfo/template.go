@@ -61,6 +61,7 @@ const TEMPLATE_PAGE = `
 	<tr><td width="25%">NodeType:</td><td width="25%">{{.NodeType}}</td><td width="25%">NodePort:</td><td width="25%">{{.NodePort}}</td></tr>
 	<tr><td width="25%">HttpRestPort:</td><td width="25%">{{.HttpRestPort}}</td><td width="25%">HttpWsPort:</td><td width="25%">{{.HttpWsPort}}</td></tr>
 	<tr><td width="25%">HttpJsonPort:</td><td width="25%">{{.HttpJsonPort}}</td><td width="25%">HttpLocalPort:</td><td width="25%">{{.HttpLocalPort}}</td></tr>
+	<tr><td width="25%">BytesSent:</td><td width="25%">{{.BytesSent}}</td><td width="25%">BytesRecv:</td><td width="25%">{{.BytesRecv}}</td></tr>
 	</table>
 </td>
 </tr>
@@ -82,9 +83,9 @@ const TEMPLATE_PAGE = `
 </td>
 <td width="80%">
 	<table class="fcntm" width="100%">
-	<tr><th>Neighbor IP</th><th>Neighbor Id</th><th>Neighbor Type</th><th>Neighbor Version</th></tr>
+	<tr><th>Neighbor IP</th><th>Neighbor Id</th><th>Neighbor Type</th><th>Neighbor Version</th><th>Bytes Sent</th><th>Bytes Recv</th></tr>
 	{{range .Neighbors}}
-	<tr><td align="center">{{.NgbAddr}}</td><td align="center">{{.NgbId}}</td><td align="center">{{.NgbType}}</td><td align="center">{{.NgbVersion}}</td></tr>
+	<tr><td align="center">{{.NgbAddr}}</td><td align="center">{{.NgbId}}</td><td align="center">{{.NgbType}}</td><td align="center">{{.NgbVersion}}</td><td align="center">{{.BytesSent}}</td><td align="center">{{.BytesRecv}}</td></tr>
 	{{end}}
 	</table>
 </td>
@@ -250,6 +250,7 @@ func (this *restServer) getParams(r *http.Request, url string, req map[string]in
 		req["PreExec"] = r.FormValue("preExec")
 	case GET_STORAGE:
 		req["Hash"], req["Key"] = getParam(r, "hash"), getParam(r, "key")
+		req["Height"] = r.FormValue("height")
 	case GET_SMTCOCE_EVT_TXS:
 		req["Height"] = getParam(r, "height")
 	case GET_SMTCOCE_EVTS:
@@ -257,7 +258,7 @@ func (this *restServer) getParams(r *http.Request, url string, req map[string]in
 	case GET_BLK_HGT_BY_TXHASH:
 		req["Hash"] = getParam(r, "hash")
 	case GET_BALANCE, GET_BALANCE_V2:
-		req["Addr"] = getParam(r, "addr")
+		req["Addr"], req["Height"] = getParam(r, "addr"), r.FormValue("height")
 	case GET_MERKLE_PROOF:
 		req["Hash"] = getParam(r, "hash")
 	case GET_ALLOWANCE, GET_ALLOWANCE_V2:
@@ -48,6 +48,7 @@ func sendBlock2WSclient(v interface{}) {
 		go func() {
 			pushBlock(v)
 			pushBlockTransactions(v)
+			pushTxConfirmed(v)
 		}()
 	}
 }
@@ -78,28 +79,14 @@ func pushSmartCodeEvent(v interface{}) {
 	go func() {
 		switch object := rs.Result.(type) {
 		case *event.LogEventArgs:
-			ccntmractAddrs, evts := bcomn.GetLogEvent(object)
-			pushEvent(ccntmractAddrs, rs.TxHash.ToHexString(), rs.Error, rs.Action, evts)
+			ws.PushLogEvent(rs.Error, rs.Action, object)
 		case *event.ExecuteNotify:
-			ccntmractAddrs, notify := bcomn.GetExecuteNotify(object)
-			pushEvent(ccntmractAddrs, rs.TxHash.ToHexString(), rs.Error, rs.Action, notify)
+			ws.PushExecuteNotify(rs.Error, rs.Action, object)
 		default:
 		}
 	}()
 }
 
-func pushEvent(ccntmractAddrs map[string]bool, txHash string, errcode int64, action string, result interface{}) {
-	if ws != nil {
-		resp := rest.ResponsePack(Err.SUCCESS)
-		resp["Result"] = result
-		resp["Error"] = errcode
-		resp["Action"] = action
-		resp["Desc"] = Err.ErrMap[resp["Error"].(int64)]
-		ws.PushTxResult(ccntmractAddrs, txHash, resp)
-		ws.BroadcastToSubscribers(ccntmractAddrs, websocket.WSTOPIC_EVENT, resp)
-	}
-}
-
 func pushBlock(v interface{}) {
 	if ws == nil {
 		return
@@ -126,3 +113,11 @@ func pushBlockTransactions(v interface{}) {
 		ws.BroadcastToSubscribers(nil, websocket.WSTOPIC_TXHASHS, resp)
 	}
 }
+func pushTxConfirmed(v interface{}) {
+	if ws == nil {
+		return
+	}
+	if block, ok := v.(types.Block); ok {
+		ws.PushTxConfirmed(&block)
+	}
+}
@@ -0,0 +1,237 @@
+/*
+ * Copyright (C) 2018 The cntmology Authors
+ * This file is part of The cntmology library.
+ *
+ * The cntmology is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The cntmology is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * alcntm with The cntmology.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package websocket
+
+import (
+	"fmt"
+
+	"github.com/cntmio/cntmology/common"
+	bcomn "github.com/cntmio/cntmology/http/base/common"
+	"github.com/cntmio/cntmology/smartccntmract/event"
+	ethcomm "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// MAX_EVM_TOPICS is the number of topic positions of an evm log
+const MAX_EVM_TOPICS = 4
+
+// the name of the transfer notify of the native and oep4 contracts
+const TRANSFER_EVENT_NAME = "transfer"
+
+// topic0 of the evm erc20 Transfer(address,address,uint256) log
+var transferTopic = ethcomm.BytesToHash(crypto.Keccak256([]byte("Transfer(address,address,uint256)")))
+
+// eventFilter is the compiled event filter of a session. A notify matches when it matches every
+// dimension of the filter that is set, and a dimension matches when any of its values matches.
+type eventFilter struct {
+	contracts    map[string]bool                       //contract hex address
+	names        map[string]bool                       //state[0] of the native and neovm notifies
+	topics       [MAX_EVM_TOPICS]map[ethcomm.Hash]bool //evm log topics by position, nil is wildcard
+	participants map[common.Address]bool               //from and to of the transfer notifies
+}
+
+// newEventFilter compiles the filter of the subscription
+func newEventFilter(sub *subscribe) (*eventFilter, error) {
+	filter := &eventFilter{}
+	if len(sub.CcntmractsFilter) > 0 {
+		filter.contracts = make(map[string]bool, len(sub.CcntmractsFilter))
+		for _, addr := range sub.CcntmractsFilter {
+			filter.contracts[addr] = true
+		}
+	}
+	if len(sub.EventNamesFilter) > 0 {
+		filter.names = make(map[string]bool, len(sub.EventNamesFilter))
+		for _, name := range sub.EventNamesFilter {
+			filter.names[name] = true
+		}
+	}
+	if len(sub.TopicsFilter) > MAX_EVM_TOPICS {
+		return nil, fmt.Errorf("at most %d topic positions", MAX_EVM_TOPICS)
+	}
+	for i, topics := range sub.TopicsFilter {
+		if len(topics) == 0 {
+			continue
+		}
+		filter.topics[i] = make(map[ethcomm.Hash]bool, len(topics))
+		for _, topic := range topics {
+			hash, err := hexToHash(topic)
+			if err != nil {
+				return nil, err
+			}
+			filter.topics[i][hash] = true
+		}
+	}
+	if len(sub.AddressesFilter) > 0 {
+		filter.participants = make(map[common.Address]bool, len(sub.AddressesFilter))
+		for _, str := range sub.AddressesFilter {
+			//evm addresses are 0x prefixed, others are base58 or the reversed hex of the address
+			if ethcomm.IsHexAddress(str) && len(str) > 2 && str[:2] == "0x" {
+				filter.participants[common.Address(ethcomm.HexToAddress(str))] = true
+				continue
+			}
+			addr, err := bcomn.GetAddress(str)
+			if err != nil {
+				return nil, fmt.Errorf("invalid address %s", str)
+			}
+			filter.participants[addr] = true
+		}
+	}
+	return filter, nil
+}
+
+func hexToHash(str string) (ethcomm.Hash, error) {
+	if len(str) >= 2 && str[0] == '0' && (str[1] == 'x' || str[1] == 'X') {
+		str = str[2:]
+	}
+	buf, err := common.HexToBytes(str)
+	if err != nil || len(buf) != ethcomm.HashLength {
+		return ethcomm.Hash{}, fmt.Errorf("invalid topic %s", str)
+	}
+	return ethcomm.BytesToHash(buf), nil
+}
+
+// isEmpty reports whether the filter matches every notify
+func (self *eventFilter) isEmpty() bool {
+	return self == nil || len(self.contracts) == 0 && len(self.names) == 0 && len(self.participants) == 0 && !self.hasTopics()
+}
+
+func (self *eventFilter) hasTopics() bool {
+	for _, topics := range self.topics {
+		if topics != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// matchContract checks the contract filter only, it is the only filter the log events can match
+func (self *eventFilter) matchContract(addr string) bool {
+	return len(self.contracts) == 0 || self.contracts[addr]
+}
+
+// matchLog checks a log event, it has no name, topics or participants
+func (self *eventFilter) matchLog(addr string) bool {
+	return self.matchContract(addr) && len(self.names) == 0 && len(self.participants) == 0 && !self.hasTopics()
+}
+
+// filterNotify returns the notifies of the execution that match the filter, nil if none matches
+func (self *eventFilter) filterNotify(notify *event.ExecuteNotify) []*event.NotifyEventInfo {
+	var matched []*event.NotifyEventInfo
+	for _, n := range notify.Notify {
+		if self.match(n) {
+			matched = append(matched, n)
+		}
+	}
+	return matched
+}
+
+func (self *eventFilter) match(n *event.NotifyEventInfo) bool {
+	if !self.matchContract(n.CcntmractAddress.ToHexString()) {
+		return false
+	}
+	if n.IsEvm {
+		// evm logs have no name
+		if len(self.names) > 0 {
+			return false
+		}
+		storageLog, err := event.NotifyEventInfoToEvmLog(n)
+		if err != nil {
+			return false
+		}
+		for i, topics := range self.topics {
+			if topics == nil {
+				continue
+			}
+			if i >= len(storageLog.Topics) || !topics[storageLog.Topics[i]] {
+				return false
+			}
+		}
+		if len(self.participants) == 0 {
+			return true
+		}
+		if len(storageLog.Topics) < 3 || storageLog.Topics[0] != transferTopic {
+			return false
+		}
+		from := common.Address(ethcomm.BytesToAddress(storageLog.Topics[1].Bytes()))
+		to := common.Address(ethcomm.BytesToAddress(storageLog.Topics[2].Bytes()))
+		return self.participants[from] || self.participants[to]
+	}
+
+	// topics only apply to evm logs
+	if self.hasTopics() {
+		return false
+	}
+	if len(self.names) == 0 && len(self.participants) == 0 {
+		return true
+	}
+	states, ok := n.States.([]interface{})
+	if !ok || len(states) == 0 {
+		return false
+	}
+	names := stateStrings(states[0])
+	if len(self.names) > 0 && !self.names[names[0]] && !self.names[names[1]] {
+		return false
+	}
+	if len(self.participants) == 0 {
+		return true
+	}
+	if (names[0] != TRANSFER_EVENT_NAME && names[1] != TRANSFER_EVENT_NAME) || len(states) < 3 {
+		return false
+	}
+	for _, state := range states[1:3] {
+		if addr, ok := stateAddress(state); ok && self.participants[addr] {
+			return true
+		}
+	}
+	return false
+}
+
+// stateStrings returns a string state as is and hex decoded, the neovm notifies hold the hex encoded string
+func stateStrings(state interface{}) [2]string {
+	var res [2]string
+	str, ok := state.(string)
+	if !ok {
+		return res
+	}
+	res[0] = str
+	if buf, err := common.HexToBytes(str); err == nil && len(buf) > 0 {
+		res[1] = string(buf)
+	}
+	return res
+}
+
+// stateAddress returns an address state, base58 encoded in the native notifies, hex encoded in the neovm notifies
+func stateAddress(state interface{}) (common.Address, bool) {
+	str, ok := state.(string)
+	if !ok {
+		return common.ADDRESS_EMPTY, false
+	}
+	if addr, err := common.AddressFromBase58(str); err == nil {
+		return addr, true
+	}
+	buf, err := common.HexToBytes(str)
+	if err != nil {
+		return common.ADDRESS_EMPTY, false
+	}
+	addr, err := common.AddressParseFromBytes(buf)
+	if err != nil {
+		return common.ADDRESS_EMPTY, false
+	}
+	return addr, true
+}
@@ -0,0 +1,113 @@
+/*
+ * Copyright (C) 2018 The cntmology Authors
+ * This file is part of The cntmology library.
+ *
+ * The cntmology is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The cntmology is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * alcntm with The cntmology.  If not, see <http://www.gnu.org/licenses/>.
+ */
+package websocket
+
+import (
+	"testing"
+
+	"github.com/cntmio/cntmology/common"
+	"github.com/cntmio/cntmology/core/types"
+	"github.com/cntmio/cntmology/smartccntmract/event"
+	ethcomm "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func evmNotify(contract common.Address, topics ...ethcomm.Hash) *event.NotifyEventInfo {
+	sink := common.NewZeroCopySink(nil)
+	storageLog := &types.StorageLog{Address: ethcomm.Address(contract), Topics: topics}
+	storageLog.Serialization(sink)
+	return &event.NotifyEventInfo{CcntmractAddress: contract, States: hexutil.Encode(sink.Bytes()), IsEvm: true}
+}
+
+func TestEventFilterNative(t *testing.T) {
+	contract := common.Address{1}
+	from, to, other := common.Address{2}, common.Address{3}, common.Address{4}
+	transfer := &event.NotifyEventInfo{
+		CcntmractAddress: contract,
+		States:           []interface{}{"transfer", from.ToBase58(), common.ToHexString(to[:]), 100},
+	}
+	// neovm notifies hold the hex encoded name
+	approve := &event.NotifyEventInfo{
+		CcntmractAddress: contract,
+		States:           []interface{}{common.ToHexString([]byte("approve")), from.ToBase58()},
+	}
+
+	filter, err := newEventFilter(&subscribe{})
+	assert.Nil(t, err)
+	assert.True(t, filter.isEmpty())
+
+	filter, err = newEventFilter(&subscribe{EventNamesFilter: []string{"approve"}})
+	assert.Nil(t, err)
+	assert.False(t, filter.match(transfer))
+	assert.True(t, filter.match(approve))
+
+	filter, err = newEventFilter(&subscribe{AddressesFilter: []string{to.ToBase58()}})
+	assert.Nil(t, err)
+	assert.True(t, filter.match(transfer))
+	assert.False(t, filter.match(approve))
+
+	filter, err = newEventFilter(&subscribe{
+		CcntmractsFilter: []string{contract.ToHexString()},
+		AddressesFilter:  []string{other.ToHexString()},
+	})
+	assert.Nil(t, err)
+	assert.False(t, filter.match(transfer))
+	assert.Nil(t, filter.filterNotify(&event.ExecuteNotify{Notify: []*event.NotifyEventInfo{transfer, approve}}))
+
+	_, err = newEventFilter(&subscribe{AddressesFilter: []string{"invalid"}})
+	assert.NotNil(t, err)
+}
+
+func TestEventFilterEvm(t *testing.T) {
+	contract := common.Address{1}
+	from, to := common.Address{2}, common.Address{3}
+	fromTopic := ethcomm.BytesToHash(from[:])
+	toTopic := ethcomm.BytesToHash(to[:])
+	transfer := evmNotify(contract, transferTopic, fromTopic, toTopic)
+	other := evmNotify(contract, ethcomm.Hash{5})
+
+	filter, err := newEventFilter(&subscribe{TopicsFilter: [][]string{{transferTopic.Hex()}, nil, {toTopic.Hex()}}})
+	assert.Nil(t, err)
+	assert.True(t, filter.match(transfer))
+	assert.False(t, filter.match(other))
+
+	filter, err = newEventFilter(&subscribe{AddressesFilter: []string{from.ToBase58()}})
+	assert.Nil(t, err)
+	assert.True(t, filter.match(transfer))
+	assert.False(t, filter.match(other))
+
+	filter, err = newEventFilter(&subscribe{AddressesFilter: []string{ethcomm.Address(to).Hex()}})
+	assert.Nil(t, err)
+	assert.True(t, filter.match(transfer))
+
+	// evm logs have no name
+	filter, err = newEventFilter(&subscribe{EventNamesFilter: []string{"transfer"}})
+	assert.Nil(t, err)
+	assert.False(t, filter.match(transfer))
+
+	notify := &event.ExecuteNotify{Notify: []*event.NotifyEventInfo{transfer, other}}
+	filter, err = newEventFilter(&subscribe{CcntmractsFilter: []string{contract.ToHexString()}})
+	assert.Nil(t, err)
+	assert.Equal(t, notify.Notify, filter.filterNotify(notify))
+
+	_, err = newEventFilter(&subscribe{TopicsFilter: make([][]string, MAX_EVM_TOPICS+1)})
+	assert.NotNil(t, err)
+	_, err = newEventFilter(&subscribe{TopicsFilter: [][]string{{"0x1234"}}})
+	assert.NotNil(t, err)
+}
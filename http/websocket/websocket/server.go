@@ -33,9 +33,13 @@ import (
 	"github.com/cntmio/cntmology/common"
 	cfg "github.com/cntmio/cntmology/common/config"
 	"github.com/cntmio/cntmology/common/log"
+	"github.com/cntmio/cntmology/core/types"
+	bactor "github.com/cntmio/cntmology/http/base/actor"
+	bcomn "github.com/cntmio/cntmology/http/base/common"
 	Err "github.com/cntmio/cntmology/http/base/error"
 	"github.com/cntmio/cntmology/http/base/rest"
 	"github.com/cntmio/cntmology/http/websocket/session"
+	"github.com/cntmio/cntmology/smartccntmract/event"
 )
 
 const (
@@ -45,6 +49,9 @@ const (
 	WSTOPIC_TXHASHS    = 4
 )
 
+//MAX_WATCH_TXS is the max number of pending transactions a session can watch
+const MAX_WATCH_TXS = 1024
+
 type handler func(map[string]interface{}) map[string]interface{}
 type Handler struct {
 	handler  handler
@@ -53,21 +60,36 @@ type Handler struct {
 
 //subscribe event for client
 type subscribe struct {
-	CcntmractsFilter       []string `json:"CcntmractsFilter"`
-	SubscribeEvent        bool     `json:"SubscribeEvent"`
-	SubscribeJsonBlock    bool     `json:"SubscribeJsonBlock"`
-	SubscribeRawBlock     bool     `json:"SubscribeRawBlock"`
-	SubscribeBlockTxHashs bool     `json:"SubscribeBlockTxHashs"`
+	CcntmractsFilter      []string     `json:"CcntmractsFilter"`
+	EventNamesFilter      []string     `json:"EventNamesFilter"`
+	TopicsFilter          [][]string   `json:"TopicsFilter"`
+	AddressesFilter       []string     `json:"AddressesFilter"`
+	SubscribeEvent        bool         `json:"SubscribeEvent"`
+	SubscribeJsonBlock    bool         `json:"SubscribeJsonBlock"`
+	SubscribeRawBlock     bool         `json:"SubscribeRawBlock"`
+	SubscribeBlockTxHashs bool         `json:"SubscribeBlockTxHashs"`
+	filter                *eventFilter `json:"-"`
+}
+
+//the result of the watchtransaction action and the txconfirmed push
+type txStatus struct {
+	TxHash    string `json:"TxHash"`
+	Confirmed bool   `json:"Confirmed"`
+	Height    uint32 `json:"Height,omitempty"`
+	BlockHash string `json:"BlockHash,omitempty"`
 }
+
 type WsServer struct {
 	sync.RWMutex
 	Upgrader     websocket.Upgrader
 	listener     net.Listener
 	server       *http.Server
-	SessionList  *session.SessionList // websocket sesseionlist
-	ActionMap    map[string]Handler   //handler functions
-	TxHashMap    map[string]string    //key: txHash   value:sessionid
-	SubscribeMap map[string]subscribe //key: sessionId   value:subscribeInfo
+	SessionList  *session.SessionList       // websocket sesseionlist
+	ActionMap    map[string]Handler         //handler functions
+	TxHashMap    map[string]string          //key: txHash   value:sessionid
+	SubscribeMap map[string]subscribe       //key: sessionId   value:subscribeInfo
+	WatchTxMap   map[string]map[string]bool //key: txHash   value:watching sessionids
+	watchCount   map[string]int             //key: sessionId   value:number of watched txHashs
 }
 
 //init websocket server
@@ -77,6 +99,8 @@ func InitWsServer() *WsServer {
 		SessionList:  session.NewSessionList(),
 		TxHashMap:    make(map[string]string),
 		SubscribeMap: make(map[string]subscribe),
+		WatchTxMap:   make(map[string]map[string]bool),
+		watchCount:   make(map[string]int),
 	}
 	return ws
 }
@@ -158,19 +182,81 @@ func (self *WsServer) registryMethod() {
 			sub.SubscribeBlockTxHashs = b
 		}
 		if ctsf, ok := cmd["CcntmractsFilter"].([]interface{}); ok {
-			sub.CcntmractsFilter = []string{}
-			for _, v := range ctsf {
-				if addr, k := v.(string); k {
-					sub.CcntmractsFilter = append(sub.CcntmractsFilter, addr)
+			sub.CcntmractsFilter = toStrings(ctsf)
+		}
+		if names, ok := cmd["EventNamesFilter"].([]interface{}); ok {
+			sub.EventNamesFilter = toStrings(names)
+		}
+		if topics, ok := cmd["TopicsFilter"].([]interface{}); ok {
+			sub.TopicsFilter = make([][]string, 0, len(topics))
+			for _, v := range topics {
+				//a position is null for any topic, a topic, or a list of topics
+				switch t := v.(type) {
+				case string:
+					sub.TopicsFilter = append(sub.TopicsFilter, []string{t})
+				case []interface{}:
+					sub.TopicsFilter = append(sub.TopicsFilter, toStrings(t))
+				default:
+					sub.TopicsFilter = append(sub.TopicsFilter, nil)
 				}
 			}
 		}
+		if addrs, ok := cmd["AddressesFilter"].([]interface{}); ok {
+			sub.AddressesFilter = toStrings(addrs)
+		}
+		filter, err := newEventFilter(&sub)
+		if err != nil {
+			resp = rest.ResponsePack(Err.INVALID_PARAMS)
+			resp["Result"] = err.Error()
+			return resp
+		}
+		sub.filter = filter
 		self.SubscribeMap[sessionId] = sub
 
 		resp["Action"] = "subscribe"
 		resp["Result"] = sub
 		return resp
 	}
+	watchtransaction := func(cmd map[string]interface{}) map[string]interface{} {
+		resp := rest.ResponsePack(Err.SUCCESS)
+		str, ok := cmd["Hash"].(string)
+		if !ok || len(str) == 0 {
+			return rest.ResponsePack(Err.INVALID_PARAMS)
+		}
+		hash, err := common.Uint256FromHexString(str)
+		if err != nil {
+			return rest.ResponsePack(Err.INVALID_PARAMS)
+		}
+		txHash := hash.ToHexString()
+		sessionId, _ := cmd["SessionId"].(string)
+
+		//check the ledger under the lock, the block saved after the check is pushed to the watch
+		self.Lock()
+		defer self.Unlock()
+		status := &txStatus{TxHash: txHash}
+		resp["Result"] = status
+		height, tx, err := bactor.GetTxnWithHeightByTxHash(hash)
+		if err == nil && tx != nil {
+			status.Confirmed = true
+			status.Height = height
+			status.BlockHash = bactor.GetBlockHashFromStore(height).ToHexString()
+			return resp
+		}
+		if self.WatchTxMap[txHash][sessionId] {
+			return resp
+		}
+		if self.watchCount[sessionId] >= MAX_WATCH_TXS {
+			resp = rest.ResponsePack(Err.INVALID_PARAMS)
+			resp["Result"] = "too many watched transactions"
+			return resp
+		}
+		if self.WatchTxMap[txHash] == nil {
+			self.WatchTxMap[txHash] = make(map[string]bool)
+		}
+		self.WatchTxMap[txHash][sessionId] = true
+		self.watchCount[sessionId]++
+		return resp
+	}
 	getsessioncount := func(cmd map[string]interface{}) map[string]interface{} {
 		resp := rest.ResponsePack(Err.SUCCESS)
 		resp["Action"] = "getsessioncount"
@@ -193,6 +279,7 @@ func (self *WsServer) registryMethod() {
 		"sendrawtransaction":        {handler: rest.SendRawTransaction, pushFlag: true},
 		"heartbeat":                 {handler: heartbeat},
 		"subscribe":                 {handler: subscribe},
+		"watchtransaction":          {handler: watchtransaction},
 		"getstorage":                {handler: rest.GetStorage},
 		"getallowance":              {handler: rest.GetAllowance},
 		"getallowancev2":            {handler: rest.GetAllowanceV2},
@@ -206,6 +293,7 @@ func (self *WsServer) registryMethod() {
 		"getmempooltxhashlist":      {handler: rest.GetMemPoolTxHashList},
 		"getversion":                {handler: rest.GetNodeVersion},
 		"getnetworkid":              {handler: rest.GetNetworkId},
+		"getconsensusstatus":        {handler: rest.GetConsensusStatus},
 
 		"getsessioncount": {handler: getsessioncount},
 	}
@@ -270,6 +358,7 @@ func (self *WsServer) webSocketHandler(w http.ResponseWriter, r *http.Request) {
 	defer func() {
 		self.deleteTxHashes(nsSession.GetSessionId())
 		self.deleteSubscribe(nsSession.GetSessionId())
+		self.deleteWatches(nsSession.GetSessionId())
 		self.SessionList.CloseSession(nsSession)
 		if err := recover(); err != nil {
 			log.Fatal("websocket recover:", err)
@@ -379,6 +468,30 @@ func (self *WsServer) deleteSubscribe(sessionId string) {
 	defer self.Unlock()
 	delete(self.SubscribeMap, sessionId)
 }
+func (self *WsServer) deleteWatches(sessionId string) {
+	self.Lock()
+	defer self.Unlock()
+	if self.watchCount[sessionId] == 0 {
+		return
+	}
+	delete(self.watchCount, sessionId)
+	for txHash, sessions := range self.WatchTxMap {
+		delete(sessions, sessionId)
+		if len(sessions) == 0 {
+			delete(self.WatchTxMap, txHash)
+		}
+	}
+}
+
+func toStrings(list []interface{}) []string {
+	res := make([]string, 0, len(list))
+	for _, v := range list {
+		if str, ok := v.(string); ok {
+			res = append(res, str)
+		}
+	}
+	return res
+}
 
 func marshalResp(resp map[string]interface{}) []byte {
 	resp["Desc"] = Err.ErrMap[resp["Error"].(int64)]
@@ -391,31 +504,135 @@ func marshalResp(resp map[string]interface{}) []byte {
 	return data
 }
 
-func (self *WsServer) PushTxResult(ccntmractAddrs map[string]bool, txHashStr string, resp map[string]interface{}) {
+func eventResp(errcode int64, action string, result interface{}) map[string]interface{} {
+	resp := rest.ResponsePack(Err.SUCCESS)
+	resp["Result"] = result
+	resp["Error"] = errcode
+	resp["Action"] = action
+	return resp
+}
+
+//PushExecuteNotify pushes the notify to the session that sent the transaction, and to the sessions whose
+//filter matches any of its notifies. A filtered session only receives the notifies matching its filter,
+//the sessions are matched before marshaling and the unfiltered sessions share one message.
+func (self *WsServer) PushExecuteNotify(errcode int64, action string, notify *event.ExecuteNotify) {
+	txHash := notify.TxHash.ToHexString()
+	var unfiltered []*session.Session
+	filtered := make(map[*session.Session][]*event.NotifyEventInfo)
+
 	self.Lock()
-	sessionId := self.TxHashMap[txHashStr]
-	delete(self.TxHashMap, txHashStr)
-	//avoid twice, will send in BroadcastToSubscribers
-	sub := self.SubscribeMap[sessionId]
-	if sub.SubscribeEvent {
-		if len(sub.CcntmractsFilter) == 0 {
-			self.Unlock()
-			return
+	senderId := self.TxHashMap[txHash]
+	delete(self.TxHashMap, txHash)
+	for sid, v := range self.SubscribeMap {
+		if !v.SubscribeEvent {
+			continue
 		}
-		for _, addr := range sub.CcntmractsFilter {
-			if ccntmractAddrs[addr] {
-				self.Unlock()
-				return
-			}
+		s := self.SessionList.GetSessionById(sid)
+		if s == nil {
+			continue
+		}
+		if v.filter.isEmpty() {
+			unfiltered = append(unfiltered, s)
+		} else if matched := v.filter.filterNotify(notify); len(matched) > 0 {
+			filtered[s] = matched
+		} else {
+			continue
+		}
+		//avoid twice, the sender receives it as a subscriber
+		if sid == senderId {
+			senderId = ""
 		}
 	}
 	self.Unlock()
 
-	s := self.SessionList.GetSessionById(sessionId)
-	if s != nil {
-		s.Send(marshalResp(resp))
+	if senderId != "" {
+		if s := self.SessionList.GetSessionById(senderId); s != nil {
+			unfiltered = append(unfiltered, s)
+		}
+	}
+	if len(unfiltered) > 0 {
+		_, result := bcomn.GetExecuteNotify(notify)
+		data := marshalResp(eventResp(errcode, action, result))
+		for _, s := range unfiltered {
+			s.Send(data)
+		}
+	}
+	for s, matched := range filtered {
+		n := *notify
+		n.Notify = matched
+		_, result := bcomn.GetExecuteNotify(&n)
+		s.Send(marshalResp(eventResp(errcode, action, result)))
 	}
 }
+
+//PushLogEvent pushes the log to the session that sent the transaction, and to the sessions whose filter matches it
+func (self *WsServer) PushLogEvent(errcode int64, action string, evt *event.LogEventArgs) {
+	txHash := evt.TxHash.ToHexString()
+	addr := evt.CcntmractAddress.ToHexString()
+	var sessions []*session.Session
+
+	self.Lock()
+	senderId := self.TxHashMap[txHash]
+	delete(self.TxHashMap, txHash)
+	for sid, v := range self.SubscribeMap {
+		if !v.SubscribeEvent || !(v.filter.isEmpty() || v.filter.matchLog(addr)) {
+			continue
+		}
+		if s := self.SessionList.GetSessionById(sid); s != nil {
+			sessions = append(sessions, s)
+		}
+		if sid == senderId {
+			senderId = ""
+		}
+	}
+	self.Unlock()
+
+	if senderId != "" {
+		if s := self.SessionList.GetSessionById(senderId); s != nil {
+			sessions = append(sessions, s)
+		}
+	}
+	if len(sessions) == 0 {
+		return
+	}
+	_, result := bcomn.GetLogEvent(evt)
+	data := marshalResp(eventResp(errcode, action, result))
+	for _, s := range sessions {
+		s.Send(data)
+	}
+}
+
+//PushTxConfirmed pushes the transactions of the block to the sessions watching them, once, and closes the watches
+func (self *WsServer) PushTxConfirmed(block *types.Block) {
+	self.Lock()
+	defer self.Unlock()
+	if len(self.WatchTxMap) == 0 {
+		return
+	}
+	height := block.Header.Height
+	blockHash := block.Hash().ToHexString()
+	for _, tx := range block.Transactions {
+		txHash := tx.Hash().ToHexString()
+		sessions, ok := self.WatchTxMap[txHash]
+		if !ok {
+			continue
+		}
+		delete(self.WatchTxMap, txHash)
+		resp := rest.ResponsePack(Err.SUCCESS)
+		resp["Action"] = "txconfirmed"
+		resp["Result"] = &txStatus{TxHash: txHash, Confirmed: true, Height: height, BlockHash: blockHash}
+		data := marshalResp(resp)
+		for sid := range sessions {
+			if self.watchCount[sid]--; self.watchCount[sid] <= 0 {
+				delete(self.watchCount, sid)
+			}
+			if s := self.SessionList.GetSessionById(sid); s != nil {
+				s.Send(data)
+			}
+		}
+	}
+}
+
 func (self *WsServer) BroadcastToSubscribers(ccntmractAddrs map[string]bool, sub int, resp map[string]interface{}) {
 	// broadcast SubscribeMap
 	self.Lock()
@@ -433,12 +650,12 @@ func (self *WsServer) BroadcastToSubscribers(ccntmractAddrs map[string]bool, sub
 		} else if sub == WSTOPIC_TXHASHS && v.SubscribeBlockTxHashs {
 			s.Send(data)
 		} else if sub == WSTOPIC_EVENT && v.SubscribeEvent {
-			if len(v.CcntmractsFilter) == 0 {
+			if v.filter.isEmpty() {
 				s.Send(data)
 				ccntminue
 			}
-			for _, addr := range v.CcntmractsFilter {
-				if ccntmractAddrs[addr] {
+			for addr := range ccntmractAddrs {
+				if v.filter.matchLog(addr) {
 					s.Send(data)
 					break
 				}
@@ -44,6 +44,7 @@ import (
 	"github.com/conntectome/cntm/core/ledger"
 	"github.com/conntectome/cntm/events"
 	bactor "github.com/conntectome/cntm/http/base/actor"
+	"github.com/conntectome/cntm/http/ethrpc"
 	hserver "github.com/conntectome/cntm/http/base/actor"
 	"github.com/conntectome/cntm/http/jsonrpc"
 	"github.com/conntectome/cntm/http/localrpc"
@@ -53,6 +54,7 @@ import (
 	"github.com/conntectome/cntm/p2pserver"
 	netreqactor "github.com/conntectome/cntm/p2pserver/actor/req"
 	p2pactor "github.com/conntectome/cntm/p2pserver/actor/server"
+	p2p "github.com/conntectome/cntm/p2pserver/net/protocol"
 	"github.com/conntectome/cntm/txnpool"
 	tc "github.com/conntectome/cntm/txnpool/common"
 	"github.com/conntectome/cntm/txnpool/proc"
@@ -74,6 +76,7 @@ func setupAPP() *cli.App {
 		cmd.ContractCommand,
 		cmd.ImportCommand,
 		cmd.ExportCommand,
+		cmd.SnapshotCommand,
 		cmd.TxCommond,
 		cmd.SigTxCommand,
 		cmd.MultiSigAddrCommand,
@@ -87,6 +90,10 @@ func setupAPP() *cli.App {
 		utils.LogLevelFlag,
 		utils.DisableLogFileFlag,
 		utils.DisableEventLogFlag,
+		utils.EnableArchiveFlag,
+		utils.PruneBlocksFlag,
+		utils.SnapshotIntervalFlag,
+		utils.StateTrieHeightFlag,
 		utils.DataDirFlag,
 		utils.WasmVerifyMethodFlag,
 		//account setting
@@ -99,6 +106,10 @@ func setupAPP() *cli.App {
 		//txpool setting
 		utils.GasPriceFlag,
 		utils.GasLimitFlag,
+		utils.TxPoolCapacityFlag,
+		utils.TxPoolPayerLimitFlag,
+		utils.TxPoolPriceBumpFlag,
+		utils.TxPoolLifetimeFlag,
 		utils.TxpoolPreExecDisableFlag,
 		utils.DisableSyncVerifyTxFlag,
 		utils.DisableBroadcastNetTxFlag,
@@ -111,14 +122,22 @@ func setupAPP() *cli.App {
 		utils.MaxConnInBoundFlag,
 		utils.MaxConnOutBoundFlag,
 		utils.MaxConnInBoundForSingleIPFlag,
+		utils.FastSyncFlag,
+		utils.RequireEncryptionFlag,
+		utils.PinnedPeersFlag,
+		utils.MaxBandwidthFlag,
+		utils.MaxPeerBandwidthFlag,
 		//test mode setting
 		utils.EnableTestModeFlag,
 		utils.TestModeGenBlockTimeFlag,
+		utils.TestModeDevFlag,
 		//rpc setting
 		utils.RPCDisabledFlag,
 		utils.RPCPortFlag,
 		utils.RPCLocalEnableFlag,
 		utils.RPCLocalProtFlag,
+		utils.ETHRPCEnableFlag,
+		utils.ETHRPCPortFlag,
 		//rest setting
 		utils.RestfulEnableFlag,
 		utils.RestfulPortFlag,
@@ -174,7 +193,7 @@ func startCntm(ctx *cli.Context) {
 		log.Errorf("initP2PNode error: %s", err)
 		return
 	}
-	_, err = initConsensus(ctx, p2pPid, txpool, acc)
+	_, err = initConsensus(ctx, p2pSvr, p2pPid, txpool, acc)
 	if err != nil {
 		log.Errorf("initConsensus error: %s", err)
 		return
@@ -189,6 +208,11 @@ func startCntm(ctx *cli.Context) {
 		log.Errorf("initLocalRpc error: %s", err)
 		return
 	}
+	err = initEthRpc(ctx, txpool)
+	if err != nil {
+		log.Errorf("initEthRpc error: %s", err)
+		return
+	}
 	initRestful(ctx)
 	initWs(ctx)
 	initNodeInfo(ctx, p2pSvr)
@@ -320,14 +344,19 @@ func initP2PNode(ctx *cli.Context, txpoolSvr *proc.TXPoolServer) (*p2pserver.P2P
 	return p2p, p2pPID, nil
 }
 
-func initConsensus(ctx *cli.Context, p2pPid *actor.PID, txpoolSvr *proc.TXPoolServer, acc *account.Account) (consensus.ConsensusService, error) {
+func initConsensus(ctx *cli.Context, p2pSvr *p2pserver.P2PServer, p2pPid *actor.PID, txpoolSvr *proc.TXPoolServer,
+	acc *account.Account) (consensus.ConsensusService, error) {
 	if !config.DefConfig.Consensus.EnableConsensus {
 		return nil, nil
 	}
 	pool := txpoolSvr.GetPID(tc.TxPoolActor)
+	var net p2p.P2P
+	if p2pSvr != nil {
+		net = p2pSvr.GetNetwork()
+	}
 
 	consensusType := strings.ToLower(config.DefConfig.Genesis.ConsensusType)
-	consensusService, err := consensus.NewConsensusService(consensusType, acc, pool, nil, p2pPid)
+	consensusService, err := consensus.NewConsensusService(consensusType, acc, pool, nil, p2pPid, net)
 	if err != nil {
 		return nil, fmt.Errorf("NewConsensusService %s error: %s", consensusType, err)
 	}
@@ -389,6 +418,30 @@ func initLocalRpc(ctx *cli.Context) error {
 	return nil
 }
 
+func initEthRpc(ctx *cli.Context, txpool *proc.TXPoolServer) error {
+	if !config.DefConfig.Rpc.EnableEthRpc {
+		return nil
+	}
+	var err error
+	exitCh := make(chan interface{}, 0)
+	go func() {
+		err = ethrpc.StartEthServer(txpool)
+		close(exitCh)
+	}()
+
+	flag := false
+	select {
+	case <-exitCh:
+		if !flag {
+			return err
+		}
+	case <-time.After(time.Millisecond * 5):
+		flag = true
+	}
+	log.Infof("Eth rpc init success")
+	return nil
+}
+
 func initRestful(ctx *cli.Context) {
 	if !config.DefConfig.Restful.EnableHttpRestful {
 		return
@@ -0,0 +1,169 @@
+/*
+ * Copyright (C) 2018 The cntmology Authors
+ * This file is part of The cntmology library.
+ *
+ * The cntmology is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The cntmology is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * alcntm with The cntmology.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package merkle
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/cntmio/cntmology/common"
+)
+
+//The sparse merkle tree is keyed by the bits of the key hash from the highest one. An empty subtree hashes
+//to zero and a subtree holding a single leaf is the leaf itself, so a path only goes as deep as needed to
+//tell the keys apart.
+const (
+	SPARSE_LEAF_NODE  byte = 0x00
+	SPARSE_INNER_NODE byte = 0x01
+	SPARSE_MAX_DEPTH       = 8 * common.UINT256_SIZE
+)
+
+//HashSparseLeaf returns the hash of the leaf of a key and a value hash
+func HashSparseLeaf(keyHash, valueHash common.Uint256) common.Uint256 {
+	data := make([]byte, 0, 1+2*common.UINT256_SIZE)
+	data = append(data, SPARSE_LEAF_NODE)
+	data = append(data, keyHash[:]...)
+	data = append(data, valueHash[:]...)
+	return sha256.Sum256(data)
+}
+
+//HashSparseNode returns the hash of the inner node of two subtrees
+func HashSparseNode(left, right common.Uint256) common.Uint256 {
+	data := make([]byte, 0, 1+2*common.UINT256_SIZE)
+	data = append(data, SPARSE_INNER_NODE)
+	data = append(data, left[:]...)
+	data = append(data, right[:]...)
+	return sha256.Sum256(data)
+}
+
+//SparseKeyBit returns the bit of the key hash at depth, which selects the right subtree if it is 1
+func SparseKeyBit(keyHash common.Uint256, depth int) byte {
+	return (keyHash[depth/8] >> uint(7-depth%8)) & 1
+}
+
+//SparseMerkleLeaf is the leaf at the end of a key path
+type SparseMerkleLeaf struct {
+	KeyHash   common.Uint256
+	ValueHash common.Uint256
+}
+
+func (self *SparseMerkleLeaf) Hash() common.Uint256 {
+	return HashSparseLeaf(self.KeyHash, self.ValueHash)
+}
+
+//SparseMerkleProof proves the value of a key in a sparse merkle tree, or that the key is not in the tree
+type SparseMerkleProof struct {
+	Leaf     *SparseMerkleLeaf //Leaf at the end of the key path, nil if the path ends at an empty subtree
+	Siblings []common.Uint256  //Sibling hashes along the key path, from the root down
+}
+
+//Verify checks the proof against the root, an empty value proves the key is not in the tree
+func (self *SparseMerkleProof) Verify(root common.Uint256, key, value []byte) error {
+	if len(self.Siblings) > SPARSE_MAX_DEPTH {
+		return fmt.Errorf("proof depth %d exceeds %d", len(self.Siblings), SPARSE_MAX_DEPTH)
+	}
+	keyHash := common.Uint256(sha256.Sum256(key))
+	hash := common.UINT256_EMPTY
+	if len(value) != 0 {
+		if self.Leaf == nil || self.Leaf.KeyHash != keyHash {
+			return errors.New("the proof does not end at the leaf of the key")
+		}
+		if self.Leaf.ValueHash != sha256.Sum256(value) {
+			return errors.New("the value does not match the leaf")
+		}
+		hash = self.Leaf.Hash()
+	} else if self.Leaf != nil {
+		if self.Leaf.KeyHash == keyHash {
+			return errors.New("the key is in the tree")
+		}
+		//the path of the key ends at the leaf of another key
+		for depth := range self.Siblings {
+			if SparseKeyBit(self.Leaf.KeyHash, depth) != SparseKeyBit(keyHash, depth) {
+				return errors.New("the leaf is not on the path of the key")
+			}
+		}
+		hash = self.Leaf.Hash()
+	}
+
+	for depth := len(self.Siblings) - 1; depth >= 0; depth-- {
+		if SparseKeyBit(keyHash, depth) == 0 {
+			hash = HashSparseNode(hash, self.Siblings[depth])
+		} else {
+			hash = HashSparseNode(self.Siblings[depth], hash)
+		}
+	}
+	if hash != root {
+		return fmt.Errorf("constructed root hash differs from provided root hash. Constructed: %x, Expected: %x",
+			hash, root)
+	}
+	return nil
+}
+
+func (self *SparseMerkleProof) Serialization(sink *common.ZeroCopySink) {
+	sink.WriteBool(self.Leaf != nil)
+	if self.Leaf != nil {
+		sink.WriteHash(self.Leaf.KeyHash)
+		sink.WriteHash(self.Leaf.ValueHash)
+	}
+	sink.WriteVarUint(uint64(len(self.Siblings)))
+	for _, hash := range self.Siblings {
+		sink.WriteHash(hash)
+	}
+}
+
+func (self *SparseMerkleProof) Deserialization(source *common.ZeroCopySource) error {
+	hasLeaf, irregular, eof := source.NextBool()
+	if irregular {
+		return common.ErrIrregularData
+	}
+	if eof {
+		return io.ErrUnexpectedEOF
+	}
+	self.Leaf = nil
+	if hasLeaf {
+		leaf := &SparseMerkleLeaf{}
+		leaf.KeyHash, eof = source.NextHash()
+		leaf.ValueHash, eof = source.NextHash()
+		if eof {
+			return io.ErrUnexpectedEOF
+		}
+		self.Leaf = leaf
+	}
+	n, _, irregular, eof := source.NextVarUint()
+	if irregular {
+		return common.ErrIrregularData
+	}
+	if eof {
+		return io.ErrUnexpectedEOF
+	}
+	if n > SPARSE_MAX_DEPTH {
+		return fmt.Errorf("proof depth %d exceeds %d", n, SPARSE_MAX_DEPTH)
+	}
+	self.Siblings = make([]common.Uint256, 0, n)
+	for i := uint64(0); i < n; i++ {
+		hash, eof := source.NextHash()
+		if eof {
+			return io.ErrUnexpectedEOF
+		}
+		self.Siblings = append(self.Siblings, hash)
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+/*
+ * Copyright (C) 2018 The cntmology Authors
+ * This file is part of The cntmology library.
+ *
+ * The cntmology is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The cntmology is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * alcntm with The cntmology.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package merkle
+
+import (
+	"crypto/sha256"
+	"testing"
+
+	"github.com/cntmio/cntmology/common"
+	"github.com/stretchr/testify/assert"
+)
+
+//sparseRoot builds the tree of the leaves below depth and returns its root and the proof of keyHash
+func sparseRoot(leaves []SparseMerkleLeaf, depth int, keyHash common.Uint256) (common.Uint256, *SparseMerkleProof) {
+	if len(leaves) == 0 {
+		return common.UINT256_EMPTY, &SparseMerkleProof{}
+	}
+	if len(leaves) == 1 {
+		return leaves[0].Hash(), &SparseMerkleProof{Leaf: &leaves[0]}
+	}
+	var left, right []SparseMerkleLeaf
+	for _, leaf := range leaves {
+		if SparseKeyBit(leaf.KeyHash, depth) == 0 {
+			left = append(left, leaf)
+		} else {
+			right = append(right, leaf)
+		}
+	}
+	l, lproof := sparseRoot(left, depth+1, keyHash)
+	r, rproof := sparseRoot(right, depth+1, keyHash)
+	proof, sibling := lproof, r
+	if SparseKeyBit(keyHash, depth) == 1 {
+		proof, sibling = rproof, l
+	}
+	proof.Siblings = append([]common.Uint256{sibling}, proof.Siblings...)
+	return HashSparseNode(l, r), proof
+}
+
+func TestSparseMerkleProof(t *testing.T) {
+	var leaves []SparseMerkleLeaf
+	for i := 0; i < 20; i++ {
+		leaves = append(leaves, SparseMerkleLeaf{
+			KeyHash:   sha256.Sum256([]byte{byte(i)}),
+			ValueHash: sha256.Sum256([]byte{byte(i), 1}),
+		})
+	}
+	root, _ := sparseRoot(leaves, 0, common.UINT256_EMPTY)
+
+	for i := 0; i < 20; i++ {
+		_, proof := sparseRoot(leaves, 0, leaves[i].KeyHash)
+		assert.Nil(t, proof.Verify(root, []byte{byte(i)}, []byte{byte(i), 1}))
+		assert.NotNil(t, proof.Verify(root, []byte{byte(i)}, []byte{byte(i), 2}))
+		assert.NotNil(t, proof.Verify(root, []byte{byte(i)}, nil))
+
+		sink := common.NewZeroCopySink(nil)
+		proof.Serialization(sink)
+		decoded := &SparseMerkleProof{}
+		assert.Nil(t, decoded.Deserialization(common.NewZeroCopySource(sink.Bytes())))
+		assert.Equal(t, proof, decoded)
+	}
+
+	for i := 20; i < 40; i++ {
+		_, proof := sparseRoot(leaves, 0, sha256.Sum256([]byte{byte(i)}))
+		assert.Nil(t, proof.Verify(root, []byte{byte(i)}, nil))
+		assert.NotNil(t, proof.Verify(root, []byte{byte(i)}, []byte{byte(i), 1}))
+		if len(proof.Siblings) > 0 {
+			proof.Siblings[0][0] ^= 1
+			assert.NotNil(t, proof.Verify(root, []byte{byte(i)}, nil))
+		}
+	}
+
+	_, proof := sparseRoot(nil, 0, common.UINT256_EMPTY)
+	assert.Nil(t, proof.Verify(common.UINT256_EMPTY, []byte{1}, nil))
+}
@@ -23,15 +23,22 @@ import (
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/binary"
+	"encoding/hex"
 	"errors"
+	"fmt"
 	"io"
+	"io/ioutil"
 	"math"
 	"math/big"
 	"math/bits"
+	"os"
+	"strings"
 
 	"github.com/cntmio/cntmology-crypto/keypair"
+	s "github.com/cntmio/cntmology-crypto/signature"
 	"github.com/cntmio/cntmology/account"
 	"github.com/cntmio/cntmology/common"
+	"github.com/cntmio/cntmology/core/signature"
 	"github.com/cntmio/cntmology/core/types"
 )
 
@@ -67,10 +74,21 @@ func (self *PeerId) ToHexString() string {
 	return self.val.ToHexString()
 }
 
+//PeerIdFromHexString parse the peer id from the string returned by ToHexString
+func PeerIdFromHexString(s string) (PeerId, error) {
+	val, err := common.AddressFromHexString(s)
+	if err != nil {
+		return PeerId{}, err
+	}
+	return PeerId{val: val}, nil
+}
+
 type PeerKeyId struct {
 	PublicKey keypair.PublicKey
 
 	Id PeerId
+
+	signer *account.Account // only set for local key id
 }
 
 func (self PeerId) GenRandPeerId(prefix uint) PeerId {
@@ -179,7 +197,60 @@ func RandPeerKeyId() *PeerKeyId {
 	return &PeerKeyId{
 		PublicKey: acc.PublicKey,
 		Id:        kid,
+		signer:    acc,
+	}
+}
+
+//LoadPeerKeyId reads the local key id from the file, a new key id is created and saved to the file if it does not
+//exist, so the node keeps its peer id across restarts and the peers can pin it
+func LoadPeerKeyId(file string) (*PeerKeyId, error) {
+	buf, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		keyId := RandPeerKeyId()
+		data := hex.EncodeToString(keypair.SerializePrivateKey(keyId.signer.PrivateKey))
+		if err := ioutil.WriteFile(file, []byte(data), 0600); err != nil {
+			return nil, fmt.Errorf("save peer key to %s: %s", file, err)
+		}
+		return keyId, nil
 	}
+	if err != nil {
+		return nil, err
+	}
+	data, err := hex.DecodeString(strings.TrimSpace(string(buf)))
+	if err != nil {
+		return nil, fmt.Errorf("decode peer key in %s: %s", file, err)
+	}
+	pri, err := keypair.DeserializePrivateKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("deserialize peer key in %s: %s", file, err)
+	}
+	pub := pri.Public()
+	if !validatePublicKey(pub) {
+		return nil, fmt.Errorf("invalid peer key in %s", file)
+	}
+	return &PeerKeyId{
+		PublicKey: pub,
+		Id:        peerIdFromPubkey(pub),
+		signer: &account.Account{
+			PrivateKey: pri,
+			PublicKey:  pub,
+			Address:    types.AddressFromPubKey(pub),
+			SigScheme:  s.SHA256withECDSA,
+		},
+	}, nil
+}
+
+//Sign sign data with the private key of local key id
+func (this *PeerKeyId) Sign(data []byte) ([]byte, error) {
+	if this.signer == nil {
+		return nil, errors.New("peer key id has no private key")
+	}
+	return signature.Sign(this.signer, data)
+}
+
+//Verify check the signature of data is signed by this key id
+func (this *PeerKeyId) Verify(data, sig []byte) error {
+	return signature.Verify(this.PublicKey, data, sig)
 }
 
 func validatePublicKey(pubKey keypair.PublicKey) bool {
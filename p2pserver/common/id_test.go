@@ -20,7 +20,10 @@ package common
 
 import (
 	"fmt"
+	"io/ioutil"
 	"math/rand"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -52,3 +55,27 @@ func TestKadId_IsEmpty(t *testing.T) {
 	kid := RandPeerKeyId()
 	assert.False(t, kid.Id.IsEmpty())
 }
+
+func TestLoadPeerKeyId(t *testing.T) {
+	dir, err := ioutil.TempDir("", "peerkey")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, KEY_FILE_NAME)
+
+	kid, err := LoadPeerKeyId(file)
+	assert.Nil(t, err)
+	info, err := os.Stat(file)
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	loaded, err := LoadPeerKeyId(file)
+	assert.Nil(t, err)
+	assert.Equal(t, kid.Id, loaded.Id)
+	sig, err := loaded.Sign([]byte("data"))
+	assert.Nil(t, err)
+	assert.Nil(t, kid.Verify([]byte("data"), sig))
+
+	assert.Nil(t, ioutil.WriteFile(file, []byte("key"), 0600))
+	_, err = LoadPeerKeyId(file)
+	assert.NotNil(t, err)
+}
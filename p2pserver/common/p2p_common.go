@@ -39,6 +39,9 @@ const (
 	MAX_REQ_RECORD_SIZE = 1000       //the maximum request record size
 	MAX_RESP_CACHE_SIZE = 50         //the maximum response cache
 	MAX_TX_CACHE_SIZE   = 100000     //the maximum txHash cache size
+	SEND_QUEUE_LEN      = 1024       //the maximum messages waiting to send of one priority per link
+	SEND_QUEUE_TIMEOUT  = 5          //seconds a block or consensus message waits for room in a full send queue
+	COMPRESS_MIN_LEN    = 1024       //payload shorter than it is not compressed
 )
 
 //msg cmd const
@@ -53,8 +56,10 @@ const (
 
 //msg type const
 const (
-	MAX_ADDR_NODE_CNT = 64 //the maximum peer address from msg
-	MAX_INV_BLK_CNT   = 64 //the maximum blk hash cnt of inv msg
+	MAX_ADDR_NODE_CNT      = 64          //the maximum peer address from msg
+	MAX_INV_BLK_CNT        = 64          //the maximum blk hash cnt of inv msg
+	MAX_INV_TX_CNT         = 1024        //the maximum tx hash cnt of inv msg
+	MAX_SNAPSHOT_CHUNK_LEN = 1024 * 1024 //the maximum data len of snapshot chunk msg
 )
 
 //info update const
@@ -70,19 +75,43 @@ const (
 	SYNC_BLK_WAIT         = 2     //timespan for blk sync check
 )
 
+//tx relay const
+const (
+	TX_ANNOUNCE_INTERVAL = 200  //interval to announce the batched tx hashes in millisecond
+	TX_REQUEST_TIMEOUT   = 10   //timeout in sec before the tx is requested from another peer
+	MAX_KNOWN_TX_CNT     = 8192 //the maximum tx hashes remembered as known by one peer
+	MAX_TX_REQUESTED     = 8192 //the maximum txs requested and not received yet
+	MAX_TX_ANNOUNCERS    = 8    //the maximum peers remembered to retry the request of a tx from
+	MAX_INV_TX_LOOKUP    = 128  //the maximum ledger lookups of the hashes of one inv msg
+)
+
 const (
 	RecentPeerElapseLimit = 60
 )
 
 //cap flag
-const HTTP_INFO_FLAG = 0 //peer`s http info bit in cap field
+const (
+	HTTP_INFO_FLAG = 0 //peer`s http info bit in cap field
+	ENCRYPT_FLAG   = 1 //peer`s encrypted transport support bit in cap field
+	COMPRESS_FLAG  = 2 //peer`s message compression support bit in cap field
+)
 
 //recent ccntmact const
 const (
 	RECENT_TIMEOUT   = 60
 	RECENT_FILE_NAME = "peers.recent"
+	BANNED_FILE_NAME = "peers.banned"
+	KEY_FILE_NAME    = "peer.key"
 )
 
+//TrafficStats is the bytes and messages transferred on p2p links
+type TrafficStats struct {
+	BytesSent uint64
+	BytesRecv uint64
+	MsgSent   uint64
+	MsgRecv   uint64
+}
+
 //PeerAddr represent peer`s net information
 type PeerAddr struct {
 	Time     int64    //latest timestamp
@@ -114,10 +143,18 @@ const (
 	FINDNODE_TYPE      = "findnode"    // find node using dht
 	FINDNODE_RESP_TYPE = "findnodeack" // find node using dht
 	UPDATE_KADID_TYPE  = "updatekadid" //update node kadid
+	KEY_EXCHANGE_TYPE  = "keyexchange" //ephemeral key of encrypted transport
+	KEY_AUTH_TYPE      = "keyauth"     //signature of encrypted transport handshake
+	COMPRESSED_TYPE    = "compressed"  //snappy compressed message
 
 	GET_SUBNET_MEMBERS_TYPE = "getmembers" // request subnet members
 	SUBNET_MEMBERS_TYPE     = "members"    // response subnet members
 	SUBNET_OFFLINE_TYPE     = "offline"    // offline witness message
+
+	GET_SNAPSHOT_INFO_TYPE  = "getsnapinfo"  // request the latest state snapshot
+	SNAPSHOT_INFO_TYPE      = "snapinfo"     // response the latest state snapshot
+	GET_SNAPSHOT_CHUNK_TYPE = "getsnapchunk" // request a chunk of the state snapshot
+	SNAPSHOT_CHUNK_TYPE     = "snapchunk"    // response a chunk of the state snapshot
 )
 
 //ParseIPAddr return ip address
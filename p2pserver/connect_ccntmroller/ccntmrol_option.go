@@ -18,7 +18,12 @@
 package connect_ccntmroller
 
 import (
+	"fmt"
+	"net"
+	"strings"
+
 	"github.com/cntmio/cntmology/common/config"
+	"github.com/cntmio/cntmology/p2pserver/common"
 	p2p "github.com/cntmio/cntmology/p2pserver/net/protocol"
 )
 
@@ -26,7 +31,13 @@ type ConnCtrlOption struct {
 	MaxConnOutBound     uint
 	MaxConnInBound      uint
 	MaxConnInBoundPerIP uint
-	ReservedPeers       p2p.AddressFilter // enabled if not empty
+	ReservedPeers       p2p.AddressFilter        // enabled if not empty
+	RequireEncryption   bool                     // reject peers which do not support encrypted transport, it does not authenticate them
+	PinnedPeers         map[string]common.PeerId // peer ids pinned to ip addresses, the peers there must authenticate with them
+	BannedPeers         p2p.PeerFilter           // peers refused at handshake
+	BannedAddrs         p2p.AddressFilter        // addresses refused at handshake
+	MaxSendRate         uint64                   // bytes per second sent to all peers, 0 means unlimited
+	MaxPeerSendRate     uint64                   // bytes per second sent to each peer, 0 means unlimited
 	dialer              Dialer
 }
 
@@ -36,6 +47,8 @@ func NewConnCtrlOption() ConnCtrlOption {
 		MaxConnOutBound:     config.DEFAULT_MAX_CONN_OUT_BOUND,
 		MaxConnInBoundPerIP: config.DEFAULT_MAX_CONN_IN_BOUND_FOR_SINGLE_IP,
 		ReservedPeers:       p2p.AllAddrFilter(),
+		BannedPeers:         p2p.NonePeerFilter(),
+		BannedAddrs:         p2p.NoneAddrFilter(),
 		dialer:              &noTlsDialer{},
 	}
 }
@@ -60,6 +73,32 @@ func (self ConnCtrlOption) ReservedOnly(peers []string) ConnCtrlOption {
 	return self
 }
 
+func (self ConnCtrlOption) RequireEncrypt(require bool) ConnCtrlOption {
+	self.RequireEncryption = require
+	return self
+}
+
+func (self ConnCtrlOption) PinPeers(pinned map[string]common.PeerId) ConnCtrlOption {
+	self.PinnedPeers = pinned
+	return self
+}
+
+func (self ConnCtrlOption) WithBannedPeers(banned p2p.PeerFilter) ConnCtrlOption {
+	self.BannedPeers = banned
+	return self
+}
+
+func (self ConnCtrlOption) WithBannedAddrs(banned p2p.AddressFilter) ConnCtrlOption {
+	self.BannedAddrs = banned
+	return self
+}
+
+func (self ConnCtrlOption) SendRate(total, perPeer uint64) ConnCtrlOption {
+	self.MaxSendRate = total
+	self.MaxPeerSendRate = perPeer
+	return self
+}
+
 func (self ConnCtrlOption) WithDialer(dialer Dialer) ConnCtrlOption {
 	self.dialer = dialer
 	return self
@@ -71,12 +110,40 @@ func ConnCtrlOptionFromConfig(config *config.P2PNodeConfig, reserveFilter p2p.Ad
 		err = e
 		return
 	}
+	pinned, e := ParsePinnedPeers(config.PinnedPeers)
+	if e != nil {
+		err = e
+		return
+	}
 	return ConnCtrlOption{
 		MaxConnOutBound:     config.MaxConnOutBound,
 		MaxConnInBound:      config.MaxConnInBound,
 		MaxConnInBoundPerIP: config.MaxConnInBoundForSingleIP,
 		ReservedPeers:       reserveFilter,
+		RequireEncryption:   config.RequireEncryption,
+		PinnedPeers:         pinned,
+		BannedPeers:         p2p.NonePeerFilter(),
+		BannedAddrs:         p2p.NoneAddrFilter(),
+		MaxSendRate:         uint64(config.MaxBandwidth) * 1024,
+		MaxPeerSendRate:     uint64(config.MaxPeerBandwidth) * 1024,
 
 		dialer: dialer,
 	}, nil
 }
+
+// ParsePinnedPeers parses the pinned peers in the format of <ip>=<peer id>
+func ParsePinnedPeers(peers []string) (map[string]common.PeerId, error) {
+	pinned := make(map[string]common.PeerId, len(peers))
+	for _, entry := range peers {
+		parts := strings.Split(entry, "=")
+		if len(parts) != 2 || net.ParseIP(strings.TrimSpace(parts[0])) == nil {
+			return nil, fmt.Errorf("invalid pinned peer %s, expect <ip>=<peer id>", entry)
+		}
+		id, err := common.PeerIdFromHexString(strings.TrimSpace(parts[1]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid peer id of pinned peer %s: %s", entry, err)
+		}
+		pinned[strings.TrimSpace(parts[0])] = id
+	}
+	return pinned, nil
+}
@@ -182,12 +182,13 @@ func (self *ConnectCcntmroller) AcceptConnect(conn net.Conn) (*peer.PeerInfo, ne
 		return nil, nil, err
 	}
 
-	peerInfo, err := handshake.HandshakeServer(self.peerInfo, self.selfId, conn)
+	peerInfo, secure, err := handshake.HandshakeServer(self.peerInfo, self.selfId, conn, self.RequireEncryption)
 	if err != nil {
 		return nil, nil, err
 	}
+	conn = secure
 
-	err = self.afterHandshakeCheck(peerInfo, addr)
+	err = self.afterHandshakeCheck(peerInfo, addr, handshake.IsEncrypted(conn))
 	if err != nil {
 		return nil, nil, err
 	}
@@ -216,13 +217,14 @@ func (self *ConnectCcntmroller) Connect(addr string) (*peer.PeerInfo, net.Conn,
 		return nil, nil, err
 	}
 
-	peerInfo, err := handshake.HandshakeClient(self.peerInfo, self.selfId, conn)
+	peerInfo, secure, err := handshake.HandshakeClient(self.peerInfo, self.selfId, conn, self.RequireEncryption)
 	if err != nil {
 		_ = conn.Close()
 		return nil, nil, err
 	}
+	conn = secure
 
-	err = self.afterHandshakeCheck(peerInfo, conn.RemoteAddr().String())
+	err = self.afterHandshakeCheck(peerInfo, conn.RemoteAddr().String(), handshake.IsEncrypted(conn))
 	if err != nil {
 		_ = conn.Close()
 		return nil, nil, err
@@ -234,14 +236,49 @@ func (self *ConnectCcntmroller) Connect(addr string) (*peer.PeerInfo, net.Conn,
 	return peerInfo, wrapped, nil
 }
 
-func (self *ConnectCcntmroller) afterHandshakeCheck(remotePeer *peer.PeerInfo, remoteAddr string) error {
+func (self *ConnectCcntmroller) afterHandshakeCheck(remotePeer *peer.PeerInfo, remoteAddr string, encrypted bool) error {
 	if err := self.isHandWithSelf(remotePeer, remoteAddr); err != nil {
 		return err
 	}
+	if err := self.checkPinnedPeer(remotePeer, remoteAddr, encrypted); err != nil {
+		return err
+	}
+
+	if self.BannedPeers != nil && self.BannedPeers.Ccntmains(remotePeer.Id) {
+		return fmt.Errorf("peer %s is banned", remotePeer.Id.ToHexString())
+	}
+	if self.BannedAddrs != nil && self.BannedAddrs.Ccntmains(remoteAddr) {
+		return fmt.Errorf("peer %s from banned address %s", remotePeer.Id.ToHexString(), remoteAddr)
+	}
 
 	return self.checkPeerIdAndIP(remotePeer, remoteAddr)
 }
 
+//checkPinnedPeer rejects the peer at a pinned ip address unless it authenticated with the pinned peer id, the peer
+//id is only authenticated by the encrypted handshake
+func (self *ConnectCcntmroller) checkPinnedPeer(remotePeer *peer.PeerInfo, remoteAddr string, encrypted bool) error {
+	if len(self.PinnedPeers) == 0 {
+		return nil
+	}
+	ip, err := common.ParseIPAddr(remoteAddr)
+	if err != nil {
+		return fmt.Errorf("[p2p]parse ip error %v", err.Error())
+	}
+	pinned, ok := self.PinnedPeers[ip]
+	if !ok {
+		return nil
+	}
+	if !encrypted {
+		return fmt.Errorf("peer %s at pinned address %s is not authenticated by encrypted transport",
+			remotePeer.Id.ToHexString(), remoteAddr)
+	}
+	if remotePeer.Id != pinned {
+		return fmt.Errorf("peer %s at pinned address %s, expect peer %s", remotePeer.Id.ToHexString(),
+			remoteAddr, pinned.ToHexString())
+	}
+	return nil
+}
+
 func (self *ConnectCcntmroller) beforeHandshakeCheck(addr string, index int) error {
 	err := self.checkReservedPeers(addr)
 	if err != nil {
@@ -108,7 +108,7 @@ func TestConnectCcntmroller_CanDetectSelfAddress(t *testing.T) {
 
 		c, s := trans.Pipe()
 		go func() {
-			_, _ = handshake.HandshakeClient(server.peerInfo, server.Key, c)
+			_, _, _ = handshake.HandshakeClient(server.peerInfo, server.Key, c, false)
 		}()
 
 		_, _, err := server.AcceptConnect(s)
@@ -132,7 +132,7 @@ func TestConnectCcntmroller_AcceptConnect_MaxInBound(t *testing.T) {
 		wg.Add(1)
 		go func(i int) {
 			defer wg.Done()
-			_, err := handshake.HandshakeClient(client.peerInfo, client.Key, conn1)
+			_, _, err := handshake.HandshakeClient(client.peerInfo, client.Key, conn1, false)
 			if i < int(maxInboud) {
 				assert.Nil(t, err)
 			} else {
@@ -194,7 +194,7 @@ func TestConnCtrlOption_MaxInBoundPerIp(t *testing.T) {
 		wg.Add(1)
 		go func(i int) {
 			defer wg.Done()
-			_, err := handshake.HandshakeClient(client.peerInfo, client.Key, conn1)
+			_, _, err := handshake.HandshakeClient(client.peerInfo, client.Key, conn1, false)
 			if i < int(maxInBoundPerIp) {
 				assert.Nil(t, err)
 			} else {
@@ -224,11 +224,93 @@ func checkServer(t *testing.T, client, server *Node, clientConns chan<- net.Conn
 	}
 	assert.Nil(t, err)
 	info.Addr = "" // client.Info is not set
+	info.Compress = false // negotiated in handshake
 	assert.Equal(t, info, client.Info)
 
 	clientConns <- conn
 }
 
+type bannedFilter map[common.PeerId]bool
+
+func (self bannedFilter) Ccntmains(id common.PeerId) bool {
+	return self[id]
+}
+
+func TestConnectCcntmroller_BannedPeer(t *testing.T) {
+	trans := NewTransport(t)
+	client := NewNode(NewConnCtrlOption())
+	server := NewNode(NewConnCtrlOption().WithBannedPeers(bannedFilter{client.Info.Id: true}))
+
+	conn1, conn2 := trans.Pipe()
+	go func() {
+		_, _, _ = handshake.HandshakeClient(client.peerInfo, client.Key, conn1, false)
+	}()
+	_, _, err := server.AcceptConnect(conn2)
+	assert.NotNil(t, err)
+	assert.Ccntmains(t, err.Error(), "banned")
+	assert.Equal(t, server.InboundsCount(), uint(0))
+	_ = conn1.Close()
+	_ = conn2.Close()
+}
+
+func TestConnectCcntmroller_BannedAddr(t *testing.T) {
+	trans := NewTransport(t)
+	client := NewNode(NewConnCtrlOption())
+	server := NewNode(NewConnCtrlOption().WithBannedAddrs(NewStaticReserveFilter([]string{"127.0.0.1"})))
+
+	conn1, conn2 := trans.Pipe()
+	go func() {
+		_, _, _ = handshake.HandshakeClient(client.peerInfo, client.Key, conn1, false)
+	}()
+	_, _, err := server.AcceptConnect(conn2)
+	assert.NotNil(t, err)
+	assert.Ccntmains(t, err.Error(), "banned address")
+	assert.Equal(t, server.InboundsCount(), uint(0))
+	_ = conn1.Close()
+	_ = conn2.Close()
+}
+
+func TestConnectCcntmroller_PinnedPeer(t *testing.T) {
+	trans := NewTransport(t)
+	client := NewNode(NewConnCtrlOption())
+	other := NewNode(NewConnCtrlOption())
+	server := NewNode(NewConnCtrlOption().PinPeers(map[string]common.PeerId{"127.0.0.1": client.Info.Id}))
+
+	// a peer with another key id at the pinned address
+	conn1, conn2 := trans.Pipe()
+	go func() {
+		_, _, _ = handshake.HandshakeClient(other.peerInfo, other.Key, conn1, false)
+	}()
+	_, _, err := server.AcceptConnect(conn2)
+	assert.NotNil(t, err)
+	assert.Ccntmains(t, err.Error(), "pinned address")
+	_ = conn1.Close()
+	_ = conn2.Close()
+
+	// the pinned peer authenticated by the encrypted handshake
+	conn1, conn2 = trans.Pipe()
+	go func() {
+		_, _, _ = handshake.HandshakeClient(client.peerInfo, client.Key, conn1, false)
+	}()
+	_, conn, err := server.AcceptConnect(conn2)
+	assert.Nil(t, err)
+	assert.Equal(t, server.InboundsCount(), uint(1))
+	_ = conn.Close()
+	_ = conn1.Close()
+}
+
+func TestParsePinnedPeers(t *testing.T) {
+	id := common.RandPeerKeyId().Id
+	pinned, err := ParsePinnedPeers([]string{"127.0.0.1=" + id.ToHexString()})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]common.PeerId{"127.0.0.1": id}, pinned)
+
+	for _, entry := range []string{"127.0.0.1", "localhost=" + id.ToHexString(), "127.0.0.1=peer"} {
+		_, err = ParsePinnedPeers([]string{entry})
+		assert.NotNil(t, err, entry)
+	}
+}
+
 func TestCheckReserveWithDomain(t *testing.T) {
 	a := assert.New(t)
 	// this domain only have one A record, so we can assure two lookup below return the same IP
@@ -31,10 +31,13 @@ import (
 
 var HANDSHAKE_DURATION = 10 * time.Second // handshake time can not exceed this duration, or will treat as attack.
 
-func HandshakeClient(info *peer.PeerInfo, selfId *common.PeerKeyId, conn net.Conn) (*peer.PeerInfo, error) {
+//HandshakeClient exchange version with the remote peer and return the connection used by the link,
+//which is encrypted if both side support it
+func HandshakeClient(info *peer.PeerInfo, selfId *common.PeerKeyId, conn net.Conn,
+	requireEncrypt bool) (*peer.PeerInfo, net.Conn, error) {
 	version := newVersion(info)
 	if err := conn.SetDeadline(time.Now().Add(HANDSHAKE_DURATION)); err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	defer func() {
 		_ = conn.SetDeadline(time.Time{}) //reset back
@@ -43,62 +46,72 @@ func HandshakeClient(info *peer.PeerInfo, selfId *common.PeerKeyId, conn net.Con
 	// 1. sendMsg version
 	err := sendMsg(conn, version)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	// 2. read version
 	msg, _, err := types.ReadMessage(conn)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	receivedVersion, ok := msg.(*types.Version)
 	if !ok {
-		return nil, fmt.Errorf("expected version message, but got message type: %s", msg.CmdType())
+		return nil, nil, fmt.Errorf("expected version message, but got message type: %s", msg.CmdType())
 	}
 
 	// 3. update kadId
 	kid := common.PseudoPeerIdFromUint64(receivedVersion.P.Nonce)
+	var remoteId *common.PeerKeyId
 	if useDHT(receivedVersion.P.SoftVersion, info.SoftVersion) {
 		err = sendMsg(conn, &types.UpdatePeerKeyId{KadKeyId: selfId})
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 		// 4. read kadkeyid
 		msg, _, err = types.ReadMessage(conn)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 		kadKeyId, ok := msg.(*types.UpdatePeerKeyId)
 		if !ok {
-			return nil, fmt.Errorf("handshake failed, expect kad id message, got %s", msg.CmdType())
+			return nil, nil, fmt.Errorf("handshake failed, expect kad id message, got %s", msg.CmdType())
 		}
 
 		kid = kadKeyId.KadKeyId.Id
+		remoteId = kadKeyId.KadKeyId
 	}
 
 	// 5. sendMsg ack
 	err = sendMsg(conn, &types.VerACK{})
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	msg, _, err = types.ReadMessage(conn)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	// 6. receive verack
 	if _, ok := msg.(*types.VerACK); !ok {
-		return nil, fmt.Errorf("handshake failed, expect verack message, got %s", msg.CmdType())
+		return nil, nil, fmt.Errorf("handshake failed, expect verack message, got %s", msg.CmdType())
 	}
 
-	return createPeerInfo(receivedVersion, kid, conn.RemoteAddr().String()), nil
+	// 7. upgrade to encrypted transport
+	secure, err := upgradeConn(conn, true, requireEncrypt, version, receivedVersion, selfId, remoteId)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return createPeerInfo(receivedVersion, kid, conn.RemoteAddr().String()), secure, nil
 }
 
-func HandshakeServer(info *peer.PeerInfo, selfId *common.PeerKeyId, conn net.Conn) (*peer.PeerInfo, error) {
+//HandshakeServer is the counterpart of HandshakeClient for inbound connection
+func HandshakeServer(info *peer.PeerInfo, selfId *common.PeerKeyId, conn net.Conn,
+	requireEncrypt bool) (*peer.PeerInfo, net.Conn, error) {
 	ver := newVersion(info)
 	if err := conn.SetDeadline(time.Now().Add(HANDSHAKE_DURATION)); err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	defer func() {
 		_ = conn.SetDeadline(time.Time{}) //reset back
@@ -107,54 +120,62 @@ func HandshakeServer(info *peer.PeerInfo, selfId *common.PeerKeyId, conn net.Con
 	// 1. read version
 	msg, _, err := types.ReadMessage(conn)
 	if err != nil {
-		return nil, fmt.Errorf("[HandshakeServer] ReadMessage failed, error: %s", err)
+		return nil, nil, fmt.Errorf("[HandshakeServer] ReadMessage failed, error: %s", err)
 	}
 	if msg.CmdType() != common.VERSION_TYPE {
-		return nil, fmt.Errorf("[HandshakeServer] expected version message")
+		return nil, nil, fmt.Errorf("[HandshakeServer] expected version message")
 	}
 	version := msg.(*types.Version)
 
 	// 2. sendMsg version
 	err = sendMsg(conn, ver)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	// 3. read update kadkey id
 	kid := common.PseudoPeerIdFromUint64(version.P.Nonce)
+	var remoteId *common.PeerKeyId
 	if useDHT(version.P.SoftVersion, info.SoftVersion) {
 		msg, _, err := types.ReadMessage(conn)
 		if err != nil {
-			return nil, fmt.Errorf("[HandshakeServer] ReadMessage failed, error: %s", err)
+			return nil, nil, fmt.Errorf("[HandshakeServer] ReadMessage failed, error: %s", err)
 		}
 		kadkeyId, ok := msg.(*types.UpdatePeerKeyId)
 		if !ok {
-			return nil, fmt.Errorf("[HandshakeServer] expected update kadkeyid message")
+			return nil, nil, fmt.Errorf("[HandshakeServer] expected update kadkeyid message")
 		}
 		kid = kadkeyId.KadKeyId.Id
+		remoteId = kadkeyId.KadKeyId
 		// 4. sendMsg update kadkey id
 		err = sendMsg(conn, &types.UpdatePeerKeyId{KadKeyId: selfId})
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 	}
 
 	// 5. read version ack
 	msg, _, err = types.ReadMessage(conn)
 	if err != nil {
-		return nil, fmt.Errorf("[HandshakeServer] ReadMessage failed, error: %s", err)
+		return nil, nil, fmt.Errorf("[HandshakeServer] ReadMessage failed, error: %s", err)
 	}
 	if msg.CmdType() != common.VERACK_TYPE {
-		return nil, fmt.Errorf("[HandshakeServer] expected version ack message")
+		return nil, nil, fmt.Errorf("[HandshakeServer] expected version ack message")
 	}
 
 	// 6. sendMsg ack
 	err = sendMsg(conn, &types.VerACK{})
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+
+	// 7. upgrade to encrypted transport
+	secure, err := upgradeConn(conn, false, requireEncrypt, version, ver, selfId, remoteId)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	return createPeerInfo(version, kid, conn.RemoteAddr().String()), nil
+	return createPeerInfo(version, kid, conn.RemoteAddr().String()), secure, nil
 }
 
 func sendMsg(conn net.Conn, msg types.Message) error {
@@ -169,8 +190,10 @@ func sendMsg(conn net.Conn, msg types.Message) error {
 }
 
 func createPeerInfo(version *types.Version, kid common.PeerId, addr string) *peer.PeerInfo {
-	return peer.NewPeerInfo(kid, version.P.Version, version.P.Services, version.P.Relay != 0, version.P.HttpInfoPort,
+	info := peer.NewPeerInfo(kid, version.P.Version, version.P.Services, version.P.Relay != 0, version.P.HttpInfoPort,
 		version.P.SyncPort, version.P.StartHeight, version.P.SoftVersion, addr)
+	info.Compress = version.P.Cap[common.COMPRESS_FLAG] == 0x01
+	return info
 }
 
 func newVersion(peerInfo *peer.PeerInfo) *types.Version {
@@ -197,10 +220,27 @@ func newVersion(peerInfo *peer.PeerInfo) *types.Version {
 	} else {
 		version.P.Cap[common.HTTP_INFO_FLAG] = 0x00
 	}
+	version.P.Cap[common.ENCRYPT_FLAG] = 0x01
+	version.P.Cap[common.COMPRESS_FLAG] = 0x01
 
 	return &version
 }
 
+//upgradeConn encrypt the connection if both side advertise it in version and exchanged peer key id,
+//otherwise keep the plaintext connection for older peers unless encryption is required
+func upgradeConn(conn net.Conn, isClient, requireEncrypt bool, clientVer, serverVer *types.Version,
+	selfId, remoteId *common.PeerKeyId) (net.Conn, error) {
+	if remoteId == nil || !supportEncrypt(clientVer) || !supportEncrypt(serverVer) {
+		if requireEncrypt {
+			return nil, fmt.Errorf("handshake failed, peer %s does not support encrypted transport",
+				conn.RemoteAddr().String())
+		}
+		return conn, nil
+	}
+
+	return secureHandshake(conn, isClient, clientVer, serverVer, selfId, remoteId)
+}
+
 func useDHT(client, server string) bool {
 	// we make this symmetric, because config.Version is depend on compile option, so to avoid the case:
 	// remote version is 1.9.0 and we support DHT, but the config.Version is not valid.
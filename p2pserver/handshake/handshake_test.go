@@ -18,12 +18,15 @@
 package handshake
 
 import (
+	"encoding/binary"
+	"io"
 	"math/rand"
 	"net"
 	"sync"
 	"testing"
 	"time"
 
+	common2 "github.com/cntmio/cntmology/common"
 	"github.com/cntmio/cntmology/p2pserver/common"
 	"github.com/cntmio/cntmology/p2pserver/message/types"
 	"github.com/cntmio/cntmology/p2pserver/peer"
@@ -76,13 +79,13 @@ func TestHandshakeNormal(t *testing.T) {
 			err  error
 		}, 2)
 		go func() {
-			info, err := HandshakeClient(client.Info, client.Id, client.Conn)
+			info, _, err := HandshakeClient(client.Info, client.Id, client.Conn, false)
 			result[0].err = err
 			result[0].info = [2]*peer.PeerInfo{info, server.Info}
 			wg.Done()
 		}()
 		go func() {
-			info, err := HandshakeServer(server.Info, server.Id, server.Conn)
+			info, _, err := HandshakeServer(server.Info, server.Id, server.Conn, false)
 			result[1].err = err
 			result[1].info = [2]*peer.PeerInfo{info, client.Info}
 			wg.Done()
@@ -99,7 +102,7 @@ func TestHandshakeNormal(t *testing.T) {
 func TestHandshakeTimeout(t *testing.T) {
 	client, _ := NewPair()
 
-	_, err := HandshakeClient(client.Info, client.Id, client.Conn)
+	_, _, err := HandshakeClient(client.Info, client.Id, client.Conn, false)
 	assert.NotNil(t, err)
 	assert.Ccntmains(t, err.Error(), "i/o timeout") // golang 1.5 error msg changed
 }
@@ -111,11 +114,112 @@ func TestHandshakeWrcntmMsg(t *testing.T) {
 		assert.Nil(t, err)
 	}()
 
-	_, err := HandshakeServer(server.Info, server.Id, server.Conn)
+	_, _, err := HandshakeServer(server.Info, server.Id, server.Conn, false)
 	assert.NotNil(t, err)
 	assert.Ccntmains(t, err.Error(), "expected version message")
 }
 
+func handshakePair(client, server Node, requireEncrypt bool) (conns [2]net.Conn, errs [2]error) {
+	wg := sync.WaitGroup{}
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		_, conns[0], errs[0] = HandshakeClient(client.Info, client.Id, client.Conn, requireEncrypt)
+		if errs[0] != nil {
+			_ = client.Conn.Close()
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		_, conns[1], errs[1] = HandshakeServer(server.Info, server.Id, server.Conn, requireEncrypt)
+		if errs[1] != nil {
+			_ = server.Conn.Close()
+		}
+	}()
+	wg.Wait()
+	return
+}
+
+func TestHandshakeEncrypted(t *testing.T) {
+	client, server := NewPair()
+	client.Info.SoftVersion = common.MIN_VERSION_FOR_DHT
+	server.Info.SoftVersion = common.MIN_VERSION_FOR_DHT
+
+	conns, errs := handshakePair(client, server, true)
+	assert.Nil(t, errs[0])
+	assert.Nil(t, errs[1])
+	assert.IsType(t, &secureConn{}, conns[0])
+	assert.IsType(t, &secureConn{}, conns[1])
+
+	// larger than one frame
+	data := make([]byte, 3*MAX_FRAME_LEN+10)
+	rand.Read(data)
+	go func() {
+		_, err := conns[0].Write(data)
+		assert.Nil(t, err)
+		err = sendMsg(conns[0], &types.VerACK{})
+		assert.Nil(t, err)
+	}()
+	received := make([]byte, len(data))
+	_, err := io.ReadFull(conns[1], received)
+	assert.Nil(t, err)
+	assert.Equal(t, data, received)
+	msg, _, err := types.ReadMessage(conns[1])
+	assert.Nil(t, err)
+	assert.Equal(t, common.VERACK_TYPE, msg.CmdType())
+
+	go func() {
+		err := sendMsg(conns[1], &types.Ping{Height: 10})
+		assert.Nil(t, err)
+	}()
+	msg, _, err = types.ReadMessage(conns[0])
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(10), msg.(*types.Ping).Height)
+}
+
+func TestHandshakeLegacyPeer(t *testing.T) {
+	client, server := NewPair()
+	client.Info.SoftVersion = "v1.9.0"
+	server.Info.SoftVersion = common.MIN_VERSION_FOR_DHT
+
+	conns, errs := handshakePair(client, server, false)
+	assert.Nil(t, errs[0])
+	assert.Nil(t, errs[1])
+	assert.Equal(t, client.Conn, conns[0])
+	assert.Equal(t, server.Conn, conns[1])
+
+	client, server = NewPair()
+	client.Info.SoftVersion = "v1.9.0"
+	server.Info.SoftVersion = common.MIN_VERSION_FOR_DHT
+	_, errs = handshakePair(client, server, true)
+	assert.NotNil(t, errs[0])
+	assert.NotNil(t, errs[1])
+}
+
+func TestHandshakeEncryptedTamper(t *testing.T) {
+	client, server := NewPair()
+	client.Info.SoftVersion = common.MIN_VERSION_FOR_DHT
+	server.Info.SoftVersion = common.MIN_VERSION_FOR_DHT
+
+	conns, errs := handshakePair(client, server, true)
+	assert.Nil(t, errs[0])
+	assert.Nil(t, errs[1])
+
+	go func() {
+		// flip one bit of the ciphertext
+		sink := common2.NewZeroCopySink(nil)
+		types.WriteMessage(sink, &types.VerACK{})
+		secure := conns[0].(*secureConn)
+		hdr := make([]byte, FRAME_HDR_LEN)
+		binary.BigEndian.PutUint32(hdr, uint32(len(sink.Bytes())+secure.sendAead.Overhead()))
+		frame := secure.sendAead.Seal(nil, makeNonce(secure.sendNonce), sink.Bytes(), hdr)
+		frame[0] ^= 0x01
+		_, _ = client.Conn.Write(append(hdr, frame...))
+	}()
+	_, _, err := types.ReadMessage(conns[1])
+	assert.NotNil(t, err)
+}
+
 func TestVersion(t *testing.T) {
 	assert.True(t, supportDHT(common.MIN_VERSION_FOR_DHT))
 	assert.True(t, supportDHT("1.9.1"))
@@ -0,0 +1,268 @@
+/*
+ * Copyright (C) 2018 The cntmology Authors
+ * This file is part of The cntmology library.
+ *
+ * The cntmology is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The cntmology is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * alcntm with The cntmology.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package handshake
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+
+	common2 "github.com/cntmio/cntmology/common"
+	"github.com/cntmio/cntmology/p2pserver/common"
+	"github.com/cntmio/cntmology/p2pserver/message/types"
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/curve25519"
+	"golang.org/x/crypto/hkdf"
+)
+
+const (
+	SECURE_PROTOCOL_NAME = "cntm-p2p-secure-v1"
+	MAX_FRAME_LEN        = 64 * 1024 // the maximum plaintext length of one encrypted frame
+	FRAME_HDR_LEN        = 4
+)
+
+var ErrFrameTooLarge = errors.New("[p2p]encrypted frame too large")
+
+//supportEncrypt check whether the version message advertises encrypted transport
+func supportEncrypt(version *types.Version) bool {
+	return version.P.Cap[common.ENCRYPT_FLAG] == 0x01
+}
+
+//handshakeTranscript binds the negotiated version messages, the static key ids and the ephemeral keys of both side
+func handshakeTranscript(clientVer, serverVer *types.Version, clientId, serverId *common.PeerKeyId,
+	clientEph, serverEph []byte) []byte {
+	sink := common2.NewZeroCopySink(nil)
+	sink.WriteString(SECURE_PROTOCOL_NAME)
+	types.WriteMessage(sink, clientVer)
+	types.WriteMessage(sink, serverVer)
+	clientId.Serialization(sink)
+	serverId.Serialization(sink)
+	sink.WriteVarBytes(clientEph)
+	sink.WriteVarBytes(serverEph)
+	hash := sha256.Sum256(sink.Bytes())
+	return hash[:]
+}
+
+func signedData(role string, transcript []byte) []byte {
+	return append([]byte(SECURE_PROTOCOL_NAME+" "+role), transcript...)
+}
+
+//secureHandshake runs the authenticated key exchange after version ack and returns the encrypted connection.
+//ephemeral x25519 keys are exchanged first, then each side signs the transcript with its peer key id,
+//so the session keys are bound to the PeerKeyId of the remote peer.
+func secureHandshake(conn net.Conn, isClient bool, clientVer, serverVer *types.Version,
+	selfId, remoteId *common.PeerKeyId) (net.Conn, error) {
+	if remoteId == nil {
+		return nil, errors.New("[p2p]encrypted transport require peer key id")
+	}
+	ephPriv := make([]byte, curve25519.ScalarSize)
+	if _, err := rand.Read(ephPriv); err != nil {
+		return nil, err
+	}
+	ephPub, err := curve25519.X25519(ephPriv, curve25519.Basepoint)
+	if err != nil {
+		return nil, err
+	}
+
+	remoteEph, err := exchangeMsg(conn, isClient, &types.KeyExchange{EphemeralKey: ephPub})
+	if err != nil {
+		return nil, err
+	}
+	keyExchange, ok := remoteEph.(*types.KeyExchange)
+	if !ok {
+		return nil, fmt.Errorf("handshake failed, expect key exchange message, got %s", remoteEph.CmdType())
+	}
+	shared, err := curve25519.X25519(ephPriv, keyExchange.EphemeralKey)
+	if err != nil {
+		return nil, fmt.Errorf("handshake failed, invalid ephemeral key: %s", err)
+	}
+
+	clientId, serverId := selfId, remoteId
+	clientEph, serverEph := ephPub, keyExchange.EphemeralKey
+	selfRole, remoteRole := "client", "server"
+	if !isClient {
+		clientId, serverId = remoteId, selfId
+		clientEph, serverEph = keyExchange.EphemeralKey, ephPub
+		selfRole, remoteRole = "server", "client"
+	}
+	transcript := handshakeTranscript(clientVer, serverVer, clientId, serverId, clientEph, serverEph)
+
+	sig, err := selfId.Sign(signedData(selfRole, transcript))
+	if err != nil {
+		return nil, err
+	}
+	remoteAuth, err := exchangeMsg(conn, isClient, &types.KeyAuth{Signature: sig})
+	if err != nil {
+		return nil, err
+	}
+	keyAuth, ok := remoteAuth.(*types.KeyAuth)
+	if !ok {
+		return nil, fmt.Errorf("handshake failed, expect key auth message, got %s", remoteAuth.CmdType())
+	}
+	if err = remoteId.Verify(signedData(remoteRole, transcript), keyAuth.Signature); err != nil {
+		return nil, fmt.Errorf("handshake failed, peer %s auth: %s", remoteId.Id.ToHexString(), err)
+	}
+
+	secure, err := newSecureConn(conn, isClient, shared, transcript)
+	if err != nil {
+		return nil, err
+	}
+	return secure, nil
+}
+
+//IsEncrypted check whether the connection returned by the handshake is encrypted, only the key id of the remote
+//peer of an encrypted connection is authenticated
+func IsEncrypted(conn net.Conn) bool {
+	_, ok := conn.(*secureConn)
+	return ok
+}
+
+//exchangeMsg send msg and read the reply of remote, client always send first
+func exchangeMsg(conn net.Conn, isClient bool, msg types.Message) (types.Message, error) {
+	if isClient {
+		if err := sendMsg(conn, msg); err != nil {
+			return nil, err
+		}
+	}
+	remote, _, err := types.ReadMessage(conn)
+	if err != nil {
+		return nil, err
+	}
+	if !isClient {
+		if err := sendMsg(conn, msg); err != nil {
+			return nil, err
+		}
+	}
+	return remote, nil
+}
+
+//secureConn encrypts every frame written to the underlying connection with chacha20-poly1305.
+//frame format: 4 bytes big endian ciphertext length | ciphertext
+type secureConn struct {
+	net.Conn
+
+	sendLock  sync.Mutex
+	sendAead  cipher.AEAD
+	sendNonce uint64
+
+	recvLock  sync.Mutex
+	recvAead  cipher.AEAD
+	recvNonce uint64
+	recvBuf   []byte // decrypted data not read yet
+}
+
+func newSecureConn(conn net.Conn, isClient bool, shared, transcript []byte) (*secureConn, error) {
+	keys := make([]byte, 2*chacha20poly1305.KeySize)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, shared, transcript, []byte(SECURE_PROTOCOL_NAME)), keys); err != nil {
+		return nil, err
+	}
+	clientKey, serverKey := keys[:chacha20poly1305.KeySize], keys[chacha20poly1305.KeySize:]
+	sendKey, recvKey := clientKey, serverKey
+	if !isClient {
+		sendKey, recvKey = serverKey, clientKey
+	}
+	sendAead, err := chacha20poly1305.New(sendKey)
+	if err != nil {
+		return nil, err
+	}
+	recvAead, err := chacha20poly1305.New(recvKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &secureConn{
+		Conn:     conn,
+		sendAead: sendAead,
+		recvAead: recvAead,
+	}, nil
+}
+
+func makeNonce(counter uint64) []byte {
+	nonce := make([]byte, chacha20poly1305.NonceSize)
+	binary.BigEndian.PutUint64(nonce[chacha20poly1305.NonceSize-8:], counter)
+	return nonce
+}
+
+//Write overwrite net.Conn, split b into frames and write them in one call
+func (self *secureConn) Write(b []byte) (int, error) {
+	self.sendLock.Lock()
+	defer self.sendLock.Unlock()
+
+	overhead := FRAME_HDR_LEN + self.sendAead.Overhead()
+	buf := bytes.NewBuffer(make([]byte, 0, len(b)+(len(b)/MAX_FRAME_LEN+1)*overhead))
+	for data := b; ; {
+		size := len(data)
+		if size > MAX_FRAME_LEN {
+			size = MAX_FRAME_LEN
+		}
+		var hdr [FRAME_HDR_LEN]byte
+		binary.BigEndian.PutUint32(hdr[:], uint32(size+self.sendAead.Overhead()))
+		buf.Write(hdr[:])
+		buf.Write(self.sendAead.Seal(nil, makeNonce(self.sendNonce), data[:size], hdr[:]))
+		self.sendNonce += 1
+
+		data = data[size:]
+		if len(data) == 0 {
+			break
+		}
+	}
+
+	if _, err := self.Conn.Write(buf.Bytes()); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+//Read overwrite net.Conn, return decrypted data of the received frames
+func (self *secureConn) Read(b []byte) (int, error) {
+	self.recvLock.Lock()
+	defer self.recvLock.Unlock()
+
+	for len(self.recvBuf) == 0 {
+		var hdr [FRAME_HDR_LEN]byte
+		if _, err := io.ReadFull(self.Conn, hdr[:]); err != nil {
+			return 0, err
+		}
+		length := binary.BigEndian.Uint32(hdr[:])
+		if length > uint32(MAX_FRAME_LEN+self.recvAead.Overhead()) {
+			return 0, ErrFrameTooLarge
+		}
+		frame := make([]byte, length)
+		if _, err := io.ReadFull(self.Conn, frame); err != nil {
+			return 0, err
+		}
+		plain, err := self.recvAead.Open(frame[:0], makeNonce(self.recvNonce), frame, hdr[:])
+		if err != nil {
+			return 0, fmt.Errorf("[p2p]decrypt frame failed: %s", err)
+		}
+		self.recvNonce += 1
+		self.recvBuf = plain
+	}
+
+	n := copy(b, self.recvBuf)
+	self.recvBuf = self.recvBuf[n:]
+	return n, nil
+}
@@ -0,0 +1,120 @@
+/*
+ * Copyright (C) 2018 The cntmology Authors
+ * This file is part of The cntmology library.
+ *
+ * The cntmology is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The cntmology is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * alcntm with The cntmology.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package link
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cntmio/cntmology/p2pserver/common"
+)
+
+//TokenBucket limits the bytes sent per second, a nil bucket means no limit
+type TokenBucket struct {
+	lock   sync.Mutex
+	rate   float64 // bytes per second
+	tokens float64
+	last   time.Time
+}
+
+//NewTokenBucket return a bucket allowing rate bytes per second with one second burst, nil if rate is 0
+func NewTokenBucket(rate uint64) *TokenBucket {
+	if rate == 0 {
+		return nil
+	}
+	return &TokenBucket{
+		rate:   float64(rate),
+		tokens: float64(rate),
+		last:   time.Now(),
+	}
+}
+
+func (self *TokenBucket) refill(now time.Time) {
+	self.tokens += now.Sub(self.last).Seconds() * self.rate
+	if self.tokens > self.rate {
+		self.tokens = self.rate
+	}
+	self.last = now
+}
+
+//Reserve take n tokens and return the time to wait before sending them.
+//the bucket can be overdrawn by a large message, the following ones wait for the debt
+func (self *TokenBucket) Reserve(n int) time.Duration {
+	if self == nil {
+		return 0
+	}
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	self.refill(time.Now())
+	self.tokens -= float64(n)
+	if self.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-self.tokens / self.rate * float64(time.Second))
+}
+
+//Take take n tokens without waiting, used by high priority messages
+func (self *TokenBucket) Take(n int) {
+	if self == nil {
+		return
+	}
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	self.refill(time.Now())
+	self.tokens -= float64(n)
+}
+
+//TrafficCounter counts the traffic of links
+type TrafficCounter struct {
+	stats common.TrafficStats
+}
+
+func NewTrafficCounter() *TrafficCounter {
+	return &TrafficCounter{}
+}
+
+func (self *TrafficCounter) addSent(n int) {
+	if self == nil {
+		return
+	}
+	atomic.AddUint64(&self.stats.BytesSent, uint64(n))
+	atomic.AddUint64(&self.stats.MsgSent, 1)
+}
+
+func (self *TrafficCounter) addRecv(n int) {
+	if self == nil {
+		return
+	}
+	atomic.AddUint64(&self.stats.BytesRecv, uint64(n))
+	atomic.AddUint64(&self.stats.MsgRecv, 1)
+}
+
+//Stats return the snapshot of counters
+func (self *TrafficCounter) Stats() common.TrafficStats {
+	if self == nil {
+		return common.TrafficStats{}
+	}
+	return common.TrafficStats{
+		BytesSent: atomic.LoadUint64(&self.stats.BytesSent),
+		BytesRecv: atomic.LoadUint64(&self.stats.BytesRecv),
+		MsgSent:   atomic.LoadUint64(&self.stats.MsgSent),
+		MsgRecv:   atomic.LoadUint64(&self.stats.MsgRecv),
+	}
+}
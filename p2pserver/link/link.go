@@ -31,6 +31,7 @@ import (
 	"github.com/cntmio/cntmology/common/log"
 	"github.com/cntmio/cntmology/p2pserver/common"
 	"github.com/cntmio/cntmology/p2pserver/message/types"
+	"github.com/cntmio/cntmology/p2pserver/reputation"
 )
 
 //Link used to establish
@@ -40,9 +41,21 @@ type Link struct {
 
 	lock      sync.RWMutex
 	conn      net.Conn               // Connect socket with the peer node
+	err       error                  // The write error which closed the link
 	time      int64                  // The latest time the node activity
 	recvChan  chan *types.MsgPayload //msgpayload channel
 	reqRecord map[string]int64       //Map RequestId to Timestamp, using for rejecting duplicate request in specific time
+	misbehave func(m reputation.Misbehavior)
+
+	compress      bool        // peer accepts compressed message
+	highQueue     chan []byte // consensus messages, sent before the others
+	lowQueue      chan []byte
+	quit          chan struct{}
+	closeOnce     sync.Once
+	sendLimit     *TokenBucket // bandwidth limit of this link
+	globalLimit   *TokenBucket // bandwidth limit shared by all links
+	traffic       *TrafficCounter
+	globalTraffic *TrafficCounter
 }
 
 func NewLink(id common.PeerId, c net.Conn, msgChan chan *types.MsgPayload) *Link {
@@ -53,11 +66,48 @@ func NewLink(id common.PeerId, c net.Conn, msgChan chan *types.MsgPayload) *Link
 		time:      time.Now().UnixNano(),
 		recvChan:  msgChan,
 		reqRecord: make(map[string]int64),
+		highQueue: make(chan []byte, common.SEND_QUEUE_LEN),
+		lowQueue:  make(chan []byte, common.SEND_QUEUE_LEN),
+		quit:      make(chan struct{}),
+		traffic:   NewTrafficCounter(),
 	}
+	go link.tx()
 
 	return link
 }
 
+//SetCompress enable compression of large messages, only if the peer advertise it in version
+func (this *Link) SetCompress(compress bool) {
+	this.compress = compress
+}
+
+//SetSendLimiter set the bandwidth limit of this link and the one shared by all links, nil means no limit
+func (this *Link) SetSendLimiter(link, global *TokenBucket) {
+	this.sendLimit = link
+	this.globalLimit = global
+}
+
+//SetGlobalTraffic set the counter shared by all links
+func (this *Link) SetGlobalTraffic(counter *TrafficCounter) {
+	this.globalTraffic = counter
+}
+
+//GetTrafficStats return the traffic of this link
+func (this *Link) GetTrafficStats() common.TrafficStats {
+	return this.traffic.Stats()
+}
+
+//SetMisbehaveHandler set the callback to report the misbehavior detected in link
+func (this *Link) SetMisbehaveHandler(handler func(m reputation.Misbehavior)) {
+	this.misbehave = handler
+}
+
+func (this *Link) reportMisbehave(m reputation.Misbehavior) {
+	if this.misbehave != nil {
+		this.misbehave(m)
+	}
+}
+
 //get address
 func (this *Link) GetAddr() string {
 	return this.addr
@@ -99,8 +149,13 @@ func (this *Link) Rx() {
 		msg, payloadSize, err := types.ReadMessage(reader)
 		if err != nil {
 			log.Infof("[p2p]error read from %s :%s", this.GetAddr(), err.Error())
+			if _, ok := err.(*types.MalformedMsgError); ok {
+				this.reportMisbehave(reputation.MALFORMED_MSG)
+			}
 			break
 		}
+		this.traffic.addRecv(int(payloadSize) + common.MSG_HDR_LEN)
+		this.globalTraffic.addRecv(int(payloadSize) + common.MSG_HDR_LEN)
 
 		if unknown, ok := msg.(*types.UnknownMessage); ok {
 			log.Infof("skip handle unknown msg type:%s from:%d", unknown.CmdType(), this.id)
@@ -112,6 +167,7 @@ func (this *Link) Rx() {
 
 		if !this.needSendMsg(msg) {
 			log.Debugf("skip handle msgType:%s from:%d", msg.CmdType(), this.id)
+			this.reportMisbehave(reputation.REQUEST_FLOOD)
 			ccntminue
 		}
 
@@ -128,28 +184,200 @@ func (this *Link) Rx() {
 	this.CloseConn()
 }
 
+//closeWithError close the link because of a write error, which is returned by the later sends
+func (this *Link) closeWithError(err error) {
+	this.lock.Lock()
+	if this.err == nil {
+		this.err = err
+	}
+	this.lock.Unlock()
+	this.CloseConn()
+}
+
+func (this *Link) closedError() error {
+	this.lock.RLock()
+	defer this.lock.RUnlock()
+	if this.err != nil {
+		return fmt.Errorf("[p2p]tx link closed: %s", this.err)
+	}
+	return errors.New("[p2p]tx link invalid")
+}
+
 //close connection
 func (this *Link) CloseConn() {
 	this.lock.Lock()
 	conn := this.conn
 	this.conn = nil
 	this.lock.Unlock()
+	this.closeOnce.Do(func() {
+		close(this.quit)
+	})
 	if conn != nil {
 		_ = conn.Close()
 	}
 }
 
-func (this *Link) Send(msg types.Message) error {
+//Packet is a serialized message, the compressed form is made once on demand when sent to many links
+type Packet struct {
+	msg        types.Message
+	plain      []byte
+	once       sync.Once
+	compressed []byte
+}
+
+func NewPacket(msg types.Message) *Packet {
 	sink := comm.NewZeroCopySink(nil)
 	types.WriteMessage(sink, msg)
+	return &Packet{msg: msg, plain: sink.Bytes()}
+}
+
+func (self *Packet) bytes(compress bool) []byte {
+	if !compress || !types.IsCompressible(self.msg.CmdType()) {
+		return self.plain
+	}
+	self.once.Do(func() {
+		sink := comm.NewZeroCopySink(nil)
+		types.WriteCompressedMessage(sink, self.msg)
+		self.compressed = sink.Bytes()
+	})
+	return self.compressed
+}
+
+func (self *Packet) isHighPriority() bool {
+	return self.msg.CmdType() == common.CONSENSUS_TYPE
+}
+
+//mustDeliver return whether the sender waits for room in a full queue instead of dropping the packet
+func (self *Packet) mustDeliver() bool {
+	switch self.msg.CmdType() {
+	case common.CONSENSUS_TYPE, common.BLOCK_TYPE, common.HEADERS_TYPE:
+		return true
+	}
+	return false
+}
+
+func (this *Link) Send(msg types.Message) error {
+	return this.SendPacket(NewPacket(msg))
+}
 
-	return this.SendRaw(sink.Bytes())
+//SendPacket queue the packet, consensus message is sent before the others.
+//block and consensus messages wait for room in a full queue, the others are dropped
+func (this *Link) SendPacket(packet *Packet) error {
+	return this.enqueue(packet.isHighPriority(), packet.mustDeliver(), packet.bytes(this.compress))
 }
 
+//SendRaw queue the serialized message with normal priority
 func (this *Link) SendRaw(rawPacket []byte) error {
+	return this.enqueue(false, false, rawPacket)
+}
+
+func (this *Link) enqueue(high bool, wait bool, rawPacket []byte) error {
+	if this.GetConn() == nil {
+		return this.closedError()
+	}
+	queue := this.lowQueue
+	if high {
+		queue = this.highQueue
+	}
+	select {
+	case queue <- rawPacket:
+		return nil
+	case <-this.quit:
+		return this.closedError()
+	default:
+	}
+	if !wait {
+		return fmt.Errorf("[p2p]send queue of %s is full", this.GetAddr())
+	}
+
+	timer := time.NewTimer(common.SEND_QUEUE_TIMEOUT * time.Second)
+	defer timer.Stop()
+	select {
+	case queue <- rawPacket:
+		return nil
+	case <-this.quit:
+		return this.closedError()
+	case <-timer.C:
+		return fmt.Errorf("[p2p]send queue of %s is full for %ds", this.GetAddr(), common.SEND_QUEUE_TIMEOUT)
+	}
+}
+
+//tx write the queued messages to connection until the link is closed, a write error closes the link
+func (this *Link) tx() {
+	err := this.sendQueued()
+	if err != nil {
+		log.Infof("[p2p] error sending messge to %s :%s", this.GetAddr(), err.Error())
+		this.closeWithError(err)
+	}
+}
+
+//sendQueued return nil when the link is closed, or the write error
+func (this *Link) sendQueued() error {
+	for {
+		// drain the high priority queue first
+		select {
+		case rawPacket := <-this.highQueue:
+			if err := this.sendHigh(rawPacket); err != nil {
+				return err
+			}
+			ccntminue
+		default:
+		}
+
+		select {
+		case rawPacket := <-this.highQueue:
+			if err := this.sendHigh(rawPacket); err != nil {
+				return err
+			}
+		case rawPacket := <-this.lowQueue:
+			delay := this.sendLimit.Reserve(len(rawPacket))
+			if global := this.globalLimit.Reserve(len(rawPacket)); global > delay {
+				delay = global
+			}
+			if err := this.waitBandwidth(delay); err != nil {
+				return err
+			}
+			if err := this.write(rawPacket); err != nil {
+				return err
+			}
+		case <-this.quit:
+			return nil
+		}
+	}
+}
+
+func (this *Link) sendHigh(rawPacket []byte) error {
+	this.sendLimit.Take(len(rawPacket))
+	this.globalLimit.Take(len(rawPacket))
+	return this.write(rawPacket)
+}
+
+//waitBandwidth wait for the delay required by bandwidth limit, high priority messages are sent meanwhile
+func (this *Link) waitBandwidth(delay time.Duration) error {
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	for {
+		select {
+		case <-timer.C:
+			return nil
+		case rawPacket := <-this.highQueue:
+			if err := this.sendHigh(rawPacket); err != nil {
+				return err
+			}
+		case <-this.quit:
+			return nil
+		}
+	}
+}
+
+func (this *Link) write(rawPacket []byte) error {
 	conn := this.GetConn()
 	if conn == nil {
-		return errors.New("[p2p]tx link invalid")
+		// closed already, tx returns on quit
+		return nil
 	}
 	nByteCnt := len(rawPacket)
 	log.Tracef("[p2p]TX buf length: %d\n", nByteCnt)
@@ -158,14 +386,15 @@ func (this *Link) SendRaw(rawPacket []byte) error {
 	if nCount == 0 {
 		nCount = 1
 	}
-	_ = conn.SetWriteDeadline(time.Now().Add(time.Duration(nCount*common.WRITE_DEADLINE) * time.Second))
-	_, err := conn.Write(rawPacket)
+	err := conn.SetWriteDeadline(time.Now().Add(time.Duration(nCount*common.WRITE_DEADLINE) * time.Second))
 	if err != nil {
-		log.Infof("[p2p] error sending messge to %s :%s", this.GetAddr(), err.Error())
-		this.CloseConn()
 		return err
 	}
-
+	if _, err = conn.Write(rawPacket); err != nil {
+		return err
+	}
+	this.traffic.addSent(nByteCnt)
+	this.globalTraffic.addSent(nByteCnt)
 	return nil
 }
 
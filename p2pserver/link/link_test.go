@@ -20,6 +20,7 @@ package link
 
 import (
 	"math/rand"
+	"net"
 	"testing"
 	"time"
 
@@ -29,6 +30,7 @@ import (
 	ct "github.com/cntmio/cntmology/core/types"
 	"github.com/cntmio/cntmology/p2pserver/common"
 	mt "github.com/cntmio/cntmology/p2pserver/message/types"
+	"github.com/stretchr/testify/assert"
 )
 
 func TestUnpackBufNode(t *testing.T) {
@@ -117,3 +119,84 @@ func TestUnpackBufNode(t *testing.T) {
 	sink := comm.NewZeroCopySink(nil)
 	mt.WriteMessage(sink, msg)
 }
+
+func TestTokenBucket(t *testing.T) {
+	unlimited := NewTokenBucket(0)
+	assert.Nil(t, unlimited)
+	assert.Equal(t, time.Duration(0), unlimited.Reserve(1<<20))
+
+	bucket := NewTokenBucket(1000)
+	assert.Equal(t, time.Duration(0), bucket.Reserve(1000))
+	delay := bucket.Reserve(500)
+	assert.True(t, delay > 400*time.Millisecond && delay <= 500*time.Millisecond)
+}
+
+func TestSendConsensusFirst(t *testing.T) {
+	local, remote := net.Pipe()
+	defer remote.Close()
+	link := NewLink(common.PeerId{}, local, nil)
+	defer link.CloseConn()
+	// the first 3 pings are sent at once, the others wait for bandwidth
+	link.SetSendLimiter(NewTokenBucket(100), nil)
+
+	const pings = 5
+	for i := 0; i < pings; i++ {
+		assert.Nil(t, link.Send(&mt.Ping{Height: uint64(i)}))
+	}
+	acct := account.NewAccount("SHA256withECDSA")
+	assert.Nil(t, link.Send(&mt.Consensus{Cons: mt.ConsensusPayload{Owner: acct.PubKey()}}))
+
+	pos := -1
+	for i := 0; i <= pings; i++ {
+		msg, _, err := mt.ReadMessage(remote)
+		assert.Nil(t, err)
+		if msg.CmdType() == common.CONSENSUS_TYPE {
+			pos = i
+		}
+	}
+	assert.True(t, pos >= 0 && pos < pings)
+}
+
+func TestSendBlockBackpressure(t *testing.T) {
+	local, remote := net.Pipe()
+	defer remote.Close()
+	link := NewLink(common.PeerId{}, local, nil)
+	defer link.CloseConn()
+
+	// nothing is read from remote, so the queue fills up and pings are dropped
+	var err error
+	for i := 0; i <= 2*common.SEND_QUEUE_LEN && err == nil; i++ {
+		err = link.Send(&mt.Ping{Height: uint64(i)})
+	}
+	assert.NotNil(t, err)
+
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		for {
+			if _, _, err := mt.ReadMessage(remote); err != nil {
+				return
+			}
+		}
+	}()
+	// headers wait for room in the queue instead
+	start := time.Now()
+	assert.Nil(t, link.Send(&mt.BlkHeader{}))
+	assert.True(t, time.Since(start) >= 100*time.Millisecond)
+}
+
+func TestWriteErrorCloseLink(t *testing.T) {
+	local, remote := net.Pipe()
+	link := NewLink(common.PeerId{}, local, nil)
+	_ = remote.Close()
+
+	assert.Nil(t, link.Send(&mt.Ping{}))
+	select {
+	case <-link.quit:
+	case <-time.After(time.Second):
+		t.Fatal("link not closed after write error")
+	}
+	assert.Nil(t, link.GetConn())
+	err := link.Send(&mt.Ping{})
+	assert.NotNil(t, err)
+	assert.Ccntmains(t, err.Error(), "closed")
+}
@@ -173,3 +173,33 @@ func NewFindNodeReq(id msgCommon.PeerId) mt.Message {
 
 	return &req
 }
+
+//state snapshot info request package
+func NewSnapshotInfoReq() mt.Message {
+	log.Trace()
+	var req mt.SnapshotInfoReq
+
+	return &req
+}
+
+//state snapshot chunk request package
+func NewSnapshotChunkReq(height uint32, offset uint64, length uint32) mt.Message {
+	log.Trace()
+	var req mt.SnapshotChunkReq
+	req.Height = height
+	req.Offset = offset
+	req.Length = length
+
+	return &req
+}
+
+//state snapshot chunk package
+func NewSnapshotChunk(height uint32, offset uint64, data []byte) mt.Message {
+	log.Trace()
+	var chunk mt.SnapshotChunk
+	chunk.Height = height
+	chunk.Offset = offset
+	chunk.Data = data
+
+	return &chunk
+}
@@ -0,0 +1,113 @@
+/*
+ * Copyright (C) 2018 The cntmology Authors
+ * This file is part of The cntmology library.
+ *
+ * The cntmology is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The cntmology is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * alcntm with The cntmology.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package types
+
+import (
+	"fmt"
+	"io"
+
+	comm "github.com/cntmio/cntmology/common"
+	"github.com/cntmio/cntmology/p2pserver/common"
+	"github.com/golang/snappy"
+)
+
+//Compressed wraps the snappy compressed payload of a large message,
+//only sent to the peers which advertise compression support in version
+type Compressed struct {
+	Cmd  string
+	Data []byte
+}
+
+//Serialize message payload
+func (this *Compressed) Serialization(sink *comm.ZeroCopySink) {
+	sink.WriteString(this.Cmd)
+	sink.WriteVarBytes(this.Data)
+}
+
+func (this *Compressed) CmdType() string {
+	return common.COMPRESSED_TYPE
+}
+
+//Deserialize message payload
+func (this *Compressed) Deserialization(source *comm.ZeroCopySource) error {
+	var irregular, eof bool
+	this.Cmd, _, irregular, eof = source.NextString()
+	if irregular {
+		return comm.ErrIrregularData
+	}
+	this.Data, _, irregular, eof = source.NextVarBytes()
+	if irregular {
+		return comm.ErrIrregularData
+	}
+	if eof {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+
+//Decompress return the wrapped message
+func (this *Compressed) Decompress() (Message, error) {
+	if !IsCompressible(this.Cmd) {
+		return nil, fmt.Errorf("message type %s can not be compressed", this.Cmd)
+	}
+	size, err := snappy.DecodedLen(this.Data)
+	if err != nil {
+		return nil, err
+	}
+	if size > common.MAX_PAYLOAD_LEN {
+		return nil, fmt.Errorf("decompressed payload length:%d exceed max payload size: %d",
+			size, common.MAX_PAYLOAD_LEN)
+	}
+	buf, err := snappy.Decode(nil, this.Data)
+	if err != nil {
+		return nil, err
+	}
+	msg := makeEmptyMessage(this.Cmd)
+	err = msg.Deserialization(comm.NewZeroCopySource(buf))
+	if err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+//IsCompressible check whether the message type is large enough to be worth compression
+func IsCompressible(cmd string) bool {
+	switch cmd {
+	case common.BLOCK_TYPE, common.HEADERS_TYPE, common.TX_TYPE:
+		return true
+	}
+	return false
+}
+
+//WriteCompressedMessage write the message in compressed form if it is compressible and large enough,
+//otherwise it is the same as WriteMessage
+func WriteCompressedMessage(sink *comm.ZeroCopySink, msg Message) {
+	if !IsCompressible(msg.CmdType()) {
+		WriteMessage(sink, msg)
+		return
+	}
+	payload := comm.NewZeroCopySink(nil)
+	msg.Serialization(payload)
+	if payload.Size() < common.COMPRESS_MIN_LEN {
+		WriteMessage(sink, msg)
+		return
+	}
+
+	WriteMessage(sink, &Compressed{Cmd: msg.CmdType(), Data: snappy.Encode(nil, payload.Bytes())})
+}
@@ -0,0 +1,71 @@
+/*
+ * Copyright (C) 2018 The cntmology Authors
+ * This file is part of The cntmology library.
+ *
+ * The cntmology is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The cntmology is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * alcntm with The cntmology.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package types
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/cntmio/cntmology-crypto/keypair"
+	comm "github.com/cntmio/cntmology/common"
+	ct "github.com/cntmio/cntmology/core/types"
+	"github.com/cntmio/cntmology/p2pserver/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompressedMessage(t *testing.T) {
+	headers := &BlkHeader{}
+	for i := 0; i < 100; i++ {
+		header := &ct.Header{Height: uint32(i)}
+		header.Bookkeepers = make([]keypair.PublicKey, 0)
+		header.SigData = make([][]byte, 0)
+		headers.BlkHdr = append(headers.BlkHdr, header)
+	}
+
+	plain := comm.NewZeroCopySink(nil)
+	WriteMessage(plain, headers)
+	compressed := comm.NewZeroCopySink(nil)
+	WriteCompressedMessage(compressed, headers)
+	assert.True(t, compressed.Size() < plain.Size())
+
+	msg, _, err := ReadMessage(bytes.NewBuffer(compressed.Bytes()))
+	assert.Nil(t, err)
+	assert.Equal(t, common.HEADERS_TYPE, msg.CmdType())
+	assert.Equal(t, len(headers.BlkHdr), len(msg.(*BlkHeader).BlkHdr))
+	assert.Equal(t, uint32(99), msg.(*BlkHeader).BlkHdr[99].Height)
+}
+
+func TestCompressedSmallMessage(t *testing.T) {
+	ping := &Ping{Height: 1}
+	plain := comm.NewZeroCopySink(nil)
+	WriteMessage(plain, ping)
+	compressed := comm.NewZeroCopySink(nil)
+	WriteCompressedMessage(compressed, ping)
+	assert.Equal(t, plain.Bytes(), compressed.Bytes())
+}
+
+func TestCompressedInvalidCmd(t *testing.T) {
+	sink := comm.NewZeroCopySink(nil)
+	WriteMessage(sink, &Compressed{Cmd: common.PING_TYPE, Data: []byte{1, 2, 3}})
+
+	_, _, err := ReadMessage(bytes.NewBuffer(sink.Bytes()))
+	assert.NotNil(t, err)
+	_, ok := err.(*MalformedMsgError)
+	assert.True(t, ok)
+}
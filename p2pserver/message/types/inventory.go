@@ -77,8 +77,12 @@ func (this *Inv) Deserialization(source *common.ZeroCopySource) error {
 		this.P.Blk = append(this.P.Blk, hash)
 	}
 
-	if blkCnt > p2pCommon.MAX_INV_BLK_CNT {
-		blkCnt = p2pCommon.MAX_INV_BLK_CNT
+	maxCnt := uint32(p2pCommon.MAX_INV_BLK_CNT)
+	if this.P.InvType == common.TRANSACTION {
+		maxCnt = p2pCommon.MAX_INV_TX_CNT
+	}
+	if blkCnt > maxCnt {
+		blkCnt = maxCnt
 	}
 	this.P.Blk = this.P.Blk[:blkCnt]
 	return nil
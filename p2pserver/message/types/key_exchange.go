@@ -0,0 +1,80 @@
+/*
+ * Copyright (C) 2018 The cntmology Authors
+ * This file is part of The cntmology library.
+ *
+ * The cntmology is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The cntmology is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * alcntm with The cntmology.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package types
+
+import (
+	"io"
+
+	comm "github.com/cntmio/cntmology/common"
+	"github.com/cntmio/cntmology/p2pserver/common"
+)
+
+//KeyExchange carries the ephemeral public key of the encrypted transport handshake
+type KeyExchange struct {
+	EphemeralKey []byte
+}
+
+//Serialize message payload
+func (this *KeyExchange) Serialization(sink *comm.ZeroCopySink) {
+	sink.WriteVarBytes(this.EphemeralKey)
+}
+
+func (this *KeyExchange) CmdType() string {
+	return common.KEY_EXCHANGE_TYPE
+}
+
+//Deserialize message payload
+func (this *KeyExchange) Deserialization(source *comm.ZeroCopySource) error {
+	var irregular, eof bool
+	this.EphemeralKey, _, irregular, eof = source.NextVarBytes()
+	if irregular {
+		return comm.ErrIrregularData
+	}
+	if eof {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+
+//KeyAuth carries the signature of the handshake transcript made with the sender's peer key id
+type KeyAuth struct {
+	Signature []byte
+}
+
+//Serialize message payload
+func (this *KeyAuth) Serialization(sink *comm.ZeroCopySink) {
+	sink.WriteVarBytes(this.Signature)
+}
+
+func (this *KeyAuth) CmdType() string {
+	return common.KEY_AUTH_TYPE
+}
+
+//Deserialize message payload
+func (this *KeyAuth) Deserialization(source *comm.ZeroCopySource) error {
+	var irregular, eof bool
+	this.Signature, _, irregular, eof = source.NextVarBytes()
+	if irregular {
+		return comm.ErrIrregularData
+	}
+	if eof {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
@@ -52,6 +52,15 @@ func (self *UnknownMessage) Deserialization(source *comm.ZeroCopySource) error {
 	return nil
 }
 
+//MalformedMsgError means the message received is broken, which is a misbehavior of the peer
+type MalformedMsgError struct {
+	Err error
+}
+
+func (self *MalformedMsgError) Error() string {
+	return self.Err.Error()
+}
+
 //MsgPayload in link channel
 type MsgPayload struct {
 	Id          common.PeerId //peer ID
@@ -131,8 +140,8 @@ func ReadMessage(reader io.Reader) (Message, uint32, error) {
 	}
 
 	if hdr.Length > common.MAX_PAYLOAD_LEN {
-		return nil, 0, fmt.Errorf("msg payload length:%d exceed max payload size: %d",
-			hdr.Length, common.MAX_PAYLOAD_LEN)
+		return nil, 0, &MalformedMsgError{fmt.Errorf("msg payload length:%d exceed max payload size: %d",
+			hdr.Length, common.MAX_PAYLOAD_LEN)}
 	}
 
 	buf := make([]byte, hdr.Length)
@@ -143,7 +152,7 @@ func ReadMessage(reader io.Reader) (Message, uint32, error) {
 
 	checksum := common.Checksum(buf)
 	if checksum != hdr.Checksum {
-		return nil, 0, fmt.Errorf("message checksum mismatch: %x != %x ", hdr.Checksum, checksum)
+		return nil, 0, &MalformedMsgError{fmt.Errorf("message checksum mismatch: %x != %x ", hdr.Checksum, checksum)}
 	}
 
 	cmdType := string(bytes.TrimRight(hdr.CMD[:], string(rune(0))))
@@ -153,7 +162,13 @@ func ReadMessage(reader io.Reader) (Message, uint32, error) {
 	source := comm.NewZeroCopySource(buf)
 	err = msg.Deserialization(source)
 	if err != nil {
-		return nil, 0, err
+		return nil, 0, &MalformedMsgError{err}
+	}
+	if compressed, ok := msg.(*Compressed); ok {
+		msg, err = compressed.Decompress()
+		if err != nil {
+			return nil, 0, &MalformedMsgError{err}
+		}
 	}
 
 	return msg, hdr.Length, nil
@@ -197,12 +212,26 @@ func makeEmptyMessage(cmdType string) Message {
 		return &FindNodeResp{}
 	case common.UPDATE_KADID_TYPE:
 		return &UpdatePeerKeyId{}
+	case common.KEY_EXCHANGE_TYPE:
+		return &KeyExchange{}
+	case common.KEY_AUTH_TYPE:
+		return &KeyAuth{}
+	case common.COMPRESSED_TYPE:
+		return &Compressed{}
 	case common.GET_SUBNET_MEMBERS_TYPE:
 		return &SubnetMembersRequest{}
 	case common.SUBNET_MEMBERS_TYPE:
 		return &SubnetMembers{}
 	case common.SUBNET_OFFLINE_TYPE:
 		return &OfflineWitnessMsg{}
+	case common.GET_SNAPSHOT_INFO_TYPE:
+		return &SnapshotInfoReq{}
+	case common.SNAPSHOT_INFO_TYPE:
+		return &SnapshotInfo{}
+	case common.GET_SNAPSHOT_CHUNK_TYPE:
+		return &SnapshotChunkReq{}
+	case common.SNAPSHOT_CHUNK_TYPE:
+		return &SnapshotChunk{}
 	default:
 		return &UnknownMessage{Cmd: cmdType}
 	}
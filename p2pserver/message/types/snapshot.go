@@ -0,0 +1,151 @@
+/*
+ * Copyright (C) 2018 The cntmology Authors
+ * This file is part of The cntmology library.
+ *
+ * The cntmology is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The cntmology is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * alcntm with The cntmology.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package types
+
+import (
+	"fmt"
+	"io"
+
+	comm "github.com/cntmio/cntmology/common"
+	"github.com/cntmio/cntmology/p2pserver/common"
+)
+
+//SnapshotInfoReq requests the latest state snapshot of the peer
+type SnapshotInfoReq struct{}
+
+//Serialize message payload
+func (this *SnapshotInfoReq) Serialization(sink *comm.ZeroCopySink) {}
+
+func (this *SnapshotInfoReq) CmdType() string {
+	return common.GET_SNAPSHOT_INFO_TYPE
+}
+
+//Deserialize message payload
+func (this *SnapshotInfoReq) Deserialization(source *comm.ZeroCopySource) error {
+	return nil
+}
+
+//SnapshotInfo describes the latest state snapshot of the peer, Height is 0 if the peer has none
+type SnapshotInfo struct {
+	Height    uint32
+	BlockHash comm.Uint256
+	StateRoot comm.Uint256
+	Size      uint64
+	Digest    comm.Uint256
+}
+
+//Serialize message payload
+func (this *SnapshotInfo) Serialization(sink *comm.ZeroCopySink) {
+	sink.WriteUint32(this.Height)
+	sink.WriteHash(this.BlockHash)
+	sink.WriteHash(this.StateRoot)
+	sink.WriteUint64(this.Size)
+	sink.WriteHash(this.Digest)
+}
+
+func (this *SnapshotInfo) CmdType() string {
+	return common.SNAPSHOT_INFO_TYPE
+}
+
+//Deserialize message payload
+func (this *SnapshotInfo) Deserialization(source *comm.ZeroCopySource) error {
+	var eof bool
+	this.Height, eof = source.NextUint32()
+	this.BlockHash, eof = source.NextHash()
+	this.StateRoot, eof = source.NextHash()
+	this.Size, eof = source.NextUint64()
+	this.Digest, eof = source.NextHash()
+	if eof {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+
+//SnapshotChunkReq requests Length bytes at Offset of the state snapshot at Height
+type SnapshotChunkReq struct {
+	Height uint32
+	Offset uint64
+	Length uint32
+}
+
+//Serialize message payload
+func (this *SnapshotChunkReq) Serialization(sink *comm.ZeroCopySink) {
+	sink.WriteUint32(this.Height)
+	sink.WriteUint64(this.Offset)
+	sink.WriteUint32(this.Length)
+}
+
+func (this *SnapshotChunkReq) CmdType() string {
+	return common.GET_SNAPSHOT_CHUNK_TYPE
+}
+
+//Deserialize message payload
+func (this *SnapshotChunkReq) Deserialization(source *comm.ZeroCopySource) error {
+	var eof bool
+	this.Height, eof = source.NextUint32()
+	this.Offset, eof = source.NextUint64()
+	this.Length, eof = source.NextUint32()
+	if eof {
+		return io.ErrUnexpectedEOF
+	}
+	if this.Length > common.MAX_SNAPSHOT_CHUNK_LEN {
+		return fmt.Errorf("snapshot chunk length %d exceeds %d", this.Length, common.MAX_SNAPSHOT_CHUNK_LEN)
+	}
+	return nil
+}
+
+//SnapshotChunk carries the bytes at Offset of the state snapshot at Height
+type SnapshotChunk struct {
+	Height uint32
+	Offset uint64
+	Data   []byte
+}
+
+//Serialize message payload
+func (this *SnapshotChunk) Serialization(sink *comm.ZeroCopySink) {
+	sink.WriteUint32(this.Height)
+	sink.WriteUint64(this.Offset)
+	sink.WriteVarBytes(this.Data)
+}
+
+func (this *SnapshotChunk) CmdType() string {
+	return common.SNAPSHOT_CHUNK_TYPE
+}
+
+//Deserialize message payload
+func (this *SnapshotChunk) Deserialization(source *comm.ZeroCopySource) error {
+	var eof bool
+	this.Height, eof = source.NextUint32()
+	this.Offset, eof = source.NextUint64()
+	if eof {
+		return io.ErrUnexpectedEOF
+	}
+	data, _, irregular, eof := source.NextVarBytes()
+	if irregular {
+		return comm.ErrIrregularData
+	}
+	if eof {
+		return io.ErrUnexpectedEOF
+	}
+	if len(data) > common.MAX_SNAPSHOT_CHUNK_LEN {
+		return fmt.Errorf("snapshot chunk length %d exceeds %d", len(data), common.MAX_SNAPSHOT_CHUNK_LEN)
+	}
+	this.Data = data
+	return nil
+}
@@ -0,0 +1,38 @@
+/*
+ * Copyright (C) 2018 The cntmology Authors
+ * This file is part of The cntmology library.
+ *
+ * The cntmology is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The cntmology is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * alcntm with The cntmology.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package types
+
+import (
+	"testing"
+
+	cm "github.com/cntmio/cntmology/common"
+)
+
+func TestSnapshotSerializationDeserialization(t *testing.T) {
+	MessageTest(t, &SnapshotInfoReq{})
+	MessageTest(t, &SnapshotInfo{
+		Height:    100,
+		BlockHash: cm.Uint256{1},
+		StateRoot: cm.Uint256{2},
+		Size:      1024,
+		Digest:    cm.Uint256{3},
+	})
+	MessageTest(t, &SnapshotChunkReq{Height: 100, Offset: 512, Length: 512})
+	MessageTest(t, &SnapshotChunk{Height: 100, Offset: 512, Data: []byte{1, 2, 3}})
+}
@@ -23,8 +23,8 @@ import (
 	"sync"
 	"sync/atomic"
 
-	comm "github.com/cntmio/cntmology/common"
 	"github.com/cntmio/cntmology/p2pserver/common"
+	"github.com/cntmio/cntmology/p2pserver/link"
 	"github.com/cntmio/cntmology/p2pserver/message/types"
 	"github.com/cntmio/cntmology/p2pserver/peer"
 )
@@ -85,14 +85,13 @@ func NewNbrPeers() *NbrPeers {
 
 //Broadcast tranfer msg buffer to all establish Peer
 func (this *NbrPeers) Broadcast(msg types.Message) {
-	sink := comm.NewZeroCopySink(nil)
-	types.WriteMessage(sink, msg)
+	packet := link.NewPacket(msg)
 
 	this.RLock()
 	defer this.RUnlock()
 	for _, node := range this.List {
 		if node.Peer.GetRelay() {
-			go node.Peer.SendRaw(sink.Bytes())
+			_ = node.Peer.SendPacket(packet)
 		}
 	}
 }
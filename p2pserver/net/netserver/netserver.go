@@ -21,15 +21,18 @@ package netserver
 import (
 	"errors"
 	"net"
+	"time"
 
 	"github.com/cntmio/cntmology/common/config"
 	"github.com/cntmio/cntmology/common/log"
 	"github.com/cntmio/cntmology/p2pserver/common"
 	"github.com/cntmio/cntmology/p2pserver/connect_ccntmroller"
+	"github.com/cntmio/cntmology/p2pserver/link"
 	"github.com/cntmio/cntmology/p2pserver/message/types"
 	p2p "github.com/cntmio/cntmology/p2pserver/net/protocol"
 	"github.com/cntmio/cntmology/p2pserver/peer"
 	"github.com/cntmio/cntmology/p2pserver/protocols"
+	"github.com/cntmio/cntmology/p2pserver/reputation"
 )
 
 //NewNetServer return the net object in p2p
@@ -39,7 +42,10 @@ func NewNetServer(protocol p2p.Protocol, conf *config.P2PNodeConfig, reserveAddr
 		nodePort = config.DEFAULT_NODE_PORT
 	}
 
-	keyId := common.RandPeerKeyId()
+	keyId, err := common.LoadPeerKeyId(common.KEY_FILE_NAME)
+	if err != nil {
+		return nil, err
+	}
 	info := peer.NewPeerInfo(keyId.Id, common.PROTOCOL_VERSION, common.SERVICE_NODE, true,
 		conf.HttpInfoPort, nodePort, 0, config.Version, "")
 
@@ -56,7 +62,11 @@ func NewNetServer(protocol p2p.Protocol, conf *config.P2PNodeConfig, reserveAddr
 
 	log.Infof("[p2p] init peer ID to %s", info.Id.ToHexString())
 
-	return NewCustomNetServer(keyId, info, protocol, listener, option, nil), nil
+	n := NewCustomNetServer(keyId, info, protocol, listener, option, nil)
+	if err := n.reputation.Persist(common.BANNED_FILE_NAME); err != nil {
+		log.Warnf("[p2p]load banned peers from %s failed: %s", common.BANNED_FILE_NAME, err)
+	}
+	return n, nil
 }
 
 func NewCustomNetServer(id *common.PeerKeyId, info *peer.PeerInfo, proto p2p.Protocol,
@@ -64,7 +74,8 @@ func NewCustomNetServer(id *common.PeerKeyId, info *peer.PeerInfo, proto p2p.Pro
 	if logger == nil {
 		logger = common.NewGlobalLoggerWrapper()
 	}
-	connCtrl := connect_ccntmroller.NewConnectCcntmroller(info, id, opt, logger)
+	rep := reputation.NewReputation()
+	connCtrl := connect_ccntmroller.NewConnectCcntmroller(info, id, opt.WithBannedPeers(rep).WithBannedAddrs(rep.AddrFilter()), logger)
 
 	n := &NetServer{
 		base:       info,
@@ -74,7 +85,12 @@ func NewCustomNetServer(id *common.PeerKeyId, info *peer.PeerInfo, proto p2p.Pro
 		Np:         NewNbrPeers(),
 		stopRecvCh: make(chan bool),
 		connCtrl:   connCtrl,
+		reputation: rep,
 		logger:     logger,
+
+		sendLimit:     link.NewTokenBucket(opt.MaxSendRate),
+		peerSendRate:  opt.MaxPeerSendRate,
+		globalTraffic: link.NewTrafficCounter(),
 	}
 
 	return n
@@ -88,8 +104,13 @@ type NetServer struct {
 	NetChan  chan *types.MsgPayload
 	Np       *NbrPeers
 
-	connCtrl *connect_ccntmroller.ConnectCcntmroller
-	logger   common.Logger
+	connCtrl   *connect_ccntmroller.ConnectCcntmroller
+	reputation *reputation.Reputation
+	logger     common.Logger
+
+	sendLimit     *link.TokenBucket // shared by all peers
+	peerSendRate  uint64
+	globalTraffic *link.TrafficCounter
 
 	stopRecvCh chan bool // To stop sync channel
 }
@@ -176,6 +197,11 @@ func (this *NetServer) GetNeighbors() []*peer.Peer {
 
 //Broadcast called by actor, broadcast msg
 func (this *NetServer) Broadcast(msg types.Message) {
+	if trn, ok := msg.(*types.Trn); ok {
+		// transactions are announced by hash, the peers request the ones they miss
+		this.protocol.HandleSystemMessage(this, p2p.TxBroadcast{Tx: trn.Txn})
+		return
+	}
 	this.Np.Broadcast(msg)
 }
 
@@ -207,7 +233,7 @@ func (this *NetServer) connect(addr string) error {
 		}
 		return err
 	}
-	remotePeer := peer.NewPeer(peerInfo, conn, this.NetChan)
+	remotePeer := this.newPeer(peerInfo, conn)
 
 	this.ReplacePeer(remotePeer)
 	go remotePeer.Link.Rx()
@@ -238,12 +264,23 @@ func (this *NetServer) Stop() {
 	this.protocol.HandleSystemMessage(this, p2p.NetworkStop{})
 }
 
+func (this *NetServer) newPeer(info *peer.PeerInfo, conn net.Conn) *peer.Peer {
+	remotePeer := peer.NewPeer(info, conn, this.NetChan)
+	remotePeer.Link.SetMisbehaveHandler(func(m reputation.Misbehavior) {
+		this.Misbehave(info.Id, m)
+	})
+	remotePeer.Link.SetCompress(info.Compress)
+	remotePeer.Link.SetSendLimiter(link.NewTokenBucket(this.peerSendRate), this.sendLimit)
+	remotePeer.Link.SetGlobalTraffic(this.globalTraffic)
+	return remotePeer
+}
+
 func (this *NetServer) handleClientConnection(conn net.Conn) error {
 	peerInfo, conn, err := this.connCtrl.AcceptConnect(conn)
 	if err != nil {
 		return err
 	}
-	remotePeer := peer.NewPeer(peerInfo, conn, this.NetChan)
+	remotePeer := this.newPeer(peerInfo, conn)
 	this.ReplacePeer(remotePeer)
 
 	go remotePeer.Link.Rx()
@@ -303,3 +340,47 @@ func (self *NetServer) GetSubnetMembersInfo() []common.SubnetMemberInfo {
 
 	return handler.GetSubnetMembersInfo()
 }
+
+//Misbehave lower the score of the peer, disconnect or ban it when the score is too low
+func (this *NetServer) Misbehave(id common.PeerId, m reputation.Misbehavior) {
+	var addr string
+	p := this.GetPeer(id)
+	if p != nil {
+		addr = p.GetAddr()
+	}
+	switch this.reputation.Misbehave(id, addr, m) {
+	case reputation.ACTION_DISCONNECT, reputation.ACTION_BAN:
+		if p != nil {
+			this.logger.Infof("[p2p]disconnect peer %s for %s", id.ToHexString(), m)
+			p.Close()
+		}
+	}
+}
+
+//BanPeer refuse the peer for duration and disconnect it
+func (this *NetServer) BanPeer(id common.PeerId, duration time.Duration, reason string) {
+	var addr string
+	p := this.GetPeer(id)
+	if p != nil {
+		addr = p.GetAddr()
+	}
+	this.reputation.Ban(id, addr, duration, reason)
+	if p != nil {
+		p.Close()
+	}
+}
+
+//UnbanPeer lift the ban of peer
+func (this *NetServer) UnbanPeer(id common.PeerId) bool {
+	return this.reputation.Unban(id)
+}
+
+//GetReputation return the scores and bans of peers
+func (this *NetServer) GetReputation() *reputation.Reputation {
+	return this.reputation
+}
+
+//GetTrafficStats return the traffic with all peers
+func (this *NetServer) GetTrafficStats() common.TrafficStats {
+	return this.globalTraffic.Stats()
+}
@@ -18,6 +18,8 @@
 
 package p2p
 
+import "github.com/cntmio/cntmology/p2pserver/common"
+
 type AddressFilter interface {
 	// addr format : ip:port
 	Ccntmains(addr string) bool
@@ -55,3 +57,17 @@ type allAddrFilter struct{}
 func (self *allAddrFilter) Ccntmains(addr string) bool {
 	return true
 }
+
+type PeerFilter interface {
+	Ccntmains(id common.PeerId) bool
+}
+
+func NonePeerFilter() PeerFilter {
+	return &nonePeerFilter{}
+}
+
+type nonePeerFilter struct{}
+
+func (self *nonePeerFilter) Ccntmains(id common.PeerId) bool {
+	return false
+}
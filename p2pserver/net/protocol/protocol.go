@@ -18,6 +18,7 @@
 package p2p
 
 import (
+	ct "github.com/cntmio/cntmology/core/types"
 	"github.com/cntmio/cntmology/p2pserver/message/types"
 	"github.com/cntmio/cntmology/p2pserver/peer"
 )
@@ -71,6 +72,12 @@ type NetworkStop struct {
 	implSystemMessage
 }
 
+//TxBroadcast asks the protocol to relay a transaction accepted by the local tx pool
+type TxBroadcast struct {
+	implSystemMessage
+	Tx *ct.Transaction
+}
+
 type HostAddrDetected struct {
 	implSystemMessage
 	ListenAddr string
@@ -20,9 +20,12 @@
 package p2p
 
 import (
+	"time"
+
 	"github.com/cntmio/cntmology/p2pserver/common"
 	"github.com/cntmio/cntmology/p2pserver/message/types"
 	"github.com/cntmio/cntmology/p2pserver/peer"
+	"github.com/cntmio/cntmology/p2pserver/reputation"
 )
 
 //P2P represent the net interface of p2p package
@@ -41,4 +44,9 @@ type P2P interface {
 	GetOutConnRecordLen() uint
 	Broadcast(msg types.Message)
 	IsOwnAddress(addr string) bool
+	Misbehave(id common.PeerId, m reputation.Misbehavior)
+	BanPeer(id common.PeerId, duration time.Duration, reason string)
+	UnbanPeer(id common.PeerId) bool
+	GetReputation() *reputation.Reputation
+	GetTrafficStats() common.TrafficStats
 }
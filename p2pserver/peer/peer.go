@@ -28,7 +28,6 @@ import (
 	"sync/atomic"
 	"time"
 
-	comm "github.com/cntmio/cntmology/common"
 	"github.com/cntmio/cntmology/common/log"
 	"github.com/cntmio/cntmology/p2pserver/common"
 	conn "github.com/cntmio/cntmology/p2pserver/link"
@@ -45,6 +44,7 @@ type PeerInfo struct {
 	Port         uint16
 	SoftVersion  string
 	Addr         string
+	Compress     bool // peer accepts compressed message
 
 	height uint64
 }
@@ -205,10 +205,17 @@ func (this *Peer) GetSoftVersion() string {
 
 //Send transfer buffer by sync or cons link
 func (this *Peer) Send(msg types.Message) error {
-	sink := comm.NewZeroCopySink(nil)
-	types.WriteMessage(sink, msg)
+	return this.Link.Send(msg)
+}
+
+//SendPacket send the shared packet to peer, used by broadcast
+func (this *Peer) SendPacket(packet *conn.Packet) error {
+	return this.Link.SendPacket(packet)
+}
 
-	return this.SendRaw(sink.Bytes())
+//GetTrafficStats return the traffic with peer
+func (this *Peer) GetTrafficStats() common.TrafficStats {
+	return this.Link.GetTrafficStats()
 }
 
 //GetHttpInfoPort return peer`s httpinfo port
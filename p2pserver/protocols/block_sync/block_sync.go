@@ -28,11 +28,13 @@ import (
 	"github.com/cntmio/cntmology/common"
 	"github.com/cntmio/cntmology/common/log"
 	"github.com/cntmio/cntmology/core/ledger"
+	scom "github.com/cntmio/cntmology/core/store/common"
 	"github.com/cntmio/cntmology/core/types"
 	p2pComm "github.com/cntmio/cntmology/p2pserver/common"
 	msgpack "github.com/cntmio/cntmology/p2pserver/message/msg_pack"
 	p2p "github.com/cntmio/cntmology/p2pserver/net/protocol"
 	"github.com/cntmio/cntmology/p2pserver/peer"
+	"github.com/cntmio/cntmology/p2pserver/reputation"
 )
 
 const (
@@ -249,6 +251,7 @@ type BlockSyncMgr struct {
 	ledger         *ledger.Ledger                       //ledger
 	lock           sync.RWMutex                         //lock
 	nodeWeights    map[p2pComm.PeerId]*NodeWeight       //Map NodeID => NodeStatus, using for getNextNode
+	fastSync       int32                                //Only sync headers while the state snapshot is downloading
 }
 
 //NewBlockSyncMgr return a BlockSyncMgr instance
@@ -457,7 +460,7 @@ func (this *BlockSyncMgr) syncHeader() {
 
 	curHeaderHeight := this.ledger.GetCurrentHeaderHeight()
 	//Waiting for block catch up header
-	if curHeaderHeight-curBlockHeight >= SYNC_MAX_HEADER_FORWARD_SIZE {
+	if curHeaderHeight-curBlockHeight >= SYNC_MAX_HEADER_FORWARD_SIZE && !this.IsFastSync() {
 		return
 	}
 	NextHeaderId := curHeaderHeight + 1
@@ -480,6 +483,9 @@ func (this *BlockSyncMgr) syncHeader() {
 }
 
 func (this *BlockSyncMgr) syncBlock() {
+	if this.IsFastSync() {
+		return
+	}
 	if this.tryGetSyncBlockLock() {
 		return
 	}
@@ -569,6 +575,7 @@ func (this *BlockSyncMgr) OnHeaderReceive(fromID p2pComm.PeerId, headers []*type
 	err := this.ledger.AddHeaders(headers)
 	this.delFlightHeader(height)
 	if err != nil {
+		this.server.Misbehave(fromID, reputation.INVALID_HEADERS)
 		this.addErrorRespCnt(fromID)
 		n := this.getNodeWeight(fromID)
 		if n != nil && n.GetErrorRespCnt() >= SYNC_MAX_ERROR_RESP_TIMES {
@@ -577,6 +584,10 @@ func (this *BlockSyncMgr) OnHeaderReceive(fromID p2pComm.PeerId, headers []*type
 		log.Warnf("[block-sync] OnHeaderReceive AddHeaders error:%s", err)
 		return
 	}
+	if this.IsFastSync() {
+		this.syncHeader()
+		return
+	}
 	sort.Slice(headers, func(i, j int) bool {
 		return headers[i].Height < headers[j].Height
 	})
@@ -618,6 +629,9 @@ func (this *BlockSyncMgr) OnBlockReceive(fromID p2pComm.PeerId, blockSize uint32
 	height := block.Header.Height
 	blockHash := block.Hash()
 	log.Tracef("[block-sync] OnBlockReceive Height:%d", height)
+	if this.IsFastSync() {
+		return
+	}
 	flightInfo := this.getFlightBlock(blockHash, fromID)
 	if flightInfo != nil {
 		t := (time.Now().UnixNano() - flightInfo.GetStartTime()) / int64(time.Millisecond)
@@ -735,6 +749,9 @@ func (this *BlockSyncMgr) releaseSaveBlockLock() {
 }
 
 func (this *BlockSyncMgr) saveBlock() {
+	if this.IsFastSync() {
+		return
+	}
 	if this.tryGetSaveBlockLock() {
 		return
 	}
@@ -750,10 +767,14 @@ func (this *BlockSyncMgr) saveBlock() {
 		err := this.ledger.AddBlock(nextBlock, ccMsg, merkleRoot)
 		this.delBlockCache(nextBlockHeight)
 		if err != nil {
-			this.addErrorRespCnt(fromID)
-			n := this.getNodeWeight(fromID)
-			if n != nil && n.GetErrorRespCnt() >= SYNC_MAX_ERROR_RESP_TIMES {
-				this.delNode(fromID)
+			// a failure of the local store is not the fault of the peer
+			if scom.IsInvalidBlock(err) {
+				this.server.Misbehave(fromID, reputation.INVALID_BLOCK)
+				this.addErrorRespCnt(fromID)
+				n := this.getNodeWeight(fromID)
+				if n != nil && n.GetErrorRespCnt() >= SYNC_MAX_ERROR_RESP_TIMES {
+					this.delNode(fromID)
+				}
 			}
 			log.Warnf("[block-sync] saveBlock Height:%d AddBlock error:%s", nextBlockHeight, err)
 			reqNode := this.getNextNode(nextBlockHeight)
@@ -933,6 +954,20 @@ func (this *BlockSyncMgr) getNodeWithMinFailedTimes(flightInfo *SyncFlightInfo,
 	}
 }
 
+//SetFastSync sets whether only the headers are synced, the blocks are synced again when the fast sync ends
+func (this *BlockSyncMgr) SetFastSync(enabled bool) {
+	if enabled {
+		atomic.StoreInt32(&this.fastSync, 1)
+	} else if atomic.SwapInt32(&this.fastSync, 0) == 1 {
+		go this.sync()
+	}
+}
+
+//IsFastSync returns whether only the headers are synced
+func (this *BlockSyncMgr) IsFastSync() bool {
+	return atomic.LoadInt32(&this.fastSync) == 1
+}
+
 //Stop to sync
 func (this *BlockSyncMgr) Stop() {
 	close(this.exitCh)
@@ -40,27 +40,28 @@ import (
 	"github.com/cntmio/cntmology/p2pserver/protocols/heatbeat"
 	"github.com/cntmio/cntmology/p2pserver/protocols/recent_peers"
 	"github.com/cntmio/cntmology/p2pserver/protocols/reconnect"
+	"github.com/cntmio/cntmology/p2pserver/protocols/snapshot_sync"
 	"github.com/cntmio/cntmology/p2pserver/protocols/subnet"
+	"github.com/cntmio/cntmology/p2pserver/protocols/tx_relay"
 	"github.com/cntmio/cntmology/p2pserver/protocols/utils"
+	"github.com/cntmio/cntmology/p2pserver/reputation"
 	common2 "github.com/cntmio/cntmology/txnpool/common"
 )
 
 //respCache cache for some response data
 var respCache, _ = lru.NewARC(msgCommon.MAX_RESP_CACHE_SIZE)
 
-//Store txHash, using for rejecting duplicate tx
-// thread safe
-var txCache, _ = lru.NewARC(msgCommon.MAX_TX_CACHE_SIZE)
-
 type MsgHandler struct {
 	seeds                    *utils.HostsResolver
 	blockSync                *block_sync.BlockSyncMgr
+	snapshotSync             *snapshot_sync.SnapshotSyncMgr
 	reconnect                *reconnect.ReconnectService
 	discovery                *discovery.Discovery
 	heatBeat                 *heatbeat.HeartBeat
 	bootstrap                *bootstrap.BootstrapService
 	persistRecentPeerService *recent_peers.PersistRecentPeerService
 	subnet                   *subnet.SubNet
+	txRelay                  *tx_relay.TxRelay
 	ledger                   *ledger.Ledger
 	acct                     *account.Account // nil if conenesus is not enabled
 	staticReserveFilter      p2p.AddressFilter
@@ -93,31 +94,38 @@ func (self *MsgHandler) GetSubnetMembersInfo() []msgCommon.SubnetMemberInfo {
 
 func (self *MsgHandler) start(net p2p.P2P) {
 	self.blockSync = block_sync.NewBlockSyncMgr(net, self.ledger)
+	self.snapshotSync = snapshot_sync.NewSnapshotSyncMgr(net, self.ledger, self.blockSync)
 	self.reconnect = reconnect.NewReconectService(net, self.staticReserveFilter)
 	maskFilter := self.subnet.GetMaskAddrFilter()
 	self.discovery = discovery.NewDiscovery(net, config.DefConfig.P2PNode.ReservedCfg.MaskPeers, maskFilter, 0)
 	self.bootstrap = bootstrap.NewBootstrapService(net, self.seeds)
 	self.heatBeat = heatbeat.NewHeartBeat(net, self.ledger)
 	self.persistRecentPeerService = recent_peers.NewPersistRecentPeerService(net)
+	self.txRelay = tx_relay.NewTxRelay(net, self.txPoolService, self.ledger)
 	go self.persistRecentPeerService.Start()
 	go self.blockSync.Start()
+	go self.snapshotSync.Start()
 	go self.reconnect.Start()
 	go self.discovery.Start()
 	go self.heatBeat.Start()
 	go self.bootstrap.Start()
 	go self.subnet.Start(net)
+	go self.txRelay.Start()
 
 	RegisterProposeOfflineVote(self.subnet)
+	RegisterReputationApi(net)
 }
 
 func (self *MsgHandler) stop() {
 	self.blockSync.Stop()
+	self.snapshotSync.Stop()
 	self.reconnect.Stop()
 	self.discovery.Stop()
 	self.persistRecentPeerService.Stop()
 	self.heatBeat.Stop()
 	self.bootstrap.Stop()
 	self.subnet.Stop()
+	self.txRelay.Stop()
 }
 
 func (self *MsgHandler) HandleSystemMessage(net p2p.P2P, msg p2p.SystemMessage) {
@@ -131,15 +139,20 @@ func (self *MsgHandler) HandleSystemMessage(net p2p.P2P, msg p2p.SystemMessage)
 		self.bootstrap.OnAddPeer(m.Info)
 		self.persistRecentPeerService.AddNodeAddr(m.Info.RemoteListenAddress())
 		self.subnet.OnAddPeer(net, m.Info)
+		self.txRelay.OnAddPeer(m.Info)
 	case p2p.PeerDisConnected:
 		self.blockSync.OnDelNode(m.Info.Id)
+		self.snapshotSync.OnDelNode(m.Info.Id)
 		self.reconnect.OnDelPeer(m.Info)
 		self.discovery.OnDelPeer(m.Info)
 		self.bootstrap.OnDelPeer(m.Info)
 		self.subnet.OnDelPeer(m.Info)
+		self.txRelay.OnDelPeer(m.Info)
 		self.persistRecentPeerService.DelNodeAddr(m.Info.RemoteListenAddress())
 	case p2p.NetworkStop:
 		self.stop()
+	case p2p.TxBroadcast:
+		self.txRelay.Announce(m.Tx)
 	case p2p.HostAddrDetected:
 		self.subnet.OnHostAddrDetected(m.ListenAddr)
 	}
@@ -171,17 +184,34 @@ func (self *MsgHandler) HandlePeerMessage(ctx *p2p.Ccntmext, msg msgTypes.Messag
 	case *msgTypes.Addr:
 		self.discovery.AddrHandle(ctx, m)
 	case *msgTypes.DataReq:
-		DataReqHandle(ctx, m)
+		if m.DataType == common.TRANSACTION {
+			self.txRelay.OnTxReq(ctx, m.Hash)
+		} else {
+			DataReqHandle(ctx, m)
+		}
 	case *msgTypes.Inv:
-		InvHandle(ctx, m)
+		if m.P.InvType == common.TRANSACTION {
+			self.txRelay.OnTxInv(ctx, m.P.Blk)
+		} else {
+			InvHandle(ctx, m)
+		}
 	case *msgTypes.SubnetMembersRequest:
 		self.subnet.OnMembersRequest(ctx, m)
 	case *msgTypes.SubnetMembers:
 		self.subnet.OnMembersResponse(ctx, m)
 	case *msgTypes.OfflineWitnessMsg:
 		self.subnet.OnOfflineWitnessMsg(ctx, m)
+	case *msgTypes.SnapshotInfoReq:
+		self.snapshotSync.OnSnapshotInfoReq(ctx)
+	case *msgTypes.SnapshotInfo:
+		self.snapshotSync.OnSnapshotInfo(ctx, m)
+	case *msgTypes.SnapshotChunkReq:
+		self.snapshotSync.OnSnapshotChunkReq(ctx, m)
+	case *msgTypes.SnapshotChunk:
+		self.snapshotSync.OnSnapshotChunk(ctx, m)
 	case *msgTypes.NotFound:
 		log.Debug("[p2p]receive notFound message, hash is ", m.Hash)
+		self.txRelay.OnNotFound(ctx, m.Hash)
 	default:
 		msgType := msg.CmdType()
 		if msgType == msgCommon.VERACK_TYPE || msgType == msgCommon.VERSION_TYPE {
@@ -215,8 +245,7 @@ func HeadersReqHandle(ctx *p2p.Ccntmext, headersReq *msgTypes.HeadersReq) {
 func (self *MsgHandler) blockHandle(ctx *p2p.Ccntmext, block *msgTypes.Block) {
 	stateHashHeight := config.GetStateHashCheckHeight(config.DefConfig.P2PNode.NetworkId)
 	if block.Blk.Header.Height >= stateHashHeight && block.MerkleRoot == common.UINT256_EMPTY {
-		remotePeer := ctx.Sender()
-		remotePeer.Close()
+		ctx.Network().Misbehave(ctx.Sender().GetID(), reputation.INVALID_BLOCK)
 		return
 	}
 
@@ -229,6 +258,7 @@ func ConsensusHandle(ctx *p2p.Ccntmext, consensus *msgTypes.Consensus) {
 	if cpid != nil {
 		if err := consensus.Cons.Verify(); err != nil {
 			log.Warn(err)
+			ctx.Network().Misbehave(ctx.Sender().GetID(), reputation.INVALID_CONSENSUS)
 			return
 		}
 		consensus.Cons.PeerId = ctx.Sender().GetID()
@@ -238,15 +268,12 @@ func ConsensusHandle(ctx *p2p.Ccntmext, consensus *msgTypes.Consensus) {
 
 // TransactionHandle handles the transaction message from peer
 func (self *MsgHandler) transactionHandle(ctx *p2p.Ccntmext, trn *msgTypes.Trn) {
-	if !txCache.Ccntmains(trn.Txn.Hash()) {
-		txCache.Add(trn.Txn.Hash(), nil)
+	if self.txRelay.OnTxReceived(ctx.Sender().GetID(), trn.Txn.Hash()) {
 		self.txPoolService.AppendTransactionAsync(common2.NetSender, trn.Txn)
-	} else {
-		log.Tracef("[p2p]receive duplicate Transaction message, txHash: %x\n", trn.Txn.Hash())
 	}
 }
 
-// DataReqHandle handles the data req(block/Transaction) from peer
+// DataReqHandle handles the block data req from peer, transactions are served by tx relay
 func DataReqHandle(ctx *p2p.Ccntmext, dataReq *msgTypes.DataReq) {
 	remotePeer := ctx.Sender()
 	reqType := common.InventoryType(dataReq.DataType)
@@ -307,30 +334,11 @@ func DataReqHandle(ctx *p2p.Ccntmext, dataReq *msgTypes.DataReq) {
 			log.Warn(err)
 			return
 		}
-
-	case common.TRANSACTION:
-		txn, _, err := ledger.DefLedger.GetTransaction(hash)
-		if err != nil {
-			log.Debug("[p2p]Can't get transaction by hash: ",
-				hash, " ,send not found message")
-			msg := msgpack.NewNotFound(hash)
-			err = remotePeer.Send(msg)
-			if err != nil {
-				log.Warn(err)
-				return
-			}
-		}
-		msg := msgpack.NewTxn(txn)
-		err = remotePeer.Send(msg)
-		if err != nil {
-			log.Warn(err)
-			return
-		}
 	}
 }
 
-// InvHandle handles the inventory message(block,
-// transaction and consensus) from peer.
+// InvHandle handles the inventory message(block
+// and consensus) from peer, transactions are handled by tx relay.
 func InvHandle(ctx *p2p.Ccntmext, inv *msgTypes.Inv) {
 	remotePeer := ctx.Sender()
 	if len(inv.P.Blk) == 0 {
@@ -344,19 +352,6 @@ func InvHandle(ctx *p2p.Ccntmext, inv *msgTypes.Inv) {
 
 	invType := common.InventoryType(inv.P.InvType)
 	switch invType {
-	case common.TRANSACTION:
-		log.Debug("[p2p]receive transaction message", id)
-		// TODO check the ID queue
-		id = inv.P.Blk[0]
-		trn, _, err := ledger.DefLedger.GetTransaction(id)
-		if trn == nil || err != nil {
-			msg := msgpack.NewTxnDataReq(id)
-			err = remotePeer.Send(msg)
-			if err != nil {
-				log.Warn(err)
-				return
-			}
-		}
 	case common.BLOCK:
 		log.Debug("[p2p]receive block message")
 		for _, id = range inv.P.Blk {
@@ -19,9 +19,14 @@
 package protocols
 
 import (
+	"time"
+
 	"github.com/cntmio/cntmology/http/base/error"
 	"github.com/cntmio/cntmology/http/base/rpc"
+	"github.com/cntmio/cntmology/p2pserver/common"
+	p2p "github.com/cntmio/cntmology/p2pserver/net/protocol"
 	"github.com/cntmio/cntmology/p2pserver/protocols/subnet"
+	"github.com/cntmio/cntmology/p2pserver/reputation"
 )
 
 func RegisterProposeOfflineVote(subnet *subnet.SubNet) {
@@ -52,3 +57,73 @@ func RegisterProposeOfflineVote(subnet *subnet.SubNet) {
 		return rpc.ResponseSuccess(votes)
 	})
 }
+
+func RegisterReputationApi(net p2p.P2P) {
+	// curl http://localhost:20337/local -v -d '{"method":"getPeerScores", "params":[]}'
+	rpc.HandleFunc("getPeerScores", func(params []interface{}) map[string]interface{} {
+		return rpc.ResponseSuccess(net.GetReputation().GetScores())
+	})
+
+	// curl http://localhost:20337/local -v -d '{"method":"getBannedPeers", "params":[]}'
+	rpc.HandleFunc("getBannedPeers", func(params []interface{}) map[string]interface{} {
+		return rpc.ResponseSuccess(net.GetReputation().GetBannedPeers())
+	})
+
+	// ban for 3600 seconds, the duration and reason are optional
+	// curl http://localhost:20337/local -v -d '{"method":"banPeer", "params":["peerid", 3600, "reason"]}'
+	rpc.HandleFunc("banPeer", func(params []interface{}) map[string]interface{} {
+		if len(params) < 1 || len(params) > 3 {
+			return rpc.ResponsePack(error.INVALID_PARAMS, "")
+		}
+		id, ok := parsePeerId(params[0])
+		if !ok {
+			return rpc.ResponsePack(error.INVALID_PARAMS, "")
+		}
+		duration := reputation.DEFAULT_BAN_DURATION
+		if len(params) > 1 {
+			seconds, ok := params[1].(float64)
+			if !ok || seconds <= 0 {
+				return rpc.ResponsePack(error.INVALID_PARAMS, "")
+			}
+			duration = time.Duration(seconds) * time.Second
+		}
+		reason := "banned manually"
+		if len(params) > 2 {
+			reason, ok = params[2].(string)
+			if !ok {
+				return rpc.ResponsePack(error.INVALID_PARAMS, "")
+			}
+		}
+
+		net.BanPeer(id, duration, reason)
+		return rpc.ResponseSuccess(nil)
+	})
+
+	// curl http://localhost:20337/local -v -d '{"method":"unbanPeer", "params":["peerid"]}'
+	rpc.HandleFunc("unbanPeer", func(params []interface{}) map[string]interface{} {
+		if len(params) != 1 {
+			return rpc.ResponsePack(error.INVALID_PARAMS, "")
+		}
+		id, ok := parsePeerId(params[0])
+		if !ok {
+			return rpc.ResponsePack(error.INVALID_PARAMS, "")
+		}
+		if !net.UnbanPeer(id) {
+			return rpc.ResponsePack(error.INVALID_PARAMS, "peer is not banned")
+		}
+
+		return rpc.ResponseSuccess(nil)
+	})
+}
+
+func parsePeerId(param interface{}) (common.PeerId, bool) {
+	str, ok := param.(string)
+	if !ok {
+		return common.PeerId{}, false
+	}
+	id, err := common.PeerIdFromHexString(str)
+	if err != nil {
+		return common.PeerId{}, false
+	}
+	return id, true
+}
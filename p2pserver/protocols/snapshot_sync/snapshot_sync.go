@@ -0,0 +1,418 @@
+/*
+ * Copyright (C) 2018 The cntmology Authors
+ * This file is part of The cntmology library.
+ *
+ * The cntmology is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The cntmology is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * alcntm with The cntmology.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package snapshot_sync
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/cntmio/cntmology/common"
+	"github.com/cntmio/cntmology/common/config"
+	"github.com/cntmio/cntmology/common/log"
+	"github.com/cntmio/cntmology/core/ledger"
+	p2pComm "github.com/cntmio/cntmology/p2pserver/common"
+	msgpack "github.com/cntmio/cntmology/p2pserver/message/msg_pack"
+	"github.com/cntmio/cntmology/p2pserver/message/types"
+	p2p "github.com/cntmio/cntmology/p2pserver/net/protocol"
+	"github.com/cntmio/cntmology/p2pserver/protocols/block_sync"
+	"github.com/cntmio/cntmology/p2pserver/reputation"
+)
+
+const (
+	SNAPSHOT_MIN_AGREED_PEERS   = 2                //Least number of peers announcing the same snapshot before it is downloaded, it only saves bad downloads
+	SNAPSHOT_MAX_FLIGHT_CHUNKS  = 8                //Number of chunks on flight
+	SNAPSHOT_CHUNK_TIMEOUT      = 10 * time.Second //Request chunk timeout time, the chunk is requested from another peer after it
+	SNAPSHOT_INFO_INTERVAL      = 5 * time.Second  //Interval of the snapshot info requests and the download checks
+	SNAPSHOT_WAIT_TIMEOUT       = 2 * time.Minute  //Fall back to the block sync if no snapshot is agreed after it
+	SNAPSHOT_MAX_IMPORT_FAILURE = 3                //Fall back to the block sync after the import failed so many times
+	SNAPSHOT_DOWNLOAD_FILE      = "fastsync.dat"   //File the snapshot is downloaded to, under the snapshot directory
+)
+
+//chunkFlight records a chunk request on flight
+type chunkFlight struct {
+	peer      p2pComm.PeerId
+	startTime time.Time
+}
+
+//download is the snapshot being downloaded
+type download struct {
+	info     types.SnapshotInfo
+	file     *os.File
+	next     uint64                 //Offset of the next chunk never requested
+	pending  []uint64               //Offsets of the chunks to request again
+	flights  map[uint64]chunkFlight //Map chunk offset => request
+	received uint64                 //Number of bytes written to the file
+}
+
+//SnapshotSyncMgr serves the state snapshots exported by the ledger, and when the fast sync is enabled, downloads
+//the latest snapshot agreed by the peers and imports it instead of executing the blocks from the genesis block.
+//The block sync only syncs the headers until the snapshot is imported, so the snapshot block is checked against
+//the synced headers before it is downloaded, and the state against the state trie root committed to by them.
+type SnapshotSyncMgr struct {
+	net       p2p.P2P
+	ledger    *ledger.Ledger
+	blockSync *block_sync.BlockSyncMgr
+	path      string //Path of the download file
+	quit      chan bool
+
+	lock      sync.Mutex
+	running   bool                                   //The fast sync is running
+	importing bool                                   //The downloaded snapshot is importing
+	failures  int                                    //Number of failed imports
+	startTime time.Time                              //Time the fast sync started
+	infos     map[p2pComm.PeerId]*types.SnapshotInfo //Map peer => latest snapshot announced by the peer
+	banned    map[common.Uint256]bool                //Digests of the snapshots failed to import
+	download  *download                              //Snapshot being downloaded, nil if none
+}
+
+//NewSnapshotSyncMgr return a SnapshotSyncMgr instance, the fast sync is enabled by config
+func NewSnapshotSyncMgr(net p2p.P2P, ld *ledger.Ledger, blockSync *block_sync.BlockSyncMgr) *SnapshotSyncMgr {
+	dir := filepath.Join(config.DefConfig.Common.DataDir, config.DefConfig.P2PNode.NetworkName, "snapshots")
+	this := &SnapshotSyncMgr{
+		net:       net,
+		ledger:    ld,
+		blockSync: blockSync,
+		path:      filepath.Join(dir, SNAPSHOT_DOWNLOAD_FILE),
+		quit:      make(chan bool),
+		infos:     make(map[p2pComm.PeerId]*types.SnapshotInfo),
+		banned:    make(map[common.Uint256]bool),
+	}
+	if config.DefConfig.P2PNode.FastSync {
+		if ld.GetCurrentBlockHeight() == 0 {
+			this.running = true
+			blockSync.SetFastSync(true)
+		} else {
+			log.Infof("[snapshot-sync] ledger is at block %d, fast sync is skipped", ld.GetCurrentBlockHeight())
+		}
+	}
+	return this
+}
+
+//Start to fast sync if enabled
+func (this *SnapshotSyncMgr) Start() {
+	this.lock.Lock()
+	running := this.running
+	this.startTime = time.Now()
+	this.lock.Unlock()
+	if !running {
+		return
+	}
+	log.Info("[snapshot-sync] fast sync started, waiting for the state snapshots of the peers")
+	ticker := time.NewTicker(SNAPSHOT_INFO_INTERVAL)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-this.quit:
+			return
+		case <-ticker.C:
+			if !this.check() {
+				return
+			}
+		}
+	}
+}
+
+//Stop to fast sync
+func (this *SnapshotSyncMgr) Stop() {
+	close(this.quit)
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	this.closeDownload(true)
+}
+
+//check drives the fast sync, it returns false once the fast sync ends
+func (this *SnapshotSyncMgr) check() bool {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	if !this.running {
+		return false
+	}
+	if this.importing {
+		return true
+	}
+	if this.download != nil {
+		this.requestChunks()
+		return true
+	}
+	info := this.agreedSnapshot()
+	if info == nil {
+		if time.Since(this.startTime) > SNAPSHOT_WAIT_TIMEOUT {
+			this.finish("no state snapshot agreed by the peers")
+			return false
+		}
+		this.net.Broadcast(msgpack.NewSnapshotInfoReq())
+		return true
+	}
+	//the snapshot block must be the block synced by the header sync, and the state is verified against the
+	//state trie root committed to by the header of the next block
+	if this.ledger.GetCurrentHeaderHeight() <= info.Height {
+		log.Infof("[snapshot-sync] waiting for the headers to pass the snapshot block %d", info.Height)
+		return true
+	}
+	if this.ledger.GetBlockHash(info.Height) != info.BlockHash {
+		log.Warnf("[snapshot-sync] the snapshot block %d does not match the synced header", info.Height)
+		this.banned[info.Digest] = true
+		return true
+	}
+	if err := this.startDownload(info); err != nil {
+		log.Errorf("[snapshot-sync] start download error %s", err)
+		this.finish("the snapshot can not be downloaded")
+		return false
+	}
+	this.requestChunks()
+	return true
+}
+
+//agreedSnapshot returns the highest snapshot announced by enough peers
+func (this *SnapshotSyncMgr) agreedSnapshot() *types.SnapshotInfo {
+	counts := make(map[types.SnapshotInfo]int)
+	var agreed *types.SnapshotInfo
+	for _, info := range this.infos {
+		if info.Height == 0 || info.Size <= common.UINT256_SIZE || this.banned[info.Digest] {
+			continue
+		}
+		counts[*info]++
+		if counts[*info] >= SNAPSHOT_MIN_AGREED_PEERS && (agreed == nil || info.Height > agreed.Height) {
+			agreed = info
+		}
+	}
+	return agreed
+}
+
+func (this *SnapshotSyncMgr) startDownload(info *types.SnapshotInfo) error {
+	if err := os.MkdirAll(filepath.Dir(this.path), 0755); err != nil {
+		return err
+	}
+	file, err := os.Create(this.path)
+	if err != nil {
+		return err
+	}
+	log.Infof("[snapshot-sync] download state snapshot of block %d, size %d", info.Height, info.Size)
+	this.download = &download{
+		info:    *info,
+		file:    file,
+		flights: make(map[uint64]chunkFlight),
+	}
+	return nil
+}
+
+//closeDownload closes the download file, and removes it if the snapshot is dropped
+func (this *SnapshotSyncMgr) closeDownload(remove bool) {
+	if this.download == nil {
+		return
+	}
+	this.download.file.Close()
+	this.download = nil
+	if remove {
+		os.Remove(this.path)
+	}
+}
+
+//requestChunks requests the chunks timeout and the next chunks from the peers announcing the snapshot
+func (this *SnapshotSyncMgr) requestChunks() {
+	d := this.download
+	for offset, flight := range d.flights {
+		if time.Since(flight.startTime) > SNAPSHOT_CHUNK_TIMEOUT {
+			log.Debugf("[snapshot-sync] chunk %d from peer %s timeout", offset, flight.peer.ToHexString())
+			delete(d.flights, offset)
+			d.pending = append(d.pending, offset)
+		}
+	}
+	var peers []p2pComm.PeerId
+	for id, info := range this.infos {
+		if *info == d.info {
+			peers = append(peers, id)
+		}
+	}
+	if len(peers) == 0 {
+		log.Warnf("[snapshot-sync] no peer serves the snapshot of block %d, download dropped", d.info.Height)
+		this.banned[d.info.Digest] = true
+		this.closeDownload(true)
+		return
+	}
+	for i := 0; len(d.flights) < SNAPSHOT_MAX_FLIGHT_CHUNKS; i++ {
+		var offset uint64
+		if len(d.pending) > 0 {
+			offset, d.pending = d.pending[0], d.pending[1:]
+		} else if d.next < d.info.Size {
+			offset = d.next
+			d.next += p2pComm.MAX_SNAPSHOT_CHUNK_LEN
+		} else {
+			return
+		}
+		length := uint64(p2pComm.MAX_SNAPSHOT_CHUNK_LEN)
+		if d.info.Size-offset < length {
+			length = d.info.Size - offset
+		}
+		id := peers[(int(offset/p2pComm.MAX_SNAPSHOT_CHUNK_LEN)+i)%len(peers)]
+		d.flights[offset] = chunkFlight{peer: id, startTime: time.Now()}
+		this.net.SendTo(id, msgpack.NewSnapshotChunkReq(d.info.Height, offset, uint32(length)))
+	}
+}
+
+//OnSnapshotInfoReq sends the latest snapshot of the ledger to the peer
+func (this *SnapshotSyncMgr) OnSnapshotInfoReq(ctx *p2p.Ccntmext) {
+	msg := &types.SnapshotInfo{}
+	file, err := this.ledger.LatestStateSnapshot()
+	if err == nil {
+		msg.Height = file.Height
+		msg.BlockHash = file.BlockHash
+		msg.StateRoot = file.StateRoot
+		msg.Size = file.Size
+		msg.Digest = file.Digest
+	}
+	if err := ctx.Sender().Send(msg); err != nil {
+		log.Warn(err)
+	}
+}
+
+//OnSnapshotInfo records the latest snapshot of the peer
+func (this *SnapshotSyncMgr) OnSnapshotInfo(ctx *p2p.Ccntmext, info *types.SnapshotInfo) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	if this.running {
+		this.infos[ctx.Sender().GetID()] = info
+	}
+}
+
+//OnSnapshotChunkReq sends the requested bytes of the latest snapshot of the ledger to the peer
+func (this *SnapshotSyncMgr) OnSnapshotChunkReq(ctx *p2p.Ccntmext, req *types.SnapshotChunkReq) {
+	var data []byte
+	file, err := this.ledger.LatestStateSnapshot()
+	if err == nil && file.Height == req.Height && req.Offset < file.Size {
+		length := uint64(req.Length)
+		if file.Size-req.Offset < length {
+			length = file.Size - req.Offset
+		}
+		data, err = readChunk(file.Path, req.Offset, length)
+		if err != nil {
+			log.Warnf("[snapshot-sync] read snapshot chunk error %s", err)
+		}
+	}
+	//an empty chunk tells the snapshot is not served anymore
+	if err := ctx.Sender().Send(msgpack.NewSnapshotChunk(req.Height, req.Offset, data)); err != nil {
+		log.Warn(err)
+	}
+}
+
+func readChunk(path string, offset, length uint64) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	data := make([]byte, length)
+	if _, err = f.ReadAt(data, int64(offset)); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+//OnSnapshotChunk writes the chunk requested from the peer to the download file
+func (this *SnapshotSyncMgr) OnSnapshotChunk(ctx *p2p.Ccntmext, chunk *types.SnapshotChunk) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	d := this.download
+	if d == nil || this.importing || chunk.Height != d.info.Height {
+		return
+	}
+	id := ctx.Sender().GetID()
+	flight, ok := d.flights[chunk.Offset]
+	if !ok || flight.peer != id {
+		ctx.Network().Misbehave(id, reputation.UNSOLICITED_DATA)
+		return
+	}
+	delete(d.flights, chunk.Offset)
+	length := d.info.Size - chunk.Offset
+	if length > p2pComm.MAX_SNAPSHOT_CHUNK_LEN {
+		length = p2pComm.MAX_SNAPSHOT_CHUNK_LEN
+	}
+	if uint64(len(chunk.Data)) != length {
+		//the peer does not serve the snapshot anymore
+		delete(this.infos, id)
+		d.pending = append(d.pending, chunk.Offset)
+		this.requestChunks()
+		return
+	}
+	if _, err := d.file.WriteAt(chunk.Data, int64(chunk.Offset)); err != nil {
+		log.Errorf("[snapshot-sync] write snapshot chunk error %s", err)
+		this.closeDownload(true)
+		this.finish("the snapshot can not be downloaded")
+		return
+	}
+	d.received += length
+	if d.received < d.info.Size {
+		this.requestChunks()
+		return
+	}
+
+	//the digest at the end of the file is checked against the content when importing
+	var digest common.Uint256
+	_, err := d.file.ReadAt(digest[:], int64(d.info.Size-common.UINT256_SIZE))
+	if err != nil || digest != d.info.Digest {
+		log.Warnf("[snapshot-sync] the downloaded snapshot of block %d does not match the digest", d.info.Height)
+		this.banned[d.info.Digest] = true
+		this.closeDownload(true)
+		return
+	}
+	d.file.Close()
+	this.importing = true
+	go this.importSnapshot(d.info)
+}
+
+//importSnapshot imports the downloaded snapshot to the ledger, and ends the fast sync if it succeeds
+func (this *SnapshotSyncMgr) importSnapshot(info types.SnapshotInfo) {
+	_, err := this.ledger.ImportStateSnapshot(this.path, false)
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	this.importing = false
+	this.download = nil
+	os.Remove(this.path)
+	if err != nil {
+		log.Errorf("[snapshot-sync] import state snapshot of block %d error %s", info.Height, err)
+		this.banned[info.Digest] = true
+		this.failures++
+		if this.failures >= SNAPSHOT_MAX_IMPORT_FAILURE || this.ledger.GetCurrentBlockHeight() != 0 {
+			this.finish("the snapshots can not be imported")
+		}
+		return
+	}
+	log.Infof("[snapshot-sync] fast synced to block %d", info.Height)
+	this.finish("")
+}
+
+//finish ends the fast sync and resumes the block sync
+func (this *SnapshotSyncMgr) finish(reason string) {
+	if reason != "" {
+		log.Warnf("[snapshot-sync] fast sync stopped, %s, sync blocks from block %d", reason,
+			this.ledger.GetCurrentBlockHeight()+1)
+	}
+	this.running = false
+	this.infos = make(map[p2pComm.PeerId]*types.SnapshotInfo)
+	this.blockSync.SetFastSync(false)
+}
+
+//OnDelNode forgets the snapshot of the peer
+func (this *SnapshotSyncMgr) OnDelNode(nodeId p2pComm.PeerId) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	delete(this.infos, nodeId)
+}
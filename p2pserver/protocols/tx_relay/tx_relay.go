@@ -0,0 +1,341 @@
+/*
+ * Copyright (C) 2018 The cntmology Authors
+ * This file is part of The cntmology library.
+ *
+ * The cntmology is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The cntmology is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * alcntm with The cntmology.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package tx_relay
+
+import (
+	"sync"
+	"time"
+
+	comm "github.com/cntmio/cntmology/common"
+	"github.com/cntmio/cntmology/common/log"
+	"github.com/cntmio/cntmology/core/ledger"
+	"github.com/cntmio/cntmology/core/types"
+	"github.com/cntmio/cntmology/p2pserver/common"
+	msgpack "github.com/cntmio/cntmology/p2pserver/message/msg_pack"
+	p2p "github.com/cntmio/cntmology/p2pserver/net/protocol"
+	"github.com/cntmio/cntmology/p2pserver/peer"
+	lru "github.com/hashicorp/golang-lru"
+)
+
+//TxPool is the part of tx pool used to answer the tx requests
+type TxPool interface {
+	GetTransaction(hash comm.Uint256) *types.Transaction
+}
+
+//TxRelay announces transactions by hash in batches instead of flooding the full transaction,
+//peers request the ones missing from their pool
+type TxRelay struct {
+	net    p2p.P2P
+	pool   TxPool
+	ledger *ledger.Ledger // nil in tests
+
+	lock      sync.Mutex
+	pending   []comm.Uint256               // hashes waiting for the next announcement
+	known     map[common.PeerId]*lru.Cache // hashes known by each peer
+	requested map[comm.Uint256]*txRequest  // hashes requested and not received yet
+	seen      *lru.ARCCache                // hashes received, using for rejecting duplicate tx
+	quit      chan bool
+}
+
+//txRequest is a transaction requested from a peer, it is requested from the next announcer
+//if the peer does not answer in time or does not have it
+type txRequest struct {
+	time       time.Time
+	peer       common.PeerId
+	announcers []common.PeerId
+}
+
+func NewTxRelay(net p2p.P2P, pool TxPool, ld *ledger.Ledger) *TxRelay {
+	seen, _ := lru.NewARC(common.MAX_TX_CACHE_SIZE)
+	return &TxRelay{
+		net:       net,
+		pool:      pool,
+		ledger:    ld,
+		known:     make(map[common.PeerId]*lru.Cache),
+		requested: make(map[comm.Uint256]*txRequest),
+		seen:      seen,
+		quit:      make(chan bool),
+	}
+}
+
+func (self *TxRelay) Start() {
+	ticker := time.NewTicker(common.TX_ANNOUNCE_INTERVAL * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			self.flush()
+			self.expireRequests()
+		case <-self.quit:
+			return
+		}
+	}
+}
+
+func (self *TxRelay) Stop() {
+	close(self.quit)
+}
+
+func (self *TxRelay) OnAddPeer(info *peer.PeerInfo) {
+	known, _ := lru.New(common.MAX_KNOWN_TX_CNT)
+	self.lock.Lock()
+	self.known[info.Id] = known
+	self.lock.Unlock()
+}
+
+func (self *TxRelay) OnDelPeer(info *peer.PeerInfo) {
+	self.lock.Lock()
+	delete(self.known, info.Id)
+	self.lock.Unlock()
+}
+
+//Announce queue the hash of transaction for the next announcement
+func (self *TxRelay) Announce(tx *types.Transaction) {
+	self.lock.Lock()
+	self.pending = append(self.pending, tx.Hash())
+	full := len(self.pending) >= common.MAX_INV_TX_CNT
+	self.lock.Unlock()
+	if full {
+		self.flush()
+	}
+}
+
+//flush send the pending hashes to the relay peers which do not know them yet
+func (self *TxRelay) flush() {
+	self.lock.Lock()
+	hashes := self.pending
+	self.pending = nil
+	self.lock.Unlock()
+	if len(hashes) == 0 {
+		return
+	}
+
+	for _, p := range self.net.GetNeighbors() {
+		if !p.GetRelay() {
+			continue
+		}
+		unknown := self.markKnown(p.GetID(), hashes)
+		for len(unknown) > 0 {
+			n := len(unknown)
+			if n > common.MAX_INV_TX_CNT {
+				n = common.MAX_INV_TX_CNT
+			}
+			msg := msgpack.NewInv(msgpack.NewInvPayload(comm.TRANSACTION, unknown[:n]))
+			if err := p.Send(msg); err != nil {
+				log.Debugf("[p2p]announce tx to %s failed: %s", p.GetAddr(), err)
+				break
+			}
+			unknown = unknown[n:]
+		}
+	}
+}
+
+//markKnown record the hashes as known by peer and return the ones it did not know
+func (self *TxRelay) markKnown(id common.PeerId, hashes []comm.Uint256) []comm.Uint256 {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	known := self.known[id]
+	if known == nil {
+		return nil
+	}
+	var unknown []comm.Uint256
+	for _, hash := range hashes {
+		if ok, _ := known.ContainsOrAdd(hash, nil); !ok {
+			unknown = append(unknown, hash)
+		}
+	}
+	return unknown
+}
+
+//OnTxInv request the announced transactions missing from local pool and ledger. The hashes beyond
+//MAX_INV_TX_CNT or MAX_INV_TX_LOOKUP ledger lookups are ignored, as well as the new ones once
+//MAX_TX_REQUESTED transactions are requested
+func (self *TxRelay) OnTxInv(ctx *p2p.Ccntmext, hashes []comm.Uint256) {
+	if len(hashes) > common.MAX_INV_TX_CNT {
+		hashes = hashes[:common.MAX_INV_TX_CNT]
+	}
+	sender := ctx.Sender()
+	id := sender.GetID()
+	self.markKnown(id, hashes)
+	lookups := 0
+	for _, hash := range hashes {
+		if self.seen.Ccntmains(hash) || self.addAnnouncer(hash, id) {
+			ccntminue
+		}
+		if self.pool.GetTransaction(hash) != nil {
+			ccntminue
+		}
+		if self.ledger != nil {
+			if lookups >= common.MAX_INV_TX_LOOKUP {
+				return
+			}
+			lookups++
+			if self.inLedger(hash) {
+				ccntminue
+			}
+		}
+		self.lock.Lock()
+		req := self.requested[hash]
+		full := len(self.requested) >= common.MAX_TX_REQUESTED
+		if req == nil && !full {
+			self.requested[hash] = &txRequest{time: time.Now(), peer: id}
+		}
+		self.lock.Unlock()
+		if req != nil {
+			self.addAnnouncer(hash, id)
+			ccntminue
+		}
+		if full {
+			return
+		}
+		if err := sender.Send(msgpack.NewTxnDataReq(hash)); err != nil {
+			log.Debugf("[p2p]request tx from %s failed: %s", sender.GetAddr(), err)
+			return
+		}
+	}
+}
+
+//addAnnouncer record the peer to request the transaction from if it is already requested from another
+//one, return false if the transaction is not requested
+func (self *TxRelay) addAnnouncer(hash comm.Uint256, id common.PeerId) bool {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	req := self.requested[hash]
+	if req == nil {
+		return false
+	}
+	if req.peer == id || len(req.announcers) >= common.MAX_TX_ANNOUNCERS {
+		return true
+	}
+	for _, announcer := range req.announcers {
+		if announcer == id {
+			return true
+		}
+	}
+	req.announcers = append(req.announcers, id)
+	return true
+}
+
+//OnNotFound request the transaction from the next announcer if the peer requested does not have it
+func (self *TxRelay) OnNotFound(ctx *p2p.Ccntmext, hash comm.Uint256) {
+	self.lock.Lock()
+	req := self.requested[hash]
+	if req == nil || req.peer != ctx.Sender().GetID() {
+		self.lock.Unlock()
+		return
+	}
+	next := self.nextAnnouncer(hash, req)
+	self.lock.Unlock()
+	if next != nil {
+		self.request(next, hash)
+	}
+}
+
+//nextAnnouncer move the request to the next announcer still connected, the request is dropped
+//if there is none. It is called with the lock held
+func (self *TxRelay) nextAnnouncer(hash comm.Uint256, req *txRequest) *peer.Peer {
+	for len(req.announcers) > 0 {
+		id := req.announcers[0]
+		req.announcers = req.announcers[1:]
+		if p := self.net.GetPeer(id); p != nil {
+			req.peer = id
+			req.time = time.Now()
+			return p
+		}
+	}
+	delete(self.requested, hash)
+	return nil
+}
+
+func (self *TxRelay) request(p *peer.Peer, hash comm.Uint256) {
+	if err := p.Send(msgpack.NewTxnDataReq(hash)); err != nil {
+		log.Debugf("[p2p]request tx from %s failed: %s", p.GetAddr(), err)
+	}
+}
+
+//OnTxReceived record the transaction received from peer, return false if it is duplicated
+func (self *TxRelay) OnTxReceived(id common.PeerId, hash comm.Uint256) bool {
+	self.markKnown(id, []comm.Uint256{hash})
+	self.lock.Lock()
+	delete(self.requested, hash)
+	self.lock.Unlock()
+	if ok, _ := self.seen.ContainsOrAdd(hash, nil); ok {
+		log.Tracef("[p2p]receive duplicate Transaction message, txHash: %x\n", hash)
+		return false
+	}
+	return true
+}
+
+//OnTxReq send the requested transaction from pool or ledger
+func (self *TxRelay) OnTxReq(ctx *p2p.Ccntmext, hash comm.Uint256) {
+	remotePeer := ctx.Sender()
+	tx := self.getTransaction(hash)
+	if tx == nil {
+		log.Debug("[p2p]Can't get transaction by hash: ", hash, " ,send not found message")
+		if err := remotePeer.Send(msgpack.NewNotFound(hash)); err != nil {
+			log.Warn(err)
+		}
+		return
+	}
+	self.markKnown(remotePeer.GetID(), []comm.Uint256{hash})
+	if err := remotePeer.Send(msgpack.NewTxn(tx)); err != nil {
+		log.Warn(err)
+	}
+}
+
+func (self *TxRelay) inLedger(hash comm.Uint256) bool {
+	ok, err := self.ledger.IsCcntmainTransaction(hash)
+	return err == nil && ok
+}
+
+func (self *TxRelay) getTransaction(hash comm.Uint256) *types.Transaction {
+	if tx := self.pool.GetTransaction(hash); tx != nil {
+		return tx
+	}
+	if self.ledger == nil {
+		return nil
+	}
+	tx, _, err := self.ledger.GetTransaction(hash)
+	if err != nil {
+		return nil
+	}
+	return tx
+}
+
+//expireRequests request the transactions not received in time from their next announcers
+func (self *TxRelay) expireRequests() {
+	deadline := time.Now().Add(-common.TX_REQUEST_TIMEOUT * time.Second)
+	type retry struct {
+		peer *peer.Peer
+		hash comm.Uint256
+	}
+	var retries []retry
+	self.lock.Lock()
+	for hash, req := range self.requested {
+		if req.time.Before(deadline) {
+			if next := self.nextAnnouncer(hash, req); next != nil {
+				retries = append(retries, retry{next, hash})
+			}
+		}
+	}
+	self.lock.Unlock()
+	for _, r := range retries {
+		self.request(r.peer, r.hash)
+	}
+}
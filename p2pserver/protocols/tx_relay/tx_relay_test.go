@@ -0,0 +1,207 @@
+/*
+ * Copyright (C) 2018 The cntmology Authors
+ * This file is part of The cntmology library.
+ *
+ * The cntmology is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The cntmology is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * alcntm with The cntmology.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package tx_relay
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	comm "github.com/cntmio/cntmology/common"
+	"github.com/cntmio/cntmology/core/types"
+	"github.com/cntmio/cntmology/p2pserver/common"
+	mt "github.com/cntmio/cntmology/p2pserver/message/types"
+	p2p "github.com/cntmio/cntmology/p2pserver/net/protocol"
+	"github.com/cntmio/cntmology/p2pserver/peer"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeNet struct {
+	p2p.P2P
+	peers []*peer.Peer
+}
+
+func (self *fakeNet) GetNeighbors() []*peer.Peer {
+	return self.peers
+}
+
+func (self *fakeNet) GetPeer(id common.PeerId) *peer.Peer {
+	for _, p := range self.peers {
+		if p.GetID() == id {
+			return p
+		}
+	}
+	return nil
+}
+
+type fakePool map[comm.Uint256]*types.Transaction
+
+func (self fakePool) GetTransaction(hash comm.Uint256) *types.Transaction {
+	return self[hash]
+}
+
+type testPeer struct {
+	*peer.Peer
+	msgs chan mt.Message
+}
+
+func newTestPeer(id uint64) *testPeer {
+	local, remote := net.Pipe()
+	info := &peer.PeerInfo{Id: common.PseudoPeerIdFromUint64(id), Relay: true}
+	p := &testPeer{Peer: peer.NewPeer(info, local, nil), msgs: make(chan mt.Message, 10)}
+	go func() {
+		for {
+			msg, _, err := mt.ReadMessage(remote)
+			if err != nil {
+				return
+			}
+			p.msgs <- msg
+		}
+	}()
+	return p
+}
+
+func (self *testPeer) next(t *testing.T) mt.Message {
+	select {
+	case msg := <-self.msgs:
+		return msg
+	case <-time.After(time.Second):
+		t.Fatal("no message received")
+	}
+	return nil
+}
+
+func newTestRelay(pool fakePool, peers ...*testPeer) (*TxRelay, *fakeNet) {
+	nw := &fakeNet{}
+	relay := NewTxRelay(nw, pool, nil)
+	for _, p := range peers {
+		nw.peers = append(nw.peers, p.Peer)
+		relay.OnAddPeer(p.Info)
+	}
+	return relay, nw
+}
+
+func hash(b byte) comm.Uint256 {
+	return comm.Uint256{b}
+}
+
+func TestAnnounceSkipKnownHashes(t *testing.T) {
+	a, b := newTestPeer(1), newTestPeer(2)
+	relay, nw := newTestRelay(fakePool{}, a, b)
+
+	// b announced hash 1 to us, so it is only announced to a
+	relay.OnTxInv(p2p.NewCcntmext(b.Peer, nw, 0), []comm.Uint256{hash(1)})
+	b.next(t) // request of hash 1 as the pool does not hold it
+	relay.pending = []comm.Uint256{hash(1), hash(2)}
+	relay.flush()
+
+	inv := a.next(t).(*mt.Inv)
+	assert.Equal(t, comm.TRANSACTION, inv.P.InvType)
+	assert.Equal(t, []comm.Uint256{hash(1), hash(2)}, inv.P.Blk)
+	inv = b.next(t).(*mt.Inv)
+	assert.Equal(t, []comm.Uint256{hash(2)}, inv.P.Blk)
+
+	// announced hashes are not announced again
+	relay.pending = []comm.Uint256{hash(2), hash(3)}
+	relay.flush()
+	assert.Equal(t, []comm.Uint256{hash(3)}, a.next(t).(*mt.Inv).P.Blk)
+	assert.Equal(t, []comm.Uint256{hash(3)}, b.next(t).(*mt.Inv).P.Blk)
+}
+
+func TestTxInvRequestMissing(t *testing.T) {
+	a, b := newTestPeer(1), newTestPeer(2)
+	relay, nw := newTestRelay(fakePool{hash(1): &types.Transaction{}}, a, b)
+
+	relay.OnTxInv(p2p.NewCcntmext(a.Peer, nw, 0), []comm.Uint256{hash(1), hash(2)})
+	req := a.next(t).(*mt.DataReq)
+	assert.Equal(t, comm.TRANSACTION, req.DataType)
+	assert.Equal(t, hash(2), req.Hash)
+
+	// hash 2 is already requested from a, only hash 3 is requested from b which is kept to retry hash 2
+	relay.OnTxInv(p2p.NewCcntmext(b.Peer, nw, 0), []comm.Uint256{hash(2), hash(3)})
+	assert.Equal(t, hash(3), b.next(t).(*mt.DataReq).Hash)
+	assert.Equal(t, []common.PeerId{b.GetID()}, relay.requested[hash(2)].announcers)
+
+	// received tx is not requested again
+	assert.True(t, relay.OnTxReceived(a.GetID(), hash(2)))
+	assert.False(t, relay.OnTxReceived(b.GetID(), hash(2)))
+	relay.OnTxInv(p2p.NewCcntmext(b.Peer, nw, 0), []comm.Uint256{hash(2), hash(4)})
+	assert.Equal(t, hash(4), b.next(t).(*mt.DataReq).Hash)
+}
+
+func TestTxRequestTimeout(t *testing.T) {
+	a, b := newTestPeer(1), newTestPeer(2)
+	relay, nw := newTestRelay(fakePool{}, a, b)
+
+	relay.OnTxInv(p2p.NewCcntmext(a.Peer, nw, 0), []comm.Uint256{hash(1)})
+	assert.Equal(t, hash(1), a.next(t).(*mt.DataReq).Hash)
+	relay.OnTxInv(p2p.NewCcntmext(b.Peer, nw, 0), []comm.Uint256{hash(1)})
+
+	// the request is moved to the next announcer after timeout
+	relay.requested[hash(1)].time = time.Now().Add(-common.TX_REQUEST_TIMEOUT * time.Second * 2)
+	relay.expireRequests()
+	assert.Equal(t, hash(1), b.next(t).(*mt.DataReq).Hash)
+	assert.Equal(t, b.GetID(), relay.requested[hash(1)].peer)
+
+	// the request is dropped once no announcer is left
+	relay.requested[hash(1)].time = time.Now().Add(-common.TX_REQUEST_TIMEOUT * time.Second * 2)
+	relay.expireRequests()
+	assert.Nil(t, relay.requested[hash(1)])
+	relay.OnTxInv(p2p.NewCcntmext(a.Peer, nw, 0), []comm.Uint256{hash(1)})
+	assert.Equal(t, hash(1), a.next(t).(*mt.DataReq).Hash)
+}
+
+func TestTxNotFound(t *testing.T) {
+	a, b, c := newTestPeer(1), newTestPeer(2), newTestPeer(3)
+	relay, nw := newTestRelay(fakePool{}, a, b)
+
+	relay.OnTxInv(p2p.NewCcntmext(a.Peer, nw, 0), []comm.Uint256{hash(1)})
+	assert.Equal(t, hash(1), a.next(t).(*mt.DataReq).Hash)
+	// c is disconnected before the retry
+	relay.OnTxInv(p2p.NewCcntmext(c.Peer, nw, 0), []comm.Uint256{hash(1)})
+	relay.OnTxInv(p2p.NewCcntmext(b.Peer, nw, 0), []comm.Uint256{hash(1)})
+
+	// not found from a peer not requested is ignored
+	relay.OnNotFound(p2p.NewCcntmext(b.Peer, nw, 0), hash(1))
+	assert.Equal(t, a.GetID(), relay.requested[hash(1)].peer)
+
+	relay.OnNotFound(p2p.NewCcntmext(a.Peer, nw, 0), hash(1))
+	assert.Equal(t, hash(1), b.next(t).(*mt.DataReq).Hash)
+	relay.OnNotFound(p2p.NewCcntmext(b.Peer, nw, 0), hash(1))
+	assert.Nil(t, relay.requested[hash(1)])
+}
+
+func TestTxRequestLimits(t *testing.T) {
+	a := newTestPeer(1)
+	relay, nw := newTestRelay(fakePool{}, a)
+
+	for i := 0; i < common.MAX_TX_REQUESTED; i++ {
+		relay.requested[comm.Uint256{0xff, byte(i), byte(i >> 8)}] = &txRequest{time: time.Now()}
+	}
+	relay.OnTxInv(p2p.NewCcntmext(a.Peer, nw, 0), []comm.Uint256{hash(1)})
+	assert.Equal(t, common.MAX_TX_REQUESTED, len(relay.requested))
+	assert.Nil(t, relay.requested[hash(1)])
+
+	// announcers are bounded
+	req := relay.requested[comm.Uint256{0xff}]
+	for i := 0; i < common.MAX_TX_ANNOUNCERS+2; i++ {
+		relay.addAnnouncer(comm.Uint256{0xff}, common.PseudoPeerIdFromUint64(uint64(i+10)))
+	}
+	assert.Equal(t, common.MAX_TX_ANNOUNCERS, len(req.announcers))
+}
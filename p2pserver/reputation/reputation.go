@@ -0,0 +1,400 @@
+/*
+ * Copyright (C) 2018 The cntmology Authors
+ * This file is part of The cntmology library.
+ *
+ * The cntmology is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The cntmology is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * alcntm with The cntmology.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+// Package reputation scores the behavior of remote peers and bans the peers which misbehave too often
+package reputation
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	common2 "github.com/cntmio/cntmology/common"
+	"github.com/cntmio/cntmology/common/log"
+	"github.com/cntmio/cntmology/p2pserver/common"
+)
+
+//Misbehavior is the kind of bad behavior of a remote peer
+type Misbehavior uint8
+
+const (
+	MALFORMED_MSG     Misbehavior = iota //message can not be decoded
+	INVALID_BLOCK                        //block can not be added to ledger
+	INVALID_HEADERS                      //headers can not be added to ledger
+	INVALID_CONSENSUS                    //consensus payload with invalid signature
+	UNSOLICITED_DATA                     //response which was not requested
+	REQUEST_FLOOD                        //duplicate request in a short time
+)
+
+var penalties = map[Misbehavior]float64{
+	MALFORMED_MSG:     50,
+	INVALID_BLOCK:     50,
+	INVALID_HEADERS:   25,
+	INVALID_CONSENSUS: 20,
+	UNSOLICITED_DATA:  5,
+	REQUEST_FLOOD:     2,
+}
+
+var descriptions = map[Misbehavior]string{
+	MALFORMED_MSG:     "malformed message",
+	INVALID_BLOCK:     "invalid block",
+	INVALID_HEADERS:   "invalid headers",
+	INVALID_CONSENSUS: "invalid consensus message",
+	UNSOLICITED_DATA:  "unsolicited data",
+	REQUEST_FLOOD:     "request flood",
+}
+
+func (self Misbehavior) String() string {
+	if desc, ok := descriptions[self]; ok {
+		return desc
+	}
+	return "unknown misbehavior"
+}
+
+//Penalty return the score deducted for the misbehavior
+func (self Misbehavior) Penalty() float64 {
+	return penalties[self]
+}
+
+const (
+	DISCONNECT_SCORE     = -50              //peer is disconnected when the score reaches it
+	BAN_SCORE            = -100             //peer is banned when the score reaches it
+	SCORE_HALF_LIFE      = 10 * time.Minute //the score decay to half in this duration
+	DEFAULT_BAN_DURATION = 24 * time.Hour
+	MAX_TRACKED_PEERS    = 4096 //the maximum number of scored peers
+)
+
+//Action is what the network should do with the peer after scoring
+type Action uint8
+
+const (
+	ACTION_NONE Action = iota
+	ACTION_DISCONNECT
+	ACTION_BAN
+)
+
+//PeerScore is the current score of a peer
+type PeerScore struct {
+	PeerId     string
+	Addr       string
+	Score      float64
+	LastReason string
+	UpdateTime int64
+}
+
+//BannedPeer is a peer refused until the unix time Until
+type BannedPeer struct {
+	PeerId string
+	Addr   string
+	Reason string
+	Until  int64
+}
+
+type peerScore struct {
+	addr       string
+	score      float64
+	lastReason string
+	updateTime time.Time
+}
+
+type ban struct {
+	addr   string
+	reason string
+	until  time.Time
+}
+
+//Reputation keeps the scores and bans of peers, the bans are saved to file if persisted
+type Reputation struct {
+	lock   sync.Mutex
+	scores map[common.PeerId]*peerScore
+	bans   map[common.PeerId]*ban
+	file   string
+	now    func() time.Time
+}
+
+func NewReputation() *Reputation {
+	return &Reputation{
+		scores: make(map[common.PeerId]*peerScore),
+		bans:   make(map[common.PeerId]*ban),
+		now:    time.Now,
+	}
+}
+
+//decayed return the score at time now, the score goes back to 0 exponentially
+func (self *peerScore) decayed(now time.Time) float64 {
+	elapsed := now.Sub(self.updateTime)
+	if elapsed <= 0 {
+		return self.score
+	}
+	return self.score * math.Exp2(-float64(elapsed)/float64(SCORE_HALF_LIFE))
+}
+
+//Misbehave deduct the penalty of misbehavior from the peer's score and return the action to take
+func (self *Reputation) Misbehave(id common.PeerId, addr string, m Misbehavior) Action {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	now := self.now()
+	if self.isBanned(id, now) {
+		return ACTION_BAN
+	}
+	ps := self.scores[id]
+	if ps == nil {
+		if len(self.scores) >= MAX_TRACKED_PEERS {
+			self.prune(now)
+		}
+		ps = &peerScore{updateTime: now}
+		self.scores[id] = ps
+	}
+	if addr != "" {
+		ps.addr = addr
+	}
+	ps.score = ps.decayed(now) - m.Penalty()
+	ps.lastReason = m.String()
+	ps.updateTime = now
+	log.Debugf("[p2p]peer %s misbehaved: %s, score: %.2f", id.ToHexString(), m, ps.score)
+
+	if ps.score <= BAN_SCORE {
+		self.ban(id, ps.addr, now.Add(DEFAULT_BAN_DURATION), ps.lastReason)
+		return ACTION_BAN
+	}
+	if ps.score <= DISCONNECT_SCORE {
+		return ACTION_DISCONNECT
+	}
+	return ACTION_NONE
+}
+
+//prune remove the scores which almost decayed to 0, or the highest ones if still full
+func (self *Reputation) prune(now time.Time) {
+	for id, ps := range self.scores {
+		if ps.decayed(now) > -1 {
+			delete(self.scores, id)
+		}
+	}
+	if len(self.scores) < MAX_TRACKED_PEERS {
+		return
+	}
+	var highest common.PeerId
+	score := math.Inf(-1)
+	for id, ps := range self.scores {
+		if s := ps.decayed(now); s > score {
+			highest, score = id, s
+		}
+	}
+	delete(self.scores, highest)
+}
+
+//Score return the current score of the peer, 0 if it never misbehaved
+func (self *Reputation) Score(id common.PeerId) float64 {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	ps := self.scores[id]
+	if ps == nil {
+		return 0
+	}
+	return ps.decayed(self.now())
+}
+
+//Ban refuse the peer for duration
+func (self *Reputation) Ban(id common.PeerId, addr string, duration time.Duration, reason string) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	self.ban(id, addr, self.now().Add(duration), reason)
+}
+
+func (self *Reputation) ban(id common.PeerId, addr string, until time.Time, reason string) {
+	if addr == "" {
+		if ps := self.scores[id]; ps != nil {
+			addr = ps.addr
+		}
+	}
+	// the score starts over when the ban expires
+	delete(self.scores, id)
+	self.bans[id] = &ban{addr: addr, reason: reason, until: until}
+	log.Warnf("[p2p]ban peer %s %s until %s, reason: %s", id.ToHexString(), addr, until.Format(time.RFC3339), reason)
+	self.save()
+}
+
+//Unban lift the ban of peer, return false if the peer is not banned
+func (self *Reputation) Unban(id common.PeerId) bool {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	if !self.isBanned(id, self.now()) {
+		return false
+	}
+	delete(self.bans, id)
+	delete(self.scores, id)
+	log.Infof("[p2p]unban peer %s", id.ToHexString())
+	self.save()
+	return true
+}
+
+//IsBanned check whether the peer is banned now
+func (self *Reputation) IsBanned(id common.PeerId) bool {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	return self.isBanned(id, self.now())
+}
+
+func (self *Reputation) isBanned(id common.PeerId, now time.Time) bool {
+	b := self.bans[id]
+	if b == nil {
+		return false
+	}
+	if now.Before(b.until) {
+		return true
+	}
+	delete(self.bans, id)
+	self.save()
+	return false
+}
+
+//Ccntmains implement peer filter, return true if the peer is banned
+func (self *Reputation) Ccntmains(id common.PeerId) bool {
+	return self.IsBanned(id)
+}
+
+//IsBannedAddr check whether a peer banned now was recorded with the ip of addr, addr format: ip:port
+func (self *Reputation) IsBannedAddr(addr string) bool {
+	ip, err := common.ParseIPAddr(addr)
+	if err != nil {
+		return false
+	}
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	now := self.now()
+	for _, b := range self.bans {
+		if !now.Before(b.until) || b.addr == "" {
+			ccntminue
+		}
+		if bannedIp, err := common.ParseIPAddr(b.addr); err == nil && bannedIp == ip {
+			return true
+		}
+	}
+	return false
+}
+
+//AddrFilter implement address filter with the ips of banned peers
+type AddrFilter struct {
+	rep *Reputation
+}
+
+//AddrFilter return the filter of the addresses whose ip is banned
+func (self *Reputation) AddrFilter() *AddrFilter {
+	return &AddrFilter{rep: self}
+}
+
+//Ccntmains return true if the ip of addr is banned
+func (self *AddrFilter) Ccntmains(addr string) bool {
+	return self.rep.IsBannedAddr(addr)
+}
+
+//GetScores return the scores of all tracked peers, the lowest first
+func (self *Reputation) GetScores() []PeerScore {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	now := self.now()
+	scores := make([]PeerScore, 0, len(self.scores))
+	for id, ps := range self.scores {
+		scores = append(scores, PeerScore{
+			PeerId:     id.ToHexString(),
+			Addr:       ps.addr,
+			Score:      ps.decayed(now),
+			LastReason: ps.lastReason,
+			UpdateTime: ps.updateTime.Unix(),
+		})
+	}
+	sort.Slice(scores, func(i, j int) bool {
+		return scores[i].Score < scores[j].Score
+	})
+	return scores
+}
+
+//GetBannedPeers return the peers banned now
+func (self *Reputation) GetBannedPeers() []BannedPeer {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	return self.bannedPeers(self.now())
+}
+
+func (self *Reputation) bannedPeers(now time.Time) []BannedPeer {
+	banned := make([]BannedPeer, 0, len(self.bans))
+	for id, b := range self.bans {
+		if !now.Before(b.until) {
+			ccntminue
+		}
+		banned = append(banned, BannedPeer{
+			PeerId: id.ToHexString(),
+			Addr:   b.addr,
+			Reason: b.reason,
+			Until:  b.until.Unix(),
+		})
+	}
+	sort.Slice(banned, func(i, j int) bool {
+		return banned[i].PeerId < banned[j].PeerId
+	})
+	return banned
+}
+
+//Persist load the bans saved in file and save the bans to it on every change
+func (self *Reputation) Persist(file string) error {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	self.file = file
+	if !common2.FileExisted(file) {
+		return nil
+	}
+	buf, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	var banned []BannedPeer
+	if err = json.Unmarshal(buf, &banned); err != nil {
+		return err
+	}
+	now := self.now()
+	for _, b := range banned {
+		id, err := common.PeerIdFromHexString(b.PeerId)
+		if err != nil {
+			log.Warnf("[p2p]invalid banned peer id %s in %s", b.PeerId, file)
+			ccntminue
+		}
+		until := time.Unix(b.Until, 0)
+		if now.Before(until) {
+			self.bans[id] = &ban{addr: b.Addr, reason: b.Reason, until: until}
+		}
+	}
+	return nil
+}
+
+func (self *Reputation) save() {
+	if self.file == "" {
+		return
+	}
+	buf, err := json.Marshal(self.bannedPeers(self.now()))
+	if err != nil {
+		log.Warn("[p2p]package banned peers fail: ", err)
+		return
+	}
+	if err = ioutil.WriteFile(self.file, buf, 0600); err != nil {
+		log.Warn("[p2p]write banned peers fail: ", err)
+	}
+}
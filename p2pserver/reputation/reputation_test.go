@@ -0,0 +1,147 @@
+/*
+ * Copyright (C) 2018 The cntmology Authors
+ * This file is part of The cntmology library.
+ *
+ * The cntmology is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The cntmology is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * alcntm with The cntmology.  If not, see <http://www.gnu.org/licenses/>.
+ */
+package reputation
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cntmio/cntmology/p2pserver/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func init() {
+	common.Difficulty = 1
+}
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (self *fakeClock) Now() time.Time {
+	return self.now
+}
+
+func newTestReputation() (*Reputation, *fakeClock) {
+	clock := &fakeClock{now: time.Unix(1600000000, 0)}
+	rep := NewReputation()
+	rep.now = clock.Now
+	return rep, clock
+}
+
+func TestMisbehaveThreshold(t *testing.T) {
+	rep, _ := newTestReputation()
+	id := common.RandPeerKeyId().Id
+
+	assert.Equal(t, ACTION_NONE, rep.Misbehave(id, "127.0.0.1:20338", INVALID_CONSENSUS))
+	assert.Equal(t, float64(-20), rep.Score(id))
+	assert.Equal(t, ACTION_DISCONNECT, rep.Misbehave(id, "", MALFORMED_MSG))
+	assert.False(t, rep.IsBanned(id))
+	assert.Equal(t, ACTION_BAN, rep.Misbehave(id, "", INVALID_BLOCK))
+	assert.True(t, rep.IsBanned(id))
+
+	banned := rep.GetBannedPeers()
+	assert.Equal(t, 1, len(banned))
+	assert.Equal(t, id.ToHexString(), banned[0].PeerId)
+	assert.Equal(t, "127.0.0.1:20338", banned[0].Addr)
+	assert.Equal(t, INVALID_BLOCK.String(), banned[0].Reason)
+	assert.Equal(t, 0, len(rep.GetScores()))
+}
+
+func TestScoreDecay(t *testing.T) {
+	rep, clock := newTestReputation()
+	id := common.RandPeerKeyId().Id
+
+	rep.Misbehave(id, "", INVALID_BLOCK)
+	clock.now = clock.now.Add(SCORE_HALF_LIFE)
+	assert.InDelta(t, -25, rep.Score(id), 0.001)
+
+	// decayed score is below the disconnect threshold again
+	assert.Equal(t, ACTION_NONE, rep.Misbehave(id, "", UNSOLICITED_DATA))
+	assert.InDelta(t, -30, rep.Score(id), 0.001)
+
+	scores := rep.GetScores()
+	assert.Equal(t, 1, len(scores))
+	assert.Equal(t, UNSOLICITED_DATA.String(), scores[0].LastReason)
+}
+
+func TestBanExpire(t *testing.T) {
+	rep, clock := newTestReputation()
+	id := common.RandPeerKeyId().Id
+
+	rep.Ban(id, "", time.Hour, "test")
+	assert.True(t, rep.Ccntmains(id))
+	clock.now = clock.now.Add(time.Hour)
+	assert.False(t, rep.Ccntmains(id))
+	assert.Equal(t, 0, len(rep.GetBannedPeers()))
+
+	rep.Ban(id, "", time.Hour, "test")
+	assert.True(t, rep.Unban(id))
+	assert.False(t, rep.IsBanned(id))
+	assert.False(t, rep.Unban(id))
+}
+
+func TestBannedAddr(t *testing.T) {
+	rep, clock := newTestReputation()
+	id := common.RandPeerKeyId().Id
+	filter := rep.AddrFilter()
+
+	rep.Ban(id, "10.0.0.1:20338", time.Hour, "test")
+	// a new peer id from the banned ip is refused whatever its port
+	assert.True(t, filter.Ccntmains("10.0.0.1:20338"))
+	assert.True(t, filter.Ccntmains("10.0.0.1:40000"))
+	assert.False(t, filter.Ccntmains("10.0.0.2:20338"))
+	assert.False(t, filter.Ccntmains("invalid"))
+
+	clock.now = clock.now.Add(time.Hour)
+	assert.False(t, filter.Ccntmains("10.0.0.1:20338"))
+
+	rep.Ban(common.RandPeerKeyId().Id, "", time.Hour, "test")
+	assert.False(t, filter.Ccntmains("10.0.0.1:20338"))
+}
+
+func TestPersist(t *testing.T) {
+	dir, err := ioutil.TempDir("", "reputation")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, common.BANNED_FILE_NAME)
+
+	rep, clock := newTestReputation()
+	assert.Nil(t, rep.Persist(file))
+	id1 := common.RandPeerKeyId().Id
+	id2 := common.RandPeerKeyId().Id
+	rep.Ban(id1, "127.0.0.1:20338", time.Hour, "test")
+	rep.Ban(id2, "", 2*time.Hour, "test")
+	info, err := os.Stat(file)
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	restarted, clock2 := newTestReputation()
+	clock2.now = clock.now.Add(90 * time.Minute)
+	assert.Nil(t, restarted.Persist(file))
+	assert.False(t, restarted.IsBanned(id1))
+	assert.True(t, restarted.IsBanned(id2))
+
+	assert.True(t, restarted.Unban(id2))
+	again, _ := newTestReputation()
+	assert.Nil(t, again.Persist(file))
+	assert.False(t, again.IsBanned(id2))
+}
@@ -84,8 +84,9 @@ func applyTransaction(msg types.Message, statedb *storage.StateDB, blockHeight u
 // ApplyTransaction attempts to apply a transaction to the given state database
 // and uses the input parameters for its environment. It returns the receipt
 // for the transaction, gas used and an error if the transaction failed,
-// indicating the block was invalid.
-func ApplyTransaction(config *params.ChainConfig, bc store.LedgerStore, statedb *storage.StateDB, blockHeight, timestamp uint32, tx *types.Transaction, usedGas *uint64, feeReceiver common.Address, cfg evm.Config, checkNonce bool) (*types2.ExecutionResult, *otypes.Receipt, error) {
+// indicating the block was invalid. The evm ccntmracts call the native ccntmracts through
+// invokeNative, the native gateway is disabled if it is nil.
+func ApplyTransaction(config *params.ChainConfig, bc store.LedgerStore, statedb *storage.StateDB, blockHeight, timestamp uint32, tx *types.Transaction, usedGas *uint64, feeReceiver common.Address, cfg evm.Config, checkNonce bool, invokeNative evm.InvokeNativeFunc) (*types2.ExecutionResult, *otypes.Receipt, error) {
 	signer := types.NewEIP155Signer(config.ChainID)
 	msg, err := tx.AsMessage(signer)
 	if err != nil {
@@ -96,6 +97,7 @@ func ApplyTransaction(config *params.ChainConfig, bc store.LedgerStore, statedb
 
 	// Create a new ccntmext to be used in the EVM environment
 	blockCcntmext := NewEVMBlockCcntmext(blockHeight, timestamp, bc)
+	blockCcntmext.InvokeNative = invokeNative
 	vmenv := evm.NewEVM(blockCcntmext, evm.TxCcntmext{}, statedb, config, cfg)
 	return applyTransaction(msg, statedb, blockHeight, tx, usedGas, vmenv, feeReceiver)
 }
@@ -0,0 +1,116 @@
+/*
+ * Copyright (C) 2018 The cntmology Authors
+ * This file is part of The cntmology library.
+ *
+ * The cntmology is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The cntmology is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * alcntm with The cntmology.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package evm
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/cntmio/cntmology/common"
+	"github.com/cntmio/cntmology/core/store/leveldbstore"
+	"github.com/cntmio/cntmology/core/store/overlaydb"
+	otypes "github.com/cntmio/cntmology/core/types"
+	"github.com/cntmio/cntmology/smartccntmract/service/native/utils"
+	"github.com/cntmio/cntmology/smartccntmract/storage"
+	"github.com/cntmio/cntmology/vm/evm"
+	"github.com/cntmio/cntmology/vm/evm/params"
+	common2 "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+type testBalanceHandle struct{}
+
+func (testBalanceHandle) SubBalance(cache *storage.CacheDB, addr common.Address, val *big.Int) error {
+	return nil
+}
+func (testBalanceHandle) AddBalance(cache *storage.CacheDB, addr common.Address, val *big.Int) error {
+	return nil
+}
+func (testBalanceHandle) SetBalance(cache *storage.CacheDB, addr common.Address, val *big.Int) error {
+	return nil
+}
+func (testBalanceHandle) GetBalance(cache *storage.CacheDB, addr common.Address) (*big.Int, error) {
+	return big.NewInt(1000000000), nil
+}
+
+// abiInvokeInput encodes invoke(string,bytes) with method and args shorter than a word
+func abiInvokeInput(method string, args []byte) []byte {
+	word := func(b []byte) []byte {
+		return common2.RightPadBytes(b, 32)
+	}
+	input := append([]byte{}, crypto.Keccak256([]byte("invoke(string,bytes)"))[:4]...)
+	input = append(input, common2.LeftPadBytes(big.NewInt(64).Bytes(), 32)...)
+	input = append(input, common2.LeftPadBytes(big.NewInt(128).Bytes(), 32)...)
+	input = append(input, common2.LeftPadBytes(big.NewInt(int64(len(method))).Bytes(), 32)...)
+	input = append(input, word([]byte(method))...)
+	input = append(input, common2.LeftPadBytes(big.NewInt(int64(len(args))).Bytes(), 32)...)
+	return append(input, word(args)...)
+}
+
+func applyGatewayTx(t *testing.T, input []byte, invokeNative evm.InvokeNativeFunc) (common2.Address, *otypes.Receipt) {
+	key, err := crypto.GenerateKey()
+	assert.Nil(t, err)
+	config := params.GetChainConfig(5851)
+	gateway := evm.NativeGatewayAddress(utils.ParamCcntmractAddress)
+	tx, err := types.SignTx(types.NewTransaction(0, gateway, big.NewInt(0), 100000, big.NewInt(1), input),
+		types.NewEIP155Signer(config.ChainID), key)
+	assert.Nil(t, err)
+
+	cache := storage.NewCacheDB(overlaydb.NewOverlayDB(leveldbstore.NewMemLevelDBStore()))
+	statedb := storage.NewStateDB(cache, tx.Hash(), common2.Hash{}, testBalanceHandle{})
+	usedGas := uint64(0)
+	_, receipt, err := ApplyTransaction(config, nil, statedb, 1, 1, tx, &usedGas, common.ADDRESS_EMPTY,
+		evm.Config{}, true, invokeNative)
+	assert.Nil(t, err)
+	return crypto.PubkeyToAddress(key.PublicKey), receipt
+}
+
+func TestApplyTransactionNativeGateway(t *testing.T) {
+	args := []byte{1, 2, 3}
+	input := abiInvokeInput("getGlobalParam", args)
+
+	// without invoker the gateway address is an ordinary account
+	_, plain := applyGatewayTx(t, input, nil)
+	assert.Equal(t, otypes.ReceiptStatusSuccessful, plain.Status)
+
+	var caller, ccntmract common2.Address
+	var method string
+	var received []byte
+	sender, receipt := applyGatewayTx(t, input, func(from, to common2.Address, m string, a []byte, gas uint64) ([]byte, uint64, error) {
+		caller, ccntmract, method, received = from, to, m, a
+		return utils.BYTE_TRUE, 7000, nil
+	})
+	assert.Equal(t, otypes.ReceiptStatusSuccessful, receipt.Status)
+	assert.Equal(t, sender, caller)
+	assert.Equal(t, common2.Address(utils.ParamCcntmractAddress), ccntmract)
+	assert.Equal(t, "getGlobalParam", method)
+	assert.True(t, bytes.Equal(args, received))
+	// the input words and the gas reported by the invoker are charged on top of the plain call
+	inputGas := uint64(len(input)+31) / 32 * params.NativeGatewayPerWordGas
+	assert.Equal(t, plain.GasUsed+inputGas+7000, receipt.GasUsed)
+
+	_, failed := applyGatewayTx(t, input, func(from, to common2.Address, m string, a []byte, gas uint64) ([]byte, uint64, error) {
+		return nil, 7000, errors.New("rejected")
+	})
+	assert.Equal(t, otypes.ReceiptStatusFailed, failed.Status)
+}
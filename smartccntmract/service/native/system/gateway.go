@@ -0,0 +1,118 @@
+/*
+ * Copyright (C) 2018 The cntmology Authors
+ * This file is part of The cntmology library.
+ *
+ * The cntmology is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The cntmology is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * alcntm with The cntmology.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package system
+
+import (
+	"fmt"
+
+	"github.com/cntmio/cntmology/common"
+	"github.com/cntmio/cntmology/common/config"
+	"github.com/cntmio/cntmology/smartccntmract/ccntmext"
+	"github.com/cntmio/cntmology/smartccntmract/service/native"
+	"github.com/cntmio/cntmology/smartccntmract/service/native/utils"
+	"github.com/cntmio/cntmology/smartccntmract/states"
+	"github.com/cntmio/cntmology/vm/evm"
+	"github.com/cntmio/cntmology/vm/evm/params"
+	common2 "github.com/ethereum/go-ethereum/common"
+)
+
+// gatewayCcntmextRef only takes the calling ccntmract as witness, so the evm ccntmracts
+// can not spend the assets of the transaction signers through the native gateway. The gas
+// is metered against the gas supplied by evm instead of the gas of the transaction.
+type gatewayCcntmextRef struct {
+	ccntmext.CcntmextRef
+	gas     uint64
+	gasUsed uint64
+}
+
+func (self *gatewayCcntmextRef) CheckWitness(address common.Address) bool {
+	calling := self.CallingCcntmext()
+	return calling != nil && calling.CcntmractAddress == address
+}
+
+func (self *gatewayCcntmextRef) CheckUseGas(gas uint64) bool {
+	if self.gas-self.gasUsed < gas {
+		self.gasUsed = self.gas
+		return false
+	}
+	self.gasUsed += gas
+	return true
+}
+
+func (self *gatewayCcntmextRef) GetGasInfo() (gasLeft uint64, gasPrice uint64) {
+	_, gasPrice = self.CcntmextRef.GetGasInfo()
+	return self.gas - self.gasUsed, gasPrice
+}
+
+// NewNativeInvoker returns the function through which the evm native gateway invokes
+// the native ccntmracts, the evm caller is pushed as the calling ccntmext. It returns nil
+// before the native gateway height, which disables the gateway ccntmracts.
+//
+// The invocation is charged params.NativeInvokeGas, the gas metered by the native ccntmract
+// and params.NativeStorePerKBGas for each kilobyte it writes.
+func NewNativeInvoker(service *native.NativeService) evm.InvokeNativeFunc {
+	if service.Height < config.GetNativeGatewayHeight(config.DefConfig.P2PNode.NetworkId) {
+		return nil
+	}
+	return func(caller common2.Address, ccntmract common2.Address, method string, args []byte, gas uint64) ([]byte, uint64, error) {
+		address := common.Address(ccntmract)
+		if !utils.IsNativeCcntmract(address) {
+			return nil, 0, fmt.Errorf("native gateway: %s is not a native ccntmract", address.ToHexString())
+		}
+
+		ref := &gatewayCcntmextRef{CcntmextRef: service.CcntmextRef, gas: gas}
+		if !ref.CheckUseGas(params.NativeInvokeGas) {
+			return nil, ref.gasUsed, fmt.Errorf("native gateway: invoke %s.%s out of gas", address.ToHexString(), method)
+		}
+		ref.PushCcntmext(&ccntmext.Ccntmext{CcntmractAddress: common.Address(caller)})
+		defer ref.PopCcntmext()
+
+		invoker := &native.NativeService{
+			CacheDB: service.CacheDB,
+			InvokeParam: states.CcntmractInvokeParam{
+				Version: 0,
+				Address: address,
+				Method:  method,
+				Args:    args,
+			},
+			Tx:          service.Tx,
+			Height:      service.Height,
+			Time:        service.Time,
+			BlockHash:   service.BlockHash,
+			CcntmextRef: ref,
+			ServiceMap:  make(map[string]native.Handler),
+			PreExec:     service.PreExec,
+		}
+		written := service.CacheDB.WrittenBytes()
+		res, err := invoker.Invoke()
+		if err != nil {
+			return nil, ref.gasUsed, fmt.Errorf("native gateway: invoke %s.%s error: %v", address.ToHexString(), method, err)
+		}
+		if size := service.CacheDB.WrittenBytes() - written; size > 0 {
+			if !ref.CheckUseGas((size + 1023) / 1024 * params.NativeStorePerKBGas) {
+				return nil, ref.gasUsed, fmt.Errorf("native gateway: invoke %s.%s out of gas", address.ToHexString(), method)
+			}
+		}
+		ret, ok := res.([]byte)
+		if !ok {
+			return nil, ref.gasUsed, fmt.Errorf("native gateway: invoke %s.%s returns unexpected result", address.ToHexString(), method)
+		}
+		return ret, ref.gasUsed, nil
+	}
+}
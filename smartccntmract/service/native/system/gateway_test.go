@@ -0,0 +1,49 @@
+/*
+ * Copyright (C) 2018 The cntmology Authors
+ * This file is part of The cntmology library.
+ *
+ * The cntmology is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The cntmology is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * alcntm with The cntmology.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package system
+
+import (
+	"testing"
+
+	"github.com/cntmio/cntmology/common/config"
+	"github.com/cntmio/cntmology/smartccntmract/service/native"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNativeInvokerHeight(t *testing.T) {
+	networkId := config.DefConfig.P2PNode.NetworkId
+	defer func() { config.DefConfig.P2PNode.NetworkId = networkId }()
+
+	service := &native.NativeService{Height: 100}
+	config.DefConfig.P2PNode.NetworkId = config.NETWORK_ID_MAIN_NET
+	assert.Nil(t, NewNativeInvoker(service), "native gateway is not scheduled on main net")
+	config.DefConfig.P2PNode.NetworkId = 1000
+	assert.Nil(t, NewNativeInvoker(service), "native gateway is disabled on custom networks")
+	config.DefConfig.P2PNode.NetworkId = config.NETWORK_ID_SOLO_NET
+	assert.NotNil(t, NewNativeInvoker(service))
+}
+
+func TestGatewayCcntmextRefGas(t *testing.T) {
+	ref := &gatewayCcntmextRef{gas: 1000}
+	assert.True(t, ref.CheckUseGas(600))
+	assert.False(t, ref.CheckUseGas(600))
+	// running out of gas consumes all the supplied gas
+	assert.Equal(t, uint64(1000), ref.gasUsed)
+	assert.False(t, ref.CheckUseGas(1))
+}
@@ -70,6 +70,7 @@ func EVMInvoke(native *native.NativeService) ([]byte, error) {
 
 	// Create a new ccntmext to be used in the EVM environment
 	blockCcntmext := evm2.NewEVMBlockCcntmext(native.Height, native.Time, native.Store)
+	blockCcntmext.InvokeNative = NewNativeInvoker(native)
 	gasLeft, gasPrice := native.CcntmextRef.GetGasInfo()
 	txctx := evm.TxCcntmext{
 		Origin:   common2.Address(native.Tx.Payer),
@@ -39,6 +39,8 @@ var (
 	STORAGE_GET_GAS               uint64 = 200
 	STORAGE_PUT_GAS               uint64 = 4000
 	STORAGE_DELETE_GAS            uint64 = 100
+	STORAGE_FIND_GAS              uint64 = 200
+	ITERATOR_NEXT_GAS             uint64 = 200
 	RUNTIME_CHECKWITNESS_GAS      uint64 = 200
 	RUNTIME_VERIFYMUTISIG_GAS     uint64 = 400
 	RUNTIME_ADDRESSTOBASE58_GAS   uint64 = 40
@@ -55,6 +57,8 @@ var (
 	METHOD_LENGTH_LIMIT  = 1024
 	DUPLICATE_STACK_SIZE = 1024 * 2
 	VM_STEP_LIMIT        = 400000
+	//max storage iterators opened by one invocation
+	MAX_STORAGE_ITERATORS = 1024
 
 	// API Name
 	ATTRIBUTE_GETUSAGE_NAME = "Ontology.Attribute.GetUsage"
@@ -97,6 +101,11 @@ var (
 
 	STORAGECcntmEXT_ASREADONLY_NAME = "System.StorageCcntmext.AsReadOnly"
 
+	STORAGE_FIND_NAME   = "System.Storage.Find"
+	ITERATOR_NEXT_NAME  = "System.Iterator.Next"
+	ITERATOR_KEY_NAME   = "System.Iterator.Key"
+	ITERATOR_VALUE_NAME = "System.Iterator.Value"
+
 	RUNTIME_GETTIME_NAME             = "System.Runtime.GetTime"
 	RUNTIME_CHECKWITNESS_NAME        = "System.Runtime.CheckWitness"
 	RUNTIME_NOTIFY_NAME              = "System.Runtime.Notify"
@@ -203,6 +212,9 @@ func initGAS_TABLE() *sync.Map {
 	m.Store(RUNTIME_VERIFYMUTISIG_NAME, RUNTIME_VERIFYMUTISIG_GAS)
 	m.Store(WASM_INVOKE_NAME, APPCALL_GAS)
 
+	m.Store(STORAGE_FIND_NAME, STORAGE_FIND_GAS)
+	m.Store(ITERATOR_NEXT_NAME, ITERATOR_NEXT_GAS)
+
 	m.Store(config.WASM_GAS_FACTOR, config.DEFAULT_WASM_GAS_FACTOR)
 
 	return &m
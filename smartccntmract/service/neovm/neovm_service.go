@@ -66,6 +66,14 @@ var (
 		BLOCKCHAIN_GETHEADER_NAME: BlockChainGetHeaderNew,
 	}
 
+	// Storage iterator services enabled from the storage iterator height
+	ServiceMapStorageIterator = map[string]ServiceHandler{
+		STORAGE_FIND_NAME:   StorageFind,
+		ITERATOR_NEXT_NAME:  IteratorNext,
+		ITERATOR_KEY_NAME:   IteratorKey,
+		ITERATOR_VALUE_NAME: IteratorValue,
+	}
+
 	// Register all service for smart ccntmract execute
 	ServiceMap = map[string]ServiceHandler{
 		BLOCKCHAIN_GETCcntmRACT_NAME: BlockChainGetCcntmract,
@@ -135,6 +143,7 @@ type NeoVmService struct {
 	BlockHash     scommon.Uint256
 	Engine        *vm.Executor
 	PreExec       bool
+	iterators     []*StorageIterator
 }
 
 // Invoke a smart ccntmract
@@ -143,6 +152,7 @@ func (this *NeoVmService) Invoke() (interface{}, error) {
 		return nil, ERR_EXECUTE_CODE
 	}
 	this.CcntmextRef.PushCcntmext(&ccntmext.Ccntmext{CcntmractAddress: scommon.AddressFromVmCode(this.Code), Code: this.Code})
+	defer this.releaseIterators()
 	var gasTable [256]uint64
 	for {
 		//check the execution step count
@@ -263,6 +273,9 @@ func (this *NeoVmService) SystemCall(engine *vm.Executor) error {
 			serviceHandler, ok = ServiceMapNew[serviceName]
 		}
 	}
+	if !ok && this.Height >= config.GetStorageIteratorHeight(config.DefConfig.P2PNode.NetworkId) {
+		serviceHandler, ok = ServiceMapStorageIterator[serviceName]
+	}
 
 	if !ok {
 		return errors.NewErr(fmt.Sprintf("[SystemCall] the given service is not supported: %s", serviceName))
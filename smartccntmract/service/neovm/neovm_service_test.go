@@ -23,8 +23,8 @@ import "testing"
 // testcase enforce keys in ServiceMap and ServiceMapDeprecated
 func TestNeoVmServiceMap(t *testing.T) {
 	for k := range ServiceMap {
-		if ServiceMapDeprecated[k] != nil || ServiceMapNew[k] != nil {
-			panic("key in ServiceMap also in ServiceMapDeprecated or ServiceMapNew")
+		if ServiceMapDeprecated[k] != nil || ServiceMapNew[k] != nil || ServiceMapStorageIterator[k] != nil {
+			panic("key in ServiceMap also in ServiceMapDeprecated, ServiceMapNew or ServiceMapStorageIterator")
 		}
 	}
 }
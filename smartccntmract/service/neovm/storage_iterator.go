@@ -0,0 +1,118 @@
+/*
+ * Copyright (C) 2018 The cntmology Authors
+ * This file is part of The cntmology library.
+ *
+ * The cntmology is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The cntmology is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * alcntm with The cntmology.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package neovm
+
+import (
+	"fmt"
+
+	"github.com/cntmio/cntmology/errors"
+	"github.com/cntmio/cntmology/smartccntmract/storage"
+	vm "github.com/cntmio/cntmology/vm/neovm"
+)
+
+// StorageIterator wrap a ccntmract storage prefix iterator as vm interop value
+type StorageIterator struct {
+	*storage.StorageIterator
+}
+
+// ToArray return ccntmract address and key prefix of the iterator
+func (this *StorageIterator) ToArray() []byte {
+	return this.Prefix()
+}
+
+// StorageFind push an iterator over the storage items with the given key prefix to vm stack
+func StorageFind(service *NeoVmService, engine *vm.Executor) error {
+	ccntmext, err := getCcntmext(engine)
+	if err != nil {
+		return errors.NewDetailErr(err, errors.ErrNoCode, "[StorageFind] get pop ccntmext error!")
+	}
+	prefix, err := engine.EvalStack.PopAsBytes()
+	if err != nil {
+		return err
+	}
+	if len(prefix) > 1024 {
+		return errors.NewErr("[StorageFind] Storage key prefix to lcntm")
+	}
+	if len(service.iterators) >= MAX_STORAGE_ITERATORS {
+		return fmt.Errorf("[StorageFind] too many storage iterators, limit %d", MAX_STORAGE_ITERATORS)
+	}
+
+	iter := &StorageIterator{service.CacheDB.NewStorageIterator(ccntmext.Address, prefix)}
+	service.iterators = append(service.iterators, iter)
+	return engine.EvalStack.PushAsInteropValue(iter)
+}
+
+// IteratorNext move the iterator to next item and push whether it exists to vm stack
+func IteratorNext(service *NeoVmService, engine *vm.Executor) error {
+	iter, err := getStorageIterator(engine)
+	if err != nil {
+		return errors.NewDetailErr(err, errors.ErrNoCode, "[IteratorNext] get pop iterator error!")
+	}
+	has, err := iter.Next()
+	if err != nil {
+		return err
+	}
+	return engine.EvalStack.PushBool(has)
+}
+
+// IteratorKey push the storage key of current item to vm stack
+func IteratorKey(service *NeoVmService, engine *vm.Executor) error {
+	iter, err := getStorageIterator(engine)
+	if err != nil {
+		return errors.NewDetailErr(err, errors.ErrNoCode, "[IteratorKey] get pop iterator error!")
+	}
+	if !iter.Valid() {
+		return errors.NewErr("[IteratorKey] iterator has no current item")
+	}
+	return engine.EvalStack.PushBytes(iter.Key())
+}
+
+// IteratorValue push the storage value of current item to vm stack
+func IteratorValue(service *NeoVmService, engine *vm.Executor) error {
+	iter, err := getStorageIterator(engine)
+	if err != nil {
+		return errors.NewDetailErr(err, errors.ErrNoCode, "[IteratorValue] get pop iterator error!")
+	}
+	if !iter.Valid() {
+		return errors.NewErr("[IteratorValue] iterator has no current item")
+	}
+	return engine.EvalStack.PushBytes(iter.Value())
+}
+
+func getStorageIterator(engine *vm.Executor) (*StorageIterator, error) {
+	opInterface, err := engine.EvalStack.PopAsInteropValue()
+	if err != nil {
+		return nil, err
+	}
+	if opInterface.Data == nil {
+		return nil, errors.NewErr("[Iterator] Get storage iterator nil")
+	}
+	iter, ok := opInterface.Data.(*StorageIterator)
+	if !ok {
+		return nil, errors.NewErr("[Iterator] Get storage iterator invalid")
+	}
+	return iter, nil
+}
+
+func (this *NeoVmService) releaseIterators() {
+	for _, iter := range this.iterators {
+		iter.Release()
+	}
+	this.iterators = nil
+}
@@ -0,0 +1,143 @@
+/*
+ * Copyright (C) 2018 The cntmology Authors
+ * This file is part of The cntmology library.
+ *
+ * The cntmology is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The cntmology is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * alcntm with The cntmology.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package neovm
+
+import (
+	"testing"
+
+	"github.com/cntmio/cntmology/common"
+	"github.com/cntmio/cntmology/common/config"
+	"github.com/cntmio/cntmology/core/states"
+	"github.com/cntmio/cntmology/core/store/leveldbstore"
+	"github.com/cntmio/cntmology/core/store/overlaydb"
+	"github.com/cntmio/cntmology/smartccntmract/ccntmext"
+	"github.com/cntmio/cntmology/smartccntmract/storage"
+	vm "github.com/cntmio/cntmology/vm/neovm"
+	"github.com/stretchr/testify/assert"
+)
+
+type testCcntmextRef struct {
+	ccntmext.CcntmextRef
+}
+
+func (self *testCcntmextRef) CheckUseGas(gas uint64) bool {
+	return true
+}
+
+func newIteratorTestService(addr common.Address) *NeoVmService {
+	cache := storage.NewCacheDB(overlaydb.NewOverlayDB(leveldbstore.NewMemLevelDBStore()))
+	for key, value := range map[string]string{"user_a": "1", "user_b": "2", "total": "3"} {
+		cache.Put(genStorageKey(addr, []byte(key)), states.GenRawStorageItem([]byte(value)))
+	}
+	cache.Put(genStorageKey(common.Address{2}, []byte("user_c")), states.GenRawStorageItem([]byte("4")))
+	return &NeoVmService{CacheDB: cache, CcntmextRef: &testCcntmextRef{}}
+}
+
+func storageFind(service *NeoVmService, addr common.Address, prefix []byte) (*StorageIterator, error) {
+	engine := vm.NewExecutor(nil, vm.VmFeatureFlag{})
+	engine.EvalStack.PushBytes(prefix)
+	engine.EvalStack.PushAsInteropValue(NewStorageCcntmext(addr))
+	if err := StorageFind(service, engine); err != nil {
+		return nil, err
+	}
+	return getStorageIterator(engine)
+}
+
+func callIterator(t *testing.T, service *NeoVmService, handler ServiceHandler, iter *StorageIterator) (*vm.Executor, error) {
+	engine := vm.NewExecutor(nil, vm.VmFeatureFlag{})
+	assert.Nil(t, engine.EvalStack.PushAsInteropValue(iter))
+	return engine, handler(service, engine)
+}
+
+func TestStorageFind(t *testing.T) {
+	addr := common.Address{1}
+	service := newIteratorTestService(addr)
+	iter, err := storageFind(service, addr, []byte("user_"))
+	assert.Nil(t, err)
+
+	// key and value are not readable before the first move
+	_, err = callIterator(t, service, IteratorKey, iter)
+	assert.NotNil(t, err)
+
+	var keys, values []string
+	for {
+		engine, err := callIterator(t, service, IteratorNext, iter)
+		assert.Nil(t, err)
+		has, err := engine.EvalStack.PopAsBool()
+		assert.Nil(t, err)
+		if !has {
+			break
+		}
+		engine, err = callIterator(t, service, IteratorKey, iter)
+		assert.Nil(t, err)
+		key, err := engine.EvalStack.PopAsBytes()
+		assert.Nil(t, err)
+		engine, err = callIterator(t, service, IteratorValue, iter)
+		assert.Nil(t, err)
+		value, err := engine.EvalStack.PopAsBytes()
+		assert.Nil(t, err)
+		keys = append(keys, string(key))
+		values = append(values, string(value))
+	}
+	assert.Equal(t, []string{"user_a", "user_b"}, keys)
+	assert.Equal(t, []string{"1", "2"}, values)
+
+	// the iterators are released with the invocation
+	service.releaseIterators()
+	_, err = callIterator(t, service, IteratorNext, iter)
+	assert.NotNil(t, err)
+}
+
+func TestStorageFindLimits(t *testing.T) {
+	addr := common.Address{1}
+	service := newIteratorTestService(addr)
+	_, err := storageFind(service, addr, make([]byte, 1025))
+	assert.NotNil(t, err)
+
+	for i := 0; i < MAX_STORAGE_ITERATORS; i++ {
+		_, err = storageFind(service, addr, nil)
+		assert.Nil(t, err)
+	}
+	_, err = storageFind(service, addr, nil)
+	assert.NotNil(t, err)
+	service.releaseIterators()
+}
+
+func TestStorageFindHeight(t *testing.T) {
+	addr := common.Address{1}
+	service := newIteratorTestService(addr)
+	systemCall := func() error {
+		sink := common.NewZeroCopySink(nil)
+		sink.WriteVarBytes([]byte(STORAGE_FIND_NAME))
+		engine := vm.NewExecutor(sink.Bytes(), vm.VmFeatureFlag{})
+		engine.EvalStack.PushBytes([]byte("user_"))
+		engine.EvalStack.PushAsInteropValue(NewStorageCcntmext(addr))
+		return service.SystemCall(engine)
+	}
+
+	networkId := config.DefConfig.P2PNode.NetworkId
+	defer func() {
+		config.DefConfig.P2PNode.NetworkId = networkId
+	}()
+	config.DefConfig.P2PNode.NetworkId = config.NETWORK_ID_MAIN_NET
+	assert.NotNil(t, systemCall())
+	config.DefConfig.P2PNode.NetworkId = config.NETWORK_ID_SOLO_NET
+	assert.Nil(t, systemCall())
+	service.releaseIterators()
+}
@@ -123,10 +123,13 @@ func CcntmractCreate(proc *exec.Process,
 	if err != nil {
 		panic(err)
 	}
-	_, err = ReadWasmModule(wasmCode, config.DefConfig.Common.WasmVerifyMethod)
+	compiled, err := ReadWasmModule(wasmCode, config.DefConfig.Common.WasmVerifyMethod)
 	if err != nil {
 		panic(err)
 	}
+	if err := CheckHostImports(compiled.RawModule, self.Service.Height); err != nil {
+		panic(err)
+	}
 
 	ccntmractAddr := dep.Address()
 	if self.isCcntmractExist(ccntmractAddr) {
@@ -203,10 +206,13 @@ func CcntmractMigrate(proc *exec.Process,
 	if err != nil {
 		panic(err)
 	}
-	_, err = ReadWasmModule(wasmCode, config.DefConfig.Common.WasmVerifyMethod)
+	compiled, err := ReadWasmModule(wasmCode, config.DefConfig.Common.WasmVerifyMethod)
 	if err != nil {
 		panic(err)
 	}
+	if err := CheckHostImports(compiled.RawModule, self.Service.Height); err != nil {
+		panic(err)
+	}
 
 	ccntmractAddr := dep.Address()
 	if self.isCcntmractExist(ccntmractAddr) {
@@ -35,8 +35,24 @@ var (
 	STORAGE_GET_GAS          uint64 = 200
 	STORAGE_PUT_GAS          uint64 = 4000
 	STORAGE_DELETE_GAS       uint64 = 100
+	STORAGE_ITER_NEW_GAS     uint64 = 200
+	STORAGE_ITER_NEXT_GAS    uint64 = 200
+	STORAGE_ITER_READ_GAS    uint64 = 10
 	UINT_DEPLOY_CODE_LEN_GAS uint64 = 200000
 	PER_UNIT_CODE_LEN        uint64 = 1024
 
-	SHA256_GAS uint64 = 10
+	SHA256_GAS    uint64 = 10
+	KECCAK256_GAS uint64 = 10
+	RIPEMD160_GAS uint64 = 20
+	BLAKE2B_GAS   uint64 = 10
+
+	ECRECOVER_GAS               uint64 = 600
+	VERIFY_SIGNATURE_GAS        uint64 = 400
+	BN256_PAIRING_BASE_GAS      uint64 = 9000
+	BN256_PAIRING_PER_POINT_GAS uint64 = 6800
+
+	//max storage iterators opened by one invocation
+	MAX_STORAGE_ITERATORS = 1024
+	//max key prefix length of storage iterator
+	MAX_STORAGE_PREFIX_LEN uint32 = 1024
 )
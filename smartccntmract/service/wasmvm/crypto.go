@@ -0,0 +1,181 @@
+/*
+ * Copyright (C) 2018 The cntmology Authors
+ * This file is part of The cntmology library.
+ *
+ * The cntmology is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The cntmology is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * alcntm with The cntmology.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package wasmvm
+
+import (
+	"github.com/cntmio/cntmology-crypto/keypair"
+	"github.com/cntmio/cntmology/core/signature"
+	"github.com/cntmio/cntmology/vm/evm"
+	"github.com/cntmio/wagon/exec"
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/crypto/blake2b"
+)
+
+// the same implementations as the evm precompiled ccntmracts
+var (
+	ecrecoverCcntmract    = evm.PrecompiledCcntmractsIstanbul[ethcommon.BytesToAddress([]byte{1})]
+	ripemd160Ccntmract    = evm.PrecompiledCcntmractsIstanbul[ethcommon.BytesToAddress([]byte{3})]
+	bn256PairingCcntmract = evm.PrecompiledCcntmractsIstanbul[ethcommon.BytesToAddress([]byte{8})]
+)
+
+func Keccak256(proc *exec.Process, src uint32, slen uint32, dst uint32) {
+	self := proc.HostData().(*Runtime)
+	cost := uint64((slen/1024)+1) * KECCAK256_GAS
+	self.checkGas(cost)
+
+	bs, err := ReadWasmMemory(proc, src, slen)
+	if err != nil {
+		panic(err)
+	}
+
+	_, err = proc.WriteAt(crypto.Keccak256(bs), int64(dst))
+	if err != nil {
+		panic(err)
+	}
+}
+
+func Ripemd160(proc *exec.Process, src uint32, slen uint32, dst uint32) {
+	self := proc.HostData().(*Runtime)
+	cost := uint64((slen/1024)+1) * RIPEMD160_GAS
+	self.checkGas(cost)
+
+	bs, err := ReadWasmMemory(proc, src, slen)
+	if err != nil {
+		panic(err)
+	}
+
+	hash, err := ripemd160Ccntmract.Run(bs)
+	if err != nil {
+		panic(err)
+	}
+	// the precompiled ccntmract left pad the 20 bytes hash to 32 bytes
+	_, err = proc.WriteAt(hash[12:], int64(dst))
+	if err != nil {
+		panic(err)
+	}
+}
+
+func Blake2b256(proc *exec.Process, src uint32, slen uint32, dst uint32) {
+	self := proc.HostData().(*Runtime)
+	cost := uint64((slen/1024)+1) * BLAKE2B_GAS
+	self.checkGas(cost)
+
+	bs, err := ReadWasmMemory(proc, src, slen)
+	if err != nil {
+		panic(err)
+	}
+
+	hash := blake2b.Sum256(bs)
+	_, err = proc.WriteAt(hash[:], int64(dst))
+	if err != nil {
+		panic(err)
+	}
+}
+
+// Ecrecover recover the ethereum address from a 32 bytes hash and a 65 bytes secp256k1 signature r|s|v,
+// v can be 0, 1, 27 or 28. It write the 20 bytes address to dst and return 1 on success, otherwise return 0
+func Ecrecover(proc *exec.Process, hashPtr uint32, sigPtr uint32, dst uint32) uint32 {
+	self := proc.HostData().(*Runtime)
+	self.checkGas(ECRECOVER_GAS)
+
+	hash, err := ReadWasmMemory(proc, hashPtr, 32)
+	if err != nil {
+		panic(err)
+	}
+	sig, err := ReadWasmMemory(proc, sigPtr, 65)
+	if err != nil {
+		panic(err)
+	}
+
+	v := sig[64]
+	if v < 27 {
+		v += 27
+	}
+	// input of the precompiled ccntmract is hash|v|r|s, each 32 bytes
+	input := make([]byte, 128)
+	copy(input, hash)
+	input[63] = v
+	copy(input[64:], sig[:64])
+
+	addr, err := ecrecoverCcntmract.Run(input)
+	if err != nil {
+		panic(err)
+	}
+	if len(addr) == 0 {
+		return 0
+	}
+	_, err = proc.WriteAt(addr[12:], int64(dst))
+	if err != nil {
+		panic(err)
+	}
+	return 1
+}
+
+// VerifySignature check the signature of data with a serialized public key, which can be
+// ECDSA, SM2 or Ed25519 key. It return 1 if the signature is valid, otherwise return 0
+func VerifySignature(proc *exec.Process, keyPtr uint32, keyLen uint32, dataPtr uint32, dataLen uint32, sigPtr uint32, sigLen uint32) uint32 {
+	self := proc.HostData().(*Runtime)
+	cost := VERIFY_SIGNATURE_GAS + uint64(dataLen/1024)*SHA256_GAS
+	self.checkGas(cost)
+
+	key, err := ReadWasmMemory(proc, keyPtr, keyLen)
+	if err != nil {
+		panic(err)
+	}
+	data, err := ReadWasmMemory(proc, dataPtr, dataLen)
+	if err != nil {
+		panic(err)
+	}
+	sig, err := ReadWasmMemory(proc, sigPtr, sigLen)
+	if err != nil {
+		panic(err)
+	}
+
+	pubKey, err := keypair.DeserializePublicKey(key)
+	if err != nil {
+		return 0
+	}
+	if signature.Verify(pubKey, data, sig) != nil {
+		return 0
+	}
+	return 1
+}
+
+// Bn256Pairing run the bn256 pairing check on the input of k*192 bytes (G1|G2 point pairs).
+// It return 1 if the check succeeds, otherwise return 0
+func Bn256Pairing(proc *exec.Process, src uint32, slen uint32) uint32 {
+	self := proc.HostData().(*Runtime)
+	cost := BN256_PAIRING_BASE_GAS + uint64(slen/192)*BN256_PAIRING_PER_POINT_GAS
+	self.checkGas(cost)
+
+	input, err := ReadWasmMemory(proc, src, slen)
+	if err != nil {
+		panic(err)
+	}
+
+	res, err := bn256PairingCcntmract.Run(input)
+	if err != nil {
+		panic(err)
+	}
+	if res[len(res)-1] == 1 {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,159 @@
+/*
+ * Copyright (C) 2018 The cntmology Authors
+ * This file is part of The cntmology library.
+ *
+ * The cntmology is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The cntmology is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * alcntm with The cntmology.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package wasmvm
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/cntmio/cntmology-crypto/keypair"
+	s "github.com/cntmio/cntmology-crypto/signature"
+	"github.com/cntmio/cntmology/common"
+	"github.com/cntmio/cntmology/common/config"
+	"github.com/cntmio/cntmology/core/store/leveldbstore"
+	"github.com/cntmio/cntmology/core/store/overlaydb"
+	"github.com/cntmio/cntmology/smartccntmract/storage"
+	"github.com/cntmio/wagon/exec"
+	"github.com/cntmio/wagon/wasm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+// bn256 pairing vectors jeff1 and jeff6 of the evm precompiled ccntmract tests
+const (
+	bn256PairingTrue  = "1c76476f4def4bb94541d57ebba1193381ffa7aa76ada664dd31c16024c43f593034dd2920f673e204fee2811c678745fc819b55d3e9d294e45c9b03a76aef41209dd15ebff5d46c4bd888e51a93cf99a7329636c63514396b4a452003a35bf704bf11ca01483bfa8b34b43561848d28905960114c8ac04049af4b6315a416782bb8324af6cfc93537a2ad1a445cfd0ca2a71acd7ac41fadbf933c2a51be344d120a2a4cf30c1bf9845f20c6fe39e07ea2cce61f0c9bb048165fe5e4de877550111e129f1cf1097710d41c4ac70fcdfa5ba2023c6ff1cbeac322de49d1b6df7c2032c61a830e3c17286de9462bf242fca2883585b93870a73853face6a6bf411198e9393920d483a7260bfb731fb5d25f1aa493335a9e71297e485b7aef312c21800deef121f1e76426a00665e5c4479674322d4f75edadd46debd5cd992f6ed090689d0585ff075ec9e99ad690c3395bc4b313370b38ef355acdadcd122975b12c85ea5db8c6deb4aab71808dcb408fe3d1e7690c43d37b4ce6cc0166fa7daa"
+	bn256PairingFalse = "1c76476f4def4bb94541d57ebba1193381ffa7aa76ada664dd31c16024c43f593034dd2920f673e204fee2811c678745fc819b55d3e9d294e45c9b03a76aef41209dd15ebff5d46c4bd888e51a93cf99a7329636c63514396b4a452003a35bf704bf11ca01483bfa8b34b43561848d28905960114c8ac04049af4b6315a416782bb8324af6cfc93537a2ad1a445cfd0ca2a71acd7ac41fadbf933c2a51be344d120a2a4cf30c1bf9845f20c6fe39e07ea2cce61f0c9bb048165fe5e4de877550111e129f1cf1097710d41c4ac70fcdfa5ba2023c6ff1cbeac322de49d1b6df7c103188585e2364128fe25c70558f1560f4f9350baf3959e603cc91486e110936198e9393920d483a7260bfb731fb5d25f1aa493335a9e71297e485b7aef312c21800deef121f1e76426a00665e5c4479674322d4f75edadd46debd5cd992f6ed090689d0585ff075ec9e99ad690c3395bc4b313370b38ef355acdadcd122975b12c85ea5db8c6deb4aab71808dcb408fe3d1e7690c43d37b4ce6cc0166fa7daa"
+)
+
+func newCryptoTestProcess(t *testing.T) *exec.Process {
+	cache := storage.NewCacheDB(overlaydb.NewOverlayDB(leveldbstore.NewMemLevelDBStore()))
+	proc, _ := newTestProcess(t, common.Address{1}, cache)
+	return proc
+}
+
+func TestHash(t *testing.T) {
+	proc := newCryptoTestProcess(t)
+	writeMemory(t, proc, 0, []byte("abc"))
+
+	Keccak256(proc, 0, 3, 100)
+	assert.Equal(t, "4e03657aea45a94fc7d47ba826c8d667c0d1e6e33a64a036ec44f58fa12d6c45",
+		hex.EncodeToString(readMemory(t, proc, 100, 32)))
+	Ripemd160(proc, 0, 3, 200)
+	assert.Equal(t, "8eb208f7e05d987a9b044a8e98c6b087f15a0bfc", hex.EncodeToString(readMemory(t, proc, 200, 20)))
+	Blake2b256(proc, 0, 3, 300)
+	assert.Equal(t, "bddd813c634239723171ef3fee98579b94964e3bb1cb3e427262c8c068d52319",
+		hex.EncodeToString(readMemory(t, proc, 300, 32)))
+
+	assert.Panics(t, func() { Keccak256(proc, 65535, 2, 100) })
+}
+
+func TestEcrecover(t *testing.T) {
+	proc := newCryptoTestProcess(t)
+	key, err := crypto.GenerateKey()
+	assert.Nil(t, err)
+	addr := crypto.PubkeyToAddress(key.PublicKey)
+	hash := crypto.Keccak256([]byte("abc"))
+	sig, err := crypto.Sign(hash, key)
+	assert.Nil(t, err)
+	writeMemory(t, proc, 0, hash)
+
+	recoverAddr := func(v byte) (uint32, []byte) {
+		sig[64] = v
+		writeMemory(t, proc, 100, sig)
+		writeMemory(t, proc, 200, make([]byte, 20))
+		return Ecrecover(proc, 0, 100, 200), readMemory(t, proc, 200, 20)
+	}
+	recid := sig[64]
+	for _, v := range []byte{recid, recid + 27} {
+		ok, recovered := recoverAddr(v)
+		assert.Equal(t, uint32(1), ok)
+		assert.Equal(t, addr[:], recovered)
+	}
+	// the other recovery id recovers another key or none
+	for _, v := range []byte{1 - recid, 28 - recid} {
+		ok, recovered := recoverAddr(v)
+		if ok == 1 {
+			assert.NotEqual(t, addr[:], recovered)
+		}
+	}
+	ok, recovered := recoverAddr(29)
+	assert.Equal(t, uint32(0), ok)
+	assert.Equal(t, make([]byte, 20), recovered)
+}
+
+func TestVerifySignature(t *testing.T) {
+	proc := newCryptoTestProcess(t)
+	data := []byte("hello")
+	writeMemory(t, proc, 0, data)
+
+	for _, c := range []struct {
+		keyType keypair.KeyType
+		param   interface{}
+		scheme  s.SignatureScheme
+	}{
+		{keypair.PK_ECDSA, keypair.P256, s.SHA256withECDSA},
+		{keypair.PK_SM2, keypair.SM2P256V1, s.SM3withSM2},
+		{keypair.PK_EDDSA, keypair.ED25519, s.SHA512withEdDSA},
+	} {
+		priv, pub, err := keypair.GenerateKeyPair(c.keyType, c.param)
+		assert.Nil(t, err)
+		sig, err := s.Sign(c.scheme, priv, data, nil)
+		assert.Nil(t, err)
+		sigData, err := s.Serialize(sig)
+		assert.Nil(t, err)
+		key := keypair.SerializePublicKey(pub)
+		writeMemory(t, proc, 100, key)
+		writeMemory(t, proc, 300, sigData)
+		verify := func(dataLen uint32) uint32 {
+			return VerifySignature(proc, 100, uint32(len(key)), 0, dataLen, 300, uint32(len(sigData)))
+		}
+		assert.Equal(t, uint32(1), verify(uint32(len(data))), c.scheme)
+		assert.Equal(t, uint32(0), verify(uint32(len(data)-1)), c.scheme)
+	}
+	// invalid public key
+	assert.Equal(t, uint32(0), VerifySignature(proc, 0, 5, 0, 5, 300, 64))
+}
+
+func TestBn256Pairing(t *testing.T) {
+	proc := newCryptoTestProcess(t)
+	for input, expected := range map[string]uint32{bn256PairingTrue: 1, bn256PairingFalse: 0, "": 1} {
+		data, err := hex.DecodeString(input)
+		assert.Nil(t, err)
+		writeMemory(t, proc, 0, data)
+		assert.Equal(t, expected, Bn256Pairing(proc, 0, uint32(len(data))))
+	}
+	// the input is made of 192 bytes pairs
+	assert.Panics(t, func() { Bn256Pairing(proc, 0, 100) })
+}
+
+func TestCheckCryptoImports(t *testing.T) {
+	module := &wasm.Module{
+		Import: &wasm.SectionImports{
+			Entries: []wasm.ImportEntry{{ModuleName: "env", FieldName: "cntmio_ecrecover"}},
+		},
+	}
+	networkId := config.DefConfig.P2PNode.NetworkId
+	defer func() {
+		config.DefConfig.P2PNode.NetworkId = networkId
+	}()
+	config.DefConfig.P2PNode.NetworkId = config.NETWORK_ID_POLARIS_NET
+	assert.NotNil(t, CheckHostImports(module, 100))
+	config.DefConfig.P2PNode.NetworkId = config.NETWORK_ID_SOLO_NET
+	assert.Nil(t, CheckHostImports(module, 0))
+}
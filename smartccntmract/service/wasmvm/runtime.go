@@ -34,6 +34,7 @@ import (
 	"github.com/cntmio/cntmology/smartccntmract/service/native/utils"
 	"github.com/cntmio/cntmology/smartccntmract/service/util"
 	"github.com/cntmio/cntmology/smartccntmract/states"
+	"github.com/cntmio/cntmology/smartccntmract/storage"
 	"github.com/cntmio/cntmology/vm/crossvm_codec"
 	neotypes "github.com/cntmio/cntmology/vm/neovm/types"
 	"github.com/cntmio/wagon/exec"
@@ -54,6 +55,8 @@ type Runtime struct {
 	Input      []byte
 	Output     []byte
 	CallOutPut []byte
+	iterators  map[uint32]*storage.StorageIterator
+	nextIter   uint32
 }
 
 func Timestamp(proc *exec.Process) uint64 {
@@ -435,6 +438,18 @@ func NewHostModule() *wasm.Module {
 				Form:       0, // value for the 'func' type constructor
 				ParamTypes: []wasm.ValueType{wasm.ValueTypeI32, wasm.ValueTypeI32, wasm.ValueTypeI32},
 			},
+			//func(uint32,uint32,uint32,uint32)uint32  [12]
+			{
+				Form:        0, // value for the 'func' type constructor
+				ParamTypes:  []wasm.ValueType{wasm.ValueTypeI32, wasm.ValueTypeI32, wasm.ValueTypeI32, wasm.ValueTypeI32},
+				ReturnTypes: []wasm.ValueType{wasm.ValueTypeI32},
+			},
+			//func(uint32 * 6)uint32   [13]
+			{
+				Form:        0, // value for the 'func' type constructor
+				ParamTypes:  paramTypes[:6],
+				ReturnTypes: []wasm.ValueType{wasm.ValueTypeI32},
+			},
 		},
 	}
 	m.FunctionIndexSpace = []wasm.Function{
@@ -558,6 +573,61 @@ func NewHostModule() *wasm.Module {
 			Host: reflect.ValueOf(Sha256),
 			Body: &wasm.FunctionBody{}, // create a dummy wasm body (the actual value will be taken from Host.)
 		},
+		{ //24
+			Sig:  &m.Types.Entries[8],
+			Host: reflect.ValueOf(StorageIterNew),
+			Body: &wasm.FunctionBody{}, // create a dummy wasm body (the actual value will be taken from Host.)
+		},
+		{ //25
+			Sig:  &m.Types.Entries[3],
+			Host: reflect.ValueOf(StorageIterNext),
+			Body: &wasm.FunctionBody{}, // create a dummy wasm body (the actual value will be taken from Host.)
+		},
+		{ //26
+			Sig:  &m.Types.Entries[12],
+			Host: reflect.ValueOf(StorageIterKey),
+			Body: &wasm.FunctionBody{}, // create a dummy wasm body (the actual value will be taken from Host.)
+		},
+		{ //27
+			Sig:  &m.Types.Entries[12],
+			Host: reflect.ValueOf(StorageIterValue),
+			Body: &wasm.FunctionBody{}, // create a dummy wasm body (the actual value will be taken from Host.)
+		},
+		{ //28
+			Sig:  &m.Types.Entries[2],
+			Host: reflect.ValueOf(StorageIterClose),
+			Body: &wasm.FunctionBody{}, // create a dummy wasm body (the actual value will be taken from Host.)
+		},
+		{ //29
+			Sig:  &m.Types.Entries[11],
+			Host: reflect.ValueOf(Keccak256),
+			Body: &wasm.FunctionBody{}, // create a dummy wasm body (the actual value will be taken from Host.)
+		},
+		{ //30
+			Sig:  &m.Types.Entries[11],
+			Host: reflect.ValueOf(Ripemd160),
+			Body: &wasm.FunctionBody{}, // create a dummy wasm body (the actual value will be taken from Host.)
+		},
+		{ //31
+			Sig:  &m.Types.Entries[11],
+			Host: reflect.ValueOf(Blake2b256),
+			Body: &wasm.FunctionBody{}, // create a dummy wasm body (the actual value will be taken from Host.)
+		},
+		{ //32
+			Sig:  &m.Types.Entries[5],
+			Host: reflect.ValueOf(Ecrecover),
+			Body: &wasm.FunctionBody{}, // create a dummy wasm body (the actual value will be taken from Host.)
+		},
+		{ //33
+			Sig:  &m.Types.Entries[13],
+			Host: reflect.ValueOf(VerifySignature),
+			Body: &wasm.FunctionBody{}, // create a dummy wasm body (the actual value will be taken from Host.)
+		},
+		{ //34
+			Sig:  &m.Types.Entries[8],
+			Host: reflect.ValueOf(Bn256Pairing),
+			Body: &wasm.FunctionBody{}, // create a dummy wasm body (the actual value will be taken from Host.)
+		},
 	}
 
 	m.Export = &wasm.SectionExports{
@@ -682,6 +752,61 @@ func NewHostModule() *wasm.Module {
 				Kind:     wasm.ExternalFunction,
 				Index:    23,
 			},
+			"cntmio_storage_iter_new": {
+				FieldStr: "cntmio_storage_iter_new",
+				Kind:     wasm.ExternalFunction,
+				Index:    24,
+			},
+			"cntmio_storage_iter_next": {
+				FieldStr: "cntmio_storage_iter_next",
+				Kind:     wasm.ExternalFunction,
+				Index:    25,
+			},
+			"cntmio_storage_iter_key": {
+				FieldStr: "cntmio_storage_iter_key",
+				Kind:     wasm.ExternalFunction,
+				Index:    26,
+			},
+			"cntmio_storage_iter_value": {
+				FieldStr: "cntmio_storage_iter_value",
+				Kind:     wasm.ExternalFunction,
+				Index:    27,
+			},
+			"cntmio_storage_iter_close": {
+				FieldStr: "cntmio_storage_iter_close",
+				Kind:     wasm.ExternalFunction,
+				Index:    28,
+			},
+			"cntmio_keccak256": {
+				FieldStr: "cntmio_keccak256",
+				Kind:     wasm.ExternalFunction,
+				Index:    29,
+			},
+			"cntmio_ripemd160": {
+				FieldStr: "cntmio_ripemd160",
+				Kind:     wasm.ExternalFunction,
+				Index:    30,
+			},
+			"cntmio_blake2b256": {
+				FieldStr: "cntmio_blake2b256",
+				Kind:     wasm.ExternalFunction,
+				Index:    31,
+			},
+			"cntmio_ecrecover": {
+				FieldStr: "cntmio_ecrecover",
+				Kind:     wasm.ExternalFunction,
+				Index:    32,
+			},
+			"cntmio_verify_signature": {
+				FieldStr: "cntmio_verify_signature",
+				Kind:     wasm.ExternalFunction,
+				Index:    33,
+			},
+			"cntmio_bn256_pairing": {
+				FieldStr: "cntmio_bn256_pairing",
+				Kind:     wasm.ExternalFunction,
+				Index:    34,
+			},
 		},
 	}
 
@@ -22,6 +22,7 @@ import (
 	"math"
 
 	"github.com/cntmio/cntmology/core/states"
+	"github.com/cntmio/cntmology/smartccntmract/storage"
 	"github.com/cntmio/wagon/exec"
 )
 
@@ -97,3 +98,98 @@ func StorageDelete(proc *exec.Process, keyPtr uint32, keyLen uint32) {
 
 	self.Service.CacheDB.Delete(key)
 }
+
+func StorageIterNew(proc *exec.Process, prefixPtr uint32, prefixLen uint32) uint32 {
+	self := proc.HostData().(*Runtime)
+	self.checkGas(STORAGE_ITER_NEW_GAS)
+	if prefixLen > MAX_STORAGE_PREFIX_LEN {
+		panic(errors.New("storage key prefix too long"))
+	}
+	prefix, err := ReadWasmMemory(proc, prefixPtr, prefixLen)
+	if err != nil {
+		panic(err)
+	}
+	if len(self.iterators) >= MAX_STORAGE_ITERATORS {
+		panic(errors.New("too many storage iterators"))
+	}
+	if self.iterators == nil {
+		self.iterators = make(map[uint32]*storage.StorageIterator)
+	}
+
+	address := self.Service.CcntmextRef.CurrentCcntmext().CcntmractAddress
+	handle := self.nextIter
+	self.nextIter += 1
+	self.iterators[handle] = self.Service.CacheDB.NewStorageIterator(address, prefix)
+
+	return handle
+}
+
+func StorageIterNext(proc *exec.Process, handle uint32) uint32 {
+	self := proc.HostData().(*Runtime)
+	self.checkGas(STORAGE_ITER_NEXT_GAS)
+	has, err := self.getIterator(handle).Next()
+	if err != nil {
+		panic(err)
+	}
+	if has {
+		return 1
+	}
+	return 0
+}
+
+func StorageIterKey(proc *exec.Process, handle uint32, dst uint32, dlen uint32, offset uint32) uint32 {
+	self := proc.HostData().(*Runtime)
+	self.checkGas(STORAGE_ITER_READ_GAS)
+	iter := self.getIterator(handle)
+	if !iter.Valid() {
+		panic(errors.New("iterator has no current item"))
+	}
+	return writeStorageData(proc, iter.Key(), dst, dlen, offset)
+}
+
+func StorageIterValue(proc *exec.Process, handle uint32, dst uint32, dlen uint32, offset uint32) uint32 {
+	self := proc.HostData().(*Runtime)
+	self.checkGas(STORAGE_ITER_READ_GAS)
+	iter := self.getIterator(handle)
+	if !iter.Valid() {
+		panic(errors.New("iterator has no current item"))
+	}
+	return writeStorageData(proc, iter.Value(), dst, dlen, offset)
+}
+
+func StorageIterClose(proc *exec.Process, handle uint32) {
+	self := proc.HostData().(*Runtime)
+	self.getIterator(handle).Release()
+	delete(self.iterators, handle)
+}
+
+func (self *Runtime) getIterator(handle uint32) *storage.StorageIterator {
+	iter, ok := self.iterators[handle]
+	if !ok {
+		panic(errors.New("invalid storage iterator handle"))
+	}
+	return iter
+}
+
+func (self *Runtime) releaseIterators() {
+	for _, iter := range self.iterators {
+		iter.Release()
+	}
+	self.iterators = nil
+}
+
+func writeStorageData(proc *exec.Process, data []byte, dst uint32, dlen uint32, offset uint32) uint32 {
+	if uint32(len(data)) < offset {
+		panic(errors.New("offset is invalid"))
+	}
+	rest := data[offset:]
+	length := dlen
+	if uint32(len(rest)) < dlen {
+		length = uint32(len(rest))
+	}
+	_, err := proc.WriteAt(rest[:length], int64(dst))
+	if err != nil {
+		panic(err)
+	}
+	return uint32(len(data))
+}
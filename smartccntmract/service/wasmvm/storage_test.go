@@ -0,0 +1,156 @@
+/*
+ * Copyright (C) 2018 The cntmology Authors
+ * This file is part of The cntmology library.
+ *
+ * The cntmology is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The cntmology is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * alcntm with The cntmology.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package wasmvm
+
+import (
+	"math"
+	"testing"
+
+	"github.com/cntmio/cntmology/common"
+	"github.com/cntmio/cntmology/common/config"
+	"github.com/cntmio/cntmology/core/states"
+	"github.com/cntmio/cntmology/core/store/leveldbstore"
+	"github.com/cntmio/cntmology/core/store/overlaydb"
+	"github.com/cntmio/cntmology/smartccntmract/ccntmext"
+	"github.com/cntmio/cntmology/smartccntmract/storage"
+	"github.com/cntmio/wagon/exec"
+	"github.com/cntmio/wagon/wasm"
+	"github.com/stretchr/testify/assert"
+)
+
+// a module of one memory page and nothing else
+var memoryModule = []byte{0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00, 0x05, 0x03, 0x01, 0x00, 0x01}
+
+type testCcntmextRef struct {
+	ccntmext.CcntmextRef
+	current *ccntmext.Ccntmext
+}
+
+func (self *testCcntmextRef) CurrentCcntmext() *ccntmext.Ccntmext {
+	return self.current
+}
+
+func newTestProcess(t *testing.T, addr common.Address, cache *storage.CacheDB) (*exec.Process, *Runtime) {
+	compiled, err := ReadWasmModule(memoryModule, false)
+	assert.Nil(t, err)
+	vm, err := exec.NewVMWithCompiled(compiled, WASM_MEM_LIMITATION)
+	assert.Nil(t, err)
+	gasLimit := uint64(math.MaxUint64)
+	vm.ExecMetrics = &exec.Gas{GasLimit: &gasLimit, GasPrice: 0, GasFactor: 1}
+	service := &WasmVmService{
+		CacheDB:     cache,
+		CcntmextRef: &testCcntmextRef{current: &ccntmext.Ccntmext{CcntmractAddress: addr}},
+		vm:          vm,
+	}
+	host := &Runtime{Service: service}
+	vm.HostData = host
+	return exec.NewProcess(vm), host
+}
+
+func writeMemory(t *testing.T, proc *exec.Process, ptr uint32, data []byte) {
+	_, err := proc.WriteAt(data, int64(ptr))
+	assert.Nil(t, err)
+}
+
+func readMemory(t *testing.T, proc *exec.Process, ptr uint32, length uint32) []byte {
+	data, err := ReadWasmMemory(proc, ptr, length)
+	assert.Nil(t, err)
+	return data
+}
+
+func TestStorageIter(t *testing.T) {
+	addr := common.Address{1}
+	cache := storage.NewCacheDB(overlaydb.NewOverlayDB(leveldbstore.NewMemLevelDBStore()))
+	for key, value := range map[string]string{"user_a": "1", "user_b": "22", "total": "3"} {
+		cache.Put(serializeStorageKey(addr, []byte(key)), states.GenRawStorageItem([]byte(value)))
+	}
+	cache.Put(serializeStorageKey(common.Address{2}, []byte("user_c")), states.GenRawStorageItem([]byte("4")))
+	proc, host := newTestProcess(t, addr, cache)
+
+	writeMemory(t, proc, 0, []byte("user_"))
+	handle := StorageIterNew(proc, 0, 5)
+	assert.Panics(t, func() { StorageIterKey(proc, handle, 100, 32, 0) })
+
+	var keys, values []string
+	for StorageIterNext(proc, handle) == 1 {
+		klen := StorageIterKey(proc, handle, 100, 32, 0)
+		keys = append(keys, string(readMemory(t, proc, 100, klen)))
+		vlen := StorageIterValue(proc, handle, 200, 32, 0)
+		values = append(values, string(readMemory(t, proc, 200, vlen)))
+	}
+	assert.Equal(t, []string{"user_a", "user_b"}, keys)
+	assert.Equal(t, []string{"1", "22"}, values)
+	assert.Equal(t, uint32(0), StorageIterNext(proc, handle))
+
+	// the data is read from the offset and truncated to the buffer length
+	handle = StorageIterNew(proc, 0, 5)
+	assert.Equal(t, uint32(1), StorageIterNext(proc, handle))
+	assert.Equal(t, uint32(6), StorageIterKey(proc, handle, 300, 2, 3))
+	assert.Equal(t, []byte("r_"), readMemory(t, proc, 300, 2))
+	assert.Panics(t, func() { StorageIterKey(proc, handle, 300, 2, 7) })
+
+	StorageIterClose(proc, handle)
+	assert.Panics(t, func() { StorageIterNext(proc, handle) })
+	assert.Equal(t, 1, len(host.iterators))
+	host.releaseIterators()
+	assert.Equal(t, 0, len(host.iterators))
+}
+
+func TestStorageIterLimits(t *testing.T) {
+	cache := storage.NewCacheDB(overlaydb.NewOverlayDB(leveldbstore.NewMemLevelDBStore()))
+	proc, host := newTestProcess(t, common.Address{1}, cache)
+	defer host.releaseIterators()
+
+	assert.Panics(t, func() { StorageIterNew(proc, 0, MAX_STORAGE_PREFIX_LEN+1) })
+	assert.Panics(t, func() { StorageIterNew(proc, math.MaxUint32-1, 4) })
+	for i := 0; i < MAX_STORAGE_ITERATORS; i++ {
+		StorageIterNew(proc, 0, 0)
+	}
+	assert.Panics(t, func() { StorageIterNew(proc, 0, 0) })
+
+	// the gas is charged before the iterator is opened
+	*host.Service.vm.ExecMetrics.GasLimit = STORAGE_ITER_NEW_GAS - 1
+	host.releaseIterators()
+	assert.Panics(t, func() { StorageIterNew(proc, 0, 0) })
+	assert.Equal(t, 0, len(host.iterators))
+}
+
+func TestCheckHostImports(t *testing.T) {
+	module := &wasm.Module{
+		Import: &wasm.SectionImports{
+			Entries: []wasm.ImportEntry{
+				{ModuleName: "env", FieldName: "cntmio_storage_read"},
+				{ModuleName: "env", FieldName: "cntmio_storage_iter_new"},
+			},
+		},
+	}
+	networkId := config.DefConfig.P2PNode.NetworkId
+	defer func() {
+		config.DefConfig.P2PNode.NetworkId = networkId
+	}()
+	config.DefConfig.P2PNode.NetworkId = config.NETWORK_ID_MAIN_NET
+	assert.NotNil(t, CheckHostImports(module, 100))
+	assert.Nil(t, CheckHostImports(&wasm.Module{}, 100))
+	module.Import.Entries = module.Import.Entries[:1]
+	assert.Nil(t, CheckHostImports(module, 100))
+
+	module.Import.Entries = append(module.Import.Entries, wasm.ImportEntry{ModuleName: "env", FieldName: "cntmio_storage_iter_close"})
+	config.DefConfig.P2PNode.NetworkId = config.NETWORK_ID_SOLO_NET
+	assert.Nil(t, CheckHostImports(module, 0))
+}
@@ -22,6 +22,7 @@ import (
 	"errors"
 	"fmt"
 
+	"github.com/cntmio/cntmology/common/config"
 	"github.com/cntmio/wagon/exec"
 	"github.com/cntmio/wagon/validate"
 	"github.com/cntmio/wagon/wasm"
@@ -40,6 +41,38 @@ func ReadWasmMemory(proc *exec.Process, ptr uint32, len uint32) ([]byte, error)
 	return keybytes, nil
 }
 
+// hostImportHeights are the activation heights of the host functions added after launch, by network id
+var hostImportHeights = map[string]func(id uint32) uint32{
+	"cntmio_storage_iter_new":   config.GetStorageIteratorHeight,
+	"cntmio_storage_iter_next":  config.GetStorageIteratorHeight,
+	"cntmio_storage_iter_key":   config.GetStorageIteratorHeight,
+	"cntmio_storage_iter_value": config.GetStorageIteratorHeight,
+	"cntmio_storage_iter_close": config.GetStorageIteratorHeight,
+	"cntmio_keccak256":          config.GetWasmCryptoHeight,
+	"cntmio_ripemd160":          config.GetWasmCryptoHeight,
+	"cntmio_blake2b256":         config.GetWasmCryptoHeight,
+	"cntmio_ecrecover":          config.GetWasmCryptoHeight,
+	"cntmio_verify_signature":   config.GetWasmCryptoHeight,
+	"cntmio_bn256_pairing":      config.GetWasmCryptoHeight,
+}
+
+// CheckHostImports reject the host functions imported by the module before they are enabled at the height
+func CheckHostImports(m *wasm.Module, height uint32) error {
+	if m.Import == nil {
+		return nil
+	}
+	for _, entry := range m.Import.Entries {
+		if entry.ModuleName != "env" {
+			continue
+		}
+		getHeight, ok := hostImportHeights[entry.FieldName]
+		if ok && height < getHeight(config.DefConfig.P2PNode.NetworkId) {
+			return fmt.Errorf("[Validate] host function %s is not enabled at height %d", entry.FieldName, height)
+		}
+	}
+	return nil
+}
+
 func checkOntoWasm(m *wasm.Module) error {
 	if m.Start != nil {
 		return errors.New("[Validate] start section is not allowed.")
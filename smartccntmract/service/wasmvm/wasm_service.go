@@ -103,6 +103,7 @@ func (this *WasmVmService) Invoke() (interface{}, error) {
 	}
 	this.CcntmextRef.PushCcntmext(&ccntmext.Ccntmext{CcntmractAddress: ccntmract.Address, Code: wasmCode})
 	host := &Runtime{Service: this, Input: ccntmract.Args}
+	defer host.releaseIterators()
 
 	var compiled *exec.CompiledModule
 	if CodeCache != nil {
@@ -119,6 +120,9 @@ func (this *WasmVmService) Invoke() (interface{}, error) {
 		}
 		CodeCache.Add(ccntmract.Address.ToHexString(), compiled)
 	}
+	if err := CheckHostImports(compiled.RawModule, this.Height); err != nil {
+		return nil, err
+	}
 
 	vm, err := exec.NewVMWithCompiled(compiled, WASM_MEM_LIMITATION)
 	if err != nil {
@@ -32,6 +32,7 @@ type CacheDB struct {
 	memdb      *overlaydb.MemDB
 	backend    *overlaydb.OverlayDB
 	keyScratch []byte
+	written    uint64 // size of the keys and values written, never reset
 }
 
 const initCap = 1024
@@ -53,6 +54,12 @@ func (self *CacheDB) Reset() {
 	self.memdb.Reset()
 }
 
+// WrittenBytes returns the size of the keys and values written to the cache, callers take the
+// difference around an execution to charge the storage it writes
+func (self *CacheDB) WrittenBytes() uint64 {
+	return self.written
+}
+
 func ensureBuffer(b []byte, n int) []byte {
 	if cap(b) < n {
 		return make([]byte, n)
@@ -94,6 +101,7 @@ func (self *CacheDB) GenAccountStateKey(addr comm.Address, key []byte) []byte {
 
 func (self *CacheDB) put(prefix common.DataEntryPrefix, key []byte, value []byte) {
 	self.keyScratch = makePrefixedKey(self.keyScratch, byte(prefix), key)
+	self.written += uint64(len(self.keyScratch) + len(value))
 	self.memdb.Put(self.keyScratch, value)
 }
 
@@ -153,6 +161,7 @@ func (self *CacheDB) Delete(key []byte) {
 // Delete item from cache
 func (self *CacheDB) delete(prefix common.DataEntryPrefix, key []byte) {
 	self.keyScratch = makePrefixedKey(self.keyScratch, byte(prefix), key)
+	self.written += uint64(len(self.keyScratch))
 	self.memdb.Delete(self.keyScratch)
 }
 
@@ -0,0 +1,111 @@
+/*
+ * Copyright (C) 2018 The cntmology Authors
+ * This file is part of The cntmology library.
+ *
+ * The cntmology is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The cntmology is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * alcntm with The cntmology.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package storage
+
+import (
+	"errors"
+
+	comm "github.com/cntmio/cntmology/common"
+	"github.com/cntmio/cntmology/core/states"
+	"github.com/cntmio/cntmology/core/store/common"
+)
+
+var ErrIterReleased = errors.New("storage iterator released")
+
+// StorageIterator walks the storage items of a ccntmract whose keys start with the given prefix.
+// Items are visited in key order and writes pending in the cache are honoured.
+type StorageIterator struct {
+	iter    common.StoreIterator
+	prefix  []byte
+	started bool
+	valid   bool
+	key     []byte
+	value   []byte
+}
+
+// NewStorageIterator return an iterator over the storage of ccntmract address under prefix
+func (self *CacheDB) NewStorageIterator(address comm.Address, prefix []byte) *StorageIterator {
+	key := make([]byte, 0, len(address)+len(prefix))
+	key = append(key, address[:]...)
+	key = append(key, prefix...)
+
+	return &StorageIterator{iter: self.NewIterator(key), prefix: key}
+}
+
+// Prefix return the ccntmract address followed by the key prefix of the iterator
+func (self *StorageIterator) Prefix() []byte {
+	return self.prefix
+}
+
+// Next move to the next item, the first call move to the first item.
+// It return false when there are no more items.
+func (self *StorageIterator) Next() (bool, error) {
+	if self.iter == nil {
+		return false, ErrIterReleased
+	}
+	if self.started && !self.valid {
+		return false, nil
+	}
+	if self.started {
+		self.valid = self.iter.Next()
+	} else {
+		self.started = true
+		self.valid = self.iter.First()
+	}
+	self.key, self.value = nil, nil
+	if !self.valid {
+		return false, self.iter.Error()
+	}
+
+	value, err := states.GetValueFromRawStorageItem(self.iter.Value())
+	if err != nil {
+		self.valid = false
+		return false, err
+	}
+	key := self.iter.Key()[comm.ADDR_LEN:]
+	self.key = append(make([]byte, 0, len(key)), key...)
+	self.value = append(make([]byte, 0, len(value)), value...)
+
+	return true, nil
+}
+
+// Valid return whether the iterator is positioned at an item
+func (self *StorageIterator) Valid() bool {
+	return self.iter != nil && self.valid
+}
+
+// Key return the storage key of current item without the ccntmract address
+func (self *StorageIterator) Key() []byte {
+	return self.key
+}
+
+// Value return the storage value of current item
+func (self *StorageIterator) Value() []byte {
+	return self.value
+}
+
+// Release close the underlying iterator, it is safe to be called more than once
+func (self *StorageIterator) Release() {
+	if self.iter != nil {
+		self.iter.Release()
+		self.iter = nil
+	}
+	self.valid = false
+	self.key, self.value = nil, nil
+}
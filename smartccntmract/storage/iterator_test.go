@@ -0,0 +1,87 @@
+/*
+ * Copyright (C) 2018 The cntmology Authors
+ * This file is part of The cntmology library.
+ *
+ * The cntmology is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The cntmology is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * alcntm with The cntmology.  If not, see <http://www.gnu.org/licenses/>.
+ */
+package storage
+
+import (
+	"testing"
+
+	comm "github.com/cntmio/cntmology/common"
+	"github.com/cntmio/cntmology/core/states"
+	"github.com/cntmio/cntmology/core/store/leveldbstore"
+	"github.com/cntmio/cntmology/core/store/overlaydb"
+	"github.com/stretchr/testify/assert"
+)
+
+func putStorage(cache *CacheDB, addr comm.Address, key, value string) {
+	k := append(addr[:], []byte(key)...)
+	cache.Put(k, states.GenRawStorageItem([]byte(value)))
+}
+
+func TestStorageIterator(t *testing.T) {
+	overlay := overlaydb.NewOverlayDB(leveldbstore.NewMemLevelDBStore())
+	cache := NewCacheDB(overlay)
+
+	addr := comm.Address{1}
+	other := comm.Address{2}
+	putStorage(cache, addr, "user_a", "1")
+	putStorage(cache, addr, "user_b", "2")
+	putStorage(cache, addr, "user_c", "3")
+	putStorage(cache, addr, "total", "6")
+	putStorage(cache, other, "user_a", "100")
+	cache.Commit()
+
+	// pending writes in cache
+	cache = NewCacheDB(overlay)
+	putStorage(cache, addr, "user_b", "20")
+	putStorage(cache, addr, "user_d", "4")
+	cache.Delete(append(addr[:], []byte("user_c")...))
+
+	iter := cache.NewStorageIterator(addr, []byte("user_"))
+	assert.Equal(t, append(addr[:], []byte("user_")...), iter.Prefix())
+	var keys, values []string
+	for {
+		has, err := iter.Next()
+		assert.Nil(t, err)
+		if !has {
+			break
+		}
+		assert.True(t, iter.Valid())
+		keys = append(keys, string(iter.Key()))
+		values = append(values, string(iter.Value()))
+	}
+	assert.Equal(t, []string{"user_a", "user_b", "user_d"}, keys)
+	assert.Equal(t, []string{"1", "20", "4"}, values)
+
+	has, err := iter.Next()
+	assert.Nil(t, err)
+	assert.False(t, has)
+	assert.False(t, iter.Valid())
+
+	iter.Release()
+	iter.Release()
+	_, err = iter.Next()
+	assert.Equal(t, ErrIterReleased, err)
+
+	iter = cache.NewStorageIterator(addr, nil)
+	count := 0
+	for has, _ := iter.Next(); has; has, _ = iter.Next() {
+		count++
+	}
+	iter.Release()
+	assert.Equal(t, 4, count)
+}
@@ -20,6 +20,8 @@
 package common
 
 import (
+	"container/heap"
+	"math"
 	"sort"
 	"sync"
 
@@ -38,17 +40,51 @@ type TXAttr struct {
 }
 
 type TXEntry struct {
-	Tx    *types.Transaction // transaction which has been verified
-	Attrs []*TXAttr          // the result from each validator
+	Tx     *types.Transaction // transaction which has been verified
+	Attrs  []*TXAttr          // the result from each validator
+	Height uint32             // the block height when the tx entered the pool
+	index  int                // the index in the price heap of the pool
+}
+
+// priceHeap is a min-heap of the transactions in the pool by gas price,
+// so the cheapest one is found without a scan when the pool is full.
+type priceHeap []*TXEntry
+
+func (h priceHeap) Len() int           { return len(h) }
+func (h priceHeap) Less(i, j int) bool { return h[i].Tx.GasPrice < h[j].Tx.GasPrice }
+func (h priceHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *priceHeap) Push(x interface{}) {
+	txEntry := x.(*TXEntry)
+	txEntry.index = len(*h)
+	*h = append(*h, txEntry)
+}
+
+func (h *priceHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	txEntry := old[n-1]
+	old[n-1] = nil
+	txEntry.index = -1
+	*h = old[0 : n-1]
+	return txEntry
 }
 
 // TXPool contains all currently valid transactions. Transactions
 // enter the pool when they are valid from the network,
 // consensus or submitted. They exit the pool when they are included
-// in the ledger.
+// in the ledger, replaced, evicted or expired.
 type TXPool struct {
 	sync.RWMutex
-	txList map[common.Uint256]*TXEntry // Transactions which have been verified
+	txList    map[common.Uint256]*TXEntry            // Transactions which have been verified
+	payerList map[common.Address]map[uint32]*TXEntry // Transactions indexed by payer and nonce
+	txHeights map[common.Uint256]uint32              // The block height when a tx entered the pool
+	priced    priceHeap                              // Transactions ordered by gas price
+	height    uint32                                 // The height of the latest saved block
 }
 
 // Init creates a new transaction pool to gather.
@@ -56,6 +92,105 @@ func (tp *TXPool) Init() {
 	tp.Lock()
 	defer tp.Unlock()
 	tp.txList = make(map[common.Uint256]*TXEntry)
+	tp.payerList = make(map[common.Address]map[uint32]*TXEntry)
+	tp.txHeights = make(map[common.Uint256]uint32)
+	tp.priced = nil
+}
+
+// ReplacementGasPrice returns the min gas price of a transaction to
+// replace the one with the same payer and nonce in the pool.
+func ReplacementGasPrice(gasPrice uint64) uint64 {
+	bump, overflow := common.SafeMul(gasPrice, config.DefConfig.Common.TxPoolPriceBump)
+	if overflow {
+		return math.MaxUint64
+	}
+	price, overflow := common.SafeAdd(gasPrice, bump/100)
+	if overflow {
+		return math.MaxUint64
+	}
+	if price == gasPrice {
+		price++
+	}
+	return price
+}
+
+// lowestTx returns the transaction of the lowest gas price in the list.
+func lowestTx(txList map[uint32]*TXEntry) *TXEntry {
+	var lowest *TXEntry
+	for _, txEntry := range txList {
+		if lowest == nil || txEntry.Tx.GasPrice < lowest.Tx.GasPrice {
+			lowest = txEntry
+		}
+	}
+	return lowest
+}
+
+// checkTx checks whether a transaction can enter the pool, and returns
+// the transaction to drop for it. A transaction with the payer and nonce
+// of one in the pool replaces it if the gas price is bumped enough. When
+// the payer or the pool is at its limit, the transaction of the lowest
+// gas price is evicted for a higher one.
+func (tp *TXPool) checkTx(tx *types.Transaction) (*TXEntry, errors.ErrCode) {
+	if _, ok := tp.txList[tx.Hash()]; ok {
+		return nil, errors.ErrDuplicateInput
+	}
+
+	payerList := tp.payerList[tx.Payer]
+	if old, ok := payerList[tx.Nonce]; ok {
+		if tx.GasPrice < ReplacementGasPrice(old.Tx.GasPrice) {
+			return nil, errors.ErrReplaceUnderpriced
+		}
+		return old, errors.ErrNoError
+	}
+
+	limit := config.DefConfig.Common.TxPoolPayerLimit
+	if limit != 0 && len(payerList) >= int(limit) {
+		lowest := lowestTx(payerList)
+		if tx.GasPrice <= lowest.Tx.GasPrice {
+			return nil, errors.ErrPayerLimit
+		}
+		return lowest, errors.ErrNoError
+	}
+
+	capacity := int(config.DefConfig.Common.TxPoolCapacity)
+	if capacity <= 0 {
+		capacity = MAX_CAPACITY
+	}
+	if len(tp.txList) < capacity {
+		return nil, errors.ErrNoError
+	}
+	lowest := tp.priced[0]
+	if tx.GasPrice <= lowest.Tx.GasPrice {
+		return nil, errors.ErrTxPoolFull
+	}
+	return lowest, errors.ErrNoError
+}
+
+// removeTx removes a transaction from the tx list and the payer index.
+func (tp *TXPool) removeTx(txHash common.Uint256) *TXEntry {
+	txEntry, ok := tp.txList[txHash]
+	if !ok {
+		return nil
+	}
+	delete(tp.txList, txHash)
+	heap.Remove(&tp.priced, txEntry.index)
+	payerList := tp.payerList[txEntry.Tx.Payer]
+	if payerList[txEntry.Tx.Nonce] == txEntry {
+		delete(payerList, txEntry.Tx.Nonce)
+		if len(payerList) == 0 {
+			delete(tp.payerList, txEntry.Tx.Payer)
+		}
+	}
+	return txEntry
+}
+
+// CheckTxList checks whether a transaction can enter the pool without
+// adding it.
+func (tp *TXPool) CheckTxList(tx *types.Transaction) errors.ErrCode {
+	tp.RLock()
+	defer tp.RUnlock()
+	_, errCode := tp.checkTx(tx)
+	return errCode
 }
 
 // AddTxList adds a valid transaction to the transaction pool. If the
@@ -63,16 +198,47 @@ func (tp *TXPool) Init() {
 // txEntry includes transaction, fee, and verified information(height,
 // validator, error code).
 func (tp *TXPool) AddTxList(txEntry *TXEntry) bool {
+	_, errCode := tp.PutTxList(txEntry)
+	return errCode == errors.ErrNoError
+}
+
+// PutTxList adds a valid transaction to the transaction pool with the
+// replacement and eviction rules of checkTx. It returns the transaction
+// dropped for the new one, or the error code if it is rejected.
+func (tp *TXPool) PutTxList(txEntry *TXEntry) (*types.Transaction, errors.ErrCode) {
 	tp.Lock()
 	defer tp.Unlock()
 	txHash := txEntry.Tx.Hash()
-	if _, ok := tp.txList[txHash]; ok {
-		log.Infof("AddTxList: transaction %x is already in the pool", txHash)
-		return false
+	dropped, errCode := tp.checkTx(txEntry.Tx)
+	if errCode != errors.ErrNoError {
+		log.Debugf("PutTxList: transaction %x rejected: %s", txHash, errCode.Error())
+		return nil, errCode
+	}
+	if dropped != nil {
+		tp.removeTx(dropped.Tx.Hash())
+		delete(tp.txHeights, dropped.Tx.Hash())
 	}
 
+	// A transaction back from re-verifying keeps the height it entered
+	height, ok := tp.txHeights[txHash]
+	if !ok {
+		height = tp.height
+		tp.txHeights[txHash] = height
+	}
+	txEntry.Height = height
 	tp.txList[txHash] = txEntry
-	return true
+	heap.Push(&tp.priced, txEntry)
+	payerList := tp.payerList[txEntry.Tx.Payer]
+	if payerList == nil {
+		payerList = make(map[uint32]*TXEntry)
+		tp.payerList[txEntry.Tx.Payer] = payerList
+	}
+	payerList[txEntry.Tx.Nonce] = txEntry
+
+	if dropped != nil {
+		return dropped.Tx, errors.ErrNoError
+	}
+	return nil, errors.ErrNoError
 }
 
 // CleanTransactionList cleans the transaction list included in the ledger.
@@ -82,8 +248,8 @@ func (tp *TXPool) CleanTransactionList(txs []*types.Transaction) {
 	tp.Lock()
 	defer tp.Unlock()
 	for _, tx := range txs {
-		if _, ok := tp.txList[tx.Hash()]; ok {
-			delete(tp.txList, tx.Hash())
+		delete(tp.txHeights, tx.Hash())
+		if tp.removeTx(tx.Hash()) != nil {
 			cleaned++
 		}
 	}
@@ -91,16 +257,39 @@ func (tp *TXPool) CleanTransactionList(txs []*types.Transaction) {
 	log.Debugf("clean txes: total %d, cleaned %d, remains %d in TxPool", txsNum, cleaned, len(tp.txList))
 }
 
+// ExpireTxList records the height of the latest saved block, and removes
+// the transactions staying in the pool for more than the configured
+// lifetime blocks. It returns the expired transactions.
+func (tp *TXPool) ExpireTxList(height uint32) []*types.Transaction {
+	tp.Lock()
+	defer tp.Unlock()
+	tp.height = height
+	lifetime := config.DefConfig.Common.TxPoolLifetime
+
+	var expired []*types.Transaction
+	for txHash, entered := range tp.txHeights {
+		txEntry, ok := tp.txList[txHash]
+		if !ok {
+			// Dropped by re-verifying
+			delete(tp.txHeights, txHash)
+			continue
+		}
+		if lifetime != 0 && height-entered > lifetime {
+			tp.removeTx(txHash)
+			delete(tp.txHeights, txHash)
+			expired = append(expired, txEntry.Tx)
+		}
+	}
+	return expired
+}
+
 // DelTxList removes a single transaction from the pool.
 func (tp *TXPool) DelTxList(tx *types.Transaction) bool {
 	tp.Lock()
 	defer tp.Unlock()
 	txHash := tx.Hash()
-	if _, ok := tp.txList[txHash]; !ok {
-		return false
-	}
-	delete(tp.txList, txHash)
-	return true
+	delete(tp.txHeights, txHash)
+	return tp.removeTx(txHash) != nil
 }
 
 // isVerfiyExpired compares a verifed transaction's height with the next
@@ -176,6 +365,35 @@ func (tp *TXPool) GetTxStatus(hash common.Uint256) *TxStatus {
 	return ret
 }
 
+// GetTxsByPayer returns the transactions of the payer in the pool
+// ordered by nonce.
+func (tp *TXPool) GetTxsByPayer(payer common.Address) []*TXEntry {
+	tp.RLock()
+	defer tp.RUnlock()
+	payerList := tp.payerList[payer]
+	txList := make([]*TXEntry, 0, len(payerList))
+	for _, txEntry := range payerList {
+		txList = append(txList, txEntry)
+	}
+	sort.Slice(txList, func(i, j int) bool {
+		return txList[i].Tx.Nonce < txList[j].Tx.Nonce
+	})
+	return txList
+}
+
+// GetEIPTxList returns the EIP155 transactions in the pool.
+func (tp *TXPool) GetEIPTxList() []*types.Transaction {
+	tp.RLock()
+	defer tp.RUnlock()
+	txList := make([]*types.Transaction, 0)
+	for _, txEntry := range tp.txList {
+		if txEntry.Tx.IsEipTx() {
+			txList = append(txList, txEntry.Tx)
+		}
+	}
+	return txList
+}
+
 // GetTransactionCount returns the tx number of the pool.
 func (tp *TXPool) GetTransactionCount() int {
 	tp.RLock()
@@ -204,7 +422,7 @@ func (tp *TXPool) GetUnverifiedTxs(txs []*types.Transaction,
 		}
 
 		if !tp.compareTxHeight(txEntry, height) {
-			delete(tp.txList, tx.Hash())
+			tp.removeTx(tx.Hash())
 			res.OldTxs = append(res.OldTxs, txEntry.Tx)
 			continue
 		}
@@ -229,14 +447,15 @@ func (tp *TXPool) GetUnverifiedTxs(txs []*types.Transaction,
 func (tp *TXPool) RemoveTxsBelowGasPrice(gasPrice uint64) {
 	tp.Lock()
 	defer tp.Unlock()
-	for _, txEntry := range tp.txList {
-		if txEntry.Tx.GasPrice < gasPrice {
-			delete(tp.txList, txEntry.Tx.Hash())
-		}
+	for len(tp.priced) > 0 && tp.priced[0].Tx.GasPrice < gasPrice {
+		txHash := tp.priced[0].Tx.Hash()
+		tp.removeTx(txHash)
+		delete(tp.txHeights, txHash)
 	}
 }
 
-// Remain returns the remaining tx list to cleanup
+// Remain returns the remaining tx list to cleanup, the txs keep the
+// height they entered the pool for re-verifying
 func (tp *TXPool) Remain() []*types.Transaction {
 	tp.Lock()
 	defer tp.Unlock()
@@ -244,8 +463,10 @@ func (tp *TXPool) Remain() []*types.Transaction {
 	txList := make([]*types.Transaction, 0, len(tp.txList))
 	for _, txEntry := range tp.txList {
 		txList = append(txList, txEntry.Tx)
-		delete(tp.txList, txEntry.Tx.Hash())
 	}
+	tp.txList = make(map[common.Uint256]*TXEntry)
+	tp.payerList = make(map[common.Address]map[uint32]*TXEntry)
+	tp.priced = nil
 
 	return txList
 }
@@ -22,9 +22,12 @@ import (
 	"testing"
 	"time"
 
+	"github.com/conntectome/cntm/common"
+	"github.com/conntectome/cntm/common/config"
 	"github.com/conntectome/cntm/common/log"
 	"github.com/conntectome/cntm/core/payload"
 	"github.com/conntectome/cntm/core/types"
+	"github.com/conntectome/cntm/errors"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -99,3 +102,125 @@ func TestTxPool(t *testing.T) {
 		return
 	}
 }
+
+func newPoolTx(t *testing.T, payer byte, nonce uint32, gasPrice uint64) *TXEntry {
+	mutable := &types.MutableTransaction{
+		TxType:   types.InvokeCntm,
+		Nonce:    nonce,
+		GasPrice: gasPrice,
+		Payer:    common.Address{payer},
+		Payload:  &payload.InvokeCode{Code: []byte{}},
+	}
+	tx, err := mutable.IntoImmutable()
+	assert.Nil(t, err)
+	return &TXEntry{Tx: tx, Attrs: []*TXAttr{}}
+}
+
+func TestTxPoolReplaceAndEvict(t *testing.T) {
+	defer func(cfg config.CommonConfig) {
+		*config.DefConfig.Common = cfg
+	}(*config.DefConfig.Common)
+	config.DefConfig.Common.TxPoolCapacity = 4
+	config.DefConfig.Common.TxPoolPayerLimit = 2
+	config.DefConfig.Common.TxPoolPriceBump = 10
+
+	txPool := &TXPool{}
+	txPool.Init()
+
+	//replacement needs the gas price bumped by 10 percent
+	old := newPoolTx(t, 1, 1, 1000)
+	_, errCode := txPool.PutTxList(old)
+	assert.Equal(t, errors.ErrNoError, errCode)
+	_, errCode = txPool.PutTxList(newPoolTx(t, 1, 1, 1099))
+	assert.Equal(t, errors.ErrReplaceUnderpriced, errCode)
+	replacement := newPoolTx(t, 1, 1, 1100)
+	dropped, errCode := txPool.PutTxList(replacement)
+	assert.Equal(t, errors.ErrNoError, errCode)
+	assert.Equal(t, old.Tx.Hash(), dropped.Hash())
+	assert.Nil(t, txPool.GetTransaction(old.Tx.Hash()))
+	assert.Equal(t, uint64(1), ReplacementGasPrice(0))
+
+	//the payer limit evicts the lowest gas price of the payer
+	_, errCode = txPool.PutTxList(newPoolTx(t, 1, 2, 500))
+	assert.Equal(t, errors.ErrNoError, errCode)
+	_, errCode = txPool.PutTxList(newPoolTx(t, 1, 3, 500))
+	assert.Equal(t, errors.ErrPayerLimit, errCode)
+	_, errCode = txPool.PutTxList(newPoolTx(t, 1, 3, 600))
+	assert.Equal(t, errors.ErrNoError, errCode)
+	txs := txPool.GetTxsByPayer(common.Address{1})
+	assert.Equal(t, 2, len(txs))
+	assert.Equal(t, uint32(1), txs[0].Tx.Nonce)
+	assert.Equal(t, uint32(3), txs[1].Tx.Nonce)
+
+	//the capacity evicts the lowest gas price of the pool
+	_, errCode = txPool.PutTxList(newPoolTx(t, 2, 1, 700))
+	assert.Equal(t, errors.ErrNoError, errCode)
+	_, errCode = txPool.PutTxList(newPoolTx(t, 2, 2, 800))
+	assert.Equal(t, errors.ErrNoError, errCode)
+	assert.Equal(t, errors.ErrTxPoolFull, txPool.CheckTxList(newPoolTx(t, 3, 1, 600).Tx))
+	dropped, errCode = txPool.PutTxList(newPoolTx(t, 3, 1, 900))
+	assert.Equal(t, errors.ErrNoError, errCode)
+	assert.Equal(t, uint64(600), dropped.GasPrice)
+	assert.Equal(t, 4, txPool.GetTransactionCount())
+	assert.Equal(t, 1, len(txPool.GetTxsByPayer(common.Address{1})))
+}
+
+func TestTxPoolExpire(t *testing.T) {
+	defer func(cfg config.CommonConfig) {
+		*config.DefConfig.Common = cfg
+	}(*config.DefConfig.Common)
+	config.DefConfig.Common.TxPoolLifetime = 10
+
+	txPool := &TXPool{}
+	txPool.Init()
+	txPool.ExpireTxList(100)
+	first := newPoolTx(t, 1, 1, 500)
+	assert.True(t, txPool.AddTxList(first))
+	txPool.ExpireTxList(105)
+	second := newPoolTx(t, 1, 2, 500)
+	assert.True(t, txPool.AddTxList(second))
+	assert.Equal(t, uint32(105), second.Height)
+
+	//the txs back from re-verifying keep their heights
+	for _, tx := range txPool.Remain() {
+		assert.True(t, txPool.AddTxList(&TXEntry{Tx: tx}))
+	}
+	assert.Equal(t, 0, len(txPool.ExpireTxList(110)))
+	expired := txPool.ExpireTxList(111)
+	assert.Equal(t, 1, len(expired))
+	assert.Equal(t, first.Tx.Hash(), expired[0].Hash())
+	assert.Equal(t, 1, txPool.GetTransactionCount())
+	assert.Equal(t, uint32(105), txPool.GetTxsByPayer(common.Address{1})[0].Height)
+}
+
+func TestTxPoolPriceHeap(t *testing.T) {
+	defer func(cfg config.CommonConfig) {
+		*config.DefConfig.Common = cfg
+	}(*config.DefConfig.Common)
+	config.DefConfig.Common.TxPoolCapacity = 50
+	config.DefConfig.Common.TxPoolPayerLimit = 0
+
+	txPool := &TXPool{}
+	txPool.Init()
+	//the gas prices 1000 to 1049 in shuffled order
+	for i := 0; i < 50; i++ {
+		assert.True(t, txPool.AddTxList(newPoolTx(t, byte(i), 1, uint64(1000+(i*37)%50))))
+	}
+
+	//each eviction drops the cheapest transaction left
+	for i := 0; i < 10; i++ {
+		dropped, errCode := txPool.PutTxList(newPoolTx(t, byte(100+i), 1, 2000))
+		assert.Equal(t, errors.ErrNoError, errCode)
+		assert.Equal(t, uint64(1000+i), dropped.GasPrice)
+	}
+	assert.Equal(t, 50, txPool.GetTransactionCount())
+
+	txPool.DelTxList(txPool.GetTxsByPayer(common.Address{100})[0].Tx)
+	txPool.RemoveTxsBelowGasPrice(1030)
+	assert.Equal(t, 29, txPool.GetTransactionCount())
+	for i, txEntry := range txPool.priced {
+		assert.Equal(t, i, txEntry.index)
+		assert.True(t, txEntry.Tx.GasPrice >= 1030)
+	}
+	assert.Equal(t, uint64(1030), txPool.priced[0].Tx.GasPrice)
+}
@@ -34,11 +34,15 @@ import (
 	"github.com/conntectome/cntm/core/ledger"
 	tx "github.com/conntectome/cntm/core/types"
 	"github.com/conntectome/cntm/errors"
+	"github.com/conntectome/cntm/events"
+	"github.com/conntectome/cntm/events/message"
 	httpcom "github.com/conntectome/cntm/http/base/common"
 	params "github.com/conntectome/cntm/smartcontract/service/native/global_params"
 	nutils "github.com/conntectome/cntm/smartcontract/service/native/utils"
 	tc "github.com/conntectome/cntm/txnpool/common"
 	"github.com/conntectome/cntm/validator/types"
+	ethcomm "github.com/ethereum/go-ethereum/common"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
 )
 
 type txStats struct {
@@ -484,6 +488,76 @@ func (s *TXPoolServer) getTransaction(hash common.Uint256) *tx.Transaction {
 	return s.txPool.GetTransaction(hash)
 }
 
+// addTxList adds a verified transaction to the tx pool, and returns the
+// error code if the pool rejects it.
+func (s *TXPoolServer) addTxList(txEntry *tc.TXEntry) errors.ErrCode {
+	dropped, errCode := s.txPool.PutTxList(txEntry)
+	if errCode != errors.ErrNoError {
+		return errCode
+	}
+	if dropped != nil {
+		log.Debugf("addTxList: transaction %x dropped from the tx pool for %x",
+			dropped.Hash(), txEntry.Tx.Hash())
+	}
+	if events.DefActorPublisher != nil {
+		events.DefActorPublisher.Publish(message.TOPIC_TXN_POOL_ADD,
+			&message.TxnPoolAddMsg{Tx: txEntry.Tx})
+	}
+	return errors.ErrNoError
+}
+
+// checkTxList checks whether a transaction can enter the tx pool before
+// verifying it.
+func (s *TXPoolServer) checkTxList(tx *tx.Transaction) errors.ErrCode {
+	return s.txPool.CheckTxList(tx)
+}
+
+// GetTxsByPayer returns the transactions of the payer in the tx pool.
+func (s *TXPoolServer) GetTxsByPayer(payer common.Address) []*tc.TXEntry {
+	return s.txPool.GetTxsByPayer(payer)
+}
+
+// Nonce returns the nonce after the last EIP155 transaction of the address
+// in the tx pool, or 0 if the pool holds none.
+func (s *TXPoolServer) Nonce(addr common.Address) uint64 {
+	txs := s.txPool.GetTxsByPayer(addr)
+	for i := len(txs) - 1; i >= 0; i-- {
+		if txs[i].Tx.IsEipTx() {
+			return uint64(txs[i].Tx.Nonce) + 1
+		}
+	}
+	return 0
+}
+
+// PendingEIPTransactions returns the EIP155 transactions in the tx pool.
+func (s *TXPoolServer) PendingEIPTransactions() []*ethtypes.Transaction {
+	txs := s.txPool.GetEIPTxList()
+	ret := make([]*ethtypes.Transaction, 0, len(txs))
+	for _, t := range txs {
+		eip155Tx, err := t.GetEIP155Tx()
+		if err != nil {
+			log.Debugf("PendingEIPTransactions: tx %x: %s", t.Hash(), err)
+			continue
+		}
+		ret = append(ret, eip155Tx)
+	}
+	return ret
+}
+
+// PendingTransactionsByHash returns the EIP155 transaction with the hash in
+// the tx pool, or nil if the pool doesn't contain it.
+func (s *TXPoolServer) PendingTransactionsByHash(target ethcomm.Hash) *ethtypes.Transaction {
+	t := s.txPool.GetTransaction(common.Uint256(target))
+	if t == nil || !t.IsEipTx() {
+		return nil
+	}
+	eip155Tx, err := t.GetEIP155Tx()
+	if err != nil {
+		return nil
+	}
+	return eip155Tx
+}
+
 // getTxPool returns a tx list for consensus.
 func (s *TXPoolServer) getTxPool(byCount bool, height uint32) []*tc.TXEntry {
 	s.setHeight(height)
@@ -521,6 +595,13 @@ func (s *TXPoolServer) getPendingTxs(byCount bool) []*tx.Transaction {
 func (s *TXPoolServer) cleanTransactionList(txs []*tx.Transaction, height uint32) {
 	s.txPool.CleanTransactionList(txs)
 
+	// Drop the txs stuck in the pool
+	expired := s.txPool.ExpireTxList(height)
+	if len(expired) > 0 {
+		log.Infof("cleanTransactionList: %d transactions expired in the tx pool at height %d",
+			len(expired), height)
+	}
+
 	// Check whether to update the gas price and remove txs below the
 	// threshold
 	if height%tc.UPDATE_FREQUENCY == 0 {
@@ -0,0 +1,121 @@
+/*
+ * Copyright (C) 2018 The cntm Authors
+ * This file is part of The cntm library.
+ *
+ * The cntm is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The cntm is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The cntm.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package governance
+
+import (
+	"encoding/hex"
+	"fmt"
+
+	"github.com/conntectome/cntm/common"
+	vconfig "github.com/conntectome/cntm/consensus/vbft/config"
+	cstates "github.com/conntectome/cntm/core/states"
+	"github.com/conntectome/cntm/smartcontract/service/native"
+	"github.com/conntectome/cntm/smartcontract/service/native/utils"
+)
+
+//GetEvidenceKey returns the storage key marking the evidence against a peer in the round of height and view as processed
+func GetEvidenceKey(contract common.Address, peerPubkey string, height, view uint32) ([]byte, error) {
+	key, err := vconfig.EvidenceKey(peerPubkey, height, view)
+	if err != nil {
+		return nil, err
+	}
+	return utils.ConcatKey(contract, key), nil
+}
+
+//ReportEvidence punishes a consensus peer proved to have equivocated. The evidence is self verifying so anyone can
+//report it, the peer is put into the black list and quits with its stake slashed at the next commitDpos,
+//which is executed at once if the peer is a consensus node.
+func ReportEvidence(native *native.NativeService) ([]byte, error) {
+	params := new(vconfig.Evidence)
+	if err := params.Deserialization(common.NewZeroCopySource(native.Input)); err != nil {
+		return utils.BYTE_FALSE, fmt.Errorf("deserialize, contract params deserialize error: %v", err)
+	}
+	contract := native.CcntmextRef.CurrentCcntmext().CcntmractAddress
+
+	if params.Height > native.Height {
+		return utils.BYTE_FALSE, fmt.Errorf("reportEvidence, evidence of future height %d", params.Height)
+	}
+
+	//check the evidence is not processed
+	evidenceKey, err := GetEvidenceKey(contract, params.PeerPubkey, params.Height, params.View)
+	if err != nil {
+		return utils.BYTE_FALSE, fmt.Errorf("getEvidenceKey, get evidence key error: %v", err)
+	}
+	processed, err := native.CacheDB.Get(evidenceKey)
+	if err != nil {
+		return utils.BYTE_FALSE, fmt.Errorf("native.CacheDB.Get, get evidence error: %v", err)
+	}
+	if processed != nil {
+		return utils.BYTE_FALSE, fmt.Errorf("reportEvidence, evidence of height %d view %d is processed",
+			params.Height, params.View)
+	}
+
+	//verify evidence
+	if err := params.Verify(); err != nil {
+		return utils.BYTE_FALSE, fmt.Errorf("reportEvidence, verify evidence error: %v", err)
+	}
+
+	//get current view
+	view, err := GetView(native, contract)
+	if err != nil {
+		return utils.BYTE_FALSE, fmt.Errorf("getView, get view error: %v", err)
+	}
+	//get peerPoolMap
+	peerPoolMap, err := GetPeerPoolMap(native, contract, view)
+	if err != nil {
+		return utils.BYTE_FALSE, fmt.Errorf("getPeerPoolMap, get peerPoolMap error: %v", err)
+	}
+	peerPoolItem, ok := peerPoolMap.PeerPoolMap[params.PeerPubkey]
+	if !ok {
+		return utils.BYTE_FALSE, fmt.Errorf("reportEvidence, peerPubkey is not in peerPoolMap")
+	}
+	if peerPoolItem.Status != ConsensusStatus && peerPoolItem.Status != CandidateStatus {
+		return utils.BYTE_FALSE, fmt.Errorf("reportEvidence, peer status is not consensus or candidate")
+	}
+	peerPubkeyPrefix, err := hex.DecodeString(params.PeerPubkey)
+	if err != nil {
+		return utils.BYTE_FALSE, fmt.Errorf("hex.DecodeString, peerPubkey format error: %v", err)
+	}
+
+	blackListItem := &BlackListItem{
+		PeerPubkey: peerPoolItem.PeerPubkey,
+		Address:    peerPoolItem.Address,
+		InitPos:    peerPoolItem.InitPos,
+	}
+	//put peer into black list
+	native.CacheDB.Put(utils.ConcatKey(contract, []byte(BLACK_LIST), peerPubkeyPrefix), cstates.GenRawStorageItem(common.SerializeToBytes(blackListItem)))
+	native.CacheDB.Put(evidenceKey, cstates.GenRawStorageItem(utils.BYTE_TRUE))
+	//change peerPool status
+	commit := peerPoolItem.Status == ConsensusStatus
+	peerPoolItem.Status = BlackStatus
+	peerPoolMap.PeerPoolMap[params.PeerPubkey] = peerPoolItem
+	err = putPeerPoolMap(native, contract, view, peerPoolMap)
+	if err != nil {
+		return utils.BYTE_FALSE, fmt.Errorf("putPeerPoolMap, put peerPoolMap error: %v", err)
+	}
+
+	//commitDpos
+	if commit {
+		err = executeCommitDpos(native, contract)
+		if err != nil {
+			return utils.BYTE_FALSE, fmt.Errorf("executeCommitDpos, executeCommitDpos error: %v", err)
+		}
+	}
+	return utils.BYTE_TRUE, nil
+}
@@ -0,0 +1,115 @@
+/*
+ * Copyright (C) 2018 The cntm Authors
+ * This file is part of The cntm library.
+ *
+ * The cntm is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The cntm is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The cntm.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package governance
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/conntectome/cntm-crypto/keypair"
+	"github.com/conntectome/cntm/account"
+	"github.com/conntectome/cntm/common"
+	vconfig "github.com/conntectome/cntm/consensus/vbft/config"
+	"github.com/conntectome/cntm/core/signature"
+	"github.com/conntectome/cntm/smartcontract/service/native"
+	"github.com/conntectome/cntm/smartcontract/service/native/testsuite"
+	"github.com/conntectome/cntm/smartcontract/service/native/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func buildTestEvidence(t *testing.T, acc *account.Account, view, height uint32) *vconfig.Evidence {
+	evidence := &vconfig.Evidence{
+		Type:       vconfig.EVIDENCE_DOUBLE_ENDORSE,
+		PeerPubkey: hex.EncodeToString(keypair.SerializePublicKey(acc.PublicKey)),
+		Height:     height,
+		View:       view,
+	}
+	for _, blockHash := range []common.Uint256{{1}, {2}} {
+		digest := vconfig.EndorsementDigest(view, height, blockHash)
+		sig, err := signature.Sign(acc, digest[:])
+		assert.Nil(t, err)
+		evidence.Endorsements = append(evidence.Endorsements, &vconfig.Endorsement{
+			View:      view,
+			Height:    height,
+			BlockHash: blockHash,
+			Sig:       sig,
+		})
+	}
+	return evidence
+}
+
+func reportEvidence(native *native.NativeService, evidence *vconfig.Evidence) error {
+	sink := common.NewZeroCopySink(nil)
+	evidence.Serialization(sink)
+	native.Input = sink.Bytes()
+	_, err := ReportEvidence(native)
+	return err
+}
+
+func TestReportEvidence(t *testing.T) {
+	testsuite.InvokeNativeCcntmract(t, utils.GovernanceCcntmractAddress, func(native *native.NativeService) ([]byte, error) {
+		contract := utils.GovernanceCcntmractAddress
+		candidate, quiting := account.NewAccount(""), account.NewAccount("")
+		peerPoolMap := &PeerPoolMap{PeerPoolMap: make(map[string]*PeerPoolItem)}
+		for i, peer := range []struct {
+			acc    *account.Account
+			status Status
+		}{{candidate, CandidateStatus}, {quiting, QuitingStatus}} {
+			peerPubkey := hex.EncodeToString(keypair.SerializePublicKey(peer.acc.PublicKey))
+			peerPoolMap.PeerPoolMap[peerPubkey] = &PeerPoolItem{
+				Index:      uint32(i + 1),
+				PeerPubkey: peerPubkey,
+				Address:    peer.acc.Address,
+				Status:     peer.status,
+				InitPos:    10000,
+			}
+		}
+		assert.Nil(t, putGovernanceView(native, contract, &GovernanceView{View: 1}))
+		assert.Nil(t, putPeerPoolMap(native, contract, 1, peerPoolMap))
+		native.Height = 20
+
+		// evidence of a future height
+		assert.NotNil(t, reportEvidence(native, buildTestEvidence(t, candidate, 1, 21)))
+		// invalid evidence
+		invalid := buildTestEvidence(t, candidate, 1, 10)
+		invalid.Endorsements[1].BlockHash = common.Uint256{3}
+		assert.NotNil(t, reportEvidence(native, invalid))
+		// peer not in consensus or candidate status
+		assert.NotNil(t, reportEvidence(native, buildTestEvidence(t, quiting, 1, 10)))
+
+		evidence := buildTestEvidence(t, candidate, 1, 10)
+		assert.Nil(t, reportEvidence(native, evidence))
+		peerPoolMap, err := GetPeerPoolMap(native, contract, 1)
+		assert.Nil(t, err)
+		assert.Equal(t, BlackStatus, peerPoolMap.PeerPoolMap[evidence.PeerPubkey].Status)
+		peerPubkeyPrefix, _ := hex.DecodeString(evidence.PeerPubkey)
+		blackList, err := native.CacheDB.Get(utils.ConcatKey(contract, []byte(BLACK_LIST), peerPubkeyPrefix))
+		assert.Nil(t, err)
+		assert.NotNil(t, blackList)
+		evidenceKey, err := GetEvidenceKey(contract, evidence.PeerPubkey, 10, 1)
+		assert.Nil(t, err)
+		processed, err := native.CacheDB.Get(evidenceKey)
+		assert.Nil(t, err)
+		assert.NotNil(t, processed)
+
+		// the evidence is processed once
+		assert.NotNil(t, reportEvidence(native, evidence))
+		return nil, nil
+	})
+}
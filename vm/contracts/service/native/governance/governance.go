@@ -74,6 +74,7 @@ const (
 	REDUCE_INIT_POS                  = "reduceInitPos"
 	SET_PROMISE_POS                  = "setPromisePos"
 	SET_GAS_ADDRESS                  = "setGasAddress"
+	REPORT_EVIDENCE                  = "reportEvidence"
 
 	//key prefix
 	GLOBAL_PARAM      = "globalParam"
@@ -150,6 +151,7 @@ func RegisterGovernanceCcntmract(native *native.NativeService) {
 	native.Register(TRANSFER_PENALTY, TransferPenalty)
 	native.Register(SET_PROMISE_POS, SetPromisePos)
 	native.Register(SET_GAS_ADDRESS, SetGasAddress)
+	native.Register(REPORT_EVIDENCE, ReportEvidence)
 }
 
 //Init governance contract, include Cbft config, global param and cntmid admin.
@@ -0,0 +1,228 @@
+// Copyright (C) 2021 The Ontology Authors
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// alcntm with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package evm
+
+import (
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// CallFrame is a single call collected by the CallTracer, with the calls it made as children.
+type CallFrame struct {
+	Type    string          `json:"type"`
+	From    common.Address  `json:"from"`
+	To      *common.Address `json:"to,omitempty"`
+	Value   *hexutil.Big    `json:"value,omitempty"`
+	Gas     hexutil.Uint64  `json:"gas"`
+	GasUsed hexutil.Uint64  `json:"gasUsed"`
+	Input   hexutil.Bytes   `json:"input"`
+	Output  hexutil.Bytes   `json:"output,omitempty"`
+	Error   string          `json:"error,omitempty"`
+	Calls   []*CallFrame    `json:"calls,omitempty"`
+
+	gasIn   uint64 // gas available when the call opcode was executed
+	gasCost uint64 // cost of the call opcode itself
+	outOff  uint64 // memory offset of the call output in the caller
+	outLen  uint64 // memory size of the call output in the caller
+}
+
+// CallTracer is a Tracer which rebuilds the call tree of a transaction, in the format of
+// the go-ethereum callTracer. Since the Tracer interface only reports single steps, entering
+// and leaving a call is detected from the call depth of consecutive steps.
+type CallTracer struct {
+	callstack []*CallFrame
+	descended bool
+}
+
+// NewCallTracer returns a new call tracer
+func NewCallTracer() *CallTracer {
+	return &CallTracer{}
+}
+
+// CaptureStart implements the Tracer interface to initialize the root call.
+func (t *CallTracer) CaptureStart(from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) {
+	typ := "CALL"
+	if create {
+		typ = "CREATE"
+	}
+	root := &CallFrame{
+		Type:  typ,
+		From:  from,
+		To:    &to,
+		Gas:   hexutil.Uint64(gas),
+		Input: common.CopyBytes(input),
+	}
+	if value != nil {
+		root.Value = (*hexutil.Big)(new(big.Int).Set(value))
+	}
+	t.callstack = []*CallFrame{root}
+}
+
+// CaptureState implements the Tracer interface, it tracks the calls entered and left by the execution.
+func (t *CallTracer) CaptureState(env *EVM, pc uint64, op OpCode, gas, cost uint64, memory *Memory,
+	stack *Stack, rStack *ReturnStack, rData []byte, ccntmract *Ccntmract, depth int, err error) {
+	if err != nil {
+		t.CaptureFault(env, pc, op, gas, cost, memory, stack, rStack, ccntmract, depth, err)
+		return
+	}
+	if len(t.callstack) == 0 {
+		return
+	}
+	// the first step after a call opcode tells whether the call entered a new contract frame
+	if t.descended {
+		if depth == len(t.callstack) {
+			t.callstack[len(t.callstack)-1].Gas = hexutil.Uint64(gas)
+		}
+		t.descended = false
+	}
+	// the execution is back in the caller, the last call is finished
+	if depth == len(t.callstack)-1 {
+		t.popCall(env, gas, memory, stack)
+	}
+	if depth != len(t.callstack) {
+		return
+	}
+	switch op {
+	case CREATE, CREATE2:
+		inOff, inLen := stack.Back(1).Uint64(), stack.Back(2).Uint64()
+		t.pushCall(&CallFrame{
+			Type:    op.String(),
+			From:    ccntmract.Address(),
+			Input:   memory.GetCopy(int64(inOff), int64(inLen)),
+			Value:   (*hexutil.Big)(stack.Back(0).ToBig()),
+			gasIn:   gas,
+			gasCost: cost,
+		})
+	case CALL, CALLCODE, DELEGATECALL, STATICCALL:
+		off := 1
+		if op == DELEGATECALL || op == STATICCALL {
+			off = 0
+		}
+		to := common.Address(stack.Back(1).Bytes20())
+		inOff, inLen := stack.Back(2+off).Uint64(), stack.Back(3+off).Uint64()
+		call := &CallFrame{
+			Type:    op.String(),
+			From:    ccntmract.Address(),
+			To:      &to,
+			Input:   memory.GetCopy(int64(inOff), int64(inLen)),
+			gasIn:   gas,
+			gasCost: cost,
+			outOff:  stack.Back(4 + off).Uint64(),
+			outLen:  stack.Back(5 + off).Uint64(),
+		}
+		if off == 1 {
+			call.Value = (*hexutil.Big)(stack.Back(2).ToBig())
+		}
+		t.pushCall(call)
+	case SELFDESTRUCT:
+		to := common.Address(stack.Back(0).Bytes20())
+		t.addCall(&CallFrame{
+			Type:  op.String(),
+			From:  ccntmract.Address(),
+			To:    &to,
+			Value: (*hexutil.Big)(env.StateDB.GetBalance(ccntmract.Address())),
+		})
+	case REVERT:
+		t.callstack[len(t.callstack)-1].Error = "execution reverted"
+	}
+}
+
+// CaptureFault implements the Tracer interface to record the error of the failing call.
+func (t *CallTracer) CaptureFault(env *EVM, pc uint64, op OpCode, gas, cost uint64, memory *Memory,
+	stack *Stack, rStack *ReturnStack, ccntmract *Ccntmract, depth int, err error) {
+	if len(t.callstack) == 0 || depth > len(t.callstack) {
+		return
+	}
+	call := t.callstack[depth-1]
+	if call.Error == "" {
+		call.Error = err.Error()
+	}
+}
+
+// CaptureEnd is called after the call finishes to finalize the root call.
+func (t *CallTracer) CaptureEnd(output []byte, gasUsed uint64, tm time.Duration, err error) {
+	if len(t.callstack) == 0 {
+		return
+	}
+	// calls aborted by an error of the enclosing frame are never popped by a step
+	for len(t.callstack) > 1 {
+		call := t.callstack[len(t.callstack)-1]
+		t.callstack = t.callstack[:len(t.callstack)-1]
+		t.addCall(call)
+	}
+	root := t.callstack[0]
+	root.GasUsed = hexutil.Uint64(gasUsed)
+	root.Output = common.CopyBytes(output)
+	if err != nil {
+		root.Error = err.Error()
+	}
+}
+
+// Result returns the root call of the traced execution.
+func (t *CallTracer) Result() *CallFrame {
+	if len(t.callstack) == 0 {
+		return nil
+	}
+	return t.callstack[0]
+}
+
+func (t *CallTracer) pushCall(call *CallFrame) {
+	t.callstack = append(t.callstack, call)
+	t.descended = true
+}
+
+// addCall appends a finished call to the children of the current call.
+func (t *CallTracer) addCall(call *CallFrame) {
+	parent := t.callstack[len(t.callstack)-1]
+	parent.Calls = append(parent.Calls, call)
+}
+
+// popCall finishes the last call with the state of the caller right after the call returned,
+// gas is what is left to the caller at this point.
+func (t *CallTracer) popCall(env *EVM, gas uint64, memory *Memory, stack *Stack) {
+	call := t.callstack[len(t.callstack)-1]
+	t.callstack = t.callstack[:len(t.callstack)-1]
+
+	// the call opcode pushed its result on top of the caller stack
+	success := stack.len() > 0 && !stack.Back(0).IsZero()
+	// the call opcode cost includes the gas forwarded to the callee, which gets the unused part
+	// back in the caller when the call returns.
+	if call.Type == CREATE.String() || call.Type == CREATE2.String() {
+		call.GasUsed = hexutil.Uint64(call.gasIn - call.gasCost - gas)
+		if success {
+			addr := common.Address(stack.Back(0).Bytes20())
+			call.To = &addr
+			call.Output = env.StateDB.GetCode(addr)
+		} else if call.Error == "" {
+			call.Error = "internal failure"
+		}
+	} else {
+		if call.Gas != 0 {
+			call.GasUsed = hexutil.Uint64(call.gasIn - call.gasCost + uint64(call.Gas) - gas)
+		}
+		if success {
+			call.Output = memory.GetCopy(int64(call.outOff), int64(call.outLen))
+		} else if call.Error == "" {
+			call.Error = "internal failure"
+		}
+	}
+	t.addCall(call)
+}
@@ -18,6 +18,7 @@
 package evm
 
 import (
+	"bytes"
 	"crypto/sha256"
 	"encoding/binary"
 	"errors"
@@ -29,6 +30,9 @@ import (
 	"github.com/ethereum/go-ethereum/crypto/blake2b"
 	"github.com/ethereum/go-ethereum/crypto/bls12381"
 	"github.com/ethereum/go-ethereum/crypto/bn256"
+	ocommon "github.com/cntmio/cntmology/common"
+	"github.com/cntmio/cntmology/core/types"
+	"github.com/cntmio/cntmology/smartccntmract/service/native/utils"
 	errors2 "github.com/cntmio/cntmology/vm/evm/errors"
 	"github.com/cntmio/cntmology/vm/evm/params"
 	"golang.org/x/crypto/ripemd160"
@@ -138,6 +142,20 @@ func RunPrecompiledCcntmract(p PrecompiledCcntmract, input []byte, suppliedGas u
 	return output, suppliedGas, err
 }
 
+// RunNativeGatewayCcntmract runs and evaluates the output of a native gateway ccntmract.
+func RunNativeGatewayCcntmract(evm *EVM, p NativeGatewayCcntmract, caller common.Address, input []byte, suppliedGas uint64, readOnly bool) (ret []byte, remainingGas uint64, err error) {
+	gasCost := p.RequiredGas(input)
+	if suppliedGas < gasCost {
+		return nil, 0, errors2.ErrOutOfGas
+	}
+	suppliedGas -= gasCost
+	output, gasUsed, err := p.Run(evm, caller, input, suppliedGas, readOnly)
+	if gasUsed > suppliedGas {
+		return nil, 0, errors2.ErrOutOfGas
+	}
+	return output, suppliedGas - gasUsed, err
+}
+
 // ECRECOVER implemented as a native ccntmract.
 type ecrecover struct{}
 
@@ -1024,3 +1042,333 @@ func (c *bls12381MapG2) Run(input []byte) ([]byte, error) {
 	// Encode the G2 point to 256 bytes
 	return g.EncodePoint(r), nil
 }
+
+// NativeGatewayCcntmract is a precompiled ccntmract forwarding calls to the native ccntmracts.
+// Unlike PrecompiledCcntmract it needs the evm and the caller, since native ccntmracts
+// change the state and check the witness of the caller. The gas of the native execution is
+// only known after the invocation, so Run reports it besides the RequiredGas charged ahead.
+type NativeGatewayCcntmract interface {
+	RequiredGas(input []byte) uint64                                                                  // RequiredPrice calculates the gas charged before running the ccntmract
+	Run(evm *EVM, caller common.Address, input []byte, gas uint64, readOnly bool) ([]byte, uint64, error) // Run runs the gateway ccntmract and returns the gas used by the native ccntmract
+}
+
+// The native gateway ccntmracts are reserved at 0x0000...0100 - 0x0000...02ff.
+// 0x0000...01XX dispatches invoke(string,bytes) to the native ccntmract 0x0000...00XX,
+// 0x0000...02XX is the ERC-20 facade of the native token 0x0000...00XX.
+const (
+	NativeGatewayPrefix byte = 0x01
+	NativeTokenPrefix   byte = 0x02
+)
+
+var (
+	errNativeGatewayInput  = errors.New("invalid native gateway input")
+	errNativeGatewayMethod = errors.New("native gateway method not supported")
+	errNativeGatewayValue  = errors.New("native gateway does not accept value")
+	errNativeTokenAmount   = errors.New("native token amount overflow")
+
+	nativeInvokeSelector = methodSelector("invoke(string,bytes)")
+	revertSelector       = methodSelector("Error(string)")
+
+	erc20NameSelector         = methodSelector("name()")
+	erc20SymbolSelector       = methodSelector("symbol()")
+	erc20DecimalsSelector     = methodSelector("decimals()")
+	erc20TotalSupplySelector  = methodSelector("totalSupply()")
+	erc20BalanceOfSelector    = methodSelector("balanceOf(address)")
+	erc20AllowanceSelector    = methodSelector("allowance(address,address)")
+	erc20TransferSelector     = methodSelector("transfer(address,uint256)")
+	erc20ApproveSelector      = methodSelector("approve(address,uint256)")
+	erc20TransferFromSelector = methodSelector("transferFrom(address,address,uint256)")
+
+	erc20TransferEvent = crypto.Keccak256Hash([]byte("Transfer(address,address,uint256)"))
+	erc20ApprovalEvent = crypto.Keccak256Hash([]byte("Approval(address,address,uint256)"))
+)
+
+// NativeGatewayAddress returns the address through which evm calls the native ccntmract
+func NativeGatewayAddress(ccntmract ocommon.Address) common.Address {
+	return common.BytesToAddress([]byte{NativeGatewayPrefix, ccntmract[ocommon.ADDR_LEN-1]})
+}
+
+// NativeTokenAddress returns the address of the ERC-20 facade of the native token
+func NativeTokenAddress(token ocommon.Address) common.Address {
+	return common.BytesToAddress([]byte{NativeTokenPrefix, token[ocommon.ADDR_LEN-1]})
+}
+
+func nativeGatewayCcntmract(addr common.Address) (NativeGatewayCcntmract, bool) {
+	if !allZero(addr[:common.AddressLength-2]) {
+		return nil, false
+	}
+	ccntmract := common.BytesToAddress(addr[common.AddressLength-1:])
+	switch addr[common.AddressLength-2] {
+	case NativeGatewayPrefix:
+		if utils.IsNativeCcntmract(ocommon.Address(ccntmract)) {
+			return &nativeInvoke{ccntmract: ccntmract}, true
+		}
+	case NativeTokenPrefix:
+		switch ocommon.Address(ccntmract) {
+		case utils.OntCcntmractAddress, utils.OngCcntmractAddress:
+			return &nativeToken{address: addr, ccntmract: ccntmract}, true
+		}
+	}
+	return nil, false
+}
+
+// nativeInvoke dispatches invoke(string method, bytes args) to a native ccntmract. The args
+// are in the native serialization format of the method, the result is returned as bytes.
+type nativeInvoke struct {
+	ccntmract common.Address
+}
+
+// RequiredGas only charges the input, the native execution is charged by the invoker.
+func (c *nativeInvoke) RequiredGas(input []byte) uint64 {
+	return uint64(len(input)+31) / 32 * params.NativeGatewayPerWordGas
+}
+
+func (c *nativeInvoke) Run(evm *EVM, caller common.Address, input []byte, gas uint64, readOnly bool) ([]byte, uint64, error) {
+	// native ccntmracts have no view methods, any of them may change the state
+	if readOnly {
+		return nil, 0, errors2.ErrWriteProtection
+	}
+	if len(input) < 4 || string(input[:4]) != nativeInvokeSelector {
+		return nil, 0, errNativeGatewayMethod
+	}
+	method, err := abiReadBytes(input[4:], 0)
+	if err != nil {
+		return nil, 0, err
+	}
+	args, err := abiReadBytes(input[4:], 1)
+	if err != nil {
+		return nil, 0, err
+	}
+	ret, gasUsed, err := evm.Ccntmext.InvokeNative(caller, c.ccntmract, string(method), args, gas)
+	if err != nil {
+		return revertReason(err.Error()), gasUsed, errors2.ErrExecutionReverted
+	}
+	return abiEncodeBytes(ret), gasUsed, nil
+}
+
+// nativeToken is the ERC-20 facade of a native token, the caller is the owner of transfer and approve
+// and the sender of transferFrom.
+type nativeToken struct {
+	address   common.Address
+	ccntmract common.Address
+}
+
+func (c *nativeToken) RequiredGas(input []byte) uint64 {
+	if len(input) >= 4 {
+		switch string(input[:4]) {
+		case erc20TransferSelector, erc20ApproveSelector, erc20TransferFromSelector:
+			return params.NativeTokenWriteGas
+		}
+	}
+	return params.NativeTokenReadGas
+}
+
+func (c *nativeToken) Run(evm *EVM, caller common.Address, input []byte, gas uint64, readOnly bool) ([]byte, uint64, error) {
+	if len(input) < 4 {
+		return nil, 0, errNativeGatewayMethod
+	}
+	selector, input := string(input[:4]), input[4:]
+	switch selector {
+	case erc20NameSelector, erc20SymbolSelector:
+		method := "name"
+		if selector == erc20SymbolSelector {
+			method = "symbol"
+		}
+		ret, gasUsed, err := c.invoke(evm, caller, method, nil, gas)
+		if err != nil {
+			return ret, gasUsed, err
+		}
+		return abiEncodeBytes(ret), gasUsed, nil
+	case erc20DecimalsSelector:
+		return c.invokeUint(evm, caller, "decimals", nil, gas)
+	case erc20TotalSupplySelector:
+		return c.invokeUint(evm, caller, "totalSupply", nil, gas)
+	case erc20BalanceOfSelector:
+		owner, err := abiReadAddress(input, 0)
+		if err != nil {
+			return nil, 0, err
+		}
+		return c.invokeUint(evm, caller, "balanceOf", nativeTokenArgs(nil, owner), gas)
+	case erc20AllowanceSelector:
+		owner, err := abiReadAddress(input, 0)
+		if err != nil {
+			return nil, 0, err
+		}
+		spender, err := abiReadAddress(input, 1)
+		if err != nil {
+			return nil, 0, err
+		}
+		return c.invokeUint(evm, caller, "allowance", nativeTokenArgs(nil, owner, spender), gas)
+	}
+
+	if readOnly {
+		return nil, 0, errors2.ErrWriteProtection
+	}
+	switch selector {
+	case erc20TransferSelector:
+		to, value, err := abiReadAddressAmount(input, 0)
+		if err != nil {
+			return nil, 0, err
+		}
+		// the native transfer takes a list of transfers
+		args := append(nativeTokenCount(1), nativeTokenArgs(&value, caller, to)...)
+		return c.invokeBool(evm, caller, "transfer", args, gas, func() {
+			c.addLog(evm, erc20TransferEvent, caller, to, value)
+		})
+	case erc20ApproveSelector:
+		spender, value, err := abiReadAddressAmount(input, 0)
+		if err != nil {
+			return nil, 0, err
+		}
+		return c.invokeBool(evm, caller, "approve", nativeTokenArgs(&value, caller, spender), gas, func() {
+			c.addLog(evm, erc20ApprovalEvent, caller, spender, value)
+		})
+	case erc20TransferFromSelector:
+		from, err := abiReadAddress(input, 0)
+		if err != nil {
+			return nil, 0, err
+		}
+		to, value, err := abiReadAddressAmount(input, 1)
+		if err != nil {
+			return nil, 0, err
+		}
+		return c.invokeBool(evm, caller, "transferFrom", nativeTokenArgs(&value, caller, from, to), gas, func() {
+			c.addLog(evm, erc20TransferEvent, from, to, value)
+		})
+	}
+	return nil, 0, errNativeGatewayMethod
+}
+
+func (c *nativeToken) invoke(evm *EVM, caller common.Address, method string, args []byte, gas uint64) ([]byte, uint64, error) {
+	ret, gasUsed, err := evm.Ccntmext.InvokeNative(caller, c.ccntmract, method, args, gas)
+	if err != nil {
+		return revertReason(err.Error()), gasUsed, errors2.ErrExecutionReverted
+	}
+	return ret, gasUsed, nil
+}
+
+func (c *nativeToken) invokeUint(evm *EVM, caller common.Address, method string, args []byte, gas uint64) ([]byte, uint64, error) {
+	ret, gasUsed, err := c.invoke(evm, caller, method, args, gas)
+	if err != nil {
+		return ret, gasUsed, err
+	}
+	value := ocommon.BigIntFromNeoBytes(ret)
+	if value.Sign() < 0 {
+		return nil, gasUsed, errNativeTokenAmount
+	}
+	return math.U256Bytes(value), gasUsed, nil
+}
+
+// invokeBool runs a state changing method, the native token returns false instead of an error
+// for some rejected requests, like zero amount. onSuccess is called only if true is returned.
+func (c *nativeToken) invokeBool(evm *EVM, caller common.Address, method string, args []byte, gas uint64, onSuccess func()) ([]byte, uint64, error) {
+	ret, gasUsed, err := c.invoke(evm, caller, method, args, gas)
+	if err != nil {
+		return ret, gasUsed, err
+	}
+	if !bytes.Equal(ret, utils.BYTE_TRUE) {
+		return false32Byte, gasUsed, nil
+	}
+	onSuccess()
+	return true32Byte, gasUsed, nil
+}
+
+func (c *nativeToken) addLog(evm *EVM, event common.Hash, from, to common.Address, value uint64) {
+	data := make([]byte, 32)
+	binary.BigEndian.PutUint64(data[24:], value)
+	evm.StateDB.AddLog(&types.StorageLog{
+		Address: c.address,
+		Topics:  []common.Hash{event, common.BytesToHash(from[:]), common.BytesToHash(to[:])},
+		Data:    data,
+	})
+}
+
+// nativeTokenArgs serializes the addresses followed by the value if any, which is the
+// parameter format of the native token methods
+func nativeTokenArgs(value *uint64, addrs ...common.Address) []byte {
+	buf := new(bytes.Buffer)
+	for _, addr := range addrs {
+		// writing to bytes.Buffer never fails
+		_ = utils.WriteAddress(buf, ocommon.Address(addr))
+	}
+	if value != nil {
+		_ = utils.WriteVarUint(buf, *value)
+	}
+	return buf.Bytes()
+}
+
+func nativeTokenCount(count uint64) []byte {
+	buf := new(bytes.Buffer)
+	_ = utils.WriteVarUint(buf, count)
+	return buf.Bytes()
+}
+
+func methodSelector(sig string) string {
+	return string(crypto.Keccak256([]byte(sig))[:4])
+}
+
+// revertReason encodes msg as Error(string), which solidity decodes as the revert reason
+func revertReason(msg string) []byte {
+	return append([]byte(revertSelector), abiEncodeBytes([]byte(msg))...)
+}
+
+func abiWord(input []byte, index int) ([]byte, error) {
+	start := index * 32
+	if start+32 > len(input) {
+		return nil, errNativeGatewayInput
+	}
+	return input[start : start+32], nil
+}
+
+func abiReadAddress(input []byte, index int) (common.Address, error) {
+	word, err := abiWord(input, index)
+	if err != nil {
+		return common.Address{}, err
+	}
+	if !allZero(word[:32-common.AddressLength]) {
+		return common.Address{}, errNativeGatewayInput
+	}
+	return common.BytesToAddress(word[32-common.AddressLength:]), nil
+}
+
+// abiReadAddressAmount reads an address and an uint256 amount, which must fit in uint64
+func abiReadAddressAmount(input []byte, index int) (common.Address, uint64, error) {
+	addr, err := abiReadAddress(input, index)
+	if err != nil {
+		return common.Address{}, 0, err
+	}
+	word, err := abiWord(input, index+1)
+	if err != nil {
+		return common.Address{}, 0, err
+	}
+	if !allZero(word[:24]) {
+		return common.Address{}, 0, errNativeTokenAmount
+	}
+	return addr, binary.BigEndian.Uint64(word[24:]), nil
+}
+
+// abiReadBytes reads the dynamic bytes or string whose offset is at word index
+func abiReadBytes(input []byte, index int) ([]byte, error) {
+	word, err := abiWord(input, index)
+	if err != nil {
+		return nil, err
+	}
+	offset := new(big.Int).SetBytes(word)
+	if !offset.IsUint64() || offset.Uint64()+32 > uint64(len(input)) {
+		return nil, errNativeGatewayInput
+	}
+	start := offset.Uint64() + 32
+	length := new(big.Int).SetBytes(input[start-32 : start])
+	if !length.IsUint64() || length.Uint64() > uint64(len(input))-start {
+		return nil, errNativeGatewayInput
+	}
+	return input[start : start+length.Uint64()], nil
+}
+
+func abiEncodeBytes(data []byte) []byte {
+	ret := make([]byte, 64+(len(data)+31)/32*32)
+	ret[31] = 32
+	binary.BigEndian.PutUint64(ret[56:64], uint64(len(data)))
+	copy(ret[64:], data)
+	return ret
+}
@@ -44,6 +44,10 @@ type (
 	// GetHashFunc returns the n'th block hash in the blockchain
 	// and is used by the BLOCKHASH EVM op code.
 	GetHashFunc func(uint64) common.Hash
+	// InvokeNativeFunc invokes the method of a native ccntmract with caller as the witness
+	// and is used by the native gateway ccntmracts. It returns the gas used out of the supplied
+	// gas, which may exceed it if the invocation runs out of gas.
+	InvokeNativeFunc func(caller common.Address, ccntmract common.Address, method string, args []byte, gas uint64) ([]byte, uint64, error)
 )
 
 // ActivePrecompiles returns the addresses of the precompiles enabled with the current
@@ -77,6 +81,37 @@ func (evm *EVM) precompile(addr common.Address) (PrecompiledCcntmract, bool) {
 	return p, ok
 }
 
+func (evm *EVM) nativeGateway(addr common.Address) (NativeGatewayCcntmract, bool) {
+	if evm.Ccntmext.InvokeNative == nil {
+		return nil, false
+	}
+	return nativeGatewayCcntmract(addr)
+}
+
+// readOnly reports whether the current call is in a static ccntmext
+func (evm *EVM) readOnly() bool {
+	in, ok := evm.interpreter.(*EVMInterpreter)
+	return ok && in.readOnly
+}
+
+// callNativeGateway runs the native gateway ccntmract and reverts the state in case of an
+// execution error. The gateway does not accept value since the native ccntmracts can not
+// receive it.
+func (evm *EVM) callNativeGateway(p NativeGatewayCcntmract, caller common.Address, input []byte, gas uint64, value *big.Int, readOnly bool) (ret []byte, leftOverGas uint64, err error) {
+	if value != nil && value.Sign() != 0 {
+		return nil, gas, errNativeGatewayValue
+	}
+	snapshot := evm.StateDB.Snapshot()
+	ret, gas, err = RunNativeGatewayCcntmract(evm, p, caller, input, gas, readOnly)
+	if err != nil {
+		evm.StateDB.RevertToSnapshot(snapshot)
+		if err != errors.ErrExecutionReverted {
+			gas = 0
+		}
+	}
+	return ret, gas, err
+}
+
 // BlockCcntmext provides the EVM with auxiliary information. Once provided
 // it shouldn't be modified.
 type BlockCcntmext struct {
@@ -87,6 +122,8 @@ type BlockCcntmext struct {
 	Transfer TransferFunc
 	// GetHash returns the hash corresponding to n
 	GetHash GetHashFunc
+	// InvokeNative invokes the native ccntmracts, the native gateway is disabled if nil
+	InvokeNative InvokeNativeFunc
 
 	// Block information
 	Coinbase    common.Address // Provides information for COINBASE
@@ -197,6 +234,9 @@ func (evm *EVM) Call(caller CcntmractRef, addr common.Address, input []byte, gas
 	if value.Sign() != 0 && !evm.Ccntmext.CanTransfer(evm.StateDB, caller.Address(), value) {
 		return nil, gas, errors.ErrInsufficientBalance
 	}
+	if g, isGateway := evm.nativeGateway(addr); isGateway {
+		return evm.callNativeGateway(g, caller.Address(), input, gas, value, evm.readOnly())
+	}
 	snapshot := evm.StateDB.Snapshot()
 	p, isPrecompile := evm.precompile(addr)
 
@@ -273,6 +313,9 @@ func (evm *EVM) CallCode(caller CcntmractRef, addr common.Address, input []byte,
 	if !evm.Ccntmext.CanTransfer(evm.StateDB, caller.Address(), value) {
 		return nil, gas, errors.ErrInsufficientBalance
 	}
+	if g, isGateway := evm.nativeGateway(addr); isGateway {
+		return evm.callNativeGateway(g, caller.Address(), input, gas, value, evm.readOnly())
+	}
 	var snapshot = evm.StateDB.Snapshot()
 
 	// It is allowed to call precompiles, even via delegatecall
@@ -309,6 +352,9 @@ func (evm *EVM) DelegateCall(caller CcntmractRef, addr common.Address, input []b
 	if evm.depth > int(params.CallCreateDepth) {
 		return nil, gas, errors.ErrDepth
 	}
+	if g, isGateway := evm.nativeGateway(addr); isGateway {
+		return evm.callNativeGateway(g, caller.Address(), input, gas, nil, evm.readOnly())
+	}
 	var snapshot = evm.StateDB.Snapshot()
 
 	// It is allowed to call precompiles, even via delegatecall
@@ -343,6 +389,9 @@ func (evm *EVM) StaticCall(caller CcntmractRef, addr common.Address, input []byt
 	if evm.depth > int(params.CallCreateDepth) {
 		return nil, gas, errors.ErrDepth
 	}
+	if g, isGateway := evm.nativeGateway(addr); isGateway {
+		return evm.callNativeGateway(g, caller.Address(), input, gas, nil, true)
+	}
 	// We take a snapshot here. This is a bit counter-intuitive, and could probably be skipped.
 	// However, even a staticcall is considered a 'touch'. On mainnet, static calls were introduced
 	// after all empty accounts were deleted, so this is not required. However, if we omit this,
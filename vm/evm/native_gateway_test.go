@@ -0,0 +1,197 @@
+/*
+ * Copyright (C) 2018 The cntmology Authors
+ * This file is part of The cntmology library.
+ *
+ * The cntmology is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The cntmology is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * alcntm with The cntmology.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package evm
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/cntmio/cntmology/core/store/leveldbstore"
+	"github.com/cntmio/cntmology/core/store/overlaydb"
+	"github.com/cntmio/cntmology/smartccntmract/service/native/cntm"
+	"github.com/cntmio/cntmology/smartccntmract/service/native/utils"
+	"github.com/cntmio/cntmology/smartccntmract/storage"
+	errors2 "github.com/cntmio/cntmology/vm/evm/errors"
+	"github.com/cntmio/cntmology/vm/evm/params"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+)
+
+type nativeCall struct {
+	caller    common.Address
+	ccntmract common.Address
+	method    string
+	args      []byte
+}
+
+func newGatewayEVM(invoke InvokeNativeFunc) *EVM {
+	db := storage.NewCacheDB(overlaydb.NewOverlayDB(leveldbstore.NewMemLevelDBStore()))
+	statedb := storage.NewStateDB(db, common.Hash{}, common.Hash{}, cntm.OngBalanceHandle{})
+	vmctx := BlockCcntmext{
+		CanTransfer:  func(StateDB, common.Address, *big.Int) bool { return true },
+		Transfer:     func(StateDB, common.Address, common.Address, *big.Int) {},
+		InvokeNative: invoke,
+	}
+	return NewEVM(vmctx, TxCcntmext{}, statedb, params.AllEthashProtocolChanges, Config{})
+}
+
+func abiInput(selector string, words ...[]byte) []byte {
+	input := []byte(selector)
+	for _, word := range words {
+		input = append(input, common.LeftPadBytes(word, 32)...)
+	}
+	return input
+}
+
+// nativeInvokeInput encodes invoke(string,bytes)
+func nativeInvokeInput(method string, args []byte) []byte {
+	encMethod := abiEncodeBytes([]byte(method))[32:]
+	input := abiInput(nativeInvokeSelector, big.NewInt(64).Bytes(), big.NewInt(int64(64+len(encMethod))).Bytes())
+	input = append(input, encMethod...)
+	return append(input, abiEncodeBytes(args)[32:]...)
+}
+
+func TestNativeTokenTransfer(t *testing.T) {
+	var calls []nativeCall
+	vmenv := newGatewayEVM(func(caller, ccntmract common.Address, method string, args []byte, gas uint64) ([]byte, uint64, error) {
+		calls = append(calls, nativeCall{caller, ccntmract, method, args})
+		return utils.BYTE_TRUE, 5000, nil
+	})
+	caller := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	to := common.HexToAddress("0x2222222222222222222222222222222222222222")
+	token := NativeTokenAddress(utils.OngCcntmractAddress)
+
+	input := abiInput(erc20TransferSelector, to[:], big.NewInt(100).Bytes())
+	ret, gas, err := vmenv.Call(AccountRef(caller), token, input, 100000, new(big.Int))
+	if err != nil {
+		t.Fatalf("transfer failed: %v", err)
+	}
+	if !bytes.Equal(ret, true32Byte) {
+		t.Errorf("transfer result mismatch: have %x", ret)
+	}
+	if used := 100000 - gas; used != params.NativeTokenWriteGas+5000 {
+		t.Errorf("gas used mismatch: have %v, want %v", used, params.NativeTokenWriteGas+5000)
+	}
+	if len(calls) != 1 || calls[0].method != "transfer" || calls[0].caller != caller ||
+		calls[0].ccntmract != common.Address(utils.OngCcntmractAddress) {
+		t.Fatalf("native call mismatch: %+v", calls)
+	}
+	value := uint64(100)
+	expected := append(nativeTokenCount(1), nativeTokenArgs(&value, caller, to)...)
+	if !bytes.Equal(calls[0].args, expected) {
+		t.Errorf("native args mismatch: have %x, want %x", calls[0].args, expected)
+	}
+	logs := vmenv.StateDB.(*storage.StateDB).GetLogs()
+	if len(logs) != 1 || logs[0].Address != token || logs[0].Topics[0] != erc20TransferEvent {
+		t.Errorf("transfer log mismatch: %+v", logs)
+	}
+}
+
+func TestNativeTokenBalanceOf(t *testing.T) {
+	vmenv := newGatewayEVM(func(caller, ccntmract common.Address, method string, args []byte, gas uint64) ([]byte, uint64, error) {
+		return []byte{0x00, 0x01}, 0, nil // 256 in neo bytes
+	})
+	owner := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	input := abiInput(erc20BalanceOfSelector, owner[:])
+	ret, _, err := vmenv.StaticCall(AccountRef(owner), NativeTokenAddress(utils.OntCcntmractAddress), input, 100000)
+	if err != nil {
+		t.Fatalf("balanceOf failed: %v", err)
+	}
+	if !bytes.Equal(ret, math.U256Bytes(big.NewInt(256))) {
+		t.Errorf("balanceOf result mismatch: have %x", ret)
+	}
+}
+
+func TestNativeGatewayWriteProtection(t *testing.T) {
+	vmenv := newGatewayEVM(func(caller, ccntmract common.Address, method string, args []byte, gas uint64) ([]byte, uint64, error) {
+		t.Fatalf("native ccntmract should not be invoked in static call")
+		return nil, 0, nil
+	})
+	caller := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	input := abiInput(erc20TransferSelector, caller[:], big.NewInt(1).Bytes())
+	_, _, err := vmenv.StaticCall(AccountRef(caller), NativeTokenAddress(utils.OntCcntmractAddress), input, 100000)
+	if err != errors2.ErrWriteProtection {
+		t.Errorf("error mismatch: have %v, want %v", err, errors2.ErrWriteProtection)
+	}
+}
+
+func TestNativeGatewayInvoke(t *testing.T) {
+	vmenv := newGatewayEVM(func(caller, ccntmract common.Address, method string, args []byte, gas uint64) ([]byte, uint64, error) {
+		if method != "getGlobalParam" {
+			return nil, 1000, errors.New("unknown method")
+		}
+		return args, 3000, nil
+	})
+	caller := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	gateway := NativeGatewayAddress(utils.ParamCcntmractAddress)
+
+	args := []byte{1, 2, 3}
+	input := nativeInvokeInput("getGlobalParam", args)
+	ret, gas, err := vmenv.Call(AccountRef(caller), gateway, input, 100000, new(big.Int))
+	if err != nil {
+		t.Fatalf("invoke failed: %v", err)
+	}
+	if !bytes.Equal(ret, abiEncodeBytes(args)) {
+		t.Errorf("invoke result mismatch: have %x", ret)
+	}
+	inputGas := uint64(len(input)+31) / 32 * params.NativeGatewayPerWordGas
+	if used := 100000 - gas; used != inputGas+3000 {
+		t.Errorf("gas used mismatch: have %v, want %v", used, inputGas+3000)
+	}
+
+	input = nativeInvokeInput("unknown", args)
+	ret, gas, err = vmenv.Call(AccountRef(caller), gateway, input, 100000, new(big.Int))
+	if err != errors2.ErrExecutionReverted {
+		t.Fatalf("error mismatch: have %v, want %v", err, errors2.ErrExecutionReverted)
+	}
+	if !bytes.Equal(ret, revertReason("unknown method")) {
+		t.Errorf("revert reason mismatch: have %x", ret)
+	}
+	if gas == 0 {
+		t.Errorf("reverted call should not consume all gas")
+	}
+}
+
+func TestNativeGatewayOutOfGas(t *testing.T) {
+	vmenv := newGatewayEVM(func(caller, ccntmract common.Address, method string, args []byte, gas uint64) ([]byte, uint64, error) {
+		// the invoker reports the whole cost even if the supplied gas is exceeded
+		return nil, gas + 1, errors.New("out of gas")
+	})
+	caller := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	input := nativeInvokeInput("transfer", nil)
+	_, gas, err := vmenv.Call(AccountRef(caller), NativeGatewayAddress(utils.OntCcntmractAddress), input, 100000, new(big.Int))
+	if err != errors2.ErrOutOfGas {
+		t.Fatalf("error mismatch: have %v, want %v", err, errors2.ErrOutOfGas)
+	}
+	if gas != 0 {
+		t.Errorf("out of gas call should consume all gas, left %v", gas)
+	}
+}
+
+func TestNativeGatewayDisabled(t *testing.T) {
+	vmenv := newGatewayEVM(nil)
+	if _, ok := vmenv.nativeGateway(NativeTokenAddress(utils.OntCcntmractAddress)); ok {
+		t.Errorf("native gateway should be disabled without InvokeNative")
+	}
+	if _, ok := nativeGatewayCcntmract(NativeTokenAddress(utils.ParamCcntmractAddress)); ok {
+		t.Errorf("only the native tokens have ERC-20 facades")
+	}
+}
@@ -135,6 +135,12 @@ const (
 	Bls12381PairingPerPairGas uint64 = 23000  // Per-point pair gas price for BLS12-381 elliptic curve pairing check
 	Bls12381MapG1Gas          uint64 = 5500   // Gas price for BLS12-381 mapping field element to G1 operation
 	Bls12381MapG2Gas          uint64 = 110000 // Gas price for BLS12-381 mapping field element to G2 operation
+
+	NativeGatewayPerWordGas uint64 = 3     // Per-word price for the native gateway input
+	NativeInvokeGas         uint64 = 1000  // Price for a native ccntmract invocation, charged on top of the gas metered by the native ccntmract
+	NativeStorePerKBGas     uint64 = 4000  // Per-kilobyte price for the storage written by a native ccntmract invocation
+	NativeTokenReadGas      uint64 = 2000  // Price for a view method of the native token facades, excluding the native invocation
+	NativeTokenWriteGas     uint64 = 20000 // Price for transfer, approve and transferFrom of the native token facades, excluding the native invocation
 )
 
 // Gas discount table for BLS12-381 G1 and G2 multi exponentiation operations
@@ -1091,3 +1091,50 @@ func TestCreateOnDeletedAddress(t *testing.T) {
 	a.Nil(err, "fail")
 	a.True((big.NewInt(0).SetBytes(ret).Cmp(big.NewInt(0)) == 0), "should not get previous value 0x1234")
 }
+
+func TestCallTracer(t *testing.T) {
+	db := storage.NewCacheDB(overlaydb.NewOverlayDB(leveldbstore.NewMemLevelDBStore()))
+	statedb := storage.NewStateDB(db, common.Hash{}, common.Hash{}, cntm.OngBalanceHandle{})
+	caller, callee := common.HexToAddress("0x0a"), common.HexToAddress("0x0b")
+	// caller does staticcall(gas, 0x0b, 0, 0, 0, 0) and stops
+	statedb.SetCode(caller, []byte{
+		byte(evm.PUSH1), 0,
+		byte(evm.PUSH1), 0,
+		byte(evm.PUSH1), 0,
+		byte(evm.PUSH1), 0,
+		byte(evm.PUSH1), 0x0b,
+		byte(evm.GAS),
+		byte(evm.STATICCALL),
+		byte(evm.STOP),
+	})
+	// callee reverts with an empty reason
+	statedb.SetCode(callee, []byte{
+		byte(evm.PUSH1), 0,
+		byte(evm.PUSH1), 0,
+		byte(evm.REVERT),
+	})
+	tracer := evm.NewCallTracer()
+	_, _, err := Call(caller, nil, &Config{State: statedb,
+		GasLimit:    100000,
+		ChainConfig: params.AllEthashProtocolChanges,
+		EVMConfig: evm.Config{
+			Debug:  true,
+			Tracer: tracer,
+		}})
+	require.Nil(t, err)
+
+	root := tracer.Result()
+	require.NotNil(t, root)
+	require.Equal(t, "CALL", root.Type)
+	require.Equal(t, caller, *root.To)
+	require.Empty(t, root.Error)
+	require.Len(t, root.Calls, 1)
+
+	call := root.Calls[0]
+	require.Equal(t, "STATICCALL", call.Type)
+	require.Equal(t, caller, call.From)
+	require.Equal(t, callee, *call.To)
+	require.Equal(t, "execution reverted", call.Error)
+	require.True(t, call.Gas > 0)
+	require.True(t, call.GasUsed > 0 && call.GasUsed < call.Gas)
+}